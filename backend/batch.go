@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BatchHandler formats or minifies many snippets in one request. Items are
+// processed concurrently by a bounded pool of workers and each one gets its
+// own result, so a bad item never fails the rest of the batch.
+func (h *Handlers) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Validate request
+	if !h.validateRequestSize(w, r, h.config.Batch.MaxSize) {
+		return
+	}
+
+	items, err := decodeBatchItems(r)
+	if err != nil {
+		h.respondError(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if len(items) == 0 {
+		h.respondError(w, "Batch must contain at least one item", http.StatusBadRequest)
+		return
+	}
+	if len(items) > h.config.Batch.MaxItems {
+		h.respondError(w, fmt.Sprintf("Batch exceeds the maximum of %d items", h.config.Batch.MaxItems), http.StatusBadRequest)
+		return
+	}
+
+	results := h.processBatch(r, items)
+
+	response := BatchResponse{
+		Success:   true,
+		Results:   results,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	for _, result := range results {
+		if result.Success {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// decodeBatchItems reads either {"items": [...]} or a bare array of items
+func decodeBatchItems(r *http.Request) ([]BatchItem, error) {
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		var items []BatchItem
+		err := json.Unmarshal(trimmed, &items)
+		return items, err
+	}
+
+	var req BatchRequest
+	err := json.Unmarshal(body, &req)
+	return req.Items, err
+}
+
+// processBatch runs every item through the worker pool and returns the
+// results in item order. Items not yet started when the client goes away
+// are reported as canceled.
+func (h *Handlers) processBatch(r *http.Request, items []BatchItem) []BatchResult {
+	formatter := NewFormatter()
+	results := make([]BatchResult, len(items))
+
+	workers := h.config.Batch.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				results[index] = h.processBatchItem(formatter, &items[index])
+			}
+		}()
+	}
+
+	ctx := r.Context()
+	next := 0
+feed:
+	for ; next < len(items); next++ {
+		select {
+		case jobs <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for ; next < len(items); next++ {
+		results[next] = BatchResult{ID: items[next].ID, Response: Response{Error: "Request canceled"}}
+	}
+	return results
+}
+
+// processBatchItem processes one item, turning a panic in a backend into
+// an error for that item alone
+func (h *Handlers) processBatchItem(formatter *Formatter, item *BatchItem) (result BatchResult) {
+	result.ID = item.ID
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Batch item %q panicked: %v", item.ID, r)
+			result.Response = Response{Error: "Internal error while processing item"}
+		}
+	}()
+
+	operation := item.Operation
+	if operation == "" {
+		operation = operationFormat
+	}
+	req := Request{
+		Code:          item.Code,
+		Language:      item.Language,
+		Filename:      item.Filename,
+		Mode:          item.Mode,
+		Options:       item.Options,
+		From:          item.From,
+		To:            item.To,
+		RedactSecrets: item.RedactSecrets,
+	}
+	result.Response = h.processSnippet(formatter, &req, operation)
+	return result
+}
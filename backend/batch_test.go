@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBatchHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		maxItems   int
+		wantStatus int
+		wantError  string
+		wantCodes  []string
+		wantFailed int
+	}{
+		{
+			"items object",
+			`{"items":[{"id":"a","code":"{\"x\":1}","language":"json","operation":"minify"},{"id":"b","code":"a{color:red}","language":"css"}]}`,
+			10, http.StatusOK, "", []string{`{"x":1}`, "a {\n  color: red;\n}\n"}, 0,
+		},
+		{
+			"bare array with a bad item",
+			`[{"id":"a","code":"{","language":"json"},{"id":"b","code":"x = 1","language":"cobol"},{"id":"c","code":"let a=1","language":"javascript"}]`,
+			10, http.StatusOK, "", []string{"", "", "let a = 1;\n"}, 2,
+		},
+		{"empty batch", `{"items":[]}`, 10, http.StatusBadRequest, "Batch must contain at least one item", nil, 0},
+		{
+			"too many items",
+			`[{"code":"1","language":"json"},{"code":"2","language":"json"},{"code":"3","language":"json"}]`,
+			2, http.StatusBadRequest, "Batch exceeds the maximum of 2 items", nil, 0,
+		},
+		{"invalid JSON", `{"items":`, 10, http.StatusBadRequest, "Invalid JSON payload", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := LoadConfig()
+			config.Batch.MaxItems = tt.maxItems
+			config.Batch.Workers = 2
+			h := &Handlers{config: config}
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/batch", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h.BatchHandler(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantError != "" {
+				var response Response
+				if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Error != tt.wantError {
+					t.Errorf("error %q, want %q", response.Error, tt.wantError)
+				}
+				return
+			}
+
+			var response BatchResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(response.Results) != len(tt.wantCodes) {
+				t.Fatalf("%d results, want %d", len(response.Results), len(tt.wantCodes))
+			}
+			for i, result := range response.Results {
+				if want := string(rune('a' + i)); result.ID != want {
+					t.Errorf("result %d has ID %q, want %q", i, result.ID, want)
+				}
+				if result.Code != tt.wantCodes[i] || result.Success != (tt.wantCodes[i] != "") {
+					t.Errorf("result %d = %+v, want code %q", i, result, tt.wantCodes[i])
+				}
+			}
+			if response.Failed != tt.wantFailed || response.Succeeded != len(tt.wantCodes)-tt.wantFailed {
+				t.Errorf("succeeded %d and failed %d, want %d failed", response.Succeeded, response.Failed, tt.wantFailed)
+			}
+		})
+	}
+}
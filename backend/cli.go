@@ -0,0 +1,514 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CLI exit codes
+const (
+	exitOK      = 0
+	exitChanged = 1 // check, -l or --diff found files that are not formatted
+	exitError   = 2
+)
+
+// cliCommands are the subcommands that run the formatter from the command
+// line instead of starting the server
+var cliCommands = map[string]bool{
+	"fmt":     true,
+	"minify":  true,
+	"check":   true,
+	"convert": true,
+	"types":   true,
+	"help":    true,
+}
+
+// defaultExcludes are directories never descended into
+var defaultExcludes = []string{".git", ".hg", ".svn", "node_modules"}
+
+const cliUsage = `Usage: tidysnips <command> [flags] [path ...]
+
+Commands:
+  fmt      format files and print the result, or rewrite them with -w
+  minify   minify files and print the result, or rewrite them with -w
+  check    list files that are not formatted and exit 1 if there are any
+  convert  convert data files to the language given by --to and print them
+  types    print types describing data files in the language given by --to
+
+Paths may be files or directories, which are walked recursively. Without
+paths, or with "-", code is read from stdin and written to stdout.
+Run without a command to start the HTTP server.
+
+Flags:
+`
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// cli holds the state of one command-line run
+type cli struct {
+	command   string
+	formatter *Formatter
+	stdin     io.Reader
+	stdout    io.Writer
+	stderr    io.Writer
+
+	language  string
+	to        string
+	stdinName string
+	write     bool
+	list      bool
+	diff      bool
+	options   *FormatOptions
+	include   []*regexp.Regexp
+	exclude   []*regexp.Regexp
+
+	changed bool
+	failed  bool
+	warned  map[string]bool
+}
+
+// runCLI runs a subcommand and returns the process exit code
+func runCLI(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{
+		command:   args[0],
+		formatter: NewFormatter(),
+		stdin:     stdin,
+		stdout:    stdout,
+		stderr:    stderr,
+		warned:    make(map[string]bool),
+	}
+
+	flags := flag.NewFlagSet("tidysnips "+c.command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, cliUsage)
+		flags.PrintDefaults()
+	}
+
+	var include, exclude stringList
+	var opts FormatOptions
+	indentSize := flags.Int("indent-size", 0, "spaces per indentation level")
+	useTabs := flags.Bool("use-tabs", false, "indent with tabs")
+	printWidth := flags.Int("print-width", 0, "maximum line width")
+	trailingNewline := flags.Bool("trailing-newline", false, "end output with one newline; =false strips it")
+	flags.StringVar(&opts.LineEnding, "line-ending", "", "line ending style: lf or crlf")
+	flags.StringVar(&opts.QuoteStyle, "quote-style", "", "preferred quotes: single, double or preserve")
+	sortDeclarations := flags.Bool("sort-declarations", false, "sort style sheet declarations by property")
+	collapseWhitespace := flags.Bool("collapse-whitespace", true, "collapse whitespace when minifying markup")
+	removeComments := flags.Bool("remove-comments", true, "remove comments when minifying markup")
+	removeOptionalTags := flags.Bool("remove-optional-tags", false, "leave out optional end tags when minifying markup")
+	flags.StringVar(&opts.SourceMap, "source-map", "", "add a source map when minifying: inline")
+	compress := flags.Int("compress", 0, "JavaScript compression: 1 folds constants and drops dead code, 2 also renames locals")
+	reserved := flags.String("reserved", "", "comma-separated names compression must not rename")
+	joinStatements := flags.Bool("join-statements", false, "put all statements of minified Go on one line")
+	organizeImports := flags.Bool("organize-imports", false, "group, sort and fix the imports of formatted Go")
+	strict := flags.Bool("strict", false, "format Go with the stricter rules of gofumpt")
+	modernize := flags.Bool("modernize", false, "rewrite outdated Go such as interface{} and io/ioutil")
+	flags.StringVar(&opts.Dialect, "dialect", "", "SQL dialect: postgresql, mysql, sqlite or tsql")
+	flags.StringVar(&opts.AttributePrefix, "attribute-prefix", "", "key prefix of XML attributes in converted data (default \"@\")")
+	flags.StringVar(&opts.TextKey, "text-key", "", "key of XML element text in converted data (default \"#text\")")
+	header := flags.Bool("header", false, "whether the first CSV row names the columns (default: infer)")
+	flags.StringVar(&c.language, "lang", "", "language of the input (default: detect)")
+	flags.StringVar(&c.to, "to", "", "language to convert to or declare types in")
+	flags.StringVar(&opts.TypeName, "type-name", "", "name of the type describing a whole file (default \"Root\")")
+	flags.StringVar(&c.stdinName, "stdin-filename", "", "file name used to detect the language of stdin")
+	flags.BoolVar(&c.write, "w", false, "write results to the source files instead of stdout")
+	flags.BoolVar(&c.list, "l", false, "list files whose result differs from their contents")
+	flags.BoolVar(&c.diff, "diff", false, "print a unified diff instead of the result")
+	flags.Var(&include, "include", "glob of files to process in directories (repeatable)")
+	flags.Var(&exclude, "exclude", "glob of files or directories to skip (repeatable)")
+
+	if c.command == "help" {
+		flags.Usage()
+		return exitOK
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+
+	// Only options given on the command line are passed on, so backends
+	// keep their own defaults for the rest. Formatted files end with a
+	// newline unless asked otherwise.
+	if c.command != "minify" {
+		opts.TrailingNewline = new(bool)
+		*opts.TrailingNewline = true
+	}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "indent-size":
+			opts.IndentSize = indentSize
+		case "use-tabs":
+			opts.UseTabs = useTabs
+		case "print-width":
+			opts.PrintWidth = printWidth
+		case "trailing-newline":
+			opts.TrailingNewline = trailingNewline
+		case "sort-declarations":
+			opts.SortDeclarations = sortDeclarations
+		case "collapse-whitespace":
+			opts.CollapseWhitespace = collapseWhitespace
+		case "remove-comments":
+			opts.RemoveComments = removeComments
+		case "remove-optional-tags":
+			opts.RemoveOptionalTags = removeOptionalTags
+		case "header":
+			opts.Header = header
+		case "compress":
+			opts.Compress = compress
+		case "join-statements":
+			opts.JoinStatements = joinStatements
+		case "organize-imports":
+			opts.OrganizeImports = organizeImports
+		case "strict":
+			opts.Strict = strict
+		case "modernize":
+			opts.Modernize = modernize
+		case "reserved":
+			opts.Reserved = strings.Split(*reserved, ",")
+		}
+	})
+	c.options = &opts
+	if err := opts.Validate(); err != nil {
+		fmt.Fprintf(stderr, "tidysnips: invalid options: %v\n", err)
+		return exitError
+	}
+
+	if opts.sourceMap() == sourceMapSeparate {
+		// Results go to stdout or back to their file, leaving no place
+		// for a second output
+		fmt.Fprintln(stderr, "tidysnips: source maps can only be written inline")
+		return exitError
+	}
+
+	var err error
+	if c.include, err = compileGlobs(include); err == nil {
+		c.exclude, err = compileGlobs(append(exclude, defaultExcludes...))
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "tidysnips: %v\n", err)
+		return exitError
+	}
+
+	if c.command == "check" {
+		if c.write {
+			fmt.Fprintln(stderr, "tidysnips: check does not write files; use fmt -w")
+			return exitError
+		}
+		c.list = true
+	}
+	if c.command == "convert" || c.command == "types" {
+		switch {
+		case c.to == "":
+			fmt.Fprintf(stderr, "tidysnips: %s needs --to\n", c.command)
+			return exitError
+		case c.write || c.list || c.diff:
+			fmt.Fprintf(stderr, "tidysnips: %s only prints its result; -w, -l and --diff do not apply\n", c.command)
+			return exitError
+		}
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	for _, path := range paths {
+		c.run(path)
+	}
+
+	switch {
+	case c.failed:
+		return exitError
+	case c.changed && (c.list || c.diff):
+		return exitChanged
+	}
+	return exitOK
+}
+
+// run processes stdin, a file or a directory tree
+func (c *cli) run(path string) {
+	if path == "-" {
+		if c.write {
+			c.errorf("cannot use -w with stdin")
+			return
+		}
+		src, err := io.ReadAll(c.stdin)
+		if err != nil {
+			c.errorf("reading stdin: %v", err)
+			return
+		}
+		c.process("<stdin>", c.stdinName, string(src))
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		c.errorf("%v", err)
+		return
+	}
+	if !info.IsDir() {
+		// Files named explicitly are processed whatever their name
+		c.processFile(path, info.Mode())
+		return
+	}
+
+	err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			c.errorf("%v", err)
+			return nil
+		}
+		rel, _ := filepath.Rel(path, file)
+		rel = filepath.ToSlash(rel)
+		if rel != "." && matchesAny(c.exclude, rel) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || !entry.Type().IsRegular() || !c.wants(rel) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			c.errorf("%v", err)
+			return nil
+		}
+		c.processFile(file, info.Mode())
+		return nil
+	})
+	if err != nil {
+		c.errorf("%v", err)
+	}
+}
+
+// wants reports whether a file found in a directory should be processed:
+// one matching --include, or by default one with a registered extension
+func (c *cli) wants(rel string) bool {
+	if len(c.include) > 0 {
+		return matchesAny(c.include, rel)
+	}
+	_, ok := c.formatter.registry.LookupExtension(filepath.Ext(rel))
+	return ok
+}
+
+func (c *cli) processFile(path string, mode fs.FileMode) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		c.errorf("%v", err)
+		return
+	}
+	out, ok := c.process(path, path, string(src))
+	if !ok || !c.write || out == string(src) {
+		return
+	}
+	if err := os.WriteFile(path, []byte(out), mode.Perm()); err != nil {
+		c.errorf("%v", err)
+	}
+}
+
+// process runs the command on one input and reports the outcome. It
+// returns the result and whether processing succeeded.
+func (c *cli) process(name, filename, src string) (string, bool) {
+	if strings.TrimSpace(src) == "" {
+		// Nothing to format; an empty file is already tidy
+		if !c.write && !c.list && !c.diff {
+			io.WriteString(c.stdout, src)
+		}
+		return src, true
+	}
+
+	language := c.language
+	if IsAutoLanguage(language) {
+		detection, err := c.formatter.Detect(src, filename)
+		if err != nil {
+			c.errorf("%s: %v", name, err)
+			return "", false
+		}
+		language = detection.Language
+	}
+
+	var result *Result
+	var err error
+	switch c.command {
+	case "minify":
+		result, err = c.formatter.MinifyWithOptions(src, language, withSourceFile(c.options, filename))
+	case "convert", "types":
+		convert := c.formatter.Convert
+		if c.command == "types" {
+			convert = c.formatter.GenerateTypes
+		}
+		var conversion *Conversion
+		if conversion, err = convert(src, language, c.to, c.options); err == nil {
+			for _, warning := range conversion.Warnings {
+				fmt.Fprintf(c.stderr, "%s: warning: %s\n", name, warning)
+			}
+			// The options apply to the output
+			language = c.to
+			result = &Result{Code: conversion.Code, IgnoredOptions: conversion.IgnoredOptions}
+		}
+	default:
+		result, err = c.formatter.FormatWithOptions(src, language, c.options)
+	}
+	if err != nil {
+		c.reportError(name, src, err)
+		return "", false
+	}
+	c.warnIgnored(language, result.IgnoredOptions)
+
+	out := result.Code
+	if out != src {
+		c.changed = true
+	}
+	switch {
+	case c.list || c.diff:
+		if out == src {
+			break
+		}
+		if c.list {
+			fmt.Fprintln(c.stdout, name)
+		}
+		if c.diff {
+			io.WriteString(c.stdout, unifiedDiff("a/"+filepath.ToSlash(name), "b/"+filepath.ToSlash(name), src, out))
+		}
+	case !c.write:
+		io.WriteString(c.stdout, out)
+	}
+	return out, true
+}
+
+// reportError prints an error, one line per diagnostic when it has any
+func (c *cli) reportError(name, src string, err error) {
+	c.failed = true
+	diagnostics := DiagnosticsFromError(err, src)
+	if len(diagnostics) == 0 {
+		fmt.Fprintf(c.stderr, "%s: %v\n", name, err)
+		return
+	}
+	for _, d := range diagnostics {
+		fmt.Fprintf(c.stderr, "%s:%d:%d: %s\n", name, d.Line, d.Column, d.Message)
+	}
+}
+
+// warnIgnored notes options a language ignored, once per language
+func (c *cli) warnIgnored(language string, ignored []string) {
+	if len(ignored) == 0 || c.warned[language] {
+		return
+	}
+	c.warned[language] = true
+	sort.Strings(ignored)
+	fmt.Fprintf(c.stderr, "tidysnips: %s ignores %s\n", language, strings.Join(ignored, ", "))
+}
+
+func (c *cli) errorf(format string, args ...interface{}) {
+	c.failed = true
+	fmt.Fprintf(c.stderr, "tidysnips: "+format+"\n", args...)
+}
+
+// compileGlobs compiles shell-style globs. A glob without a slash matches
+// the base name of a path, otherwise the whole slash-separated path
+// relative to the directory being walked. ** matches any number of
+// directories and {a,b} matches either alternative.
+func compileGlobs(globs []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(globs))
+	for _, glob := range globs {
+		re, err := globRegexp(glob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %v", glob, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	if strings.Contains(glob, "/") {
+		b.WriteString("^")
+		glob = strings.TrimPrefix(glob, "/")
+	} else {
+		b.WriteString("(?:^|/)")
+	}
+
+	braces := 0
+	for i := 0; i < len(glob); i++ {
+		switch ch := glob[i]; ch {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case '{':
+			braces++
+			b.WriteString("(?:")
+		case '}':
+			if braces == 0 {
+				return nil, fmt.Errorf("unmatched }")
+			}
+			braces--
+			b.WriteString(")")
+		case ',':
+			if braces > 0 {
+				b.WriteString("|")
+			} else {
+				b.WriteString(",")
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	if braces > 0 {
+		return nil, fmt.Errorf("unmatched {")
+	}
+	// A pattern matching a directory also matches everything inside it
+	b.WriteString("(?:/.*)?$")
+	return regexp.Compile(b.String())
+}
+
+func matchesAny(globs []*regexp.Regexp, path string) bool {
+	for _, re := range globs {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeCLITree writes files given by slash-separated paths under a new
+// temporary directory and returns it
+func writeCLITree(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunCLI(t *testing.T) {
+	files := map[string]string{
+		"a.json":              `{"a":1}`,
+		"b.css":               "a {\n  color: red;\n}\n",
+		"bad.json":            "{\n  \"a\": tru\n}",
+		"node_modules/c.json": `{"c":1}`,
+	}
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		wantCode int
+		wantOut  string
+		wantErr  string
+	}{
+		{"format stdin", []string{"fmt", "--lang", "json"}, `{"b": [1,2]}`, exitOK, "{\n  \"b\": [\n    1,\n    2\n  ]\n}\n", ""},
+		{"stdin file name", []string{"fmt", "--stdin-filename", "x.json"}, `{"b":1}`, exitOK, "{\n  \"b\": 1\n}\n", ""},
+		{"minify stdin", []string{"minify", "--lang", "json"}, `{"b": [1, 2]}`, exitOK, `{"b":[1,2]}`, ""},
+		{"options", []string{"fmt", "--lang", "json", "--indent-size", "4"}, `{"b":1}`, exitOK, "{\n    \"b\": 1\n}\n", ""},
+		{"check directory", []string{"check", "DIR"}, "", exitError, "DIR/a.json\n", "DIR/bad.json:2:8: invalid literal \"tru\"\n"},
+		{"check with exclude", []string{"check", "--exclude", "bad.json", "DIR"}, "", exitChanged, "DIR/a.json\n", ""},
+		{
+			"diff", []string{"fmt", "--diff", "DIR/a.json"}, "", exitChanged,
+			"--- a/DIR/a.json\n+++ b/DIR/a.json\n@@ -1 +1,3 @@\n-{\"a\":1}\n\\ No newline at end of file\n+{\n+  \"a\": 1\n+}\n", "",
+		},
+		{"formatted file", []string{"check", "DIR/b.css"}, "", exitOK, "", ""},
+		{"convert", []string{"convert", "--to", "yaml", "DIR/a.json"}, "", exitOK, "a: 1\n", ""},
+		{"types", []string{"types", "--to", "go", "DIR/a.json"}, "", exitOK, "type Root struct {\n\tA int64 `json:\"a\"`\n}\n", ""},
+		{"convert without target", []string{"convert", "DIR/a.json"}, "", exitError, "", "tidysnips: convert needs --to\n"},
+		{"check writing", []string{"check", "-w", "DIR"}, "", exitError, "", "tidysnips: check does not write files; use fmt -w\n"},
+		{"invalid option", []string{"fmt", "--indent-size", "0"}, "", exitError, "", "tidysnips: invalid options: indentSize must be between 1 and 16\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeCLITree(t, files)
+			args := make([]string, len(tt.args))
+			for i, arg := range tt.args {
+				args[i] = strings.Replace(arg, "DIR", dir, 1)
+			}
+			var stdout, stderr bytes.Buffer
+			code := runCLI(args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			out := strings.ReplaceAll(stdout.String(), dir, "DIR")
+			errOut := strings.ReplaceAll(stderr.String(), dir, "DIR")
+			if code != tt.wantCode || out != tt.wantOut || errOut != tt.wantErr {
+				t.Errorf("runCLI(%q) = %d\nstdout %q\nstderr %q\nwant %d\nstdout %q\nstderr %q", tt.args, code, out, errOut, tt.wantCode, tt.wantOut, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunCLIWrite(t *testing.T) {
+	dir := writeCLITree(t, map[string]string{
+		"a.json":              `{"a":1}`,
+		"src/b.css":           "a{color:red}",
+		"node_modules/c.json": `{"c":1}`,
+	})
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"fmt", "-w", dir}, strings.NewReader(""), &stdout, &stderr); code != exitOK {
+		t.Fatalf("runCLI exit code %d: %s", code, stderr.String())
+	}
+
+	want := map[string]string{
+		"a.json":              "{\n  \"a\": 1\n}\n",
+		"src/b.css":           "a {\n  color: red;\n}\n",
+		"node_modules/c.json": `{"c":1}`,
+	}
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
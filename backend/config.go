@@ -12,9 +12,13 @@ type Config struct {
 	Server    ServerConfig
 	RateLimit RateLimitConfig
 	Request   RequestConfig
+	Batch     BatchConfig
 	CORS      CORSConfig
 	Logging   LoggingConfig
 	Security  SecurityConfig
+	Policy    PolicyConfig
+	Secrets   SecretsConfig
+	Snippets  SnippetsConfig
 }
 
 // ServerConfig holds server-specific configuration
@@ -38,6 +42,13 @@ type RequestConfig struct {
 	MaxSize int64
 }
 
+// BatchConfig holds limits for the batch endpoint
+type BatchConfig struct {
+	MaxItems int
+	MaxSize  int64
+	Workers  int
+}
+
 // CORSConfig holds CORS configuration
 type CORSConfig struct {
 	AllowedOrigins []string
@@ -56,6 +67,35 @@ type SecurityConfig struct {
 	EnableRateLimiting bool
 	EnableLogging      bool
 	EnableCORS         bool
+	EnableSecretScan   bool
+}
+
+// PolicyConfig holds the actions that override those of the suspicious
+// code rules, by rule ID
+type PolicyConfig struct {
+	Actions map[string]string
+}
+
+// SecretsConfig holds the patterns the secret scanner looks for
+type SecretsConfig struct {
+	// Patterns names built-in pattern sets or patterns; empty means all
+	Patterns []string
+	// PatternsFile is a JSON file of additional patterns
+	PatternsFile string
+}
+
+// SnippetsConfig holds the storage of saved snippets
+type SnippetsConfig struct {
+	// Store is file, sql or none, which disables the snippet endpoints
+	Store string
+	// Dir is the directory of the file store
+	Dir string
+	// Driver and DSN open the database of the sql store
+	Driver string
+	DSN    string
+	// BaseURL is prefixed to snippet IDs to make short links
+	BaseURL         string
+	CleanupInterval time.Duration
 }
 
 // LoadConfig loads configuration from environment variables
@@ -76,10 +116,15 @@ func LoadConfig() *Config {
 		Request: RequestConfig{
 			MaxSize: int64(getEnvAsIntOrDefault("MAX_REQUEST_SIZE", 1048576)), // 1MB default
 		},
+		Batch: BatchConfig{
+			MaxItems: getEnvAsIntOrDefault("BATCH_MAX_ITEMS", 500),
+			MaxSize:  int64(getEnvAsIntOrDefault("BATCH_MAX_REQUEST_SIZE", 10485760)), // 10MB default
+			Workers:  getEnvAsIntOrDefault("BATCH_WORKERS", 8),
+		},
 		CORS: CORSConfig{
 			AllowedOrigins: strings.Split(getEnvOrDefault("ALLOWED_ORIGINS", "http://localhost:3000"), ","),
-			AllowedMethods: strings.Split(getEnvOrDefault("ALLOWED_METHODS", "GET,POST,OPTIONS"), ","),
-			AllowedHeaders: strings.Split(getEnvOrDefault("ALLOWED_HEADERS", "Content-Type,Authorization"), ","),
+			AllowedMethods: strings.Split(getEnvOrDefault("ALLOWED_METHODS", "GET,POST,DELETE,OPTIONS"), ","),
+			AllowedHeaders: strings.Split(getEnvOrDefault("ALLOWED_HEADERS", "Content-Type,Authorization,X-Delete-Token"), ","),
 		},
 		Logging: LoggingConfig{
 			Level:  getEnvOrDefault("LOG_LEVEL", "info"),
@@ -89,6 +134,22 @@ func LoadConfig() *Config {
 			EnableRateLimiting: getEnvAsBoolOrDefault("ENABLE_RATE_LIMITING", true),
 			EnableLogging:      getEnvAsBoolOrDefault("ENABLE_LOGGING", true),
 			EnableCORS:         getEnvAsBoolOrDefault("ENABLE_CORS", true),
+			EnableSecretScan:   getEnvAsBoolOrDefault("ENABLE_SECRET_SCAN", true),
+		},
+		Policy: PolicyConfig{
+			Actions: getEnvAsMap("POLICY_RULES"),
+		},
+		Secrets: SecretsConfig{
+			Patterns:     getEnvAsList("SECRET_PATTERNS"),
+			PatternsFile: os.Getenv("SECRET_PATTERNS_FILE"),
+		},
+		Snippets: SnippetsConfig{
+			Store:           getEnvOrDefault("SNIPPET_STORE", "none"),
+			Dir:             getEnvOrDefault("SNIPPET_DIR", "data/snippets"),
+			Driver:          getEnvOrDefault("SNIPPET_DB_DRIVER", "sqlite"),
+			DSN:             getEnvOrDefault("SNIPPET_DB_DSN", "data/snippets.db"),
+			BaseURL:         os.Getenv("SNIPPET_BASE_URL"),
+			CleanupInterval: time.Duration(getEnvAsIntOrDefault("SNIPPET_CLEANUP_INTERVAL", 600)) * time.Second,
 		},
 	}
 }
@@ -119,6 +180,31 @@ func getEnvAsBoolOrDefault(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getEnvAsList parses a comma-separated list, empty when the variable is
+// not set
+func getEnvAsList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvAsMap parses a comma-separated list of key=value pairs
+func getEnvAsMap(key string) map[string]string {
+	pairs := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		pairs[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return pairs
+}
+
 // IsProduction returns true if running in production environment
 func (c *Config) IsProduction() bool {
 	return c.Server.Environment == "production"
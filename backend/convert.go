@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Conversions pass documents between languages as plain values: nil,
+// bool, string, dataNumber, []interface{} and *dataMap.
+
+// dataNumber is a number written as a JSON number literal
+type dataNumber string
+
+// dataMap is a mapping with string keys that keeps the order they were
+// added in
+type dataMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newDataMap() *dataMap {
+	return &dataMap{values: make(map[string]interface{})}
+}
+
+// set adds a key, or replaces its value where it is
+func (m *dataMap) set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *dataMap) has(key string) bool {
+	_, ok := m.values[key]
+	return ok
+}
+
+// conversionLog collects the warnings of one conversion, each once
+type conversionLog struct {
+	warnings []string
+	seen     map[string]bool
+}
+
+func (l *conversionLog) warnf(format string, args ...interface{}) {
+	warning := fmt.Sprintf(format, args...)
+	if l.seen == nil {
+		l.seen = make(map[string]bool)
+	}
+	if !l.seen[warning] {
+		l.seen[warning] = true
+		l.warnings = append(l.warnings, warning)
+	}
+}
+
+// Conversion is the outcome of converting code between languages
+type Conversion struct {
+	Code string
+	// Warnings describes what the target language could not represent
+	Warnings []string
+	// IgnoredOptions lists the options that were set but not honored
+	IgnoredOptions []string
+}
+
+// Convert reads code in one language and writes the same data in another.
+// The output is formatted by the target backend with opts.
+func (f *Formatter) Convert(code, from, to string, opts *FormatOptions) (*Conversion, error) {
+	source, err := f.lookup(code, from, opts)
+	if err != nil {
+		return nil, err
+	}
+	target, ok := f.registry.Lookup(to)
+	if !ok {
+		return nil, fmt.Errorf("unsupported language: %s", to)
+	}
+	switch {
+	case source.Decode == nil:
+		return nil, fmt.Errorf("conversion from %s is not supported", source.Name)
+	case target.Encode == nil:
+		return nil, fmt.Errorf("conversion to %s is not supported", target.Name)
+	case source == target:
+		return nil, fmt.Errorf("code is already %s", target.Name)
+	}
+
+	log := &conversionLog{}
+	value, err := source.Decode(code, opts, log)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := target.Encode(value, opts, log)
+	if err != nil {
+		return nil, err
+	}
+	// An empty TOML table or CSV list is an empty document, which has
+	// nothing to format
+	if encoded != "" {
+		result, err := f.FormatWithOptions(encoded, target.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+		encoded = result.Code
+	}
+
+	var supported []string
+	supported = append(supported, target.FormatSupports...)
+	supported = append(supported, source.ConvertSupports...)
+	supported = append(supported, target.ConvertSupports...)
+	return &Conversion{
+		Code:           encoded,
+		Warnings:       log.warnings,
+		IgnoredOptions: ignoredOptions(opts, supported),
+	}, nil
+}
+
+// jsonDecoder reads JSON into plain values with the scanner the formatter
+// uses, so number literals and key order survive
+type jsonDecoder struct {
+	scanner *jsonScanner
+	log     *conversionLog
+}
+
+func decodeJSON(code string, log *conversionLog) (interface{}, error) {
+	d := &jsonDecoder{scanner: newJSONScanner(code), log: log}
+	value, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := d.scanner.next()
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind != jsonEOF {
+		return nil, d.scanner.errorf(tok.offset, "invalid character %q after top-level value", tok.text)
+	}
+	return value, nil
+}
+
+func (d *jsonDecoder) value(depth int) (interface{}, error) {
+	tok, err := d.scanner.next()
+	if err != nil {
+		return nil, err
+	}
+	switch tok.kind {
+	case jsonString:
+		var s string
+		if err := json.Unmarshal([]byte(tok.text), &s); err != nil {
+			return nil, d.scanner.errorf(tok.offset, "invalid string literal")
+		}
+		return s, nil
+	case jsonNumber:
+		return dataNumber(tok.text), nil
+	case jsonLiteral:
+		switch tok.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, nil
+	case jsonBeginObject, jsonBeginArray:
+		if depth >= maxJSONDepth {
+			return nil, d.scanner.errorf(tok.offset, "exceeded max nesting depth")
+		}
+		if tok.kind == jsonBeginObject {
+			return d.object(depth)
+		}
+		return d.array(depth)
+	case jsonEOF:
+		return nil, d.scanner.errorf(tok.offset, "unexpected end of JSON input")
+	}
+	return nil, d.scanner.errorf(tok.offset, "invalid character %q looking for beginning of value", tok.text)
+}
+
+func (d *jsonDecoder) object(depth int) (interface{}, error) {
+	m := newDataMap()
+	if tok, err := d.scanner.peek(); err != nil {
+		return nil, err
+	} else if tok.kind == jsonEndObject {
+		d.scanner.next()
+		return m, nil
+	}
+	for {
+		tok, err := d.scanner.next()
+		if err != nil {
+			return nil, err
+		}
+		if tok.kind != jsonString {
+			return nil, d.scanner.errorf(tok.offset, "expected string for object key")
+		}
+		var key string
+		if err := json.Unmarshal([]byte(tok.text), &key); err != nil {
+			return nil, d.scanner.errorf(tok.offset, "invalid string literal")
+		}
+		colon, err := d.scanner.next()
+		if err != nil {
+			return nil, err
+		}
+		if colon.kind != jsonColon {
+			return nil, d.scanner.errorf(colon.offset, "expected ':' after object key")
+		}
+		value, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if m.has(key) {
+			line, _ := lineColumn(d.scanner.src, tok.offset)
+			d.log.warnf("line %d: duplicate key %q replaces the earlier value", line, key)
+		}
+		m.set(key, value)
+		if done, err := d.separator(jsonEndObject); err != nil || done {
+			return m, err
+		}
+	}
+}
+
+func (d *jsonDecoder) array(depth int) (interface{}, error) {
+	list := []interface{}{}
+	if tok, err := d.scanner.peek(); err != nil {
+		return nil, err
+	} else if tok.kind == jsonEndArray {
+		d.scanner.next()
+		return list, nil
+	}
+	for {
+		value, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+		if done, err := d.separator(jsonEndArray); err != nil || done {
+			return list, err
+		}
+	}
+}
+
+// separator reads the ',' between elements or the closing bracket
+func (d *jsonDecoder) separator(closeKind jsonTokenKind) (bool, error) {
+	sep, err := d.scanner.next()
+	switch {
+	case err != nil:
+		return false, err
+	case sep.kind == jsonComma:
+		return false, nil
+	case sep.kind == closeKind:
+		return true, nil
+	case sep.kind == jsonEOF:
+		return false, d.scanner.errorf(sep.offset, "unexpected end of JSON input")
+	}
+	return false, d.scanner.errorf(sep.offset, "invalid character %q after %s element", sep.text, containerName(closeKind))
+}
+
+// encodeJSON writes plain values as compact JSON; the JSON formatter lays
+// it out afterwards
+func encodeJSON(value interface{}, _ *FormatOptions, _ *conversionLog) (string, error) {
+	var b strings.Builder
+	writeJSONValue(&b, value)
+	return b.String(), nil
+}
+
+func writeJSONValue(b *strings.Builder, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		fmt.Fprint(b, v)
+	case dataNumber:
+		b.WriteString(string(v))
+	case string:
+		b.WriteString(jsonQuote(v))
+	case []interface{}:
+		b.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSONValue(b, item)
+		}
+		b.WriteByte(']')
+	case *dataMap:
+		b.WriteByte('{')
+		for i, key := range v.keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(jsonQuote(key))
+			b.WriteByte(':')
+			writeJSONValue(b, v.values[key])
+		}
+		b.WriteByte('}')
+	}
+}
+
+// jsonQuote quotes a string as JSON without escaping HTML characters
+func jsonQuote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func decodeJSONCode(code string, _ *FormatOptions, log *conversionLog) (interface{}, error) {
+	value, err := decodeJSON(code, log)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON syntax: %w", err)
+	}
+	return value, nil
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type cssNodeKind int
+
+const (
+	cssRuleNode      cssNodeKind = iota // selector { ... }
+	cssAtRuleNode                       // @name prelude; or @name prelude { ... }
+	cssDeclNode                         // property: value; or property: { ... };
+	cssStatementNode                    // anything else ending in ;, such as a Less mixin call
+	cssCommentNode
+)
+
+// cssNode is a statement of a style sheet. Preprocessor syntax needs no
+// nodes of its own: SCSS control directives and mixins are at-rules, Less
+// variables are declarations and mixin calls are statements.
+type cssNode struct {
+	kind cssNodeKind
+	// prelude holds the selector, the at-keyword and its prelude, the
+	// declaration's property, or the comment
+	prelude []cssToken
+	// value holds a declaration's value after the colon
+	value    []cssToken
+	children []*cssNode
+	block    bool
+	// newlines counts the line breaks before the node in the source
+	newlines int
+}
+
+// property returns the declaration's property as written
+func (n *cssNode) property() string {
+	var b strings.Builder
+	for _, tok := range n.prelude {
+		if !tok.isComment() {
+			b.WriteString(tok.text)
+		}
+	}
+	return b.String()
+}
+
+// isVariable reports whether a declaration assigns an SCSS or Less
+// variable, whose order matters
+func (n *cssNode) isVariable() bool {
+	name := n.property()
+	return strings.HasPrefix(name, "$") || strings.HasPrefix(name, "@")
+}
+
+type cssParser struct {
+	src     string
+	dialect cssDialect
+	tokens  []cssToken
+	pos     int
+}
+
+// parseCSS parses a style sheet into its top-level statements
+func parseCSS(src string, dialect cssDialect) ([]*cssNode, error) {
+	tokens, err := tokenizeCSS(src, dialect)
+	if err != nil {
+		return nil, err
+	}
+	p := &cssParser{src: src, dialect: dialect, tokens: tokens}
+	return p.parseBlock(-1)
+}
+
+// parseBlock parses statements up to the } closing the block opened at
+// offset open, or to the end of input when open is negative
+func (p *cssParser) parseBlock(open int) ([]*cssNode, error) {
+	var nodes []*cssNode
+	for p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+		switch {
+		case tok.isComment():
+			nodes = append(nodes, &cssNode{kind: cssCommentNode, prelude: []cssToken{tok}, newlines: tok.newlines})
+			p.pos++
+			continue
+		case tok.is("}"):
+			if open < 0 {
+				return nil, cssErrorf(p.src, tok.start, "unexpected }")
+			}
+			p.pos++
+			return nodes, nil
+		case tok.is(";"):
+			p.pos++
+			continue
+		}
+		parsed, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, parsed...)
+	}
+	if open >= 0 {
+		return nil, cssErrorf(p.src, open, "unclosed {")
+	}
+	return nodes, nil
+}
+
+// parseStatement parses one rule, at-rule or declaration. Comments
+// trailing it before its terminator are returned as nodes of their own.
+func (p *cssParser) parseStatement() ([]*cssNode, error) {
+	start := p.pos
+	var closers []string
+	var opens []int
+	for ; p.pos < len(p.tokens); p.pos++ {
+		tok := p.tokens[p.pos]
+		if tok.kind != cssDelim {
+			continue
+		}
+		switch tok.text {
+		case "(", "[":
+			closers = append(closers, map[string]string{"(": ")", "[": "]"}[tok.text])
+			opens = append(opens, tok.start)
+			continue
+		case ")", "]":
+			if len(closers) == 0 || closers[len(closers)-1] != tok.text {
+				return nil, cssErrorf(p.src, tok.start, "unexpected %s", tok.text)
+			}
+			closers, opens = closers[:len(closers)-1], opens[:len(opens)-1]
+			continue
+		}
+		if len(closers) == 0 && (tok.text == ";" || tok.text == "{" || tok.text == "}") {
+			break
+		}
+	}
+	if len(closers) > 0 {
+		return nil, cssErrorf(p.src, opens[len(opens)-1], "unclosed %s", p.src[opens[len(opens)-1]:opens[len(opens)-1]+1])
+	}
+
+	if p.pos == start {
+		// Only a { can end a statement before it starts
+		return nil, cssErrorf(p.src, p.tokens[start].start, "unexpected {")
+	}
+	toks := p.tokens[start:p.pos]
+	var trailing []*cssNode
+	for len(toks) > 0 && toks[len(toks)-1].isComment() {
+		last := toks[len(toks)-1]
+		trailing = append([]*cssNode{{kind: cssCommentNode, prelude: []cssToken{last}, newlines: last.newlines}}, trailing...)
+		toks = toks[:len(toks)-1]
+	}
+	node := &cssNode{prelude: toks, newlines: toks[0].newlines}
+
+	if p.pos < len(p.tokens) && p.tokens[p.pos].is("{") {
+		open := p.tokens[p.pos].start
+		p.pos++
+		children, err := p.parseBlock(open)
+		if err != nil {
+			return nil, err
+		}
+		node.kind, node.block, node.children = cssRuleNode, true, children
+		switch colon := cssTopLevelColon(toks); {
+		case toks[0].kind == cssAtKeyword && !(len(toks) > 1 && toks[1].is(":")):
+			node.kind = cssAtRuleNode
+		case colon > 0 && colon == len(toks)-1:
+			// A declaration whose value is a block, such as a custom
+			// property holding {a:b} or SCSS nested properties
+			node.kind, node.prelude = cssDeclNode, toks[:colon]
+		}
+		return append([]*cssNode{node}, trailing...), nil
+	}
+	if p.pos < len(p.tokens) && p.tokens[p.pos].is(";") {
+		p.pos++
+	}
+
+	switch colon := cssTopLevelColon(toks); {
+	case toks[0].kind == cssAtKeyword && colon != 1:
+		node.kind = cssAtRuleNode
+	case colon > 0 && !toks[0].is("&"):
+		// &:extend(...) in Less is a statement, not a declaration
+		node.kind, node.prelude, node.value = cssDeclNode, toks[:colon], toks[colon+1:]
+	default:
+		node.kind = cssStatementNode
+	}
+	return append([]*cssNode{node}, trailing...), nil
+}
+
+// cssTopLevelColon returns the index of the first colon outside brackets,
+// or -1
+func cssTopLevelColon(toks []cssToken) int {
+	depth := 0
+	for i, tok := range toks {
+		switch {
+		case tok.is("(") || tok.is("["):
+			depth++
+		case tok.is(")") || tok.is("]"):
+			depth--
+		case tok.is(":") && depth == 0:
+			return i
+		}
+	}
+	return -1
+}
+
+// cssFormatter pretty-prints a parsed style sheet, one declaration per
+// line and one selector per line
+type cssFormatter struct {
+	out     strings.Builder
+	dialect cssDialect
+	indent  string
+	sort    bool
+}
+
+func formatCSS(src string, dialect cssDialect, opts *FormatOptions) (string, error) {
+	nodes, err := parseCSS(src, dialect)
+	if err != nil {
+		return "", err
+	}
+	f := &cssFormatter{dialect: dialect, indent: opts.indent(2), sort: opts.sortDeclarations()}
+	f.block(nodes, 0)
+	if f.out.Len() == 0 {
+		return "", nil
+	}
+	return f.out.String() + "\n", nil
+}
+
+func (f *cssFormatter) block(nodes []*cssNode, depth int) {
+	if f.sort {
+		nodes = sortCSSDeclarations(nodes)
+	}
+	indent := strings.Repeat(f.indent, depth)
+	for i, node := range nodes {
+		switch {
+		case i > 0 && node.kind == cssCommentNode && node.newlines == 0:
+			// A comment on the line of the statement before it stays there
+			f.out.WriteByte(' ')
+		case i > 0 && node.kind == cssAtRuleNode && isCSSElse(node) && nodes[i-1].block:
+			f.out.WriteByte(' ')
+		default:
+			if i > 0 || depth > 0 {
+				f.out.WriteByte('\n')
+			}
+			if i > 0 && node.newlines > 1 {
+				f.out.WriteByte('\n')
+			}
+			f.out.WriteString(indent)
+		}
+		f.node(node, depth)
+	}
+}
+
+func (f *cssFormatter) node(node *cssNode, depth int) {
+	switch node.kind {
+	case cssCommentNode:
+		f.out.WriteString(node.prelude[0].text)
+		return
+	case cssRuleNode:
+		f.out.WriteString(f.selector(node.prelude, depth))
+	case cssAtRuleNode:
+		f.out.WriteString(f.atRule(node.prelude))
+	case cssDeclNode:
+		f.out.WriteString(f.property(node))
+		f.out.WriteByte(':')
+		switch value := f.value(node.value, depth); {
+		case value == "":
+		case node.value[0].newlines > 0 && strings.Contains(value, "\n"):
+			// A value laid out over lines of its own, such as grid areas
+			f.out.WriteByte('\n')
+			f.out.WriteString(strings.Repeat(f.indent, depth+1))
+			f.out.WriteString(value)
+		default:
+			f.out.WriteByte(' ')
+			f.out.WriteString(value)
+		}
+	case cssStatementNode:
+		f.out.WriteString(f.value(node.prelude, depth))
+	}
+	if !node.block {
+		f.out.WriteByte(';')
+		return
+	}
+	f.out.WriteString(" {")
+	f.block(node.children, depth+1)
+	f.out.WriteByte('\n')
+	f.out.WriteString(strings.Repeat(f.indent, depth))
+	f.out.WriteByte('}')
+	if node.kind == cssDeclNode {
+		// The declaration only ends at a semicolon
+		f.out.WriteByte(';')
+	}
+}
+
+// property returns a declaration's property, lowercased unless it is a
+// custom property, a variable or built by interpolation
+func (f *cssFormatter) property(node *cssNode) string {
+	name := node.property()
+	if len(node.prelude) == 1 && node.prelude[0].kind == cssIdent && !strings.HasPrefix(name, "--") &&
+		!strings.ContainsAny(name, "#@") {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// selector prints a selector list one complex selector per line, with a
+// single space around combinators
+func (f *cssFormatter) selector(toks []cssToken, depth int) string {
+	var b strings.Builder
+	nesting := 0
+	for i, tok := range toks {
+		if i > 0 {
+			prev := toks[i-1]
+			switch {
+			case nesting == 0 && prev.is(","):
+				b.WriteString("\n")
+				b.WriteString(strings.Repeat(f.indent, depth))
+			case nesting == 0 && (isCSSCombinator(tok) || isCSSCombinator(prev)):
+				b.WriteByte(' ')
+			case tok.is(",") || tok.is(")") || tok.is("]") || prev.is("(") || prev.is("["):
+			case prev.is(","):
+				b.WriteByte(' ')
+			case tok.spaced || prev.isComment() || tok.isComment():
+				b.WriteByte(' ')
+			}
+		}
+		switch {
+		case tok.is("(") || tok.is("["):
+			nesting++
+		case tok.is(")") || tok.is("]"):
+			nesting--
+		}
+		b.WriteString(tok.text)
+	}
+	return b.String()
+}
+
+// atRule prints an at-rule's keyword and prelude
+func (f *cssFormatter) atRule(toks []cssToken) string {
+	var b strings.Builder
+	b.WriteString(toks[0].text)
+	nesting := 0
+	for i, tok := range toks[1:] {
+		prev := toks[i]
+		switch {
+		case i == 0:
+			// Less calls detached rulesets as @name()
+			if tok.spaced || tok.is("(") && !f.dialect.less {
+				b.WriteByte(' ')
+			}
+		case tok.is(",") || tok.is(")") || tok.is("]") || prev.is("(") || prev.is("["):
+		case prev.is(","):
+			b.WriteByte(' ')
+		case prev.is(":") && nesting > 0 && i > 1 && isCSSFeatureName(toks[i-1]):
+			// (max-width:100px) and keyword arguments such as ($a:1)
+			b.WriteByte(' ')
+		case tok.spaced || prev.isComment() || tok.isComment():
+			b.WriteByte(' ')
+		}
+		switch {
+		case tok.is("(") || tok.is("["):
+			nesting++
+		case tok.is(")") || tok.is("]"):
+			nesting--
+		}
+		b.WriteString(tok.text)
+	}
+	return b.String()
+}
+
+// value prints a declaration value or a statement at depth. Line breaks
+// from the source are kept and indented by bracket nesting, with a closing
+// bracket back at the level of its statement; other whitespace is
+// collapsed to single spaces.
+func (f *cssFormatter) value(toks []cssToken, depth int) string {
+	var b strings.Builder
+	nesting := 0
+	for i, tok := range toks {
+		closer := tok.is(")") || tok.is("]")
+		if closer {
+			nesting--
+		}
+		if i > 0 {
+			prev := toks[i-1]
+			switch {
+			case tok.newlines > 0 || prev.kind == cssLineComment:
+				level := max(nesting, 1)
+				if closer {
+					level = max(nesting, 0)
+				}
+				b.WriteByte('\n')
+				b.WriteString(strings.Repeat(f.indent, depth+level))
+			case tok.is(",") || tok.is(")") || tok.is("]") || prev.is("(") || prev.is("["):
+			case prev.is(",") || tok.is("!"):
+				b.WriteByte(' ')
+			case tok.spaced || prev.isComment() || tok.isComment():
+				b.WriteByte(' ')
+			}
+		}
+		if tok.is("(") || tok.is("[") {
+			nesting++
+		}
+		if i > 0 && toks[i-1].is("!") && tok.kind == cssIdent {
+			b.WriteString(strings.ToLower(tok.text))
+			continue
+		}
+		b.WriteString(tok.text)
+	}
+	return b.String()
+}
+
+func isCSSCombinator(tok cssToken) bool {
+	return tok.is(">") || tok.is("+") || tok.is("~")
+}
+
+// isCSSFeatureName reports whether tok can name a media feature or a
+// keyword argument
+func isCSSFeatureName(tok cssToken) bool {
+	return tok.kind == cssIdent || tok.kind == cssVariable || tok.kind == cssAtKeyword || tok.kind == cssInterpolation
+}
+
+// isCSSElse reports whether an at-rule continues the @if before it
+func isCSSElse(node *cssNode) bool {
+	return strings.EqualFold(node.prelude[0].text, "@else")
+}
+
+// sortCSSDeclarations sorts each run of consecutive declarations by
+// property name. Variables, rules, at-rules and comments on their own line
+// end a run, since moving a declaration across them can change its
+// meaning; a comment on the line of a declaration moves with it.
+func sortCSSDeclarations(nodes []*cssNode) []*cssNode {
+	type unit struct {
+		name  string
+		nodes []*cssNode
+	}
+	sorted := make([]*cssNode, 0, len(nodes))
+	var run []unit
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		newlines := run[0].nodes[0].newlines
+		sort.SliceStable(run, func(i, j int) bool { return run[i].name < run[j].name })
+		for i, u := range run {
+			u.nodes[0].newlines = 1
+			if i == 0 {
+				u.nodes[0].newlines = newlines
+			}
+			sorted = append(sorted, u.nodes...)
+		}
+		run = nil
+	}
+	for _, node := range nodes {
+		switch {
+		case node.kind == cssDeclNode && !node.isVariable():
+			run = append(run, unit{strings.ToLower(node.property()), []*cssNode{node}})
+		case node.kind == cssCommentNode && node.newlines == 0 && len(run) > 0:
+			last := &run[len(run)-1]
+			last.nodes = append(last.nodes, node)
+		default:
+			flush()
+			sorted = append(sorted, node)
+		}
+	}
+	flush()
+	return sorted
+}
+
+func formatCSSCode(code string, opts *FormatOptions) (string, error) {
+	result, err := formatCSS(code, cssDialect{}, opts)
+	if err != nil {
+		return "", fmt.Errorf("invalid CSS syntax: %w", err)
+	}
+	return result, nil
+}
+
+func formatSCSSCode(code string, opts *FormatOptions) (string, error) {
+	result, err := formatCSS(code, cssDialect{scss: true}, opts)
+	if err != nil {
+		return "", fmt.Errorf("invalid SCSS syntax: %w", err)
+	}
+	return result, nil
+}
+
+func formatLessCode(code string, opts *FormatOptions) (string, error) {
+	result, err := formatCSS(code, cssDialect{less: true}, opts)
+	if err != nil {
+		return "", fmt.Errorf("invalid Less syntax: %w", err)
+	}
+	return result, nil
+}
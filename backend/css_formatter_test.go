@@ -0,0 +1,101 @@
+package main
+
+import "testing"
+
+func TestFormatCSS(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		code     string
+		want     string
+	}{
+		{
+			"media query", "css",
+			"@media (max-width:600px){a,b>c{color:red;background:url(\"a b.png\")}}\n/* note */\n.x::before{content:\"a;b{}\"}",
+			"@media (max-width: 600px) {\n  a,\n  b > c {\n    color: red;\n    background: url(\"a b.png\");\n  }\n}\n/* note */\n.x::before {\n  content: \"a;b{}\";\n}\n",
+		},
+		{"empty declaration", "css", "a{color:red;;margin:0 auto!important}", "a {\n  color: red;\n  margin: 0 auto !important;\n}\n"},
+		{
+			"scss", "scss",
+			"$c:red;@mixin m($x){color:$x}a{@include m($c);&:hover{color:darken($c,10%)}.b{margin:0}}",
+			"$c: red;\n@mixin m($x) {\n  color: $x;\n}\na {\n  @include m($c);\n  &:hover {\n    color: darken($c, 10%);\n  }\n  .b {\n    margin: 0;\n  }\n}\n",
+		},
+		{
+			"less", "less",
+			"@c:red;.m(){color:@c}a{.m();&:hover{color:lighten(@c,10%)}}",
+			"@c: red;\n.m() {\n  color: @c;\n}\na {\n  .m();\n  &:hover {\n    color: lighten(@c, 10%);\n  }\n}\n",
+		},
+		{"declaration block", "css", ":root{--x: {a:b}; --y: 2px}", ":root {\n  --x: {\n    a: b;\n  };\n  --y: 2px;\n}\n"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Format(tt.code, tt.language)
+			if err != nil {
+				t.Fatalf("Format(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if again, err := f.Format(got, tt.language); err != nil || again != got {
+				t.Errorf("Format(%q) = %q, %v; not idempotent", got, again, err)
+			}
+		})
+	}
+}
+
+func TestMinifyCSS(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		code     string
+		want     string
+	}{
+		{
+			"css", "css",
+			"@media (max-width: 600px) {\n  a, b > c { color: red; margin: 0 0 0 0; }\n}\n/* drop */\n/*! keep */\n.x::before { content: \"a  ;b\" }\na:not(.b) .c { width: calc(100% - 10px) }",
+			"@media (max-width:600px){a,b>c{color:red;margin:0 0 0 0}}/*! keep */.x::before{content:\"a  ;b\"}a:not(.b) .c{width:calc(100% - 10px)}",
+		},
+		{"scss", "scss", "$c: red;\n// line comment\na { color: $c; &:hover { color: blue } }", "$c:red;a{color:$c;&:hover{color:blue}}"},
+		{"less", "less", "@c: red;\n.m() { color: @c }\na { .m(); }", "@c:red;.m(){color:@c}a{.m()}"},
+		{"declaration block", "css", ":root{--x: {a:b}; --y: 2px}", ":root{--x:{a:b};--y:2px}"},
+		{"declaration block last", "css", ":root{--y: 2px; --x: {a:b}}", ":root{--y:2px;--x:{a:b}}"},
+		{"nested properties", "scss", "a { font: { family: x; size: 1px } color: red }", "a{font:{family:x;size:1px};color:red}"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Minify(tt.code, tt.language)
+			if err != nil {
+				t.Fatalf("Minify(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Minify(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if again, err := f.Minify(got, tt.language); err != nil || again != got {
+				t.Errorf("Minify(%q) = %q, %v; minified code reads differently", got, again, err)
+			}
+		})
+	}
+}
+
+func TestFormatCSSErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"a { color: red;", "invalid CSS syntax: 1:3: unclosed {"},
+		{"a { color: red }\n}", "invalid CSS syntax: 2:1: unexpected }"},
+		{"a { content: \"unterminated }\n", "invalid CSS syntax: 1:14: unterminated string"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		_, err := f.Format(tt.code, "css")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Format(%q) error = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"strings"
+)
+
+// cssDialect selects the preprocessor syntax accepted on top of CSS
+type cssDialect struct {
+	scss bool
+	less bool
+}
+
+type cssTokenKind int
+
+const (
+	cssEOF           cssTokenKind = iota
+	cssIdent                      // color, -webkit-box, --custom
+	cssAtKeyword                  // @media, and Less variables such as @color
+	cssHash                       // #fff, #main
+	cssNumber                     // 12, 1.5em, 50%
+	cssString                     // '...' or "..."
+	cssURL                        // url(...) with an unquoted argument
+	cssVariable                   // SCSS $name
+	cssInterpolation              // SCSS #{...} or Less @{...}
+	cssDelim                      // punctuation and operators
+	cssComment                    // /* ... */
+	cssLineComment                // // ... in SCSS and Less
+)
+
+// cssToken is a lexical CSS token. Text is the exact source slice.
+type cssToken struct {
+	kind cssTokenKind
+	text string
+	// start and end are byte offsets into the source
+	start int
+	end   int
+	// spaced is set when whitespace precedes the token, which is
+	// significant in selectors
+	spaced bool
+	// newlines counts the line breaks in the whitespace before the token
+	newlines int
+}
+
+func (t cssToken) isComment() bool {
+	return t.kind == cssComment || t.kind == cssLineComment
+}
+
+func (t cssToken) is(text string) bool {
+	return t.kind == cssDelim && t.text == text
+}
+
+func cssErrorf(src string, offset int, format string, args ...interface{}) error {
+	return newSyntaxError(syntaxCSS, src, offset, format, args...)
+}
+
+// cssDelimiters lists the multi-character operators before the single
+// characters that start them
+var cssDelimiters = []string{"~=", "|=", "^=", "$=", "*=", "==", "!=", "<=", ">="}
+
+// tokenizeCSS lexes a style sheet, comments included
+func tokenizeCSS(src string, dialect cssDialect) ([]cssToken, error) {
+	var tokens []cssToken
+	pos := 0
+	for {
+		newlines, spaced := 0, false
+		for pos < len(src) && isCSSSpace(src[pos]) {
+			if src[pos] == '\n' {
+				newlines++
+			}
+			spaced = true
+			pos++
+		}
+		if pos >= len(src) {
+			return tokens, nil
+		}
+		start := pos
+		kind, end, err := scanCSSToken(src, pos, dialect)
+		if err != nil {
+			return nil, err
+		}
+		pos = end
+		tokens = append(tokens, cssToken{
+			kind:     kind,
+			text:     src[start:end],
+			start:    start,
+			end:      end,
+			spaced:   spaced,
+			newlines: newlines,
+		})
+	}
+}
+
+// scanCSSToken returns the kind and end of the token starting at pos
+func scanCSSToken(src string, pos int, dialect cssDialect) (cssTokenKind, int, error) {
+	rest := src[pos:]
+	c := src[pos]
+	switch {
+	case strings.HasPrefix(rest, "/*"):
+		end := strings.Index(rest[2:], "*/")
+		if end < 0 {
+			return cssEOF, 0, cssErrorf(src, pos, "unterminated comment")
+		}
+		return cssComment, pos + 2 + end + 2, nil
+	case strings.HasPrefix(rest, "//") && (dialect.scss || dialect.less):
+		end := strings.IndexByte(rest, '\n')
+		if end < 0 {
+			end = len(rest)
+		}
+		return cssLineComment, pos + len(strings.TrimRight(rest[:end], "\r")), nil
+	case c == '"' || c == '\'':
+		end, err := scanCSSString(src, pos)
+		return cssString, end, err
+	case (c == '#' && dialect.scss || c == '@' && dialect.less) && strings.HasPrefix(rest[1:], "{"):
+		end, err := scanCSSInterpolation(src, pos+1)
+		return cssInterpolation, end, err
+	case c == '@' && cssIdentStart(src, pos+1):
+		return cssAtKeyword, scanCSSName(src, pos+1), nil
+	case c == '$' && dialect.scss && cssIdentStart(src, pos+1):
+		return cssVariable, scanCSSName(src, pos+1), nil
+	case c == '#' && pos+1 < len(src) && (isCSSNameChar(src[pos+1]) || src[pos+1] == '\\'):
+		return cssHash, scanCSSName(src, pos+1), nil
+	case isDigit(rune(c)) || (c == '.' && pos+1 < len(src) && isDigit(rune(src[pos+1]))):
+		return cssNumber, scanCSSNumber(src, pos), nil
+	case cssIdentStart(src, pos):
+		end := scanCSSName(src, pos)
+		if strings.EqualFold(src[pos:end], "url") && end < len(src) && src[end] == '(' {
+			if urlEnd, ok := scanCSSURL(src, end+1); ok {
+				return cssURL, urlEnd, nil
+			}
+		}
+		return cssIdent, end, nil
+	}
+	for _, d := range cssDelimiters {
+		if strings.HasPrefix(rest, d) {
+			return cssDelim, pos + len(d), nil
+		}
+	}
+	return cssDelim, pos + 1, nil
+}
+
+func scanCSSString(src string, pos int) (int, error) {
+	quote := src[pos]
+	for i := pos + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1, nil
+		case '\n':
+			return 0, cssErrorf(src, pos, "unterminated string")
+		}
+	}
+	return 0, cssErrorf(src, pos, "unterminated string")
+}
+
+// scanCSSInterpolation skips from a { to its matching }, stepping over
+// strings inside it
+func scanCSSInterpolation(src string, pos int) (int, error) {
+	depth := 0
+	for i := pos; i < len(src); i++ {
+		switch src[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i + 1, nil
+			}
+		case '"', '\'':
+			end, err := scanCSSString(src, i)
+			if err != nil {
+				return 0, err
+			}
+			i = end - 1
+		}
+	}
+	return 0, cssErrorf(src, pos-1, "unterminated interpolation")
+}
+
+// scanCSSURL scans the unquoted argument of url( and its closing
+// parenthesis. It reports false when the argument is quoted, which leaves
+// url( to be read as an ordinary function.
+func scanCSSURL(src string, pos int) (int, bool) {
+	i := pos
+	for i < len(src) && isCSSSpace(src[i]) {
+		i++
+	}
+	if i < len(src) && (src[i] == '"' || src[i] == '\'') {
+		return 0, false
+	}
+	for ; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case ')':
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+func scanCSSNumber(src string, pos int) int {
+	i := pos
+	for i < len(src) && isDigit(rune(src[i])) {
+		i++
+	}
+	if i+1 < len(src) && src[i] == '.' && isDigit(rune(src[i+1])) {
+		i++
+		for i < len(src) && isDigit(rune(src[i])) {
+			i++
+		}
+	}
+	if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+		j := i + 1
+		if j < len(src) && (src[j] == '+' || src[j] == '-') {
+			j++
+		}
+		if j < len(src) && isDigit(rune(src[j])) {
+			i = j
+			for i < len(src) && isDigit(rune(src[i])) {
+				i++
+			}
+		}
+	}
+	switch {
+	case i < len(src) && src[i] == '%':
+		i++
+	case cssIdentStart(src, i):
+		i = scanCSSName(src, i)
+	}
+	return i
+}
+
+// cssIdentStart reports whether an identifier starts at pos: a name
+// character other than a digit, possibly after one or two hyphens
+func cssIdentStart(src string, pos int) bool {
+	if pos < len(src) && src[pos] == '-' {
+		pos++
+		if pos < len(src) && src[pos] == '-' {
+			return true
+		}
+	}
+	if pos >= len(src) {
+		return false
+	}
+	c := src[pos]
+	return isLetter(c) || c == '_' || c >= 0x80 || c == '\\'
+}
+
+// scanCSSName returns the end of the name starting at pos, escapes and
+// SCSS or Less interpolations inside it included
+func scanCSSName(src string, pos int) int {
+	i := pos
+	for i < len(src) {
+		switch c := src[i]; {
+		case c == '\\' && i+1 < len(src):
+			i += 2
+		case isCSSNameChar(c):
+			i++
+		case (c == '#' || c == '@') && i+1 < len(src) && src[i+1] == '{' && i > pos:
+			end, err := scanCSSInterpolation(src, i+1)
+			if err != nil {
+				return i
+			}
+			i = end
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func isCSSNameChar(c byte) bool {
+	return isLetter(c) || isDigit(rune(c)) || c == '-' || c == '_' || c >= 0x80
+}
+
+func isCSSSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cssZeroUnits are the length units a zero needs none of. Zero times,
+// angles, frequencies, resolutions and percentages keep their unit, since
+// some properties reject or reinterpret them bare.
+var cssZeroUnits = map[string]bool{
+	"px": true, "em": true, "rem": true, "ex": true, "ch": true,
+	"vw": true, "vh": true, "vmin": true, "vmax": true,
+	"cm": true, "mm": true, "q": true, "in": true, "pt": true, "pc": true,
+}
+
+// cssMathFunctions mix their arguments' units, so a unitless zero inside
+// them is invalid
+var cssMathFunctions = map[string]bool{
+	"calc": true, "-webkit-calc": true, "-moz-calc": true,
+	"min": true, "max": true, "clamp": true,
+}
+
+func minifyCSS(src string, dialect cssDialect) (string, error) {
+	nodes, err := parseCSS(src, dialect)
+	if err != nil {
+		return "", err
+	}
+	var out mappedOutput
+	writeMinifiedCSS(&out, nodes)
+	return out.String(), nil
+}
+
+// minifyCSSWithMap minifies like minifyCSS and maps every token it writes
+// back to the source
+func minifyCSSWithMap(src string, dialect cssDialect, opts *FormatOptions, defaultSource string) (string, string, error) {
+	nodes, err := parseCSS(src, dialect)
+	if err != nil {
+		return "", "", err
+	}
+	out := mappedOutput{mapped: true}
+	writeMinifiedCSS(&out, nodes)
+	minified, sourceMap := finishSourceMap(&out, src, opts, defaultSource, func(url string) string {
+		return "/*# sourceMappingURL=" + url + " */"
+	})
+	return minified, sourceMap, nil
+}
+
+// isLegalCSSComment reports whether a comment must survive minification
+func isLegalCSSComment(tok cssToken) bool {
+	if tok.kind != cssComment {
+		return false
+	}
+	return strings.HasPrefix(tok.text, "/*!") ||
+		strings.Contains(tok.text, "@license") ||
+		strings.Contains(tok.text, "@preserve")
+}
+
+// writeMinifiedCSS prints statements without comments or layout. The
+// semicolon after the last statement of a block is left out.
+func writeMinifiedCSS(out *mappedOutput, nodes []*cssNode) {
+	semicolon := false
+	for _, node := range nodes {
+		if node.kind == cssCommentNode && !isLegalCSSComment(node.prelude[0]) {
+			continue
+		}
+		if semicolon {
+			out.WriteByte(';')
+			semicolon = false
+		}
+		switch node.kind {
+		case cssCommentNode:
+			out.token(node.prelude[0].text, node.prelude[0].start)
+			continue
+		case cssRuleNode:
+			minifyCSSSelector(out, node.prelude)
+		case cssAtRuleNode:
+			minifyCSSAtRule(out, node.prelude)
+		case cssDeclNode:
+			// The name is written token by token, as property() joins it
+			for _, tok := range node.prelude {
+				if !tok.isComment() {
+					out.token(tok.text, tok.start)
+				}
+			}
+			out.WriteByte(':')
+			minifyCSSValue(out, node)
+		case cssStatementNode:
+			joinMinifiedCSS(out, node.prelude, cssTightValue, nil)
+		}
+		if !node.block {
+			semicolon = true
+			continue
+		}
+		out.WriteByte('{')
+		writeMinifiedCSS(out, node.children)
+		out.WriteByte('}')
+		// A declaration still ends at a semicolon after its block, as in
+		// --x:{a:b};--y:0
+		semicolon = node.kind == cssDeclNode
+	}
+}
+
+// joinMinifiedCSS writes tokens without comments, separated by a space
+// only where the source separated them and tight does not allow dropping
+// it. text, when given, rewrites the token at index i.
+func joinMinifiedCSS(out *mappedOutput, toks []cssToken, tight func(prev, tok cssToken, nesting int) bool, text func(i int) string) {
+	var prev cssToken
+	written := false
+	nesting := 0
+	dropped, droppedSpace := false, false
+	for i, tok := range toks {
+		if tok.isComment() && !isLegalCSSComment(tok) {
+			dropped = true
+			droppedSpace = droppedSpace || tok.spaced
+			continue
+		}
+		if tok.is(")") || tok.is("]") {
+			nesting--
+		}
+		if written {
+			// A comment between two words was all that kept them apart
+			space := tok.spaced || droppedSpace || dropped && prev.kind != cssDelim && tok.kind != cssDelim
+			if space && !tight(prev, tok, nesting) {
+				out.WriteByte(' ')
+			}
+		}
+		if tok.is("(") || tok.is("[") {
+			nesting++
+		}
+		if text != nil {
+			out.token(text(i), tok.start)
+		} else {
+			out.token(tok.text, tok.start)
+		}
+		prev, written = tok, true
+		dropped, droppedSpace = false, false
+	}
+}
+
+// cssTightAlways reports whether the space between two tokens can go in
+// any context: around commas and inside brackets
+func cssTightAlways(prev, tok cssToken) bool {
+	return prev.is(",") || tok.is(",") || prev.is("(") || prev.is("[") || tok.is(")") || tok.is("]")
+}
+
+// cssTightSelector drops the spaces around combinators, leaving the
+// descendant combinator a single space
+func cssTightSelector(prev, tok cssToken, nesting int) bool {
+	return cssTightAlways(prev, tok) || nesting == 0 && (isCSSCombinator(prev) || isCSSCombinator(tok))
+}
+
+// cssTightAtRule drops the spaces around the colons of media features, but
+// not the one before a parenthesis: and( would read as a function
+func cssTightAtRule(prev, tok cssToken, nesting int) bool {
+	return cssTightAlways(prev, tok) || nesting > 0 && (prev.is(":") || tok.is(":"))
+}
+
+// cssTightValue drops the spaces before !important and around the colons
+// of SCSS maps. Operators keep theirs, which calc() requires.
+func cssTightValue(prev, tok cssToken, nesting int) bool {
+	return cssTightAlways(prev, tok) || tok.is("!") || prev.is("!") ||
+		nesting > 0 && (prev.is(":") || tok.is(":"))
+}
+
+func minifyCSSSelector(out *mappedOutput, toks []cssToken) {
+	joinMinifiedCSS(out, toks, cssTightSelector, nil)
+}
+
+func minifyCSSAtRule(out *mappedOutput, toks []cssToken) {
+	joinMinifiedCSS(out, toks, cssTightAtRule, nil)
+}
+
+// minifyCSSValue minifies a declaration's value, shortening hex colors
+// and dropping the unit of zero lengths. Values of custom properties and
+// preprocessor variables keep their units, since they may end up in
+// calc() or in arithmetic; so do flex values, where some browsers read a
+// bare zero basis as a flex factor.
+func minifyCSSValue(out *mappedOutput, node *cssNode) {
+	name := strings.ToLower(node.property())
+	keepUnits := strings.HasPrefix(name, "--") || node.isVariable() || strings.HasSuffix(name, "flex")
+
+	toks := node.value
+	var functions []string
+	inMath := 0
+	joinMinifiedCSS(out, toks, cssTightValue, func(i int) string {
+		tok := toks[i]
+		switch {
+		case tok.is("("):
+			function := ""
+			if i > 0 && toks[i-1].kind == cssIdent && !tok.spaced {
+				function = strings.ToLower(toks[i-1].text)
+			}
+			if cssMathFunctions[function] {
+				inMath++
+			}
+			functions = append(functions, function)
+		case tok.is(")") && len(functions) > 0:
+			if cssMathFunctions[functions[len(functions)-1]] {
+				inMath--
+			}
+			functions = functions[:len(functions)-1]
+		case tok.kind == cssHash:
+			return shortenCSSColor(tok.text)
+		case tok.kind == cssNumber && !keepUnits && inMath == 0 && isCSSZeroLength(tok.text):
+			return "0"
+		}
+		return tok.text
+	})
+}
+
+// shortenCSSColor lowercases a hex color and writes #aabbcc as #abc and
+// #aabbccdd as #abcd. Other hashes are returned unchanged.
+func shortenCSSColor(hash string) string {
+	digits := hash[1:]
+	switch len(digits) {
+	case 3, 4, 6, 8:
+	default:
+		return hash
+	}
+	for i := 0; i < len(digits); i++ {
+		if !isHexDigit(digits[i]) {
+			return hash
+		}
+	}
+	digits = strings.ToLower(digits)
+	if len(digits) == 6 || len(digits) == 8 {
+		short := make([]byte, 0, len(digits)/2)
+		for i := 0; i < len(digits); i += 2 {
+			if digits[i] != digits[i+1] {
+				return "#" + digits
+			}
+			short = append(short, digits[i])
+		}
+		digits = string(short)
+	}
+	return "#" + digits
+}
+
+// isCSSZeroLength reports whether a number token is a zero with a length
+// unit, such as 0px or 0.0em
+func isCSSZeroLength(text string) bool {
+	end := 0
+	for end < len(text) && (text[end] == '0' || text[end] == '.') {
+		end++
+	}
+	if end == 0 || end < len(text) && isDigit(rune(text[end])) || !strings.Contains(text[:end], "0") {
+		return false
+	}
+	return cssZeroUnits[strings.ToLower(text[end:])]
+}
+
+func minifyCSSCode(code string, _ *FormatOptions) (string, error) {
+	result, err := minifyCSS(code, cssDialect{})
+	if err != nil {
+		return "", fmt.Errorf("invalid CSS syntax: %w", err)
+	}
+	return result, nil
+}
+
+func minifyCSSMapped(code string, opts *FormatOptions) (string, string, error) {
+	result, sourceMap, err := minifyCSSWithMap(code, cssDialect{}, opts, "input.css")
+	if err != nil {
+		return "", "", fmt.Errorf("invalid CSS syntax: %w", err)
+	}
+	return result, sourceMap, nil
+}
+
+func minifySCSSCode(code string, _ *FormatOptions) (string, error) {
+	result, err := minifyCSS(code, cssDialect{scss: true})
+	if err != nil {
+		return "", fmt.Errorf("invalid SCSS syntax: %w", err)
+	}
+	return result, nil
+}
+
+func minifySCSSMapped(code string, opts *FormatOptions) (string, string, error) {
+	result, sourceMap, err := minifyCSSWithMap(code, cssDialect{scss: true}, opts, "input.scss")
+	if err != nil {
+		return "", "", fmt.Errorf("invalid SCSS syntax: %w", err)
+	}
+	return result, sourceMap, nil
+}
+
+func minifyLessCode(code string, _ *FormatOptions) (string, error) {
+	result, err := minifyCSS(code, cssDialect{less: true})
+	if err != nil {
+		return "", fmt.Errorf("invalid Less syntax: %w", err)
+	}
+	return result, nil
+}
+
+func minifyLessMapped(code string, opts *FormatOptions) (string, string, error) {
+	result, sourceMap, err := minifyCSSWithMap(code, cssDialect{less: true}, opts, "input.less")
+	if err != nil {
+		return "", "", fmt.Errorf("invalid Less syntax: %w", err)
+	}
+	return result, sourceMap, nil
+}
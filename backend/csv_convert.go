@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// csvValueKey names the column holding values that are not objects
+const csvValueKey = "value"
+
+// csvNumberPattern matches cells read as numbers. Leading zeros are left
+// out, as codes such as 00501 are text.
+var csvNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// decodeCSV reads records as a list of objects keyed by the header. The
+// first row is taken as the header unless the header option says
+// otherwise or one of its cells is empty, repeated, a number or a boolean.
+func decodeCSV(code string, opts *FormatOptions, log *conversionLog) ([]interface{}, error) {
+	records, err := parseCSV(code)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]interface{}, 0, len(records))
+	if len(records) == 0 {
+		return list, nil
+	}
+
+	header, set := opts.header()
+	if !set {
+		header = csvHeaderLike(records[0].cells)
+		if !header {
+			log.warnf("the first row does not look like a header, so the columns were named column1, column2, ...")
+		}
+	}
+	var columns []string
+	if header {
+		columns = csvColumns(records[0].cells, log)
+		records = records[1:]
+	}
+
+	for _, record := range records {
+		if header && len(record.cells) != len(columns) {
+			log.warnf("line %d: the row has %d cells and the header has %d", record.line, len(record.cells), len(columns))
+		}
+		m := newDataMap()
+		for i, cell := range record.cells {
+			if i >= len(columns) {
+				columns = append(columns, csvColumnName(i, columns))
+			}
+			m.set(columns[i], csvCell(cell))
+		}
+		list = append(list, m)
+	}
+	return list, nil
+}
+
+// csvHeaderLike reports whether a row looks like a header: text cells
+// that are neither empty nor repeated
+func csvHeaderLike(cells []string) bool {
+	seen := make(map[string]bool, len(cells))
+	for _, cell := range cells {
+		if _, text := csvCell(cell).(string); !text || strings.TrimSpace(cell) == "" || seen[cell] {
+			return false
+		}
+		seen[cell] = true
+	}
+	return true
+}
+
+// csvColumns names columns after a header row, naming empty and repeated
+// cells after their position
+func csvColumns(cells []string, log *conversionLog) []string {
+	columns := make([]string, 0, len(cells))
+	seen := make(map[string]bool, len(cells))
+	for i, cell := range cells {
+		name := cell
+		if name == "" || seen[name] {
+			name = csvColumnName(i, cells)
+			if cell == "" {
+				log.warnf("the empty header of column %d was named %q", i+1, name)
+			} else {
+				log.warnf("the repeated header %q of column %d was named %q", cell, i+1, name)
+			}
+		}
+		seen[name] = true
+		columns = append(columns, name)
+	}
+	return columns
+}
+
+// csvColumnName names the column at index i after its position, avoiding
+// the names already taken
+func csvColumnName(i int, taken []string) string {
+	name := "column" + strconv.Itoa(i+1)
+	for n := 2; containsString(taken, name); n++ {
+		name = "column" + strconv.Itoa(i+1) + "_" + strconv.Itoa(n)
+	}
+	return name
+}
+
+// csvCell reads a cell as a number or a boolean when it is written as
+// one, and as text otherwise
+func csvCell(cell string) interface{} {
+	switch {
+	case cell == "true":
+		return true
+	case cell == "false":
+		return false
+	case csvNumberPattern.MatchString(cell):
+		return dataNumber(cell)
+	}
+	return cell
+}
+
+func decodeCSVCode(code string, opts *FormatOptions, log *conversionLog) (interface{}, error) {
+	list, err := decodeCSV(code, opts, log)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV syntax: %w", err)
+	}
+	return list, nil
+}
+
+// encodeCSV writes a list of objects as rows under a header naming every
+// key, in the order the keys first appear. Items that are not objects go
+// in a column of their own.
+func encodeCSV(value interface{}, opts *FormatOptions, log *conversionLog) (string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		log.warnf("the data was written as a single row, as CSV holds a list of rows")
+		list = []interface{}{value}
+	}
+
+	var columns []string
+	index := make(map[string]int)
+	add := func(key string) {
+		if _, ok := index[key]; !ok {
+			index[key] = len(columns)
+			columns = append(columns, key)
+		}
+	}
+	for _, item := range list {
+		if m, ok := item.(*dataMap); ok {
+			for _, key := range m.keys {
+				add(key)
+			}
+		} else {
+			add(csvValueKey)
+		}
+	}
+
+	records := make([]csvRecord, 0, len(list)+1)
+	if header, set := opts.header(); header || !set {
+		records = append(records, csvRecord{cells: columns})
+	} else if len(columns) > 0 {
+		log.warnf("the header was left out, so the column names were dropped")
+	}
+	for _, item := range list {
+		cells := make([]string, len(columns))
+		if m, ok := item.(*dataMap); ok {
+			for _, key := range m.keys {
+				cells[index[key]] = csvText(m.values[key], log)
+			}
+		} else {
+			cells[index[csvValueKey]] = csvText(item, log)
+		}
+		records = append(records, csvRecord{cells: cells})
+	}
+	if len(columns) == 0 {
+		return "", nil
+	}
+	return writeCSV(records), nil
+}
+
+// csvText returns the cell a value is written as
+func csvText(value interface{}, log *conversionLog) string {
+	switch v := value.(type) {
+	case nil:
+		log.warnf("null values were written as empty cells")
+		return ""
+	case bool:
+		return strconv.FormatBool(v)
+	case dataNumber:
+		return string(v)
+	case string:
+		return v
+	}
+	log.warnf("nested values were written as JSON text")
+	json, _ := encodeJSON(value, nil, log)
+	return json
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvRecord is a row of cells and the line it starts on
+type csvRecord struct {
+	cells []string
+	line  int
+}
+
+// parseCSV reads comma-separated records as RFC 4180 describes them. Rows
+// may have different numbers of cells, and blank lines are skipped.
+func parseCSV(src string) ([]csvRecord, error) {
+	r := csv.NewReader(strings.NewReader(src))
+	r.FieldsPerRecord = -1
+	var records []csvRecord
+	for {
+		cells, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, csvError(src, err)
+		}
+		line, _ := r.FieldPos(0)
+		records = append(records, csvRecord{cells: cells, line: line})
+	}
+}
+
+// csvError converts an encoding/csv error, whose columns count bytes
+func csvError(src string, err error) error {
+	var parseErr *csv.ParseError
+	if !errors.As(err, &parseErr) {
+		return err
+	}
+	offset := min(csvLineOffset(src, parseErr.Line)+max(parseErr.Column, 1)-1, len(src))
+	msg := parseErr.Err.Error()
+	if errors.Is(parseErr.Err, csv.ErrQuote) {
+		// The error is reported at the end of the input, which is of no
+		// help in finding the quote
+		msg = "unclosed quoted field"
+		offset = csvOpenQuote(src, csvLineOffset(src, parseErr.StartLine))
+	} else if errors.Is(parseErr.Err, csv.ErrBareQuote) {
+		msg = `a quote in an unquoted field must be written in a quoted field as ""`
+	}
+	return newSyntaxError(syntaxCSV, src, offset, "%s", msg)
+}
+
+// csvLineOffset returns the offset of a 1-based line
+func csvLineOffset(src string, line int) int {
+	offset := 0
+	for ; line > 1; line-- {
+		i := strings.IndexByte(src[offset:], '\n')
+		if i < 0 {
+			return len(src)
+		}
+		offset += i + 1
+	}
+	return offset
+}
+
+// csvOpenQuote returns the offset of the quote opening the last quoted
+// field of the record starting at offset
+func csvOpenQuote(src string, offset int) int {
+	open, quoted, fieldStart := offset, false, true
+	for i := offset; i < len(src); i++ {
+		switch c := src[i]; {
+		case quoted:
+			if c == '"' {
+				if i+1 < len(src) && src[i+1] == '"' {
+					i++
+				} else {
+					quoted = false
+				}
+			}
+		case c == '"' && fieldStart:
+			open, quoted = i, true
+		}
+		fieldStart = !quoted && (src[i] == ',' || src[i] == '\n')
+	}
+	return open
+}
+
+// formatCSV rewrites records with quotes only where a cell needs them
+func formatCSV(src string) (string, error) {
+	records, err := parseCSV(src)
+	if err != nil {
+		return "", err
+	}
+	return writeCSV(records), nil
+}
+
+func writeCSV(records []csvRecord) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	for _, record := range records {
+		// A row of one empty cell would be a blank line, which is skipped
+		if len(record.cells) == 1 && record.cells[0] == "" {
+			w.Flush()
+			b.WriteString(`""` + "\n")
+			continue
+		}
+		// Writes to a strings.Builder cannot fail
+		_ = w.Write(record.cells)
+	}
+	w.Flush()
+	return b.String()
+}
+
+func formatCSVCode(code string, _ *FormatOptions) (string, error) {
+	formatted, err := formatCSV(code)
+	if err != nil {
+		return "", fmt.Errorf("invalid CSV syntax: %w", err)
+	}
+	return formatted, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"quoting kept", "name,age\n\"x,y\",1\n", "name,age\n\"x,y\",1\n"},
+		{"leading space quoted", "name,age\nada, 36\n", "name,age\nada,\" 36\"\n"},
+		{"final newline", "a,b\n1,2", "a,b\n1,2\n"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Format(tt.code, "csv")
+			if err != nil {
+				t.Fatalf("Format(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if again, err := f.Format(got, "csv"); err != nil || again != got {
+				t.Errorf("Format(%q) = %q, %v; not idempotent", got, again, err)
+			}
+		})
+	}
+}
+
+func TestFormatCSVErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"a,b\n\"unterminated,1\n", "invalid CSV syntax: 2:1: unclosed quoted field"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		_, err := f.Format(tt.code, "csv")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Format(%q) error = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+}
+
+func TestConvertCSV(t *testing.T) {
+	noHeader := false
+	tests := []struct {
+		name         string
+		code         string
+		from, to     string
+		opts         *FormatOptions
+		want         string
+		wantWarnings []string
+	}{
+		{
+			"header inferred", "name,age\n\"x,y\",1\n", "csv", "json", nil,
+			"[\n  {\n    \"name\": \"x,y\",\n    \"age\": 1\n  }\n]\n", nil,
+		},
+		{
+			"no header inferred", "1,2\n3,4\n", "csv", "json", nil,
+			"[\n  {\n    \"column1\": 1,\n    \"column2\": 2\n  },\n  {\n    \"column1\": 3,\n    \"column2\": 4\n  }\n]\n",
+			[]string{"the first row does not look like a header, so the columns were named column1, column2, ..."},
+		},
+		{
+			"no header set", "a,b\n1,2\n", "csv", "json", &FormatOptions{Header: &noHeader},
+			"[\n  {\n    \"column1\": \"a\",\n    \"column2\": \"b\"\n  },\n  {\n    \"column1\": 1,\n    \"column2\": 2\n  }\n]\n", nil,
+		},
+		{"columns from JSON", `[{"a":1,"b":true},{"a":2,"c":"x"}]`, "json", "csv", nil, "a,b,c\n1,true,\n2,,x\n", nil},
+		{
+			"object from JSON", `{"a":1,"n":{"b":2}}`, "json", "csv", nil, "a,n\n1,\"{\"\"b\"\":2}\"\n",
+			[]string{"the data was written as a single row, as CSV holds a list of rows", "nested values were written as JSON text"},
+		},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Convert(tt.code, tt.from, tt.to, tt.opts)
+			if err != nil {
+				t.Fatalf("Convert(%q) error: %v", tt.code, err)
+			}
+			if got.Code != tt.want {
+				t.Errorf("Convert(%q) = %q, want %q", tt.code, got.Code, tt.want)
+			}
+			if len(got.Warnings) > 0 || len(tt.wantWarnings) > 0 {
+				if !reflect.DeepEqual(got.Warnings, tt.wantWarnings) {
+					t.Errorf("Convert(%q) warnings = %q, want %q", tt.code, got.Warnings, tt.wantWarnings)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertCSVRoundTrip(t *testing.T) {
+	codes := []string{
+		"name,age\n\"x,y\",1\nada,36\n",
+		"a,b\n\"say \"\"hi\"\"\",true\n",
+	}
+
+	f := NewFormatter()
+	for _, code := range codes {
+		json, err := f.Convert(code, "csv", "json", nil)
+		if err != nil {
+			t.Fatalf("Convert(%q) error: %v", code, err)
+		}
+		back, err := f.Convert(json.Code, "json", "csv", nil)
+		if err != nil {
+			t.Fatalf("Convert(%q) error: %v", json.Code, err)
+		}
+		if back.Code != code {
+			t.Errorf("Convert(%q) back to CSV = %q, want %q", json.Code, back.Code, code)
+		}
+	}
+}
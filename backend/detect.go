@@ -0,0 +1,442 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"math"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// autoLanguage asks the Formatter to detect the language of a snippet
+const autoLanguage = "auto"
+
+// minDetectConfidence is the lowest score accepted as a detection
+const minDetectConfidence = 0.25
+
+// Detection is the outcome of guessing the language of a snippet
+type Detection struct {
+	Language   string
+	Confidence float64
+}
+
+// IsAutoLanguage reports whether language asks for detection
+func IsAutoLanguage(language string) bool {
+	language = strings.TrimSpace(language)
+	return language == "" || strings.EqualFold(language, autoLanguage)
+}
+
+// Detect guesses the language of code. A filename or bare extension, when
+// given and registered, decides the language outright; otherwise every
+// backend with a detector scores the content and the best score wins.
+func (f *Formatter) Detect(code, filename string) (*Detection, error) {
+	if filename = strings.TrimSpace(filename); filename != "" {
+		ext := path.Ext(filename)
+		if ext == "" {
+			ext = filename
+		}
+		if backend, ok := f.registry.LookupExtension(ext); ok {
+			return &Detection{Language: backend.Name, Confidence: 1}, nil
+		}
+	}
+
+	if strings.TrimSpace(code) == "" {
+		return nil, fmt.Errorf("code cannot be empty")
+	}
+
+	var best *LanguageBackend
+	bestScore := 0.0
+	for _, backend := range f.registry.Languages() {
+		if backend.Detect == nil {
+			continue
+		}
+		if score := backend.Detect(code); score > bestScore {
+			best, bestScore = backend, score
+		}
+	}
+	if best == nil || bestScore < minDetectConfidence {
+		return nil, fmt.Errorf("could not detect the language; set the language field")
+	}
+	return &Detection{Language: best.Name, Confidence: math.Round(bestScore*100) / 100}, nil
+}
+
+// detectHint is a pattern suggesting a language, weighted by how
+// strongly it does so on its own
+type detectHint struct {
+	pattern *regexp.Regexp
+	weight  float64
+}
+
+func hint(pattern string, weight float64) detectHint {
+	return detectHint{regexp.MustCompile(pattern), weight}
+}
+
+// hintScore combines the weights of the matching hints as independent
+// evidence, so each further match raises the score towards but never to 1
+func hintScore(code string, hints ...[]detectHint) float64 {
+	miss := 1.0
+	for _, set := range hints {
+		for _, s := range set {
+			if s.pattern.MatchString(code) {
+				miss *= 1 - s.weight
+			}
+		}
+	}
+	return 1 - miss
+}
+
+// parseFailurePenalty scales down the score of content the language's own
+// parser rejects; snippets are often incomplete, so it is not ruled out
+const parseFailurePenalty = 0.3
+
+var (
+	goPackageClause = regexp.MustCompile(`(?m)^package\s+[A-Za-z_]\w*\s*$`)
+
+	goHints = []detectHint{
+		hint(`(?m)^func\s+(\([^)]*\)\s*)?[A-Za-z_]\w*\s*\(`, 0.5),
+		hint(`:=`, 0.4),
+		hint(`\bfmt\.[A-Z]\w*\(`, 0.5),
+		hint(`\berr\s*!=\s*nil\b`, 0.6),
+		hint(`(?m)^import\s+(\(|")`, 0.5),
+		hint(`\b(chan|defer|go\s+func)\b`, 0.4),
+		hint(`(?m)^type\s+\w+\s+(struct|interface)\s*\{`, 0.6),
+		hint(`\[\]\w+\{`, 0.3),
+	}
+)
+
+func detectGo(code string) float64 {
+	score := 0.95
+	if !goPackageClause.MatchString(code) {
+		if score = hintScore(code, goHints); score == 0 {
+			return 0
+		}
+	}
+	// format.Source also accepts declaration and statement lists
+	if _, err := format.Source([]byte(code)); err != nil {
+		score *= parseFailurePenalty
+	}
+	return score
+}
+
+func detectJSON(code string) float64 {
+	trimmed := strings.TrimSpace(code)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return 0
+	}
+	if _, err := reprintJSON(code, "", false); err != nil {
+		// Probably a JavaScript object or array literal
+		return 0.3 * parseFailurePenalty
+	}
+	return 0.98
+}
+
+var phpHints = []detectHint{
+	hint(`\$[A-Za-z_]\w*\s*=[^=]`, 0.3),
+	hint(`\$this->`, 0.6),
+	hint(`\bfunction\s+\w+\s*\(\s*(\??\w+\s+)?\$`, 0.6),
+	hint(`\b(echo|print)\s+["'$]`, 0.4),
+	hint(`(?m)^\s*namespace\s+[\w\\]+;`, 0.6),
+	hint(`(?m)^\s*use\s+[\w\\]+(\s+as\s+\w+)?;`, 0.3),
+	hint(`::class\b`, 0.5),
+	hint(`\barray\s*\(`, 0.3),
+	hint(`\$\w+\s*\.=`, 0.4),
+}
+
+func detectPHP(code string) float64 {
+	trimmed := strings.TrimSpace(code)
+	if strings.HasPrefix(trimmed, "<?php") || strings.HasPrefix(trimmed, "<?=") {
+		return 0.99
+	}
+	if strings.Contains(code, "<?php") {
+		// PHP embedded in an HTML template
+		return 0.9
+	}
+	// Without an opening tag nothing is certain
+	return 0.9 * hintScore(code, phpHints)
+}
+
+var (
+	jsHints = []detectHint{
+		hint(`\bfunction\b\s*\*?\s*[\w$]*\s*\(`, 0.3),
+		hint(`\b(const|let|var)\s+[\w${\[]`, 0.4),
+		hint(`=>`, 0.3),
+		hint(`\bconsole\.\w+\(`, 0.5),
+		hint(`\brequire\(\s*['"]`, 0.5),
+		hint(`(?m)^\s*import\s+.*\bfrom\s+['"]`, 0.5),
+		hint(`(?m)^\s*export\s+(default|const|let|function|class|async)\b`, 0.5),
+		hint(`\b(document|window)\.\w+`, 0.5),
+		hint(`===|!==`, 0.4),
+		hint(`\.then\(|\basync\s+(function|\()|\bawait\s`, 0.3),
+	}
+
+	tsHints = []detectHint{
+		hint(`(?m)^\s*(export\s+)?interface\s+\w+`, 0.6),
+		hint(`(?m)^\s*(export\s+)?type\s+\w+(<[^>]*>)?\s*=`, 0.6),
+		hint(`[\w)?]\s*:\s*(string|number|boolean|any|unknown|void|never)\b`, 0.6),
+		hint(`(?m)^\s*(export\s+)?(const\s+)?enum\s+\w+\s*\{`, 0.5),
+		hint(`\b(private|public|protected|readonly)\s+[\w$]+\s*[:;=(]`, 0.4),
+		hint(`\bas\s+(const|string|number|any|unknown)\b`, 0.4),
+		hint(`\bimplements\s+\w+`, 0.3),
+		hint(`\(\s*[\w$]+\??\s*:\s*[A-Z][\w.]*`, 0.4),
+		hint(`\)\s*:\s*[A-Z][\w.]*(<[^>]*>)?(\[\])?\s*(=>|\{)`, 0.5),
+	}
+
+	jsxHints = []detectHint{
+		hint(`\breturn\s*\(?\s*<[A-Za-z]`, 0.5),
+		hint(`<[A-Za-z][\w.]*(\s+[\w-]+=(\{|"))`, 0.4),
+		hint(`</[A-Za-z][\w.]*>|/>`, 0.3),
+		hint(`\bclassName=`, 0.5),
+	}
+)
+
+func jsParses(code string, dialect jsDialect) bool {
+	_, _, err := parseJS(code, dialect)
+	return err == nil
+}
+
+func detectJavaScript(code string) float64 {
+	score := hintScore(code, jsHints, jsxHints)
+	if score == 0 {
+		return 0
+	}
+	if !jsParses(code, jsDialect{jsx: true}) {
+		score *= parseFailurePenalty
+	}
+	return score
+}
+
+// detectTypeScript scores TypeScript without JSX. Plain JavaScript is also
+// valid TypeScript, so content that parses as JavaScript ranks below it.
+func detectTypeScript(code string) float64 {
+	score := hintScore(code, jsHints, tsHints)
+	if score == 0 {
+		return 0
+	}
+	switch {
+	case !jsParses(code, jsDialect{typescript: true}):
+		score *= parseFailurePenalty
+	case jsParses(code, jsDialect{jsx: true}):
+		score *= 0.8
+	}
+	return score
+}
+
+// detectTSX only wins for content that needs both type syntax and JSX
+func detectTSX(code string) float64 {
+	score := hintScore(code, jsHints, tsHints, jsxHints)
+	if hintScore(code, tsHints) == 0 || hintScore(code, jsxHints) == 0 {
+		return score * 0.5
+	}
+	switch {
+	case !jsParses(code, jsDialect{typescript: true, jsx: true}):
+		score *= parseFailurePenalty
+	case jsParses(code, jsDialect{jsx: true}) || jsParses(code, jsDialect{typescript: true}):
+		score *= 0.7
+	}
+	return score
+}
+
+var (
+	cssHints = []detectHint{
+		hint(`(?m)^\s*[.#][\w-]+[^{};()=]*\{`, 0.4),
+		hint(`(?m)^\s*(html|body|a|p|div|span|ul|ol|li|h[1-6]|img|button|input|table|\*)([\s,.:>\[][^{};()=]*)?\{`, 0.4),
+		hint(`(?m)^\s*(color|background(-color)?|margin|padding|display|font-(size|family|weight)|width|height|border|position)\s*:\s*[^;{}]+;`, 0.4),
+		hint(`(?m)^\s*@(media|import|font-face|keyframes|supports|charset|layer|container)\b`, 0.6),
+		hint(`!important\b`, 0.5),
+		hint(`\b\d+(\.\d+)?(px|rem|em|vh|vw)\b`, 0.3),
+		hint(`:(hover|focus|active|visited|first-child|last-child|nth-child\(|before|after)\b`, 0.3),
+		hint(`#[0-9a-fA-F]{3}([0-9a-fA-F]{3})?\s*;`, 0.3),
+		hint(`(?m)^\s*&[\w:.-][^{};]*\{`, 0.3),
+	}
+
+	scssHints = []detectHint{
+		hint(`(?m)^\s*\$[\w-]+\s*:`, 0.6),
+		hint(`(?m)^\s*@(mixin|include|extend|use|forward|function|each|if)\b`, 0.6),
+		hint(`#\{`, 0.5),
+	}
+
+	lessHints = []detectHint{
+		hint(`(?m)^\s*@[\w-]+\s*:`, 0.6),
+		hint(`(?m)^\s*[.#][\w-]+\([^)]*\)\s*(!important\s*)?;`, 0.5),
+		hint(`\bwhen\s*\(`, 0.4),
+		hint(`@\{[\w-]+\}`, 0.5),
+		hint(`~["']|:extend\(`, 0.5),
+	}
+)
+
+func cssParses(code string, dialect cssDialect) bool {
+	_, err := parseCSS(code, dialect)
+	return err == nil
+}
+
+// detectCSS scores plain CSS. Style sheets have no keywords of their own,
+// so the score rests on the shape of rules and declarations.
+func detectCSS(code string) float64 {
+	score := 0.9 * hintScore(code, cssHints)
+	if score > 0 && !cssParses(code, cssDialect{}) {
+		score *= parseFailurePenalty
+	}
+	return score
+}
+
+// detectSCSS only ranks above CSS for content using SCSS syntax, since
+// plain CSS is also valid SCSS
+func detectSCSS(code string) float64 {
+	if hintScore(code, scssHints) == 0 {
+		return 0
+	}
+	score := 0.9 * hintScore(code, cssHints, scssHints)
+	if !cssParses(code, cssDialect{scss: true}) {
+		score *= parseFailurePenalty
+	}
+	return score
+}
+
+// detectLess only ranks above CSS for content using Less syntax
+func detectLess(code string) float64 {
+	if hintScore(code, lessHints) == 0 {
+		return 0
+	}
+	score := 0.9 * hintScore(code, cssHints, lessHints)
+	if !cssParses(code, cssDialect{less: true}) {
+		score *= parseFailurePenalty
+	}
+	return score
+}
+
+var (
+	htmlDoctype = regexp.MustCompile(`(?i)^\s*(<!--.*?-->\s*)*<!doctype\s+html`)
+
+	htmlHints = []detectHint{
+		hint(`(?i)<html[\s>]|<head>|<body[\s>]`, 0.7),
+		hint(`(?i)</(div|p|span|a|ul|ol|li|h[1-6]|table|tr|td|section|nav|header|footer|form|button|label)>`, 0.4),
+		hint(`(?i)<(div|p|span|a|img|br|hr|ul|ol|li|h[1-6]|table|form|input|button|meta|link|section)[\s/>]`, 0.3),
+		hint(`<!--.*?-->`, 0.3),
+		hint(`\s(class|id|href|src|alt)="`, 0.3),
+		hint(`&(nbsp|amp|lt|gt|quot|copy|#\d+);`, 0.3),
+	}
+)
+
+// detectHTML scores markup. JSX looks much the same, so attributes
+// holding expressions count against HTML, as does embedded PHP.
+func detectHTML(code string) float64 {
+	score := 0.9 * hintScore(code, htmlHints)
+	switch {
+	case htmlDoctype.MatchString(code):
+		score = 0.95
+	case score == 0:
+		return 0
+	case strings.HasPrefix(strings.TrimSpace(code), "<"):
+		score = 1 - (1-score)*0.5
+	}
+	if strings.Contains(code, "<?") || strings.Contains(code, "={") || strings.Contains(code, "className=") {
+		score *= 0.4
+	}
+	if _, err := parseHTML(code); err != nil {
+		score *= parseFailurePenalty
+	}
+	return score
+}
+
+var (
+	// sqlStatementStart matches the first word of a statement, after any
+	// comments
+	sqlStatementStart = regexp.MustCompile(`(?is)^\s*((--[^\n]*\n|#[^\n]*\n|/\*.*?\*/)\s*)*(select|with|insert|update|delete|create|alter|drop|truncate|merge|explain|grant|revoke|begin|declare|set|use|replace|pragma)\b`)
+
+	sqlHints = []detectHint{
+		hint(`(?is)\bselect\b.+\bfrom\b`, 0.5),
+		hint(`(?i)\bwhere\b`, 0.3),
+		hint(`(?i)\b(inner|left|right|full|cross)\s+(outer\s+)?join\b`, 0.5),
+		hint(`(?i)\b(group|order)\s+by\b`, 0.5),
+		hint(`(?i)\binsert\s+into\b|\bdelete\s+from\b|\bupdate\s+\S+\s+set\b`, 0.6),
+		hint(`(?i)\bcreate\s+(or\s+replace\s+)?(table|view|index|function|procedure|trigger)\b`, 0.7),
+		hint(`(?i)\b(varchar|integer|primary\s+key|not\s+null)\b`, 0.4),
+		hint(`;\s*$`, 0.2),
+	}
+)
+
+// detectSQL scores code starting with a statement keyword. Programs in
+// other languages mention SQL in strings, but never start with it.
+func detectSQL(code string) float64 {
+	if !sqlStatementStart.MatchString(code) {
+		return 0
+	}
+	score := 0.9 * hintScore(code, sqlHints)
+	for _, dialect := range []string{"", dialectPostgreSQL, dialectMySQL, dialectSQLite, dialectTSQL} {
+		if _, err := parseSQL(code, newSQLDialect(dialect)); err == nil {
+			return score
+		}
+	}
+	return score * parseFailurePenalty
+}
+
+var yamlHints = []detectHint{
+	hint(`(?m)^---\s*$`, 0.5),
+	hint(`(?m)^[A-Za-z_][\w.-]*:(\s|$)`, 0.4),
+	hint(`(?m)^\s*- \S`, 0.3),
+	hint(`(?m)^\s+[A-Za-z_][\w.-]*: \S`, 0.3),
+	hint(`(?m)^(apiVersion|kind|jobs|steps|services|version|name|on):`, 0.5),
+	hint(`(?m):\s+[|>][-+]?\s*$`, 0.4),
+}
+
+// detectYAML scores block-style YAML. Flow-style documents are written
+// like JSON and left to it.
+func detectYAML(code string) float64 {
+	trimmed := strings.TrimSpace(code)
+	if trimmed == "" || trimmed[0] == '{' || trimmed[0] == '[' {
+		return 0
+	}
+	score := 0.9 * hintScore(code, yamlHints)
+	if _, err := parseYAML(code); err != nil {
+		score *= parseFailurePenalty
+	}
+	return score
+}
+
+var tomlHints = []detectHint{
+	hint(`(?m)^\[[A-Za-z0-9_."' -]+\]\s*(#.*)?$`, 0.5),
+	hint(`(?m)^\[\[[A-Za-z0-9_."' -]+\]\]\s*(#.*)?$`, 0.6),
+	hint(`(?m)^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*\s*=\s*("|'|\[|\{|true\b|false\b|[-+0-9])`, 0.5),
+	hint(`(?m)^\[(package|dependencies|dev-dependencies|tool\.[\w-]+|project|build-system|workspace)\]`, 0.6),
+}
+
+// detectTOML scores documents of key/value pairs and table headers. INI
+// files look much the same, but rarely parse as TOML.
+func detectTOML(code string) float64 {
+	score := 0.9 * hintScore(code, tomlHints)
+	if _, err := parseTOML(code); err != nil {
+		score *= parseFailurePenalty
+	}
+	return score
+}
+
+var (
+	xmlDeclaration = regexp.MustCompile(`^<\?xml\s`)
+	xhtmlRoot      = regexp.MustCompile(`(?i)^<html[\s>]`)
+
+	xmlHints = []detectHint{
+		hint(`\sxmlns(:[\w.-]+)?\s*=`, 0.8),
+		hint(`<[\w.-]+:[\w.-]+[\s/>]`, 0.3),
+		hint(`<!\[CDATA\[`, 0.4),
+		hint(`<[\w.-]+(\s[^<>]*)?/>`, 0.2),
+		hint(`</[\w.:-]+>`, 0.2),
+	}
+)
+
+// detectXML scores markup that starts with an XML declaration or uses
+// namespaces. Other markup, and XHTML, is left to HTML.
+func detectXML(code string) float64 {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(code), "\uFEFF")
+	if !strings.HasPrefix(trimmed, "<") || htmlDoctype.MatchString(trimmed) || xhtmlRoot.MatchString(trimmed) {
+		return 0
+	}
+	score := 0.9 * hintScore(code, xmlHints)
+	if xmlDeclaration.MatchString(trimmed) {
+		score = 1 - (1-score)*0.1
+	}
+	if _, err := parseXML(code); err != nil {
+		score *= parseFailurePenalty
+	}
+	return score
+}
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		filename string
+		want     string
+	}{
+		{"go", "package main\n\nfunc main() {}", "", "Go"},
+		{"json", `{"a": [1, 2]}`, "", "JSON"},
+		{"php", "<?php echo 1;", "", "PHP"},
+		{"javascript", "const a = 1;\nfunction f(x) { return x * 2 }", "", "JavaScript"},
+		{"typescript", "interface A { b: string }\nconst x: number = 1;", "", "TypeScript"},
+		{"tsx", "const a = <div className=\"x\">{b}</div>;\nlet n: number = 1;", "", "TSX"},
+		{"css", "a { color: red; }\n.b > p { margin: 0 }", "", "CSS"},
+		{"scss", "$c: red;\na { color: $c; &:hover { color: blue } }", "", "SCSS"},
+		{"less", "@c: red;\na { color: @c; .mixin(); }", "", "Less"},
+		{"html", "<!DOCTYPE html>\n<html><body><p>Hi</p></body></html>", "", "HTML"},
+		{"sql", "SELECT a, b FROM t WHERE a = 1;", "", "SQL"},
+		{"yaml", "name: app\nversion: 1\nitems:\n  - a\n  - b", "", "YAML"},
+		{"toml", "[server]\nport = 8080\nhost = \"x\"", "", "TOML"},
+		{"xml", "<?xml version=\"1.0\"?>\n<note><to>A</to></note>", "", "XML"},
+		{"filename", "a,b\n1,2", "data.csv", "CSV"},
+		{"bare extension", "let a = 1", "ts", "TypeScript"},
+		{"unknown extension", "package main\n\nfunc main() {}", "main.txt", "Go"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Detect(tt.code, tt.filename)
+			if err != nil {
+				t.Fatalf("Detect(%q, %q) error: %v", tt.code, tt.filename, err)
+			}
+			if got.Language != tt.want {
+				t.Errorf("Detect(%q, %q) = %s, want %s", tt.code, tt.filename, got.Language, tt.want)
+			}
+			if got.Confidence < minDetectConfidence || got.Confidence > 1 {
+				t.Errorf("Detect(%q, %q) confidence %v out of range", tt.code, tt.filename, got.Confidence)
+			}
+			if tt.filename == "data.csv" && got.Confidence != 1 {
+				t.Errorf("detection by file name has confidence %v, want 1", got.Confidence)
+			}
+		})
+	}
+}
+
+func TestDetectFailure(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"hello world", "could not detect the language; set the language field"},
+		{"  \n", "code cannot be empty"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		if got, err := f.Detect(tt.code, ""); err == nil || err.Error() != tt.want {
+			t.Errorf("Detect(%q) = %+v, %v, want error %q", tt.code, got, err, tt.want)
+		}
+	}
+}
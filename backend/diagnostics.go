@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/scanner"
+	"strings"
+	"unicode/utf8"
+)
+
+// Diagnostic severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Languages of syntax errors. A diagnostic code is the language followed
+// by "-syntax", such as js-syntax.
+const (
+	syntaxGo   = "go"
+	syntaxJSON = "json"
+	syntaxJS   = "js"
+	syntaxPHP  = "php"
+	syntaxCSS  = "css"
+	syntaxHTML = "html"
+	syntaxSQL  = "sql"
+	syntaxYAML = "yaml"
+	syntaxTOML = "toml"
+	syntaxXML  = "xml"
+	syntaxCSV  = "csv"
+)
+
+// Diagnostic describes a problem at a range of the input. Lines and columns
+// are 1-based and columns count characters; the end position is exclusive.
+type Diagnostic struct {
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Code      string `json:"code"`
+}
+
+// SyntaxError describes malformed input in a language at a byte offset
+// of the input. Lines and columns are 1-based and columns count
+// characters.
+type SyntaxError struct {
+	Language string
+	Msg      string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+// newSyntaxError returns an error at offset of src
+func newSyntaxError(language, src string, offset int, format string, args ...interface{}) *SyntaxError {
+	line, column := lineColumn(src, offset)
+	return &SyntaxError{Language: language, Msg: fmt.Sprintf(format, args...), Offset: offset, Line: line, Column: column}
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Diagnostics implements diagnosticError
+func (e *SyntaxError) Diagnostics(src string) []Diagnostic {
+	return []Diagnostic{newDiagnostic(src, SeverityError, syntaxCode(e.Language), e.Msg, e.Offset, tokenEnd(src, e.Offset))}
+}
+
+func syntaxCode(language string) string {
+	return language + "-syntax"
+}
+
+// diagnosticError is implemented by backend errors that can point at the
+// place in the input they describe
+type diagnosticError interface {
+	error
+	Diagnostics(src string) []Diagnostic
+}
+
+// DiagnosticsFromError extracts positioned diagnostics from an error
+// returned while processing src. Errors without a position yield none.
+func DiagnosticsFromError(err error, src string) []Diagnostic {
+	var located diagnosticError
+	if errors.As(err, &located) {
+		return located.Diagnostics(src)
+	}
+	var goErrors scanner.ErrorList
+	if errors.As(err, &goErrors) {
+		return goDiagnostics(goErrors, src)
+	}
+	return nil
+}
+
+// newDiagnostic builds a diagnostic for the byte range [start, end) of src
+func newDiagnostic(src string, severity, code, message string, start, end int) Diagnostic {
+	line, column := lineColumn(src, start)
+	endLine, endColumn := lineColumn(src, end)
+	return Diagnostic{
+		Severity:  severity,
+		Message:   message,
+		Line:      line,
+		Column:    column,
+		EndLine:   endLine,
+		EndColumn: endColumn,
+		Code:      code,
+	}
+}
+
+// tokenEnd returns the end of the token starting at offset: a run of word
+// characters, or else a single character
+func tokenEnd(src string, offset int) int {
+	if offset >= len(src) {
+		return len(src)
+	}
+	end := offset
+	for end < len(src) && isAlphaNumeric(src[end]) {
+		end++
+	}
+	if end > offset {
+		return end
+	}
+	_, size := utf8.DecodeRuneInString(src[offset:])
+	return offset + size
+}
+
+// goDiagnostics converts go/scanner errors, whose columns count bytes, to
+// diagnostics
+func goDiagnostics(list scanner.ErrorList, src string) []Diagnostic {
+	diagnostics := make([]Diagnostic, 0, len(list))
+	for _, e := range list {
+		offset := goLineOffset(src, e.Pos.Line) + max(e.Pos.Column, 1) - 1
+		if offset > len(src) {
+			offset = len(src)
+		}
+		diagnostics = append(diagnostics, newDiagnostic(src, SeverityError, syntaxCode(syntaxGo), e.Msg, offset, tokenEnd(src, offset)))
+	}
+	return diagnostics
+}
+
+// unwrapGoFragmentErrors moves error positions from the wrapper go/format
+// puts around source without a package clause back onto src. The wrapper
+// adds no line breaks before src, so only columns of the first line shift,
+// and errors at the brace closing a statement fragment are moved to the
+// end of src.
+func unwrapGoFragmentErrors(err error, src string) error {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) {
+		return err
+	}
+	shift := goFragmentPrefixLen(src)
+	if shift == 0 {
+		return err
+	}
+	endLine := strings.Count(src, "\n") + 1
+	endColumn := len(src) - strings.LastIndexByte(src, '\n')
+	for _, e := range list {
+		e.Pos.Offset = max(e.Pos.Offset-shift, 0)
+		if e.Pos.Line == 1 {
+			e.Pos.Column = max(e.Pos.Column-shift, 1)
+		}
+		if e.Pos.Offset > len(src) || e.Pos.Line > endLine || e.Pos.Line == endLine && e.Pos.Column > endColumn {
+			e.Pos.Offset, e.Pos.Line, e.Pos.Column = len(src), endLine, endColumn
+		}
+	}
+	return err
+}
+
+// goLineOffset returns the byte offset at which a 1-based line starts
+func goLineOffset(src string, line int) int {
+	offset := 0
+	for ; line > 1; line-- {
+		i := strings.IndexByte(src[offset:], '\n')
+		if i < 0 {
+			return len(src)
+		}
+		offset += i + 1
+	}
+	return offset
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSyntaxDiagnostics(t *testing.T) {
+	tests := []struct {
+		language string
+		code     string
+		want     Diagnostic
+	}{
+		{"json", "{\n  \"a\": tru\n}", Diagnostic{Message: `invalid literal "tru"`, Line: 2, Column: 8, EndLine: 2, EndColumn: 11, Code: "json-syntax"}},
+		{"javascript", "let a = ;", Diagnostic{Message: "unexpected token ;", Line: 1, Column: 9, EndLine: 1, EndColumn: 10, Code: "js-syntax"}},
+		{"typescript", "let a: = 1", Diagnostic{Message: "unexpected token =", Line: 1, Column: 8, EndLine: 1, EndColumn: 9, Code: "js-syntax"}},
+		{"php", "<?php\n$a = 'x;", Diagnostic{Message: "unterminated string", Line: 2, Column: 6, EndLine: 2, EndColumn: 7, Code: "php-syntax"}},
+		{"css", "a { color: red;", Diagnostic{Message: "unclosed {", Line: 1, Column: 3, EndLine: 1, EndColumn: 4, Code: "css-syntax"}},
+		{"html", "<div>\n<p class=\"x></p>", Diagnostic{Message: "unterminated attribute value", Line: 2, Column: 10, EndLine: 2, EndColumn: 11, Code: "html-syntax"}},
+		{"sql", "select 'a from t", Diagnostic{Message: "unterminated string literal", Line: 1, Column: 8, EndLine: 1, EndColumn: 9, Code: "sql-syntax"}},
+		{"yaml", "a: [1, 2\nb: 1", Diagnostic{Message: "unclosed [", Line: 1, Column: 4, EndLine: 1, EndColumn: 5, Code: "yaml-syntax"}},
+		{"toml", "a = \nb = 1", Diagnostic{Message: "expected a value, found end of line", Line: 1, Column: 5, EndLine: 2, EndColumn: 1, Code: "toml-syntax"}},
+		{"xml", "<a><b></a>", Diagnostic{Message: "expected </b>, found </a>", Line: 1, Column: 7, EndLine: 1, EndColumn: 8, Code: "xml-syntax"}},
+		{"csv", "a,b\n\"x,y\n", Diagnostic{Message: "unclosed quoted field", Line: 2, Column: 1, EndLine: 2, EndColumn: 2, Code: "csv-syntax"}},
+		{"go", "package p\nfunc f( {}", Diagnostic{Message: "expected ')', found '{'", Line: 2, Column: 9, EndLine: 2, EndColumn: 10, Code: "go-syntax"}},
+		{"go", "func f( {}", Diagnostic{Message: "expected ')', found '{'", Line: 1, Column: 9, EndLine: 1, EndColumn: 10, Code: "go-syntax"}},
+		{"go", "x := 1 +", Diagnostic{Message: "expected operand, found '}'", Line: 1, Column: 9, EndLine: 1, EndColumn: 9, Code: "go-syntax"}},
+		{"go", "x := (1 +\n  2", Diagnostic{Message: "expected ')', found newline", Line: 2, Column: 4, EndLine: 2, EndColumn: 4, Code: "go-syntax"}},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			_, err := f.Format(tt.code, tt.language)
+			if err == nil {
+				t.Fatalf("Format(%q) succeeded, want a syntax error", tt.code)
+			}
+			tt.want.Severity = SeverityError
+			diagnostics := DiagnosticsFromError(err, tt.code)
+			if len(diagnostics) != 1 || diagnostics[0] != tt.want {
+				t.Fatalf("diagnostics of %q = %+v, want %+v", tt.code, diagnostics, tt.want)
+			}
+			// The message locates the error where the diagnostic does
+			if at := fmt.Sprintf("%d:%d: %s", tt.want.Line, tt.want.Column, tt.want.Message); !strings.Contains(err.Error(), at) {
+				t.Errorf("error %q does not contain %q", err, at)
+			}
+		})
+	}
+}
+
+func TestEmbeddedSyntaxDiagnostics(t *testing.T) {
+	code := "<p>x</p>\n  <script>let a = ;</script>"
+	_, err := NewFormatter().Format(code, "html")
+	if err == nil {
+		t.Fatal("Format succeeded, want a syntax error")
+	}
+	if strings.Contains(err.Error(), "invalid HTML syntax") {
+		t.Errorf("error %q blames the HTML for a script error", err)
+	}
+	want := Diagnostic{Severity: SeverityError, Message: "unexpected token ;", Line: 2, Column: 19, EndLine: 2, EndColumn: 20, Code: "js-syntax"}
+	if diagnostics := DiagnosticsFromError(err, code); len(diagnostics) != 1 || diagnostics[0] != want {
+		t.Errorf("diagnostics = %+v, want %+v", diagnostics, want)
+	}
+}
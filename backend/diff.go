@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// diffOp is one line of an edit script: kept, deleted from the old text or
+// inserted from the new text
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	old  int  // line index in the old text, for kept and deleted lines
+	new  int  // line index in the new text, for kept and inserted lines
+}
+
+// splitLines splits text after each newline, so every line but possibly the
+// last keeps its terminator and a missing final newline is a difference
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns a shortest edit script turning a into b, using Myers'
+// linear-space divide and conquer algorithm
+func diffLines(a, b []string) []diffOp {
+	d := &lineDiffer{
+		a:        a,
+		b:        b,
+		deleted:  make([]bool, len(a)),
+		inserted: make([]bool, len(b)),
+	}
+	d.compare(0, len(a), 0, len(b))
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && d.deleted[i]:
+			ops = append(ops, diffOp{'-', i, j})
+			i++
+		case j < len(b) && d.inserted[j]:
+			ops = append(ops, diffOp{'+', i, j})
+			j++
+		default:
+			ops = append(ops, diffOp{' ', i, j})
+			i++
+			j++
+		}
+	}
+	return ops
+}
+
+type lineDiffer struct {
+	a, b     []string
+	deleted  []bool
+	inserted []bool
+}
+
+// compare marks the edits between a[aLo:aHi] and b[bLo:bHi]
+func (d *lineDiffer) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			d.inserted[j] = true
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			d.deleted[i] = true
+		}
+	default:
+		// Both sides are non-empty and differ at both ends, so the middle
+		// snake is strictly inside the box and the recursion shrinks
+		x1, y1, x2, y2 := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x1, bLo, y1)
+		d.compare(x1, x2, y1, y2)
+		d.compare(x2, aHi, y2, bHi)
+	}
+}
+
+// middleSnake finds the middle snake of an optimal path through the box by
+// searching forwards from its top left and backwards from its bottom right
+// until the two searches overlap. It returns the snake's start and end.
+func (d *lineDiffer) middleSnake(left, right, top, bottom int) (int, int, int, int) {
+	width, height := right-left, bottom-top
+	delta := width - height
+	odd := delta%2 != 0
+	limit := (width + height + 1) / 2
+
+	// vf[k] is the furthest x reached forwards on diagonal k = x - y;
+	// vb[c] the furthest y reached backwards on diagonal c = k - delta.
+	// Both are indexed from -limit-1 to limit+1.
+	offset := limit + 1
+	vf := make([]int, 2*offset+1)
+	vb := make([]int, 2*offset+1)
+	vf[offset+1] = left
+	vb[offset+1] = bottom
+
+	for depth := 0; depth <= limit; depth++ {
+		for k := depth; k >= -depth; k -= 2 {
+			var x, px int
+			if k == -depth || (k != depth && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+				px = x
+			} else {
+				px = vf[offset+k-1]
+				x = px + 1
+			}
+			y := top + (x - left) - k
+			py := y
+			if depth > 0 && x == px {
+				py = y - 1
+			}
+			for x < right && y < bottom && d.a[x] == d.b[y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+
+			if c := k - delta; odd && c >= -(depth-1) && c <= depth-1 && y >= vb[offset+c] {
+				return px, py, x, y
+			}
+		}
+
+		for c := depth; c >= -depth; c -= 2 {
+			var y, py int
+			if c == -depth || (c != depth && vb[offset+c-1] > vb[offset+c+1]) {
+				y = vb[offset+c+1]
+				py = y
+			} else {
+				py = vb[offset+c-1]
+				y = py - 1
+			}
+			k := c + delta
+			x := left + (y - top) + k
+			px := x
+			if depth > 0 && y == py {
+				px = x + 1
+			}
+			for x > left && y > top && d.a[x-1] == d.b[y-1] {
+				x--
+				y--
+			}
+			vb[offset+c] = y
+
+			if !odd && k >= -depth && k <= depth && x <= vf[offset+k] {
+				return x, y, px, py
+			}
+		}
+	}
+
+	// Unreachable: the searches always meet within limit steps
+	return left, top, right, bottom
+}
+
+// LineRange is a run of changed lines: Count lines of the input starting at
+// Start are replaced by NewCount lines of the output starting at NewStart.
+// Lines are 1-based; an empty run starts at the line it is inserted before.
+type LineRange struct {
+	Start    int `json:"start"`
+	Count    int `json:"count"`
+	NewStart int `json:"newStart"`
+	NewCount int `json:"newCount"`
+}
+
+// changedRanges returns the runs of changed lines between two texts
+func changedRanges(oldText, newText string) []LineRange {
+	if oldText == newText {
+		return nil
+	}
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	var ranges []LineRange
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == ' ' {
+			continue
+		}
+		r := LineRange{Start: ops[i].old + 1, NewStart: ops[i].new + 1}
+		for ; i < len(ops) && ops[i].kind != ' '; i++ {
+			if ops[i].kind == '-' {
+				r.Count++
+			} else {
+				r.NewCount++
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// unifiedDiff returns a unified diff between two texts with the given file
+// names in its header, or an empty string when they are equal
+func unifiedDiff(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	a, b := splitLines(oldText), splitLines(newText)
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for _, hunk := range diffHunks(ops) {
+		writeHunk(&out, ops[hunk[0]:hunk[1]], a, b)
+	}
+	return out.String()
+}
+
+// diffHunks groups an edit script into [start, end) ranges of ops that
+// each hold one or more changes with their surrounding context
+func diffHunks(ops []diffOp) [][2]int {
+	var hunks [][2]int
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := max(i-diffContext, 0)
+		end := i
+		// Extend while the next change is close enough to share context
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end = min(end+diffContext, len(ops))
+				break
+			}
+			end = run
+		}
+		if len(hunks) > 0 && start <= hunks[len(hunks)-1][1] {
+			hunks[len(hunks)-1][1] = end
+		} else {
+			hunks = append(hunks, [2]int{start, end})
+		}
+		i = end
+	}
+	return hunks
+}
+
+func writeHunk(out *strings.Builder, ops []diffOp, a, b []string) {
+	oldStart, newStart := ops[0].old, ops[0].new
+	oldCount, newCount := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+
+	for _, op := range ops {
+		line := ""
+		switch op.kind {
+		case '-':
+			line = a[op.old]
+		default:
+			line = b[op.new]
+		}
+		out.WriteByte(op.kind)
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the 1-based line range of a hunk header. An empty range
+// names the line before it, and a count of one is left implicit.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
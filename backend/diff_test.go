@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name       string
+		old, new   string
+		want       string
+		wantRanges []LineRange
+	}{
+		{"equal", "a\nb\n", "a\nb\n", "", nil},
+		{"changed line", "a\nb\nc\n", "a\nx\nc\n", "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n", []LineRange{{2, 1, 2, 1}}},
+		{"insertion into empty", "", "a\n", "--- a/f\n+++ b/f\n@@ -0,0 +1 @@\n+a\n", []LineRange{{1, 0, 1, 1}}},
+		{"deletion of everything", "a\n", "", "--- a/f\n+++ b/f\n@@ -1 +0,0 @@\n-a\n", []LineRange{{1, 1, 1, 0}}},
+		{
+			"missing final newline", "a", "a\n",
+			"--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+a\n", []LineRange{{1, 1, 1, 1}},
+		},
+		{
+			"separate hunks", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n", "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			"--- a/f\n+++ b/f\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -9,4 +10,3 @@\n 9\n 10\n 11\n-12\n",
+			[]LineRange{{1, 0, 1, 1}, {12, 1, 13, 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("a/f", "b/f", tt.old, tt.new); got != tt.want {
+				t.Errorf("unifiedDiff(%q, %q) = %q, want %q", tt.old, tt.new, got, tt.want)
+			}
+			if got := changedRanges(tt.old, tt.new); !reflect.DeepEqual(got, tt.wantRanges) {
+				t.Errorf("changedRanges(%q, %q) = %+v, want %+v", tt.old, tt.new, got, tt.wantRanges)
+			}
+		})
+	}
+}
+
+// TestDiffLinesEditScript checks that the edit scripts of random texts
+// turn one into the other and are no longer than a longest common
+// subsequence allows
+func TestDiffLinesEditScript(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a'+rng.Intn(4))) + "\n"
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		var oldLines, newLines []string
+		edits := 0
+		for _, op := range diffLines(a, b) {
+			switch op.kind {
+			case ' ':
+				oldLines = append(oldLines, a[op.old])
+				newLines = append(newLines, b[op.new])
+			case '-':
+				oldLines = append(oldLines, a[op.old])
+				edits++
+			case '+':
+				newLines = append(newLines, b[op.new])
+				edits++
+			}
+		}
+		if strings.Join(oldLines, "") != strings.Join(a, "") || strings.Join(newLines, "") != strings.Join(b, "") {
+			t.Fatalf("diffLines(%q, %q) does not turn one into the other", a, b)
+		}
+		if want := len(a) + len(b) - 2*lcsLength(a, b); edits != want {
+			t.Fatalf("diffLines(%q, %q) has %d edits, want %d", a, b, edits, want)
+		}
+	}
+}
+
+// lcsLength returns the length of a longest common subsequence of a and b
+func lcsLength(a, b []string) int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+	return lengths[0][0]
+}
@@ -1,258 +1,170 @@
 package main
 
-import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"go/format"
-	"regexp"
-	"strings"
-)
+import "fmt"
 
 // Formatter provides code formatting and minification capabilities
-type Formatter struct{}
+type Formatter struct {
+	registry *Registry
+}
 
-// NewFormatter creates a new Formatter instance
+// NewFormatter creates a new Formatter instance backed by the built-in languages
 func NewFormatter() *Formatter {
-	return &Formatter{}
+	return NewFormatterWithRegistry(defaultRegistry)
+}
+
+// NewFormatterWithRegistry creates a Formatter that dispatches to the given registry
+func NewFormatterWithRegistry(registry *Registry) *Formatter {
+	return &Formatter{registry: registry}
+}
+
+// Result is the outcome of formatting or minifying with options
+type Result struct {
+	Code string
+	// SourceMap is the source map of minified code, when the sourceMap
+	// option asks for a separate one
+	SourceMap string
+	// IgnoredOptions lists the options that were set but not honored
+	IgnoredOptions []string
 }
 
 // Format formats code based on the language
 func (f *Formatter) Format(code, language string) (string, error) {
-	return formatCode(language, code)
+	result, err := f.FormatWithOptions(code, language, nil)
+	if err != nil {
+		return "", err
+	}
+	return result.Code, nil
 }
 
 // Minify minifies code based on the language
 func (f *Formatter) Minify(code, language string) (string, error) {
-	return minifyCode(language, code)
+	result, err := f.MinifyWithOptions(code, language, nil)
+	if err != nil {
+		return "", err
+	}
+	return result.Code, nil
 }
 
-func formatCode(language, code string) (string, error) {
-	// Validate inputs
-	if code == "" {
-		return "", fmt.Errorf("code cannot be empty")
+// FormatWithOptions formats code, honoring the options the language supports
+func (f *Formatter) FormatWithOptions(code, language string, opts *FormatOptions) (*Result, error) {
+	backend, err := f.lookup(code, language, opts)
+	if err != nil {
+		return nil, err
 	}
-
-	switch language {
-	case "Go":
-		return formatGoCode(code)
-	case "JSON":
-		return formatJSONCode(code)
-	case "PHP":
-		return formatPHPCode(code)
-	case "JavaScript", "JS":
-		return formatJavaScriptCode(code)
-	default:
-		return "", fmt.Errorf("unsupported language: %s", language)
+	if !backend.CanFormat() {
+		return nil, fmt.Errorf("formatting is not supported for %s", backend.Name)
 	}
+	formatted, err := backend.Format(code, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		Code:           applyOutputOptions(formatted, opts, backend.outputLiterals(formatted, opts)),
+		IgnoredOptions: ignoredOptions(opts, backend.FormatSupports),
+	}, nil
 }
 
-func minifyCode(language, code string) (string, error) {
-	// Validate inputs
-	if code == "" {
-		return "", fmt.Errorf("code cannot be empty")
+// MinifyWithOptions minifies code, honoring the options the language supports
+func (f *Formatter) MinifyWithOptions(code, language string, opts *FormatOptions) (*Result, error) {
+	backend, err := f.lookup(code, language, opts)
+	if err != nil {
+		return nil, err
 	}
-
-	switch language {
-	case "Go":
-		// Go doesn't really "minify" - just format
-		return formatGoCode(code)
-	case "JSON":
-		return minifyJSONCode(code)
-	case "PHP":
-		return minifyPHPCode(code)
-	case "JavaScript", "JS":
-		return minifyJavaScriptCode(code)
-	default:
-		return "", fmt.Errorf("unsupported language: %s", language)
+	if !backend.CanMinify() {
+		return nil, fmt.Errorf("minification is not supported for %s", backend.Name)
+	}
+	var minified, sourceMap string
+	if opts.sourceMap() != "" && backend.MinifyMap != nil {
+		minified, sourceMap, err = backend.MinifyMap(code, opts)
+	} else {
+		minified, err = backend.Minify(code, opts)
 	}
-}
-
-func formatGoCode(code string) (string, error) {
-	formatted, err := format.Source([]byte(code))
 	if err != nil {
-		return "", fmt.Errorf("invalid Go syntax: %v", err)
+		return nil, err
 	}
-	return string(formatted), nil
+	return &Result{
+		Code:           applyOutputOptions(minified, opts, backend.outputLiterals(minified, opts)),
+		SourceMap:      sourceMap,
+		IgnoredOptions: ignoredOptions(opts, backend.MinifySupports),
+	}, nil
 }
 
-func formatJSONCode(code string) (string, error) {
-	var obj interface{}
-	if err := json.Unmarshal([]byte(code), &obj); err != nil {
-		return "", fmt.Errorf("invalid JSON syntax: %v", err)
-	}
+// Backend returns the backend registered for a language name or alias
+func (f *Formatter) Backend(language string) (*LanguageBackend, bool) {
+	return f.registry.Lookup(language)
+}
 
-	formatted, err := json.MarshalIndent(obj, "", "  ")
-	if err != nil {
-		return "", fmt.Errorf("failed to format JSON: %v", err)
+func (f *Formatter) lookup(code, language string, opts *FormatOptions) (*LanguageBackend, error) {
+	// Validate inputs
+	if code == "" {
+		return nil, fmt.Errorf("code cannot be empty")
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid options: %v", err)
 	}
 
-	return string(formatted), nil
+	backend, ok := f.registry.Lookup(language)
+	if !ok {
+		return nil, fmt.Errorf("unsupported language: %s", language)
+	}
+	return backend, nil
 }
 
-func minifyJSONCode(code string) (string, error) {
-	var obj interface{}
-	if err := json.Unmarshal([]byte(code), &obj); err != nil {
-		return "", fmt.Errorf("invalid JSON syntax: %v", err)
+func formatJSONCode(code string, opts *FormatOptions) (string, error) {
+	formatted, err := reprintJSON(code, opts.indent(2), true)
+	if err != nil {
+		return "", fmt.Errorf("invalid JSON syntax: %w", err)
 	}
+	// End with a newline like every other formatter
+	return formatted + "\n", nil
+}
 
-	minified, err := json.Marshal(obj)
+func minifyJSONCode(code string, _ *FormatOptions) (string, error) {
+	minified, err := reprintJSON(code, "", false)
 	if err != nil {
-		return "", fmt.Errorf("failed to minify JSON: %v", err)
+		return "", fmt.Errorf("invalid JSON syntax: %w", err)
 	}
-
-	return string(minified), nil
+	return minified, nil
 }
 
-func formatPHPCode(code string) (string, error) {
-	// Basic PHP formatting - add proper indentation
-	lines := strings.Split(code, "\n")
-	var formatted []string
-	indent := 0
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" {
-			formatted = append(formatted, "")
-			continue
-		}
-
-		// Decrease indent for closing braces
-		if strings.HasPrefix(trimmed, "}") {
-			indent--
-		}
-
-		// Add indentation
-		indentedLine := strings.Repeat("    ", indent) + trimmed
-		formatted = append(formatted, indentedLine)
-
-		// Increase indent for opening braces
-		if strings.HasSuffix(trimmed, "{") {
-			indent++
-		}
+func formatPHPCode(code string, opts *FormatOptions) (string, error) {
+	formatted, err := formatPHP(code, opts)
+	if err != nil {
+		return "", fmt.Errorf("invalid PHP syntax: %w", err)
 	}
-
-	return strings.Join(formatted, "\n"), nil
+	return formatted, nil
 }
 
-func minifyPHPCode(code string) (string, error) {
-	// Basic PHP minification - remove extra whitespace and comments
-	// Remove single-line comments
-	re1 := regexp.MustCompile(`//.*$`)
-	code = re1.ReplaceAllString(code, "")
-
-	// Remove multi-line comments
-	re2 := regexp.MustCompile(`/\*[\s\S]*?\*/`)
-	code = re2.ReplaceAllString(code, "")
-
-	// Remove extra whitespace
-	lines := strings.Split(code, "\n")
-	var minified []string
+// JavaScript and TypeScript default to 80 columns and two-space indentation
+const (
+	jsPrintWidth = 80
+	jsIndentSize = 2
+)
 
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed != "" {
-			minified = append(minified, trimmed)
-		}
+func formatJavaScriptCode(code string, opts *FormatOptions) (string, error) {
+	// JSX is accepted in plain JavaScript since it cannot clash with any
+	// valid non-JSX program
+	formatted, err := formatJS(code, jsDialect{jsx: true}, opts)
+	if err != nil {
+		return "", fmt.Errorf("invalid JavaScript syntax: %w", err)
 	}
-
-	return strings.Join(minified, " "), nil
+	return formatted, nil
 }
 
-func formatJavaScriptCode(code string) (string, error) {
-	// Basic JavaScript formatting
-	var buffer bytes.Buffer
-	indent := 0
-	inString := false
-	stringChar := byte(0)
-
-	for i := 0; i < len(code); i++ {
-		char := code[i]
-
-		// Handle string literals
-		if !inString && (char == '"' || char == '\'' || char == '`') {
-			inString = true
-			stringChar = char
-			buffer.WriteByte(char)
-			continue
-		} else if inString && char == stringChar {
-			inString = false
-			buffer.WriteByte(char)
-			continue
-		} else if inString {
-			buffer.WriteByte(char)
-			continue
-		}
-
-		switch char {
-		case '{':
-			buffer.WriteByte(char)
-			buffer.WriteByte('\n')
-			indent++
-			buffer.WriteString(strings.Repeat("  ", indent))
-		case '}':
-			buffer.WriteByte('\n')
-			indent--
-			buffer.WriteString(strings.Repeat("  ", indent))
-			buffer.WriteByte(char)
-		case ';':
-			buffer.WriteByte(char)
-			if i+1 < len(code) && code[i+1] != '}' {
-				buffer.WriteByte('\n')
-				buffer.WriteString(strings.Repeat("  ", indent))
-			}
-		case ' ', '\t', '\n', '\r':
-			if buffer.Len() > 0 && buffer.Bytes()[buffer.Len()-1] != ' ' {
-				buffer.WriteByte(' ')
-			}
-		default:
-			buffer.WriteByte(char)
-		}
+func formatTypeScriptCode(code string, opts *FormatOptions) (string, error) {
+	formatted, err := formatJS(code, jsDialect{typescript: true}, opts)
+	if err != nil {
+		return "", fmt.Errorf("invalid TypeScript syntax: %w", err)
 	}
-
-	return strings.TrimSpace(buffer.String()), nil
+	return formatted, nil
 }
 
-func minifyJavaScriptCode(code string) (string, error) {
-	// Basic JavaScript minification
-	var buffer bytes.Buffer
-	inString := false
-	stringChar := byte(0)
-
-	for i := 0; i < len(code); i++ {
-		char := code[i]
-
-		// Handle string literals
-		if !inString && (char == '"' || char == '\'' || char == '`') {
-			inString = true
-			stringChar = char
-			buffer.WriteByte(char)
-			continue
-		} else if inString && char == stringChar {
-			inString = false
-			buffer.WriteByte(char)
-			continue
-		} else if inString {
-			buffer.WriteByte(char)
-			continue
-		}
-
-		// Skip whitespace outside strings
-		if char == ' ' || char == '\t' || char == '\n' || char == '\r' {
-			// Only add space if necessary for syntax
-			if buffer.Len() > 0 {
-				lastChar := buffer.Bytes()[buffer.Len()-1]
-				if isAlphaNumeric(lastChar) && i+1 < len(code) && isAlphaNumeric(code[i+1]) {
-					buffer.WriteByte(' ')
-				}
-			}
-			continue
-		}
-
-		buffer.WriteByte(char)
+func formatTSXCode(code string, opts *FormatOptions) (string, error) {
+	formatted, err := formatJS(code, jsDialect{typescript: true, jsx: true}, opts)
+	if err != nil {
+		return "", fmt.Errorf("invalid TSX syntax: %w", err)
 	}
-
-	return buffer.String(), nil
+	return formatted, nil
 }
 
 func isAlphaNumeric(char byte) bool {
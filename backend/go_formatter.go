@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// Prefixes that make Go fragments parse as a file, as go/format adds them
+const (
+	goDeclPrefix = "package p;"
+	goStmtPrefix = "package p; func _() {"
+)
+
+// goFragmentPrefixLen mirrors how go/format parses source without a package
+// clause and returns the length of the prefix it adds, or 0 for a file
+func goFragmentPrefixLen(src string) int {
+	fset := token.NewFileSet()
+	_, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err == nil || !strings.Contains(err.Error(), "expected 'package'") {
+		return 0
+	}
+	_, err = parser.ParseFile(fset, "", goDeclPrefix+src, parser.ParseComments)
+	if err == nil || !strings.Contains(err.Error(), "expected declaration") {
+		return len(goDeclPrefix)
+	}
+	return len(goStmtPrefix)
+}
+
+// goFile is Go source parsed as a file. Fragments are wrapped the way
+// go/format wraps them: declarations in a package clause, statements also
+// in a function.
+type goFile struct {
+	fset *token.FileSet
+	file *ast.File
+	src  string
+	// prefix and suffix are the lengths of the text wrapped around a
+	// fragment
+	prefix, suffix int
+	statements     bool
+}
+
+func parseGoFile(code string) (*goFile, error) {
+	g := &goFile{fset: token.NewFileSet(), src: code}
+	switch goFragmentPrefixLen(code) {
+	case len(goDeclPrefix):
+		g.src, g.prefix = goDeclPrefix+code, len(goDeclPrefix)
+	case len(goStmtPrefix):
+		g.src, g.prefix, g.suffix = goStmtPrefix+code+"\n}", len(goStmtPrefix), 2
+		g.statements = true
+	}
+	file, err := parser.ParseFile(g.fset, "", g.src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Go syntax: %w", unwrapGoFragmentErrors(err, code))
+	}
+	g.file = file
+	return g, nil
+}
+
+// offset returns the offset of a position in the wrapped source
+func (g *goFile) offset(pos token.Pos) int {
+	return g.fset.Position(pos).Offset
+}
+
+// text returns the source of a node
+func (g *goFile) text(n ast.Node) string {
+	return g.src[g.offset(n.Pos()):g.offset(n.End())]
+}
+
+// line returns the line a position is on
+func (g *goFile) line(pos token.Pos) int {
+	return g.fset.Position(pos).Line
+}
+
+// goEdit replaces the source between two offsets
+type goEdit struct {
+	start, end int
+	text       string
+}
+
+// apply makes edits, which must not overlap, and returns the code without
+// the text wrapped around a fragment
+func (g *goFile) apply(edits []goEdit) string {
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var out []byte
+	last := 0
+	for _, e := range edits {
+		if e.start < last {
+			continue
+		}
+		out = append(out, g.src[last:e.start]...)
+		out = append(out, e.text...)
+		last = e.end
+	}
+	out = append(out, g.src[last:]...)
+	return string(out[g.prefix : len(out)-g.suffix])
+}
+
+// formatGoCode formats Go source like gofmt. The organizeImports option
+// first sorts, groups and fixes the imports like goimports, modernize
+// rewrites outdated constructs, including uses of io/ioutil the imports
+// were just fixed for, and strict applies the stricter rules of gofumpt
+// to the result.
+func formatGoCode(code string, opts *FormatOptions) (string, error) {
+	var err error
+	if opts.organizeImports() {
+		if code, err = organizeGoImports(code); err != nil {
+			return "", err
+		}
+	}
+	if opts.modernize() {
+		modernized, err := modernizeGo(code)
+		if err != nil {
+			return "", err
+		}
+		// The io and os imports replacing io/ioutil are grouped in turn
+		if opts.organizeImports() && modernized != code {
+			if modernized, err = organizeGoImports(modernized); err != nil {
+				return "", err
+			}
+		}
+		code = modernized
+	}
+	formatted, err := format.Source([]byte(code))
+	if err != nil {
+		return "", fmt.Errorf("invalid Go syntax: %w", unwrapGoFragmentErrors(err, code))
+	}
+	if !opts.strict() {
+		return string(formatted), nil
+	}
+	code, err = strictGo(string(formatted))
+	if err != nil {
+		return "", err
+	}
+	if formatted, err = format.Source([]byte(code)); err != nil {
+		return "", fmt.Errorf("invalid Go syntax: %w", unwrapGoFragmentErrors(err, code))
+	}
+	return string(formatted), nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import "testing"
+
+func TestFormatGoOptions(t *testing.T) {
+	yes := true
+	imports := &FormatOptions{OrganizeImports: &yes}
+	strict := &FormatOptions{Strict: &yes}
+	modernize := &FormatOptions{Modernize: &yes}
+	tests := []struct {
+		name string
+		code string
+		opts *FormatOptions
+		want string
+	}{
+		{
+			"imports grouped, pruned and added",
+			"package main\n\nimport (\n\t\"os\"\n\t\"github.com/x/y\"\n\t\"fmt\"\n\t\"strings\"\n)\n\nfunc main() {\n\tfmt.Println(y.Z, strings.ToUpper(\"a\"))\n\t_ = json.Marshal\n}\n",
+			imports,
+			"package main\n\nimport (\n\t\"encoding/json\"\n\t\"fmt\"\n\t\"strings\"\n\n\t\"github.com/x/y\"\n)\n\nfunc main() {\n\tfmt.Println(y.Z, strings.ToUpper(\"a\"))\n\t_ = json.Marshal\n}\n",
+		},
+		{
+			"package picked by its symbols",
+			"package p\n\nimport \"crypto/rand\"\n\nfunc f() { rand.Read(nil); _ = template.HTML(\"\") }\n",
+			imports,
+			"package p\n\nimport (\n\t\"crypto/rand\"\n\t\"html/template\"\n)\n\nfunc f() { rand.Read(nil); _ = template.HTML(\"\") }\n",
+		},
+		{
+			"blank and dot imports kept",
+			"package p\n\nimport _ \"embed\"\nimport . \"fmt\"\n\nfunc f() { Println() }\n",
+			imports,
+			"package p\n\nimport (\n\t_ \"embed\"\n\t. \"fmt\"\n)\n\nfunc f() { Println() }\n",
+		},
+		{
+			"strict",
+			"package p\n\nfunc f() error {\n\n\tvar x = 1\n\terr := g(x)\n\n\tif err != nil {\n\t\treturn err\n\t}\n\tm := map[string][]int{\"a\": []int{1}}\n\t_ = m\n\t_ = 0755\n\treturn nil\n\n}\n\n//comment\nfunc g(int) error { return nil }\n",
+			strict,
+			"package p\n\nfunc f() error {\n\tx := 1\n\terr := g(x)\n\tif err != nil {\n\t\treturn err\n\t}\n\tm := map[string][]int{\"a\": {1}}\n\t_ = m\n\t_ = 0o755\n\treturn nil\n}\n\n// comment\nfunc g(int) error { return nil }\n",
+		},
+		{"strict fragment", "x := 1\n\n\n_ = x", strict, "x := 1\n\n_ = x"},
+		{
+			"modernize",
+			"package p\n\nimport (\n\t\"io/ioutil\"\n)\n\nfunc f(v interface{}) ([]byte, error) {\n\t_ = ioutil.Discard\n\treturn ioutil.ReadFile(\"x\")\n}\n",
+			modernize,
+			"package p\n\nimport (\n\t\"io\"\n\t\"os\"\n)\n\nfunc f(v any) ([]byte, error) {\n\t_ = io.Discard\n\treturn os.ReadFile(\"x\")\n}\n",
+		},
+		{
+			"ioutil.ReadDir kept",
+			"package p\n\nimport \"io/ioutil\"\n\nfunc f() { ioutil.ReadDir(\".\") }\n",
+			modernize,
+			"package p\n\nimport \"io/ioutil\"\n\nfunc f() { ioutil.ReadDir(\".\") }\n",
+		},
+		{"any declared", "package p\n\ntype any int\n\nvar v interface{}\n", modernize, "package p\n\ntype any int\n\nvar v interface{}\n"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.FormatWithOptions(tt.code, "go", tt.opts)
+			if err != nil {
+				t.Fatalf("FormatWithOptions(%q) error: %v", tt.code, err)
+			}
+			if got.Code != tt.want {
+				t.Errorf("FormatWithOptions(%q) = %q, want %q", tt.code, got.Code, tt.want)
+			}
+			if len(got.IgnoredOptions) > 0 {
+				t.Errorf("FormatWithOptions(%q) ignored %q", tt.code, got.IgnoredOptions)
+			}
+			if again, err := f.FormatWithOptions(got.Code, "go", tt.opts); err != nil || again.Code != got.Code {
+				t.Errorf("FormatWithOptions(%q) not idempotent: %v", got.Code, err)
+			}
+		})
+	}
+}
+
+func TestFormatGoModernizeAndOrganizeImports(t *testing.T) {
+	yes := true
+	opts := &FormatOptions{OrganizeImports: &yes, Modernize: &yes}
+	tests := []struct {
+		code string
+		want string
+	}{
+		{
+			"package main; import \"os\"; func main(){ data,_ := ioutil.ReadAll(os.Stdin); _ = data }",
+			"package main\n\nimport (\n\t\"io\"\n\t\"os\"\n)\n\nfunc main() { data, _ := io.ReadAll(os.Stdin); _ = data }\n",
+		},
+		{
+			"package main\nimport (\n\"github.com/a/b\"\n\"io/ioutil\"\n)\nfunc main(){ ioutil.WriteFile(\"x\", nil, 0); b.X() }",
+			"package main\n\nimport (\n\t\"os\"\n\n\t\"github.com/a/b\"\n)\n\nfunc main() { os.WriteFile(\"x\", nil, 0); b.X() }\n",
+		},
+		{
+			"package main\nfunc main(){ var v interface{} = ioutil.Discard; _ = v }",
+			"package main\n\nimport \"io\"\n\nfunc main() { var v any = io.Discard; _ = v }\n",
+		},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		got, err := f.FormatWithOptions(tt.code, "go", opts)
+		if err != nil {
+			t.Fatalf("FormatWithOptions(%q) error: %v", tt.code, err)
+		}
+		if got.Code != tt.want {
+			t.Errorf("FormatWithOptions(%q) = %q, want %q", tt.code, got.Code, tt.want)
+		}
+		again, err := f.FormatWithOptions(got.Code, "go", opts)
+		if err != nil || again.Code != got.Code {
+			t.Errorf("FormatWithOptions(%q) = %q, %v; not idempotent", got.Code, again.Code, err)
+		}
+	}
+}
+
+func TestFormatGoErrors(t *testing.T) {
+	yes := true
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"package p\nfunc f() {\n\tx := \n}", "invalid Go syntax: 4:1: expected operand, found '}'"},
+		{"func f() {", "invalid Go syntax: 1:11: expected '}', found 'EOF'"},
+		{"package p\nimport \"fmt\nfunc f(){}", "invalid Go syntax: 2:8: string literal not terminated"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		for _, opts := range []*FormatOptions{nil, {OrganizeImports: &yes, Strict: &yes, Modernize: &yes}} {
+			_, err := f.FormatWithOptions(tt.code, "go", opts)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("FormatWithOptions(%q, %+v) error = %v, want %q", tt.code, opts, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestGoImportName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"fmt", "fmt"},
+		{"github.com/x/go-yaml/v3", "yaml"},
+		{"gopkg.in/yaml.v2", "yaml"},
+		{"example.com/my-pkg", "my"},
+	}
+
+	for _, tt := range tests {
+		if got := goImportName(tt.path); got != tt.want {
+			t.Errorf("goImportName(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// goStdlib maps the names of standard library packages to their import
+// paths. Where packages share a name, it holds the most used one and
+// goStdlibPath picks the others by the symbols used from them.
+var goStdlib = map[string]string{
+	"adler32":         "hash/adler32",
+	"aes":             "crypto/aes",
+	"ascii85":         "encoding/ascii85",
+	"asn1":            "encoding/asn1",
+	"ast":             "go/ast",
+	"atomic":          "sync/atomic",
+	"base32":          "encoding/base32",
+	"base64":          "encoding/base64",
+	"big":             "math/big",
+	"binary":          "encoding/binary",
+	"bits":            "math/bits",
+	"bufio":           "bufio",
+	"build":           "go/build",
+	"buildinfo":       "debug/buildinfo",
+	"bytes":           "bytes",
+	"bzip2":           "compress/bzip2",
+	"cgi":             "net/http/cgi",
+	"cipher":          "crypto/cipher",
+	"cmp":             "cmp",
+	"cmplx":           "math/cmplx",
+	"color":           "image/color",
+	"comment":         "go/doc/comment",
+	"constant":        "go/constant",
+	"constraint":      "go/build/constraint",
+	"context":         "context",
+	"cookiejar":       "net/http/cookiejar",
+	"coverage":        "runtime/coverage",
+	"crc32":           "hash/crc32",
+	"crc64":           "hash/crc64",
+	"crypto":          "crypto",
+	"csv":             "encoding/csv",
+	"debug":           "runtime/debug",
+	"des":             "crypto/des",
+	"doc":             "go/doc",
+	"draw":            "image/draw",
+	"driver":          "database/sql/driver",
+	"dsa":             "crypto/dsa",
+	"dwarf":           "debug/dwarf",
+	"ecdh":            "crypto/ecdh",
+	"ecdsa":           "crypto/ecdsa",
+	"ed25519":         "crypto/ed25519",
+	"elf":             "debug/elf",
+	"elliptic":        "crypto/elliptic",
+	"embed":           "embed",
+	"encoding":        "encoding",
+	"errors":          "errors",
+	"exec":            "os/exec",
+	"expvar":          "expvar",
+	"fcgi":            "net/http/fcgi",
+	"filepath":        "path/filepath",
+	"fips140":         "crypto/fips140",
+	"flag":            "flag",
+	"flate":           "compress/flate",
+	"fmt":             "fmt",
+	"fnv":             "hash/fnv",
+	"format":          "go/format",
+	"fs":              "io/fs",
+	"fstest":          "testing/fstest",
+	"gif":             "image/gif",
+	"gob":             "encoding/gob",
+	"gosym":           "debug/gosym",
+	"gzip":            "compress/gzip",
+	"hash":            "hash",
+	"heap":            "container/heap",
+	"hex":             "encoding/hex",
+	"hkdf":            "crypto/hkdf",
+	"hmac":            "crypto/hmac",
+	"html":            "html",
+	"http":            "net/http",
+	"httptest":        "net/http/httptest",
+	"httptrace":       "net/http/httptrace",
+	"httputil":        "net/http/httputil",
+	"image":           "image",
+	"importer":        "go/importer",
+	"io":              "io",
+	"iotest":          "testing/iotest",
+	"ioutil":          "io/ioutil",
+	"iter":            "iter",
+	"jpeg":            "image/jpeg",
+	"json":            "encoding/json",
+	"jsonrpc":         "net/rpc/jsonrpc",
+	"list":            "container/list",
+	"log":             "log",
+	"lzw":             "compress/lzw",
+	"macho":           "debug/macho",
+	"mail":            "net/mail",
+	"maphash":         "hash/maphash",
+	"maps":            "maps",
+	"math":            "math",
+	"md5":             "crypto/md5",
+	"metrics":         "runtime/metrics",
+	"mime":            "mime",
+	"mlkem":           "crypto/mlkem",
+	"multipart":       "mime/multipart",
+	"net":             "net",
+	"netip":           "net/netip",
+	"os":              "os",
+	"palette":         "image/color/palette",
+	"parse":           "text/template/parse",
+	"parser":          "go/parser",
+	"path":            "path",
+	"pbkdf2":          "crypto/pbkdf2",
+	"pe":              "debug/pe",
+	"pem":             "encoding/pem",
+	"pkix":            "crypto/x509/pkix",
+	"plan9obj":        "debug/plan9obj",
+	"plugin":          "plugin",
+	"png":             "image/png",
+	"pprof":           "runtime/pprof",
+	"printer":         "go/printer",
+	"quick":           "testing/quick",
+	"quotedprintable": "mime/quotedprintable",
+	"rand":            "math/rand",
+	"rc4":             "crypto/rc4",
+	"reflect":         "reflect",
+	"regexp":          "regexp",
+	"ring":            "container/ring",
+	"rpc":             "net/rpc",
+	"rsa":             "crypto/rsa",
+	"runtime":         "runtime",
+	"scanner":         "text/scanner",
+	"sha1":            "crypto/sha1",
+	"sha256":          "crypto/sha256",
+	"sha3":            "crypto/sha3",
+	"sha512":          "crypto/sha512",
+	"signal":          "os/signal",
+	"slices":          "slices",
+	"slog":            "log/slog",
+	"slogtest":        "testing/slogtest",
+	"smtp":            "net/smtp",
+	"sort":            "sort",
+	"sql":             "database/sql",
+	"strconv":         "strconv",
+	"strings":         "strings",
+	"structs":         "structs",
+	"subtle":          "crypto/subtle",
+	"suffixarray":     "index/suffixarray",
+	"sync":            "sync",
+	"synctest":        "testing/synctest",
+	"syntax":          "regexp/syntax",
+	"syscall":         "syscall",
+	"syslog":          "log/syslog",
+	"tabwriter":       "text/tabwriter",
+	"tar":             "archive/tar",
+	"template":        "text/template",
+	"testing":         "testing",
+	"textproto":       "net/textproto",
+	"time":            "time",
+	"tls":             "crypto/tls",
+	"token":           "go/token",
+	"trace":           "runtime/trace",
+	"types":           "go/types",
+	"tzdata":          "time/tzdata",
+	"unicode":         "unicode",
+	"unique":          "unique",
+	"unsafe":          "unsafe",
+	"url":             "net/url",
+	"user":            "os/user",
+	"utf16":           "unicode/utf16",
+	"utf8":            "unicode/utf8",
+	"version":         "go/version",
+	"weak":            "weak",
+	"x509":            "crypto/x509",
+	"xml":             "encoding/xml",
+	"zip":             "archive/zip",
+	"zlib":            "compress/zlib",
+}
+
+// goStdlibPath returns the standard library package a qualifier names,
+// judging by the symbols selected from it, or "" for none
+func goStdlibPath(name string, symbols map[string]bool) string {
+	uses := func(names ...string) bool {
+		for _, n := range names {
+			if symbols[n] {
+				return true
+			}
+		}
+		return false
+	}
+	switch name {
+	case "rand":
+		if uses("Prime", "Reader", "Text") {
+			return "crypto/rand"
+		}
+	case "template":
+		if uses("HTML", "HTMLAttr", "JS", "JSStr", "CSS", "URL", "Srcset", "ErrorCode") {
+			return "html/template"
+		}
+	case "scanner":
+		if uses("ErrorList", "Error", "ErrorHandler", "PrintError", "Mode", "ScanComments") {
+			return "go/scanner"
+		}
+	case "pprof":
+		if uses("Index", "Cmdline", "Symbol", "Handler") {
+			return "net/http/pprof"
+		}
+	}
+	return goStdlib[name]
+}
+
+// isGoStdlib reports whether an import path belongs to the standard
+// library, whose paths have no dot in their first element
+func isGoStdlib(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// goImportName returns the name a package is assumed to have from its
+// import path, as goimports assumes it: the last element, skipping a
+// major version suffix and a go- prefix, up to the first character not
+// allowed in identifiers
+func goImportName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && strings.HasPrefix(name, "v") {
+		if _, err := strconv.Atoi(name[1:]); err == nil {
+			name = elems[len(elems)-2]
+		}
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// goImport is an import spec with the comments that go along with it
+type goImport struct {
+	name, path string
+	pos        token.Pos
+	// docs are the comment lines before the spec, and comment the one
+	// after it on its line
+	docs    []string
+	comment string
+}
+
+func (imp *goImport) spec() string {
+	spec := strconv.Quote(imp.path)
+	if imp.name != "" {
+		spec = imp.name + " " + spec
+	}
+	if imp.comment != "" {
+		spec += " " + imp.comment
+	}
+	return spec
+}
+
+// provides returns the name an import makes available, or "" for blank
+// and dot imports
+func (imp *goImport) provides() string {
+	switch imp.name {
+	case "_", ".":
+		return ""
+	case "":
+		return goImportName(imp.path)
+	}
+	return imp.name
+}
+
+// goQualifiers returns the names a file uses to qualify identifiers
+// without declaring them, with the identifiers selected from each
+func goQualifiers(file *ast.File) map[string]map[string]bool {
+	used := map[string]map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+			if used[id.Name] == nil {
+				used[id.Name] = map[string]bool{}
+			}
+			used[id.Name][sel.Sel.Name] = true
+		}
+		return true
+	})
+	return used
+}
+
+// organizeGoImports rewrites the imports of Go source like goimports:
+// unused imports are removed, missing standard library imports are
+// added, and the imports are sorted into a standard library group and a
+// group for all others. Imports of "C" are left as they are, ahead of
+// the rest. Since the packages themselves are not at hand, an import of
+// another module whose name cannot be guessed from its path is kept
+// while any qualifier remains unexplained.
+func organizeGoImports(code string) (string, error) {
+	g, err := parseGoFile(code)
+	if err != nil {
+		return "", err
+	}
+	if g.statements {
+		// Statements have nowhere to put imports
+		return code, nil
+	}
+	file := g.file
+
+	var decls []*ast.GenDecl
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			decls = append(decls, gen)
+		}
+	}
+
+	// The comments of the replaced declarations go with the specs they
+	// precede, or above the new declaration when they precede them all
+	var imports []*goImport
+	var cgo, header, trailing []string
+	start, end := token.NoPos, token.NoPos
+	var cgoSpans [][2]token.Pos
+	for _, decl := range decls {
+		declStart := decl.Pos()
+		if decl.Doc != nil {
+			declStart = decl.Doc.Pos()
+		}
+		if !start.IsValid() {
+			start = declStart
+		}
+		end = decl.End()
+		if goImportsC(decl) {
+			cgo = append(cgo, g.src[g.offset(declStart):g.offset(decl.End())])
+			cgoSpans = append(cgoSpans, [2]token.Pos{declStart, decl.End()})
+			continue
+		}
+		for _, s := range decl.Specs {
+			spec := s.(*ast.ImportSpec)
+			path, _ := strconv.Unquote(spec.Path.Value)
+			imp := &goImport{path: path, pos: spec.Pos()}
+			if spec.Name != nil {
+				imp.name = spec.Name.Name
+			}
+			if spec.Comment != nil {
+				imp.comment = g.text(spec.Comment)
+			}
+			imports = append(imports, imp)
+		}
+	}
+	if len(decls) > 0 {
+		firstSpec := token.NoPos
+		if len(imports) > 0 {
+			firstSpec = imports[0].pos
+		}
+	comments:
+		for _, group := range file.Comments {
+			if group.Pos() < start || group.End() > end {
+				continue
+			}
+			for _, span := range cgoSpans {
+				if group.Pos() >= span[0] && group.End() <= span[1] {
+					continue comments
+				}
+			}
+			for _, imp := range imports {
+				if imp.comment != "" && g.line(group.Pos()) == g.line(imp.pos) {
+					continue comments
+				}
+			}
+			lines := strings.Split(g.text(group), "\n")
+			if !firstSpec.IsValid() || group.End() < firstSpec && goIsDeclComment(decls, group) {
+				header = append(header, lines...)
+				continue
+			}
+			attached := false
+			for _, imp := range imports {
+				if imp.pos > group.End() {
+					imp.docs = append(imp.docs, lines...)
+					attached = true
+					break
+				}
+			}
+			if !attached {
+				trailing = append(trailing, lines...)
+			}
+		}
+	}
+
+	used := goQualifiers(file)
+	provided := map[string]bool{}
+	for _, imp := range imports {
+		if name := imp.provides(); used[name] != nil {
+			provided[name] = true
+		}
+	}
+	var added []*goImport
+	unexplained := false
+	for name, symbols := range used {
+		if provided[name] || name == "C" {
+			continue
+		}
+		if path := goStdlibPath(name, symbols); path != "" {
+			added = append(added, &goImport{path: path})
+		} else {
+			unexplained = true
+		}
+	}
+
+	var kept []*goImport
+	seen := map[string]bool{}
+	for _, imp := range append(imports, added...) {
+		key := imp.name + " " + imp.path
+		if seen[key] {
+			continue
+		}
+		switch name := imp.provides(); {
+		case name == "", used[name] != nil:
+		case imp.name == "" && !isGoStdlib(imp.path) && unexplained:
+			// The package may not be named after its path
+		default:
+			continue
+		}
+		seen[key] = true
+		kept = append(kept, imp)
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		if a, b := isGoStdlib(kept[i].path), isGoStdlib(kept[j].path); a != b {
+			return a
+		}
+		return kept[i].path < kept[j].path
+	})
+
+	var b strings.Builder
+	for _, decl := range cgo {
+		b.WriteString(decl)
+		b.WriteString("\n\n")
+	}
+	for _, line := range header {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	switch {
+	case len(kept) == 1 && len(kept[0].docs) == 0 && len(trailing) == 0:
+		b.WriteString("import " + kept[0].spec())
+	case len(kept) > 0:
+		b.WriteString("import (\n")
+		for i, imp := range kept {
+			if i > 0 && isGoStdlib(imp.path) != isGoStdlib(kept[i-1].path) {
+				b.WriteString("\n")
+			}
+			for _, line := range imp.docs {
+				b.WriteString("\t" + line + "\n")
+			}
+			b.WriteString("\t" + imp.spec() + "\n")
+		}
+		for _, line := range trailing {
+			b.WriteString("\t" + line + "\n")
+		}
+		b.WriteString(")")
+	}
+
+	if len(decls) == 0 {
+		if len(added) == 0 {
+			return code, nil
+		}
+		if g.prefix > 0 {
+			// A fragment starts with its declarations
+			return g.apply([]goEdit{{start: g.prefix, end: g.prefix, text: b.String() + "\n\n"}}), nil
+		}
+		at := g.offset(file.Name.End())
+		return g.apply([]goEdit{{start: at, end: at, text: "\n\n" + b.String() + "\n"}}), nil
+	}
+	return g.apply([]goEdit{{start: g.offset(start), end: g.offset(end), text: strings.TrimRight(b.String(), "\n")}}), nil
+}
+
+// goImportsC reports whether an import declaration imports "C"
+func goImportsC(decl *ast.GenDecl) bool {
+	for _, s := range decl.Specs {
+		if s.(*ast.ImportSpec).Path.Value == `"C"` {
+			return true
+		}
+	}
+	return false
+}
+
+// goIsDeclComment reports whether a comment is the doc comment of one of
+// the import declarations rather than a comment inside one
+func goIsDeclComment(decls []*ast.GenDecl, group *ast.CommentGroup) bool {
+	for _, decl := range decls {
+		if decl.Doc == group {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/scanner"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// goToken is a token of printed Go code. Semicolons the scanner inserts
+// at line ends have the text "\n".
+type goToken struct {
+	tok  token.Token
+	text string
+}
+
+// minifyGoCode compacts Go source. Comments are dropped except build
+// constraints, //go: directives and what cgo reads, blank lines and
+// indentation go, and each line holds one statement, or with the
+// joinStatements option the whole program.
+func minifyGoCode(code string, opts *FormatOptions) (string, error) {
+	g, err := parseGoFile(code)
+	if err != nil {
+		return "", err
+	}
+	skip, trim := 0, 0
+	switch {
+	case g.statements:
+		// package p ; func _ ( ) { and the closing } ;
+		skip, trim = 8, 2
+	case g.prefix > 0:
+		// package p ;
+		skip = 3
+	}
+	g.file.Comments = keptGoComments(g.file)
+
+	var printed bytes.Buffer
+	if err := printer.Fprint(&printed, g.fset, g.file); err != nil {
+		return "", err
+	}
+	tokens := scanGo(printed.Bytes())
+	tokens = tokens[skip : len(tokens)-trim]
+	return writeMinifiedGo(tokens, opts.joinStatements()), nil
+}
+
+// keptGoComments returns the comments minification keeps: build
+// constraints, compiler directives, and in files importing "C" the
+// preamble before the import and //export directives
+func keptGoComments(file *ast.File) []*ast.CommentGroup {
+	preambles := map[*ast.CommentGroup]bool{}
+	cgo := false
+	for _, spec := range file.Imports {
+		if path, _ := strconv.Unquote(spec.Path.Value); path == "C" {
+			cgo = true
+			preambles[spec.Doc] = true
+		}
+	}
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT && !gen.Lparen.IsValid() {
+			if path, _ := strconv.Unquote(gen.Specs[0].(*ast.ImportSpec).Path.Value); path == "C" {
+				preambles[gen.Doc] = true
+			}
+		}
+	}
+
+	// The printer falls back to the comments attached to nodes when the
+	// file has none at all
+	kept := []*ast.CommentGroup{}
+	for _, group := range file.Comments {
+		if preambles[group] {
+			kept = append(kept, group)
+			continue
+		}
+		var list []*ast.Comment
+		for _, c := range group.List {
+			switch {
+			case strings.HasPrefix(c.Text, "//go:"),
+				strings.HasPrefix(c.Text, "// +build") && c.Pos() < file.Package,
+				strings.HasPrefix(c.Text, "//export ") && cgo:
+				list = append(list, c)
+			}
+		}
+		if len(list) > 0 {
+			kept = append(kept, &ast.CommentGroup{List: list})
+		}
+	}
+	return kept
+}
+
+// scanGo splits printed Go code into tokens
+func scanGo(src []byte) []goToken {
+	fset := token.NewFileSet()
+	var s scanner.Scanner
+	s.Init(fset.AddFile("", -1, len(src)), src, nil, scanner.ScanComments)
+	var tokens []goToken
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return tokens
+		}
+		text := lit
+		if lit == "" {
+			text = tok.String()
+		}
+		tokens = append(tokens, goToken{tok: tok, text: text})
+	}
+}
+
+// writeMinifiedGo prints tokens with the least whitespace that keeps them
+// apart. Line-ending semicolons become line breaks, or with join explicit
+// semicolons, and are left out where a closing bracket ends the list.
+func writeMinifiedGo(tokens []goToken, join bool) string {
+	var out strings.Builder
+	prev := ""
+	lineStart := func() {
+		if out.Len() > 0 && prev != "" {
+			out.WriteByte('\n')
+		}
+		prev = ""
+	}
+	for i, t := range tokens {
+		var next token.Token = token.EOF
+		if i+1 < len(tokens) {
+			next = tokens[i+1].tok
+		}
+		switch {
+		case t.tok == token.SEMICOLON && t.text == "\n":
+			if next == token.EOF || next == token.RPAREN || next == token.RBRACE {
+				continue
+			}
+			if join && next != token.COMMENT {
+				out.WriteByte(';')
+				prev = ";"
+			} else {
+				lineStart()
+			}
+		case t.tok == token.COMMENT:
+			// Directives and preambles apply to the line after them
+			lineStart()
+			out.WriteString(t.text)
+			out.WriteByte('\n')
+			if next == token.PACKAGE {
+				// Build constraints must be followed by a blank line
+				out.WriteByte('\n')
+			}
+		default:
+			if prev != "" && goNeedsSpace(prev, t.text) {
+				out.WriteByte(' ')
+			}
+			out.WriteString(t.text)
+			prev = t.text
+		}
+	}
+	return out.String()
+}
+
+// goNeedsSpace reports whether two tokens written back to back would scan
+// differently than they do apart
+func goNeedsSpace(prev, next string) bool {
+	if prev == "import" && next == `"C"` {
+		// cgo only recognizes the import written with a space
+		return true
+	}
+	if strings.ContainsAny(prev[len(prev)-1:], "()[]{},;") || strings.ContainsAny(next[:1], "()[]{},;") {
+		return false
+	}
+	src := []byte(prev + next)
+	fset := token.NewFileSet()
+	var s scanner.Scanner
+	s.Init(fset.AddFile("", -1, len(src)), src, nil, scanner.ScanComments)
+	s.Scan()
+	pos, _, _ := s.Scan()
+	return fset.Position(pos).Offset != len(prev)
+}
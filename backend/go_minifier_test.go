@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestMinifyGo(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       string
+		want       string
+		wantJoined string
+		// formatted is what the minified code formats back to, when that
+		// is not the formatted source because comments were dropped
+		formatted string
+	}{
+		{
+			"comments and blank lines",
+			"package main\n\nimport \"fmt\"\n\n// main prints\nfunc main() {\n\t// say hi\n\tx := 1\n\n\tif x > 0 {\n\t\tfmt.Println(\"hi\", x)\n\t}\n}\n",
+			"package main\nimport\"fmt\"\nfunc main(){x:=1\nif x>0{fmt.Println(\"hi\",x)}}",
+			"package main;import\"fmt\";func main(){x:=1;if x>0{fmt.Println(\"hi\",x)}}",
+			"package main\n\nimport \"fmt\"\n\nfunc main() {\n\tx := 1\n\tif x > 0 {\n\t\tfmt.Println(\"hi\", x)\n\t}\n}\n",
+		},
+		{
+			"directives kept", "//go:build linux\n\npackage p\n\n//go:noinline\nfunc f() int { return - -1 }\n",
+			"//go:build linux\n\npackage p\n//go:noinline\nfunc f()int{return- -1}",
+			"//go:build linux\n\npackage p\n//go:noinline\nfunc f()int{return- -1}", "",
+		},
+		{
+			"cgo preamble", "package p\n\n/*\n#include <stdio.h>\n*/\nimport \"C\"\n\n//export F\nfunc F() {}\n",
+			"package p\n/*\n#include <stdio.h>\n*/\nimport \"C\"\n//export F\nfunc F(){}",
+			"package p\n/*\n#include <stdio.h>\n*/\nimport \"C\"\n//export F\nfunc F(){}", "",
+		},
+		{
+			"raw strings", "package p\nvar s = `raw\n  text`\nvar a, b = 1, 2\n",
+			"package p\nvar s=`raw\n  text`\nvar a,b=1,2", "package p;var s=`raw\n  text`;var a,b=1,2", "",
+		},
+		{"statements", "x := 1\nfmt.Println(x)", "x:=1\nfmt.Println(x)", "x:=1;fmt.Println(x)", ""},
+		{"declarations", "func f() {}\ntype T struct{ A, B int }", "func f(){}\ntype T struct{A,B int}", "func f(){};type T struct{A,B int}", ""},
+	}
+
+	f := NewFormatter()
+	join := true
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted := tt.formatted
+			if formatted == "" {
+				var err error
+				if formatted, err = f.Format(tt.code, "go"); err != nil {
+					t.Fatalf("Format(%q) error: %v", tt.code, err)
+				}
+			}
+			for _, c := range []struct {
+				opts *FormatOptions
+				want string
+			}{{nil, tt.want}, {&FormatOptions{JoinStatements: &join}, tt.wantJoined}} {
+				got, err := f.MinifyWithOptions(tt.code, "go", c.opts)
+				if err != nil {
+					t.Fatalf("MinifyWithOptions(%q) error: %v", tt.code, err)
+				}
+				if got.Code != c.want {
+					t.Errorf("MinifyWithOptions(%q, %+v) = %q, want %q", tt.code, c.opts, got.Code, c.want)
+				}
+				// The minified code is the same program
+				back, err := f.Format(got.Code, "go")
+				if err != nil || back != formatted {
+					t.Errorf("Format(%q) = %q, %v, want %q", got.Code, back, err, formatted)
+				}
+			}
+		})
+	}
+}
+
+func TestMinifyGoRuns(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not installed")
+	}
+	program := "package main\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n\n// words splits text\nfunc words(text string) []string {\n\treturn strings.Fields(text) // on spaces\n}\n\nfunc main() {\n\tfor i, w := range words(\"a b  c\") {\n\t\tfmt.Println(i, w, `raw\n\t\tline`)\n\t}\n\tvar x = - -1\n\tfmt.Println(x)\n}\n"
+
+	f := NewFormatter()
+	join := true
+	want := runGo(t, goTool, program)
+	for _, opts := range []*FormatOptions{nil, {JoinStatements: &join}} {
+		minified, err := f.MinifyWithOptions(program, "go", opts)
+		if err != nil {
+			t.Fatalf("MinifyWithOptions(%q) error: %v", program, err)
+		}
+		if got := runGo(t, goTool, minified.Code); got != want {
+			t.Errorf("%q prints %q, want %q", minified.Code, got, want)
+		}
+	}
+}
+
+func runGo(t *testing.T, goTool, program string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(file, []byte(program), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(goTool, "run", file).CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run %q: %v\n%s", program, err, out)
+	}
+	return string(out)
+}
+
+func TestMinifyGoErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"package p\nfunc f() {", "invalid Go syntax: 2:11: expected '}', found 'EOF'"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		_, err := f.Minify(tt.code, "go")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Minify(%q) error = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// goDirectivePattern matches the text after // of comments that are
+// directives to tools and so must not start with a space
+var goDirectivePattern = regexp.MustCompile(`^(line |extern |export |sys(nb)? |nolint\b|\+build|[a-z0-9-]+:[a-z0-9])`)
+
+// goIoutilMoves maps the io/ioutil functions that have replacements to
+// them. ReadDir is left alone since os.ReadDir returns a different type.
+var goIoutilMoves = map[string]string{
+	"Discard":   "io.Discard",
+	"NopCloser": "io.NopCloser",
+	"ReadAll":   "io.ReadAll",
+	"ReadFile":  "os.ReadFile",
+	"TempDir":   "os.MkdirTemp",
+	"TempFile":  "os.CreateTemp",
+	"WriteFile": "os.WriteFile",
+}
+
+// comments reports whether there are comments between two positions
+func (g *goFile) comments(from, to token.Pos) bool {
+	for _, group := range g.file.Comments {
+		if group.Pos() >= from && group.End() <= to {
+			return true
+		}
+	}
+	return false
+}
+
+// declares reports whether the file declares any of the names
+func (g *goFile) declares(names ...string) bool {
+	found := false
+	ast.Inspect(g.file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Obj != nil {
+			for _, name := range names {
+				if id.Name == name {
+					found = true
+				}
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// modernizeGo rewrites constructs newer Go versions have replaced, as go
+// fix and gopls' modernize do: interface{} becomes any, and the io/ioutil
+// functions become their io and os counterparts
+func modernizeGo(code string) (string, error) {
+	g, err := parseGoFile(code)
+	if err != nil {
+		return "", err
+	}
+	var edits []goEdit
+	if !g.declares("any") {
+		ast.Inspect(g.file, func(n ast.Node) bool {
+			if it, ok := n.(*ast.InterfaceType); ok && len(it.Methods.List) == 0 && !g.comments(it.Pos(), it.End()) {
+				edits = append(edits, goEdit{g.offset(it.Pos()), g.offset(it.End()), "any"})
+			}
+			return true
+		})
+	}
+	edits = append(edits, g.ioutilEdits()...)
+	if len(edits) == 0 {
+		return code, nil
+	}
+	return g.apply(edits), nil
+}
+
+// ioutilEdits moves the uses of io/ioutil to io and os, importing those
+// in place of io/ioutil once nothing is left using it
+func (g *goFile) ioutilEdits() []goEdit {
+	var spec *ast.ImportSpec
+	var decl *ast.GenDecl
+	imported := map[string]bool{}
+	for _, d := range g.file.Decls {
+		gen, ok := d.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		for _, s := range gen.Specs {
+			s := s.(*ast.ImportSpec)
+			path, _ := strconv.Unquote(s.Path.Value)
+			if s.Name != nil {
+				imported[s.Name.Name] = true
+				continue
+			}
+			imported[goImportName(path)] = true
+			if path == "io/ioutil" {
+				spec, decl = s, gen
+			}
+		}
+	}
+	if spec == nil || g.declares("ioutil", "io", "os") {
+		return nil
+	}
+
+	var edits []goEdit
+	kept := false
+	needed := map[string]bool{}
+	ast.Inspect(g.file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); ok && id.Name == "ioutil" {
+			moved, ok := goIoutilMoves[sel.Sel.Name]
+			if !ok {
+				kept = true
+				return false
+			}
+			pkg, _, _ := strings.Cut(moved, ".")
+			needed[pkg] = true
+			edits = append(edits, goEdit{g.offset(sel.Pos()), g.offset(sel.End()), moved})
+			return false
+		}
+		return true
+	})
+	if len(edits) == 0 {
+		return nil
+	}
+
+	var specs []string
+	if kept {
+		specs = append(specs, `"io/ioutil"`)
+	}
+	for _, pkg := range []string{"io", "os"} {
+		if needed[pkg] && !imported[pkg] {
+			specs = append(specs, strconv.Quote(pkg))
+		}
+	}
+	switch {
+	case len(specs) == 0:
+		if decl.Lparen.IsValid() {
+			edits = append(edits, goEdit{g.offset(spec.Pos()), g.offset(spec.End()), ""})
+		} else {
+			edits = append(edits, goEdit{g.offset(decl.Pos()), g.offset(decl.End()), ""})
+		}
+	case decl.Lparen.IsValid():
+		edits = append(edits, goEdit{g.offset(spec.Pos()), g.offset(spec.End()), strings.Join(specs, "\n")})
+	default:
+		edits = append(edits, goEdit{g.offset(decl.Pos()), g.offset(decl.End()), "import (\n" + strings.Join(specs, "\n") + "\n)"})
+	}
+	return edits
+}
+
+// strictGo applies a subset of gofumpt's rules, and the simplifications
+// of gofmt -s, to formatted Go source. The result needs formatting again.
+func strictGo(code string) (string, error) {
+	// Rewrites inside rewritten code take another pass
+	for pass := 0; pass < 3; pass++ {
+		out, err := strictGoPass(code)
+		if err != nil || out == code {
+			return out, err
+		}
+		code = out
+	}
+	return code, nil
+}
+
+func strictGoPass(code string) (string, error) {
+	g, err := parseGoFile(code)
+	if err != nil {
+		return "", err
+	}
+	var edits []goEdit
+	edit := func(from, to token.Pos, text string) {
+		edits = append(edits, goEdit{g.offset(from), g.offset(to), text})
+	}
+
+	for _, group := range g.file.Comments {
+		for _, c := range group.List {
+			rest := strings.TrimPrefix(c.Text, "//")
+			if rest == c.Text || rest == "" || strings.ContainsAny(rest[:1], " \t/") || goDirectivePattern.MatchString(rest) {
+				continue
+			}
+			edit(c.Pos()+2, c.Pos()+2, " ")
+		}
+	}
+
+	// Multiline top-level declarations are set apart by blank lines
+	for i := 1; i < len(g.file.Decls); i++ {
+		prev, decl := g.file.Decls[i-1], g.file.Decls[i]
+		start := decl.Pos()
+		if doc := goDeclDoc(decl); doc != nil {
+			start = doc.Pos()
+		}
+		if g.line(start) == g.line(prev.End())+1 && (g.line(prev.Pos()) != g.line(prev.End()) || g.line(start) != g.line(decl.End())) {
+			edit(prev.End(), prev.End(), "\n")
+		}
+	}
+
+	ast.Inspect(g.file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			edits = append(edits, g.blockEdits(n)...)
+		case *ast.GenDecl:
+			// A lone declaration needs no parentheses
+			if n.Tok != token.IMPORT && n.Lparen.IsValid() && len(n.Specs) == 1 && n.Doc == nil && !g.comments(n.Lparen, n.Rparen) {
+				edit(n.Lparen, n.Rparen+1, g.text(n.Specs[0]))
+			}
+		case *ast.DeclStmt:
+			if text := g.shortVarDecl(n); text != "" {
+				edit(n.Pos(), n.End(), text)
+			}
+		case *ast.StructType:
+			if len(n.Fields.List) == 0 && g.line(n.Pos()) != g.line(n.End()) && !g.comments(n.Pos(), n.End()) {
+				edit(n.Pos(), n.End(), "struct{}")
+			}
+		case *ast.BasicLit:
+			// Octal literals take the 0o prefix
+			if n.Kind == token.INT && len(n.Value) > 1 && n.Value[0] == '0' && n.Value[1] >= '0' && n.Value[1] <= '9' {
+				edit(n.Pos(), n.End(), "0o"+n.Value[1:])
+			}
+		case *ast.SliceExpr:
+			// s[a:len(s)] is s[a:]
+			if x, ok := n.X.(*ast.Ident); ok && !n.Slice3 {
+				if call, ok := n.High.(*ast.CallExpr); ok && len(call.Args) == 1 && goIsBuiltin(call.Fun, "len") {
+					if arg, ok := call.Args[0].(*ast.Ident); ok && arg.Name == x.Name && arg.Obj == x.Obj {
+						edit(n.High.Pos(), n.High.End(), "")
+					}
+				}
+			}
+		case *ast.RangeStmt:
+			switch {
+			case n.Value != nil && goIsBlank(n.Value):
+				edit(n.Key.End(), n.Value.End(), "")
+			case n.Value == nil && n.Key != nil && goIsBlank(n.Key):
+				edit(n.Key.Pos(), n.Range, "")
+			}
+		case *ast.CompositeLit:
+			edits = append(edits, g.elidedTypeEdits(n)...)
+		}
+		return true
+	})
+	if len(edits) == 0 {
+		return code, nil
+	}
+	return g.apply(edits), nil
+}
+
+// blockEdits removes blank lines at the start and end of a block, and
+// between an assignment and the check of the error it returns
+func (g *goFile) blockEdits(block *ast.BlockStmt) []goEdit {
+	var edits []goEdit
+	open, end := g.offset(block.Lbrace)+1, g.offset(block.Rbrace)
+	body := g.src[open:end]
+	first := open + len(body) - len(strings.TrimLeft(body, " \t\n"))
+	last := open + len(strings.TrimRight(body, " \t\n"))
+	if strings.Count(g.src[open:first], "\n") > 1 {
+		edits = append(edits, goEdit{open, first, "\n"})
+	}
+	if last > first && strings.Count(g.src[last:end], "\n") > 1 {
+		edits = append(edits, goEdit{last, end, "\n"})
+	}
+	for i := 1; i < len(block.List); i++ {
+		prev, stmt := block.List[i-1], block.List[i]
+		if goAssignsErr(prev) && goChecksErr(stmt) && g.line(stmt.Pos()) > g.line(prev.End())+1 && !g.comments(prev.End(), stmt.Pos()) {
+			edits = append(edits, goEdit{g.offset(prev.End()), g.offset(stmt.Pos()), "\n"})
+		}
+	}
+	return edits
+}
+
+// shortVarDecl returns a lone untyped local var declaration written as a
+// short variable declaration, or "" if it can't be
+func (g *goFile) shortVarDecl(stmt *ast.DeclStmt) string {
+	decl := stmt.Decl.(*ast.GenDecl)
+	if decl.Tok != token.VAR || decl.Lparen.IsValid() || len(decl.Specs) != 1 || g.comments(decl.Pos(), decl.End()) {
+		return ""
+	}
+	spec := decl.Specs[0].(*ast.ValueSpec)
+	if spec.Type != nil || len(spec.Values) == 0 {
+		return ""
+	}
+	var names []string
+	blank := true
+	for _, name := range spec.Names {
+		names = append(names, name.Name)
+		blank = blank && name.Name == "_"
+	}
+	if blank {
+		return ""
+	}
+	return strings.Join(names, ", ") + " := " + g.src[g.offset(spec.Values[0].Pos()):g.offset(spec.End())]
+}
+
+// elidedTypeEdits removes element types a composite literal of an array,
+// slice or map type repeats, as gofmt -s does
+func (g *goFile) elidedTypeEdits(lit *ast.CompositeLit) []goEdit {
+	var key, elem ast.Expr
+	switch t := lit.Type.(type) {
+	case *ast.ArrayType:
+		elem = t.Elt
+	case *ast.MapType:
+		key, elem = t.Key, t.Value
+	default:
+		return nil
+	}
+	var edits []goEdit
+	elide := func(typ, x ast.Expr) {
+		if typ == nil {
+			return
+		}
+		if inner, ok := x.(*ast.CompositeLit); ok && inner.Type != nil && g.text(inner.Type) == g.text(typ) {
+			edits = append(edits, goEdit{g.offset(inner.Type.Pos()), g.offset(inner.Type.End()), ""})
+		}
+	}
+	for _, x := range lit.Elts {
+		if kv, ok := x.(*ast.KeyValueExpr); ok {
+			elide(key, kv.Key)
+			x = kv.Value
+		}
+		elide(elem, x)
+	}
+	return edits
+}
+
+// goDeclDoc returns the doc comment of a top-level declaration
+func goDeclDoc(decl ast.Decl) *ast.CommentGroup {
+	switch decl := decl.(type) {
+	case *ast.GenDecl:
+		return decl.Doc
+	case *ast.FuncDecl:
+		return decl.Doc
+	}
+	return nil
+}
+
+func goIsBlank(x ast.Expr) bool {
+	id, ok := x.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+// goIsBuiltin reports whether an expression names an undeclared builtin
+func goIsBuiltin(x ast.Expr, name string) bool {
+	id, ok := x.(*ast.Ident)
+	return ok && id.Name == name && id.Obj == nil
+}
+
+// goAssignsErr reports whether a statement assigns to err
+func goAssignsErr(stmt ast.Stmt) bool {
+	assign, ok := stmt.(*ast.AssignStmt)
+	if !ok {
+		return false
+	}
+	for _, lhs := range assign.Lhs {
+		if id, ok := lhs.(*ast.Ident); ok && id.Name == "err" {
+			return true
+		}
+	}
+	return false
+}
+
+// goChecksErr reports whether a statement is if err != nil
+func goChecksErr(stmt ast.Stmt) bool {
+	s, ok := stmt.(*ast.IfStmt)
+	if !ok || s.Init != nil {
+		return false
+	}
+	cond, ok := s.Cond.(*ast.BinaryExpr)
+	if !ok || cond.Op != token.NEQ {
+		return false
+	}
+	x, ok := cond.X.(*ast.Ident)
+	y, ok2 := cond.Y.(*ast.Ident)
+	return ok && ok2 && x.Name == "err" && y.Name == "nil"
+}
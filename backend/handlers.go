@@ -4,18 +4,58 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"regexp"
 	"strings"
 	"time"
 )
 
 // Handlers struct holds configuration and provides HTTP handlers
 type Handlers struct {
-	config *Config
+	config   *Config
+	policy   *Policy
+	secrets  *SecretScanner
+	snippets SnippetStore
 }
 
+// Operations a snippet can be processed with
+const (
+	operationFormat  = "format"
+	operationMinify  = "minify"
+	operationCheck   = "check"
+	operationConvert = "convert"
+	operationTypes   = "types"
+)
+
+// modeCheck reports how the output differs from the input instead of
+// returning the output
+const modeCheck = "check"
+
 // FormatHandler handles code formatting requests
 func (h *Handlers) FormatHandler(w http.ResponseWriter, r *http.Request) {
+	h.handleSnippet(w, r, operationFormat)
+}
+
+// MinifyHandler handles code minification requests
+func (h *Handlers) MinifyHandler(w http.ResponseWriter, r *http.Request) {
+	h.handleSnippet(w, r, operationMinify)
+}
+
+// CheckHandler reports whether code is already formatted
+func (h *Handlers) CheckHandler(w http.ResponseWriter, r *http.Request) {
+	h.handleSnippet(w, r, operationCheck)
+}
+
+// ConvertHandler handles conversions of data between languages
+func (h *Handlers) ConvertHandler(w http.ResponseWriter, r *http.Request) {
+	h.handleSnippet(w, r, operationConvert)
+}
+
+// TypesHandler handles generation of type declarations from sample data
+func (h *Handlers) TypesHandler(w http.ResponseWriter, r *http.Request) {
+	h.handleSnippet(w, r, operationTypes)
+}
+
+// handleSnippet decodes a single-snippet request and applies operation to it
+func (h *Handlers) handleSnippet(w http.ResponseWriter, r *http.Request, operation string) {
 	if r.Method != http.MethodPost {
 		h.respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -32,96 +72,166 @@ func (h *Handlers) FormatHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	response := h.processSnippet(NewFormatter(), &req, operation)
+	statusCode := http.StatusOK
+	if !response.Success {
+		statusCode = http.StatusBadRequest
+	}
+	response.Timestamp = time.Now().Format(time.RFC3339)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+// processSnippet validates a request and formats or minifies its code,
+// reporting the credentials in it. Failures are reported in the returned
+// response rather than as an error.
+func (h *Handlers) processSnippet(formatter *Formatter, req *Request, operation string) Response {
 	// Validate input
 	if strings.TrimSpace(req.Code) == "" {
-		h.respondError(w, "Code field is required", http.StatusBadRequest)
-		return
+		return Response{Error: "Code field is required"}
 	}
 
-	if strings.TrimSpace(req.Language) == "" {
-		h.respondError(w, "Language field is required", http.StatusBadRequest)
-		return
+	secrets := h.secrets.Find(req.Code)
+	if req.RedactSecrets && len(secrets) > 0 {
+		// The redacted code is all that is processed, so no output, diff
+		// or source map can hold the secrets
+		redacted := *req
+		redacted.Code = redactSecrets(req.Code, secrets)
+		req = &redacted
 	}
+	response := h.processCode(formatter, req, operation)
+	response.Secrets = secrets
+	return response
+}
 
-	// Security check for suspicious code patterns
-	if h.containsSuspiciousCode(req.Code) {
-		h.respondError(w, "Code contains suspicious patterns", http.StatusBadRequest)
-		return
+// processCode applies operation to the code of a validated request
+func (h *Handlers) processCode(formatter *Formatter, req *Request, operation string) Response {
+	check := operation == operationCheck
+	switch strings.ToLower(req.Mode) {
+	case "":
+	case modeCheck:
+		check = true
+	default:
+		return Response{Error: fmt.Sprintf("Unsupported mode: %s", req.Mode)}
 	}
 
-	// Format the code
-	formatter := NewFormatter()
-	formattedCode, err := formatter.Format(req.Code, req.Language)
-	if err != nil {
-		h.respondError(w, fmt.Sprintf("Formatting error: %v", err), http.StatusBadRequest)
-		return
+	switch operation {
+	case operationConvert:
+		if check {
+			return Response{Error: "Check mode is not supported for conversions"}
+		}
+		return h.processConversion(formatter, req, formatter.Convert, "Conversion error")
+	case operationTypes:
+		if check {
+			return Response{Error: "Check mode is not supported for type generation"}
+		}
+		return h.processConversion(formatter, req, formatter.GenerateTypes, "Type generation error")
 	}
 
-	// Return successful response
-	response := Response{
-		Success:   true,
-		Code:      formattedCode,
-		Timestamp: time.Now().Format(time.RFC3339),
+	language, detection, err := resolveLanguage(formatter, req)
+	if err != nil {
+		return Response{Error: fmt.Sprintf("Language detection error: %v", err)}
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
-}
-
-// MinifyHandler handles code minification requests
-func (h *Handlers) MinifyHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	matches, rejection := h.checkPolicy(formatter, req.Code, language, req.Options)
+	if rejection != nil {
+		return *rejection
 	}
 
-	// Validate request
-	if !h.validateRequest(w, r) {
-		return
+	var result *Result
+	switch operation {
+	case operationFormat, operationCheck:
+		result, err = formatter.FormatWithOptions(req.Code, language, req.Options)
+		if err != nil {
+			return Response{Error: fmt.Sprintf("Formatting error: %v", err), Diagnostics: DiagnosticsFromError(err, req.Code)}
+		}
+	case operationMinify:
+		result, err = formatter.MinifyWithOptions(req.Code, language, withSourceFile(req.Options, req.Filename))
+		if err != nil {
+			return Response{Error: fmt.Sprintf("Minification error: %v", err), Diagnostics: DiagnosticsFromError(err, req.Code)}
+		}
+	default:
+		return Response{Error: fmt.Sprintf("Unsupported operation: %s", operation)}
 	}
 
-	var req Request
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondError(w, "Invalid JSON payload", http.StatusBadRequest)
-		return
+	response := Response{
+		Success:        true,
+		IgnoredOptions: result.IgnoredOptions,
+		PolicyMatches:  matches,
 	}
-
-	// Validate input
-	if strings.TrimSpace(req.Code) == "" {
-		h.respondError(w, "Code field is required", http.StatusBadRequest)
-		return
+	if detection != nil {
+		response.Language = detection.Language
+		response.Confidence = detection.Confidence
 	}
-
-	if strings.TrimSpace(req.Language) == "" {
-		h.respondError(w, "Language field is required", http.StatusBadRequest)
-		return
+	if !check {
+		response.Code = result.Code
+		response.SourceMap = result.SourceMap
+		return response
 	}
 
-	// Security check for suspicious code patterns
-	if h.containsSuspiciousCode(req.Code) {
-		h.respondError(w, "Code contains suspicious patterns", http.StatusBadRequest)
-		return
+	formatted := result.Code == req.Code
+	response.Formatted = &formatted
+	if !formatted {
+		name := req.Filename
+		if name == "" {
+			name = "snippet"
+		}
+		response.Diff = unifiedDiff("a/"+name, "b/"+name, req.Code, result.Code)
+		response.Changes = changedRanges(req.Code, result.Code)
 	}
+	return response
+}
 
-	// Minify the code
-	formatter := NewFormatter()
-	minifiedCode, err := formatter.Minify(req.Code, req.Language)
+// processConversion converts the code of a request from its From language,
+// or its Language, to its To language with convert, which is either a
+// conversion or type generation
+func (h *Handlers) processConversion(formatter *Formatter, req *Request, convert func(code, from, to string, opts *FormatOptions) (*Conversion, error), failure string) Response {
+	if strings.TrimSpace(req.To) == "" {
+		return Response{Error: "To field is required"}
+	}
+	source := *req
+	if req.From != "" {
+		source.Language = req.From
+	}
+	language, detection, err := resolveLanguage(formatter, &source)
 	if err != nil {
-		h.respondError(w, fmt.Sprintf("Minification error: %v", err), http.StatusBadRequest)
-		return
+		return Response{Error: fmt.Sprintf("Language detection error: %v", err)}
+	}
+	matches, rejection := h.checkPolicy(formatter, req.Code, language, req.Options)
+	if rejection != nil {
+		return *rejection
 	}
 
-	// Return successful response
+	conversion, err := convert(req.Code, language, req.To, req.Options)
+	if err != nil {
+		return Response{Error: fmt.Sprintf("%s: %v", failure, err), Diagnostics: DiagnosticsFromError(err, req.Code)}
+	}
 	response := Response{
-		Success:   true,
-		Code:      minifiedCode,
-		Timestamp: time.Now().Format(time.RFC3339),
+		Success:        true,
+		Code:           conversion.Code,
+		Warnings:       conversion.Warnings,
+		IgnoredOptions: conversion.IgnoredOptions,
+		PolicyMatches:  matches,
+	}
+	if detection != nil {
+		response.Language = detection.Language
+		response.Confidence = detection.Confidence
 	}
+	return response
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+// resolveLanguage returns the language to process the request as, detecting
+// it when the request leaves it empty or asks for "auto"
+func resolveLanguage(formatter *Formatter, req *Request) (string, *Detection, error) {
+	if !IsAutoLanguage(req.Language) {
+		return req.Language, nil, nil
+	}
+	detection, err := formatter.Detect(req.Code, req.Filename)
+	if err != nil {
+		return "", nil, err
+	}
+	return detection.Language, detection, nil
 }
 
 // HealthHandler handles health check requests
@@ -145,6 +255,11 @@ func (h *Handlers) HealthHandler(w http.ResponseWriter, r *http.Request) {
 
 // validateRequest performs common request validation
 func (h *Handlers) validateRequest(w http.ResponseWriter, r *http.Request) bool {
+	return h.validateRequestSize(w, r, h.config.Request.MaxSize)
+}
+
+// validateRequestSize performs common request validation with a body size limit
+func (h *Handlers) validateRequestSize(w http.ResponseWriter, r *http.Request, maxSize int64) bool {
 	// Check Content-Type for POST requests
 	if r.Method == http.MethodPost {
 		contentType := r.Header.Get("Content-Type")
@@ -155,38 +270,30 @@ func (h *Handlers) validateRequest(w http.ResponseWriter, r *http.Request) bool
 	}
 
 	// Check request size
-	if r.ContentLength > h.config.Request.MaxSize {
+	if r.ContentLength > maxSize {
 		h.respondError(w, "Request body too large", http.StatusRequestEntityTooLarge)
 		return false
 	}
 
 	// Limit request body size
-	r.Body = http.MaxBytesReader(w, r.Body, h.config.Request.MaxSize)
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
 
 	return true
 }
 
-// containsSuspiciousCode checks for potentially dangerous code patterns
-func (h *Handlers) containsSuspiciousCode(code string) bool {
-	suspiciousPatterns := []string{
-		`eval\s*\(`,
-		`exec\s*\(`,
-		`system\s*\(`,
-		`os\.system`,
-		`subprocess`,
-		`shell_exec`,
-		`passthru`,
-		`\$\{.*\}`,  // Template injection
-		`<!--.*-->`, // HTML comments that might contain scripts
+// checkPolicy matches the suspicious code rules for language against
+// code. It returns the matches, or a response rejecting the code when a
+// rule blocks it.
+func (h *Handlers) checkPolicy(formatter *Formatter, code, language string, opts *FormatOptions) ([]PolicyMatch, *Response) {
+	backend, _ := formatter.Backend(language)
+	matches, blocked := h.policy.Check(backend, code, opts)
+	if blocked == nil {
+		return matches, nil
 	}
-
-	for _, pattern := range suspiciousPatterns {
-		if matched, _ := regexp.MatchString(pattern, code); matched {
-			return true
-		}
+	return nil, &Response{
+		Error:         fmt.Sprintf("Code blocked by policy rule %s at line %d, column %d: %s", blocked.Rule, blocked.Line, blocked.Column, blocked.Message),
+		PolicyMatches: matches,
 	}
-
-	return false
 }
 
 // respondError sends an error response
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// formatSamples holds unformatted code in every language that can be
+// formatted
+var formatSamples = map[string]string{
+	"CSS":        "a{color:red;margin:0}",
+	"CSV":        "name,age\nada, 36\n",
+	"Go":         "package main\nfunc main(){x:=1;_=x}",
+	"HTML":       "<div><p>hi</p><script>let a=1</script></div>",
+	"JSON":       `{"a":[1,2],"b":{"c":null}}`,
+	"JavaScript": "function f(a){return a+1}",
+	"Less":       "@c:red;a{color:@c;.b{margin:0}}",
+	"PHP":        "<?php\nfunction f($a){return $a+1;}",
+	"SCSS":       "$c:red;a{color:$c;b{margin:0}}",
+	"SQL":        "select a,b from t where a=1",
+	"TOML":       "a=1\n[t]\nb='x'",
+	"TSX":        "const a=<div className='x'>{b}</div>",
+	"TypeScript": "function f(a:number):number{return a+1}",
+	"XML":        "<a><b x='1'>t</b></a>",
+	"YAML":       "a:   1\nb: [1,2]\nc:\n    - x",
+}
+
+func TestCheckModeAcceptsFormattedOutput(t *testing.T) {
+	h := &Handlers{}
+	f := NewFormatter()
+	for _, backend := range f.registry.Languages() {
+		if !backend.CanFormat() {
+			continue
+		}
+		t.Run(backend.Name, func(t *testing.T) {
+			sample, ok := formatSamples[backend.Name]
+			if !ok {
+				t.Fatalf("no format sample for %s", backend.Name)
+			}
+			formatted := h.processSnippet(f, &Request{Code: sample, Language: backend.Name}, operationFormat)
+			if !formatted.Success {
+				t.Fatalf("format %q: %s", sample, formatted.Error)
+			}
+
+			// Formatted files end with a single newline
+			code := strings.TrimRight(formatted.Code, "\n") + "\n"
+			check := h.processSnippet(f, &Request{Code: code, Language: backend.Name}, operationCheck)
+			if !check.Success || check.Formatted == nil {
+				t.Fatalf("check %q: %s", code, check.Error)
+			}
+			if !*check.Formatted {
+				t.Errorf("check reports formatted %q as unformatted:\n%s", code, check.Diff)
+			}
+
+			unformatted := h.processSnippet(f, &Request{Code: sample, Language: backend.Name}, operationCheck)
+			if unformatted.Formatted == nil || *unformatted.Formatted || unformatted.Diff == "" {
+				t.Errorf("check reports %q as formatted", sample)
+			}
+		})
+	}
+}
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// HTML defaults to 80 columns and two-space indentation
+const htmlPrintWidth = 80
+
+// htmlSpace holds the characters HTML treats as whitespace
+const htmlSpace = " \t\n\r\f"
+
+// htmlBlockElements are laid out on lines of their own. Whitespace around
+// them is not rendered, so it can be added or removed freely.
+var htmlBlockElements = setOf(
+	"address", "article", "aside", "base", "blockquote", "body", "caption",
+	"col", "colgroup", "dd", "details", "dialog", "div", "dl", "dt",
+	"fieldset", "figcaption", "figure", "footer", "form", "h1", "h2", "h3",
+	"h4", "h5", "h6", "head", "header", "hgroup", "hr", "html", "legend", "li",
+	"link", "main", "menu", "meta", "nav", "ol", "optgroup", "option", "p",
+	"pre", "section", "summary", "table", "tbody", "td", "template", "tfoot",
+	"th", "thead", "title", "tr", "ul",
+)
+
+// htmlEmbeddedError is an error in the contents of a <script> or <style>
+// element. Its diagnostics are moved to where the contents start in the
+// document.
+type htmlEmbeddedError struct {
+	Tag    string
+	Offset int
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *htmlEmbeddedError) Error() string {
+	return fmt.Sprintf("in <%s> at %d:%d: %v", e.Tag, e.Line, e.Column, e.Err)
+}
+
+func (e *htmlEmbeddedError) Unwrap() error {
+	return e.Err
+}
+
+// Diagnostics implements diagnosticError
+func (e *htmlEmbeddedError) Diagnostics(src string) []Diagnostic {
+	if e.Offset > len(src) {
+		return nil
+	}
+	diagnostics := DiagnosticsFromError(e.Err, src[e.Offset:])
+	for i := range diagnostics {
+		d := &diagnostics[i]
+		if d.Line == 1 {
+			d.Column += e.Column - 1
+		}
+		if d.EndLine == 1 {
+			d.EndColumn += e.Column - 1
+		}
+		d.Line += e.Line - 1
+		d.EndLine += e.Line - 1
+	}
+	return diagnostics
+}
+
+// embedded runs the contents of a <script> or <style> element through the
+// backend for its language and reports whether it did. Contents in a
+// language without such a backend are returned unchanged.
+func embedded(src string, node *htmlNode, formatter *Formatter, opts *FormatOptions, minify bool) (string, bool, error) {
+	language := htmlEmbeddedLanguage(node)
+	backend, ok := formatter.Backend(language)
+	if !ok || (minify && !backend.CanMinify()) || (!minify && !backend.CanFormat()) {
+		return node.text, false, nil
+	}
+	// The document's own output options must not apply to its parts, its
+	// parts get no source maps of their own, and the HTML minifier does not
+	// offer to compress scripts
+	var inner FormatOptions
+	if opts != nil {
+		inner = *opts
+	}
+	inner.TrailingNewline, inner.LineEnding = nil, ""
+	inner.SourceMap, inner.SourceFile = "", ""
+	inner.Compress, inner.Reserved = nil, nil
+
+	var result *Result
+	var err error
+	if minify {
+		result, err = formatter.MinifyWithOptions(node.text, backend.Name, &inner)
+	} else {
+		result, err = formatter.FormatWithOptions(node.text, backend.Name, &inner)
+	}
+	if err != nil {
+		line, column := lineColumn(src, node.inner)
+		return "", false, &htmlEmbeddedError{Tag: node.tag, Offset: node.inner, Line: line, Column: column, Err: err}
+	}
+	return result.Code, true, nil
+}
+
+// htmlEmbeddedLanguage names the language of a <script> or <style>
+// element from its type and lang attributes, or returns "" for contents
+// such as templates that are left alone
+func htmlEmbeddedLanguage(node *htmlNode) string {
+	typ, _ := node.attr("type")
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	lang, _ := node.attr("lang")
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	switch node.name {
+	case "script":
+		switch {
+		case lang == "ts" || lang == "typescript" || typ == "text/typescript" || typ == "application/typescript":
+			return "TypeScript"
+		case lang == "tsx":
+			return "TSX"
+		case typ == "application/json" || typ == "importmap" || strings.HasSuffix(typ, "+json"):
+			return "JSON"
+		case typ == "" || typ == "module" || typ == "text/javascript" || typ == "application/javascript" ||
+			typ == "text/ecmascript" || typ == "application/ecmascript" || typ == "text/babel" || typ == "text/jsx":
+			return "JavaScript"
+		}
+	case "style":
+		if typ != "" && typ != "text/css" {
+			return ""
+		}
+		switch lang {
+		case "", "css":
+			return "CSS"
+		case "scss":
+			return "SCSS"
+		case "less":
+			return "Less"
+		}
+	}
+	return ""
+}
+
+// attr returns the value of the named attribute
+func (n *htmlNode) attr(name string) (string, bool) {
+	for _, a := range n.attrs {
+		if strings.EqualFold(a.Name, name) {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// isInline reports whether a node flows with the text around it: text,
+// comments and elements other than block ones, as long as they contain
+// only such nodes
+func (n *htmlNode) isInline() bool {
+	switch n.kind {
+	case htmlText, htmlComment:
+		return true
+	case htmlElement:
+		if htmlBlockElements[n.name] || n.name == "script" || n.name == "style" {
+			return false
+		}
+		for _, child := range n.children {
+			if !child.isInline() {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (n *htmlNode) isVoid() bool {
+	return htmlVoidElements[n.name] || n.selfClosing
+}
+
+// startTag prints the start tag on one line. Unquoted attribute values are
+// quoted; quoted ones keep their quotes.
+func (n *htmlNode) startTag() string {
+	var b strings.Builder
+	b.WriteByte('<')
+	b.WriteString(n.tag)
+	for _, a := range n.attrs {
+		b.WriteByte(' ')
+		b.WriteString(a.format())
+	}
+	if n.selfClosing {
+		b.WriteString(" />")
+	} else {
+		b.WriteByte('>')
+	}
+	return b.String()
+}
+
+func (n *htmlNode) endTag() string {
+	return "</" + n.tag + ">"
+}
+
+func (a htmlAttr) format() string {
+	if !a.HasValue {
+		return a.Name
+	}
+	quote := a.Quote
+	if quote == 0 {
+		quote = '"'
+		if strings.ContainsRune(a.Value, '"') {
+			quote = '\''
+		}
+	}
+	return a.Name + "=" + string(quote) + a.Value + string(quote)
+}
+
+// htmlFormatter indents the element tree. Block elements go on lines of
+// their own; runs of text and inline elements are filled into lines no
+// wider than the print width, breaking only where the source had
+// whitespace.
+type htmlFormatter struct {
+	src       string
+	formatter *Formatter
+	opts      *FormatOptions
+	indent    string
+	width     int
+	out       strings.Builder
+}
+
+func formatHTML(src string, formatter *Formatter, opts *FormatOptions) (string, error) {
+	doc, err := parseHTML(src)
+	if err != nil {
+		return "", err
+	}
+	f := &htmlFormatter{
+		src:       src,
+		formatter: formatter,
+		opts:      opts,
+		indent:    opts.indent(2),
+		width:     opts.width(htmlPrintWidth),
+	}
+	if err := f.children(doc.children, 0); err != nil {
+		return "", err
+	}
+	return f.out.String(), nil
+}
+
+func (f *htmlFormatter) line(depth int, text string) {
+	f.out.WriteString(strings.Repeat(f.indent, depth))
+	f.out.WriteString(text)
+	f.out.WriteByte('\n')
+}
+
+// children prints the children of a block element at depth. A blank line
+// between two children in the source is kept.
+func (f *htmlFormatter) children(nodes []*htmlNode, depth int) error {
+	var run []*htmlNode
+	printed, blank := false, false
+	separate := func() {
+		if printed && blank {
+			f.out.WriteByte('\n')
+		}
+		printed, blank = true, false
+	}
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if atoms := htmlAtoms(run); len(atoms) > 0 {
+			separate()
+			f.fill(atoms, depth)
+		}
+		run = nil
+	}
+	for _, node := range nodes {
+		if node.kind == htmlText && isHTMLBlank(node.text) {
+			if strings.Count(node.text, "\n") > 1 {
+				flush()
+				blank = true
+			} else if len(run) > 0 {
+				// Keeps the space between two inline elements
+				run = append(run, node)
+			}
+			continue
+		}
+		if node.isInline() {
+			run = append(run, node)
+			continue
+		}
+		flush()
+		separate()
+		if err := f.block(node, depth); err != nil {
+			return err
+		}
+	}
+	flush()
+	return nil
+}
+
+// block prints a node that is not part of a run of inline content
+func (f *htmlFormatter) block(node *htmlNode, depth int) error {
+	if node.kind == htmlDirective {
+		f.line(depth, node.text)
+		return nil
+	}
+
+	open := f.openTag(node, depth)
+	switch {
+	case node.isVoid():
+		f.out.WriteString(open)
+		return nil
+	case node.name == "script" || node.name == "style":
+		return f.embedded(node, open, depth)
+	case htmlRawTextElements[node.name] && !htmlPreservedElements[node.name]:
+		// A <title> holds text only
+		f.out.WriteString(strings.TrimSuffix(open, "\n"))
+		f.out.WriteString(strings.Join(htmlFields(node.text), " "))
+		f.out.WriteString(node.endTag())
+		f.out.WriteByte('\n')
+		return nil
+	case htmlPreservedElements[node.name]:
+		f.out.WriteString(strings.TrimSuffix(open, "\n"))
+		f.out.WriteString(f.src[node.inner:node.innerEnd])
+		f.out.WriteString(node.endTag())
+		f.out.WriteByte('\n')
+		return nil
+	}
+
+	var content []*htmlNode
+	inline := true
+	for _, child := range node.children {
+		if child.kind == htmlText && isHTMLBlank(child.text) {
+			continue
+		}
+		content = append(content, child)
+		inline = inline && child.isInline()
+	}
+	if len(content) == 0 {
+		f.out.WriteString(strings.TrimSuffix(open, "\n"))
+		f.out.WriteString(node.endTag())
+		f.out.WriteByte('\n')
+		return nil
+	}
+	if inline && !strings.Contains(strings.TrimSuffix(open, "\n"), "\n") {
+		atoms := htmlAtoms(node.children)
+		single := strings.TrimSpace(open) + joinHTMLAtoms(atoms) + node.endTag()
+		if !strings.Contains(single, "\n") && !htmlAtomsBreak(atoms) && len(f.indent)*depth+len(single) <= f.width {
+			f.line(depth, single)
+			return nil
+		}
+	}
+	f.out.WriteString(open)
+	if err := f.children(node.children, depth+1); err != nil {
+		return err
+	}
+	f.line(depth, node.endTag())
+	return nil
+}
+
+// openTag returns the indented start tag and a line break. A tag too long
+// for the line puts each attribute on a line of its own.
+func (f *htmlFormatter) openTag(node *htmlNode, depth int) string {
+	indent := strings.Repeat(f.indent, depth)
+	tag := node.startTag()
+	if len(indent)+len(tag) <= f.width || len(node.attrs) < 2 {
+		return indent + tag + "\n"
+	}
+	var b strings.Builder
+	b.WriteString(indent + "<" + node.tag + "\n")
+	for _, a := range node.attrs {
+		b.WriteString(indent + f.indent + a.format() + "\n")
+	}
+	if node.selfClosing {
+		b.WriteString(indent + "/>\n")
+	} else {
+		b.WriteString(indent + ">\n")
+	}
+	return b.String()
+}
+
+// embedded prints a <script> or <style> element with its contents
+// formatted by their own backend, one level deeper than the tags
+func (f *htmlFormatter) embedded(node *htmlNode, open string, depth int) error {
+	if strings.TrimSpace(node.text) == "" {
+		f.out.WriteString(strings.TrimSuffix(open, "\n"))
+		f.out.WriteString(node.endTag())
+		f.out.WriteByte('\n')
+		return nil
+	}
+	code, ok, err := embedded(f.src, node, f.formatter, f.opts, false)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		f.out.WriteString(strings.TrimSuffix(open, "\n"))
+		f.out.WriteString(node.text)
+		f.out.WriteString(node.endTag())
+		f.out.WriteByte('\n')
+		return nil
+	}
+	f.out.WriteString(open)
+	for _, line := range strings.Split(strings.TrimRight(code, "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			f.out.WriteByte('\n')
+			continue
+		}
+		f.line(depth+1, line)
+	}
+	f.line(depth, node.endTag())
+	return nil
+}
+
+// htmlAtom is an unbreakable piece of inline content: a word, a tag or a
+// comment. spaced records whether whitespace came before it and broken
+// whether that whitespace held a line break.
+type htmlAtom struct {
+	text    string
+	spaced  bool
+	broken  bool
+	comment bool
+}
+
+// breaks reports whether the line must break before the atom: a comment
+// written on a line of its own stays there
+func (a htmlAtom) breaks(prev htmlAtom) bool {
+	return a.broken && (a.comment || prev.comment)
+}
+
+// htmlAtoms flattens a run of inline nodes into atoms, dropping the
+// whitespace at either end
+func htmlAtoms(nodes []*htmlNode) []htmlAtom {
+	var atoms []htmlAtom
+	space, broken := false, false
+	var walk func(nodes []*htmlNode)
+	walk = func(nodes []*htmlNode) {
+		for _, node := range nodes {
+			switch {
+			case node.kind == htmlText:
+				text := node.text
+				leading := text[:len(text)-len(strings.TrimLeft(text, htmlSpace))]
+				if leading != "" {
+					space = true
+					broken = broken || strings.Contains(leading, "\n")
+				}
+				for _, word := range htmlFields(text) {
+					atoms = append(atoms, htmlAtom{text: word, spaced: space, broken: broken})
+					space, broken = true, false
+				}
+				trailing := text[len(strings.TrimRight(text, htmlSpace)):]
+				space = trailing != ""
+				broken = strings.Contains(trailing, "\n")
+			case node.kind == htmlComment:
+				atoms = append(atoms, htmlAtom{text: node.text, spaced: space, broken: broken, comment: true})
+				space, broken = false, false
+			case htmlPreservedElements[node.name]:
+				atoms = append(atoms, htmlAtom{text: node.startTag() + node.text + node.endTag(), spaced: space, broken: broken})
+				space, broken = false, false
+			default:
+				atoms = append(atoms, htmlAtom{text: node.startTag(), spaced: space, broken: broken})
+				space, broken = false, false
+				if !node.isVoid() {
+					walk(node.children)
+					atoms = append(atoms, htmlAtom{text: node.endTag(), spaced: space, broken: broken})
+					space, broken = false, false
+				}
+			}
+		}
+	}
+	walk(nodes)
+	if len(atoms) > 0 {
+		atoms[0].spaced, atoms[0].broken = false, false
+	}
+	return atoms
+}
+
+// htmlFields splits text at runs of HTML whitespace
+func htmlFields(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool { return r < utf8.RuneSelf && isHTMLSpace(byte(r)) })
+}
+
+// isHTMLBlank reports whether text consists of HTML whitespace only
+func isHTMLBlank(text string) bool {
+	return strings.Trim(text, htmlSpace) == ""
+}
+
+// htmlAtomsBreak reports whether any atom has to start a new line
+func htmlAtomsBreak(atoms []htmlAtom) bool {
+	for i := 1; i < len(atoms); i++ {
+		if atoms[i].breaks(atoms[i-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinHTMLAtoms(atoms []htmlAtom) string {
+	var b strings.Builder
+	for _, atom := range atoms {
+		if atom.spaced {
+			b.WriteByte(' ')
+		}
+		b.WriteString(atom.text)
+	}
+	return b.String()
+}
+
+// fill prints atoms in lines no wider than the print width where possible
+func (f *htmlFormatter) fill(atoms []htmlAtom, depth int) {
+	indent := strings.Repeat(f.indent, depth)
+	var line strings.Builder
+	for i, atom := range atoms {
+		if line.Len() > 0 && i > 0 && atom.breaks(atoms[i-1]) {
+			f.line(depth, line.String())
+			line.Reset()
+		} else if line.Len() > 0 && atom.spaced && len(indent)+line.Len()+1+len(atom.text) > f.width {
+			f.line(depth, line.String())
+			line.Reset()
+		} else if line.Len() > 0 && atom.spaced {
+			line.WriteByte(' ')
+		}
+		line.WriteString(atom.text)
+	}
+	if line.Len() > 0 {
+		f.line(depth, line.String())
+	}
+}
+
+func formatHTMLCode(formatter *Formatter) FormatFunc {
+	return func(code string, opts *FormatOptions) (string, error) {
+		formatted, err := formatHTML(code, formatter, opts)
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) && syntaxErr.Language == syntaxHTML {
+			return "", fmt.Errorf("invalid HTML syntax: %w", err)
+		}
+		return formatted, err
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import "testing"
+
+func TestFormatHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			"document",
+			"<!DOCTYPE html><html><head><title>T</title><style>a{color:red}</style></head><body><div class=\"a\"><p>Some <b>bold</b> text</p><pre>  keep\n   this</pre><script>let a=1;if(a){b()}</script></div></body></html>",
+			"<!DOCTYPE html>\n<html>\n  <head>\n    <title>T</title>\n    <style>\n      a {\n        color: red;\n      }\n    </style>\n  </head>\n  <body>\n    <div class=\"a\">\n      <p>Some <b>bold</b> text</p>\n      <pre>  keep\n   this</pre>\n      <script>\n        let a = 1;\n        if (a) {\n          b();\n        }\n      </script>\n    </div>\n  </body>\n</html>\n",
+		},
+		{
+			"optional end tags and void elements",
+			"<ul><li>a<li>b</ul><input disabled><img src=x.png alt=\"\">",
+			"<ul>\n  <li>a</li>\n  <li>b</li>\n</ul>\n<input disabled><img src=\"x.png\" alt=\"\">\n",
+		},
+		{
+			"script types",
+			"<script type=\"text/template\"><div>{{ x }}</div></script><script type=\"application/json\">{\"a\":1}</script>",
+			"<script type=\"text/template\"><div>{{ x }}</div></script>\n<script type=\"application/json\">\n  {\n    \"a\": 1\n  }\n</script>\n",
+		},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Format(tt.code, "html")
+			if err != nil {
+				t.Fatalf("Format(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if again, err := f.Format(got, "html"); err != nil || again != got {
+				t.Errorf("Format(%q) = %q, %v; not idempotent", got, again, err)
+			}
+		})
+	}
+}
+
+func TestMinifyHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			"comments, whitespace and delegation",
+			"<!-- comment -->\n<div  class=\"a\" >\n  <p> Some   <b>bold</b>  text </p>\n  <!--[if IE]>keep<![endif]-->\n  <script>\n    let a = 1;\n  </script>\n  <style> a { color : red } </style>\n</div>",
+			"<div class=\"a\"><p>Some <b>bold</b> text</p><!--[if IE]>keep<![endif]--> <script>let a=1;</script> <style>a{color:red}</style></div>",
+		},
+		{"preformatted text", "<pre>  a\n  b</pre>\n<textarea> x  y </textarea>", "<pre>  a\n  b</pre><textarea> x  y </textarea>"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Minify(tt.code, "html")
+			if err != nil {
+				t.Fatalf("Minify(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Minify(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if again, err := f.Minify(got, "html"); err != nil || again != got {
+				t.Errorf("Minify(%q) = %q, %v; minified markup reads differently", got, again, err)
+			}
+		})
+	}
+}
+
+func TestFormatHTMLErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"<div><!-- open", "invalid HTML syntax: 1:6: unterminated comment"},
+		{"<p>x</p>\n<script>let a = ;</script>", "in <script> at 2:9: invalid JavaScript syntax: 1:9: unexpected token ;"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		_, err := f.Format(tt.code, "html")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Format(%q) error = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+}
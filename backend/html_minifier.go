@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// htmlKeepsParagraphOpen lists the parents a <p> must close explicitly
+// even as their last child, since their content model is transparent
+var htmlKeepsParagraphOpen = setOf("a", "audio", "del", "ins", "map", "noscript", "video")
+
+// htmlNoText lists the elements whose whitespace-only text is never
+// rendered, as they only hold other elements
+var htmlNoText = setOf(
+	"html", "head", "table", "thead", "tbody", "tfoot", "tr", "colgroup",
+	"ul", "ol", "dl", "select", "optgroup", "datalist",
+)
+
+// htmlEndAtParentEnd lists the elements whose end tag may be left out when
+// nothing follows them in their parent
+var htmlEndAtParentEnd = setOf(
+	"li", "dd", "p", "option", "optgroup", "tr", "td", "th", "tbody", "tfoot",
+	"rt", "rp", "body", "html",
+)
+
+// htmlMinifier prints the element tree compactly. Whitespace collapsing
+// and comment removal are on unless turned off; leaving out optional end
+// tags has to be asked for.
+type htmlMinifier struct {
+	src          string
+	formatter    *Formatter
+	opts         *FormatOptions
+	collapse     bool
+	comments     bool
+	optionalTags bool
+	out          strings.Builder
+}
+
+func minifyHTML(src string, formatter *Formatter, opts *FormatOptions) (string, error) {
+	doc, err := parseHTML(src)
+	if err != nil {
+		return "", err
+	}
+	m := &htmlMinifier{
+		src:          src,
+		formatter:    formatter,
+		opts:         opts,
+		collapse:     opts.collapseWhitespace(),
+		comments:     opts.removeComments(),
+		optionalTags: opts.removeOptionalTags(),
+	}
+	if err := m.children(doc); err != nil {
+		return "", err
+	}
+	return m.out.String(), nil
+}
+
+// isConditionalComment reports whether a comment is an Internet Explorer
+// conditional comment, which is markup rather than commentary
+func isConditionalComment(text string) bool {
+	return strings.HasPrefix(text, "<!--[if") || strings.Contains(text, "[endif]")
+}
+
+func (m *htmlMinifier) children(parent *htmlNode) error {
+	var nodes []*htmlNode
+	for _, node := range parent.children {
+		switch {
+		case m.comments && node.kind == htmlComment && !isConditionalComment(node.text):
+			continue
+		case m.collapse && node.kind == htmlText && htmlNoText[parent.name] && isHTMLBlank(node.text):
+			continue
+		case node.kind == htmlText && len(nodes) > 0 && nodes[len(nodes)-1].kind == htmlText:
+			// Text on both sides of a removed comment
+			merged := *nodes[len(nodes)-1]
+			merged.text += node.text
+			nodes[len(nodes)-1] = &merged
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+
+	// Whitespace next to a block element or at either end of one is not
+	// rendered; elsewhere a run of it renders as one space
+	block := parent.kind == htmlDocument || htmlBlockElements[parent.name]
+	texts := make([]string, len(nodes))
+	for i, node := range nodes {
+		if node.kind != htmlText {
+			continue
+		}
+		text := node.text
+		if m.collapse {
+			text = collapseHTMLSpace(text)
+			if i == 0 && block || i > 0 && isHTMLBlock(nodes[i-1]) {
+				text = strings.TrimPrefix(text, " ")
+			}
+			if i == len(nodes)-1 && block || i+1 < len(nodes) && isHTMLBlock(nodes[i+1]) {
+				text = strings.TrimSuffix(text, " ")
+			}
+		}
+		texts[i] = text
+	}
+
+	for i, node := range nodes {
+		switch node.kind {
+		case htmlText:
+			m.out.WriteString(texts[i])
+		case htmlComment, htmlDirective:
+			m.out.WriteString(node.text)
+		case htmlElement:
+			var next *htmlNode
+			for j := i + 1; j < len(nodes); j++ {
+				if nodes[j].kind != htmlText || texts[j] != "" {
+					next = nodes[j]
+					break
+				}
+			}
+			if err := m.element(node, next); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *htmlMinifier) element(node *htmlNode, next *htmlNode) error {
+	m.out.WriteByte('<')
+	m.out.WriteString(node.tag)
+	for _, a := range node.attrs {
+		m.out.WriteByte(' ')
+		m.out.WriteString(a.Name)
+		if a.HasValue {
+			m.out.WriteByte('=')
+			if a.Quote != 0 {
+				m.out.WriteByte(a.Quote)
+			}
+			m.out.WriteString(a.Value)
+			if a.Quote != 0 {
+				m.out.WriteByte(a.Quote)
+			}
+		}
+	}
+	if node.selfClosing {
+		m.out.WriteString("/>")
+		return nil
+	}
+	m.out.WriteByte('>')
+	if htmlVoidElements[node.name] {
+		return nil
+	}
+
+	switch {
+	case node.name == "script" || node.name == "style":
+		code, _, err := embedded(m.src, node, m.formatter, m.opts, true)
+		if err != nil {
+			return err
+		}
+		m.out.WriteString(code)
+	case htmlPreservedElements[node.name]:
+		m.out.WriteString(m.src[node.inner:node.innerEnd])
+	case htmlRawTextElements[node.name]:
+		text := node.text
+		if m.collapse {
+			text = strings.Trim(collapseHTMLSpace(text), " ")
+		}
+		m.out.WriteString(text)
+	default:
+		if err := m.children(node); err != nil {
+			return err
+		}
+	}
+
+	if !m.optionalTags || !htmlEndTagOptional(node, next) {
+		m.out.WriteString(node.endTag())
+	}
+	return nil
+}
+
+// htmlEndTagOptional reports whether leaving out the end tag of node,
+// followed by next or by nothing, parses to the same tree
+func htmlEndTagOptional(node, next *htmlNode) bool {
+	if next == nil {
+		if !htmlEndAtParentEnd[node.name] {
+			return false
+		}
+		parent := node.parent
+		return node.name != "p" || parent.kind == htmlDocument ||
+			!htmlKeepsParagraphOpen[parent.name] && !strings.Contains(parent.name, "-")
+	}
+	return next.kind == htmlElement && htmlImpliedEnd[node.name][next.name]
+}
+
+// isHTMLBlock reports whether whitespace next to a node is not rendered
+func isHTMLBlock(node *htmlNode) bool {
+	return node.kind == htmlElement && htmlBlockElements[node.name]
+}
+
+// collapseHTMLSpace replaces each run of HTML whitespace with one space.
+// Other Unicode spaces, such as the no-break space, are content.
+func collapseHTMLSpace(text string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(text); i++ {
+		if isHTMLSpace(text[i]) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(text[i])
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+func minifyHTMLCode(formatter *Formatter) MinifyFunc {
+	return func(code string, opts *FormatOptions) (string, error) {
+		minified, err := minifyHTML(code, formatter, opts)
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) && syntaxErr.Language == syntaxHTML {
+			return "", fmt.Errorf("invalid HTML syntax: %w", err)
+		}
+		return minified, err
+	}
+}
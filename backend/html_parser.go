@@ -0,0 +1,373 @@
+package main
+
+import (
+	"strings"
+)
+
+type htmlNodeKind int
+
+const (
+	htmlDocument htmlNodeKind = iota
+	htmlElement
+	htmlText
+	htmlComment
+	// htmlDirective is a doctype, CDATA section or processing instruction
+	htmlDirective
+)
+
+// htmlAttr is an attribute as written. Quote is 0 for an unquoted value.
+type htmlAttr struct {
+	Name     string
+	Value    string
+	Quote    byte
+	HasValue bool
+}
+
+// htmlNode is a node of the element tree. Text, comments and directives
+// keep their source text; elements keep their tag name as written.
+type htmlNode struct {
+	kind     htmlNodeKind
+	name     string // lowercased tag name
+	tag      string // tag name as written
+	attrs    []htmlAttr
+	children []*htmlNode
+	text     string
+	// selfClosing is set for tags written as <name/>
+	selfClosing bool
+	// start is the offset of the node in the source. inner and innerEnd
+	// delimit an element's content; raw text elements such as <script> and
+	// elements keeping their whitespace such as <pre> are printed from it.
+	start    int
+	inner    int
+	innerEnd int
+	parent   *htmlNode
+}
+
+// htmlVoidElements never have content or an end tag
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlRawTextElements hold text that is not parsed as markup
+var htmlRawTextElements = map[string]bool{
+	"script": true, "style": true, "textarea": true, "title": true, "xmp": true,
+}
+
+// htmlPreservedElements keep their content exactly as written
+var htmlPreservedElements = map[string]bool{
+	"pre": true, "textarea": true, "xmp": true,
+}
+
+// htmlClosesParagraph lists the start tags that end an open <p>
+var htmlClosesParagraph = setOf(
+	"address", "article", "aside", "blockquote", "details", "dialog", "div",
+	"dl", "fieldset", "figcaption", "figure", "footer", "form", "h1", "h2",
+	"h3", "h4", "h5", "h6", "header", "hgroup", "hr", "main", "menu", "nav",
+	"ol", "p", "pre", "section", "table", "ul",
+)
+
+// htmlImpliedEnd maps elements whose end tag may be omitted to the start
+// tags of the siblings that close them
+var htmlImpliedEnd = map[string]map[string]bool{
+	"p":        union(htmlClosesParagraph, setOf("li", "dt", "dd")),
+	"li":       setOf("li"),
+	"dt":       setOf("dt", "dd"),
+	"dd":       setOf("dt", "dd"),
+	"option":   setOf("option", "optgroup"),
+	"optgroup": setOf("optgroup"),
+	"tr":       setOf("tr", "tbody", "tfoot", "thead"),
+	"td":       setOf("td", "th", "tr", "tbody", "tfoot", "thead"),
+	"th":       setOf("td", "th", "tr", "tbody", "tfoot", "thead"),
+	"thead":    setOf("tbody", "tfoot"),
+	"tbody":    setOf("tbody", "tfoot"),
+	"tfoot":    setOf("tbody"),
+	"colgroup": setOf("thead", "tbody", "tfoot", "tr"),
+	"rt":       setOf("rt", "rp"),
+	"rp":       setOf("rt", "rp"),
+	"head":     setOf("body"),
+	"body":     {},
+	"html":     {},
+}
+
+func union(a, b map[string]bool) map[string]bool {
+	set := make(map[string]bool, len(a)+len(b))
+	for name := range a {
+		set[name] = true
+	}
+	for name := range b {
+		set[name] = true
+	}
+	return set
+}
+
+func setOf(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+func htmlErrorf(src string, offset int, format string, args ...interface{}) error {
+	return newSyntaxError(syntaxHTML, src, offset, format, args...)
+}
+
+type htmlParser struct {
+	src   string
+	pos   int
+	stack []*htmlNode
+}
+
+// parseHTML builds the element tree of a document or fragment. Misnested
+// and missing tags are recovered from the way the HTML parsing algorithm
+// recovers: elements are closed where a browser would close them, end tags
+// closing nothing are dropped, and what is left open at the end is closed
+// there. Only markup that cannot be tokenized is an error.
+func parseHTML(src string) (*htmlNode, error) {
+	doc := &htmlNode{kind: htmlDocument, innerEnd: len(src)}
+	p := &htmlParser{src: src, stack: []*htmlNode{doc}}
+	for p.pos < len(src) {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	for len(p.stack) > 1 {
+		p.pop(len(src))
+	}
+	return doc, nil
+}
+
+func (p *htmlParser) top() *htmlNode {
+	return p.stack[len(p.stack)-1]
+}
+
+func (p *htmlParser) add(node *htmlNode) {
+	node.parent = p.top()
+	node.parent.children = append(node.parent.children, node)
+}
+
+// pop closes the innermost open element at offset end
+func (p *htmlParser) pop(end int) {
+	p.top().innerEnd = end
+	p.stack = p.stack[:len(p.stack)-1]
+}
+
+func (p *htmlParser) next() error {
+	src, start := p.src, p.pos
+	rest := src[start:]
+	switch {
+	case strings.HasPrefix(rest, "<!--"):
+		end := strings.Index(rest[4:], "-->")
+		if end < 0 {
+			return htmlErrorf(src, start, "unterminated comment")
+		}
+		p.pos = start + 4 + end + 3
+		p.add(&htmlNode{kind: htmlComment, text: src[start:p.pos], start: start})
+	case strings.HasPrefix(rest, "<![CDATA["):
+		end := strings.Index(rest, "]]>")
+		if end < 0 {
+			return htmlErrorf(src, start, "unterminated CDATA section")
+		}
+		p.pos = start + end + 3
+		p.add(&htmlNode{kind: htmlDirective, text: src[start:p.pos], start: start})
+	case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+		end := strings.IndexByte(rest, '>')
+		if end < 0 {
+			return htmlErrorf(src, start, "unterminated %s", rest[:2])
+		}
+		p.pos = start + end + 1
+		p.add(&htmlNode{kind: htmlDirective, text: src[start:p.pos], start: start})
+	case strings.HasPrefix(rest, "</") && len(rest) > 2 && isLetter(rest[2]):
+		return p.endTag()
+	case rest[0] == '<' && len(rest) > 1 && isLetter(rest[1]):
+		return p.startTag(start + 1)
+	default:
+		end := len(rest)
+		for i := 1; i < len(rest); i++ {
+			if rest[i] == '<' && i+1 < len(rest) && (isLetter(rest[i+1]) || strings.ContainsRune("!/?", rune(rest[i+1]))) {
+				end = i
+				break
+			}
+		}
+		p.pos = start + end
+		p.add(&htmlNode{kind: htmlText, text: rest[:end], start: start})
+	}
+	return nil
+}
+
+// startTag reads the start tag at the parser's offset, whose name starts
+// at i
+func (p *htmlParser) startTag(i int) error {
+	src, start := p.src, p.pos
+	name := i
+	for i < len(src) && !isHTMLSpace(src[i]) && src[i] != '/' && src[i] != '>' {
+		i++
+	}
+	node := &htmlNode{kind: htmlElement, tag: src[name:i], start: start}
+	node.name = strings.ToLower(node.tag)
+	for {
+		for i < len(src) && (isHTMLSpace(src[i]) || src[i] == '/' && !strings.HasPrefix(src[i:], "/>")) {
+			i++
+		}
+		if i >= len(src) {
+			return htmlErrorf(src, start, "unterminated <%s> tag", node.tag)
+		}
+		if strings.HasPrefix(src[i:], "/>") {
+			node.selfClosing = true
+			i += 2
+			break
+		}
+		if src[i] == '>' {
+			i++
+			break
+		}
+		attr, end, err := p.attribute(i)
+		if err != nil {
+			return err
+		}
+		node.attrs = append(node.attrs, attr)
+		i = end
+	}
+	p.pos = i
+	node.inner, node.innerEnd = i, i
+
+	for htmlImpliedEnd[p.top().name][node.name] {
+		p.pop(start)
+	}
+	p.add(node)
+	if node.selfClosing || htmlVoidElements[node.name] {
+		return nil
+	}
+	if htmlRawTextElements[node.name] {
+		end := indexFold(src[i:], "</"+node.name)
+		for end >= 0 {
+			after := i + end + 2 + len(node.name)
+			if after >= len(src) || isHTMLSpace(src[after]) || src[after] == '>' || src[after] == '/' {
+				break
+			}
+			next := indexFold(src[after:], "</"+node.name)
+			if next < 0 {
+				end = -1
+				break
+			}
+			end = after - i + next
+		}
+		if end < 0 {
+			// The text runs to the end of the input
+			end = len(src) - i
+		}
+		node.innerEnd = i + end
+		node.text = src[i : i+end]
+		close := strings.IndexByte(src[i+end:], '>')
+		if close < 0 {
+			close = len(src) - i - end - 1
+		}
+		p.pos = i + end + close + 1
+		return nil
+	}
+	p.stack = append(p.stack, node)
+	return nil
+}
+
+// attribute reads the attribute starting at i and returns the offset after it
+func (p *htmlParser) attribute(i int) (htmlAttr, int, error) {
+	src := p.src
+	start := i
+	// The first character may be = as in Vue's shorthands, never the end
+	i++
+	for i < len(src) && !isHTMLSpace(src[i]) && !strings.ContainsRune("=>\"'", rune(src[i])) && !strings.HasPrefix(src[i:], "/>") {
+		i++
+	}
+	attr := htmlAttr{Name: src[start:i]}
+	j := i
+	for j < len(src) && isHTMLSpace(src[j]) {
+		j++
+	}
+	if j >= len(src) || src[j] != '=' {
+		return attr, i, nil
+	}
+	j++
+	for j < len(src) && isHTMLSpace(src[j]) {
+		j++
+	}
+	attr.HasValue = true
+	if j < len(src) && (src[j] == '"' || src[j] == '\'') {
+		end := strings.IndexByte(src[j+1:], src[j])
+		if end < 0 {
+			return attr, 0, htmlErrorf(src, j, "unterminated attribute value")
+		}
+		attr.Quote = src[j]
+		attr.Value = src[j+1 : j+1+end]
+		return attr, j + 1 + end + 1, nil
+	}
+	end := j
+	for end < len(src) && !isHTMLSpace(src[end]) && src[end] != '>' {
+		end++
+	}
+	attr.Value = src[j:end]
+	return attr, end, nil
+}
+
+func (p *htmlParser) endTag() error {
+	src, start := p.src, p.pos
+	i := start + 2
+	for i < len(src) && !isHTMLSpace(src[i]) && src[i] != '/' && src[i] != '>' {
+		i++
+	}
+	name := strings.ToLower(src[start+2 : i])
+	close := strings.IndexByte(src[i:], '>')
+	if close < 0 {
+		return htmlErrorf(src, start, "unterminated </%s> tag", src[start+2:i])
+	}
+
+	switch name {
+	case "br":
+		// </br> is read as <br>
+		return p.startTag(start + 2)
+	case "p":
+		// </p> closing nothing stands for an empty paragraph
+		if !p.open(name) {
+			p.add(&htmlNode{kind: htmlElement, name: name, tag: src[start+2 : i], start: start, inner: start, innerEnd: start})
+		}
+	}
+	p.pos = i + close + 1
+
+	for j := len(p.stack) - 1; j > 0; j-- {
+		if p.stack[j].name != name {
+			continue
+		}
+		// Elements still open inside are closed with it
+		for len(p.stack)-1 > j {
+			p.pop(start)
+		}
+		p.pop(start)
+		break
+	}
+	return nil
+}
+
+// open reports whether an element is open
+func (p *htmlParser) open(name string) bool {
+	for _, node := range p.stack[1:] {
+		if node.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// indexFold is strings.Index ignoring ASCII case in s
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
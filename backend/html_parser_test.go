@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestFormatHTMLRecovery(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"unclosed div", "<div><p>x</p>", "<div>\n  <p>x</p>\n</div>\n"},
+		{"end br", "<p>a</br>b</p>", "<p>a<br>b</p>\n"},
+		{"stray end tag", "</span><p>x</p>", "<p>x</p>\n"},
+		{"stray end p", "a</p>b", "a\n<p></p>\nb\n"},
+		{"misnested", "<b><i>x</b></i>", "<b><i>x</i></b>\n"},
+		{"inner elements closed", "<div><span>a</div>b", "<div><span>a</span></div>\nb\n"},
+		{"unclosed script", "<script>let a=1", "<script>\n  let a = 1;\n</script>\n"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Format(tt.code, "html")
+			if err != nil {
+				t.Fatalf("Format(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if again, err := f.Format(got, "html"); err != nil || again != got {
+				t.Errorf("Format(%q) = %q, %v; not idempotent", got, again, err)
+			}
+		})
+	}
+}
+
+func TestMinifyHTMLRecovery(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"<div><p>x", "<div><p>x</p></div>"},
+		{"a</br>b", "a<br>b"},
+		{"</em>x", "x"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		got, err := f.Minify(tt.code, "html")
+		if err != nil {
+			t.Fatalf("Minify(%q) error: %v", tt.code, err)
+		}
+		if got != tt.want {
+			t.Errorf("Minify(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestParseHTMLErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"<div>\n<!-- x", "2:1: unterminated comment"},
+		{"<p>\n  <a href='x>", "2:11: unterminated attribute value"},
+		{"<p>x</p", "1:5: unterminated </p> tag"},
+	}
+
+	for _, tt := range tests {
+		_, err := parseHTML(tt.code)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("parseHTML(%q) error = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,647 @@
+package main
+
+// jsNode is a node of the JavaScript/TypeScript syntax tree. Every node
+// records the byte range it spans in the source.
+type jsNode interface {
+	span() (int, int)
+}
+
+type jsSpan struct {
+	start int
+	end   int
+}
+
+func (s *jsSpan) span() (int, int) {
+	return s.start, s.end
+}
+
+func nodeStart(n jsNode) int {
+	start, _ := n.span()
+	return start
+}
+
+func nodeEnd(n jsNode) int {
+	_, end := n.span()
+	return end
+}
+
+// Program and statements
+
+type jsProgram struct {
+	jsSpan
+	body []jsNode
+}
+
+type jsVarDecl struct {
+	jsSpan
+	kind  string // var, let, const, using
+	decls []*jsVarDeclarator
+}
+
+type jsVarDeclarator struct {
+	jsSpan
+	target   jsNode
+	definite bool
+	typeAnn  jsNode
+	init     jsNode
+}
+
+type jsExprStmt struct {
+	jsSpan
+	expr jsNode
+}
+
+type jsBlock struct {
+	jsSpan
+	body []jsNode
+}
+
+type jsEmpty struct {
+	jsSpan
+}
+
+type jsIf struct {
+	jsSpan
+	test jsNode
+	cons jsNode
+	alt  jsNode
+}
+
+type jsFor struct {
+	jsSpan
+	init   jsNode
+	test   jsNode
+	update jsNode
+	body   jsNode
+}
+
+type jsForIn struct {
+	jsSpan
+	of    bool
+	await bool
+	left  jsNode
+	right jsNode
+	body  jsNode
+}
+
+type jsWhile struct {
+	jsSpan
+	test jsNode
+	body jsNode
+}
+
+type jsDoWhile struct {
+	jsSpan
+	body jsNode
+	test jsNode
+}
+
+type jsReturn struct {
+	jsSpan
+	arg jsNode
+}
+
+type jsJump struct {
+	jsSpan
+	keyword string // break or continue
+	label   *jsIdent
+}
+
+type jsThrow struct {
+	jsSpan
+	arg jsNode
+}
+
+type jsTry struct {
+	jsSpan
+	block     *jsBlock
+	param     jsNode
+	paramType jsNode
+	handler   *jsBlock
+	finalizer *jsBlock
+}
+
+type jsSwitch struct {
+	jsSpan
+	disc  jsNode
+	cases []*jsSwitchCase
+}
+
+type jsSwitchCase struct {
+	jsSpan
+	test jsNode // nil for default
+	body []jsNode
+}
+
+type jsLabeled struct {
+	jsSpan
+	label *jsIdent
+	body  jsNode
+}
+
+type jsDebugger struct {
+	jsSpan
+}
+
+type jsWith struct {
+	jsSpan
+	object jsNode
+	body   jsNode
+}
+
+type jsImport struct {
+	jsSpan
+	typeOnly     bool
+	defaultName  *jsIdent
+	namespace    *jsIdent
+	named        []*jsModuleSpec
+	hasNamed     bool
+	source       *jsLiteral
+	attributes   jsNode
+	attributesKw string
+}
+
+// jsModuleSpec is an import or export specifier: local as exported
+type jsModuleSpec struct {
+	jsSpan
+	typeOnly bool
+	local    jsNode
+	exported jsNode // nil when not renamed
+}
+
+type jsExport struct {
+	jsSpan
+	decorators  []jsNode // decorators written before the export keyword
+	typeOnly    bool
+	isDefault   bool
+	decl        jsNode // declaration or default expression
+	named       []*jsModuleSpec
+	hasNamed    bool
+	star        bool
+	starAs      jsNode
+	source      *jsLiteral
+	attributes  jsNode
+	assignment  jsNode // TypeScript export = value
+	namespaceAs *jsIdent
+}
+
+// Functions and classes
+
+type jsFunction struct {
+	jsSpan
+	name       *jsIdent
+	async      bool
+	generator  bool
+	arrow      bool
+	params     []*jsParam
+	typeParams *tsTypeParams
+	returnType jsNode
+	body       *jsBlock // nil for arrow expression bodies and overloads
+	exprBody   jsNode
+}
+
+type jsParam struct {
+	jsSpan
+	decorators []jsNode
+	modifiers  []string
+	rest       bool
+	pattern    jsNode
+	optional   bool
+	typeAnn    jsNode
+	init       jsNode
+}
+
+type jsClass struct {
+	jsSpan
+	decorators    []jsNode
+	modifiers     []string // abstract, declare
+	name          *jsIdent
+	typeParams    *tsTypeParams
+	superClass    jsNode
+	superTypeArgs []jsNode
+	implements    []jsNode
+	members       []jsNode
+}
+
+type jsMethod struct {
+	jsSpan
+	decorators []jsNode
+	modifiers  []string
+	kind       string // method, get, set, constructor
+	key        jsNode
+	computed   bool
+	optional   bool
+	fn         *jsFunction
+}
+
+type jsField struct {
+	jsSpan
+	decorators []jsNode
+	modifiers  []string
+	key        jsNode
+	computed   bool
+	optional   bool
+	definite   bool
+	typeAnn    jsNode
+	value      jsNode
+}
+
+type jsStaticBlock struct {
+	jsSpan
+	body []jsNode
+}
+
+type jsDecorator struct {
+	jsSpan
+	expr jsNode
+}
+
+// Expressions
+
+type jsIdent struct {
+	jsSpan
+	name string
+}
+
+// jsLiteral is any literal kept verbatim: strings, numbers, regular
+// expressions and the keywords this, super, null, true and false
+type jsLiteral struct {
+	jsSpan
+	raw  string
+	kind jsTokenKind
+}
+
+type jsTemplateLiteral struct {
+	jsSpan
+	tag      jsNode
+	typeArgs []jsNode
+	quasis   []string // raw text between substitutions, delimiters included
+	exprs    []jsNode
+}
+
+type jsArray struct {
+	jsSpan
+	elems []jsNode // nil entries are holes
+}
+
+type jsObject struct {
+	jsSpan
+	props     []jsNode
+	multiline bool // the source broke the line after the opening brace
+}
+
+type jsProperty struct {
+	jsSpan
+	kind      string // init, get, set, method
+	key       jsNode
+	computed  bool
+	shorthand bool
+	value     jsNode
+	fn        *jsFunction
+}
+
+type jsSpread struct {
+	jsSpan
+	arg jsNode
+}
+
+type jsUnary struct {
+	jsSpan
+	op  string
+	arg jsNode
+}
+
+type jsUpdate struct {
+	jsSpan
+	op     string
+	prefix bool
+	arg    jsNode
+}
+
+// jsBinary covers arithmetic, logical, relational and assignment operators
+type jsBinary struct {
+	jsSpan
+	op    string
+	left  jsNode
+	right jsNode
+}
+
+type jsConditional struct {
+	jsSpan
+	test jsNode
+	cons jsNode
+	alt  jsNode
+}
+
+type jsCall struct {
+	jsSpan
+	isNew    bool
+	callee   jsNode
+	typeArgs []jsNode
+	args     []jsNode
+	optional bool
+	hasArgs  bool // false for new Foo without parentheses
+}
+
+type jsMember struct {
+	jsSpan
+	object   jsNode
+	property jsNode
+	computed bool
+	optional bool
+}
+
+type jsSequence struct {
+	jsSpan
+	exprs []jsNode
+}
+
+type jsParen struct {
+	jsSpan
+	expr jsNode
+}
+
+type jsYield struct {
+	jsSpan
+	delegate bool
+	arg      jsNode
+}
+
+type jsAwait struct {
+	jsSpan
+	arg jsNode
+}
+
+// jsMetaProperty is new.target or import.meta
+type jsMetaProperty struct {
+	jsSpan
+	text string
+}
+
+// TypeScript expressions
+
+type tsAsExpr struct {
+	jsSpan
+	op   string // as or satisfies
+	expr jsNode
+	typ  jsNode
+}
+
+type tsNonNull struct {
+	jsSpan
+	expr jsNode
+}
+
+type tsTypeAssertion struct {
+	jsSpan
+	typ  jsNode
+	expr jsNode
+}
+
+// JSX
+
+type jsxElement struct {
+	jsSpan
+	name        string // empty for fragments
+	typeArgs    []jsNode
+	attrs       []jsNode
+	children    []jsNode
+	selfClosing bool
+}
+
+type jsxAttribute struct {
+	jsSpan
+	name  string
+	value jsNode // nil, string literal, expression container or element
+}
+
+type jsxSpreadAttribute struct {
+	jsSpan
+	arg jsNode
+}
+
+type jsxText struct {
+	jsSpan
+	raw string
+}
+
+type jsxExprContainer struct {
+	jsSpan
+	expr   jsNode // nil for an empty {} or comment-only container
+	spread bool
+}
+
+// TypeScript declarations
+
+type tsTypeAlias struct {
+	jsSpan
+	name       *jsIdent
+	typeParams *tsTypeParams
+	typ        jsNode
+}
+
+type tsInterface struct {
+	jsSpan
+	name       *jsIdent
+	typeParams *tsTypeParams
+	extends    []jsNode
+	body       *tsTypeLiteral
+}
+
+type tsEnum struct {
+	jsSpan
+	modifiers []string // const
+	name      *jsIdent
+	members   []*tsEnumMember
+}
+
+type tsEnumMember struct {
+	jsSpan
+	name jsNode
+	init jsNode
+}
+
+type tsModule struct {
+	jsSpan
+	keyword string // namespace or module; empty for global
+	name    jsNode
+	body    *jsBlock
+}
+
+// tsDeclare wraps a statement preceded by the declare modifier
+type tsDeclare struct {
+	jsSpan
+	stmt jsNode
+}
+
+type tsImportEquals struct {
+	jsSpan
+	exported bool
+	typeOnly bool
+	name     *jsIdent
+	ref      jsNode
+}
+
+// TypeScript types
+
+type tsTypeParams struct {
+	jsSpan
+	params []*tsTypeParam
+}
+
+type tsTypeParam struct {
+	jsSpan
+	modifiers  []string // const, in, out
+	name       *jsIdent
+	constraint jsNode
+	def        jsNode
+}
+
+// tsTypeRef is a named type such as Array<T> or ns.Type
+type tsTypeRef struct {
+	jsSpan
+	name string
+	args []jsNode
+}
+
+// tsKeywordType is a primitive, literal or this type printed verbatim
+type tsKeywordType struct {
+	jsSpan
+	text string
+}
+
+type tsUnion struct {
+	jsSpan
+	op    string // | or &
+	types []jsNode
+}
+
+type tsArrayType struct {
+	jsSpan
+	elem jsNode
+}
+
+type tsIndexedAccess struct {
+	jsSpan
+	object jsNode
+	index  jsNode
+}
+
+type tsTuple struct {
+	jsSpan
+	elems []*tsTupleElem
+}
+
+type tsTupleElem struct {
+	jsSpan
+	rest     bool
+	name     string
+	optional bool
+	typ      jsNode
+}
+
+type tsFunctionType struct {
+	jsSpan
+	construct  bool
+	abstract   bool
+	typeParams *tsTypeParams
+	params     []*jsParam
+	returnType jsNode
+}
+
+type tsTypeLiteral struct {
+	jsSpan
+	members   []jsNode
+	multiline bool
+}
+
+// tsPropertySig is a property or method signature in a type literal
+type tsPropertySig struct {
+	jsSpan
+	modifiers  []string
+	key        jsNode
+	computed   bool
+	optional   bool
+	method     bool
+	kind       string // get or set accessors
+	typeParams *tsTypeParams
+	params     []*jsParam
+	typ        jsNode
+}
+
+// tsCallSig is a call or construct signature
+type tsCallSig struct {
+	jsSpan
+	construct  bool
+	typeParams *tsTypeParams
+	params     []*jsParam
+	returnType jsNode
+}
+
+type tsIndexSig struct {
+	jsSpan
+	modifiers []string
+	param     *jsParam
+	typ       jsNode
+}
+
+type tsMappedType struct {
+	jsSpan
+	readonly   string // "", "readonly", "+readonly", "-readonly"
+	param      string
+	constraint jsNode
+	nameType   jsNode
+	optional   string // "", "?", "+?", "-?"
+	typ        jsNode
+}
+
+type tsConditionalType struct {
+	jsSpan
+	check     jsNode
+	extends   jsNode
+	trueType  jsNode
+	falseType jsNode
+}
+
+type tsTypeOperator struct {
+	jsSpan
+	op  string // keyof, unique, readonly, infer
+	typ jsNode
+}
+
+type tsInferType struct {
+	jsSpan
+	name       *jsIdent
+	constraint jsNode
+}
+
+type tsTypeQuery struct {
+	jsSpan
+	expr string
+	args []jsNode
+}
+
+type tsParenType struct {
+	jsSpan
+	typ jsNode
+}
+
+type tsImportType struct {
+	jsSpan
+	arg       *jsLiteral
+	qualifier string
+	args      []jsNode
+}
+
+type tsTypePredicate struct {
+	jsSpan
+	asserts bool
+	param   string
+	typ     jsNode
+}
+
+type tsTemplateType struct {
+	jsSpan
+	quasis []string
+	types  []jsNode
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Levels of the compress option
+const (
+	// compressWhitespace only drops whitespace and comments
+	compressWhitespace = 0
+	// compressSimplify also folds constants, shortens booleans and removes
+	// unreachable code and unused functions
+	compressSimplify = 1
+	// compressMangle also renames local variables
+	compressMangle = 2
+
+	maxCompress = compressMangle
+)
+
+// jsEdit replaces the tokens starting in [start, end) of the source with
+// the tokens of text. name is the original name of a renamed identifier.
+type jsEdit struct {
+	start, end int
+	text       string
+	name       string
+}
+
+// compressJS parses a program and rewrites its tokens as the compress
+// level asks. It returns the rewritten tokens and, for each of them, the
+// original name of an identifier it renames.
+func compressJS(src string, tokens []jsToken, opts *FormatOptions) ([]jsToken, []string, error) {
+	prog, _, err := parseJS(src, jsDialect{})
+	if err != nil {
+		return nil, nil, err
+	}
+	a := analyzeJS(src, prog, opts.reserved())
+
+	c := &jsCompressor{src: src, unused: a.unusedFunctions()}
+	c.statements(prog.body)
+	edits := c.edits
+	if opts.compress() >= compressMangle {
+		edits = append(edits, a.mangle(opts.reserved())...)
+	}
+	return applyJSEdits(tokens, edits)
+}
+
+// applyJSEdits rewrites a token stream. Edits nested in an earlier edit
+// are dropped along with the tokens they would have changed.
+func applyJSEdits(tokens []jsToken, edits []jsEdit) ([]jsToken, []string, error) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].end > edits[j].end
+	})
+	kept := edits[:0]
+	for _, e := range edits {
+		if len(kept) > 0 && e.start < kept[len(kept)-1].end {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	edits = kept
+
+	out := make([]jsToken, 0, len(tokens))
+	names := make([]string, 0, len(tokens))
+	// newline carries a line break out of dropped tokens, which may still
+	// end a statement
+	newline := false
+	insert := func(e jsEdit, newlineBefore bool) error {
+		replacement, err := tokenizeJS(e.text)
+		if err != nil {
+			return err
+		}
+		for i, tok := range replacement {
+			tok.start, tok.end = e.start, e.end
+			tok.newlineBefore = i == 0 && newlineBefore
+			out = append(out, tok)
+			name := ""
+			if i == len(replacement)-1 {
+				name = e.name
+			}
+			names = append(names, name)
+		}
+		newline = newlineBefore && len(replacement) == 0
+		return nil
+	}
+
+	i, inserted := 0, false
+	for _, tok := range tokens {
+		for i < len(edits) && edits[i].end <= tok.start {
+			if !inserted {
+				if err := insert(edits[i], false); err != nil {
+					return nil, nil, err
+				}
+			}
+			i, inserted = i+1, false
+		}
+		if i < len(edits) && tok.start >= edits[i].start && !tok.isComment() {
+			if !inserted {
+				if err := insert(edits[i], tok.newlineBefore || newline); err != nil {
+					return nil, nil, err
+				}
+				inserted = true
+			} else if tok.newlineBefore {
+				newline = true
+			}
+			continue
+		}
+		if newline && !tok.isComment() {
+			tok.newlineBefore = true
+			newline = false
+		}
+		out = append(out, tok)
+		names = append(names, "")
+	}
+	for ; i < len(edits); i++ {
+		if !inserted {
+			if err := insert(edits[i], false); err != nil {
+				return nil, nil, err
+			}
+		}
+		inserted = false
+	}
+	return out, names, nil
+}
+
+// jsCompressor collects the edits that fold constants and remove dead code
+type jsCompressor struct {
+	src    string
+	edits  []jsEdit
+	unused map[*jsFunction]bool
+	// needsSemicolon is set when the last statement written to the
+	// current list may run on into what follows it
+	needsSemicolon bool
+}
+
+func (c *jsCompressor) replace(n jsNode, text string) {
+	start, end := n.span()
+	c.edits = append(c.edits, jsEdit{start: start, end: end, text: text})
+}
+
+func (c *jsCompressor) cut(start, end int, text string) {
+	c.edits = append(c.edits, jsEdit{start: start, end: end, text: text})
+}
+
+// separator returns what a statement removed from a list must leave
+// behind: a semicolon when the statement before it could otherwise run
+// on into the one after it
+func (c *jsCompressor) separator() string {
+	if !c.needsSemicolon {
+		return ""
+	}
+	c.needsSemicolon = false
+	return ";"
+}
+
+// statements walks a statement list, dropping unused functions and the
+// statements following a return, throw, break or continue
+func (c *jsCompressor) statements(list []jsNode) {
+	saved := c.needsSemicolon
+	c.needsSemicolon = false
+	terminated := false
+	for _, n := range list {
+		fn, isFunction := n.(*jsFunction)
+		if terminated && !jsDeclares(n) || isFunction && c.unused[fn] {
+			c.replace(n, c.separator())
+			continue
+		}
+		c.visit(n, nil)
+		c.needsSemicolon = !c.terminated(n)
+		switch n.(type) {
+		case *jsReturn, *jsThrow, *jsJump:
+			terminated = true
+		}
+	}
+	c.needsSemicolon = saved
+}
+
+// terminated reports whether a statement ends in a semicolon or a block,
+// so that nothing following it can continue it
+func (c *jsCompressor) terminated(n jsNode) bool {
+	if c.src[nodeEnd(n)-1] == ';' {
+		return true
+	}
+	switch n := n.(type) {
+	case *jsBlock, *jsFunction, *jsClass, *jsTry, *jsSwitch:
+		return true
+	case *jsIf:
+		if n.alt != nil {
+			return c.terminated(n.alt)
+		}
+		return c.terminated(n.cons)
+	case *jsFor:
+		return c.terminated(n.body)
+	case *jsForIn:
+		return c.terminated(n.body)
+	case *jsWhile:
+		return c.terminated(n.body)
+	case *jsLabeled:
+		return c.terminated(n.body)
+	case *jsWith:
+		return c.terminated(n.body)
+	case *jsExport:
+		switch n.decl.(type) {
+		case *jsFunction, *jsClass:
+			return true
+		}
+	}
+	return false
+}
+
+// visit simplifies a node. parent is nil for statements in a list.
+func (c *jsCompressor) visit(n, parent jsNode) {
+	switch n := n.(type) {
+	case nil:
+	case *jsBlock:
+		c.statements(n.body)
+	case *jsVarDecl:
+		for _, d := range n.decls {
+			c.visit(d.target, d)
+			c.visit(d.init, d)
+		}
+	case *jsExprStmt:
+		c.visit(n.expr, n)
+	case *jsIf:
+		if !c.simplifyIf(n, parent) {
+			c.visit(n.test, n)
+			c.visit(n.cons, n)
+			c.visit(n.alt, n)
+		}
+	case *jsFor:
+		c.visit(n.init, n)
+		c.visit(n.test, n)
+		c.visit(n.update, n)
+		c.visit(n.body, n)
+	case *jsForIn:
+		c.visit(n.left, n)
+		c.visit(n.right, n)
+		c.visit(n.body, n)
+	case *jsWhile:
+		if value, ok := jsEvaluate(n.test); ok && !value.truthy() && !jsDeclares(n.body) {
+			c.removeStatement(n, parent)
+			return
+		}
+		c.visit(n.test, n)
+		c.visit(n.body, n)
+	case *jsDoWhile:
+		c.visit(n.body, n)
+		c.visit(n.test, n)
+	case *jsReturn:
+		c.visit(n.arg, n)
+	case *jsThrow:
+		c.visit(n.arg, n)
+	case *jsTry:
+		c.visit(n.block, n)
+		c.visit(n.param, n)
+		if n.handler != nil {
+			c.visit(n.handler, n)
+		}
+		if n.finalizer != nil {
+			c.visit(n.finalizer, n)
+		}
+	case *jsSwitch:
+		c.visit(n.disc, n)
+		for _, sc := range n.cases {
+			c.visit(sc.test, sc)
+			c.statements(sc.body)
+		}
+	case *jsLabeled:
+		c.visit(n.body, n)
+	case *jsWith:
+		c.visit(n.object, n)
+		c.visit(n.body, n)
+	case *jsExport:
+		c.visit(n.decl, n)
+	case *jsFunction:
+		for _, p := range n.params {
+			c.visit(p.pattern, p)
+			c.visit(p.init, p)
+		}
+		if n.body != nil {
+			c.statements(n.body.body)
+		}
+		c.visit(n.exprBody, n)
+	case *jsClass:
+		c.visit(n.superClass, n)
+		for _, m := range n.members {
+			switch m := m.(type) {
+			case *jsMethod:
+				if m.computed {
+					c.visit(m.key, m)
+				}
+				c.visit(m.fn, m)
+			case *jsField:
+				if m.computed {
+					c.visit(m.key, m)
+				}
+				c.visit(m.value, m)
+			case *jsStaticBlock:
+				c.statements(m.body)
+			}
+		}
+	case *jsConditional:
+		if !c.simplifyConditional(n, parent) {
+			c.visit(n.test, n)
+			c.visit(n.cons, n)
+			c.visit(n.alt, n)
+		}
+	case *jsLiteral, *jsBinary, *jsUnary, *jsParen:
+		if c.fold(n, parent) {
+			return
+		}
+		switch n := n.(type) {
+		case *jsBinary:
+			c.visit(n.left, n)
+			c.visit(n.right, n)
+		case *jsUnary:
+			c.visit(n.arg, n)
+		case *jsParen:
+			c.visit(n.expr, n)
+		}
+	case *jsTemplateLiteral:
+		c.visit(n.tag, n)
+		for _, e := range n.exprs {
+			c.visit(e, n)
+		}
+	case *jsArray:
+		for _, e := range n.elems {
+			c.visit(e, n)
+		}
+	case *jsObject:
+		for _, p := range n.props {
+			c.visit(p, n)
+		}
+	case *jsProperty:
+		if n.computed {
+			c.visit(n.key, n)
+		}
+		if n.fn != nil {
+			c.visit(n.fn, n)
+		} else if !n.shorthand {
+			c.visit(n.value, n)
+		} else if def, ok := n.value.(*jsBinary); ok {
+			c.visit(def.right, def)
+		}
+	case *jsSpread:
+		c.visit(n.arg, n)
+	case *jsUpdate:
+		c.visit(n.arg, n)
+	case *jsCall:
+		c.visit(n.callee, n)
+		for _, arg := range n.args {
+			c.visit(arg, n)
+		}
+	case *jsMember:
+		c.visit(n.object, n)
+		if n.computed {
+			c.visit(n.property, n)
+		}
+	case *jsSequence:
+		for _, e := range n.exprs {
+			c.visit(e, n)
+		}
+	case *jsYield:
+		c.visit(n.arg, n)
+	case *jsAwait:
+		c.visit(n.arg, n)
+	}
+}
+
+// removeStatement drops a statement, leaving an empty statement where the
+// grammar needs one
+func (c *jsCompressor) removeStatement(n, parent jsNode) {
+	if parent != nil {
+		c.replace(n, ";")
+		return
+	}
+	c.replace(n, c.separator())
+}
+
+// simplifyIf keeps only the branch of an if statement its constant test
+// selects. Branches declaring hoisted names are kept, as are branches
+// that are themselves if statements, which could capture an else.
+func (c *jsCompressor) simplifyIf(n *jsIf, parent jsNode) bool {
+	value, ok := jsEvaluate(n.test)
+	if !ok {
+		return false
+	}
+	keep, drop := n.cons, n.alt
+	if !value.truthy() {
+		keep, drop = drop, keep
+	}
+	if drop != nil && jsDeclares(drop) {
+		return false
+	}
+	switch k := keep.(type) {
+	case nil:
+		c.removeStatement(n, parent)
+		return true
+	case *jsIf, *jsFunction, *jsClass:
+		return false
+	case *jsVarDecl:
+		if k.kind != "var" {
+			return false
+		}
+	}
+
+	prefix := ""
+	if parent == nil {
+		prefix = c.separator()
+	}
+	c.cut(n.start, nodeStart(keep), prefix)
+	if end := nodeEnd(keep); end < n.end {
+		c.cut(end, n.end, "")
+	}
+	c.visit(keep, parent)
+	return true
+}
+
+// simplifyConditional keeps only the branch of a conditional expression
+// its constant test selects
+func (c *jsCompressor) simplifyConditional(n *jsConditional, parent jsNode) bool {
+	value, ok := jsEvaluate(n.test)
+	if !ok {
+		return false
+	}
+	keep := n.cons
+	if !value.truthy() {
+		keep = n.alt
+	}
+	// The branch may end up at the start of a statement or an arrow body
+	switch parent.(type) {
+	case *jsExprStmt, *jsFunction, *jsSequence, *jsExport:
+		if jsAmbiguousStart(c.src[nodeStart(keep):]) {
+			return false
+		}
+	}
+	c.cut(n.start, nodeStart(keep), "")
+	c.cut(nodeEnd(keep), n.end, "")
+	c.visit(keep, parent)
+	return true
+}
+
+// jsAmbiguousStart reports whether code starting with text would be read
+// as a block or a declaration at the start of a statement
+func jsAmbiguousStart(text string) bool {
+	if strings.HasPrefix(text, "{") {
+		return true
+	}
+	for _, word := range []string{"function", "class", "let", "async"} {
+		if strings.HasPrefix(text, word) {
+			next, _ := utf8.DecodeRuneInString(text[len(word):])
+			if !isJSIdentifierPart(next) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fold replaces a constant expression with the shortest literal of its
+// value
+func (c *jsCompressor) fold(n, parent jsNode) bool {
+	value, ok := jsEvaluate(n)
+	if !ok {
+		return false
+	}
+	if _, statement := parent.(*jsExprStmt); statement && value.kind == jsStringValue {
+		// A string statement could become a directive such as "use strict"
+		return false
+	}
+	text := value.literal()
+	if text == "" || !jsLiteralFits(text, n, parent) {
+		return false
+	}
+	start, end := n.span()
+	if len(text) >= end-start {
+		return false
+	}
+	c.replace(n, text)
+	return true
+}
+
+// jsLiteralFits reports whether a literal can stand in for n in parent.
+// Literals starting with ! or - are unary expressions, which bind less
+// tightly than member access, calls and the left of **.
+func jsLiteralFits(text string, n, parent jsNode) bool {
+	if text[0] != '!' && text[0] != '-' {
+		return true
+	}
+	switch p := parent.(type) {
+	case *jsMember:
+		return p.object != n
+	case *jsCall:
+		return p.callee != n
+	case *jsTemplateLiteral:
+		return p.tag != n
+	case *jsBinary:
+		return p.op != "**" || p.left != n
+	}
+	return true
+}
+
+// jsDeclares reports whether a statement declares a name outside of its
+// own blocks: a var, which is hoisted to the function, or a declaration
+// standing directly in the list
+func jsDeclares(n jsNode) bool {
+	switch n := n.(type) {
+	case *jsVarDecl, *jsFunction, *jsClass, *jsImport, *jsExport:
+		return true
+	case *jsLabeled:
+		return jsDeclares(n.body)
+	}
+	return jsDeclaresVar(n)
+}
+
+// jsDeclaresVar reports whether a statement contains a var declaration or
+// a function declaration, which sloppy mode code hoists out of blocks
+func jsDeclaresVar(n jsNode) bool {
+	switch n := n.(type) {
+	case *jsVarDecl:
+		return n.kind == "var"
+	case *jsFunction:
+		return true
+	case *jsBlock:
+		return jsAnyDeclaresVar(n.body)
+	case *jsIf:
+		return jsDeclaresVar(n.cons) || n.alt != nil && jsDeclaresVar(n.alt)
+	case *jsFor:
+		return n.init != nil && jsDeclaresVar(n.init) || jsDeclaresVar(n.body)
+	case *jsForIn:
+		return jsDeclaresVar(n.left) || jsDeclaresVar(n.body)
+	case *jsWhile:
+		return jsDeclaresVar(n.body)
+	case *jsDoWhile:
+		return jsDeclaresVar(n.body)
+	case *jsTry:
+		return jsDeclaresVar(n.block) || n.handler != nil && jsDeclaresVar(n.handler) ||
+			n.finalizer != nil && jsDeclaresVar(n.finalizer)
+	case *jsSwitch:
+		for _, sc := range n.cases {
+			if jsAnyDeclaresVar(sc.body) {
+				return true
+			}
+		}
+	case *jsLabeled:
+		return jsDeclaresVar(n.body)
+	case *jsWith:
+		return jsDeclaresVar(n.body)
+	}
+	return false
+}
+
+func jsAnyDeclaresVar(list []jsNode) bool {
+	for _, n := range list {
+		if jsDeclaresVar(n) {
+			return true
+		}
+	}
+	return false
+}
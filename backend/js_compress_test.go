@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestCompressJavaScript(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		compress int
+		reserved []string
+		want     string
+	}{
+		{"whitespace only", "var a = 1 + 2 * 3; var b = true;", compressWhitespace, nil, "var a=1+2*3;var b=true;"},
+		{"constants", "var a = 1 + 2 * 3; let s = 'a' + \"b\" + 1; let big = 2 ** 10;", compressSimplify, nil, "var a=7;let s=\"ab1\";let big=1024;"},
+		{"booleans", "var b = true; let n = !true;", compressSimplify, nil, "var b=!0;let n=!1;"},
+		{"unreachable code", "function f() { return 1; console.log('dead'); }\nf();", compressSimplify, nil, "function f(){return 1;}\nf();"},
+		{"code after break", "function f(){ while(x){ break; y() } }", compressSimplify, nil, "function f(){while(x){break;}}"},
+		{"constant conditions", "if (true) { a() } else { b() }\nif (false) c();\nx = 0 ? y : z;", compressSimplify, nil, "{a()}\nx=z;"},
+		{"unused local function", "function o(){ function unused(){} function used(){return 2} return used() }", compressSimplify, nil, "function o(){function used(){return 2}return used()}"},
+		{"global function kept", "function unused() {}\nf();", compressSimplify, nil, "function unused(){}\nf();"},
+		{"no renaming at 1", "function f(x) { var keep = x; return keep; }", compressSimplify, nil, "function f(x){var keep=x;return keep;}"},
+		{"renaming", "var g = 1; function f(x) { var keep = x; return keep; } f(g);", compressMangle, nil, "var g=1;function f(a){var b=a;return b;}f(g);"},
+		{"local function renamed", "function o(){ function used(){return 2} return used() }", compressMangle, nil, "function o(){function a(){return 2}return a()}"},
+		{"shadowing", "function f(a){ let b=1; { let b=2; a+=b } return a+b }", compressMangle, nil, "function f(a){let b=1;{let c=2;a+=c}return a+b}"},
+		{"globals used inside", "const o = { a: 1 }; function h(value){ return o.a + value }", compressMangle, nil, "const o={a:1};function h(a){return o.a+a}"},
+		{"exports kept", "export function f(param) { const v = param; return v }", compressMangle, nil, "export function f(a){const b=a;return b}"},
+		{"eval", "function f(x) { eval('x'); var y = x; return y; }", compressMangle, nil, "function f(x){eval('x');var y=x;return y;}"},
+		{
+			"reserved", "function outer(longName, other) { var local = longName + other; return local }", compressMangle, []string{"other"},
+			"function outer(a,other){var b=a+other;return b}",
+		},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &FormatOptions{Compress: intPtr(tt.compress), Reserved: tt.reserved}
+			got, err := f.MinifyWithOptions(tt.code, "javascript", opts)
+			if err != nil {
+				t.Fatalf("MinifyWithOptions(%q) error: %v", tt.code, err)
+			}
+			if got.Code != tt.want {
+				t.Errorf("MinifyWithOptions(%q) = %q, want %q", tt.code, got.Code, tt.want)
+			}
+			if _, _, err := parseJS(got.Code, jsDialect{}); err != nil {
+				t.Errorf("MinifyWithOptions(%q) = %q, which does not parse: %v", tt.code, got.Code, err)
+			}
+		})
+	}
+}
+
+func TestCompressJavaScriptRuns(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	programs := []string{
+		"function outer(longName, other) { var local = longName + other; return function () { return local * 2; }; }\nconsole.log(outer(1, 2)());",
+		"function f(a) { let b = 1; { let b = 2; a += b } return a + b }\nconsole.log(f(1), 1 + 2 * 3, 'a' + \"b\" + 1, 2 ** 10, !true);",
+		"function g(n) { if (false) return 0; const out = []; for (let i = 0; i < n; i++) { out.push(i % 2 ? 'odd' : 'even') } return out.join() }\nconsole.log(g(4));",
+		"var count = 0\nfunction inc() { function unused() { count = -1 } return ++count }\ninc()\nconsole.log(inc(), typeof unused)",
+	}
+
+	f := NewFormatter()
+	for _, program := range programs {
+		want := runNode(t, node, program)
+		for _, level := range []int{compressSimplify, compressMangle} {
+			minified, err := f.MinifyWithOptions(program, "javascript", &FormatOptions{Compress: intPtr(level)})
+			if err != nil {
+				t.Fatalf("MinifyWithOptions(%q) error: %v", program, err)
+			}
+			if got := runNode(t, node, minified.Code); got != want {
+				t.Errorf("compress %d: %q prints %q, want %q", level, minified.Code, got, want)
+			}
+		}
+	}
+}
+
+func runNode(t *testing.T, node, program string) string {
+	t.Helper()
+	out, err := exec.Command(node, "-e", program).CombinedOutput()
+	if err != nil {
+		t.Fatalf("node -e %q: %v\n%s", program, err, out)
+	}
+	return string(out)
+}
+
+func TestCompressJavaScriptErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		opts *FormatOptions
+		want string
+	}{
+		{"function f( {", &FormatOptions{Compress: intPtr(compressSimplify)}, "1:14: unexpected end of input"},
+		{"x", &FormatOptions{Compress: intPtr(3)}, "invalid options: compress must be between 0 and 2"},
+		{"x", &FormatOptions{Reserved: []string{"1a"}}, `invalid options: reserved entry "1a" is not an identifier`},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		_, err := f.MinifyWithOptions(tt.code, "javascript", tt.opts)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("MinifyWithOptions(%q) error = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+}
+
+func TestCompressTypeScriptIgnored(t *testing.T) {
+	f := NewFormatter()
+	got, err := f.MinifyWithOptions("let a: number = 1+2", "typescript", &FormatOptions{Compress: intPtr(compressMangle)})
+	if err != nil {
+		t.Fatalf("MinifyWithOptions error: %v", err)
+	}
+	if got.Code != "let a:number=1+2" || len(got.IgnoredOptions) != 1 || got.IgnoredOptions[0] != optionCompress {
+		t.Errorf("MinifyWithOptions = %q ignoring %q, want it uncompressed ignoring compress", got.Code, got.IgnoredOptions)
+	}
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Kinds of constant values
+const (
+	jsNumberValue = iota
+	jsStringValue
+	jsBooleanValue
+	jsNullValue
+)
+
+// jsValue is the value of a constant expression
+type jsValue struct {
+	kind    int
+	number  float64
+	str     string
+	boolean bool
+}
+
+// jsEvaluate computes the value of an expression made only of literals,
+// reporting false for anything else or anything it cannot compute
+// exactly as JavaScript would
+func jsEvaluate(n jsNode) (jsValue, bool) {
+	switch n := n.(type) {
+	case *jsLiteral:
+		switch n.kind {
+		case jsNumber:
+			f, ok := jsParseNumber(n.raw)
+			return jsValue{kind: jsNumberValue, number: f}, ok
+		case jsString:
+			s, ok := jsParseString(n.raw)
+			return jsValue{kind: jsStringValue, str: s}, ok
+		}
+		switch n.raw {
+		case "true", "false":
+			return jsValue{kind: jsBooleanValue, boolean: n.raw == "true"}, true
+		case "null":
+			return jsValue{kind: jsNullValue}, true
+		}
+	case *jsParen:
+		return jsEvaluate(n.expr)
+	case *jsUnary:
+		arg, ok := jsEvaluate(n.arg)
+		if !ok {
+			return jsValue{}, false
+		}
+		switch n.op {
+		case "!":
+			return jsValue{kind: jsBooleanValue, boolean: !arg.truthy()}, true
+		case "-":
+			return jsValue{kind: jsNumberValue, number: -arg.toNumber()}, true
+		case "+":
+			return jsValue{kind: jsNumberValue, number: arg.toNumber()}, true
+		case "~":
+			return jsValue{kind: jsNumberValue, number: float64(^jsToInt32(arg.toNumber()))}, true
+		case "typeof":
+			types := map[int]string{jsNumberValue: "number", jsStringValue: "string", jsBooleanValue: "boolean", jsNullValue: "object"}
+			return jsValue{kind: jsStringValue, str: types[arg.kind]}, true
+		}
+	case *jsBinary:
+		left, ok := jsEvaluate(n.left)
+		if !ok {
+			return jsValue{}, false
+		}
+		right, ok := jsEvaluate(n.right)
+		if !ok {
+			return jsValue{}, false
+		}
+		return jsBinaryValue(n.op, left, right)
+	}
+	return jsValue{}, false
+}
+
+func jsBinaryValue(op string, left, right jsValue) (jsValue, bool) {
+	number := func(f float64) (jsValue, bool) {
+		return jsValue{kind: jsNumberValue, number: f}, true
+	}
+	boolean := func(b bool) (jsValue, bool) {
+		return jsValue{kind: jsBooleanValue, boolean: b}, true
+	}
+	l, r := left.toNumber(), right.toNumber()
+
+	switch op {
+	case "+":
+		if left.kind == jsStringValue || right.kind == jsStringValue {
+			return jsValue{kind: jsStringValue, str: left.toString() + right.toString()}, true
+		}
+		return number(l + r)
+	case "-":
+		return number(l - r)
+	case "*":
+		return number(l * r)
+	case "/":
+		return number(l / r)
+	case "%":
+		return number(math.Mod(l, r))
+	case "**":
+		// Go's Pow returns 1 where JavaScript returns NaN
+		if math.IsNaN(r) || math.Abs(l) == 1 && math.IsInf(r, 0) {
+			return number(math.NaN())
+		}
+		return number(math.Pow(l, r))
+	case "&":
+		return number(float64(jsToInt32(l) & jsToInt32(r)))
+	case "|":
+		return number(float64(jsToInt32(l) | jsToInt32(r)))
+	case "^":
+		return number(float64(jsToInt32(l) ^ jsToInt32(r)))
+	case "<<":
+		return number(float64(jsToInt32(l) << (uint32(jsToInt32(r)) & 31)))
+	case ">>":
+		return number(float64(jsToInt32(l) >> (uint32(jsToInt32(r)) & 31)))
+	case ">>>":
+		return number(float64(uint32(jsToInt32(l)) >> (uint32(jsToInt32(r)) & 31)))
+	case "<", ">", "<=", ">=":
+		if left.kind == jsStringValue && right.kind == jsStringValue {
+			cmp := jsCompareStrings(left.str, right.str)
+			switch op {
+			case "<":
+				return boolean(cmp < 0)
+			case ">":
+				return boolean(cmp > 0)
+			case "<=":
+				return boolean(cmp <= 0)
+			}
+			return boolean(cmp >= 0)
+		}
+		switch op {
+		case "<":
+			return boolean(l < r)
+		case ">":
+			return boolean(l > r)
+		case "<=":
+			return boolean(l <= r)
+		}
+		return boolean(l >= r)
+	case "===":
+		return boolean(jsStrictEquals(left, right))
+	case "!==":
+		return boolean(!jsStrictEquals(left, right))
+	case "==":
+		return boolean(jsLooseEquals(left, right))
+	case "!=":
+		return boolean(!jsLooseEquals(left, right))
+	case "&&":
+		if left.truthy() {
+			return right, true
+		}
+		return left, true
+	case "||":
+		if left.truthy() {
+			return left, true
+		}
+		return right, true
+	case "??":
+		if left.kind == jsNullValue {
+			return right, true
+		}
+		return left, true
+	}
+	return jsValue{}, false
+}
+
+func jsStrictEquals(left, right jsValue) bool {
+	if left.kind != right.kind {
+		return false
+	}
+	switch left.kind {
+	case jsNumberValue:
+		return left.number == right.number
+	case jsStringValue:
+		return left.str == right.str
+	case jsBooleanValue:
+		return left.boolean == right.boolean
+	}
+	return true
+}
+
+func jsLooseEquals(left, right jsValue) bool {
+	switch {
+	case left.kind == right.kind:
+		return jsStrictEquals(left, right)
+	case left.kind == jsNullValue || right.kind == jsNullValue:
+		return false
+	}
+	// Strings and booleans are compared as numbers
+	return left.toNumber() == right.toNumber()
+}
+
+// jsCompareStrings compares strings by UTF-16 code units, as JavaScript
+// does
+func jsCompareStrings(a, b string) int {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return int(ua[i]) - int(ub[i])
+		}
+	}
+	return len(ua) - len(ub)
+}
+
+func (v jsValue) truthy() bool {
+	switch v.kind {
+	case jsNumberValue:
+		return v.number != 0 && !math.IsNaN(v.number)
+	case jsStringValue:
+		return v.str != ""
+	case jsBooleanValue:
+		return v.boolean
+	}
+	return false
+}
+
+func (v jsValue) toNumber() float64 {
+	switch v.kind {
+	case jsNumberValue:
+		return v.number
+	case jsStringValue:
+		return jsStringToNumber(v.str)
+	case jsBooleanValue:
+		if v.boolean {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (v jsValue) toString() string {
+	switch v.kind {
+	case jsNumberValue:
+		return jsNumberString(v.number)
+	case jsStringValue:
+		return v.str
+	case jsBooleanValue:
+		return strconv.FormatBool(v.boolean)
+	}
+	return "null"
+}
+
+// literal returns the shortest source text of the value, or "" when it
+// has none, as for NaN and Infinity, which are global names
+func (v jsValue) literal() string {
+	switch v.kind {
+	case jsNumberValue:
+		return jsNumberLiteral(v.number)
+	case jsStringValue:
+		return jsQuoteString(v.str)
+	case jsBooleanValue:
+		if v.boolean {
+			return "!0"
+		}
+		return "!1"
+	}
+	return "null"
+}
+
+// jsToInt32 converts a number as the bitwise operators do
+func jsToInt32(f float64) int32 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0
+	}
+	f = math.Mod(math.Trunc(f), 1<<32)
+	if f < 0 {
+		f += 1 << 32
+	}
+	return int32(uint32(f))
+}
+
+var jsDecimalPattern = regexp.MustCompile(`^[+-]?(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][+-]?[0-9]+)?$`)
+
+// jsStringToNumber converts a string as Number() does
+func jsStringToNumber(s string) float64 {
+	s = strings.TrimFunc(s, func(r rune) bool {
+		return isJSWhitespace(r) || isJSLineTerminator(r) || r == '\t' || r == '\v' || r == '\f'
+	})
+	switch s {
+	case "":
+		return 0
+	case "Infinity", "+Infinity":
+		return math.Inf(1)
+	case "-Infinity":
+		return math.Inf(-1)
+	}
+	if len(s) > 2 && s[0] == '0' {
+		base := map[byte]int{'x': 16, 'X': 16, 'o': 8, 'O': 8, 'b': 2, 'B': 2}[s[1]]
+		if base != 0 {
+			if n, err := strconv.ParseUint(s[2:], base, 64); err == nil {
+				return float64(n)
+			}
+			return math.NaN()
+		}
+	}
+	if !jsDecimalPattern.MatchString(s) {
+		return math.NaN()
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// jsParseNumber reads a number literal. Legacy octal literals, BigInts and
+// hexadecimal literals beyond 64 bits are not read.
+func jsParseNumber(raw string) (float64, bool) {
+	raw = strings.ReplaceAll(raw, "_", "")
+	if strings.HasSuffix(raw, "n") {
+		return 0, false
+	}
+	if len(raw) > 1 && raw[0] == '0' {
+		base := 0
+		switch raw[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		case '.', 'e', 'E':
+		default:
+			return 0, false
+		}
+		if base != 0 {
+			n, err := strconv.ParseUint(raw[2:], base, 64)
+			return float64(n), err == nil
+		}
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil && !math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
+
+// jsNumberString converts a number to a string as JavaScript's
+// Number.prototype.toString does
+func jsNumberString(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case f == 0:
+		return "0"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case f < 0:
+		return "-" + jsNumberString(-f)
+	}
+	digits, n := jsDigits(f)
+	k := len(digits)
+	switch {
+	case k <= n && n <= 21:
+		return digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		return digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		return "0." + strings.Repeat("0", -n) + digits
+	}
+	exponent := strconv.Itoa(n - 1)
+	if n-1 >= 0 {
+		exponent = "+" + exponent
+	}
+	if k == 1 {
+		return digits + "e" + exponent
+	}
+	return digits[:1] + "." + digits[1:] + "e" + exponent
+}
+
+// jsDigits returns the shortest digits identifying a positive number and
+// the position of the decimal point relative to them: f is 0.digits*10^n
+func jsDigits(f float64) (string, int) {
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exponent, _ := strings.Cut(s, "e")
+	e, _ := strconv.Atoi(exponent)
+	return strings.Replace(mantissa, ".", "", 1), e + 1
+}
+
+// jsNumberLiteral returns the shortest literal of a number, or "" for NaN
+// and the infinities
+func jsNumberLiteral(f float64) string {
+	switch {
+	case math.IsNaN(f), math.IsInf(f, 0):
+		return ""
+	case math.Signbit(f):
+		return "-" + jsNumberLiteral(-f)
+	case f == 0:
+		return "0"
+	}
+	text := jsNumberString(f)
+	text = strings.Replace(text, "e+", "e", 1)
+	if strings.HasPrefix(text, "0.") {
+		text = text[1:]
+	}
+	digits, n := jsDigits(f)
+	if exponent := n - len(digits); exponent != 0 {
+		if scientific := digits + "e" + strconv.Itoa(exponent); len(scientific) < len(text) {
+			text = scientific
+		}
+	}
+	return text
+}
+
+// jsParseString reads the value of a string literal. Legacy octal escapes
+// and lone surrogates are not read.
+func jsParseString(raw string) (string, bool) {
+	body := raw[1 : len(raw)-1]
+	if !strings.Contains(body, `\`) {
+		return body, true
+	}
+	var units []uint16
+	for i := 0; i < len(body); {
+		r, size := utf8.DecodeRuneInString(body[i:])
+		i += size
+		if r != '\\' {
+			units = utf16.AppendRune(units, r)
+			continue
+		}
+		r, size = utf8.DecodeRuneInString(body[i:])
+		i += size
+		switch r {
+		case 'n':
+			units = append(units, '\n')
+		case 't':
+			units = append(units, '\t')
+		case 'r':
+			units = append(units, '\r')
+		case 'b':
+			units = append(units, '\b')
+		case 'f':
+			units = append(units, '\f')
+		case 'v':
+			units = append(units, '\v')
+		case '0':
+			if i < len(body) && isDigit(rune(body[i])) {
+				return "", false
+			}
+			units = append(units, 0)
+		case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			return "", false
+		case 'x':
+			if i+2 > len(body) {
+				return "", false
+			}
+			n, err := strconv.ParseUint(body[i:i+2], 16, 8)
+			if err != nil {
+				return "", false
+			}
+			units = append(units, uint16(n))
+			i += 2
+		case 'u':
+			digits := ""
+			if strings.HasPrefix(body[i:], "{") {
+				end := strings.IndexByte(body[i:], '}')
+				if end < 0 {
+					return "", false
+				}
+				digits, i = body[i+1:i+end], i+end+1
+			} else if i+4 <= len(body) {
+				digits, i = body[i:i+4], i+4
+			}
+			n, err := strconv.ParseUint(digits, 16, 32)
+			if err != nil || n > utf8.MaxRune {
+				return "", false
+			}
+			units = utf16.AppendRune(units, rune(n))
+			if n < 0x10000 {
+				// AppendRune rejects surrogates, which may pair up later
+				units[len(units)-1] = uint16(n)
+			}
+		case '\r':
+			if strings.HasPrefix(body[i:], "\n") {
+				i++
+			}
+		case '\n', '\u2028', '\u2029':
+			// A line continuation adds nothing
+		default:
+			units = utf16.AppendRune(units, r)
+		}
+	}
+	for i := 0; i < len(units); i++ {
+		switch {
+		case utf16.IsSurrogate(rune(units[i])) && units[i] < 0xdc00 && i+1 < len(units) && units[i+1] >= 0xdc00 && units[i+1] < 0xe000:
+			i++
+		case utf16.IsSurrogate(rune(units[i])):
+			return "", false
+		}
+	}
+	return string(utf16.Decode(units)), true
+}
+
+// jsQuoteString writes a string literal, choosing the quote that needs
+// fewer escapes
+func jsQuoteString(s string) string {
+	quote := '"'
+	if strings.Count(s, `"`) > strings.Count(s, "'") {
+		quote = '\''
+	}
+	var b strings.Builder
+	b.WriteRune(quote)
+	for _, r := range s {
+		switch r {
+		case quote, '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\u2028':
+			b.WriteString(`\u2028`)
+		case '\u2029':
+			b.WriteString(`\u2029`)
+		default:
+			if r < 0x20 {
+				b.WriteString(`\x`)
+				b.WriteString(strconv.FormatUint(uint64(r)|0x100, 16)[1:])
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteRune(quote)
+	return b.String()
+}
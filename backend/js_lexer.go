@@ -0,0 +1,620 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type jsTokenKind int
+
+const (
+	jsEOF jsTokenKind = iota
+	jsIdentifier
+	jsKeyword
+	jsPrivateName
+	jsPunctuator
+	jsNumber
+	jsString
+	jsRegExp
+	jsTemplate       // `...` with no substitutions
+	jsTemplateHead   // `...${
+	jsTemplateMiddle // }...${
+	jsTemplateTail   // }...`
+	jsLineComment
+	jsBlockComment
+	jsHashbang
+)
+
+// jsToken is a lexical ECMAScript token. Text is the exact source slice.
+type jsToken struct {
+	kind jsTokenKind
+	text string
+	// start and end are byte offsets into the source
+	start int
+	end   int
+	// newlineBefore is set when a line terminator (including one inside a
+	// comment) separates this token from the previous one
+	newlineBefore bool
+}
+
+func (t jsToken) isComment() bool {
+	return t.kind == jsLineComment || t.kind == jsBlockComment || t.kind == jsHashbang
+}
+
+func (t jsToken) is(kind jsTokenKind, text string) bool {
+	return t.kind == kind && t.text == text
+}
+
+var jsKeywords = map[string]bool{
+	"await": true, "break": true, "case": true, "catch": true, "class": true,
+	"const": true, "continue": true, "debugger": true, "default": true,
+	"delete": true, "do": true, "else": true, "enum": true, "export": true,
+	"extends": true, "false": true, "finally": true, "for": true,
+	"function": true, "if": true, "import": true, "in": true,
+	"instanceof": true, "new": true, "null": true, "return": true,
+	"super": true, "switch": true, "this": true, "throw": true, "true": true,
+	"try": true, "typeof": true, "var": true, "void": true, "while": true,
+	"with": true, "yield": true,
+}
+
+// jsRegexAfterKeyword lists keywords after which a slash starts a regular
+// expression rather than a division
+var jsRegexAfterKeyword = map[string]bool{
+	"await": true, "case": true, "delete": true, "do": true, "else": true,
+	"extends": true, "in": true, "instanceof": true, "new": true, "of": true,
+	"return": true, "throw": true, "typeof": true, "void": true, "yield": true,
+}
+
+// jsPunctuators is ordered longest first so scanning takes the longest match
+var jsPunctuators = []string{
+	">>>=",
+	"...", "===", "!==", "**=", "<<=", ">>=", ">>>", "&&=", "||=", "??=",
+	"=>", "==", "!=", "<=", ">=", "&&", "||", "??", "?.", "++", "--",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<", ">>", "**",
+	"{", "}", "(", ")", "[", "]", ";", ",", "<", ">", "+", "-", "*", "/",
+	"%", "&", "|", "^", "!", "~", "?", ":", "=", ".", "@",
+}
+
+type jsBraceKind int
+
+const (
+	jsBraceBlock jsBraceKind = iota
+	jsBraceExpression
+	jsBraceTemplate
+)
+
+// jsLexer tokenizes ECMAScript 2022 source. Whether a slash begins a
+// regular expression is decided from the preceding tokens, tracking
+// parenthesis and brace context the way the grammar would.
+type jsLexer struct {
+	src string
+	pos int
+
+	prev        jsToken // last significant (non-comment) token
+	beforePrev  jsToken // significant token before prev
+	parens      []bool  // per open '(': whether it closes a control header
+	braces      []jsBraceKind
+	closedBrace jsBraceKind // kind of the brace closed by prev when prev is '}'
+	closedParen bool        // whether prev ')' closed a control header
+}
+
+func newJSLexer(src string) *jsLexer {
+	return &jsLexer{src: src}
+}
+
+// tokenizeJS lexes a whole program, comments included
+func tokenizeJS(src string) ([]jsToken, error) {
+	lx := newJSLexer(src)
+	var tokens []jsToken
+	for {
+		tok, err := lx.next()
+		if err != nil {
+			return nil, err
+		}
+		if tok.kind == jsEOF {
+			return tokens, nil
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+func (lx *jsLexer) errorf(offset int, format string, args ...interface{}) error {
+	return newSyntaxError(syntaxJS, lx.src, offset, format, args...)
+}
+
+// lineColumn converts a byte offset to a 1-based line and column
+func lineColumn(src string, offset int) (int, int) {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	line := 1 + strings.Count(src[:offset], "\n")
+	lineStart := strings.LastIndexByte(src[:offset], '\n') + 1
+	return line, utf8.RuneCountInString(src[lineStart:offset]) + 1
+}
+
+func isJSLineTerminator(r rune) bool {
+	return r == '\n' || r == '\r' || r == '\u2028' || r == '\u2029'
+}
+
+func isJSWhitespace(r rune) bool {
+	switch r {
+	case ' ', '\t', '\v', '\f', '\u00a0', '\ufeff':
+		return true
+	}
+	return r > 0x7f && unicode.Is(unicode.Zs, r)
+}
+
+func isJSIdentifierStart(r rune) bool {
+	return r == '$' || r == '_' || r == '\\' ||
+		(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+		(r > 0x7f && (unicode.IsLetter(r) || unicode.Is(unicode.Nl, r)))
+}
+
+func isJSIdentifierPart(r rune) bool {
+	return isJSIdentifierStart(r) || (r >= '0' && r <= '9') || r == '\u200c' || r == '\u200d' ||
+		(r > 0x7f && (unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r) ||
+			unicode.Is(unicode.Nd, r) || unicode.Is(unicode.Pc, r)))
+}
+
+func (lx *jsLexer) peekRune(offset int) rune {
+	if lx.pos+offset >= len(lx.src) {
+		return -1
+	}
+	r, _ := utf8.DecodeRuneInString(lx.src[lx.pos+offset:])
+	return r
+}
+
+// skipWhitespace skips whitespace and reports whether a line terminator was seen
+func (lx *jsLexer) skipWhitespace() bool {
+	newline := false
+	for lx.pos < len(lx.src) {
+		r, size := utf8.DecodeRuneInString(lx.src[lx.pos:])
+		switch {
+		case isJSLineTerminator(r):
+			newline = true
+		case isJSWhitespace(r):
+		default:
+			return newline
+		}
+		lx.pos += size
+	}
+	return newline
+}
+
+// regexAllowed reports whether a slash at the current position starts a
+// regular expression literal
+func (lx *jsLexer) regexAllowed() bool {
+	prev := lx.prev
+	switch prev.kind {
+	case jsEOF:
+		return true
+	case jsNumber, jsString, jsRegExp, jsTemplate, jsTemplateTail, jsPrivateName:
+		return false
+	case jsIdentifier:
+		return prev.text == "of" || prev.text == "yield" || prev.text == "await"
+	case jsKeyword:
+		// obj.return / 2 uses a keyword as a property name
+		if lx.beforePrev.is(jsPunctuator, ".") || lx.beforePrev.is(jsPunctuator, "?.") {
+			return false
+		}
+		return jsRegexAfterKeyword[prev.text]
+	case jsPunctuator:
+		switch prev.text {
+		case ")":
+			return lx.closedParen
+		case "]", "++", "--":
+			return false
+		case "}":
+			return lx.closedBrace == jsBraceBlock
+		}
+		return true
+	}
+	return true
+}
+
+// braceKindAfter guesses whether a '{' following the previous token opens a
+// block (statement position) or an object literal (expression position)
+func (lx *jsLexer) braceKindAfter() jsBraceKind {
+	prev := lx.prev
+	switch prev.kind {
+	case jsEOF:
+		return jsBraceBlock
+	case jsKeyword:
+		switch prev.text {
+		case "return", "typeof", "instanceof", "in", "new", "delete", "void",
+			"throw", "case", "yield", "await":
+			return jsBraceExpression
+		}
+		return jsBraceBlock
+	case jsIdentifier, jsNumber, jsString, jsTemplate, jsTemplateTail, jsRegExp, jsPrivateName:
+		// class Foo {, extends Bar {, get x() {...}
+		return jsBraceBlock
+	case jsPunctuator:
+		switch prev.text {
+		case ";", "{", "}", ")", "=>":
+			return jsBraceBlock
+		case ":":
+			if n := len(lx.braces); n > 0 && lx.braces[n-1] == jsBraceExpression {
+				return jsBraceExpression
+			}
+			return jsBraceBlock
+		}
+		return jsBraceExpression
+	}
+	return jsBraceExpression
+}
+
+// next returns the next token, including comments
+func (lx *jsLexer) next() (jsToken, error) {
+	newline := lx.skipWhitespace()
+	start := lx.pos
+
+	if lx.pos >= len(lx.src) {
+		return jsToken{kind: jsEOF, start: start, end: start, newlineBefore: newline}, nil
+	}
+
+	tok, err := lx.scan()
+	if err != nil {
+		return tok, err
+	}
+	tok.start = start
+	tok.end = lx.pos
+	tok.text = lx.src[start:lx.pos]
+	tok.newlineBefore = newline
+
+	if !tok.isComment() {
+		lx.track(tok)
+	}
+	return tok, nil
+}
+
+// track updates the regex/brace context after a significant token
+func (lx *jsLexer) track(tok jsToken) {
+	lx.closedParen = false
+	lx.closedBrace = jsBraceBlock
+
+	switch {
+	case tok.kind == jsPunctuator && tok.text == "(":
+		control := lx.prev.kind == jsKeyword &&
+			(lx.prev.text == "if" || lx.prev.text == "while" || lx.prev.text == "for" || lx.prev.text == "with")
+		lx.parens = append(lx.parens, control)
+	case tok.kind == jsPunctuator && tok.text == ")":
+		if n := len(lx.parens); n > 0 {
+			lx.closedParen = lx.parens[n-1]
+			lx.parens = lx.parens[:n-1]
+		}
+	case tok.kind == jsPunctuator && tok.text == "{":
+		lx.braces = append(lx.braces, lx.braceKindAfter())
+	case tok.kind == jsTemplateHead:
+		lx.braces = append(lx.braces, jsBraceTemplate)
+	case tok.kind == jsPunctuator && tok.text == "}", tok.kind == jsTemplateTail:
+		if n := len(lx.braces); n > 0 {
+			lx.closedBrace = lx.braces[n-1]
+			lx.braces = lx.braces[:n-1]
+		}
+	}
+
+	lx.beforePrev = lx.prev
+	lx.prev = tok
+}
+
+func (lx *jsLexer) scan() (jsToken, error) {
+	start := lx.pos
+	r, size := utf8.DecodeRuneInString(lx.src[lx.pos:])
+
+	switch {
+	case r == '#' && start == 0 && lx.peekRune(1) == '!':
+		lx.skipToLineEnd()
+		return jsToken{kind: jsHashbang}, nil
+	case r == '/' && lx.peekRune(1) == '/':
+		lx.skipToLineEnd()
+		return jsToken{kind: jsLineComment}, nil
+	case r == '/' && lx.peekRune(1) == '*':
+		end := strings.Index(lx.src[lx.pos+2:], "*/")
+		if end < 0 {
+			return jsToken{}, lx.errorf(start, "unterminated comment")
+		}
+		lx.pos += 2 + end + 2
+		return jsToken{kind: jsBlockComment}, nil
+	case r == '/' && lx.regexAllowed():
+		return lx.scanRegExp()
+	case r == '\'' || r == '"':
+		return lx.scanString(byte(r))
+	case r == '`':
+		lx.pos++
+		return lx.scanTemplate(jsTemplate, jsTemplateHead)
+	case r == '}' && len(lx.braces) > 0 && lx.braces[len(lx.braces)-1] == jsBraceTemplate:
+		lx.pos++
+		return lx.scanTemplate(jsTemplateTail, jsTemplateMiddle)
+	case r >= '0' && r <= '9', r == '.' && isDigit(lx.peekRune(1)):
+		return lx.scanNumber()
+	case r == '#':
+		lx.pos += size
+		if !isJSIdentifierStart(lx.peekRune(0)) {
+			return jsToken{}, lx.errorf(start, "invalid character '#'")
+		}
+		if err := lx.scanIdentifierRest(); err != nil {
+			return jsToken{}, err
+		}
+		return jsToken{kind: jsPrivateName}, nil
+	case isJSIdentifierStart(r):
+		if err := lx.scanIdentifierRest(); err != nil {
+			return jsToken{}, err
+		}
+		if jsKeywords[lx.src[start:lx.pos]] {
+			return jsToken{kind: jsKeyword}, nil
+		}
+		return jsToken{kind: jsIdentifier}, nil
+	}
+
+	for _, p := range jsPunctuators {
+		if strings.HasPrefix(lx.src[lx.pos:], p) {
+			// a?.5:0 is a conditional, not optional chaining
+			if p == "?." && isDigit(lx.peekRune(2)) {
+				continue
+			}
+			lx.pos += len(p)
+			return jsToken{kind: jsPunctuator}, nil
+		}
+	}
+
+	return jsToken{}, lx.errorf(start, "unexpected character %q", r)
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func (lx *jsLexer) skipToLineEnd() {
+	for lx.pos < len(lx.src) {
+		r, size := utf8.DecodeRuneInString(lx.src[lx.pos:])
+		if isJSLineTerminator(r) {
+			return
+		}
+		lx.pos += size
+	}
+}
+
+func (lx *jsLexer) scanIdentifierRest() error {
+	for lx.pos < len(lx.src) {
+		r, size := utf8.DecodeRuneInString(lx.src[lx.pos:])
+		if r == '\\' {
+			// \uXXXX or \u{X...} escape inside an identifier
+			rest := lx.src[lx.pos:]
+			switch {
+			case strings.HasPrefix(rest, "\\u{"):
+				end := strings.IndexByte(rest, '}')
+				if end < 0 || !isHexString(rest[3:end]) || end == 3 {
+					return lx.errorf(lx.pos, "invalid unicode escape in identifier")
+				}
+				lx.pos += end + 1
+			case strings.HasPrefix(rest, "\\u") && len(rest) >= 6 && isHexString(rest[2:6]):
+				lx.pos += 6
+			default:
+				return lx.errorf(lx.pos, "invalid unicode escape in identifier")
+			}
+			continue
+		}
+		if !isJSIdentifierPart(r) {
+			return nil
+		}
+		lx.pos += size
+	}
+	return nil
+}
+
+func (lx *jsLexer) scanString(quote byte) (jsToken, error) {
+	start := lx.pos
+	lx.pos++
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		switch {
+		case c == quote:
+			lx.pos++
+			return jsToken{kind: jsString}, nil
+		case c == '\\':
+			// Skip the escaped character; an escaped line terminator is a
+			// line continuation and CRLF counts as one terminator
+			lx.pos++
+			if strings.HasPrefix(lx.src[lx.pos:], "\r\n") {
+				lx.pos += 2
+			} else if lx.pos < len(lx.src) {
+				_, size := utf8.DecodeRuneInString(lx.src[lx.pos:])
+				lx.pos += size
+			}
+		case c == '\n' || c == '\r':
+			return jsToken{}, lx.errorf(start, "unterminated string literal")
+		default:
+			lx.pos++
+		}
+	}
+	return jsToken{}, lx.errorf(start, "unterminated string literal")
+}
+
+// scanTemplate scans template characters after a backtick or a closing
+// substitution brace, up to the closing backtick or the next "${"
+func (lx *jsLexer) scanTemplate(closed, open jsTokenKind) (jsToken, error) {
+	start := lx.pos - 1
+	for lx.pos < len(lx.src) {
+		switch lx.src[lx.pos] {
+		case '`':
+			lx.pos++
+			return jsToken{kind: closed}, nil
+		case '\\':
+			lx.pos += 2
+		case '$':
+			if lx.pos+1 < len(lx.src) && lx.src[lx.pos+1] == '{' {
+				lx.pos += 2
+				return jsToken{kind: open}, nil
+			}
+			lx.pos++
+		default:
+			lx.pos++
+		}
+	}
+	if lx.pos > len(lx.src) {
+		lx.pos = len(lx.src)
+	}
+	return jsToken{}, lx.errorf(start, "unterminated template literal")
+}
+
+func (lx *jsLexer) scanRegExp() (jsToken, error) {
+	start := lx.pos
+	lx.pos++
+	inClass := false
+	for {
+		if lx.pos >= len(lx.src) {
+			return jsToken{}, lx.errorf(start, "unterminated regular expression")
+		}
+		r, size := utf8.DecodeRuneInString(lx.src[lx.pos:])
+		if isJSLineTerminator(r) {
+			return jsToken{}, lx.errorf(start, "unterminated regular expression")
+		}
+		lx.pos += size
+		switch r {
+		case '\\':
+			if lx.pos < len(lx.src) {
+				r, size := utf8.DecodeRuneInString(lx.src[lx.pos:])
+				if isJSLineTerminator(r) {
+					return jsToken{}, lx.errorf(start, "unterminated regular expression")
+				}
+				lx.pos += size
+			}
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '/':
+			if !inClass {
+				// flags
+				for lx.pos < len(lx.src) {
+					r, size := utf8.DecodeRuneInString(lx.src[lx.pos:])
+					if !isJSIdentifierPart(r) || r == '\\' {
+						break
+					}
+					lx.pos += size
+				}
+				return jsToken{kind: jsRegExp}, nil
+			}
+		}
+	}
+}
+
+func (lx *jsLexer) scanNumber() (jsToken, error) {
+	start := lx.pos
+	src := lx.src
+	isHex := func(c byte) bool {
+		return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+	}
+	digitsWhile := func(valid func(byte) bool) {
+		for lx.pos < len(src) && (valid(src[lx.pos]) || src[lx.pos] == '_') {
+			lx.pos++
+		}
+	}
+	decimal := func(c byte) bool { return c >= '0' && c <= '9' }
+
+	if src[lx.pos] == '0' && lx.pos+1 < len(src) && strings.IndexByte("xXoObB", src[lx.pos+1]) >= 0 {
+		lx.pos += 2
+		switch src[lx.pos-1] {
+		case 'x', 'X':
+			digitsWhile(isHex)
+		case 'o', 'O':
+			digitsWhile(func(c byte) bool { return c >= '0' && c <= '7' })
+		default:
+			digitsWhile(func(c byte) bool { return c == '0' || c == '1' })
+		}
+		if lx.pos == start+2 {
+			return jsToken{}, lx.errorf(start, "invalid number literal")
+		}
+		if lx.pos < len(src) && src[lx.pos] == 'n' {
+			lx.pos++
+		}
+	} else {
+		digitsWhile(decimal)
+		bigint := false
+		if lx.pos < len(src) && src[lx.pos] == 'n' {
+			lx.pos++
+			bigint = true
+		}
+		if !bigint && lx.pos < len(src) && src[lx.pos] == '.' {
+			lx.pos++
+			digitsWhile(decimal)
+		}
+		if !bigint && lx.pos < len(src) && (src[lx.pos] == 'e' || src[lx.pos] == 'E') {
+			lx.pos++
+			if lx.pos < len(src) && (src[lx.pos] == '+' || src[lx.pos] == '-') {
+				lx.pos++
+			}
+			expStart := lx.pos
+			digitsWhile(decimal)
+			if lx.pos == expStart {
+				return jsToken{}, lx.errorf(start, "invalid number literal")
+			}
+		}
+	}
+
+	if r := lx.peekRune(0); r >= 0 && isJSIdentifierStart(r) {
+		return jsToken{}, lx.errorf(start, "identifier starts immediately after numeric literal")
+	}
+	return jsToken{kind: jsNumber}, nil
+}
+
+// jsLexerState is a snapshot used by the parser to backtrack
+type jsLexerState struct {
+	pos         int
+	prev        jsToken
+	beforePrev  jsToken
+	parens      []bool
+	braces      []jsBraceKind
+	closedBrace jsBraceKind
+	closedParen bool
+}
+
+func (lx *jsLexer) save() jsLexerState {
+	return jsLexerState{
+		pos:         lx.pos,
+		prev:        lx.prev,
+		beforePrev:  lx.beforePrev,
+		parens:      append([]bool(nil), lx.parens...),
+		braces:      append([]jsBraceKind(nil), lx.braces...),
+		closedBrace: lx.closedBrace,
+		closedParen: lx.closedParen,
+	}
+}
+
+func (lx *jsLexer) restore(s jsLexerState) {
+	lx.pos = s.pos
+	lx.prev = s.prev
+	lx.beforePrev = s.beforePrev
+	lx.parens = s.parens
+	lx.braces = s.braces
+	lx.closedBrace = s.closedBrace
+	lx.closedParen = s.closedParen
+}
+
+// rescan re-lexes tok from its start with a specific scanner, for when the
+// parser knows better than the slash heuristic
+func (lx *jsLexer) rescan(tok jsToken, scan func() (jsToken, error)) (jsToken, error) {
+	lx.pos = tok.start
+	next, err := scan()
+	if err != nil {
+		return next, err
+	}
+	next.start = tok.start
+	next.end = lx.pos
+	next.text = lx.src[tok.start:lx.pos]
+	next.newlineBefore = tok.newlineBefore
+	lx.prev = next
+	return next, nil
+}
+
+func (lx *jsLexer) scanPunctuator() (jsToken, error) {
+	for _, p := range jsPunctuators {
+		if strings.HasPrefix(lx.src[lx.pos:], p) {
+			lx.pos += len(p)
+			return jsToken{kind: jsPunctuator}, nil
+		}
+	}
+	return jsToken{}, lx.errorf(lx.pos, "unexpected character")
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// jsContinuationPunctuators can never start a statement, so a line break
+// before one of them is never turned into a semicolon by ASI
+var jsContinuationPunctuators = map[string]bool{
+	"(": true, "[": true, ".": true, "?.": true, ",": true, ";": true,
+	":": true, "?": true, ")": true, "]": true, "}": true, "=>": true,
+	"=": true, "+=": true, "-=": true, "*=": true, "/=": true, "%=": true,
+	"**=": true, "<<=": true, ">>=": true, ">>>=": true, "&=": true,
+	"|=": true, "^=": true, "&&=": true, "||=": true, "??=": true,
+	"==": true, "!=": true, "===": true, "!==": true, "<": true, ">": true,
+	"<=": true, ">=": true, "+": true, "-": true, "*": true, "/": true,
+	"%": true, "**": true, "&": true, "|": true, "^": true, "<<": true,
+	">>": true, ">>>": true, "&&": true, "||": true, "??": true,
+}
+
+// jsValueKeywords can end an expression, so ASI may apply after them
+var jsValueKeywords = map[string]bool{
+	"this": true, "super": true, "null": true, "true": true, "false": true,
+}
+
+// jsRestrictedKeywords are restricted productions: a line break after them
+// always terminates the statement
+var jsRestrictedKeywords = map[string]bool{
+	"return": true, "break": true, "continue": true, "throw": true,
+	"yield": true, "await": true, "debugger": true, "async": true, "let": true,
+}
+
+func minifyJavaScriptCode(code string, opts *FormatOptions) (string, error) {
+	var out mappedOutput
+	if err := minifyJS(&out, code, opts, true); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// minifyJavaScriptMapped minifies like minifyJavaScriptCode and maps every
+// token it writes back to the source
+func minifyJavaScriptMapped(code string, opts *FormatOptions) (string, string, error) {
+	return minifyJSMapped(code, opts, true, "input.js")
+}
+
+// minifyTypeScriptCode minifies TypeScript, whose type annotations are
+// ordinary tokens to the minifier. It never compresses, since the
+// compressor only understands JavaScript.
+func minifyTypeScriptCode(code string, opts *FormatOptions) (string, error) {
+	var out mappedOutput
+	if err := minifyJS(&out, code, opts, false); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// minifyTypeScriptMapped minifies like minifyTypeScriptCode and maps every
+// token it writes back to the source
+func minifyTypeScriptMapped(code string, opts *FormatOptions) (string, string, error) {
+	return minifyJSMapped(code, opts, false, "input.ts")
+}
+
+// minifyJS writes the minified code to out, compressing it first when
+// compress is set and the compress option asks for it
+func minifyJS(out *mappedOutput, code string, opts *FormatOptions, compress bool) error {
+	tokens, err := tokenizeJS(code)
+	if err != nil {
+		return err
+	}
+	var names []string
+	if compress && opts.compress() > compressWhitespace {
+		if tokens, names, err = compressJS(code, tokens, opts); err != nil {
+			return err
+		}
+	}
+	writeMinifiedJS(out, tokens, names)
+	return nil
+}
+
+func minifyJSMapped(code string, opts *FormatOptions, compress bool, defaultSource string) (string, string, error) {
+	out := mappedOutput{mapped: true}
+	if err := minifyJS(&out, code, opts, compress); err != nil {
+		return "", "", err
+	}
+	minified, sourceMap := finishSourceMap(&out, code, opts, defaultSource, func(url string) string {
+		return "//# sourceMappingURL=" + url
+	})
+	return minified, sourceMap, nil
+}
+
+// isLegalComment reports whether a block comment must survive minification
+func isLegalComment(tok jsToken) bool {
+	if tok.kind != jsBlockComment {
+		return false
+	}
+	return strings.HasPrefix(tok.text, "/*!") ||
+		strings.Contains(tok.text, "@license") ||
+		strings.Contains(tok.text, "@preserve")
+}
+
+// writeMinifiedJS prints tokens with the least whitespace that keeps both
+// tokenization and automatic semicolon insertion unchanged. names holds,
+// for tokens renaming an identifier, its original name.
+func writeMinifiedJS(out *mappedOutput, tokens []jsToken, names []string) {
+	var prev jsToken // last significant token written
+	prevWritten := false
+	afterComment := false
+	pendingNewline := false
+
+	for i, tok := range tokens {
+		if tok.newlineBefore {
+			pendingNewline = true
+		}
+
+		if tok.isComment() {
+			if tok.kind == jsHashbang || isLegalComment(tok) {
+				if out.Len() > 0 && pendingNewline {
+					out.WriteByte('\n')
+				}
+				out.token(tok.text, tok.start)
+				afterComment = true
+				pendingNewline = false
+				continue
+			}
+			// A dropped comment spanning lines still separates the
+			// surrounding tokens by a line terminator
+			if tok.kind == jsLineComment || strings.ContainsAny(tok.text, "\n\r\u2028\u2029") {
+				pendingNewline = true
+			}
+			continue
+		}
+
+		if tok.kind == jsKeyword && prevWritten && (prev.is(jsPunctuator, ".") || prev.is(jsPunctuator, "?.")) {
+			// A keyword after a dot is a property name, which a line
+			// break may end like any other
+			tok.kind = jsIdentifier
+		}
+
+		switch {
+		case afterComment:
+			if pendingNewline {
+				out.WriteByte('\n')
+			}
+		case !prevWritten:
+		case pendingNewline && jsNewlineSignificant(prev, tok):
+			out.WriteByte('\n')
+		case jsNeedsSpace(prev, tok):
+			out.WriteByte(' ')
+		}
+
+		if i < len(names) && names[i] != "" {
+			out.namedToken(tok.text, tok.start, names[i])
+		} else {
+			out.token(tok.text, tok.start)
+		}
+		prev = tok
+		prevWritten = true
+		afterComment = false
+		pendingNewline = false
+	}
+}
+
+// jsNewlineSignificant reports whether removing the line break between two
+// tokens could change where automatic semicolon insertion applies
+func jsNewlineSignificant(prev, next jsToken) bool {
+	if (prev.kind == jsKeyword || prev.kind == jsIdentifier) && jsRestrictedKeywords[prev.text] {
+		return true
+	}
+	if next.is(jsPunctuator, "++") || next.is(jsPunctuator, "--") {
+		return true
+	}
+
+	switch prev.kind {
+	case jsPunctuator:
+		switch prev.text {
+		case ")", "]", "}", "++", "--":
+		default:
+			return false
+		}
+	case jsKeyword:
+		if !jsValueKeywords[prev.text] {
+			return false
+		}
+	}
+
+	switch next.kind {
+	case jsPunctuator:
+		return !jsContinuationPunctuators[next.text]
+	case jsTemplate, jsTemplateHead:
+		return false
+	case jsKeyword:
+		return next.text != "in" && next.text != "instanceof"
+	}
+	return true
+}
+
+// jsNeedsSpace reports whether two tokens written back to back would lex
+// differently than they do apart
+func jsNeedsSpace(prev, next jsToken) bool {
+	last, _ := utf8.DecodeLastRuneInString(prev.text)
+	first, _ := utf8.DecodeRuneInString(next.text)
+
+	switch {
+	case isJSIdentifierPart(last) && isJSIdentifierPart(first):
+		// words, numbers and regex flags would merge
+		return true
+	case prev.kind == jsRegExp && isJSIdentifierPart(first):
+		return true
+	case prev.kind == jsNumber && first == '.' && isPlainInteger(prev.text):
+		// 1 .toString() must not become the literal 1.
+		return true
+	case (last == '+' || last == '-') && first == last:
+		return true
+	case last == '/' && (first == '/' || first == '*'):
+		return true
+	case last == '<' && first == '!':
+		// <!-- opens an HTML-like comment
+		return true
+	case strings.HasSuffix(prev.text, "--") && first == '>':
+		// --> closes an HTML-like comment
+		return true
+	}
+	return false
+}
+
+func isPlainInteger(text string) bool {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if !(c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestMinifyJavaScript(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"comments", "// comment\nvar a = 1 /* inline */ + 2;", "var a=1+2;"},
+		{"regular expression", "var re = /ab+c\\/[/]/g.test(s) ? a / 2 / b : 0;", "var re=/ab+c\\/[/]/g.test(s)?a/2/b:0;"},
+		{"string with comment text", "var s = 'it\\'s //not a comment';", "var s='it\\'s //not a comment';"},
+		{"template literal", "var t = `x ${ a + 1 } // y`;", "var t=`x ${a+1} // y`;"},
+		{"line break before ++", "a = b\n++c", "a=b\n++c"},
+		{"line break ending a statement", "x = y\nif (a) { b() } else { c() }", "x=y\nif(a){b()}else{c()}"},
+		{"unary operators", "let x = a + +b - -c + ++d", "let x=a+ +b- -c+ ++d"},
+		{"division", "x = y / z / w", "x=y/z/w"},
+		{"license comment", "/*! keep license */\nfunction f ( ) { return 1 }", "/*! keep license */\nfunction f(){return 1}"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Minify(tt.code, "javascript")
+			if err != nil {
+				t.Fatalf("Minify(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Minify(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if _, err := jsCodeTokens(got, jsDialect{}); err != nil {
+				t.Errorf("Minify(%q) = %q, which does not parse: %v", tt.code, got, err)
+			}
+		})
+	}
+}
+
+func TestMinifyJavaScriptErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`let s = "abc`, "1:9: unterminated string literal"},
+		{"let a = 1;\nlet r = /unterminated\n", "2:9: unterminated regular expression"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		_, err := f.Minify(tt.code, "javascript")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Minify(%q) error = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+}
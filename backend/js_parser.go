@@ -0,0 +1,2644 @@
+package main
+
+import "strings"
+
+// jsDialect selects the syntax extensions accepted by the parser
+type jsDialect struct {
+	typescript bool
+	jsx        bool
+}
+
+// jsParser is a recursive descent parser for ECMAScript 2022 with optional
+// JSX and TypeScript syntax. It drives jsLexer and re-scans tokens whose
+// meaning depends on grammar context (regular expressions, template
+// continuations, JSX and closing type-argument brackets).
+type jsParser struct {
+	lx       *jsLexer
+	src      string
+	dialect  jsDialect
+	tok      jsToken
+	prevEnd  int
+	comments []jsToken
+	// tokens collects the significant tokens consumed when record is set
+	tokens []jsToken
+	record bool
+
+	inGenerator bool
+	inAsync     bool
+}
+
+// jsBailout carries a syntax error out of the recursive descent
+type jsBailout struct {
+	err error
+}
+
+type jsParserState struct {
+	lexer    jsLexerState
+	tok      jsToken
+	prevEnd  int
+	comments int
+	tokens   int
+}
+
+func newJSParser(src string, dialect jsDialect) *jsParser {
+	return &jsParser{
+		lx:      newJSLexer(src),
+		src:     src,
+		dialect: dialect,
+		// Modules allow top-level await
+		inAsync: true,
+	}
+}
+
+// parseJS parses a whole program and returns it with every comment found
+func parseJS(src string, dialect jsDialect) (*jsProgram, []jsToken, error) {
+	p := newJSParser(src, dialect)
+	prog, err := p.parseProgram()
+	if err != nil {
+		return nil, nil, err
+	}
+	return prog, p.comments, nil
+}
+
+// jsCodeTokens parses a whole program and returns its significant tokens
+// as the grammar read them, without comments or the text of JSX
+func jsCodeTokens(src string, dialect jsDialect) ([]jsToken, error) {
+	p := newJSParser(src, dialect)
+	p.record = true
+	if _, err := p.parseProgram(); err != nil {
+		return nil, err
+	}
+	return p.tokens, nil
+}
+
+func (p *jsParser) parseProgram() (prog *jsProgram, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			bailout, ok := r.(jsBailout)
+			if !ok {
+				panic(r)
+			}
+			prog, err = nil, bailout.err
+		}
+	}()
+
+	p.next()
+	prog = &jsProgram{}
+	for p.tok.kind != jsEOF {
+		prog.body = append(prog.body, p.parseStatement())
+	}
+	prog.end = len(p.src)
+	return prog, nil
+}
+
+func (p *jsParser) fail(offset int, format string, args ...interface{}) {
+	panic(jsBailout{p.lx.errorf(offset, format, args...)})
+}
+
+func (p *jsParser) unexpected() {
+	if p.tok.kind == jsEOF {
+		p.fail(p.tok.start, "unexpected end of input")
+	}
+	p.fail(p.tok.start, "unexpected token %s", p.tok.text)
+}
+
+func (p *jsParser) next() {
+	if p.record && p.tok.text != "" {
+		// Tokens re-scanned in context are recorded as they ended up, and
+		// JSX elements, which stand in as tokens without text, are not
+		p.tokens = append(p.tokens, p.tok)
+	}
+	p.prevEnd = p.tok.end
+	newline := false
+	for {
+		tok, err := p.lx.next()
+		if err != nil {
+			panic(jsBailout{err})
+		}
+		if tok.isComment() {
+			p.comments = append(p.comments, tok)
+			if tok.newlineBefore || strings.ContainsAny(tok.text, "\n\r") {
+				newline = true
+			}
+			continue
+		}
+		tok.newlineBefore = tok.newlineBefore || newline
+		p.tok = tok
+		return
+	}
+}
+
+func (p *jsParser) snapshot() jsParserState {
+	return jsParserState{lexer: p.lx.save(), tok: p.tok, prevEnd: p.prevEnd, comments: len(p.comments), tokens: len(p.tokens)}
+}
+
+func (p *jsParser) restore(s jsParserState) {
+	p.lx.restore(s.lexer)
+	p.tok = s.tok
+	p.prevEnd = s.prevEnd
+	p.comments = p.comments[:s.comments]
+	p.tokens = p.tokens[:s.tokens]
+}
+
+// try runs fn speculatively, rewinding the parser if it fails
+func (p *jsParser) try(fn func()) (ok bool) {
+	state := p.snapshot()
+	defer func() {
+		if r := recover(); r != nil {
+			if _, isBailout := r.(jsBailout); !isBailout {
+				panic(r)
+			}
+			p.restore(state)
+			ok = false
+		}
+	}()
+	fn()
+	return true
+}
+
+// peek returns the token after the current one without consuming anything
+func (p *jsParser) peek() jsToken {
+	state := p.snapshot()
+	p.next()
+	tok := p.tok
+	p.restore(state)
+	return tok
+}
+
+// is reports whether the current token is the punctuator or keyword text
+func (p *jsParser) is(text string) bool {
+	return (p.tok.kind == jsPunctuator || p.tok.kind == jsKeyword) && p.tok.text == text
+}
+
+// isIdent reports whether the current token is the contextual keyword name
+func (p *jsParser) isIdent(name string) bool {
+	return p.tok.kind == jsIdentifier && p.tok.text == name
+}
+
+func (p *jsParser) eat(text string) bool {
+	if p.is(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *jsParser) expect(text string) {
+	if !p.is(text) {
+		if p.tok.kind == jsEOF {
+			p.fail(p.tok.start, "expected %s but found end of input", text)
+		}
+		p.fail(p.tok.start, "expected %s but found %s", text, p.tok.text)
+	}
+	p.next()
+}
+
+// consumeSemicolon applies automatic semicolon insertion
+func (p *jsParser) consumeSemicolon() {
+	if p.eat(";") {
+		return
+	}
+	if p.is("}") || p.tok.kind == jsEOF || p.tok.newlineBefore {
+		return
+	}
+	p.unexpected()
+}
+
+// canInsertSemicolon reports whether a statement may end before the current token
+func (p *jsParser) canInsertSemicolon() bool {
+	return p.is(";") || p.is("}") || p.tok.kind == jsEOF || p.tok.newlineBefore
+}
+
+func (p *jsParser) isBindingIdentifier() bool {
+	switch p.tok.kind {
+	case jsIdentifier:
+		return true
+	case jsKeyword:
+		switch p.tok.text {
+		case "yield":
+			return !p.inGenerator
+		case "await":
+			return !p.inAsync
+		}
+	}
+	return false
+}
+
+func (p *jsParser) parseIdentifier() *jsIdent {
+	if !p.isBindingIdentifier() {
+		p.unexpected()
+	}
+	id := &jsIdent{jsSpan: jsSpan{p.tok.start, p.tok.end}, name: p.tok.text}
+	p.next()
+	return id
+}
+
+// parseIdentifierName accepts any identifier including reserved words, as
+// allowed after a dot or as a property key
+func (p *jsParser) parseIdentifierName() *jsIdent {
+	if p.tok.kind != jsIdentifier && p.tok.kind != jsKeyword && p.tok.kind != jsPrivateName {
+		p.unexpected()
+	}
+	id := &jsIdent{jsSpan: jsSpan{p.tok.start, p.tok.end}, name: p.tok.text}
+	p.next()
+	return id
+}
+
+// Statements
+
+func (p *jsParser) parseStatement() jsNode {
+	start := p.tok.start
+
+	switch p.tok.kind {
+	case jsPunctuator:
+		switch p.tok.text {
+		case "{":
+			return p.parseBlock()
+		case ";":
+			p.next()
+			return &jsEmpty{jsSpan{start, p.prevEnd}}
+		case "@":
+			decorators := p.parseDecorators()
+			if p.is("export") {
+				return p.parseExport(decorators)
+			}
+			return p.parseClass(decorators, p.parseClassModifiers(), false)
+		}
+	case jsKeyword:
+		switch p.tok.text {
+		case "var", "const":
+			if p.tok.text == "const" && p.dialect.typescript && p.peek().is(jsKeyword, "enum") {
+				return p.parseEnum(start, nil)
+			}
+			decl := p.parseVarDecl(false)
+			p.consumeSemicolon()
+			decl.end = p.prevEnd
+			return decl
+		case "function":
+			return p.parseFunction(start, false, false)
+		case "class":
+			return p.parseClass(nil, nil, false)
+		case "if":
+			return p.parseIf()
+		case "for":
+			return p.parseFor()
+		case "while":
+			p.next()
+			test := p.parseParenExpression()
+			body := p.parseStatement()
+			return &jsWhile{jsSpan{start, p.prevEnd}, test, body}
+		case "do":
+			p.next()
+			body := p.parseStatement()
+			p.expect("while")
+			test := p.parseParenExpression()
+			// The semicolon after do-while is always optional
+			p.eat(";")
+			return &jsDoWhile{jsSpan{start, p.prevEnd}, body, test}
+		case "return":
+			p.next()
+			var arg jsNode
+			if !p.canInsertSemicolon() {
+				arg = p.parseExpression(false)
+			}
+			p.consumeSemicolon()
+			return &jsReturn{jsSpan{start, p.prevEnd}, arg}
+		case "break", "continue":
+			keyword := p.tok.text
+			p.next()
+			var label *jsIdent
+			if p.tok.kind == jsIdentifier && !p.tok.newlineBefore {
+				label = p.parseIdentifier()
+			}
+			p.consumeSemicolon()
+			return &jsJump{jsSpan{start, p.prevEnd}, keyword, label}
+		case "throw":
+			p.next()
+			if p.tok.newlineBefore {
+				p.fail(p.tok.start, "illegal newline after throw")
+			}
+			arg := p.parseExpression(false)
+			p.consumeSemicolon()
+			return &jsThrow{jsSpan{start, p.prevEnd}, arg}
+		case "try":
+			return p.parseTry()
+		case "switch":
+			return p.parseSwitch()
+		case "debugger":
+			p.next()
+			p.consumeSemicolon()
+			return &jsDebugger{jsSpan{start, p.prevEnd}}
+		case "with":
+			p.next()
+			object := p.parseParenExpression()
+			body := p.parseStatement()
+			return &jsWith{jsSpan{start, p.prevEnd}, object, body}
+		case "import":
+			if next := p.peek(); !next.is(jsPunctuator, "(") && !next.is(jsPunctuator, ".") {
+				return p.parseImport()
+			}
+		case "export":
+			return p.parseExport(nil)
+		case "enum":
+			if p.dialect.typescript {
+				return p.parseEnum(start, nil)
+			}
+		}
+	case jsIdentifier:
+		if stmt := p.parseContextualStatement(); stmt != nil {
+			return stmt
+		}
+	}
+
+	expr := p.parseExpression(false)
+	if id, ok := expr.(*jsIdent); ok && p.is(":") {
+		p.next()
+		body := p.parseStatement()
+		return &jsLabeled{jsSpan{start, p.prevEnd}, id, body}
+	}
+	p.consumeSemicolon()
+	return &jsExprStmt{jsSpan{start, p.prevEnd}, expr}
+}
+
+// parseContextualStatement handles statements introduced by identifiers
+// that are only keywords in context, returning nil for plain expressions
+func (p *jsParser) parseContextualStatement() jsNode {
+	start := p.tok.start
+	next := p.peek()
+	sameLine := !next.newlineBefore
+
+	switch p.tok.text {
+	case "let":
+		if next.kind == jsIdentifier || next.is(jsPunctuator, "[") || next.is(jsPunctuator, "{") ||
+			(next.kind == jsKeyword && (next.text == "yield" || next.text == "await")) {
+			decl := p.parseVarDecl(false)
+			p.consumeSemicolon()
+			decl.end = p.prevEnd
+			return decl
+		}
+	case "async":
+		if next.is(jsKeyword, "function") && sameLine {
+			p.next()
+			return p.parseFunction(start, true, false)
+		}
+	}
+
+	if !p.dialect.typescript || !sameLine {
+		return nil
+	}
+
+	switch p.tok.text {
+	case "type":
+		if next.kind == jsIdentifier || next.kind == jsKeyword {
+			return p.parseTypeAlias(start)
+		}
+	case "interface":
+		if next.kind == jsIdentifier {
+			return p.parseInterface(start)
+		}
+	case "namespace", "module":
+		if next.kind == jsIdentifier || next.kind == jsString {
+			return p.parseModule(start)
+		}
+	case "global":
+		if next.is(jsPunctuator, "{") {
+			return p.parseModule(start)
+		}
+	case "abstract":
+		if next.is(jsKeyword, "class") {
+			return p.parseClass(nil, p.parseClassModifiers(), false)
+		}
+	case "declare":
+		if next.kind == jsKeyword || next.kind == jsIdentifier {
+			p.next()
+			stmt := p.parseStatement()
+			return &tsDeclare{jsSpan{start, p.prevEnd}, stmt}
+		}
+	}
+	return nil
+}
+
+func (p *jsParser) parseBlock() *jsBlock {
+	start := p.tok.start
+	p.expect("{")
+	block := &jsBlock{}
+	for !p.is("}") {
+		if p.tok.kind == jsEOF {
+			p.fail(start, "unterminated block")
+		}
+		block.body = append(block.body, p.parseStatement())
+	}
+	p.next()
+	block.jsSpan = jsSpan{start, p.prevEnd}
+	return block
+}
+
+func (p *jsParser) parseParenExpression() jsNode {
+	p.expect("(")
+	expr := p.parseExpression(false)
+	p.expect(")")
+	return expr
+}
+
+func (p *jsParser) isVarDeclStart() bool {
+	if p.is("var") || p.is("const") {
+		return true
+	}
+	if p.isIdent("let") {
+		next := p.peek()
+		return next.kind == jsIdentifier || next.is(jsPunctuator, "[") || next.is(jsPunctuator, "{") ||
+			(next.kind == jsKeyword && (next.text == "yield" || next.text == "await"))
+	}
+	return false
+}
+
+func (p *jsParser) parseVarDecl(noIn bool) *jsVarDecl {
+	decl := &jsVarDecl{jsSpan: jsSpan{start: p.tok.start}, kind: p.tok.text}
+	p.next()
+
+	for {
+		d := &jsVarDeclarator{jsSpan: jsSpan{start: p.tok.start}}
+		d.target = p.parseBindingTarget()
+		if p.dialect.typescript && p.is("!") {
+			p.next()
+			d.definite = true
+		}
+		if p.dialect.typescript && p.is(":") {
+			p.next()
+			d.typeAnn = p.parseType()
+		}
+		if p.eat("=") {
+			d.init = p.parseAssign(noIn)
+		}
+		d.end = p.prevEnd
+		decl.decls = append(decl.decls, d)
+		if !p.eat(",") {
+			break
+		}
+	}
+
+	decl.end = p.prevEnd
+	return decl
+}
+
+func (p *jsParser) parseIf() jsNode {
+	start := p.tok.start
+	p.next()
+	test := p.parseParenExpression()
+	cons := p.parseStatement()
+	var alt jsNode
+	if p.eat("else") {
+		alt = p.parseStatement()
+	}
+	return &jsIf{jsSpan{start, p.prevEnd}, test, cons, alt}
+}
+
+func (p *jsParser) parseFor() jsNode {
+	start := p.tok.start
+	p.next()
+	await := false
+	if p.is("await") {
+		p.next()
+		await = true
+	}
+	p.expect("(")
+
+	var init jsNode
+	switch {
+	case p.is(";"):
+	case p.isVarDeclStart():
+		init = p.parseVarDecl(true)
+	default:
+		init = p.parseExpression(true)
+	}
+
+	if init != nil && (p.is("in") || p.isIdent("of")) {
+		of := p.tok.text == "of"
+		p.next()
+		var right jsNode
+		if of {
+			right = p.parseAssign(false)
+		} else {
+			right = p.parseExpression(false)
+		}
+		p.expect(")")
+		body := p.parseStatement()
+		return &jsForIn{jsSpan{start, p.prevEnd}, of, await, init, right, body}
+	}
+
+	p.expect(";")
+	var test, update jsNode
+	if !p.is(";") {
+		test = p.parseExpression(false)
+	}
+	p.expect(";")
+	if !p.is(")") {
+		update = p.parseExpression(false)
+	}
+	p.expect(")")
+	body := p.parseStatement()
+	return &jsFor{jsSpan{start, p.prevEnd}, init, test, update, body}
+}
+
+func (p *jsParser) parseTry() jsNode {
+	start := p.tok.start
+	p.next()
+	stmt := &jsTry{block: p.parseBlock()}
+
+	if p.eat("catch") {
+		if p.eat("(") {
+			stmt.param = p.parseBindingTarget()
+			if p.dialect.typescript && p.eat(":") {
+				stmt.paramType = p.parseType()
+			}
+			p.expect(")")
+		}
+		stmt.handler = p.parseBlock()
+	}
+	if p.eat("finally") {
+		stmt.finalizer = p.parseBlock()
+	}
+	if stmt.handler == nil && stmt.finalizer == nil {
+		p.fail(start, "missing catch or finally after try")
+	}
+
+	stmt.jsSpan = jsSpan{start, p.prevEnd}
+	return stmt
+}
+
+func (p *jsParser) parseSwitch() jsNode {
+	start := p.tok.start
+	p.next()
+	stmt := &jsSwitch{disc: p.parseParenExpression()}
+	p.expect("{")
+
+	for !p.is("}") {
+		c := &jsSwitchCase{jsSpan: jsSpan{start: p.tok.start}}
+		if p.eat("default") {
+			p.expect(":")
+		} else {
+			p.expect("case")
+			c.test = p.parseExpression(false)
+			p.expect(":")
+		}
+		for !p.is("case") && !p.is("default") && !p.is("}") {
+			if p.tok.kind == jsEOF {
+				p.fail(start, "unterminated switch statement")
+			}
+			c.body = append(c.body, p.parseStatement())
+		}
+		c.end = p.prevEnd
+		stmt.cases = append(stmt.cases, c)
+	}
+	p.next()
+
+	stmt.jsSpan = jsSpan{start, p.prevEnd}
+	return stmt
+}
+
+// Modules
+
+func (p *jsParser) parseModuleExportName() jsNode {
+	if p.tok.kind == jsString {
+		return p.parseLiteral()
+	}
+	return p.parseIdentifierName()
+}
+
+func (p *jsParser) parseModuleSpecs() []*jsModuleSpec {
+	p.expect("{")
+	var specs []*jsModuleSpec
+	for !p.is("}") {
+		spec := &jsModuleSpec{jsSpan: jsSpan{start: p.tok.start}}
+		if p.dialect.typescript && p.isIdent("type") {
+			if next := p.peek(); next.kind == jsIdentifier || next.kind == jsKeyword || next.kind == jsString {
+				if !(next.text == "as" && next.kind == jsIdentifier) {
+					p.next()
+					spec.typeOnly = true
+				}
+			}
+		}
+		spec.local = p.parseModuleExportName()
+		if p.isIdent("as") {
+			p.next()
+			spec.exported = p.parseModuleExportName()
+		}
+		spec.end = p.prevEnd
+		specs = append(specs, spec)
+		if !p.eat(",") {
+			break
+		}
+	}
+	p.expect("}")
+	return specs
+}
+
+func (p *jsParser) parseImportAttributes() (string, jsNode) {
+	if (p.is("with") || p.isIdent("assert")) && !p.tok.newlineBefore {
+		keyword := p.tok.text
+		p.next()
+		return keyword, p.parseObjectLiteral()
+	}
+	return "", nil
+}
+
+func (p *jsParser) parseImport() jsNode {
+	start := p.tok.start
+	p.next()
+	stmt := &jsImport{}
+
+	if p.dialect.typescript && p.isIdent("type") {
+		if next := p.peek(); next.kind == jsIdentifier && next.text != "from" || next.is(jsPunctuator, "{") || next.is(jsPunctuator, "*") {
+			p.next()
+			stmt.typeOnly = true
+		}
+	}
+
+	if p.tok.kind == jsString {
+		stmt.source = p.parseLiteral()
+		stmt.attributesKw, stmt.attributes = p.parseImportAttributes()
+		p.consumeSemicolon()
+		stmt.jsSpan = jsSpan{start, p.prevEnd}
+		return stmt
+	}
+
+	if p.isBindingIdentifier() {
+		stmt.defaultName = p.parseIdentifier()
+		if p.dialect.typescript && p.is("=") {
+			p.next()
+			ref := p.parseImportEqualsRef()
+			p.consumeSemicolon()
+			return &tsImportEquals{jsSpan{start, p.prevEnd}, false, stmt.typeOnly, stmt.defaultName, ref}
+		}
+		p.eat(",")
+	}
+	if p.is("*") {
+		p.next()
+		if !p.isIdent("as") {
+			p.unexpected()
+		}
+		p.next()
+		stmt.namespace = p.parseIdentifier()
+	} else if p.is("{") {
+		stmt.hasNamed = true
+		stmt.named = p.parseModuleSpecs()
+	}
+
+	if !p.isIdent("from") {
+		p.unexpected()
+	}
+	p.next()
+	if p.tok.kind != jsString {
+		p.unexpected()
+	}
+	stmt.source = p.parseLiteral()
+	stmt.attributesKw, stmt.attributes = p.parseImportAttributes()
+	p.consumeSemicolon()
+	stmt.jsSpan = jsSpan{start, p.prevEnd}
+	return stmt
+}
+
+func (p *jsParser) parseImportEqualsRef() jsNode {
+	if p.isIdent("require") && p.peek().is(jsPunctuator, "(") {
+		return p.parseLHSExpression()
+	}
+	return p.parseEntityName()
+}
+
+// parseEntityName parses a dotted name such as A.B.C into an identifier
+func (p *jsParser) parseEntityName() *jsIdent {
+	start := p.tok.start
+	name := p.parseIdentifierName().name
+	for p.is(".") {
+		p.next()
+		name += "." + p.parseIdentifierName().name
+	}
+	return &jsIdent{jsSpan{start, p.prevEnd}, name}
+}
+
+func (p *jsParser) parseExport(decorators []jsNode) jsNode {
+	start := p.tok.start
+	if len(decorators) > 0 {
+		start = nodeStart(decorators[0])
+	}
+	p.next()
+	// Decorators may come before or after the export keyword
+	stmt := &jsExport{decorators: decorators}
+	var classDecorators []jsNode
+
+	switch {
+	case p.is("default"):
+		p.next()
+		stmt.isDefault = true
+		switch {
+		case p.is("function"):
+			stmt.decl = p.parseFunction(p.tok.start, false, true)
+		case p.isIdent("async") && p.peek().is(jsKeyword, "function"):
+			fnStart := p.tok.start
+			p.next()
+			stmt.decl = p.parseFunction(fnStart, true, true)
+		case p.is("class"), p.is("@"):
+			if p.is("@") {
+				classDecorators = p.parseDecorators()
+			}
+			stmt.decl = p.parseClass(classDecorators, nil, true)
+		case p.dialect.typescript && p.isIdent("abstract") && p.peek().is(jsKeyword, "class"):
+			stmt.decl = p.parseClass(classDecorators, p.parseClassModifiers(), true)
+		case p.dialect.typescript && p.isIdent("interface") && p.peek().kind == jsIdentifier:
+			stmt.decl = p.parseInterface(p.tok.start)
+		default:
+			stmt.decl = p.parseAssign(false)
+			p.consumeSemicolon()
+		}
+	case p.is("*"):
+		p.next()
+		stmt.star = true
+		if p.isIdent("as") {
+			p.next()
+			stmt.starAs = p.parseModuleExportName()
+		}
+		p.parseExportFrom(stmt)
+	case p.is("{"):
+		stmt.hasNamed = true
+		stmt.named = p.parseModuleSpecs()
+		if p.isIdent("from") {
+			p.parseExportFrom(stmt)
+		} else {
+			p.consumeSemicolon()
+		}
+	case p.dialect.typescript && p.isIdent("type") && p.peek().is(jsPunctuator, "{"):
+		p.next()
+		stmt.typeOnly = true
+		stmt.hasNamed = true
+		stmt.named = p.parseModuleSpecs()
+		if p.isIdent("from") {
+			p.parseExportFrom(stmt)
+		} else {
+			p.consumeSemicolon()
+		}
+	case p.dialect.typescript && p.is("="):
+		p.next()
+		stmt.assignment = p.parseExpression(false)
+		p.consumeSemicolon()
+	case p.dialect.typescript && p.isIdent("as"):
+		p.next()
+		if !p.isIdent("namespace") {
+			p.unexpected()
+		}
+		p.next()
+		stmt.namespaceAs = p.parseIdentifier()
+		p.consumeSemicolon()
+	case p.dialect.typescript && p.is("import"):
+		importStart := p.tok.start
+		p.next()
+		name := p.parseIdentifier()
+		p.expect("=")
+		ref := p.parseImportEqualsRef()
+		p.consumeSemicolon()
+		stmt.decl = &tsImportEquals{jsSpan{importStart, p.prevEnd}, true, false, name, ref}
+	default:
+		if p.is("@") {
+			classDecorators = p.parseDecorators()
+		}
+		if p.is("class") || p.isIdent("abstract") {
+			stmt.decl = p.parseClass(classDecorators, p.parseClassModifiers(), false)
+		} else {
+			stmt.decl = p.parseStatement()
+		}
+	}
+
+	stmt.jsSpan = jsSpan{start, p.prevEnd}
+	return stmt
+}
+
+func (p *jsParser) parseExportFrom(stmt *jsExport) {
+	if !p.isIdent("from") {
+		p.unexpected()
+	}
+	p.next()
+	if p.tok.kind != jsString {
+		p.unexpected()
+	}
+	stmt.source = p.parseLiteral()
+	_, stmt.attributes = p.parseImportAttributes()
+	p.consumeSemicolon()
+}
+
+// Functions
+
+// parseFunction parses a function declaration or expression starting at
+// the function keyword; start may point at a preceding async
+func (p *jsParser) parseFunction(start int, async, optionalName bool) *jsFunction {
+	p.expect("function")
+	fn := &jsFunction{async: async}
+	if p.eat("*") {
+		fn.generator = true
+	}
+	if p.isBindingIdentifier() || p.tok.kind == jsKeyword && (p.tok.text == "yield" || p.tok.text == "await") {
+		fn.name = p.parseIdentifierName()
+	} else if !optionalName {
+		p.unexpected()
+	}
+	p.parseFunctionRest(fn)
+	fn.jsSpan = jsSpan{start, p.prevEnd}
+	return fn
+}
+
+// parseFunctionRest parses type parameters, parameters, return type and body
+func (p *jsParser) parseFunctionRest(fn *jsFunction) {
+	savedGenerator, savedAsync := p.inGenerator, p.inAsync
+	p.inGenerator, p.inAsync = fn.generator, fn.async
+	defer func() {
+		p.inGenerator, p.inAsync = savedGenerator, savedAsync
+	}()
+
+	if p.dialect.typescript && p.is("<") {
+		fn.typeParams = p.parseTypeParams()
+	}
+	fn.params = p.parseParams()
+	if p.dialect.typescript && p.is(":") {
+		p.next()
+		fn.returnType = p.parseReturnType()
+	}
+	if p.is("{") {
+		fn.body = p.parseBlock()
+		return
+	}
+	if !p.dialect.typescript {
+		p.unexpected()
+	}
+	// Overload signature or abstract method without a body
+	p.consumeSemicolon()
+}
+
+var tsParamModifiers = map[string]bool{
+	"public": true, "private": true, "protected": true, "readonly": true, "override": true,
+}
+
+func (p *jsParser) parseParams() []*jsParam {
+	p.expect("(")
+	var params []*jsParam
+	for !p.is(")") {
+		params = append(params, p.parseParam())
+		if !p.eat(",") {
+			break
+		}
+	}
+	p.expect(")")
+	return params
+}
+
+func (p *jsParser) parseParam() *jsParam {
+	param := &jsParam{jsSpan: jsSpan{start: p.tok.start}}
+	if p.is("@") {
+		param.decorators = p.parseDecorators()
+	}
+	for p.dialect.typescript && p.tok.kind == jsIdentifier && tsParamModifiers[p.tok.text] {
+		next := p.peek()
+		if next.kind != jsIdentifier && next.kind != jsKeyword && !next.is(jsPunctuator, "{") && !next.is(jsPunctuator, "[") {
+			break
+		}
+		param.modifiers = append(param.modifiers, p.tok.text)
+		p.next()
+	}
+	if p.eat("...") {
+		param.rest = true
+	}
+	if p.dialect.typescript && p.is("this") {
+		param.pattern = p.parseIdentifierName()
+	} else {
+		param.pattern = p.parseBindingTarget()
+	}
+	if p.dialect.typescript && p.is("?") {
+		p.next()
+		param.optional = true
+	}
+	if p.dialect.typescript && p.is(":") {
+		p.next()
+		param.typeAnn = p.parseType()
+	}
+	if p.eat("=") {
+		param.init = p.parseAssign(false)
+	}
+	param.end = p.prevEnd
+	return param
+}
+
+// parseBindingTarget parses an identifier or destructuring pattern
+func (p *jsParser) parseBindingTarget() jsNode {
+	switch {
+	case p.is("["):
+		return p.parseArrayLiteral()
+	case p.is("{"):
+		return p.parseObjectLiteral()
+	}
+	return p.parseIdentifier()
+}
+
+// Classes
+
+var tsClassModifiers = map[string]bool{
+	"static": true, "public": true, "private": true, "protected": true,
+	"readonly": true, "abstract": true, "override": true, "declare": true,
+	"accessor": true,
+}
+
+func (p *jsParser) parseDecorators() []jsNode {
+	var decorators []jsNode
+	for p.is("@") {
+		start := p.tok.start
+		p.next()
+		var expr jsNode
+		if p.is("(") {
+			expr = p.parsePrimary()
+		} else {
+			expr = p.parseIdentifierName()
+			for p.is(".") {
+				p.next()
+				prop := p.parseIdentifierName()
+				expr = &jsMember{jsSpan{start + 1, p.prevEnd}, expr, prop, false, false}
+			}
+		}
+		if p.is("(") {
+			args := p.parseArguments()
+			expr = &jsCall{jsSpan: jsSpan{start + 1, p.prevEnd}, callee: expr, args: args, hasArgs: true}
+		}
+		decorators = append(decorators, &jsDecorator{jsSpan{start, p.prevEnd}, expr})
+	}
+	return decorators
+}
+
+func (p *jsParser) parseClassModifiers() []string {
+	var modifiers []string
+	for p.isIdent("abstract") || p.isIdent("declare") {
+		modifiers = append(modifiers, p.tok.text)
+		p.next()
+	}
+	return modifiers
+}
+
+func (p *jsParser) parseClass(decorators []jsNode, modifiers []string, optionalName bool) *jsClass {
+	start := p.tok.start
+	if len(decorators) > 0 {
+		start = nodeStart(decorators[0])
+	}
+	p.expect("class")
+	class := &jsClass{decorators: decorators, modifiers: modifiers}
+
+	if p.isBindingIdentifier() && !p.isIdent("implements") {
+		class.name = p.parseIdentifier()
+	} else if !optionalName {
+		p.unexpected()
+	}
+	if p.dialect.typescript && p.is("<") {
+		class.typeParams = p.parseTypeParams()
+	}
+	if p.eat("extends") {
+		class.superClass = p.parseLHSExpression()
+		if p.dialect.typescript && p.is("<") {
+			class.superTypeArgs = p.parseTypeArgs()
+		}
+	}
+	if p.dialect.typescript && p.isIdent("implements") {
+		p.next()
+		for {
+			class.implements = append(class.implements, p.parseType())
+			if !p.eat(",") {
+				break
+			}
+		}
+	}
+
+	p.expect("{")
+	for !p.is("}") {
+		if p.eat(";") {
+			continue
+		}
+		if p.tok.kind == jsEOF {
+			p.fail(start, "unterminated class body")
+		}
+		class.members = append(class.members, p.parseClassMember())
+	}
+	p.next()
+
+	class.jsSpan = jsSpan{start, p.prevEnd}
+	return class
+}
+
+// isMemberKeyStart reports whether tok can begin a property key, telling
+// modifiers such as static or get apart from members with those names
+func isMemberKeyStart(tok jsToken) bool {
+	switch tok.kind {
+	case jsIdentifier, jsKeyword, jsString, jsNumber, jsPrivateName:
+		return true
+	case jsPunctuator:
+		return tok.text == "[" || tok.text == "*" || tok.text == "{"
+	}
+	return false
+}
+
+func (p *jsParser) parseClassMember() jsNode {
+	start := p.tok.start
+	var decorators []jsNode
+	if p.is("@") {
+		decorators = p.parseDecorators()
+	}
+
+	var modifiers []string
+	for p.tok.kind == jsIdentifier && tsClassModifiers[p.tok.text] {
+		next := p.peek()
+		if p.tok.text == "static" && next.is(jsPunctuator, "{") {
+			p.next()
+			body := p.parseBlock()
+			return &jsStaticBlock{jsSpan{start, p.prevEnd}, body.body}
+		}
+		if !isMemberKeyStart(next) || next.newlineBefore && p.tok.text != "static" {
+			break
+		}
+		modifiers = append(modifiers, p.tok.text)
+		p.next()
+	}
+
+	if p.dialect.typescript && p.is("[") && p.isIndexSignature() {
+		sig := p.parseIndexSignature(start, modifiers)
+		p.consumeSemicolon()
+		sig.end = p.prevEnd
+		return sig
+	}
+
+	kind, async, generator := p.parseMethodPrefix()
+	key, computed := p.parsePropertyKey()
+
+	method := &jsMethod{decorators: decorators, modifiers: modifiers, kind: kind, key: key, computed: computed}
+	if p.dialect.typescript && p.is("?") {
+		p.next()
+		method.optional = true
+	}
+
+	if p.is("(") || p.is("<") {
+		if id, ok := key.(*jsIdent); ok && id.name == "constructor" && kind == "method" {
+			method.kind = "constructor"
+		}
+		fn := &jsFunction{async: async, generator: generator}
+		fnStart := p.tok.start
+		p.parseFunctionRest(fn)
+		fn.jsSpan = jsSpan{fnStart, p.prevEnd}
+		method.fn = fn
+		method.jsSpan = jsSpan{start, p.prevEnd}
+		return method
+	}
+
+	if kind != "method" || async || generator {
+		p.unexpected()
+	}
+	field := &jsField{decorators: decorators, modifiers: modifiers, key: key, computed: computed, optional: method.optional}
+	if p.dialect.typescript && p.is("!") {
+		p.next()
+		field.definite = true
+	}
+	if p.dialect.typescript && p.is(":") {
+		p.next()
+		field.typeAnn = p.parseType()
+	}
+	if p.eat("=") {
+		savedGenerator, savedAsync := p.inGenerator, p.inAsync
+		p.inGenerator, p.inAsync = false, false
+		field.value = p.parseAssign(false)
+		p.inGenerator, p.inAsync = savedGenerator, savedAsync
+	}
+	p.consumeSemicolon()
+	field.jsSpan = jsSpan{start, p.prevEnd}
+	return field
+}
+
+// parseMethodPrefix consumes get, set, async and * before a method key
+func (p *jsParser) parseMethodPrefix() (kind string, async, generator bool) {
+	kind = "method"
+	if p.isIdent("async") {
+		if next := p.peek(); !next.newlineBefore && isMemberKeyStart(next) && !next.is(jsPunctuator, "{") {
+			p.next()
+			async = true
+		}
+	}
+	if p.is("*") {
+		p.next()
+		generator = true
+	}
+	if !async && !generator && (p.isIdent("get") || p.isIdent("set")) {
+		if next := p.peek(); isMemberKeyStart(next) && !next.is(jsPunctuator, "*") && !next.is(jsPunctuator, "{") {
+			kind = p.tok.text
+			p.next()
+		}
+	}
+	return kind, async, generator
+}
+
+func (p *jsParser) parsePropertyKey() (jsNode, bool) {
+	switch p.tok.kind {
+	case jsString, jsNumber:
+		return p.parseLiteral(), false
+	case jsIdentifier, jsKeyword, jsPrivateName:
+		return p.parseIdentifierName(), false
+	}
+	if p.is("[") {
+		p.next()
+		key := p.parseAssign(false)
+		p.expect("]")
+		return key, true
+	}
+	p.unexpected()
+	return nil, false
+}
+
+// Expressions
+
+func (p *jsParser) parseExpression(noIn bool) jsNode {
+	start := p.tok.start
+	expr := p.parseAssign(noIn)
+	if !p.is(",") {
+		return expr
+	}
+	seq := &jsSequence{exprs: []jsNode{expr}}
+	for p.eat(",") {
+		seq.exprs = append(seq.exprs, p.parseAssign(noIn))
+	}
+	seq.jsSpan = jsSpan{start, p.prevEnd}
+	return seq
+}
+
+var jsAssignOps = map[string]bool{
+	"=": true, "+=": true, "-=": true, "*=": true, "/=": true, "%=": true,
+	"**=": true, "<<=": true, ">>=": true, ">>>=": true, "&=": true,
+	"|=": true, "^=": true, "&&=": true, "||=": true, "??=": true,
+}
+
+func (p *jsParser) parseAssign(noIn bool) jsNode {
+	start := p.tok.start
+
+	if p.is("yield") && p.inGenerator {
+		return p.parseYield(noIn)
+	}
+	if arrow := p.tryArrowFunction(noIn); arrow != nil {
+		return arrow
+	}
+
+	left := p.parseConditional(noIn)
+	if p.tok.kind == jsRegExp {
+		p.rescanPunctuator()
+	}
+	if p.tok.kind == jsPunctuator && jsAssignOps[p.tok.text] {
+		op := p.tok.text
+		p.next()
+		right := p.parseAssign(noIn)
+		return &jsBinary{jsSpan{start, p.prevEnd}, op, left, right}
+	}
+	return left
+}
+
+func (p *jsParser) parseYield(noIn bool) jsNode {
+	start := p.tok.start
+	p.next()
+	y := &jsYield{}
+	if !p.tok.newlineBefore {
+		if p.eat("*") {
+			y.delegate = true
+			y.arg = p.parseAssign(noIn)
+		} else if !p.is(")") && !p.is("]") && !p.is("}") && !p.is(",") && !p.is(";") && !p.is(":") && p.tok.kind != jsEOF && !p.is("in") {
+			y.arg = p.parseAssign(noIn)
+		}
+	}
+	y.jsSpan = jsSpan{start, p.prevEnd}
+	return y
+}
+
+// skipBalanced advances past a bracketed token sequence starting at the
+// current opening token. It is only used for lookahead.
+func (p *jsParser) skipBalanced() {
+	depth := 0
+	for {
+		switch {
+		case p.tok.kind == jsEOF:
+			return
+		case p.is("(") || p.is("[") || p.is("{"):
+			depth++
+		case p.is(")") || p.is("]") || p.is("}"):
+			depth--
+		}
+		p.next()
+		if depth == 0 {
+			return
+		}
+	}
+}
+
+// looksLikeArrow scans ahead from "(" to see whether the matching ")" is
+// followed by => (or a return type annotation in TypeScript)
+func (p *jsParser) looksLikeArrow() bool {
+	state := p.snapshot()
+	defer p.restore(state)
+
+	ok := p.try(func() {
+		p.skipBalanced()
+	})
+	if !ok {
+		return false
+	}
+	if p.is("=>") && !p.tok.newlineBefore {
+		return true
+	}
+	return p.dialect.typescript && p.is(":")
+}
+
+// tryArrowFunction parses an arrow function if one starts at the current
+// token, returning nil (with nothing consumed) otherwise
+func (p *jsParser) tryArrowFunction(noIn bool) jsNode {
+	start := p.tok.start
+
+	switch {
+	case p.isBindingIdentifier():
+		next := p.peek()
+		if p.isIdent("async") && !next.newlineBefore {
+			if next.kind == jsIdentifier {
+				state := p.snapshot()
+				p.next()
+				if p.peek().is(jsPunctuator, "=>") {
+					return p.parseArrowFunction(start, true, noIn)
+				}
+				p.restore(state)
+			}
+			if next.is(jsPunctuator, "(") || (p.dialect.typescript && next.is(jsPunctuator, "<")) {
+				var arrow jsNode
+				p.try(func() {
+					p.next()
+					arrow = p.parseArrowFunction(start, true, noIn)
+				})
+				if arrow != nil {
+					return arrow
+				}
+			}
+		}
+		if next.is(jsPunctuator, "=>") && !next.newlineBefore {
+			return p.parseArrowFunction(start, false, noIn)
+		}
+	case p.is("("):
+		if !p.looksLikeArrow() {
+			return nil
+		}
+		var arrow jsNode
+		if p.try(func() { arrow = p.parseArrowFunction(start, false, noIn) }) {
+			return arrow
+		}
+	case p.is("<") && p.dialect.typescript:
+		var arrow jsNode
+		if p.try(func() { arrow = p.parseArrowFunction(start, false, noIn) }) {
+			return arrow
+		}
+	}
+	return nil
+}
+
+func (p *jsParser) parseArrowFunction(start int, async, noIn bool) jsNode {
+	fn := &jsFunction{arrow: true, async: async}
+
+	savedGenerator, savedAsync := p.inGenerator, p.inAsync
+	p.inGenerator, p.inAsync = false, async
+	defer func() {
+		p.inGenerator, p.inAsync = savedGenerator, savedAsync
+	}()
+
+	if p.dialect.typescript && p.is("<") {
+		fn.typeParams = p.parseTypeParams()
+	}
+	if p.is("(") {
+		fn.params = p.parseParams()
+	} else {
+		paramStart := p.tok.start
+		id := p.parseIdentifier()
+		fn.params = []*jsParam{{jsSpan: jsSpan{paramStart, p.prevEnd}, pattern: id}}
+	}
+	if p.dialect.typescript && p.is(":") {
+		p.next()
+		fn.returnType = p.parseReturnType()
+	}
+	if !p.is("=>") || p.tok.newlineBefore {
+		p.unexpected()
+	}
+	p.next()
+
+	if p.is("{") {
+		fn.body = p.parseBlock()
+	} else {
+		fn.exprBody = p.parseAssign(noIn)
+	}
+	fn.jsSpan = jsSpan{start, p.prevEnd}
+	return fn
+}
+
+func (p *jsParser) parseConditional(noIn bool) jsNode {
+	start := p.tok.start
+	test := p.parseBinary(0, noIn)
+	if !p.is("?") {
+		return test
+	}
+	p.next()
+	cons := p.parseAssign(false)
+	p.expect(":")
+	alt := p.parseAssign(noIn)
+	return &jsConditional{jsSpan{start, p.prevEnd}, test, cons, alt}
+}
+
+func (p *jsParser) binaryPrecedence(noIn bool) int {
+	switch p.tok.kind {
+	case jsPunctuator:
+		switch p.tok.text {
+		case "??":
+			return 1
+		case "||":
+			return 2
+		case "&&":
+			return 3
+		case "|":
+			return 4
+		case "^":
+			return 5
+		case "&":
+			return 6
+		case "==", "!=", "===", "!==":
+			return 7
+		case "<", ">", "<=", ">=":
+			return 8
+		case "<<", ">>", ">>>":
+			return 9
+		case "+", "-":
+			return 10
+		case "*", "/", "%":
+			return 11
+		case "**":
+			return 12
+		}
+	case jsKeyword:
+		switch p.tok.text {
+		case "instanceof":
+			return 8
+		case "in":
+			if !noIn {
+				return 8
+			}
+		}
+	case jsIdentifier:
+		if p.dialect.typescript && (p.tok.text == "as" || p.tok.text == "satisfies") && !p.tok.newlineBefore {
+			return 8
+		}
+	}
+	return 0
+}
+
+func (p *jsParser) rescanPunctuator() {
+	tok, err := p.lx.rescan(p.tok, p.lx.scanPunctuator)
+	if err != nil {
+		panic(jsBailout{err})
+	}
+	p.tok = tok
+}
+
+func (p *jsParser) parseBinary(minPrec int, noIn bool) jsNode {
+	start := p.tok.start
+	left := p.parseUnary()
+
+	for {
+		if p.tok.kind == jsRegExp {
+			// A slash after an operand is division, whatever the lexer guessed
+			p.rescanPunctuator()
+		}
+		prec := p.binaryPrecedence(noIn)
+		if prec == 0 || prec <= minPrec {
+			return left
+		}
+
+		op := p.tok.text
+		p.next()
+		if op == "as" || op == "satisfies" {
+			var typ jsNode
+			if op == "as" && p.is("const") {
+				typ = &tsKeywordType{jsSpan{p.tok.start, p.tok.end}, "const"}
+				p.next()
+			} else {
+				typ = p.parseType()
+			}
+			left = &tsAsExpr{jsSpan{start, p.prevEnd}, op, left, typ}
+			continue
+		}
+
+		var right jsNode
+		if op == "**" {
+			right = p.parseBinary(prec-1, noIn)
+		} else {
+			right = p.parseBinary(prec, noIn)
+		}
+		left = &jsBinary{jsSpan{start, p.prevEnd}, op, left, right}
+	}
+}
+
+func (p *jsParser) parseUnary() jsNode {
+	start := p.tok.start
+
+	switch {
+	case p.tok.kind == jsPunctuator && (p.tok.text == "!" || p.tok.text == "~" || p.tok.text == "+" || p.tok.text == "-"),
+		p.is("typeof"), p.is("void"), p.is("delete"):
+		op := p.tok.text
+		p.next()
+		arg := p.parseUnary()
+		return &jsUnary{jsSpan{start, p.prevEnd}, op, arg}
+	case p.is("++") || p.is("--"):
+		op := p.tok.text
+		p.next()
+		arg := p.parseUnary()
+		return &jsUpdate{jsSpan{start, p.prevEnd}, op, true, arg}
+	case p.is("await") && p.inAsync:
+		p.next()
+		arg := p.parseUnary()
+		return &jsAwait{jsSpan{start, p.prevEnd}, arg}
+	case p.is("<") && p.dialect.typescript && !p.dialect.jsx:
+		p.next()
+		typ := p.parseType()
+		p.expectGreater()
+		expr := p.parseUnary()
+		return &tsTypeAssertion{jsSpan{start, p.prevEnd}, typ, expr}
+	}
+
+	expr := p.parseLHSExpression()
+	if (p.is("++") || p.is("--")) && !p.tok.newlineBefore {
+		op := p.tok.text
+		p.next()
+		return &jsUpdate{jsSpan{start, p.prevEnd}, op, false, expr}
+	}
+	return expr
+}
+
+func (p *jsParser) parseLHSExpression() jsNode {
+	var expr jsNode
+	if p.is("new") {
+		expr = p.parseNew()
+	} else {
+		expr = p.parsePrimary()
+	}
+	return p.parseCallTail(expr, true)
+}
+
+func (p *jsParser) parseNew() jsNode {
+	start := p.tok.start
+	p.next()
+	if p.is(".") {
+		p.next()
+		prop := p.parseIdentifierName()
+		return &jsMetaProperty{jsSpan{start, p.prevEnd}, "new." + prop.name}
+	}
+
+	var callee jsNode
+	if p.is("new") {
+		callee = p.parseNew()
+	} else {
+		callee = p.parsePrimary()
+	}
+	callee = p.parseCallTail(callee, false)
+
+	call := &jsCall{isNew: true, callee: callee}
+	if p.dialect.typescript && p.is("<") {
+		p.try(func() { call.typeArgs = p.parseTypeArgs() })
+	}
+	if p.is("(") {
+		call.args = p.parseArguments()
+		call.hasArgs = true
+	}
+	call.jsSpan = jsSpan{start, p.prevEnd}
+	return call
+}
+
+func (p *jsParser) parseCallTail(expr jsNode, allowCalls bool) jsNode {
+	start := nodeStart(expr)
+	for {
+		switch {
+		case p.is("."):
+			p.next()
+			prop := p.parseIdentifierName()
+			expr = &jsMember{jsSpan{start, p.prevEnd}, expr, prop, false, false}
+		case p.is("?.") && allowCalls:
+			p.next()
+			switch {
+			case p.is("("):
+				args := p.parseArguments()
+				expr = &jsCall{jsSpan: jsSpan{start, p.prevEnd}, callee: expr, args: args, optional: true, hasArgs: true}
+			case p.is("["):
+				p.next()
+				prop := p.parseExpression(false)
+				p.expect("]")
+				expr = &jsMember{jsSpan{start, p.prevEnd}, expr, prop, true, true}
+			default:
+				prop := p.parseIdentifierName()
+				expr = &jsMember{jsSpan{start, p.prevEnd}, expr, prop, false, true}
+			}
+		case p.is("["):
+			p.next()
+			prop := p.parseExpression(false)
+			p.expect("]")
+			expr = &jsMember{jsSpan{start, p.prevEnd}, expr, prop, true, false}
+		case p.is("(") && allowCalls:
+			args := p.parseArguments()
+			expr = &jsCall{jsSpan: jsSpan{start, p.prevEnd}, callee: expr, args: args, hasArgs: true}
+		case p.tok.kind == jsTemplate || p.tok.kind == jsTemplateHead:
+			tmpl := p.parseTemplate()
+			tmpl.tag = expr
+			tmpl.start = start
+			expr = tmpl
+		case p.dialect.typescript && p.is("!") && !p.tok.newlineBefore:
+			p.next()
+			expr = &tsNonNull{jsSpan{start, p.prevEnd}, expr}
+		case p.dialect.typescript && p.is("<") && allowCalls:
+			var typeArgs []jsNode
+			ok := p.try(func() {
+				typeArgs = p.parseTypeArgs()
+				if !p.is("(") && p.tok.kind != jsTemplate && p.tok.kind != jsTemplateHead {
+					p.unexpected()
+				}
+			})
+			if !ok {
+				return expr
+			}
+			if p.is("(") {
+				args := p.parseArguments()
+				expr = &jsCall{jsSpan: jsSpan{start, p.prevEnd}, callee: expr, typeArgs: typeArgs, args: args, hasArgs: true}
+			} else {
+				tmpl := p.parseTemplate()
+				tmpl.tag = expr
+				tmpl.typeArgs = typeArgs
+				tmpl.start = start
+				expr = tmpl
+			}
+		default:
+			return expr
+		}
+	}
+}
+
+func (p *jsParser) parseArguments() []jsNode {
+	p.expect("(")
+	var args []jsNode
+	for !p.is(")") {
+		if p.is("...") {
+			start := p.tok.start
+			p.next()
+			arg := p.parseAssign(false)
+			args = append(args, &jsSpread{jsSpan{start, p.prevEnd}, arg})
+		} else {
+			args = append(args, p.parseAssign(false))
+		}
+		if !p.eat(",") {
+			break
+		}
+	}
+	p.expect(")")
+	return args
+}
+
+func (p *jsParser) parseLiteral() *jsLiteral {
+	lit := &jsLiteral{jsSpan{p.tok.start, p.tok.end}, p.tok.text, p.tok.kind}
+	p.next()
+	return lit
+}
+
+func (p *jsParser) parsePrimary() jsNode {
+	start := p.tok.start
+
+	switch p.tok.kind {
+	case jsIdentifier:
+		if p.isIdent("async") {
+			if next := p.peek(); next.is(jsKeyword, "function") && !next.newlineBefore {
+				p.next()
+				return p.parseFunction(start, true, true)
+			}
+		}
+		return p.parseIdentifier()
+	case jsPrivateName:
+		return p.parseIdentifierName()
+	case jsNumber, jsString:
+		return p.parseLiteral()
+	case jsRegExp:
+		return p.parseLiteral()
+	case jsTemplate, jsTemplateHead:
+		return p.parseTemplate()
+	case jsKeyword:
+		switch p.tok.text {
+		case "this", "super", "null", "true", "false":
+			return p.parseLiteral()
+		case "function":
+			return p.parseFunction(start, false, true)
+		case "class":
+			return p.parseClass(nil, nil, true)
+		case "new":
+			return p.parseNew()
+		case "import":
+			p.next()
+			if p.is(".") {
+				p.next()
+				prop := p.parseIdentifierName()
+				return &jsMetaProperty{jsSpan{start, p.prevEnd}, "import." + prop.name}
+			}
+			if !p.is("(") {
+				p.unexpected()
+			}
+			return &jsIdent{jsSpan{start, p.prevEnd}, "import"}
+		case "yield", "await":
+			return p.parseIdentifierName()
+		}
+	case jsPunctuator:
+		switch p.tok.text {
+		case "(":
+			p.next()
+			expr := p.parseExpression(false)
+			p.expect(")")
+			return &jsParen{jsSpan{start, p.prevEnd}, expr}
+		case "[":
+			return p.parseArrayLiteral()
+		case "{":
+			return p.parseObjectLiteral()
+		case "/", "/=":
+			tok, err := p.lx.rescan(p.tok, p.lx.scanRegExp)
+			if err != nil {
+				panic(jsBailout{err})
+			}
+			p.tok = tok
+			return p.parseLiteral()
+		case "<":
+			if p.dialect.jsx {
+				return p.parseJSXElement()
+			}
+		case "@":
+			decorators := p.parseDecorators()
+			return p.parseClass(decorators, nil, true)
+		}
+	}
+
+	p.unexpected()
+	return nil
+}
+
+func (p *jsParser) parseArrayLiteral() jsNode {
+	start := p.tok.start
+	p.expect("[")
+	arr := &jsArray{}
+	for !p.is("]") {
+		if p.is(",") {
+			p.next()
+			arr.elems = append(arr.elems, nil)
+			continue
+		}
+		var elem jsNode
+		if p.is("...") {
+			spreadStart := p.tok.start
+			p.next()
+			arg := p.parseAssign(false)
+			elem = &jsSpread{jsSpan{spreadStart, p.prevEnd}, arg}
+		} else {
+			elem = p.parseAssign(false)
+		}
+		arr.elems = append(arr.elems, elem)
+		if !p.is("]") {
+			p.expect(",")
+		}
+	}
+	p.next()
+	arr.jsSpan = jsSpan{start, p.prevEnd}
+	return arr
+}
+
+func (p *jsParser) parseObjectLiteral() *jsObject {
+	start := p.tok.start
+	p.expect("{")
+	obj := &jsObject{multiline: p.tok.newlineBefore}
+	for !p.is("}") {
+		obj.props = append(obj.props, p.parseObjectMember())
+		if !p.is("}") {
+			p.expect(",")
+		}
+	}
+	p.next()
+	obj.jsSpan = jsSpan{start, p.prevEnd}
+	return obj
+}
+
+func (p *jsParser) parseObjectMember() jsNode {
+	start := p.tok.start
+	if p.is("...") {
+		p.next()
+		arg := p.parseAssign(false)
+		return &jsSpread{jsSpan{start, p.prevEnd}, arg}
+	}
+
+	kind, async, generator := p.parseMethodPrefix()
+	key, computed := p.parsePropertyKey()
+	prop := &jsProperty{kind: "init", key: key, computed: computed}
+
+	switch {
+	case p.is("(") || p.is("<"):
+		if kind == "method" {
+			prop.kind = "method"
+		} else {
+			prop.kind = kind
+		}
+		fn := &jsFunction{async: async, generator: generator}
+		fnStart := p.tok.start
+		p.parseFunctionRest(fn)
+		fn.jsSpan = jsSpan{fnStart, p.prevEnd}
+		prop.fn = fn
+	case kind != "method" || async || generator:
+		p.unexpected()
+	case p.is(":"):
+		p.next()
+		prop.value = p.parseAssign(false)
+	default:
+		id, ok := key.(*jsIdent)
+		if !ok || computed {
+			p.unexpected()
+		}
+		prop.shorthand = true
+		prop.value = id
+		if p.is("=") {
+			// Default value in a destructuring pattern
+			p.next()
+			def := p.parseAssign(false)
+			prop.value = &jsBinary{jsSpan{start, p.prevEnd}, "=", id, def}
+		}
+	}
+
+	prop.jsSpan = jsSpan{start, p.prevEnd}
+	return prop
+}
+
+func (p *jsParser) parseTemplate() *jsTemplateLiteral {
+	start := p.tok.start
+	tmpl := &jsTemplateLiteral{quasis: []string{p.tok.text}}
+	if p.tok.kind == jsTemplate {
+		p.next()
+		tmpl.jsSpan = jsSpan{start, p.prevEnd}
+		return tmpl
+	}
+
+	for {
+		p.next()
+		tmpl.exprs = append(tmpl.exprs, p.parseExpression(false))
+		switch p.tok.kind {
+		case jsTemplateMiddle:
+			tmpl.quasis = append(tmpl.quasis, p.tok.text)
+		case jsTemplateTail:
+			tmpl.quasis = append(tmpl.quasis, p.tok.text)
+			p.next()
+			tmpl.jsSpan = jsSpan{start, p.prevEnd}
+			return tmpl
+		default:
+			p.fail(p.tok.start, "expected } to close template substitution")
+		}
+	}
+}
+
+// JSX
+
+func (p *jsParser) jsxSkipSpace(pos int) int {
+	for pos < len(p.src) {
+		switch p.src[pos] {
+		case ' ', '\t', '\n', '\r':
+			pos++
+			continue
+		case '/':
+			if strings.HasPrefix(p.src[pos:], "/*") {
+				end := strings.Index(p.src[pos+2:], "*/")
+				if end < 0 {
+					p.fail(pos, "unterminated comment")
+				}
+				p.comments = append(p.comments, jsToken{kind: jsBlockComment, text: p.src[pos : pos+2+end+2], start: pos, end: pos + 2 + end + 2})
+				pos += 2 + end + 2
+				continue
+			}
+			if strings.HasPrefix(p.src[pos:], "//") {
+				end := strings.IndexByte(p.src[pos:], '\n')
+				if end < 0 {
+					end = len(p.src) - pos
+				}
+				p.comments = append(p.comments, jsToken{kind: jsLineComment, text: p.src[pos : pos+end], start: pos, end: pos + end})
+				pos += end
+				continue
+			}
+		}
+		return pos
+	}
+	return pos
+}
+
+func isJSXNameChar(c byte) bool {
+	return isAlphaNumeric(c) || c == '-' || c == '.' || c == ':' || c >= 0x80
+}
+
+func (p *jsParser) jsxName(pos int) (string, int) {
+	start := pos
+	for pos < len(p.src) && isJSXNameChar(p.src[pos]) {
+		pos++
+	}
+	if pos == start {
+		p.fail(pos, "expected JSX name")
+	}
+	return p.src[start:pos], pos
+}
+
+// jsxExpression parses a JavaScript expression inside braces starting at
+// pos, returning the expression (nil if empty) and the offset after "}"
+func (p *jsParser) jsxExpression(pos int) (jsNode, bool, int) {
+	p.lx.pos = pos + 1
+	p.lx.braces = append(p.lx.braces, jsBraceExpression)
+	p.lx.prev = jsToken{kind: jsPunctuator, text: "{"}
+	p.next()
+
+	var expr jsNode
+	spread := false
+	if !p.is("}") {
+		if p.is("...") {
+			p.next()
+			spread = true
+		}
+		expr = p.parseExpression(false)
+	}
+	if !p.is("}") {
+		p.fail(p.tok.start, "expected } in JSX expression")
+	}
+	return expr, spread, p.tok.end
+}
+
+func (p *jsParser) parseJSXElement() jsNode {
+	start := p.tok.start
+	el, end := p.jsxElementAt(start)
+
+	// Resume normal lexing after the element; a slash following it is division
+	p.lx.pos = end
+	p.lx.prev = jsToken{kind: jsString}
+	p.tok = jsToken{kind: jsString, start: start, end: end}
+	p.next()
+	return el
+}
+
+// jsxElementAt parses a JSX element whose "<" is at start and returns it
+// with the offset just past its end
+func (p *jsParser) jsxElementAt(start int) (*jsxElement, int) {
+	el := &jsxElement{}
+	pos := p.jsxSkipSpace(start + 1)
+
+	if pos < len(p.src) && p.src[pos] == '>' {
+		// Fragment
+		pos++
+	} else {
+		el.name, pos = p.jsxName(pos)
+		if p.dialect.typescript && pos < len(p.src) && p.src[pos] == '<' {
+			p.lx.pos = pos
+			p.next()
+			el.typeArgs = p.parseTypeArgs()
+			pos = p.prevEnd
+		}
+		for {
+			pos = p.jsxSkipSpace(pos)
+			if pos >= len(p.src) {
+				p.fail(start, "unterminated JSX element")
+			}
+			if strings.HasPrefix(p.src[pos:], "/>") {
+				el.selfClosing = true
+				el.jsSpan = jsSpan{start, pos + 2}
+				return el, pos + 2
+			}
+			if p.src[pos] == '>' {
+				pos++
+				break
+			}
+			if p.src[pos] == '{' {
+				attrStart := pos
+				expr, _, end := p.jsxExpression(pos)
+				if expr == nil {
+					p.fail(pos, "expected spread in JSX attributes")
+				}
+				el.attrs = append(el.attrs, &jsxSpreadAttribute{jsSpan{attrStart, end}, expr})
+				pos = end
+				continue
+			}
+
+			attr := &jsxAttribute{jsSpan: jsSpan{start: pos}}
+			attr.name, pos = p.jsxName(pos)
+			pos = p.jsxSkipSpace(pos)
+			if pos < len(p.src) && p.src[pos] == '=' {
+				pos = p.jsxSkipSpace(pos + 1)
+				if pos >= len(p.src) {
+					p.fail(start, "unterminated JSX element")
+				}
+				switch c := p.src[pos]; c {
+				case '"', '\'':
+					end := strings.IndexByte(p.src[pos+1:], c)
+					if end < 0 {
+						p.fail(pos, "unterminated JSX attribute string")
+					}
+					attr.value = &jsLiteral{jsSpan{pos, pos + end + 2}, p.src[pos : pos+end+2], jsString}
+					pos += end + 2
+				case '{':
+					valueStart := pos
+					expr, _, end := p.jsxExpression(pos)
+					attr.value = &jsxExprContainer{jsSpan{valueStart, end}, expr, false}
+					pos = end
+				case '<':
+					child, end := p.jsxElementAt(pos)
+					attr.value = child
+					pos = end
+				default:
+					p.fail(pos, "unexpected character in JSX attribute value")
+				}
+			}
+			attr.end = pos
+			el.attrs = append(el.attrs, attr)
+		}
+	}
+
+	// Children up to the closing tag
+	for {
+		if pos >= len(p.src) {
+			p.fail(start, "unterminated JSX element")
+		}
+		textStart := pos
+		for pos < len(p.src) && p.src[pos] != '<' && p.src[pos] != '{' {
+			pos++
+		}
+		if pos > textStart {
+			el.children = append(el.children, &jsxText{jsSpan{textStart, pos}, p.src[textStart:pos]})
+			continue
+		}
+
+		if p.src[pos] == '{' {
+			childStart := pos
+			expr, spread, end := p.jsxExpression(pos)
+			el.children = append(el.children, &jsxExprContainer{jsSpan{childStart, end}, expr, spread})
+			pos = end
+			continue
+		}
+
+		// '<': closing tag or nested element
+		after := p.jsxSkipSpace(pos + 1)
+		if after < len(p.src) && p.src[after] == '/' {
+			closeStart := pos
+			pos = p.jsxSkipSpace(after + 1)
+			name := ""
+			if pos < len(p.src) && p.src[pos] != '>' {
+				name, pos = p.jsxName(pos)
+			}
+			if name != el.name {
+				p.fail(closeStart, "expected corresponding closing tag for <%s>", el.name)
+			}
+			pos = p.jsxSkipSpace(pos)
+			if pos >= len(p.src) || p.src[pos] != '>' {
+				p.fail(closeStart, "unterminated closing tag")
+			}
+			el.jsSpan = jsSpan{start, pos + 1}
+			return el, pos + 1
+		}
+		child, end := p.jsxElementAt(pos)
+		el.children = append(el.children, child)
+		pos = end
+	}
+}
+
+// TypeScript declarations
+
+func (p *jsParser) parseTypeAlias(start int) jsNode {
+	p.next()
+	alias := &tsTypeAlias{name: p.parseIdentifierName()}
+	if p.is("<") {
+		alias.typeParams = p.parseTypeParams()
+	}
+	p.expect("=")
+	alias.typ = p.parseType()
+	p.consumeSemicolon()
+	alias.jsSpan = jsSpan{start, p.prevEnd}
+	return alias
+}
+
+func (p *jsParser) parseInterface(start int) jsNode {
+	p.next()
+	iface := &tsInterface{name: p.parseIdentifier()}
+	if p.is("<") {
+		iface.typeParams = p.parseTypeParams()
+	}
+	if p.eat("extends") {
+		for {
+			iface.extends = append(iface.extends, p.parseType())
+			if !p.eat(",") {
+				break
+			}
+		}
+	}
+	iface.body = p.parseTypeLiteral()
+	iface.jsSpan = jsSpan{start, p.prevEnd}
+	return iface
+}
+
+func (p *jsParser) parseEnum(start int, modifiers []string) jsNode {
+	if p.is("const") {
+		modifiers = append(modifiers, "const")
+		p.next()
+	}
+	p.expect("enum")
+	enum := &tsEnum{modifiers: modifiers, name: p.parseIdentifier()}
+	p.expect("{")
+	for !p.is("}") {
+		member := &tsEnumMember{jsSpan: jsSpan{start: p.tok.start}}
+		if p.tok.kind == jsString {
+			member.name = p.parseLiteral()
+		} else {
+			member.name = p.parseIdentifierName()
+		}
+		if p.eat("=") {
+			member.init = p.parseAssign(false)
+		}
+		member.end = p.prevEnd
+		enum.members = append(enum.members, member)
+		if !p.eat(",") {
+			break
+		}
+	}
+	p.expect("}")
+	enum.jsSpan = jsSpan{start, p.prevEnd}
+	return enum
+}
+
+func (p *jsParser) parseModule(start int) jsNode {
+	module := &tsModule{keyword: p.tok.text}
+	if module.keyword == "global" {
+		module.name = &jsIdent{jsSpan{p.tok.start, p.tok.end}, "global"}
+		module.keyword = ""
+		p.next()
+	} else {
+		p.next()
+		if p.tok.kind == jsString {
+			module.name = p.parseLiteral()
+		} else {
+			module.name = p.parseEntityName()
+		}
+	}
+	if p.is("{") {
+		module.body = p.parseBlock()
+	} else {
+		p.consumeSemicolon()
+	}
+	module.jsSpan = jsSpan{start, p.prevEnd}
+	return module
+}
+
+// TypeScript types
+
+// expectGreater consumes a ">" that closes type arguments, splitting
+// compound tokens such as ">>" the lexer produced
+func (p *jsParser) expectGreater() {
+	if p.is(">") {
+		p.next()
+		return
+	}
+	if p.tok.kind == jsPunctuator && strings.HasPrefix(p.tok.text, ">") {
+		p.prevEnd = p.tok.start + 1
+		tok, err := p.lx.rescan(jsToken{start: p.tok.start + 1}, p.lx.scanPunctuator)
+		if err != nil {
+			panic(jsBailout{err})
+		}
+		p.tok = tok
+		return
+	}
+	p.fail(p.tok.start, "expected > but found %s", p.tok.text)
+}
+
+func (p *jsParser) parseTypeParams() *tsTypeParams {
+	start := p.tok.start
+	p.expect("<")
+	params := &tsTypeParams{}
+	for !p.is(">") {
+		param := &tsTypeParam{jsSpan: jsSpan{start: p.tok.start}}
+		for (p.is("in") || p.isIdent("out") || p.is("const")) && p.peek().kind == jsIdentifier {
+			param.modifiers = append(param.modifiers, p.tok.text)
+			p.next()
+		}
+		param.name = p.parseIdentifierName()
+		if p.eat("extends") {
+			param.constraint = p.parseType()
+		}
+		if p.eat("=") {
+			param.def = p.parseType()
+		}
+		param.end = p.prevEnd
+		params.params = append(params.params, param)
+		if !p.eat(",") {
+			break
+		}
+	}
+	p.expectGreater()
+	params.jsSpan = jsSpan{start, p.prevEnd}
+	return params
+}
+
+func (p *jsParser) parseTypeArgs() []jsNode {
+	p.expect("<")
+	var args []jsNode
+	for !p.is(">") {
+		args = append(args, p.parseType())
+		if !p.eat(",") {
+			break
+		}
+	}
+	p.expectGreater()
+	return args
+}
+
+// parseReturnType parses a return annotation, which may be a type predicate
+func (p *jsParser) parseReturnType() jsNode {
+	start := p.tok.start
+	if p.isIdent("asserts") {
+		if next := p.peek(); (next.kind == jsIdentifier || next.is(jsKeyword, "this")) && !next.newlineBefore {
+			p.next()
+			pred := &tsTypePredicate{asserts: true, param: p.tok.text}
+			p.next()
+			if p.isIdent("is") {
+				p.next()
+				pred.typ = p.parseType()
+			}
+			pred.jsSpan = jsSpan{start, p.prevEnd}
+			return pred
+		}
+	}
+	if p.tok.kind == jsIdentifier || p.is("this") {
+		if next := p.peek(); next.kind == jsIdentifier && next.text == "is" && !next.newlineBefore {
+			pred := &tsTypePredicate{param: p.tok.text}
+			p.next()
+			p.next()
+			pred.typ = p.parseType()
+			pred.jsSpan = jsSpan{start, p.prevEnd}
+			return pred
+		}
+	}
+	return p.parseType()
+}
+
+func (p *jsParser) parseType() jsNode {
+	start := p.tok.start
+
+	if p.is("<") || p.is("new") || (p.isIdent("abstract") && p.peek().is(jsKeyword, "new")) ||
+		(p.is("(") && p.looksLikeFunctionType()) {
+		return p.parseFunctionType()
+	}
+
+	typ := p.parseUnionType()
+	if p.is("extends") && !p.tok.newlineBefore {
+		cond := &tsConditionalType{check: typ}
+		state := p.snapshot()
+		p.next()
+		cond.extends = p.parseUnionType()
+		if !p.is("?") {
+			// An extends clause for an infer constraint, not a conditional
+			p.restore(state)
+			return typ
+		}
+		p.next()
+		cond.trueType = p.parseType()
+		p.expect(":")
+		cond.falseType = p.parseType()
+		cond.jsSpan = jsSpan{start, p.prevEnd}
+		return cond
+	}
+	return typ
+}
+
+func (p *jsParser) looksLikeFunctionType() bool {
+	state := p.snapshot()
+	defer p.restore(state)
+	ok := p.try(func() { p.skipBalanced() })
+	return ok && p.is("=>")
+}
+
+func (p *jsParser) parseFunctionType() jsNode {
+	start := p.tok.start
+	fn := &tsFunctionType{}
+	if p.isIdent("abstract") {
+		p.next()
+		fn.abstract = true
+	}
+	if p.eat("new") {
+		fn.construct = true
+	}
+	if p.is("<") {
+		fn.typeParams = p.parseTypeParams()
+	}
+	fn.params = p.parseParams()
+	p.expect("=>")
+	fn.returnType = p.parseReturnType()
+	fn.jsSpan = jsSpan{start, p.prevEnd}
+	return fn
+}
+
+func (p *jsParser) parseUnionType() jsNode {
+	return p.parseTypeList("|", p.parseIntersectionType)
+}
+
+func (p *jsParser) parseIntersectionType() jsNode {
+	return p.parseTypeList("&", p.parseTypeOperator)
+}
+
+func (p *jsParser) parseTypeList(op string, parseOperand func() jsNode) jsNode {
+	start := p.tok.start
+	leading := p.eat(op)
+	types := []jsNode{parseOperand()}
+	for p.eat(op) {
+		types = append(types, parseOperand())
+	}
+	if len(types) == 1 && !leading {
+		return types[0]
+	}
+	return &tsUnion{jsSpan{start, p.prevEnd}, op, types}
+}
+
+func (p *jsParser) parseTypeOperator() jsNode {
+	start := p.tok.start
+	if p.tok.kind == jsIdentifier {
+		switch p.tok.text {
+		case "keyof", "unique", "readonly":
+			if next := p.peek(); !next.is(jsPunctuator, ")") && !next.is(jsPunctuator, ",") &&
+				!next.is(jsPunctuator, "]") && !next.is(jsPunctuator, ">") && !next.is(jsPunctuator, "=") &&
+				!next.is(jsPunctuator, ";") && !next.is(jsPunctuator, "|") && !next.is(jsPunctuator, "&") {
+				op := p.tok.text
+				p.next()
+				typ := p.parseTypeOperator()
+				return &tsTypeOperator{jsSpan{start, p.prevEnd}, op, typ}
+			}
+		case "infer":
+			if p.peek().kind == jsIdentifier {
+				p.next()
+				infer := &tsInferType{name: p.parseIdentifierName()}
+				if p.is("extends") {
+					state := p.snapshot()
+					p.next()
+					constraint := p.parseUnionType()
+					if p.is("?") {
+						// extends belongs to an enclosing conditional type
+						p.restore(state)
+					} else {
+						infer.constraint = constraint
+					}
+				}
+				infer.jsSpan = jsSpan{start, p.prevEnd}
+				return infer
+			}
+		}
+	}
+	return p.parsePostfixType()
+}
+
+func (p *jsParser) parsePostfixType() jsNode {
+	start := p.tok.start
+	typ := p.parsePrimaryType()
+	for p.is("[") && !p.tok.newlineBefore {
+		p.next()
+		if p.eat("]") {
+			typ = &tsArrayType{jsSpan{start, p.prevEnd}, typ}
+			continue
+		}
+		index := p.parseType()
+		p.expect("]")
+		typ = &tsIndexedAccess{jsSpan{start, p.prevEnd}, typ, index}
+	}
+	return typ
+}
+
+func (p *jsParser) parsePrimaryType() jsNode {
+	start := p.tok.start
+
+	switch p.tok.kind {
+	case jsString, jsNumber:
+		text := p.tok.text
+		p.next()
+		return &tsKeywordType{jsSpan{start, p.prevEnd}, text}
+	case jsTemplate:
+		text := p.tok.text
+		p.next()
+		return &tsKeywordType{jsSpan{start, p.prevEnd}, text}
+	case jsTemplateHead:
+		return p.parseTemplateType()
+	case jsKeyword:
+		switch p.tok.text {
+		case "this", "null", "true", "false", "void", "undefined":
+			text := p.tok.text
+			p.next()
+			return &tsKeywordType{jsSpan{start, p.prevEnd}, text}
+		case "typeof":
+			p.next()
+			query := &tsTypeQuery{}
+			if p.is("import") {
+				query.expr = p.src[p.tok.start:nodeEnd(p.parseImportType())]
+			} else {
+				query.expr = p.parseEntityName().name
+			}
+			if p.is("<") && !p.tok.newlineBefore {
+				query.args = p.parseTypeArgs()
+			}
+			query.jsSpan = jsSpan{start, p.prevEnd}
+			return query
+		case "import":
+			return p.parseImportType()
+		}
+	case jsIdentifier:
+		name := p.parseEntityName().name
+		ref := &tsTypeRef{name: name}
+		if p.is("<") && !p.tok.newlineBefore {
+			ref.args = p.parseTypeArgs()
+		}
+		ref.jsSpan = jsSpan{start, p.prevEnd}
+		return ref
+	case jsPunctuator:
+		switch p.tok.text {
+		case "(":
+			p.next()
+			typ := p.parseType()
+			p.expect(")")
+			return &tsParenType{jsSpan{start, p.prevEnd}, typ}
+		case "[":
+			return p.parseTupleType()
+		case "{":
+			if p.isMappedType() {
+				return p.parseMappedType()
+			}
+			return p.parseTypeLiteral()
+		case "-":
+			p.next()
+			if p.tok.kind != jsNumber {
+				p.unexpected()
+			}
+			p.next()
+			return &tsKeywordType{jsSpan{start, p.prevEnd}, p.src[start:p.prevEnd]}
+		case "*", "?":
+			text := p.tok.text
+			p.next()
+			return &tsKeywordType{jsSpan{start, p.prevEnd}, text}
+		}
+	}
+
+	p.unexpected()
+	return nil
+}
+
+func (p *jsParser) parseImportType() jsNode {
+	start := p.tok.start
+	p.expect("import")
+	p.expect("(")
+	if p.tok.kind != jsString {
+		p.unexpected()
+	}
+	imp := &tsImportType{arg: p.parseLiteral()}
+	p.expect(")")
+	if p.is(".") {
+		p.next()
+		imp.qualifier = p.parseEntityName().name
+	}
+	if p.is("<") && !p.tok.newlineBefore {
+		imp.args = p.parseTypeArgs()
+	}
+	imp.jsSpan = jsSpan{start, p.prevEnd}
+	return imp
+}
+
+func (p *jsParser) parseTemplateType() jsNode {
+	start := p.tok.start
+	tmpl := &tsTemplateType{quasis: []string{p.tok.text}}
+	for {
+		p.next()
+		tmpl.types = append(tmpl.types, p.parseType())
+		tmpl.quasis = append(tmpl.quasis, p.tok.text)
+		if p.tok.kind == jsTemplateTail {
+			p.next()
+			tmpl.jsSpan = jsSpan{start, p.prevEnd}
+			return tmpl
+		}
+		if p.tok.kind != jsTemplateMiddle {
+			p.fail(p.tok.start, "expected } to close template type substitution")
+		}
+	}
+}
+
+func (p *jsParser) parseTupleType() jsNode {
+	start := p.tok.start
+	p.expect("[")
+	tuple := &tsTuple{}
+	for !p.is("]") {
+		elem := &tsTupleElem{jsSpan: jsSpan{start: p.tok.start}}
+		if p.eat("...") {
+			elem.rest = true
+		}
+		if p.tok.kind == jsIdentifier || p.tok.kind == jsKeyword {
+			if next := p.peek(); next.is(jsPunctuator, ":") || (next.is(jsPunctuator, "?") && p.isNamedTupleOptional()) {
+				elem.name = p.tok.text
+				p.next()
+				if p.eat("?") {
+					elem.optional = true
+				}
+				p.expect(":")
+			}
+		}
+		elem.typ = p.parseType()
+		if elem.name == "" && p.is("?") {
+			p.next()
+			elem.optional = true
+		}
+		elem.end = p.prevEnd
+		tuple.elems = append(tuple.elems, elem)
+		if !p.eat(",") {
+			break
+		}
+	}
+	p.expect("]")
+	tuple.jsSpan = jsSpan{start, p.prevEnd}
+	return tuple
+}
+
+// isNamedTupleOptional distinguishes [name?: T] from [T?]
+func (p *jsParser) isNamedTupleOptional() bool {
+	state := p.snapshot()
+	defer p.restore(state)
+	p.next()
+	p.next()
+	return p.is(":")
+}
+
+func (p *jsParser) isMappedType() bool {
+	state := p.snapshot()
+	defer p.restore(state)
+	p.next()
+	if p.is("+") || p.is("-") {
+		p.next()
+	}
+	if p.isIdent("readonly") {
+		p.next()
+	}
+	if !p.is("[") {
+		return false
+	}
+	p.next()
+	if p.tok.kind != jsIdentifier && p.tok.kind != jsKeyword {
+		return false
+	}
+	p.next()
+	return p.is("in")
+}
+
+func (p *jsParser) parseMappedType() jsNode {
+	start := p.tok.start
+	p.expect("{")
+	mapped := &tsMappedType{}
+	if p.is("+") || p.is("-") {
+		mapped.readonly = p.tok.text
+		p.next()
+	}
+	if p.isIdent("readonly") {
+		mapped.readonly += "readonly"
+		p.next()
+	}
+	p.expect("[")
+	mapped.param = p.parseIdentifierName().name
+	p.expect("in")
+	mapped.constraint = p.parseType()
+	if p.isIdent("as") {
+		p.next()
+		mapped.nameType = p.parseType()
+	}
+	p.expect("]")
+	if p.is("+") || p.is("-") {
+		mapped.optional = p.tok.text
+		p.next()
+		p.expect("?")
+		mapped.optional += "?"
+	} else if p.eat("?") {
+		mapped.optional = "?"
+	}
+	if p.eat(":") {
+		mapped.typ = p.parseType()
+	}
+	p.eat(";")
+	p.eat(",")
+	p.expect("}")
+	mapped.jsSpan = jsSpan{start, p.prevEnd}
+	return mapped
+}
+
+func (p *jsParser) isIndexSignature() bool {
+	state := p.snapshot()
+	defer p.restore(state)
+	p.next()
+	if p.tok.kind != jsIdentifier && p.tok.kind != jsKeyword {
+		return false
+	}
+	p.next()
+	return p.is(":")
+}
+
+func (p *jsParser) parseIndexSignature(start int, modifiers []string) *tsIndexSig {
+	p.expect("[")
+	paramStart := p.tok.start
+	name := p.parseIdentifierName()
+	p.expect(":")
+	paramType := p.parseType()
+	p.expect("]")
+	sig := &tsIndexSig{
+		modifiers: modifiers,
+		param:     &jsParam{jsSpan: jsSpan{paramStart, p.prevEnd}, pattern: name, typeAnn: paramType},
+	}
+	if p.eat(":") {
+		sig.typ = p.parseType()
+	}
+	sig.jsSpan = jsSpan{start, p.prevEnd}
+	return sig
+}
+
+func (p *jsParser) parseTypeLiteral() *tsTypeLiteral {
+	start := p.tok.start
+	p.expect("{")
+	lit := &tsTypeLiteral{multiline: p.tok.newlineBefore}
+	for !p.is("}") {
+		if p.tok.kind == jsEOF {
+			p.fail(start, "unterminated type literal")
+		}
+		lit.members = append(lit.members, p.parseTypeMember())
+		if !p.eat(";") && !p.eat(",") && !p.is("}") && !p.tok.newlineBefore {
+			p.unexpected()
+		}
+	}
+	p.next()
+	lit.jsSpan = jsSpan{start, p.prevEnd}
+	return lit
+}
+
+func (p *jsParser) parseTypeMember() jsNode {
+	start := p.tok.start
+
+	if p.is("(") || p.is("<") {
+		return p.parseCallSignature(start, false)
+	}
+	if p.is("new") {
+		if next := p.peek(); next.is(jsPunctuator, "(") || next.is(jsPunctuator, "<") {
+			p.next()
+			return p.parseCallSignature(start, true)
+		}
+	}
+
+	var modifiers []string
+	for p.isIdent("readonly") || p.isIdent("static") || p.isIdent("public") || p.isIdent("private") || p.isIdent("protected") {
+		if !isMemberKeyStart(p.peek()) {
+			break
+		}
+		modifiers = append(modifiers, p.tok.text)
+		p.next()
+	}
+
+	if p.is("[") && p.isIndexSignature() {
+		return p.parseIndexSignature(start, modifiers)
+	}
+
+	sig := &tsPropertySig{modifiers: modifiers}
+	if p.isIdent("get") || p.isIdent("set") {
+		if next := p.peek(); isMemberKeyStart(next) && !next.newlineBefore {
+			sig.kind = p.tok.text
+			p.next()
+		}
+	}
+	sig.key, sig.computed = p.parsePropertyKey()
+	if p.eat("?") {
+		sig.optional = true
+	}
+	if p.is("(") || p.is("<") {
+		sig.method = true
+		if p.is("<") {
+			sig.typeParams = p.parseTypeParams()
+		}
+		sig.params = p.parseParams()
+		if p.eat(":") {
+			sig.typ = p.parseReturnType()
+		}
+	} else if p.eat(":") {
+		sig.typ = p.parseType()
+	}
+	sig.jsSpan = jsSpan{start, p.prevEnd}
+	return sig
+}
+
+func (p *jsParser) parseCallSignature(start int, construct bool) jsNode {
+	sig := &tsCallSig{construct: construct}
+	if p.is("<") {
+		sig.typeParams = p.parseTypeParams()
+	}
+	sig.params = p.parseParams()
+	if p.eat(":") {
+		sig.returnType = p.parseReturnType()
+	}
+	sig.jsSpan = jsSpan{start, p.prevEnd}
+	return sig
+}
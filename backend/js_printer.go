@@ -0,0 +1,1901 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// jsPrinter turns a parsed program back into source text through the doc
+// layout engine. Comments are not part of the tree; they are kept in source
+// order and attached to the nearest statement, member or list item.
+type jsPrinter struct {
+	src      string
+	dialect  jsDialect
+	comments []jsToken
+	done     []bool
+	next     int // index of the first comment that may still be pending
+	indent   string
+	quote    byte // preferred string delimiter, 0 to keep the source's
+}
+
+// formatJS parses code in the given dialect and pretty prints it
+func formatJS(code string, dialect jsDialect, opts *FormatOptions) (string, error) {
+	prog, comments, err := parseJS(code, dialect)
+	if err != nil {
+		return "", err
+	}
+
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].start < comments[j].start
+	})
+	pr := &jsPrinter{
+		src:      code,
+		dialect:  dialect,
+		comments: comments,
+		done:     make([]bool, len(comments)),
+		indent:   opts.indent(jsIndentSize),
+		quote:    opts.quote(),
+	}
+
+	body := pr.list(prog.body, len(code), pr.stmt)
+	if body == nil {
+		return "", nil
+	}
+	return renderDoc([]doc{body, hardLine}, opts.width(jsPrintWidth), pr.indent), nil
+}
+
+// Comments
+
+// pendingBefore returns the index of the first unprinted comment starting
+// before limit, or -1
+func (pr *jsPrinter) pendingBefore(limit int) int {
+	for pr.next < len(pr.comments) && pr.done[pr.next] {
+		pr.next++
+	}
+	for i := pr.next; i < len(pr.comments) && pr.comments[i].start < limit; i++ {
+		if !pr.done[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// markDone drops comments inside [start, end) that were printed verbatim
+func (pr *jsPrinter) markDone(start, end int) {
+	for i := pr.next; i < len(pr.comments) && pr.comments[i].start < end; i++ {
+		if pr.comments[i].start >= start {
+			pr.done[i] = true
+		}
+	}
+}
+
+// commentDoc prints a comment, re-indenting the continuation lines of
+// JSDoc-style block comments
+func (pr *jsPrinter) commentDoc(c jsToken) doc {
+	text := strings.TrimRight(c.text, "\r")
+	if c.kind != jsBlockComment || !strings.Contains(text, "\n") {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	for _, l := range lines[1:] {
+		if !strings.HasPrefix(strings.TrimSpace(l), "*") {
+			return text
+		}
+	}
+	parts := []doc{strings.TrimRight(lines[0], " \t\r")}
+	for _, l := range lines[1:] {
+		parts = append(parts, hardLine, " "+strings.TrimSpace(l))
+	}
+	return parts
+}
+
+// leadingComments prints the comments before pos, each followed by the
+// separator it had in the source
+func (pr *jsPrinter) leadingComments(pos int) []doc {
+	var parts []doc
+	for i := pr.pendingBefore(pos); i >= 0; i = pr.pendingBefore(pos) {
+		c := pr.comments[i]
+		pr.done[i] = true
+		parts = append(parts, pr.commentDoc(c))
+
+		nextStart := pos
+		if j := pr.pendingBefore(pos); j >= 0 {
+			nextStart = pr.comments[j].start
+		}
+		if nextStart > len(pr.src) {
+			nextStart = len(pr.src)
+		}
+		if nextStart < c.end {
+			nextStart = c.end
+		}
+		between := pr.src[c.end:nextStart]
+		switch {
+		case c.kind != jsBlockComment || strings.ContainsAny(between, "\n\r"):
+			parts = append(parts, hardLine)
+			if strings.Count(between, "\n") > 1 {
+				parts = append(parts, hardLine)
+			}
+		default:
+			parts = append(parts, " ")
+		}
+	}
+	return parts
+}
+
+func (pr *jsPrinter) leading(pos int) doc {
+	if parts := pr.leadingComments(pos); parts != nil {
+		return parts
+	}
+	return nil
+}
+
+// dangling prints comments left before limit with no node to attach to,
+// such as those in an empty block. It returns nil when there are none.
+func (pr *jsPrinter) dangling(limit int) doc {
+	parts := pr.leadingComments(limit)
+	for len(parts) > 0 {
+		if s, ok := parts[len(parts)-1].(string); ok && s == " " {
+			parts = parts[:len(parts)-1]
+			continue
+		}
+		if _, ok := parts[len(parts)-1].(docLine); ok {
+			parts = parts[:len(parts)-1]
+			continue
+		}
+		break
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return parts
+}
+
+// trailing prints comments that follow end on the same line, along with
+// any comment inside the node that no inner anchor claimed
+func (pr *jsPrinter) trailing(end int) doc {
+	var parts []doc
+	pos := end
+	for i := pr.pendingBefore(len(pr.src) + 1); i >= 0; i = pr.pendingBefore(len(pr.src) + 1) {
+		c := pr.comments[i]
+		if c.start >= end {
+			if c.start < pos || strings.Trim(pr.src[pos:c.start], " \t,;") != "" {
+				break
+			}
+			pos = c.end
+		}
+		pr.done[i] = true
+		parts = append(parts, " ", pr.commentDoc(c))
+		if c.kind != jsBlockComment {
+			parts = append(parts, breakParent)
+		}
+	}
+	if parts == nil {
+		return nil
+	}
+	return lineSuffixDoc(parts)
+}
+
+// item prints a list element with its leading and trailing comments
+func (pr *jsPrinter) item(n jsNode, print func(jsNode) doc) doc {
+	start, end := n.span()
+	return []doc{pr.leading(start), print(n), pr.trailing(end)}
+}
+
+// list prints nodes one per line with their comments, keeping at most one
+// blank line between them. Comments left before limit are printed after
+// the last node. It returns nil when there is nothing to print.
+func (pr *jsPrinter) list(nodes []jsNode, limit int, print func(jsNode) doc) doc {
+	var parts []doc
+	prevEnd := -1
+
+	for _, n := range nodes {
+		if _, ok := n.(*jsEmpty); ok {
+			continue
+		}
+		start, end := n.span()
+		leadStart := start
+		if i := pr.pendingBefore(start); i >= 0 {
+			leadStart = pr.comments[i].start
+		}
+		if prevEnd >= 0 {
+			parts = append(parts, hardLine)
+			if pr.blankLineBetween(prevEnd, leadStart) {
+				parts = append(parts, hardLine)
+			}
+		}
+		parts = append(parts, pr.leading(start), print(n), pr.trailing(end))
+		prevEnd = pr.lastPrintedEnd(end)
+	}
+
+	if limit > len(pr.src) {
+		limit = len(pr.src)
+	}
+	leadStart := limit
+	if i := pr.pendingBefore(limit); i >= 0 {
+		leadStart = pr.comments[i].start
+	}
+	if d := pr.dangling(limit); d != nil {
+		if prevEnd >= 0 {
+			parts = append(parts, hardLine)
+			if pr.blankLineBetween(prevEnd, leadStart) {
+				parts = append(parts, hardLine)
+			}
+		}
+		parts = append(parts, d)
+	}
+
+	if len(parts) == 0 {
+		return nil
+	}
+	return parts
+}
+
+// lastPrintedEnd extends end past trailing comments already printed
+func (pr *jsPrinter) lastPrintedEnd(end int) int {
+	for i := pr.next; i < len(pr.comments) && pr.done[i]; i++ {
+		if pr.comments[i].end > end {
+			end = pr.comments[i].end
+		}
+	}
+	return end
+}
+
+func (pr *jsPrinter) blankLineBetween(from, to int) bool {
+	if from < 0 || to <= from || to > len(pr.src) {
+		return false
+	}
+	return strings.Count(pr.src[from:to], "\n") > 1
+}
+
+// block prints braces around a statement list, or {} when empty
+func (pr *jsPrinter) block(body []jsNode, end int) doc {
+	inner := pr.list(body, end-1, pr.stmt)
+	if inner == nil {
+		return "{}"
+	}
+	return []doc{"{", indentDoc(hardLine, inner), hardLine, "}"}
+}
+
+// delimited prints a comma-separated list that is either flat on one line
+// or broken one item per line. padded lists put spaces inside the
+// delimiters when flat; trailing adds a separator after the last item when
+// broken. limit bounds dangling comments before the closing delimiter.
+func (pr *jsPrinter) delimited(open, close string, items []jsNode, limit int, print func(jsNode) doc, padded, trailing bool) *docGroup {
+	line := softLine
+	if padded {
+		line = spaceLine
+	}
+
+	var inner []doc
+	for i, it := range items {
+		if i > 0 {
+			inner = append(inner, ",", spaceLine)
+		}
+		if it == nil {
+			continue
+		}
+		inner = append(inner, pr.item(it, print))
+	}
+
+	var tail doc
+	if len(items) > 0 && items[len(items)-1] == nil {
+		// A trailing hole needs its comma to keep the array length
+		tail = ","
+	} else if trailing {
+		tail = ifBreakDoc(",", nil)
+	}
+
+	d := pr.dangling(limit)
+	if len(items) == 0 {
+		if d == nil {
+			return groupDoc(open, close)
+		}
+		return groupDoc(open, indentDoc(line, d), line, close)
+	}
+	if d != nil {
+		inner = append(inner, tail, hardLine, d)
+		tail = nil
+	}
+	return groupDoc(open, indentDoc(line, inner, tail), line, close)
+}
+
+// Statements
+
+func (pr *jsPrinter) stmt(n jsNode) doc {
+	switch s := n.(type) {
+	case *jsEmpty:
+		return ";"
+	case *jsExprStmt:
+		return []doc{pr.expr(s.expr), ";"}
+	case *jsVarDecl:
+		return []doc{pr.varDecl(s), ";"}
+	case *jsBlock:
+		return pr.block(s.body, s.end)
+	case *jsFunction:
+		return pr.function(s)
+	case *jsClass:
+		return pr.class(s)
+	case *jsIf:
+		return pr.ifStmt(s)
+	case *jsFor:
+		parts := []doc{"for ("}
+		if s.init != nil {
+			if decl, ok := s.init.(*jsVarDecl); ok {
+				parts = append(parts, pr.varDecl(decl))
+			} else {
+				parts = append(parts, pr.expr(s.init))
+			}
+		}
+		parts = append(parts, ";")
+		if s.test != nil {
+			parts = append(parts, " ", pr.expr(s.test))
+		}
+		parts = append(parts, ";")
+		if s.update != nil {
+			parts = append(parts, " ", pr.expr(s.update))
+		}
+		return append(parts, ")", pr.body(s.body))
+	case *jsForIn:
+		parts := []doc{"for"}
+		if s.await {
+			parts = append(parts, " await")
+		}
+		parts = append(parts, " (")
+		if decl, ok := s.left.(*jsVarDecl); ok {
+			parts = append(parts, pr.varDecl(decl))
+		} else {
+			parts = append(parts, pr.expr(s.left))
+		}
+		op := " in "
+		if s.of {
+			op = " of "
+		}
+		return append(parts, op, pr.expr(s.right), ")", pr.body(s.body))
+	case *jsWhile:
+		return []doc{pr.header("while", s.test), pr.body(s.body)}
+	case *jsDoWhile:
+		parts := []doc{"do", pr.body(s.body)}
+		if _, ok := s.body.(*jsBlock); ok {
+			parts = append(parts, " ")
+		} else {
+			parts = append(parts, hardLine)
+		}
+		return append(parts, pr.header("while", s.test), ";")
+	case *jsReturn:
+		return pr.jumpWithArg("return", s.arg)
+	case *jsThrow:
+		return pr.jumpWithArg("throw", s.arg)
+	case *jsJump:
+		if s.label != nil {
+			return []doc{s.keyword, " ", s.label.name, ";"}
+		}
+		return []doc{s.keyword, ";"}
+	case *jsTry:
+		parts := []doc{"try ", pr.block(s.block.body, s.block.end)}
+		if s.handler != nil {
+			parts = append(parts, " catch ")
+			if s.param != nil {
+				parts = append(parts, "(", pr.expr(s.param))
+				if s.paramType != nil {
+					parts = append(parts, ": ", pr.typ(s.paramType))
+				}
+				parts = append(parts, ") ")
+			}
+			parts = append(parts, pr.block(s.handler.body, s.handler.end))
+		}
+		if s.finalizer != nil {
+			parts = append(parts, " finally ", pr.block(s.finalizer.body, s.finalizer.end))
+		}
+		return parts
+	case *jsSwitch:
+		cases := make([]jsNode, len(s.cases))
+		for i, c := range s.cases {
+			cases[i] = c
+		}
+		parts := []doc{pr.header("switch", s.disc), " {"}
+		if inner := pr.list(cases, s.end-1, pr.switchCase); inner != nil {
+			parts = append(parts, indentDoc(hardLine, inner), hardLine)
+		}
+		return append(parts, "}")
+	case *jsLabeled:
+		if _, ok := s.body.(*jsEmpty); ok {
+			return []doc{s.label.name, ":;"}
+		}
+		return []doc{s.label.name, ": ", pr.stmt(s.body)}
+	case *jsDebugger:
+		return "debugger;"
+	case *jsWith:
+		return []doc{pr.header("with", s.object), pr.body(s.body)}
+	case *jsImport:
+		return pr.importDecl(s)
+	case *jsExport:
+		return pr.exportDecl(s)
+	case *tsTypeAlias:
+		parts := []doc{"type ", s.name.name, pr.typeParams(s.typeParams), " ="}
+		if u, ok := s.typ.(*tsUnion); ok && u.op == "|" && len(u.types) > 1 {
+			parts = append(parts, groupDoc(indentDoc(spaceLine, ifBreakDoc("| ", nil), pr.unionMembers(u))))
+		} else {
+			parts = append(parts, " ", pr.typ(s.typ))
+		}
+		return append(parts, ";")
+	case *tsInterface:
+		parts := []doc{"interface ", s.name.name, pr.typeParams(s.typeParams)}
+		if len(s.extends) > 0 {
+			parts = append(parts, " extends ", joinDocs(", ", pr.types(s.extends)))
+		}
+		return append(parts, " ", pr.typeBody(s.body))
+	case *tsEnum:
+		parts := []doc{}
+		for _, m := range s.modifiers {
+			parts = append(parts, m, " ")
+		}
+		members := make([]jsNode, len(s.members))
+		for i, m := range s.members {
+			members[i] = m
+		}
+		parts = append(parts, "enum ", s.name.name, " {")
+		if inner := pr.list(members, s.end-1, pr.enumMember); inner != nil {
+			parts = append(parts, indentDoc(hardLine, inner), hardLine)
+		}
+		return append(parts, "}")
+	case *tsModule:
+		parts := []doc{}
+		if s.keyword != "" {
+			parts = append(parts, s.keyword, " ")
+		}
+		parts = append(parts, pr.expr(s.name))
+		if s.body == nil {
+			return append(parts, ";")
+		}
+		return append(parts, " ", pr.block(s.body.body, s.body.end))
+	case *tsDeclare:
+		return []doc{"declare ", pr.stmt(s.stmt)}
+	case *tsImportEquals:
+		parts := []doc{}
+		if s.exported {
+			parts = append(parts, "export ")
+		}
+		parts = append(parts, "import ")
+		if s.typeOnly {
+			parts = append(parts, "type ")
+		}
+		return append(parts, s.name.name, " = ", pr.expr(s.ref), ";")
+	}
+	return []doc{pr.expr(n), ";"}
+}
+
+// header prints a keyword followed by a parenthesized condition, breaking
+// inside the parentheses when the condition does not fit
+func (pr *jsPrinter) header(keyword string, test jsNode) doc {
+	return groupDoc(keyword, " (", indentDoc(softLine, pr.chain(test)), softLine, ")")
+}
+
+// body prints the statement controlled by if, for, while and the like
+func (pr *jsPrinter) body(n jsNode) doc {
+	switch s := n.(type) {
+	case *jsBlock:
+		return []doc{" ", pr.block(s.body, s.end)}
+	case *jsEmpty:
+		return ";"
+	}
+	return groupDoc(indentDoc(spaceLine, pr.stmt(n)))
+}
+
+func (pr *jsPrinter) ifStmt(s *jsIf) doc {
+	parts := []doc{pr.header("if", s.test), pr.body(s.cons)}
+	if s.alt == nil {
+		return parts
+	}
+
+	if _, ok := s.cons.(*jsBlock); ok {
+		parts = append(parts, " ")
+	} else {
+		parts = append(parts, hardLine)
+	}
+	parts = append(parts, pr.leading(nodeStart(s.alt)), "else")
+	if alt, ok := s.alt.(*jsIf); ok {
+		return append(parts, " ", pr.ifStmt(alt))
+	}
+	return append(parts, pr.body(s.alt))
+}
+
+// jumpWithArg prints return or throw, wrapping a breaking binary argument
+// in parentheses so it stays attached to the keyword
+func (pr *jsPrinter) jumpWithArg(keyword string, arg jsNode) doc {
+	if arg == nil {
+		return []doc{keyword, ";"}
+	}
+	switch a := arg.(type) {
+	case *jsBinary:
+		if !isAssignmentOp(a.op) {
+			return []doc{keyword, " ", groupDoc(ifBreakDoc("(", nil), indentDoc(softLine, pr.chain(arg)), softLine, ifBreakDoc(")", nil)), ";"}
+		}
+	case *jsSequence:
+		return []doc{keyword, " ", groupDoc(ifBreakDoc("(", nil), indentDoc(softLine, pr.expr(arg)), softLine, ifBreakDoc(")", nil)), ";"}
+	}
+	return []doc{keyword, " ", pr.wrapJSX(arg), ";"}
+}
+
+func (pr *jsPrinter) switchCase(n jsNode) doc {
+	c := n.(*jsSwitchCase)
+	var parts []doc
+	if c.test == nil {
+		parts = []doc{"default:"}
+	} else {
+		parts = []doc{"case ", pr.expr(c.test), ":"}
+	}
+
+	if len(c.body) == 1 {
+		if b, ok := c.body[0].(*jsBlock); ok {
+			return append(parts, " ", pr.block(b.body, b.end))
+		}
+	}
+	if inner := pr.list(c.body, c.end, pr.stmt); inner != nil {
+		parts = append(parts, indentDoc(hardLine, inner))
+	}
+	return parts
+}
+
+func (pr *jsPrinter) varDecl(d *jsVarDecl) doc {
+	decls := make([]doc, len(d.decls))
+	hasInit := false
+	for i, v := range d.decls {
+		decls[i] = pr.declarator(v)
+		if v.init != nil {
+			hasInit = true
+		}
+	}
+	if len(decls) == 1 {
+		return []doc{d.kind, " ", decls[0]}
+	}
+
+	sep := doc(spaceLine)
+	if hasInit {
+		sep = hardLine
+	}
+	rest := []doc{}
+	for _, v := range decls[1:] {
+		rest = append(rest, ",", sep, v)
+	}
+	return groupDoc(d.kind, " ", decls[0], indentDoc(rest...))
+}
+
+func (pr *jsPrinter) declarator(v *jsVarDeclarator) doc {
+	left := []doc{pr.expr(v.target)}
+	if v.definite {
+		left = append(left, "!")
+	}
+	if v.typeAnn != nil {
+		left = append(left, ": ", pr.typ(v.typeAnn))
+	}
+	if v.init == nil {
+		return left
+	}
+	return pr.assignment(left, "=", v.init)
+}
+
+func (pr *jsPrinter) enumMember(n jsNode) doc {
+	m := n.(*tsEnumMember)
+	if m.init == nil {
+		return []doc{pr.expr(m.name), ","}
+	}
+	return []doc{pr.assignment(pr.expr(m.name), "=", m.init), ","}
+}
+
+// Modules
+
+func (pr *jsPrinter) moduleSpec(n jsNode) doc {
+	s := n.(*jsModuleSpec)
+	parts := []doc{}
+	if s.typeOnly {
+		parts = append(parts, "type ")
+	}
+	parts = append(parts, pr.expr(s.local))
+	if s.exported != nil {
+		parts = append(parts, " as ", pr.expr(s.exported))
+	}
+	return parts
+}
+
+func (pr *jsPrinter) moduleSpecs(specs []*jsModuleSpec, limit int) doc {
+	nodes := make([]jsNode, len(specs))
+	for i, s := range specs {
+		nodes[i] = s
+	}
+	return pr.delimited("{", "}", nodes, limit, pr.moduleSpec, true, true)
+}
+
+// specsLimit returns the offset of the closing brace of a specifier list
+func (pr *jsPrinter) specsLimit(from, to int) int {
+	if i := strings.LastIndexByte(pr.src[from:to], '}'); i >= 0 {
+		return from + i
+	}
+	return from
+}
+
+func (pr *jsPrinter) importDecl(s *jsImport) doc {
+	parts := []doc{"import"}
+	if s.typeOnly {
+		parts = append(parts, " type")
+	}
+	parts = append(parts, " ")
+
+	if s.defaultName != nil || s.namespace != nil || s.hasNamed {
+		if s.defaultName != nil {
+			parts = append(parts, s.defaultName.name)
+			if s.namespace != nil || s.hasNamed {
+				parts = append(parts, ", ")
+			}
+		}
+		if s.namespace != nil {
+			parts = append(parts, "* as ", s.namespace.name)
+		}
+		if s.hasNamed {
+			parts = append(parts, pr.moduleSpecs(s.named, pr.specsLimit(s.start, s.source.start)))
+		}
+		parts = append(parts, " from ")
+	}
+	parts = append(parts, pr.str(s.source.raw))
+	if s.attributes != nil {
+		parts = append(parts, " ", s.attributesKw, " ", pr.expr(s.attributes))
+	}
+	return append(parts, ";")
+}
+
+func (pr *jsPrinter) exportDecl(s *jsExport) doc {
+	parts := []doc{}
+	if len(s.decorators) > 0 {
+		parts = append(parts, pr.decorators(s.decorators, pr.afterDecorators(s.decorators, s.end)))
+	}
+	parts = append(parts, "export ")
+
+	switch {
+	case s.isDefault:
+		parts = append(parts, "default ")
+		switch d := s.decl.(type) {
+		case *jsClass, *tsInterface:
+			return append(parts, pr.stmt(d))
+		case *jsFunction:
+			if !d.arrow {
+				return append(parts, pr.stmt(d))
+			}
+		}
+		return append(parts, pr.wrapJSX(s.decl), ";")
+	case s.decl != nil:
+		return append(parts, pr.stmt(s.decl))
+	case s.assignment != nil:
+		return []doc{"export = ", pr.expr(s.assignment), ";"}
+	case s.namespaceAs != nil:
+		return []doc{"export as namespace ", s.namespaceAs.name, ";"}
+	}
+
+	if s.typeOnly {
+		parts = append(parts, "type ")
+	}
+	limit := s.end
+	if s.star {
+		parts = append(parts, "*")
+		if s.starAs != nil {
+			parts = append(parts, " as ", pr.expr(s.starAs))
+		}
+	} else {
+		if s.source != nil {
+			limit = s.source.start
+		}
+		parts = append(parts, pr.moduleSpecs(s.named, pr.specsLimit(s.start, limit)))
+	}
+	if s.source != nil {
+		parts = append(parts, " from ", pr.str(s.source.raw))
+		if s.attributes != nil {
+			parts = append(parts, " with ", pr.expr(s.attributes))
+		}
+	}
+	return append(parts, ";")
+}
+
+// Functions and classes
+
+func (pr *jsPrinter) function(fn *jsFunction) doc {
+	if fn.arrow {
+		return pr.arrow(fn)
+	}
+	parts := []doc{}
+	if fn.async {
+		parts = append(parts, "async ")
+	}
+	parts = append(parts, "function")
+	if fn.generator {
+		parts = append(parts, "*")
+	}
+	if fn.name != nil {
+		parts = append(parts, " ", fn.name.name)
+	} else if !fn.generator {
+		parts = append(parts, " ")
+	}
+	return append(parts, pr.signature(fn), pr.functionBody(fn))
+}
+
+func (pr *jsPrinter) signature(fn *jsFunction) doc {
+	parts := []doc{pr.typeParams(fn.typeParams), pr.params(fn.params, fn.start)}
+	if fn.returnType != nil {
+		parts = append(parts, ": ", pr.typ(fn.returnType))
+	}
+	return groupDoc(parts...)
+}
+
+func (pr *jsPrinter) functionBody(fn *jsFunction) doc {
+	if fn.body == nil {
+		return ";"
+	}
+	return []doc{" ", pr.block(fn.body.body, fn.body.end)}
+}
+
+func (pr *jsPrinter) arrow(fn *jsFunction) doc {
+	parts := []doc{}
+	if fn.async {
+		parts = append(parts, "async ")
+	}
+	if fn.typeParams != nil && pr.dialect.jsx && len(fn.typeParams.params) == 1 && fn.typeParams.params[0].constraint == nil {
+		// <T>() => {} would read as a JSX tag
+		parts = append(parts, "<", pr.typeParam(fn.typeParams.params[0]), ",>", pr.params(fn.params, fn.start))
+		if fn.returnType != nil {
+			parts = append(parts, ": ", pr.typ(fn.returnType))
+		}
+	} else {
+		parts = append(parts, pr.signature(fn))
+	}
+
+	if fn.body != nil {
+		return append(parts, " => ", pr.block(fn.body.body, fn.body.end))
+	}
+	switch body := fn.exprBody.(type) {
+	case *jsObject, *jsArray, *jsTemplateLiteral, *jsParen, *jsxElement:
+		return append(parts, " => ", pr.wrapJSX(body))
+	case *jsFunction:
+		if body.arrow {
+			return append(parts, " => ", pr.expr(body))
+		}
+	case *jsConditional:
+		return append(parts, " =>", groupDoc(indentDoc(spaceLine, ifBreakDoc("(", nil), pr.expr(body), ifBreakDoc(")", nil))))
+	}
+	return append(parts, " =>", groupDoc(indentDoc(spaceLine, pr.chain(fn.exprBody))))
+}
+
+func (pr *jsPrinter) params(params []*jsParam, limit int) doc {
+	if len(params) == 1 && params[0].typeAnn == nil && params[0].init == nil && len(params[0].modifiers) == 0 {
+		// Hug a lone destructuring pattern: f({ a, b }) rather than f(\n{...}\n)
+		switch params[0].pattern.(type) {
+		case *jsObject, *jsArray:
+			return []doc{"(", pr.param(params[0]), ")"}
+		}
+	}
+
+	nodes := make([]jsNode, len(params))
+	for i, p := range params {
+		nodes[i] = p
+	}
+	// A rest parameter must be last, so it cannot take a trailing comma
+	trailing := len(params) == 0 || !params[len(params)-1].rest
+	return pr.delimited("(", ")", nodes, -1, func(n jsNode) doc {
+		return pr.param(n.(*jsParam))
+	}, false, trailing)
+}
+
+func (pr *jsPrinter) param(p *jsParam) doc {
+	parts := []doc{}
+	for _, d := range p.decorators {
+		parts = append(parts, pr.expr(d), " ")
+	}
+	for _, m := range p.modifiers {
+		parts = append(parts, m, " ")
+	}
+	if p.rest {
+		parts = append(parts, "...")
+	}
+	parts = append(parts, pr.expr(p.pattern))
+	if p.optional {
+		parts = append(parts, "?")
+	}
+	if p.typeAnn != nil {
+		parts = append(parts, ": ", pr.typ(p.typeAnn))
+	}
+	if p.init != nil {
+		parts = append(parts, " = ", pr.expr(p.init))
+	}
+	return parts
+}
+
+// decorators prints decorators on their own lines if the source did so
+func (pr *jsPrinter) decorators(decorators []jsNode, next int) doc {
+	parts := []doc{}
+	for i, d := range decorators {
+		parts = append(parts, pr.expr(d))
+		following := next
+		if i+1 < len(decorators) {
+			following = nodeStart(decorators[i+1])
+		}
+		if strings.ContainsAny(pr.src[nodeEnd(d):following], "\n\r") {
+			parts = append(parts, hardLine)
+		} else {
+			parts = append(parts, " ")
+		}
+	}
+	return parts
+}
+
+func (pr *jsPrinter) class(c *jsClass) doc {
+	parts := []doc{}
+	if len(c.decorators) > 0 {
+		parts = append(parts, pr.decorators(c.decorators, pr.afterDecorators(c.decorators, c.end)))
+	}
+	for _, m := range c.modifiers {
+		parts = append(parts, m, " ")
+	}
+	parts = append(parts, "class")
+	if c.name != nil {
+		parts = append(parts, " ", c.name.name)
+	}
+	parts = append(parts, pr.typeParams(c.typeParams))
+	if c.superClass != nil {
+		parts = append(parts, " extends ", pr.expr(c.superClass), pr.typeArgs(c.superTypeArgs))
+	}
+	if len(c.implements) > 0 {
+		parts = append(parts, " implements ", joinDocs(", ", pr.types(c.implements)))
+	}
+	parts = append(parts, " {")
+	if inner := pr.list(c.members, c.end-1, pr.classMember); inner != nil {
+		parts = append(parts, indentDoc(hardLine, inner), hardLine)
+	}
+	return append(parts, "}")
+}
+
+func (pr *jsPrinter) classMember(n jsNode) doc {
+	switch m := n.(type) {
+	case *jsMethod:
+		parts := []doc{}
+		if len(m.decorators) > 0 {
+			parts = append(parts, pr.decorators(m.decorators, pr.afterDecorators(m.decorators, nodeStart(m.key))))
+		}
+		for _, mod := range m.modifiers {
+			parts = append(parts, mod, " ")
+		}
+		return append(parts, pr.method(m.kind, m.key, m.computed, m.optional, m.fn))
+	case *jsField:
+		parts := []doc{}
+		if len(m.decorators) > 0 {
+			parts = append(parts, pr.decorators(m.decorators, pr.afterDecorators(m.decorators, nodeStart(m.key))))
+		}
+		for _, mod := range m.modifiers {
+			parts = append(parts, mod, " ")
+		}
+		left := []doc{pr.propertyKey(m.key, m.computed)}
+		if m.optional {
+			left = append(left, "?")
+		}
+		if m.definite {
+			left = append(left, "!")
+		}
+		if m.typeAnn != nil {
+			left = append(left, ": ", pr.typ(m.typeAnn))
+		}
+		if m.value == nil {
+			return append(parts, left, ";")
+		}
+		return append(parts, pr.assignment(left, "=", m.value), ";")
+	case *jsStaticBlock:
+		return []doc{"static ", pr.block(m.body, m.end)}
+	case *tsIndexSig:
+		return []doc{pr.typeMember(m), ";"}
+	}
+	return pr.stmt(n)
+}
+
+// afterDecorators returns where the decorated declaration proper begins
+func (pr *jsPrinter) afterDecorators(decorators []jsNode, limit int) int {
+	from := nodeEnd(decorators[len(decorators)-1])
+	text := pr.src[from:limit]
+	trimmed := strings.TrimLeft(text, " \t\r\n")
+	return from + len(text) - len(trimmed)
+}
+
+// method prints an object or class method from its key onwards
+func (pr *jsPrinter) method(kind string, key jsNode, computed, optional bool, fn *jsFunction) doc {
+	parts := []doc{}
+	if fn.async {
+		parts = append(parts, "async ")
+	}
+	if kind == "get" || kind == "set" {
+		parts = append(parts, kind, " ")
+	}
+	if fn.generator {
+		parts = append(parts, "*")
+	}
+	parts = append(parts, pr.propertyKey(key, computed))
+	if optional {
+		parts = append(parts, "?")
+	}
+	return append(parts, pr.signature(fn), pr.functionBody(fn))
+}
+
+func (pr *jsPrinter) propertyKey(key jsNode, computed bool) doc {
+	if computed {
+		return []doc{"[", pr.expr(key), "]"}
+	}
+	return pr.expr(key)
+}
+
+// Expressions
+
+var jsBinaryPrecedence = map[string]int{
+	"??": 1, "||": 2, "&&": 3, "|": 4, "^": 5, "&": 6,
+	"==": 7, "!=": 7, "===": 7, "!==": 7,
+	"<": 8, ">": 8, "<=": 8, ">=": 8, "in": 8, "instanceof": 8,
+	"<<": 9, ">>": 9, ">>>": 9,
+	"+": 10, "-": 10, "*": 11, "/": 11, "%": 11, "**": 12,
+}
+
+func isAssignmentOp(op string) bool {
+	return jsAssignOps[op]
+}
+
+// shouldFlatten reports whether a binary operand can share its parent's
+// line-breaking group, as in a + b + c
+func shouldFlatten(parent, child string) bool {
+	pp, cp := jsBinaryPrecedence[parent], jsBinaryPrecedence[child]
+	if pp == 0 || pp != cp || parent == "**" || pp == 7 {
+		return false
+	}
+	if pp == 11 && parent != child && (parent == "%" || child == "%") {
+		return false
+	}
+	return true
+}
+
+// binaryParts flattens a chain of same-precedence binary operators into
+// operand, " op", line, operand, ...
+func (pr *jsPrinter) binaryParts(b *jsBinary) []doc {
+	var parts []doc
+	if left, ok := b.left.(*jsBinary); ok && shouldFlatten(b.op, left.op) {
+		parts = pr.binaryParts(left)
+	} else {
+		parts = []doc{groupDoc(pr.expr(b.left))}
+	}
+	return append(parts, " "+b.op, spaceLine, pr.expr(b.right))
+}
+
+// chain prints an expression whose surrounding context already indents,
+// so binary chains break without an extra indentation level
+func (pr *jsPrinter) chain(n jsNode) doc {
+	if b, ok := n.(*jsBinary); ok && !isAssignmentOp(b.op) {
+		return groupDoc(pr.binaryParts(b)...)
+	}
+	return pr.expr(n)
+}
+
+// assignment prints left op right, breaking after the operator only for
+// right-hand sides that cannot break nicely themselves
+func (pr *jsPrinter) assignment(left doc, op string, right jsNode) doc {
+	return pr.assignLike(left, " "+op, right)
+}
+
+// assignLike lays out left, separator and right as assignment does; object
+// properties use it with a colon
+func (pr *jsPrinter) assignLike(left doc, sep string, right jsNode) doc {
+	switch r := right.(type) {
+	case *jsBinary:
+		if !isAssignmentOp(r.op) {
+			return groupDoc(left, sep, groupDoc(indentDoc(spaceLine, pr.chain(right))))
+		}
+	case *jsConditional, *jsSequence, *tsAsExpr, *jsMember:
+		return groupDoc(left, sep, groupDoc(indentDoc(spaceLine, pr.expr(right))))
+	case *jsLiteral:
+		if r.kind == jsString {
+			return groupDoc(left, sep, groupDoc(indentDoc(spaceLine, pr.expr(right))))
+		}
+	}
+	return groupDoc(left, sep, " ", pr.wrapJSX(right))
+}
+
+// wrapJSX prints a JSX element so that a multi-line element is wrapped in
+// parentheses; other expressions print as usual
+func (pr *jsPrinter) wrapJSX(n jsNode) doc {
+	if p, ok := n.(*jsParen); ok {
+		if _, isJSX := p.expr.(*jsxElement); isJSX {
+			n = p.expr
+		}
+	}
+	if _, ok := n.(*jsxElement); !ok {
+		return pr.expr(n)
+	}
+	return groupDoc(ifBreakDoc("(", nil), indentDoc(softLine, pr.expr(n)), softLine, ifBreakDoc(")", nil))
+}
+
+func (pr *jsPrinter) exprs(nodes []jsNode) []doc {
+	docs := make([]doc, len(nodes))
+	for i, n := range nodes {
+		docs[i] = pr.expr(n)
+	}
+	return docs
+}
+
+func (pr *jsPrinter) expr(n jsNode) doc {
+	switch e := n.(type) {
+	case nil:
+		return nil
+	case *jsIdent:
+		return e.name
+	case *jsLiteral:
+		if e.kind == jsString {
+			return pr.str(e.raw)
+		}
+		return e.raw
+	case *jsTemplateLiteral:
+		return pr.template(e)
+	case *jsArray:
+		return pr.array(e)
+	case *jsObject:
+		return pr.object(e)
+	case *jsProperty:
+		return pr.property(e)
+	case *jsSpread:
+		return []doc{"...", pr.expr(e.arg)}
+	case *jsFunction:
+		return pr.function(e)
+	case *jsClass:
+		return pr.class(e)
+	case *jsUnary:
+		if len(e.op) > 1 {
+			return []doc{e.op, " ", pr.expr(e.arg)}
+		}
+		if (e.op == "+" || e.op == "-") && startsWithSign(e.arg, e.op[0]) {
+			return []doc{e.op, " ", pr.expr(e.arg)}
+		}
+		return []doc{e.op, pr.expr(e.arg)}
+	case *jsUpdate:
+		if e.prefix {
+			return []doc{e.op, pr.expr(e.arg)}
+		}
+		return []doc{pr.expr(e.arg), e.op}
+	case *jsBinary:
+		if isAssignmentOp(e.op) {
+			return pr.assignment(pr.expr(e.left), e.op, e.right)
+		}
+		parts := pr.binaryParts(e)
+		return groupDoc(parts[0], indentDoc(parts[1:]...))
+	case *jsConditional:
+		return groupDoc(pr.expr(e.test), indentDoc(spaceLine, "? ", pr.wrapJSX(e.cons), spaceLine, ": ", pr.wrapJSX(e.alt)))
+	case *jsCall:
+		return pr.call(e)
+	case *jsMember:
+		if chain := pr.memberChain(e); chain != nil {
+			return chain
+		}
+		return []doc{pr.memberObject(e.object), pr.memberProperty(e)}
+	case *jsSequence:
+		docs := pr.exprs(e.exprs)
+		return groupDoc(docs[0], indentDoc(joinChain(docs[1:])...))
+	case *jsParen:
+		if _, ok := e.expr.(*jsxElement); ok {
+			return pr.wrapJSX(e.expr)
+		}
+		return []doc{"(", pr.expr(e.expr), ")"}
+	case *jsYield:
+		parts := []doc{"yield"}
+		if e.delegate {
+			parts = append(parts, "*")
+		}
+		if e.arg != nil {
+			parts = append(parts, " ", pr.expr(e.arg))
+		}
+		return parts
+	case *jsAwait:
+		return []doc{"await ", pr.expr(e.arg)}
+	case *jsMetaProperty:
+		return e.text
+	case *jsDecorator:
+		return []doc{"@", pr.expr(e.expr)}
+	case *tsAsExpr:
+		return []doc{pr.expr(e.expr), " ", e.op, " ", pr.typ(e.typ)}
+	case *tsNonNull:
+		return []doc{pr.expr(e.expr), "!"}
+	case *tsTypeAssertion:
+		return []doc{"<", pr.typ(e.typ), ">", pr.expr(e.expr)}
+	case *jsxElement:
+		return pr.jsxElement(e)
+	}
+	start, end := n.span()
+	return pr.src[start:end]
+}
+
+// joinChain prints ", " separated items that break one per line
+func joinChain(docs []doc) []doc {
+	var parts []doc
+	for _, d := range docs {
+		parts = append(parts, ",", spaceLine, d)
+	}
+	return parts
+}
+
+// startsWithSign reports whether printing n begins with the sign c, which
+// would fuse with a preceding unary operator of the same sign
+func startsWithSign(n jsNode, c byte) bool {
+	switch e := n.(type) {
+	case *jsUnary:
+		return e.op[0] == c
+	case *jsUpdate:
+		return e.prefix && e.op[0] == c
+	}
+	return false
+}
+
+// template prints a template literal as written; substitutions are copied
+// verbatim so formatting never changes the string it produces
+// str prints a quoted string literal with the preferred delimiter. Like
+// prettier, the other delimiter is kept when switching would need more
+// escapes. Anything else, such as a number literal type, is returned as is.
+func (pr *jsPrinter) str(raw string) string {
+	if pr.quote == 0 || len(raw) < 2 || (raw[0] != '"' && raw[0] != '\'') {
+		return raw
+	}
+	body := raw[1 : len(raw)-1]
+
+	enclosing, alternate := pr.quote, byte('"')
+	if enclosing == '"' {
+		alternate = '\''
+	}
+	if strings.Count(body, string(enclosing)) > strings.Count(body, string(alternate)) {
+		enclosing, alternate = alternate, enclosing
+	}
+	if enclosing == raw[0] {
+		return raw
+	}
+
+	var b strings.Builder
+	b.Grow(len(raw) + 2)
+	b.WriteByte(enclosing)
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == '\\' && i+1 < len(body):
+			i++
+			// An escaped quote that no longer needs escaping loses its backslash
+			if body[i] != alternate {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(body[i])
+		case c == enclosing:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(enclosing)
+	return b.String()
+}
+
+func (pr *jsPrinter) template(t *jsTemplateLiteral) doc {
+	parts := []doc{}
+	if t.tag != nil {
+		parts = append(parts, pr.expr(t.tag), pr.typeArgs(t.typeArgs))
+	}
+	var text strings.Builder
+	for i, q := range t.quasis {
+		text.WriteString(q)
+		if i < len(t.exprs) {
+			start, end := t.exprs[i].span()
+			text.WriteString(pr.src[start:end])
+			pr.markDone(start, end)
+		}
+	}
+	return append(parts, text.String())
+}
+
+func (pr *jsPrinter) array(a *jsArray) doc {
+	if len(a.elems) > 1 && allNumbers(a.elems) {
+		// Pack numeric tables several to a line
+		var parts []doc
+		for i, e := range a.elems {
+			d := pr.expr(e)
+			if i < len(a.elems)-1 {
+				d = []doc{d, ","}
+			}
+			if i > 0 {
+				parts = append(parts, spaceLine)
+			}
+			parts = append(parts, d)
+		}
+		return groupDoc("[", indentDoc(softLine, &docFill{parts: parts}), ifBreakDoc(",", nil), softLine, "]")
+	}
+	trailing := len(a.elems) == 0 || !isSpread(a.elems[len(a.elems)-1])
+	return pr.delimited("[", "]", a.elems, a.end-1, pr.expr, false, trailing)
+}
+
+func allNumbers(nodes []jsNode) bool {
+	for _, n := range nodes {
+		if u, ok := n.(*jsUnary); ok && (u.op == "-" || u.op == "+") {
+			n = u.arg
+		}
+		lit, ok := n.(*jsLiteral)
+		if !ok || lit.kind != jsNumber {
+			return false
+		}
+	}
+	return true
+}
+
+func isSpread(n jsNode) bool {
+	_, ok := n.(*jsSpread)
+	return ok
+}
+
+func (pr *jsPrinter) object(o *jsObject) doc {
+	// A rest element in a pattern cannot take a trailing comma
+	trailing := len(o.props) == 0 || !isSpread(o.props[len(o.props)-1])
+	g := pr.delimited("{", "}", o.props, o.end-1, pr.expr, true, trailing)
+	if o.multiline && len(o.props) > 0 {
+		g.shouldBreak = true
+	}
+	return g
+}
+
+func (pr *jsPrinter) property(p *jsProperty) doc {
+	switch p.kind {
+	case "init":
+		if p.shorthand {
+			return pr.expr(p.value)
+		}
+		return pr.assignLike(pr.propertyKey(p.key, p.computed), ":", p.value)
+	}
+	return pr.method(p.kind, p.key, p.computed, false, p.fn)
+}
+
+// call prints a call or new expression, hugging a trailing function or
+// object argument so that only its body breaks
+func (pr *jsPrinter) call(c *jsCall) doc {
+	if !c.isNew {
+		if chain := pr.memberChain(c); chain != nil {
+			return chain
+		}
+	}
+
+	parts := []doc{}
+	if c.isNew {
+		parts = append(parts, "new ")
+	}
+	parts = append(parts, pr.memberObject(c.callee), pr.typeArgs(c.typeArgs))
+	if c.optional {
+		parts = append(parts, "?.")
+	}
+	return append(parts, pr.arguments(c))
+}
+
+func (pr *jsPrinter) arguments(c *jsCall) doc {
+	args := c.args
+	if len(args) == 0 {
+		if d := pr.dangling(c.end - 1); d != nil {
+			return []doc{"(", d, ")"}
+		}
+		return "()"
+	}
+
+	if shouldHugFirstArg(args) || shouldHugLastArg(args) {
+		parts := []doc{"("}
+		for i, a := range args {
+			if i > 0 {
+				parts = append(parts, ", ")
+			}
+			parts = append(parts, pr.item(a, pr.expr))
+		}
+		return append(parts, ")")
+	}
+
+	trailing := !isSpread(args[len(args)-1])
+	return pr.delimited("(", ")", args, c.end-1, pr.expr, false, trailing)
+}
+
+// shouldHugLastArg reports whether the last argument is a function, object
+// or array that can open on the call's line while the others stay simple
+func shouldHugLastArg(args []jsNode) bool {
+	switch last := args[len(args)-1].(type) {
+	case *jsFunction, *jsTemplateLiteral:
+	case *jsObject:
+		if len(last.props) == 0 {
+			return false
+		}
+	case *jsArray:
+		if len(last.elems) == 0 {
+			return false
+		}
+	default:
+		return false
+	}
+	for _, a := range args[:len(args)-1] {
+		if !isSimpleArg(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// shouldHugFirstArg matches calls like useEffect(() => {...}, [deps]) and
+// setTimeout(function () {...}, 500) where a callback comes first
+func shouldHugFirstArg(args []jsNode) bool {
+	if len(args) != 2 {
+		return false
+	}
+	fn, ok := args[0].(*jsFunction)
+	if !ok || fn.body == nil {
+		return false
+	}
+	if arr, ok := args[1].(*jsArray); ok {
+		for _, e := range arr.elems {
+			if !isSimpleArg(e) {
+				return false
+			}
+		}
+		return true
+	}
+	return isSimpleArg(args[1])
+}
+
+func isSimpleArg(n jsNode) bool {
+	switch e := n.(type) {
+	case *jsIdent, *jsLiteral, *jsMetaProperty:
+		return true
+	case *jsTemplateLiteral:
+		return len(e.exprs) == 0
+	case *jsMember:
+		return !e.computed && isSimpleArg(e.object)
+	case *jsUnary:
+		return isSimpleArg(e.arg)
+	case *jsObject:
+		return len(e.props) == 0
+	case *jsArray:
+		return len(e.elems) == 0
+	}
+	return false
+}
+
+// memberObject prints the object of a member access or callee, wrapping an
+// integer literal so its dot is not read as a decimal point
+func (pr *jsPrinter) memberObject(n jsNode) doc {
+	if lit, ok := n.(*jsLiteral); ok && lit.kind == jsNumber && isPlainInteger(lit.raw) {
+		return []doc{"(", lit.raw, ")"}
+	}
+	return pr.expr(n)
+}
+
+func (pr *jsPrinter) memberProperty(m *jsMember) doc {
+	switch {
+	case m.computed && m.optional:
+		return []doc{"?.[", pr.expr(m.property), "]"}
+	case m.computed:
+		return []doc{"[", pr.expr(m.property), "]"}
+	case m.optional:
+		return []doc{"?.", pr.expr(m.property)}
+	}
+	return []doc{".", pr.expr(m.property)}
+}
+
+// memberChain prints a.b().c().d() with one call per line when the chain
+// has at least three calls, returning nil for shorter chains
+func (pr *jsPrinter) memberChain(n jsNode) doc {
+	var links []jsNode
+	cur := n
+walk:
+	for {
+		switch e := cur.(type) {
+		case *jsCall:
+			if e.isNew {
+				break walk
+			}
+			links = append(links, e)
+			cur = e.callee
+		case *jsMember:
+			links = append(links, e)
+			cur = e.object
+		case *tsNonNull:
+			links = append(links, e)
+			cur = e.expr
+		default:
+			break walk
+		}
+	}
+
+	calls := 0
+	hasFunctionArg := false
+	for _, l := range links {
+		if c, ok := l.(*jsCall); ok {
+			calls++
+			for _, a := range c.args {
+				if fn, ok := a.(*jsFunction); ok && (fn.body != nil || !fn.arrow) {
+					hasFunctionArg = true
+				}
+			}
+		}
+	}
+	if calls < 3 {
+		return nil
+	}
+
+	// Links run outermost first; print them from the base outwards, starting
+	// a new line at each non-computed property access
+	head := []doc{pr.memberObject(cur)}
+	var groups [][]doc
+	for i := len(links) - 1; i >= 0; i-- {
+		var d doc
+		newGroup := false
+		switch l := links[i].(type) {
+		case *jsCall:
+			d = pr.typeArgs(l.typeArgs)
+			if l.optional {
+				d = []doc{d, "?."}
+			}
+			d = []doc{d, pr.arguments(l)}
+		case *jsMember:
+			d = pr.memberProperty(l)
+			newGroup = !l.computed
+		case *tsNonNull:
+			d = "!"
+		}
+		switch {
+		case newGroup:
+			groups = append(groups, []doc{d})
+		case len(groups) == 0:
+			head = append(head, d)
+		default:
+			groups[len(groups)-1] = append(groups[len(groups)-1], d)
+		}
+	}
+
+	// Keep the first access on the head line for short receivers such as
+	// this, z or $ so that chains read z.object()\n  .shape()
+	if len(groups) > 0 && len(head) == 1 && pr.isShortReceiver(cur) {
+		head = append(head, groups[0])
+		groups = groups[1:]
+	}
+
+	var rest []doc
+	for _, g := range groups {
+		rest = append(rest, softLine, g)
+	}
+	chain := groupDoc(head, indentDoc(rest...))
+	chain.shouldBreak = hasFunctionArg
+	return chain
+}
+
+func (pr *jsPrinter) isShortReceiver(n jsNode) bool {
+	switch e := n.(type) {
+	case *jsLiteral:
+		return e.raw == "this"
+	case *jsIdent:
+		if len(e.name) <= len(pr.indent) {
+			return true
+		}
+		c := e.name[0]
+		return c >= 'A' && c <= 'Z' || c == '_' || c == '$'
+	}
+	return false
+}
+
+// JSX
+
+// jsxSeparator is meaningful whitespace between JSX children: a plain space
+// on one line, or {" "} before a break
+var jsxSeparator = ifBreakDoc([]doc{`{" "}`, softLine}, " ")
+
+func isJSXSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (pr *jsPrinter) jsxElement(el *jsxElement) doc {
+	open := pr.jsxOpening(el)
+	if el.selfClosing {
+		return open
+	}
+
+	children, forceBreak := pr.jsxChildren(el.children)
+	closing := "</" + el.name + ">"
+	if len(children) == 0 {
+		return []doc{open, closing}
+	}
+	g := groupDoc(open, indentDoc(softLine, &docFill{parts: children}), softLine, closing)
+	g.shouldBreak = forceBreak
+	return g
+}
+
+func (pr *jsPrinter) jsxOpening(el *jsxElement) doc {
+	name := []doc{"<", el.name, pr.typeArgs(el.typeArgs)}
+	if len(el.attrs) == 0 {
+		if el.selfClosing {
+			return []doc{name, " />"}
+		}
+		return []doc{name, ">"}
+	}
+
+	attrs := []doc{}
+	for _, a := range el.attrs {
+		attrs = append(attrs, spaceLine, pr.jsxAttribute(a))
+	}
+	if el.selfClosing {
+		return groupDoc(name, indentDoc(attrs...), spaceLine, "/>")
+	}
+	return groupDoc(name, indentDoc(attrs...), softLine, ">")
+}
+
+func (pr *jsPrinter) jsxAttribute(n jsNode) doc {
+	switch a := n.(type) {
+	case *jsxSpreadAttribute:
+		return []doc{"{...", pr.expr(a.arg), "}"}
+	case *jsxAttribute:
+		if a.value == nil {
+			return a.name
+		}
+		return []doc{a.name, "=", pr.jsxValue(a.value)}
+	}
+	return nil
+}
+
+func (pr *jsPrinter) jsxValue(n jsNode) doc {
+	switch v := n.(type) {
+	case *jsLiteral:
+		return v.raw
+	case *jsxExprContainer:
+		return pr.jsxContainer(v)
+	}
+	return pr.expr(n)
+}
+
+func (pr *jsPrinter) jsxContainer(c *jsxExprContainer) doc {
+	if c.expr == nil {
+		// Only comments, if anything: keep them as written
+		pr.markDone(c.start, c.end)
+		return pr.src[c.start:c.end]
+	}
+	if c.spread {
+		return []doc{"{...", pr.expr(c.expr), "}"}
+	}
+	switch c.expr.(type) {
+	case *jsObject, *jsFunction, *jsCall, *jsTemplateLiteral, *jsArray:
+		return []doc{"{", pr.expr(c.expr), "}"}
+	}
+	return groupDoc("{", indentDoc(softLine, pr.chain(c.expr)), softLine, "}")
+}
+
+// jsxChildren builds fill parts (content, separator, content, ...) from
+// children. JSX drops whitespace that contains a line break and collapses
+// other runs to one space, so words may be rewrapped freely but a space
+// next to a break must become {" "}. Elements and expressions go on their
+// own lines unless they touch text.
+func (pr *jsPrinter) jsxChildren(children []jsNode) (parts []doc, forceBreak bool) {
+	const (
+		none = iota
+		text
+		node
+	)
+	last := none
+	pendingSep := doc(nil) // separator waiting for the next content
+	pendingBreak := false  // the pending separator came from a line break
+
+	addContent := func(d doc, kind int) {
+		if last != none {
+			sep := pendingSep
+			switch {
+			case sep == nil && last == node && kind == node:
+				sep = hardLine
+			case sep == nil:
+				sep = softLine
+			case pendingBreak && (last == node || kind == node):
+				sep = hardLine
+			}
+			parts = append(parts, sep)
+		} else if pendingSep == jsxSeparator {
+			parts = append(parts, "", jsxSeparator)
+		}
+		parts = append(parts, d)
+		last = kind
+		pendingSep, pendingBreak = nil, false
+	}
+	whitespace := func(ws string) {
+		switch {
+		case ws == "":
+		case strings.ContainsAny(ws, "\n\r"):
+			if pendingSep == nil {
+				pendingSep, pendingBreak = softLine, true
+			}
+		default:
+			pendingSep, pendingBreak = jsxSeparator, false
+		}
+	}
+
+	expressions := 0
+	for _, child := range children {
+		switch c := child.(type) {
+		case *jsxText:
+			raw := c.raw
+			i := 0
+			for i < len(raw) && isJSXSpace(raw[i]) {
+				i++
+			}
+			whitespace(raw[:i])
+			for i < len(raw) {
+				j := i
+				for j < len(raw) && !isJSXSpace(raw[j]) {
+					j++
+				}
+				addContent(raw[i:j], text)
+				k := j
+				for k < len(raw) && isJSXSpace(raw[k]) {
+					k++
+				}
+				if k < len(raw) {
+					pendingSep = spaceLine
+				} else {
+					whitespace(raw[j:k])
+				}
+				i = k
+			}
+		case *jsxExprContainer:
+			if c.expr != nil {
+				expressions++
+			}
+			addContent(pr.jsxContainer(c), node)
+		case *jsxElement:
+			forceBreak = true
+			addContent(pr.jsxElement(c), node)
+		}
+	}
+
+	if last == none {
+		return nil, false
+	}
+	if pendingSep == jsxSeparator {
+		parts = append(parts, ifBreakDoc(`{" "}`, " "), "")
+	}
+	return parts, forceBreak || expressions > 1
+}
+
+// TypeScript types
+
+func (pr *jsPrinter) types(nodes []jsNode) []doc {
+	docs := make([]doc, len(nodes))
+	for i, n := range nodes {
+		docs[i] = pr.typ(n)
+	}
+	return docs
+}
+
+func (pr *jsPrinter) typeArgs(args []jsNode) doc {
+	if len(args) == 0 {
+		return nil
+	}
+	return groupDoc("<", indentDoc(softLine, joinDocs([]doc{",", spaceLine}, pr.types(args))), softLine, ">")
+}
+
+func (pr *jsPrinter) typeParams(tp *tsTypeParams) doc {
+	if tp == nil {
+		return nil
+	}
+	params := make([]doc, len(tp.params))
+	for i, p := range tp.params {
+		params[i] = pr.typeParam(p)
+	}
+	return groupDoc("<", indentDoc(softLine, joinDocs([]doc{",", spaceLine}, params)), softLine, ">")
+}
+
+func (pr *jsPrinter) typeParam(p *tsTypeParam) doc {
+	parts := []doc{}
+	for _, m := range p.modifiers {
+		parts = append(parts, m, " ")
+	}
+	parts = append(parts, p.name.name)
+	if p.constraint != nil {
+		parts = append(parts, " extends ", pr.typ(p.constraint))
+	}
+	if p.def != nil {
+		parts = append(parts, " = ", pr.typ(p.def))
+	}
+	return parts
+}
+
+func (pr *jsPrinter) unionMembers(u *tsUnion) doc {
+	return joinDocs([]doc{spaceLine, u.op + " "}, pr.types(u.types))
+}
+
+// typeBody prints an interface body, which is always expanded
+func (pr *jsPrinter) typeBody(lit *tsTypeLiteral) doc {
+	inner := pr.list(lit.members, lit.end-1, func(n jsNode) doc {
+		return []doc{pr.typeMember(n), ";"}
+	})
+	if inner == nil {
+		return "{}"
+	}
+	return []doc{"{", indentDoc(hardLine, inner), hardLine, "}"}
+}
+
+func (pr *jsPrinter) typeMember(n jsNode) doc {
+	switch m := n.(type) {
+	case *tsPropertySig:
+		parts := []doc{}
+		for _, mod := range m.modifiers {
+			parts = append(parts, mod, " ")
+		}
+		if m.kind != "" {
+			parts = append(parts, m.kind, " ")
+		}
+		parts = append(parts, pr.propertyKey(m.key, m.computed))
+		if m.optional {
+			parts = append(parts, "?")
+		}
+		if m.method {
+			parts = append(parts, pr.typeParams(m.typeParams), pr.params(m.params, m.start))
+		}
+		if m.typ != nil {
+			parts = append(parts, ": ", pr.typ(m.typ))
+		}
+		return parts
+	case *tsCallSig:
+		parts := []doc{}
+		if m.construct {
+			parts = append(parts, "new ")
+		}
+		parts = append(parts, pr.typeParams(m.typeParams), pr.params(m.params, m.start))
+		if m.returnType != nil {
+			parts = append(parts, ": ", pr.typ(m.returnType))
+		}
+		return parts
+	case *tsIndexSig:
+		parts := []doc{}
+		for _, mod := range m.modifiers {
+			parts = append(parts, mod, " ")
+		}
+		parts = append(parts, "[", pr.expr(m.param.pattern), ": ", pr.typ(m.param.typeAnn), "]")
+		if m.typ != nil {
+			parts = append(parts, ": ", pr.typ(m.typ))
+		}
+		return parts
+	}
+	return pr.typ(n)
+}
+
+func (pr *jsPrinter) typ(n jsNode) doc {
+	switch t := n.(type) {
+	case nil:
+		return nil
+	case *tsTypeRef:
+		return []doc{t.name, pr.typeArgs(t.args)}
+	case *tsKeywordType:
+		return pr.str(t.text)
+	case *tsUnion:
+		if len(t.types) == 1 {
+			return pr.typ(t.types[0])
+		}
+		if t.op == "&" {
+			return joinDocs(" & ", pr.types(t.types))
+		}
+		return groupDoc(indentDoc(softLine, ifBreakDoc("| ", nil), pr.unionMembers(t)))
+	case *tsArrayType:
+		return []doc{pr.typ(t.elem), "[]"}
+	case *tsIndexedAccess:
+		return []doc{pr.typ(t.object), "[", pr.typ(t.index), "]"}
+	case *tsTuple:
+		elems := make([]jsNode, len(t.elems))
+		for i, e := range t.elems {
+			elems[i] = e
+		}
+		trailing := len(t.elems) == 0 || !t.elems[len(t.elems)-1].rest
+		return pr.delimited("[", "]", elems, t.end-1, pr.typ, false, trailing)
+	case *tsTupleElem:
+		parts := []doc{}
+		if t.rest {
+			parts = append(parts, "...")
+		}
+		if t.name != "" {
+			parts = append(parts, t.name)
+			if t.optional {
+				parts = append(parts, "?")
+			}
+			return append(parts, ": ", pr.typ(t.typ))
+		}
+		parts = append(parts, pr.typ(t.typ))
+		if t.optional {
+			parts = append(parts, "?")
+		}
+		return parts
+	case *tsFunctionType:
+		parts := []doc{}
+		if t.abstract {
+			parts = append(parts, "abstract ")
+		}
+		if t.construct {
+			parts = append(parts, "new ")
+		}
+		return append(parts, pr.typeParams(t.typeParams), pr.params(t.params, t.start), " => ", pr.typ(t.returnType))
+	case *tsTypeLiteral:
+		return pr.delimitedMembers(t)
+	case *tsPropertySig, *tsCallSig, *tsIndexSig:
+		return pr.typeMember(n)
+	case *tsMappedType:
+		inner := []doc{}
+		if t.readonly != "" {
+			inner = append(inner, t.readonly, " ")
+		}
+		inner = append(inner, "[", t.param, " in ", pr.typ(t.constraint))
+		if t.nameType != nil {
+			inner = append(inner, " as ", pr.typ(t.nameType))
+		}
+		inner = append(inner, "]", t.optional)
+		if t.typ != nil {
+			inner = append(inner, ": ", pr.typ(t.typ))
+		}
+		return groupDoc("{", indentDoc(spaceLine, inner, ifBreakDoc(";", nil)), spaceLine, "}")
+	case *tsConditionalType:
+		return groupDoc(pr.typ(t.check), " extends ", pr.typ(t.extends),
+			indentDoc(spaceLine, "? ", pr.typ(t.trueType), spaceLine, ": ", pr.typ(t.falseType)))
+	case *tsTypeOperator:
+		return []doc{t.op, " ", pr.typ(t.typ)}
+	case *tsInferType:
+		if t.constraint != nil {
+			return []doc{"infer ", t.name.name, " extends ", pr.typ(t.constraint)}
+		}
+		return []doc{"infer ", t.name.name}
+	case *tsTypeQuery:
+		return []doc{"typeof ", t.expr, pr.typeArgs(t.args)}
+	case *tsParenType:
+		return []doc{"(", pr.typ(t.typ), ")"}
+	case *tsImportType:
+		parts := []doc{"import(", pr.str(t.arg.raw), ")"}
+		if t.qualifier != "" {
+			parts = append(parts, ".", t.qualifier)
+		}
+		return append(parts, pr.typeArgs(t.args))
+	case *tsTypePredicate:
+		parts := []doc{}
+		if t.asserts {
+			parts = append(parts, "asserts ")
+		}
+		parts = append(parts, t.param)
+		if t.typ != nil {
+			parts = append(parts, " is ", pr.typ(t.typ))
+		}
+		return parts
+	case *tsTemplateType:
+		parts := []doc{}
+		for i, q := range t.quasis {
+			parts = append(parts, q)
+			if i < len(t.types) {
+				parts = append(parts, pr.typ(t.types[i]))
+			}
+		}
+		return parts
+	}
+	return pr.expr(n)
+}
+
+// delimitedMembers prints an inline object type with ; separators
+func (pr *jsPrinter) delimitedMembers(t *tsTypeLiteral) doc {
+	if len(t.members) == 0 {
+		if d := pr.dangling(t.end - 1); d != nil {
+			return []doc{"{ ", d, " }"}
+		}
+		return "{}"
+	}
+
+	var inner []doc
+	for i, m := range t.members {
+		if i > 0 {
+			inner = append(inner, ";", spaceLine)
+		}
+		inner = append(inner, pr.item(m, pr.typeMember))
+	}
+	if d := pr.dangling(t.end - 1); d != nil {
+		inner = append(inner, ";", hardLine, d)
+	} else {
+		inner = append(inner, ifBreakDoc(";", nil))
+	}
+	g := groupDoc("{", indentDoc(spaceLine, inner), spaceLine, "}")
+	g.shouldBreak = t.multiline
+	return g
+}
@@ -0,0 +1,107 @@
+package main
+
+import "testing"
+
+func TestFormatJSComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		code     string
+		want     string
+	}{
+		{"trailing line comment", "javascript", "a()\n// end", "a();\n// end\n"},
+		{"only a comment", "javascript", "// only", "// only\n"},
+		{"trailing block comment", "javascript", "a();\n/* end */", "a();\n/* end */\n"},
+		{"trailing comment and newline", "javascript", "a()\n// end\n", "a();\n// end\n"},
+		{"typescript", "typescript", "let x: number = 1\n// end", "let x: number = 1;\n// end\n"},
+		{"tsx", "tsx", "const a = <b />\n/* end */", "const a = <b />;\n/* end */\n"},
+		{"html script", "html", "<script>\na()\n// end</script>", "<script>\n  a();\n  // end\n</script>\n"},
+		{"comment on the same line", "javascript", "a() // call\nb()", "a(); // call\nb();\n"},
+		{"comment in an empty block", "javascript", "function f() {\n// todo\n}", "function f() {\n  // todo\n}\n"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Format(tt.code, tt.language)
+			if err != nil {
+				t.Fatalf("Format(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatJSPrograms(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		code     string
+		want     string
+	}{
+		{
+			"arrow function", "javascript",
+			"const f=async(a,{b,c=1},...d)=>{for(const x of a){if(x)continue;else break}return await g?.(b)??c}",
+			"const f = async (a, { b, c = 1 }, ...d) => {\n  for (const x of a) {\n    if (x) continue;\n    else break;\n  }\n  return await g?.(b) ?? c;\n};\n",
+		},
+		{
+			"class", "javascript",
+			"class A extends B{static #x=1;get y(){return this.#x}constructor(){super();this.z=[1,2,3].map(v=>v*2)}}",
+			"class A extends B {\n  static #x = 1;\n  get y() {\n    return this.#x;\n  }\n  constructor() {\n    super();\n    this.z = [1, 2, 3].map((v) => v * 2);\n  }\n}\n",
+		},
+		{
+			"long call", "javascript",
+			"const veryLongVariableName = someFunction(argumentNumberOne, argumentNumberTwo, argumentNumberThree, four)",
+			"const veryLongVariableName = someFunction(\n  argumentNumberOne,\n  argumentNumberTwo,\n  argumentNumberThree,\n  four,\n);\n",
+		},
+		{"object literal", "javascript", "let a = {x:1,'y-z':2,[k]:3,m(){},...rest}", "let a = { x: 1, 'y-z': 2, [k]: 3, m() {}, ...rest };\n"},
+		{
+			"switch", "javascript",
+			"label: switch(x){case 1:case 2:y();break;default:z()}",
+			"label: switch (x) {\n  case 1:\n  case 2:\n    y();\n    break;\n  default:\n    z();\n}\n",
+		},
+		{
+			"types", "typescript",
+			"interface P<T extends object>{a?:T;readonly b:string[]}type U=A|B;function f<T>(x:T):x is T{return x as any}",
+			"interface P<T extends object> {\n  a?: T;\n  readonly b: string[];\n}\ntype U = A | B;\nfunction f<T>(x: T): x is T {\n  return x as any;\n}\n",
+		},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Format(tt.code, tt.language)
+			if err != nil {
+				t.Fatalf("Format(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if again, err := f.Format(got, tt.language); err != nil || again != got {
+				t.Errorf("Format(%q) = %q, %v; not idempotent", got, again, err)
+			}
+		})
+	}
+}
+
+func TestFormatJSErrors(t *testing.T) {
+	tests := []struct {
+		language string
+		code     string
+		want     string
+	}{
+		{"javascript", "let a = (1 +", "invalid JavaScript syntax: 1:13: unexpected end of input"},
+		{"javascript", "function (){}", "invalid JavaScript syntax: 1:10: unexpected token ("},
+		{"typescript", "let y = 2\nlet x: = 1", "invalid TypeScript syntax: 2:8: unexpected token ="},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		_, err := f.Format(tt.code, tt.language)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Format(%q) error = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,702 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// jsScope is a region of a program in which names are bound
+type jsScope struct {
+	parent   *jsScope
+	children []*jsScope
+	// function is set for the scopes var declarations are hoisted to
+	function bool
+	bindings map[string]*jsBinding
+	order    []*jsBinding
+	// dynamic is set when eval or with may look names up by their text
+	dynamic bool
+}
+
+// jsBinding is a declared name and every identifier referring to it
+type jsBinding struct {
+	name   string
+	scope  *jsScope
+	idents []*jsIdent
+	decls  int
+	// keep is set for names that must not change: globals, exports,
+	// reserved names and names visible to eval or with
+	keep bool
+	// fn is the function a function declaration binds, for names that
+	// only a function declaration in a function body binds
+	fn      *jsFunction
+	mangled string
+}
+
+// jsReference is an identifier to be resolved once every declaration is
+// known
+type jsReference struct {
+	id    *jsIdent
+	scope *jsScope
+	// within lists the functions enclosing the reference
+	within []*jsFunction
+}
+
+// jsAnalysis binds the identifiers of a program to their declarations
+type jsAnalysis struct {
+	program *jsScope
+	scope   *jsScope
+	refs    []jsReference
+	// shorthand holds the text that must precede the new name of an
+	// identifier standing for both a property name and a binding
+	shorthand map[*jsIdent]string
+	exported  []*jsIdent
+	free      map[string]bool
+	functions []*jsFunction
+	withDepth int
+	// escaped is set when an identifier is written with escapes, which
+	// would make names compare unequal by their text
+	escaped bool
+}
+
+// jsUnsafeNames may not be used as new names: keywords, words reserved in
+// strict mode and names with special meanings
+var jsUnsafeNames = map[string]bool{
+	"let": true, "static": true, "implements": true, "interface": true,
+	"package": true, "private": true, "protected": true, "public": true,
+	"arguments": true, "eval": true, "undefined": true, "NaN": true,
+	"Infinity": true, "as": true, "of": true, "async": true, "get": true,
+	"set": true, "from": true,
+}
+
+// analyzeJS resolves the names of a program. Names in reserved are never
+// renamed.
+func analyzeJS(src string, prog *jsProgram, reserved []string) *jsAnalysis {
+	a := &jsAnalysis{shorthand: map[*jsIdent]string{}, free: map[string]bool{}}
+	a.program = a.push(true)
+	module := false
+	for _, n := range prog.body {
+		switch n.(type) {
+		case *jsImport, *jsExport:
+			module = true
+		}
+	}
+	a.statements(prog.body, true)
+	a.scope = nil
+
+	for _, r := range a.refs {
+		if b := r.scope.lookup(r.id.name); b != nil {
+			b.idents = append(b.idents, r.id)
+			continue
+		}
+		a.free[r.id.name] = true
+		if r.id.name == "eval" {
+			r.scope.markDynamic()
+		}
+	}
+	for _, id := range a.exported {
+		if b := a.program.lookup(id.name); b != nil {
+			b.keep = true
+		}
+	}
+
+	keep := map[string]bool{}
+	for _, name := range reserved {
+		keep[name] = true
+	}
+	var mark func(s *jsScope)
+	mark = func(s *jsScope) {
+		for _, b := range s.order {
+			if s.dynamic || s == a.program && !module || keep[b.name] || jsKeywords[b.name] || jsUnsafeNames[b.name] {
+				b.keep = true
+			}
+		}
+		for _, child := range s.children {
+			mark(child)
+		}
+	}
+	mark(a.program)
+	return a
+}
+
+func (s *jsScope) lookup(name string) *jsBinding {
+	for ; s != nil; s = s.parent {
+		if b := s.bindings[name]; b != nil {
+			return b
+		}
+	}
+	return nil
+}
+
+// markDynamic marks a scope and every scope enclosing it as dynamic
+func (s *jsScope) markDynamic() {
+	for ; s != nil; s = s.parent {
+		s.dynamic = true
+	}
+}
+
+func (a *jsAnalysis) push(function bool) *jsScope {
+	s := &jsScope{parent: a.scope, function: function, bindings: map[string]*jsBinding{}, dynamic: a.withDepth > 0}
+	if a.scope != nil {
+		a.scope.children = append(a.scope.children, s)
+	}
+	a.scope = s
+	return s
+}
+
+func (a *jsAnalysis) pop() {
+	a.scope = a.scope.parent
+}
+
+// declare binds an identifier in a scope
+func (a *jsAnalysis) declare(s *jsScope, id *jsIdent) *jsBinding {
+	if strings.Contains(id.name, `\`) {
+		a.escaped = true
+	}
+	b := s.bindings[id.name]
+	if b == nil {
+		b = &jsBinding{name: id.name, scope: s}
+		s.bindings[id.name] = b
+		s.order = append(s.order, b)
+	}
+	b.idents = append(b.idents, id)
+	b.decls++
+	return b
+}
+
+// declareVar binds a var in the enclosing function scope. A var redeclaring
+// a catch parameter assigns to the parameter, so both keep their name.
+func (a *jsAnalysis) declareVar(id *jsIdent) {
+	s := a.scope
+	var shadowed []*jsBinding
+	for ; !s.function; s = s.parent {
+		if b := s.bindings[id.name]; b != nil {
+			shadowed = append(shadowed, b)
+		}
+	}
+	b := a.declare(s, id)
+	for _, other := range shadowed {
+		other.keep = true
+		b.keep = true
+	}
+}
+
+func (a *jsAnalysis) reference(id *jsIdent) {
+	if strings.HasPrefix(id.name, "#") || id.name == "import" {
+		return
+	}
+	if strings.Contains(id.name, `\`) {
+		a.escaped = true
+	}
+	within := append([]*jsFunction(nil), a.functions...)
+	a.refs = append(a.refs, jsReference{id: id, scope: a.scope, within: within})
+}
+
+// pattern declares the names bound by a binding pattern with bind and
+// walks the expressions in its defaults and computed keys
+func (a *jsAnalysis) pattern(n jsNode, bind func(id *jsIdent)) {
+	switch n := n.(type) {
+	case *jsIdent:
+		bind(n)
+	case *jsArray:
+		for _, e := range n.elems {
+			a.pattern(e, bind)
+		}
+	case *jsObject:
+		for _, p := range n.props {
+			a.pattern(p, bind)
+		}
+	case *jsProperty:
+		if n.computed {
+			a.expr(n.key)
+		}
+		if n.shorthand {
+			id := n.key.(*jsIdent)
+			a.shorthand[id] = id.name + ":"
+		}
+		a.pattern(n.value, bind)
+	case *jsSpread:
+		a.pattern(n.arg, bind)
+	case *jsBinary:
+		a.pattern(n.left, bind)
+		a.expr(n.right)
+	case *jsParen:
+		a.pattern(n.expr, bind)
+	default:
+		a.expr(n)
+	}
+}
+
+// statements walks a statement list. body is set for the body of a
+// program or function, where function declarations bind function-wide.
+func (a *jsAnalysis) statements(list []jsNode, body bool) {
+	for _, n := range list {
+		if fn, ok := n.(*jsFunction); ok && fn.name != nil {
+			b := a.declare(a.scope, fn.name)
+			if body {
+				b.fn = fn
+			} else {
+				// Sloppy mode code also binds it outside the block
+				b.keep = true
+			}
+		}
+	}
+	for _, n := range list {
+		a.statement(n)
+	}
+}
+
+func (a *jsAnalysis) statement(n jsNode) {
+	switch n := n.(type) {
+	case nil:
+	case *jsFunction:
+		a.function(n, false)
+	case *jsClass:
+		if n.name != nil {
+			a.declare(a.scope, n.name)
+		}
+		a.class(n, false)
+	case *jsVarDecl:
+		for _, d := range n.decls {
+			a.pattern(d.target, func(id *jsIdent) {
+				if n.kind == "var" {
+					a.declareVar(id)
+				} else {
+					a.declare(a.scope, id)
+				}
+			})
+			a.expr(d.init)
+		}
+	case *jsExprStmt:
+		a.expr(n.expr)
+	case *jsBlock:
+		a.push(false)
+		a.statements(n.body, false)
+		a.pop()
+	case *jsIf:
+		a.expr(n.test)
+		a.nested(n.cons)
+		a.nested(n.alt)
+	case *jsFor:
+		a.push(false)
+		if _, ok := n.init.(*jsVarDecl); ok {
+			a.statement(n.init)
+		} else {
+			a.expr(n.init)
+		}
+		a.expr(n.test)
+		a.expr(n.update)
+		a.nested(n.body)
+		a.pop()
+	case *jsForIn:
+		a.push(false)
+		if _, ok := n.left.(*jsVarDecl); ok {
+			a.statement(n.left)
+		} else {
+			a.expr(n.left)
+		}
+		a.expr(n.right)
+		a.nested(n.body)
+		a.pop()
+	case *jsWhile:
+		a.expr(n.test)
+		a.nested(n.body)
+	case *jsDoWhile:
+		a.nested(n.body)
+		a.expr(n.test)
+	case *jsReturn:
+		a.expr(n.arg)
+	case *jsThrow:
+		a.expr(n.arg)
+	case *jsTry:
+		a.statement(n.block)
+		if n.handler != nil {
+			a.push(false)
+			if n.param != nil {
+				a.pattern(n.param, func(id *jsIdent) { a.declare(a.scope, id) })
+			}
+			a.statements(n.handler.body, false)
+			a.pop()
+		}
+		if n.finalizer != nil {
+			a.statement(n.finalizer)
+		}
+	case *jsSwitch:
+		a.expr(n.disc)
+		a.push(false)
+		var body []jsNode
+		for _, sc := range n.cases {
+			body = append(body, sc.body...)
+		}
+		for _, sc := range n.cases {
+			a.expr(sc.test)
+		}
+		a.statements(body, false)
+		a.pop()
+	case *jsLabeled:
+		a.nested(n.body)
+	case *jsWith:
+		a.expr(n.object)
+		a.scope.markDynamic()
+		a.withDepth++
+		a.nested(n.body)
+		a.withDepth--
+	case *jsImport:
+		for _, id := range []*jsIdent{n.defaultName, n.namespace} {
+			if id != nil {
+				a.declare(a.scope, id)
+			}
+		}
+		for _, spec := range n.named {
+			if spec.exported == nil {
+				if id, ok := spec.local.(*jsIdent); ok {
+					a.shorthand[id] = id.name + " as "
+					a.declare(a.scope, id)
+				}
+			} else if id, ok := spec.exported.(*jsIdent); ok {
+				a.declare(a.scope, id)
+			}
+		}
+	case *jsExport:
+		a.export(n)
+	}
+}
+
+// nested walks a statement that is the body of another statement
+func (a *jsAnalysis) nested(n jsNode) {
+	switch n.(type) {
+	case *jsFunction, *jsClass, *jsVarDecl:
+		// Declarations as bodies are only allowed in sloppy mode code
+		a.push(false)
+		a.statements([]jsNode{n}, false)
+		a.pop()
+	default:
+		a.statement(n)
+	}
+}
+
+func (a *jsAnalysis) export(n *jsExport) {
+	for _, d := range n.decorators {
+		a.expr(d)
+	}
+	switch decl := n.decl.(type) {
+	case nil:
+	case *jsFunction:
+		if decl.name != nil {
+			b := a.declare(a.scope, decl.name)
+			b.keep = !n.isDefault
+		}
+		a.function(decl, false)
+	case *jsClass:
+		if decl.name != nil {
+			b := a.declare(a.scope, decl.name)
+			b.keep = !n.isDefault
+		}
+		a.class(decl, false)
+	case *jsVarDecl:
+		a.statement(decl)
+		for _, d := range decl.decls {
+			a.pattern(d.target, func(id *jsIdent) { a.exported = append(a.exported, id) })
+		}
+	default:
+		a.expr(decl)
+	}
+	if n.source != nil {
+		return
+	}
+	for _, spec := range n.named {
+		if id, ok := spec.local.(*jsIdent); ok {
+			a.reference(id)
+			a.exported = append(a.exported, id)
+		}
+	}
+}
+
+// function walks a function. A named function expression binds its name
+// in a scope of its own.
+func (a *jsAnalysis) function(fn *jsFunction, expression bool) {
+	if expression && fn.name != nil {
+		a.push(false)
+		a.declare(a.scope, fn.name)
+		defer a.pop()
+	}
+	a.functions = append(a.functions, fn)
+	a.push(true)
+	for _, p := range fn.params {
+		for _, d := range p.decorators {
+			a.expr(d)
+		}
+		a.pattern(p.pattern, func(id *jsIdent) { a.declare(a.scope, id) })
+		a.expr(p.init)
+	}
+	if fn.body != nil {
+		a.statements(fn.body.body, true)
+	}
+	a.expr(fn.exprBody)
+	a.pop()
+	a.functions = a.functions[:len(a.functions)-1]
+}
+
+// class walks a class. A named class expression binds its name in a scope
+// of its own.
+func (a *jsAnalysis) class(c *jsClass, expression bool) {
+	for _, d := range c.decorators {
+		a.expr(d)
+	}
+	if expression && c.name != nil {
+		a.push(false)
+		a.declare(a.scope, c.name)
+		defer a.pop()
+	}
+	a.expr(c.superClass)
+	for _, m := range c.members {
+		switch m := m.(type) {
+		case *jsMethod:
+			for _, d := range m.decorators {
+				a.expr(d)
+			}
+			if m.computed {
+				a.expr(m.key)
+			}
+			a.function(m.fn, false)
+		case *jsField:
+			for _, d := range m.decorators {
+				a.expr(d)
+			}
+			if m.computed {
+				a.expr(m.key)
+			}
+			a.push(true)
+			a.expr(m.value)
+			a.pop()
+		case *jsStaticBlock:
+			a.push(true)
+			a.statements(m.body, true)
+			a.pop()
+		}
+	}
+}
+
+func (a *jsAnalysis) expr(n jsNode) {
+	switch n := n.(type) {
+	case nil:
+	case *jsIdent:
+		a.reference(n)
+	case *jsFunction:
+		a.function(n, true)
+	case *jsClass:
+		a.class(n, true)
+	case *jsDecorator:
+		a.expr(n.expr)
+	case *jsTemplateLiteral:
+		a.expr(n.tag)
+		for _, e := range n.exprs {
+			a.expr(e)
+		}
+	case *jsArray:
+		for _, e := range n.elems {
+			a.expr(e)
+		}
+	case *jsObject:
+		for _, p := range n.props {
+			a.expr(p)
+		}
+	case *jsProperty:
+		if n.computed {
+			a.expr(n.key)
+		}
+		if n.fn != nil {
+			a.function(n.fn, false)
+			return
+		}
+		if n.shorthand {
+			id := n.key.(*jsIdent)
+			a.shorthand[id] = id.name + ":"
+		}
+		a.expr(n.value)
+	case *jsSpread:
+		a.expr(n.arg)
+	case *jsUnary:
+		a.expr(n.arg)
+	case *jsUpdate:
+		a.expr(n.arg)
+	case *jsBinary:
+		a.expr(n.left)
+		a.expr(n.right)
+	case *jsConditional:
+		a.expr(n.test)
+		a.expr(n.cons)
+		a.expr(n.alt)
+	case *jsCall:
+		a.expr(n.callee)
+		for _, arg := range n.args {
+			a.expr(arg)
+		}
+	case *jsMember:
+		a.expr(n.object)
+		if n.computed {
+			a.expr(n.property)
+		}
+	case *jsSequence:
+		for _, e := range n.exprs {
+			a.expr(e)
+		}
+	case *jsParen:
+		a.expr(n.expr)
+	case *jsYield:
+		a.expr(n.arg)
+	case *jsAwait:
+		a.expr(n.arg)
+	}
+}
+
+// unusedFunctions returns the function declarations nothing calls or
+// refers to, other than themselves and other unused functions
+func (a *jsAnalysis) unusedFunctions() map[*jsFunction]bool {
+	if a.escaped {
+		return nil
+	}
+	candidates := map[*jsFunction]*jsBinding{}
+	var collect func(s *jsScope)
+	collect = func(s *jsScope) {
+		for _, b := range s.order {
+			if b.fn != nil && b.decls == 1 && !b.keep {
+				candidates[b.fn] = b
+			}
+		}
+		for _, child := range s.children {
+			collect(child)
+		}
+	}
+	collect(a.program)
+
+	refs := map[*jsBinding][]jsReference{}
+	for _, r := range a.refs {
+		if b := r.scope.lookup(r.id.name); b != nil && candidates[b.fn] == b {
+			refs[b] = append(refs[b], r)
+		}
+	}
+	// A function is used once a reference outside every function not yet
+	// known to be used refers to it
+	used := map[*jsFunction]bool{}
+	for changed := true; changed; {
+		changed = false
+		for fn, b := range candidates {
+			if used[fn] {
+				continue
+			}
+			for _, r := range refs[b] {
+				live := true
+				for _, outer := range r.within {
+					if candidates[outer] != nil && !used[outer] {
+						live = false
+						break
+					}
+				}
+				if live {
+					used[fn], changed = true, true
+					break
+				}
+			}
+		}
+	}
+
+	unused := map[*jsFunction]bool{}
+	for fn := range candidates {
+		if !used[fn] {
+			unused[fn] = true
+		}
+	}
+	return unused
+}
+
+// mangle gives every local binding the shortest name that neither a
+// global, a kept name nor a binding of an enclosing scope uses, shortest
+// names going to the most used bindings
+func (a *jsAnalysis) mangle(reserved []string) []jsEdit {
+	if a.escaped {
+		return nil
+	}
+	avoid := map[string]bool{}
+	for name := range a.free {
+		avoid[name] = true
+	}
+	for _, name := range reserved {
+		avoid[name] = true
+	}
+	var kept func(s *jsScope)
+	kept = func(s *jsScope) {
+		for _, b := range s.order {
+			if b.keep {
+				avoid[b.name] = true
+			}
+		}
+		for _, child := range s.children {
+			kept(child)
+		}
+	}
+	kept(a.program)
+
+	var edits []jsEdit
+	taken := map[string]bool{}
+	var assign func(s *jsScope)
+	assign = func(s *jsScope) {
+		bindings := append([]*jsBinding(nil), s.order...)
+		sort.SliceStable(bindings, func(i, j int) bool {
+			return len(bindings[i].idents) > len(bindings[j].idents)
+		})
+		var added []string
+		next := 0
+		for _, b := range bindings {
+			if b.keep {
+				continue
+			}
+			name := jsShortName(next)
+			for next++; taken[name] || avoid[name]; next++ {
+				name = jsShortName(next)
+			}
+			b.mangled = name
+			taken[name] = true
+			added = append(added, name)
+			if name == b.name {
+				continue
+			}
+			for _, id := range b.idents {
+				edits = append(edits, jsEdit{start: id.start, end: id.end, text: a.shorthand[id] + name, name: b.name})
+			}
+		}
+		for _, child := range s.children {
+			assign(child)
+		}
+		for _, name := range added {
+			delete(taken, name)
+		}
+	}
+	assign(a.program)
+	return edits
+}
+
+const (
+	jsNameStart = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ$_"
+	jsNamePart  = jsNameStart + "0123456789"
+)
+
+// jsShortName returns the i-th identifier in order of length, skipping
+// keywords and names with special meanings
+func jsShortName(i int) string {
+	for {
+		name := jsNthName(i)
+		if !jsKeywords[name] && !jsUnsafeNames[name] {
+			return name
+		}
+		i++
+	}
+}
+
+func jsNthName(i int) string {
+	name := []byte{jsNameStart[i%len(jsNameStart)]}
+	for i /= len(jsNameStart); i > 0; i /= len(jsNamePart) {
+		i--
+		name = append(name, jsNamePart[i%len(jsNamePart)])
+	}
+	return string(name)
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxJSONDepth bounds nesting so hostile input cannot exhaust the stack
+const maxJSONDepth = 10000
+
+type jsonTokenKind int
+
+const (
+	jsonEOF jsonTokenKind = iota
+	jsonBeginObject
+	jsonEndObject
+	jsonBeginArray
+	jsonEndArray
+	jsonColon
+	jsonComma
+	jsonString
+	jsonNumber
+	jsonLiteral
+)
+
+// jsonToken is a lexical JSON token. Text holds the exact source bytes so
+// strings and numbers are reproduced verbatim.
+type jsonToken struct {
+	kind   jsonTokenKind
+	text   string
+	offset int
+}
+
+// jsonScanner splits JSON source into tokens without decoding values
+type jsonScanner struct {
+	src    string
+	pos    int
+	peeked *jsonToken
+}
+
+func newJSONScanner(src string) *jsonScanner {
+	s := &jsonScanner{src: src}
+	// A UTF-8 byte order mark is tolerated and dropped
+	if strings.HasPrefix(src, "\uFEFF") {
+		s.pos = len("\uFEFF")
+	}
+	return s
+}
+
+func (s *jsonScanner) errorf(offset int, format string, args ...interface{}) error {
+	return newSyntaxError(syntaxJSON, s.src, offset, format, args...)
+}
+
+func (s *jsonScanner) peek() (jsonToken, error) {
+	if s.peeked != nil {
+		return *s.peeked, nil
+	}
+	tok, err := s.scan()
+	if err != nil {
+		return tok, err
+	}
+	s.peeked = &tok
+	return tok, nil
+}
+
+func (s *jsonScanner) next() (jsonToken, error) {
+	if s.peeked != nil {
+		tok := *s.peeked
+		s.peeked = nil
+		return tok, nil
+	}
+	return s.scan()
+}
+
+func (s *jsonScanner) skipWhitespace() {
+	for s.pos < len(s.src) {
+		switch s.src[s.pos] {
+		case ' ', '\t', '\n', '\r':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (s *jsonScanner) scan() (jsonToken, error) {
+	s.skipWhitespace()
+	start := s.pos
+	if s.pos >= len(s.src) {
+		return jsonToken{kind: jsonEOF, offset: start}, nil
+	}
+
+	single := func(kind jsonTokenKind) (jsonToken, error) {
+		s.pos++
+		return jsonToken{kind: kind, text: s.src[start:s.pos], offset: start}, nil
+	}
+
+	switch c := s.src[s.pos]; {
+	case c == '{':
+		return single(jsonBeginObject)
+	case c == '}':
+		return single(jsonEndObject)
+	case c == '[':
+		return single(jsonBeginArray)
+	case c == ']':
+		return single(jsonEndArray)
+	case c == ':':
+		return single(jsonColon)
+	case c == ',':
+		return single(jsonComma)
+	case c == '"':
+		return s.scanString()
+	case c == '-' || (c >= '0' && c <= '9'):
+		return s.scanNumber()
+	case c >= 'a' && c <= 'z':
+		for s.pos < len(s.src) && s.src[s.pos] >= 'a' && s.src[s.pos] <= 'z' {
+			s.pos++
+		}
+		word := s.src[start:s.pos]
+		if word != "true" && word != "false" && word != "null" {
+			return jsonToken{}, s.errorf(start, "invalid literal %q", word)
+		}
+		return jsonToken{kind: jsonLiteral, text: word, offset: start}, nil
+	default:
+		r, _ := utf8.DecodeRuneInString(s.src[s.pos:])
+		return jsonToken{}, s.errorf(start, "invalid character %q looking for beginning of value", r)
+	}
+}
+
+func (s *jsonScanner) scanString() (jsonToken, error) {
+	start := s.pos
+	s.pos++ // opening quote
+
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '"':
+			s.pos++
+			return jsonToken{kind: jsonString, text: s.src[start:s.pos], offset: start}, nil
+		case c == '\\':
+			if s.pos+1 >= len(s.src) {
+				return jsonToken{}, s.errorf(s.pos, "unterminated escape sequence")
+			}
+			switch s.src[s.pos+1] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				s.pos += 2
+			case 'u':
+				if s.pos+6 > len(s.src) || !isHexString(s.src[s.pos+2:s.pos+6]) {
+					return jsonToken{}, s.errorf(s.pos, "invalid unicode escape")
+				}
+				s.pos += 6
+			default:
+				return jsonToken{}, s.errorf(s.pos, "invalid escape sequence \\%c", s.src[s.pos+1])
+			}
+		case c < 0x20:
+			return jsonToken{}, s.errorf(s.pos, "invalid control character in string literal")
+		case c < utf8.RuneSelf:
+			s.pos++
+		default:
+			r, size := utf8.DecodeRuneInString(s.src[s.pos:])
+			if r == utf8.RuneError && size == 1 {
+				return jsonToken{}, s.errorf(s.pos, "invalid UTF-8 in string literal")
+			}
+			s.pos += size
+		}
+	}
+
+	return jsonToken{}, s.errorf(start, "unterminated string literal")
+}
+
+func (s *jsonScanner) scanNumber() (jsonToken, error) {
+	start := s.pos
+	digits := func() int {
+		n := 0
+		for s.pos < len(s.src) && s.src[s.pos] >= '0' && s.src[s.pos] <= '9' {
+			s.pos++
+			n++
+		}
+		return n
+	}
+
+	if s.src[s.pos] == '-' {
+		s.pos++
+	}
+	if s.pos < len(s.src) && s.src[s.pos] == '0' {
+		s.pos++
+	} else if digits() == 0 {
+		return jsonToken{}, s.errorf(start, "invalid number literal")
+	}
+	if s.pos < len(s.src) && s.src[s.pos] == '.' {
+		s.pos++
+		if digits() == 0 {
+			return jsonToken{}, s.errorf(start, "invalid number literal")
+		}
+	}
+	if s.pos < len(s.src) && (s.src[s.pos] == 'e' || s.src[s.pos] == 'E') {
+		s.pos++
+		if s.pos < len(s.src) && (s.src[s.pos] == '+' || s.src[s.pos] == '-') {
+			s.pos++
+		}
+		if digits() == 0 {
+			return jsonToken{}, s.errorf(start, "invalid number literal")
+		}
+	}
+	if s.pos < len(s.src) && (isAlphaNumeric(s.src[s.pos]) || s.src[s.pos] == '.') {
+		return jsonToken{}, s.errorf(start, "invalid number literal")
+	}
+
+	return jsonToken{kind: jsonNumber, text: s.src[start:s.pos], offset: start}, nil
+}
+
+func isHexString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonPrinter re-emits a JSON token stream, changing only whitespace
+type jsonPrinter struct {
+	scanner *jsonScanner
+	out     strings.Builder
+	indent  string
+	pretty  bool
+}
+
+// reprintJSON validates code and prints it either indented or compact.
+// Key order, duplicate keys, number literals and string escapes are kept
+// byte-for-byte.
+func reprintJSON(code, indent string, pretty bool) (string, error) {
+	p := &jsonPrinter{
+		scanner: newJSONScanner(code),
+		indent:  indent,
+		pretty:  pretty,
+	}
+
+	if err := p.value(0); err != nil {
+		return "", err
+	}
+
+	tok, err := p.scanner.next()
+	if err != nil {
+		return "", err
+	}
+	if tok.kind != jsonEOF {
+		return "", p.scanner.errorf(tok.offset, "invalid character %q after top-level value", tok.text)
+	}
+
+	return p.out.String(), nil
+}
+
+func (p *jsonPrinter) newline(depth int) {
+	if !p.pretty {
+		return
+	}
+	p.out.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		p.out.WriteString(p.indent)
+	}
+}
+
+func (p *jsonPrinter) value(depth int) error {
+	tok, err := p.scanner.next()
+	if err != nil {
+		return err
+	}
+
+	switch tok.kind {
+	case jsonString, jsonNumber, jsonLiteral:
+		p.out.WriteString(tok.text)
+		return nil
+	case jsonBeginObject:
+		return p.container(tok, jsonEndObject, depth)
+	case jsonBeginArray:
+		return p.container(tok, jsonEndArray, depth)
+	case jsonEOF:
+		return p.scanner.errorf(tok.offset, "unexpected end of JSON input")
+	default:
+		return p.scanner.errorf(tok.offset, "invalid character %q looking for beginning of value", tok.text)
+	}
+}
+
+func (p *jsonPrinter) container(open jsonToken, closeKind jsonTokenKind, depth int) error {
+	if depth >= maxJSONDepth {
+		return p.scanner.errorf(open.offset, "exceeded max nesting depth")
+	}
+	p.out.WriteString(open.text)
+
+	tok, err := p.scanner.peek()
+	if err != nil {
+		return err
+	}
+	if tok.kind == closeKind {
+		p.scanner.next()
+		p.out.WriteString(tok.text)
+		return nil
+	}
+
+	for {
+		p.newline(depth + 1)
+
+		if closeKind == jsonEndObject {
+			key, err := p.scanner.next()
+			if err != nil {
+				return err
+			}
+			if key.kind != jsonString {
+				return p.scanner.errorf(key.offset, "expected string for object key")
+			}
+			p.out.WriteString(key.text)
+
+			colon, err := p.scanner.next()
+			if err != nil {
+				return err
+			}
+			if colon.kind != jsonColon {
+				return p.scanner.errorf(colon.offset, "expected ':' after object key")
+			}
+			p.out.WriteByte(':')
+			if p.pretty {
+				p.out.WriteByte(' ')
+			}
+		}
+
+		if err := p.value(depth + 1); err != nil {
+			return err
+		}
+
+		sep, err := p.scanner.next()
+		if err != nil {
+			return err
+		}
+		switch sep.kind {
+		case jsonComma:
+			p.out.WriteByte(',')
+		case closeKind:
+			p.newline(depth)
+			p.out.WriteString(sep.text)
+			return nil
+		case jsonEOF:
+			return p.scanner.errorf(sep.offset, "unexpected end of JSON input")
+		default:
+			return p.scanner.errorf(sep.offset, "invalid character %q after %s element", sep.text, containerName(closeKind))
+		}
+	}
+}
+
+func containerName(closeKind jsonTokenKind) string {
+	if closeKind == jsonEndObject {
+		return "object"
+	}
+	return "array"
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFormatJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"key order", `{"b":1,"a":2}`, "{\n  \"b\": 1,\n  \"a\": 2\n}\n"},
+		{"number precision", `[1.10,1e400,12345678901234567890,-0.0]`, "[\n  1.10,\n  1e400,\n  12345678901234567890,\n  -0.0\n]\n"},
+		{"escapes", `{"s":"é\/\n\u00e9"}`, "{\n  \"s\": \"é\\/\\n\\u00e9\"\n}\n"},
+		{"empty containers", `{"o":{},"a":[]}`, "{\n  \"o\": {},\n  \"a\": []\n}\n"},
+		{"scalar", ` true `, "true\n"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Format(tt.code, "json")
+			if err != nil {
+				t.Fatalf("Format(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if again, err := f.Format(got, "json"); err != nil || again != got {
+				t.Errorf("Format(%q) = %q, %v; not idempotent", got, again, err)
+			}
+		})
+	}
+}
+
+func TestMinifyJSON(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"{ \"b\" : 1 ,\n  \"a\" : [ 1.10 , true , null ] }", `{"b":1,"a":[1.10,true,null]}`},
+		{`{"s": "x y", "t": "a\"b"}`, `{"s":"x y","t":"a\"b"}`},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		got, err := f.Minify(tt.code, "json")
+		if err != nil {
+			t.Fatalf("Minify(%q) error: %v", tt.code, err)
+		}
+		if got != tt.want {
+			t.Errorf("Minify(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+		if !json.Valid([]byte(got)) {
+			t.Errorf("Minify(%q) = %q, which is not valid JSON", tt.code, got)
+		}
+	}
+}
+
+func TestFormatJSONErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`{"a":1,}`, "invalid JSON syntax: 1:8: expected string for object key"},
+		{"{\"a\":\n  tru}", `invalid JSON syntax: 2:3: invalid literal "tru"`},
+		{"[1, 2", "invalid JSON syntax: 1:6: unexpected end of JSON input"},
+		{`{"a":1} {"b":2}`, `invalid JSON syntax: 1:9: invalid character "{" after top-level value`},
+		{`"\x"`, `invalid JSON syntax: 1:2: invalid escape sequence \x`},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		_, err := f.Format(tt.code, "json")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Format(%q) error = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+}
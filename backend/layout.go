@@ -0,0 +1,368 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// doc is a layout document in the style of Wadler's "prettier printer".
+// Printers for structured languages build a doc tree and render it with
+// renderDoc, which picks line breaks so output fits in the print width.
+//
+// A doc is one of: string (literal text), []doc (concatenation), *docGroup,
+// *docIndent, docLine, *docIfBreak, *docFill, *docLineSuffix, docBreakParent
+// or nil.
+type doc interface{}
+
+// docGroup is printed flat if it fits on the rest of the line, otherwise
+// its lines break
+type docGroup struct {
+	contents    doc
+	shouldBreak bool
+}
+
+// docIndent increases the indentation of lines broken inside it
+type docIndent struct {
+	contents doc
+}
+
+// docLine is a potential line break. A plain line prints as a space when
+// flat, a soft line prints as nothing, and a hard line always breaks.
+type docLine struct {
+	soft bool
+	hard bool
+}
+
+// docIfBreak selects contents depending on whether the enclosing group breaks
+type docIfBreak struct {
+	breakContents doc
+	flatContents  doc
+}
+
+// docFill packs content onto lines, breaking separators only when the next
+// item does not fit. Parts alternate between content and separator.
+type docFill struct {
+	parts []doc
+}
+
+// docLineSuffix is deferred until the next line break, used for trailing
+// comments
+type docLineSuffix struct {
+	contents doc
+}
+
+// docBreakParent prints nothing but forces every enclosing group to break,
+// used after line comments that must end their line
+type docBreakParent struct{}
+
+var (
+	breakParent = docBreakParent{}
+	spaceLine   = docLine{}
+	softLine    = docLine{soft: true}
+	hardLine    = docLine{hard: true}
+)
+
+func groupDoc(parts ...doc) *docGroup {
+	return &docGroup{contents: doc(parts)}
+}
+
+func indentDoc(parts ...doc) *docIndent {
+	return &docIndent{contents: doc(parts)}
+}
+
+func ifBreakDoc(breakContents, flatContents doc) *docIfBreak {
+	return &docIfBreak{breakContents: breakContents, flatContents: flatContents}
+}
+
+func lineSuffixDoc(contents doc) *docLineSuffix {
+	return &docLineSuffix{contents: contents}
+}
+
+// joinDocs places sep between each of docs
+func joinDocs(sep doc, docs []doc) []doc {
+	out := make([]doc, 0, 2*len(docs))
+	for i, d := range docs {
+		if i > 0 {
+			out = append(out, sep)
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+type docMode int
+
+const (
+	docModeBreak docMode = iota
+	docModeFlat
+)
+
+type docCommand struct {
+	indent int
+	mode   docMode
+	doc    doc
+}
+
+// docRenderer holds the output state while rendering a doc
+type docRenderer struct {
+	width      int
+	indentUnit string
+	unitWidth  int
+	out        []byte
+	column     int
+	suffixes   []docCommand
+}
+
+// renderDoc prints d, breaking groups that do not fit in width columns.
+// indentUnit is the text emitted once per indentation level.
+func renderDoc(d doc, width int, indentUnit string) string {
+	propagateBreaks(d)
+
+	r := &docRenderer{
+		width:      width,
+		indentUnit: indentUnit,
+		unitWidth:  textWidth(indentUnit, 0),
+	}
+	r.render(d)
+	return string(r.out)
+}
+
+// textWidth returns the display width of s starting at column, with tabs
+// advancing to the next multiple of four
+func textWidth(s string, column int) int {
+	start := column
+	for _, c := range s {
+		if c == '\t' {
+			column += 4 - column%4
+		} else {
+			column++
+		}
+	}
+	return column - start
+}
+
+func (r *docRenderer) render(d doc) {
+	stack := []docCommand{{indent: 0, mode: docModeBreak, doc: d}}
+
+	for len(stack) > 0 {
+		cmd := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		switch x := cmd.doc.(type) {
+		case nil:
+		case string:
+			r.out = append(r.out, x...)
+			if i := strings.LastIndexByte(x, '\n'); i >= 0 {
+				r.column = textWidth(x[i+1:], 0)
+			} else {
+				r.column += textWidth(x, r.column)
+			}
+		case []doc:
+			for i := len(x) - 1; i >= 0; i-- {
+				stack = append(stack, docCommand{cmd.indent, cmd.mode, x[i]})
+			}
+		case *docIndent:
+			stack = append(stack, docCommand{cmd.indent + 1, cmd.mode, x.contents})
+		case *docGroup:
+			mode := docModeBreak
+			if !x.shouldBreak {
+				next := docCommand{cmd.indent, docModeFlat, x.contents}
+				if cmd.mode == docModeFlat || r.fits(next, stack, r.width-r.column, false) {
+					mode = docModeFlat
+				}
+			}
+			stack = append(stack, docCommand{cmd.indent, mode, x.contents})
+		case *docIfBreak:
+			if cmd.mode == docModeBreak {
+				stack = append(stack, docCommand{cmd.indent, cmd.mode, x.breakContents})
+			} else {
+				stack = append(stack, docCommand{cmd.indent, cmd.mode, x.flatContents})
+			}
+		case *docLineSuffix:
+			r.suffixes = append(r.suffixes, docCommand{cmd.indent, cmd.mode, x.contents})
+		case *docFill:
+			stack = r.renderFill(cmd, x, stack)
+		case docLine:
+			if cmd.mode == docModeFlat && !x.hard {
+				if !x.soft {
+					r.out = append(r.out, ' ')
+					r.column++
+				}
+				continue
+			}
+			if len(r.suffixes) > 0 {
+				// Flush trailing comments before breaking the line
+				stack = append(stack, cmd)
+				for i := len(r.suffixes) - 1; i >= 0; i-- {
+					stack = append(stack, r.suffixes[i])
+				}
+				r.suffixes = nil
+				continue
+			}
+			r.newline(cmd.indent)
+		}
+
+		if len(stack) == 0 && len(r.suffixes) > 0 {
+			for i := len(r.suffixes) - 1; i >= 0; i-- {
+				stack = append(stack, r.suffixes[i])
+			}
+			r.suffixes = nil
+		}
+	}
+}
+
+func (r *docRenderer) newline(level int) {
+	// Trailing whitespace is never significant in generated layout
+	n := len(r.out)
+	for n > 0 && (r.out[n-1] == ' ' || r.out[n-1] == '\t') {
+		n--
+	}
+	r.out = append(r.out[:n], '\n')
+	for i := 0; i < level; i++ {
+		r.out = append(r.out, r.indentUnit...)
+	}
+	r.column = level * r.unitWidth
+}
+
+// renderFill expands a fill onto the stack: each separator breaks only when
+// the content after it would not fit on the current line
+func (r *docRenderer) renderFill(cmd docCommand, fill *docFill, stack []docCommand) []docCommand {
+	parts := fill.parts
+	if len(parts) == 0 {
+		return stack
+	}
+
+	content := docCommand{cmd.indent, docModeFlat, parts[0]}
+	contentFits := r.fits(content, nil, r.width-r.column, true)
+	if len(parts) == 1 {
+		if !contentFits {
+			content.mode = docModeBreak
+		}
+		return append(stack, content)
+	}
+
+	sep := parts[1]
+	rest := docCommand{cmd.indent, cmd.mode, &docFill{parts: parts[2:]}}
+	if !contentFits {
+		content.mode = docModeBreak
+	}
+	sepMode := docModeBreak
+	if contentFits && len(parts) > 2 {
+		pair := docCommand{cmd.indent, docModeFlat, []doc{parts[0], sep, parts[2]}}
+		if r.fits(pair, nil, r.width-r.column, true) {
+			sepMode = docModeFlat
+		}
+	}
+
+	stack = append(stack, rest)
+	stack = append(stack, docCommand{cmd.indent, sepMode, sep})
+	stack = append(stack, content)
+	return stack
+}
+
+// fits reports whether next, followed by the rest of the current line from
+// the stack, can be printed flat within width columns
+func (r *docRenderer) fits(next docCommand, rest []docCommand, width int, mustBeFlat bool) bool {
+	queue := []docCommand{next}
+	restIndex := len(rest)
+
+	for width >= 0 {
+		if len(queue) == 0 {
+			if restIndex == 0 {
+				return true
+			}
+			restIndex--
+			queue = append(queue, rest[restIndex])
+			continue
+		}
+
+		cmd := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+
+		switch x := cmd.doc.(type) {
+		case nil:
+		case string:
+			// Multi-line text such as a template literal only has to fit
+			// up to its first line break
+			if i := strings.IndexByte(x, '\n'); i >= 0 {
+				return width-utf8.RuneCountInString(x[:i]) >= 0
+			}
+			width -= utf8.RuneCountInString(x)
+		case []doc:
+			for i := len(x) - 1; i >= 0; i-- {
+				queue = append(queue, docCommand{cmd.indent, cmd.mode, x[i]})
+			}
+		case *docIndent:
+			queue = append(queue, docCommand{cmd.indent + 1, cmd.mode, x.contents})
+		case *docGroup:
+			if mustBeFlat && x.shouldBreak {
+				return false
+			}
+			mode := cmd.mode
+			if x.shouldBreak {
+				mode = docModeBreak
+			}
+			queue = append(queue, docCommand{cmd.indent, mode, x.contents})
+		case *docIfBreak:
+			if cmd.mode == docModeBreak {
+				queue = append(queue, docCommand{cmd.indent, cmd.mode, x.breakContents})
+			} else {
+				queue = append(queue, docCommand{cmd.indent, cmd.mode, x.flatContents})
+			}
+		case *docFill:
+			for i := len(x.parts) - 1; i >= 0; i-- {
+				queue = append(queue, docCommand{cmd.indent, cmd.mode, x.parts[i]})
+			}
+		case *docLineSuffix:
+		case docLine:
+			if cmd.mode == docModeBreak || x.hard {
+				return true
+			}
+			if !x.soft {
+				width--
+			}
+		}
+	}
+	return false
+}
+
+// propagateBreaks marks every group containing a hard line as broken and
+// reports whether d contains one
+func propagateBreaks(d doc) bool {
+	switch x := d.(type) {
+	case []doc:
+		found := false
+		for _, part := range x {
+			if propagateBreaks(part) {
+				found = true
+			}
+		}
+		return found
+	case *docGroup:
+		if propagateBreaks(x.contents) {
+			x.shouldBreak = true
+		}
+		return x.shouldBreak
+	case *docIndent:
+		return propagateBreaks(x.contents)
+	case *docIfBreak:
+		b := propagateBreaks(x.breakContents)
+		f := propagateBreaks(x.flatContents)
+		return b || f
+	case *docFill:
+		found := false
+		for _, part := range x.parts {
+			if propagateBreaks(part) {
+				found = true
+			}
+		}
+		return found
+	case *docLineSuffix:
+		return propagateBreaks(x.contents)
+	case docBreakParent:
+		return true
+	case docLine:
+		return x.hard
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// Literal finders read the output of a backend, which lexes cleanly. Should
+// one fail anyway it reports no literals, and the output options apply to
+// the whole output.
+
+// goLiterals returns the raw strings of Go code
+func goLiterals(code string, _ *FormatOptions) []textSpan {
+	src := []byte(code)
+	fset := token.NewFileSet()
+	var s scanner.Scanner
+	s.Init(fset.AddFile("", -1, len(src)), src, nil, 0)
+
+	var spans []textSpan
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return spans
+		}
+		if tok == token.STRING && strings.HasPrefix(lit, "`") && strings.Contains(lit, "\n") {
+			start := fset.Position(pos).Offset
+			spans = append(spans, textSpan{start, start + len(lit)})
+		}
+	}
+}
+
+func jsLiterals(code string, _ *FormatOptions) []textSpan {
+	return jsDialectLiterals(code, jsDialect{jsx: true})
+}
+
+func tsLiterals(code string, _ *FormatOptions) []textSpan {
+	return jsDialectLiterals(code, jsDialect{typescript: true})
+}
+
+func tsxLiterals(code string, _ *FormatOptions) []textSpan {
+	return jsDialectLiterals(code, jsDialect{typescript: true, jsx: true})
+}
+
+// jsDialectLiterals returns the template literals of a program and the
+// strings continued over lines
+func jsDialectLiterals(code string, dialect jsDialect) []textSpan {
+	tokens, err := jsCodeTokens(code, dialect)
+	if err != nil {
+		return nil
+	}
+	sort.SliceStable(tokens, func(i, j int) bool { return tokens[i].start < tokens[j].start })
+	var spans []textSpan
+	for _, t := range tokens {
+		switch t.kind {
+		case jsString, jsTemplate, jsTemplateHead, jsTemplateMiddle, jsTemplateTail:
+			spans = appendLiteral(spans, code, t.start, t.end)
+		}
+	}
+	return spans
+}
+
+// phpLiterals returns the strings and heredocs of PHP code
+func phpLiterals(code string, _ *FormatOptions) []textSpan {
+	tokens, err := tokenizePHP(code)
+	if err != nil {
+		return nil
+	}
+	var spans []textSpan
+	for _, t := range tokens {
+		if t.kind == phpString || t.kind == phpHeredoc {
+			spans = appendLiteral(spans, code, t.start, t.end)
+		}
+	}
+	return spans
+}
+
+// sqlLiterals returns the strings of SQL code
+func sqlLiterals(code string, opts *FormatOptions) []textSpan {
+	tokens, err := tokenizeSQL(code, newSQLDialect(opts.dialect()))
+	if err != nil {
+		return nil
+	}
+	var spans []textSpan
+	for _, t := range tokens {
+		if t.kind == sqlString || t.kind == sqlDollarString {
+			spans = appendLiteral(spans, code, t.start, t.end)
+		}
+	}
+	return spans
+}
+
+// yamlLiterals returns the content of the block scalars of a YAML stream,
+// including the line breaks their chomping keeps
+func yamlLiterals(code string, _ *FormatOptions) []textSpan {
+	if strings.Contains(code, "\r") {
+		// Offsets would count \r\n as one byte
+		return nil
+	}
+	p := &yamlParser{src: code}
+	if _, err := p.stream(); err != nil {
+		return nil
+	}
+	return p.literals
+}
+
+// appendLiteral adds the literal at [start, end) to spans when it holds a
+// line break, skipping any that overlaps the one before, as tokens of
+// readings the parser backtracked from may
+func appendLiteral(spans []textSpan, code string, start, end int) []textSpan {
+	if !strings.ContainsAny(code[start:end], "\r\n") {
+		return spans
+	}
+	if len(spans) > 0 && start < spans[len(spans)-1].end {
+		return spans
+	}
+	return append(spans, textSpan{start, end})
+}
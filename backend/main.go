@@ -11,14 +11,47 @@ import (
 )
 
 func main() {
+	// Subcommands run the formatter from the command line without a server
+	if len(os.Args) > 1 && cliCommands[os.Args[1]] {
+		os.Exit(runCLI(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
 	// Load configuration
 	config := LoadConfig()
 
 	// Create rate limiter with config
 	rateLimiter := NewRateLimiter(config.RateLimit.RequestsPerMinute, time.Duration(config.RateLimit.WindowSeconds)*time.Second)
 
+	policy, err := NewPolicy(config.Policy.Actions)
+	if err != nil {
+		log.Fatalf("Invalid POLICY_RULES: %v", err)
+	}
+
 	// Create handlers with config
-	handlers := &Handlers{config: config}
+	handlers := &Handlers{config: config, policy: policy}
+	if config.Security.EnableSecretScan {
+		var extra []SecretPattern
+		if config.Secrets.PatternsFile != "" {
+			if extra, err = LoadSecretPatterns(config.Secrets.PatternsFile); err != nil {
+				log.Fatalf("Invalid SECRET_PATTERNS_FILE: %v", err)
+			}
+		}
+		if handlers.secrets, err = NewSecretScanner(config.Secrets.Patterns, extra); err != nil {
+			log.Fatalf("Invalid secret patterns: %v", err)
+		}
+	}
+
+	store, err := NewSnippetStore(config.Snippets)
+	if err != nil {
+		log.Fatalf("Invalid snippet store: %v", err)
+	}
+	if store != nil {
+		defer store.Close()
+		handlers.snippets = store
+		if config.Snippets.CleanupInterval > 0 {
+			go purgeExpiredSnippets(store, config.Snippets.CleanupInterval)
+		}
+	}
 
 	// Create a new HTTP mux
 	mux := http.NewServeMux()
@@ -26,7 +59,15 @@ func main() {
 	// Register routes with API versioning
 	mux.HandleFunc("/api/v1/format", handlers.FormatHandler)
 	mux.HandleFunc("/api/v1/minify", handlers.MinifyHandler)
+	mux.HandleFunc("/api/v1/check", handlers.CheckHandler)
+	mux.HandleFunc("/api/v1/convert", handlers.ConvertHandler)
+	mux.HandleFunc("/api/v1/types", handlers.TypesHandler)
+	mux.HandleFunc("/api/v1/batch", handlers.BatchHandler)
 	mux.HandleFunc("/api/v1/health", handlers.HealthHandler)
+	if store != nil {
+		mux.HandleFunc("/api/v1/snippets", handlers.SnippetsHandler)
+		mux.HandleFunc(snippetPath, handlers.SnippetHandler)
+	}
 
 	// Apply middleware based on configuration
 	var handler http.Handler = mux
@@ -2,15 +2,99 @@ package main
 
 // Request represents the incoming format/minify request
 type Request struct {
-	Code     string `json:"code" validate:"required"`
-	Language string `json:"language" validate:"required"`
+	Code     string         `json:"code" validate:"required"`
+	Language string         `json:"language,omitempty"`
+	Filename string         `json:"filename,omitempty"`
+	Mode     string         `json:"mode,omitempty"`
+	Options  *FormatOptions `json:"options,omitempty"`
+	// From and To name the languages of a conversion. From defaults to
+	// Language.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// RedactSecrets replaces the credentials found in the code with
+	// placeholders before it is processed
+	RedactSecrets bool `json:"redactSecrets,omitempty"`
 }
 
 // Response represents the API response
 type Response struct {
-	Success   bool   `json:"success"`
-	Code      string `json:"code,omitempty"`
-	Output    string `json:"output,omitempty"`
-	Error     string `json:"error,omitempty"`
-	Timestamp string `json:"timestamp"`
+	Success        bool            `json:"success"`
+	Code           string          `json:"code,omitempty"`
+	SourceMap      string          `json:"sourceMap,omitempty"`
+	Output         string          `json:"output,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	Diagnostics    []Diagnostic    `json:"diagnostics,omitempty"`
+	Language       string          `json:"language,omitempty"`
+	Confidence     float64         `json:"confidence,omitempty"`
+	IgnoredOptions []string        `json:"ignoredOptions,omitempty"`
+	Warnings       []string        `json:"warnings,omitempty"`
+	PolicyMatches  []PolicyMatch   `json:"policyMatches,omitempty"`
+	Secrets        []SecretFinding `json:"secrets,omitempty"`
+	Formatted      *bool           `json:"formatted,omitempty"`
+	Diff           string          `json:"diff,omitempty"`
+	Changes        []LineRange     `json:"changes,omitempty"`
+	Timestamp      string          `json:"timestamp,omitempty"`
+}
+
+// BatchItem is one snippet of a batch request
+type BatchItem struct {
+	ID        string         `json:"id"`
+	Code      string         `json:"code"`
+	Language  string         `json:"language,omitempty"`
+	Filename  string         `json:"filename,omitempty"`
+	Operation string         `json:"operation"`
+	Mode      string         `json:"mode,omitempty"`
+	Options   *FormatOptions `json:"options,omitempty"`
+	From      string         `json:"from,omitempty"`
+	To        string         `json:"to,omitempty"`
+	// RedactSecrets is as in a single snippet request
+	RedactSecrets bool `json:"redactSecrets,omitempty"`
+}
+
+// BatchRequest is the body of a batch request. A bare array of items is
+// accepted as well.
+type BatchRequest struct {
+	Items []BatchItem `json:"items"`
+}
+
+// BatchResult is the outcome of one batch item, in the shape of a single
+// snippet response
+type BatchResult struct {
+	ID string `json:"id"`
+	Response
+}
+
+// BatchResponse represents the API response to a batch request
+type BatchResponse struct {
+	Success   bool          `json:"success"`
+	Results   []BatchResult `json:"results"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Timestamp string        `json:"timestamp"`
+}
+
+// SnippetRequest is the body of a request to save a snippet. Its code is
+// processed as by the format or minify endpoint and saved with the output.
+type SnippetRequest struct {
+	Request
+	// Operation is format, the default, or minify
+	Operation string `json:"operation,omitempty"`
+	// ExpiresIn is the number of seconds the snippet is kept for, or 0 to
+	// keep it until it is deleted
+	ExpiresIn int64 `json:"expiresIn,omitempty"`
+	// BurnAfterRead deletes the snippet the first time it is read
+	BurnAfterRead bool `json:"burnAfterRead,omitempty"`
+}
+
+// SnippetResponse represents the API response to the snippet endpoints
+type SnippetResponse struct {
+	Success bool     `json:"success"`
+	Snippet *Snippet `json:"snippet,omitempty"`
+	// URL is the short link to the snippet, when a base URL is configured
+	URL string `json:"url,omitempty"`
+	// DeleteToken is returned only when the snippet is saved
+	DeleteToken   string          `json:"deleteToken,omitempty"`
+	PolicyMatches []PolicyMatch   `json:"policyMatches,omitempty"`
+	Secrets       []SecretFinding `json:"secrets,omitempty"`
+	Timestamp     string          `json:"timestamp"`
 }
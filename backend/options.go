@@ -0,0 +1,475 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Option names as they appear in the request's options object
+const (
+	optionIndentSize         = "indentSize"
+	optionUseTabs            = "useTabs"
+	optionPrintWidth         = "printWidth"
+	optionTrailingNewline    = "trailingNewline"
+	optionLineEnding         = "lineEnding"
+	optionQuoteStyle         = "quoteStyle"
+	optionSortDeclarations   = "sortDeclarations"
+	optionCollapseWhitespace = "collapseWhitespace"
+	optionRemoveComments     = "removeComments"
+	optionRemoveOptionalTags = "removeOptionalTags"
+	optionDialect            = "dialect"
+	optionAttributePrefix    = "attributePrefix"
+	optionTextKey            = "textKey"
+	optionHeader             = "header"
+	optionTypeName           = "typeName"
+	optionSourceMap          = "sourceMap"
+	optionSourceFile         = "sourceFile"
+	optionCompress           = "compress"
+	optionReserved           = "reserved"
+	optionJoinStatements     = "joinStatements"
+	optionOrganizeImports    = "organizeImports"
+	optionStrict             = "strict"
+	optionModernize          = "modernize"
+)
+
+// Accepted values for the lineEnding and quoteStyle options
+const (
+	lineEndingLF   = "lf"
+	lineEndingCRLF = "crlf"
+
+	quoteSingle   = "single"
+	quoteDouble   = "double"
+	quotePreserve = "preserve"
+)
+
+// Bounds for the numeric options
+const (
+	maxIndentSize = 16
+	minPrintWidth = 20
+	maxPrintWidth = 1000
+)
+
+// FormatOptions tunes the layout of the output. Every field is optional:
+// an unset field keeps the backend's own default, so a nil *FormatOptions
+// behaves like an empty one.
+type FormatOptions struct {
+	IndentSize         *int     `json:"indentSize,omitempty"`
+	UseTabs            *bool    `json:"useTabs,omitempty"`
+	PrintWidth         *int     `json:"printWidth,omitempty"`
+	TrailingNewline    *bool    `json:"trailingNewline,omitempty"`
+	LineEnding         string   `json:"lineEnding,omitempty"`
+	QuoteStyle         string   `json:"quoteStyle,omitempty"`
+	SortDeclarations   *bool    `json:"sortDeclarations,omitempty"`
+	CollapseWhitespace *bool    `json:"collapseWhitespace,omitempty"`
+	RemoveComments     *bool    `json:"removeComments,omitempty"`
+	RemoveOptionalTags *bool    `json:"removeOptionalTags,omitempty"`
+	Dialect            string   `json:"dialect,omitempty"`
+	AttributePrefix    string   `json:"attributePrefix,omitempty"`
+	TextKey            string   `json:"textKey,omitempty"`
+	Header             *bool    `json:"header,omitempty"`
+	TypeName           string   `json:"typeName,omitempty"`
+	SourceMap          string   `json:"sourceMap,omitempty"`
+	SourceFile         string   `json:"sourceFile,omitempty"`
+	Compress           *int     `json:"compress,omitempty"`
+	Reserved           []string `json:"reserved,omitempty"`
+	JoinStatements     *bool    `json:"joinStatements,omitempty"`
+	OrganizeImports    *bool    `json:"organizeImports,omitempty"`
+	Strict             *bool    `json:"strict,omitempty"`
+	Modernize          *bool    `json:"modernize,omitempty"`
+}
+
+// Validate checks that every option that is set has an acceptable value
+func (o *FormatOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.IndentSize != nil && (*o.IndentSize < 1 || *o.IndentSize > maxIndentSize) {
+		return fmt.Errorf("%s must be between 1 and %d", optionIndentSize, maxIndentSize)
+	}
+	if o.PrintWidth != nil && (*o.PrintWidth < minPrintWidth || *o.PrintWidth > maxPrintWidth) {
+		return fmt.Errorf("%s must be between %d and %d", optionPrintWidth, minPrintWidth, maxPrintWidth)
+	}
+	switch strings.ToLower(o.LineEnding) {
+	case "", lineEndingLF, lineEndingCRLF:
+	default:
+		return fmt.Errorf("%s must be %q or %q", optionLineEnding, lineEndingLF, lineEndingCRLF)
+	}
+	switch strings.ToLower(o.QuoteStyle) {
+	case "", quoteSingle, quoteDouble, quotePreserve:
+	default:
+		return fmt.Errorf("%s must be %q, %q or %q", optionQuoteStyle, quoteSingle, quoteDouble, quotePreserve)
+	}
+	switch strings.ToLower(o.Dialect) {
+	case "", dialectPostgreSQL, dialectMySQL, dialectSQLite, dialectTSQL:
+	default:
+		return fmt.Errorf("%s must be %q, %q, %q or %q", optionDialect, dialectPostgreSQL, dialectMySQL, dialectSQLite, dialectTSQL)
+	}
+	if strings.HasPrefix(o.textKey(), o.attributePrefix()) {
+		return fmt.Errorf("%s %q must not start with %s %q", optionTextKey, o.textKey(), optionAttributePrefix, o.attributePrefix())
+	}
+	if o.TypeName != "" && !typeNamePattern.MatchString(o.TypeName) {
+		return fmt.Errorf("%s must be a letter followed by letters, digits or underscores", optionTypeName)
+	}
+	switch strings.ToLower(o.SourceMap) {
+	case "", sourceMapInline, sourceMapSeparate:
+	default:
+		return fmt.Errorf("%s must be %q or %q", optionSourceMap, sourceMapInline, sourceMapSeparate)
+	}
+	if o.Compress != nil && (*o.Compress < 0 || *o.Compress > maxCompress) {
+		return fmt.Errorf("%s must be between 0 and %d", optionCompress, maxCompress)
+	}
+	for _, name := range o.Reserved {
+		if !jsIdentifierPattern.MatchString(name) {
+			return fmt.Errorf("%s entry %q is not an identifier", optionReserved, name)
+		}
+	}
+	return nil
+}
+
+// names returns the names of the options that are set, in declaration order
+func (o *FormatOptions) names() []string {
+	if o == nil {
+		return nil
+	}
+	var names []string
+	if o.IndentSize != nil {
+		names = append(names, optionIndentSize)
+	}
+	if o.UseTabs != nil {
+		names = append(names, optionUseTabs)
+	}
+	if o.PrintWidth != nil {
+		names = append(names, optionPrintWidth)
+	}
+	if o.TrailingNewline != nil {
+		names = append(names, optionTrailingNewline)
+	}
+	if o.LineEnding != "" {
+		names = append(names, optionLineEnding)
+	}
+	if o.QuoteStyle != "" {
+		names = append(names, optionQuoteStyle)
+	}
+	if o.SortDeclarations != nil {
+		names = append(names, optionSortDeclarations)
+	}
+	if o.CollapseWhitespace != nil {
+		names = append(names, optionCollapseWhitespace)
+	}
+	if o.RemoveComments != nil {
+		names = append(names, optionRemoveComments)
+	}
+	if o.RemoveOptionalTags != nil {
+		names = append(names, optionRemoveOptionalTags)
+	}
+	if o.Dialect != "" {
+		names = append(names, optionDialect)
+	}
+	if o.AttributePrefix != "" {
+		names = append(names, optionAttributePrefix)
+	}
+	if o.TextKey != "" {
+		names = append(names, optionTextKey)
+	}
+	if o.Header != nil {
+		names = append(names, optionHeader)
+	}
+	if o.TypeName != "" {
+		names = append(names, optionTypeName)
+	}
+	if o.SourceMap != "" {
+		names = append(names, optionSourceMap)
+	}
+	if o.SourceFile != "" {
+		names = append(names, optionSourceFile)
+	}
+	if o.Compress != nil {
+		names = append(names, optionCompress)
+	}
+	if o.Reserved != nil {
+		names = append(names, optionReserved)
+	}
+	if o.JoinStatements != nil {
+		names = append(names, optionJoinStatements)
+	}
+	if o.OrganizeImports != nil {
+		names = append(names, optionOrganizeImports)
+	}
+	if o.Strict != nil {
+		names = append(names, optionStrict)
+	}
+	if o.Modernize != nil {
+		names = append(names, optionModernize)
+	}
+	return names
+}
+
+// indent returns the text for one indentation level, falling back to
+// size spaces when neither indentSize nor useTabs is set
+func (o *FormatOptions) indent(size int) string {
+	if o != nil && o.UseTabs != nil && *o.UseTabs {
+		return "\t"
+	}
+	if o != nil && o.IndentSize != nil {
+		size = *o.IndentSize
+	}
+	return strings.Repeat(" ", size)
+}
+
+// width returns the maximum line width, or def when it is not set
+func (o *FormatOptions) width(def int) int {
+	if o != nil && o.PrintWidth != nil {
+		return *o.PrintWidth
+	}
+	return def
+}
+
+// quote returns the preferred string delimiter, or 0 to keep the quotes
+// found in the source
+func (o *FormatOptions) quote() byte {
+	if o == nil {
+		return 0
+	}
+	switch strings.ToLower(o.QuoteStyle) {
+	case quoteSingle:
+		return '\''
+	case quoteDouble:
+		return '"'
+	}
+	return 0
+}
+
+// sortDeclarations reports whether style sheet declarations should be
+// sorted by property name rather than kept in source order
+func (o *FormatOptions) sortDeclarations() bool {
+	return o != nil && boolOption(o.SortDeclarations, false)
+}
+
+// collapseWhitespace reports whether minified markup should render each
+// run of whitespace as at most one space. It is on by default.
+func (o *FormatOptions) collapseWhitespace() bool {
+	return o == nil || boolOption(o.CollapseWhitespace, true)
+}
+
+// removeComments reports whether minified markup should drop comments. It
+// is on by default.
+func (o *FormatOptions) removeComments() bool {
+	return o == nil || boolOption(o.RemoveComments, true)
+}
+
+// removeOptionalTags reports whether minified markup should leave out the
+// end tags HTML lets a parser infer
+func (o *FormatOptions) removeOptionalTags() bool {
+	return o != nil && boolOption(o.RemoveOptionalTags, false)
+}
+
+// dialect returns the lowercased SQL dialect, or "" for standard SQL
+func (o *FormatOptions) dialect() string {
+	if o == nil {
+		return ""
+	}
+	return strings.ToLower(o.Dialect)
+}
+
+// Default keys XML attributes and text are read into
+const (
+	defaultAttributePrefix = "@"
+	defaultTextKey         = "#text"
+)
+
+// attributePrefix returns the prefix that marks XML attributes among the
+// keys of converted data
+func (o *FormatOptions) attributePrefix() string {
+	if o == nil || o.AttributePrefix == "" {
+		return defaultAttributePrefix
+	}
+	return o.AttributePrefix
+}
+
+// textKey returns the key holding the text of XML elements that also have
+// attributes or children
+func (o *FormatOptions) textKey() string {
+	if o == nil || o.TextKey == "" {
+		return defaultTextKey
+	}
+	return o.TextKey
+}
+
+// header returns whether the first CSV row names the columns, and whether
+// that was set rather than left to be inferred
+func (o *FormatOptions) header() (header, set bool) {
+	if o == nil || o.Header == nil {
+		return false, false
+	}
+	return *o.Header, true
+}
+
+// defaultTypeName names the type describing a whole document
+const defaultTypeName = "Root"
+
+var typeNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// typeName returns the name of the type generated for a whole document
+func (o *FormatOptions) typeName() string {
+	if o == nil || o.TypeName == "" {
+		return defaultTypeName
+	}
+	return o.TypeName
+}
+
+// sourceMap returns the lowercased way a minifier should emit a source
+// map, or "" for none
+func (o *FormatOptions) sourceMap() string {
+	if o == nil {
+		return ""
+	}
+	return strings.ToLower(o.SourceMap)
+}
+
+// sourceFile returns the name a source map gives the minified source, or
+// def when it is not set
+func (o *FormatOptions) sourceFile(def string) string {
+	if o == nil || o.SourceFile == "" {
+		return def
+	}
+	return o.SourceFile
+}
+
+var jsIdentifierPattern = regexp.MustCompile(`^[\p{L}\p{Nl}$_][\p{L}\p{Nl}\p{Mn}\p{Mc}\p{Nd}\p{Pc}$_\x{200c}\x{200d}]*$`)
+
+// compress returns how far a JavaScript minifier should go beyond
+// dropping whitespace
+func (o *FormatOptions) compress() int {
+	if o == nil || o.Compress == nil {
+		return compressWhitespace
+	}
+	return *o.Compress
+}
+
+// reserved returns the names a JavaScript minifier must not rename
+func (o *FormatOptions) reserved() []string {
+	if o == nil {
+		return nil
+	}
+	return o.Reserved
+}
+
+// joinStatements reports whether minified Go should separate statements
+// with semicolons rather than line breaks
+func (o *FormatOptions) joinStatements() bool {
+	return o != nil && boolOption(o.JoinStatements, false)
+}
+
+// organizeImports reports whether formatted Go should have its imports
+// grouped, sorted and fixed
+func (o *FormatOptions) organizeImports() bool {
+	return o != nil && boolOption(o.OrganizeImports, false)
+}
+
+// strict reports whether formatted Go should follow the stricter rules
+// of gofumpt
+func (o *FormatOptions) strict() bool {
+	return o != nil && boolOption(o.Strict, false)
+}
+
+// modernize reports whether formatted Go should have outdated constructs
+// rewritten
+func (o *FormatOptions) modernize() bool {
+	return o != nil && boolOption(o.Modernize, false)
+}
+
+// boolOption returns the value of a boolean option, or def when it is not
+// set
+func boolOption(value *bool, def bool) bool {
+	if value == nil {
+		return def
+	}
+	return *value
+}
+
+// textSpan is the byte range [start, end) of a text
+type textSpan struct {
+	start, end int
+}
+
+// applyOutputOptions applies the options every backend supports by
+// post-processing its output: the final newline and the line ending style.
+// Neither changes the line breaks inside literals, which are part of the
+// values the code holds.
+func applyOutputOptions(code string, opts *FormatOptions, literals []textSpan) string {
+	if opts == nil {
+		return code
+	}
+
+	if opts.TrailingNewline != nil {
+		// The line breaks ending the last literal, such as the blank lines
+		// of a kept YAML block scalar, are not trailing
+		keep := 0
+		if len(literals) > 0 {
+			keep = literals[len(literals)-1].end
+		}
+		end := len(code)
+		for end > keep && (code[end-1] == '\n' || code[end-1] == '\r') {
+			end--
+		}
+		code = code[:end]
+		if *opts.TrailingNewline && code != "" && !strings.HasSuffix(code, "\n") {
+			code += "\n"
+		}
+	}
+
+	switch strings.ToLower(opts.LineEnding) {
+	case lineEndingLF:
+		code = outsideSpans(code, literals, func(s string) string {
+			return strings.ReplaceAll(s, "\r\n", "\n")
+		})
+	case lineEndingCRLF:
+		code = outsideSpans(code, literals, func(s string) string {
+			return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+		})
+	}
+	return code
+}
+
+// outsideSpans applies replace to the text between the spans, which are in
+// order, and keeps the spans as they are
+func outsideSpans(code string, spans []textSpan, replace func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, span := range spans {
+		if span.start < last || span.end > len(code) {
+			continue
+		}
+		b.WriteString(replace(code[last:span.start]))
+		b.WriteString(code[span.start:span.end])
+		last = span.end
+	}
+	b.WriteString(replace(code[last:]))
+	return b.String()
+}
+
+// ignoredOptions returns the options set in opts that neither the backend
+// operation nor the common output handling honors
+func ignoredOptions(opts *FormatOptions, supported []string) []string {
+	var ignored []string
+	for _, name := range opts.names() {
+		switch name {
+		case optionTrailingNewline, optionLineEnding:
+			continue
+		}
+		if !containsString(supported, name) {
+			ignored = append(ignored, name)
+		}
+	}
+	return ignored
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOutputOptions(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name     string
+		language string
+		code     string
+		opts     *FormatOptions
+		want     string
+	}{
+		{"trailing newline added", "json", `{"a":1}`, &FormatOptions{TrailingNewline: &yes}, "{\n  \"a\": 1\n}\n"},
+		{"trailing newline stripped", "json", `{"a":1}`, &FormatOptions{TrailingNewline: &no}, "{\n  \"a\": 1\n}"},
+		{"crlf", "yaml", "a:\n  b: 1\n", &FormatOptions{LineEnding: "crlf"}, "a:\r\n  b: 1\r\n"},
+		{"kept block scalar", "yaml", "keep: |+\n  text\n\n\n", &FormatOptions{TrailingNewline: &yes}, "keep: |+\n  text\n\n\n"},
+		{"kept block scalar without newline", "yaml", "keep: |+\n  text\n\n", &FormatOptions{TrailingNewline: &no}, "keep: |+\n  text\n\n"},
+		{"clipped block scalar", "yaml", "clip: |\n  text\n\n\n", &FormatOptions{TrailingNewline: &yes}, "clip: |\n  text\n"},
+		{"block scalar in crlf", "yaml", "a: |\n  x\n  y\nb: 1\n", &FormatOptions{LineEnding: "crlf"}, "a: |\r\n  x\n  y\nb: 1\r\n"},
+		{"template literal in crlf", "javascript", "const s = `a\nb`\n", &FormatOptions{LineEnding: "crlf"}, "const s = `a\nb`;\r\n"},
+		{"heredoc in crlf", "php", "<?php\n$s = <<<EOT\na\nb\nEOT;\n", &FormatOptions{LineEnding: "crlf"}, "<?php\r\n$s = <<<EOT\na\nb\nEOT;\r\n"},
+		{"sql string in crlf", "sql", "select 'a\nb' from t", &FormatOptions{LineEnding: "crlf"}, "SELECT 'a\nb'\r\nFROM t\r\n"},
+		{"go raw string in crlf", "go", "package p\n\nvar s = `a\nb`\n", &FormatOptions{LineEnding: "crlf"}, "package p\r\n\r\nvar s = `a\nb`\r\n"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := f.FormatWithOptions(tt.code, tt.language, tt.opts)
+			if err != nil {
+				t.Fatalf("FormatWithOptions(%q) error: %v", tt.code, err)
+			}
+			if result.Code != tt.want {
+				t.Errorf("FormatWithOptions(%q) = %q, want %q", tt.code, result.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayoutOptions(t *testing.T) {
+	four, width := 4, 40
+	yes := true
+	tests := []struct {
+		name        string
+		language    string
+		code        string
+		opts        *FormatOptions
+		want        string
+		wantIgnored []string
+	}{
+		{"indent size", "json", `{"a":[1]}`, &FormatOptions{IndentSize: &four}, "{\n    \"a\": [\n        1\n    ]\n}\n", nil},
+		{"tabs", "javascript", "if(a){b()}", &FormatOptions{UseTabs: &yes}, "if (a) {\n\tb();\n}\n", nil},
+		{"double quotes", "javascript", "let s = 'a\"b' + 'c'", &FormatOptions{QuoteStyle: quoteDouble}, "let s = 'a\"b' + \"c\";\n", nil},
+		{"single quotes", "typescript", `let s: string = "c"`, &FormatOptions{QuoteStyle: quoteSingle}, "let s: string = 'c';\n", nil},
+		{
+			"print width", "javascript", "const total = first + second + third + fourth",
+			&FormatOptions{PrintWidth: &width}, "const total =\n  first + second + third + fourth;\n", nil,
+		},
+		{"ignored option", "json", `{"a":1}`, &FormatOptions{QuoteStyle: quoteSingle}, "{\n  \"a\": 1\n}\n", []string{optionQuoteStyle}},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := f.FormatWithOptions(tt.code, tt.language, tt.opts)
+			if err != nil {
+				t.Fatalf("FormatWithOptions(%q) error: %v", tt.code, err)
+			}
+			if result.Code != tt.want {
+				t.Errorf("FormatWithOptions(%q) = %q, want %q", tt.code, result.Code, tt.want)
+			}
+			if !reflect.DeepEqual(result.IgnoredOptions, tt.wantIgnored) {
+				t.Errorf("FormatWithOptions(%q) ignored %q, want %q", tt.code, result.IgnoredOptions, tt.wantIgnored)
+			}
+		})
+	}
+}
+
+func TestValidateOptions(t *testing.T) {
+	zero, wide, negative := 0, 5000, -1
+	tests := []struct {
+		opts *FormatOptions
+		want string
+	}{
+		{&FormatOptions{IndentSize: &zero}, "indentSize must be between 1 and 16"},
+		{&FormatOptions{PrintWidth: &wide}, "printWidth must be between 20 and 1000"},
+		{&FormatOptions{LineEnding: "cr"}, `lineEnding must be "lf" or "crlf"`},
+		{&FormatOptions{QuoteStyle: "back"}, `quoteStyle must be "single", "double" or "preserve"`},
+		{&FormatOptions{Compress: &negative}, "compress must be between 0 and 2"},
+		{&FormatOptions{Reserved: []string{"a-b"}}, `reserved entry "a-b" is not an identifier`},
+		{&FormatOptions{LineEnding: "CRLF", QuoteStyle: "Single"}, ""},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		err := tt.opts.Validate()
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("Validate(%+v) error: %v", tt.opts, err)
+		case tt.want != "" && (err == nil || err.Error() != tt.want):
+			t.Errorf("Validate(%+v) error = %v, want %q", tt.opts, err, tt.want)
+		}
+	}
+}
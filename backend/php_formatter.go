@@ -0,0 +1,1111 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// phpScopeKind says how the code between a pair of brackets is laid out
+type phpScopeKind int
+
+const (
+	phpStatements phpScopeKind = iota // the file, blocks and alternative syntax bodies
+	phpArms                           // the arms of a match expression
+	phpList                           // parentheses, brackets and attributes
+	phpInline                         // braces inside an expression, such as ${...}
+)
+
+// phpScope is an open bracket, or an alternative syntax body, being printed
+type phpScope struct {
+	kind phpScopeKind
+	// base is the indentation level of the line the scope opened on; its
+	// contents are indented one level deeper
+	base int
+	// broken lists put each element on its own line
+	broken bool
+	// array lists get a trailing comma when broken and align their arrows
+	array bool
+	align int
+	// role is the keyword owning a parenthesized list, or "params"
+	role string
+	// fresh is set when the next token starts a statement or element
+	fresh bool
+	// empty is set until something is written in the scope
+	empty bool
+	// compact scopes were written as {} in the source and stay that way
+	compact   bool
+	ternaries int
+	// expr is set for blocks that end an expression: closures, anonymous
+	// classes and match arms
+	expr bool
+	alt  bool
+	do   bool
+	// cases is set for switch bodies; label while a case label is being
+	// written and body once its colon is
+	cases bool
+	label bool
+	body  bool
+	// assigned is set in a parameter list after a default value begins
+	assigned bool
+	// arrow is set in a match arm once its => is written
+	arrow bool
+}
+
+// phpBrace is the block a keyword expects, waiting for its opening brace
+type phpBrace struct {
+	depth    int
+	keyword  string
+	nextLine bool // PSR-12 puts class and function braces on their own line
+	expr     bool
+	broken   bool // the header's parameters were split across lines
+}
+
+type phpSep int
+
+const (
+	phpSepNone phpSep = iota
+	phpSepSpace
+	phpSepAlign // a space that is widened to line up => in an array
+	phpSepLine
+	phpSepBlank
+)
+
+// phpFormatter lays out a token stream following PSR-12. Line breaks are
+// decided by the brackets and statements around each token; string
+// literals, heredocs and inline HTML are copied unchanged.
+type phpFormatter struct {
+	tokens []phpToken
+	match  []int  // index of the matching bracket
+	broken []bool // lists whose elements are on separate lines in the source
+	unit   string
+
+	out        []byte
+	lineIndent int
+	codeEnd    int // length of out after the last code token
+	stack      []*phpScope
+	braces     []phpBrace
+	aligns     int
+	canAlign   bool
+
+	prev       int      // last token written, or -1
+	prevCode   int      // last token written that is not a comment, or -1
+	keywords   []string // lowercased keyword of each token written, if any
+	force      phpSep   // break the previous token requires after it
+	glue       bool     // the previous token binds to the next one
+	ends       bool     // the previous token ends an operand
+	control    string   // keyword whose condition the previous token closed
+	params     bool     // the previous token closed a parameter list
+	typeHint   bool     // inside a property, constant or return type
+	stmtStart  int      // first token of the current statement
+	afterBlock *phpScope
+}
+
+func formatPHP(src string, opts *FormatOptions) (string, error) {
+	tokens, err := tokenizePHP(src)
+	if err != nil {
+		return "", err
+	}
+	f := &phpFormatter{
+		tokens:   tokens,
+		unit:     opts.indent(4),
+		stack:    []*phpScope{{kind: phpStatements, base: -1, fresh: true}},
+		prev:     -1,
+		prevCode: -1,
+		keywords: make([]string, len(tokens)),
+		canAlign: !strings.ContainsRune(src, 0),
+	}
+	if err := f.matchBrackets(src); err != nil {
+		return "", err
+	}
+	for i := range tokens {
+		f.emit(i)
+	}
+	if f.prev >= 0 {
+		if last := tokens[f.prev].kind; last != phpInlineHTML && last != phpCloseTag {
+			f.trimSpace()
+			f.out = append(f.out, '\n')
+		}
+	}
+	return f.alignArrows(string(f.out)), nil
+}
+
+// matchBrackets pairs up brackets and notes which lists are broken: those
+// with a line break before any token directly inside them or before the
+// closing bracket
+func (f *phpFormatter) matchBrackets(src string) error {
+	f.match = make([]int, len(f.tokens))
+	f.broken = make([]bool, len(f.tokens))
+	var open []int
+	closers := map[string]string{"(": ")", "[": "]", "#[": "]", "{": "}"}
+	for i, tok := range f.tokens {
+		if tok.kind != phpPunct {
+			continue
+		}
+		if _, ok := closers[tok.text]; ok {
+			open = append(open, i)
+			continue
+		}
+		if tok.text != ")" && tok.text != "]" && tok.text != "}" {
+			continue
+		}
+		if len(open) == 0 || closers[f.tokens[open[len(open)-1]].text] != tok.text {
+			return phpErrorf(src, tok.start, "unexpected %s", tok.text)
+		}
+		o := open[len(open)-1]
+		open = open[:len(open)-1]
+		f.match[o], f.match[i] = i, o
+	}
+	if len(open) > 0 {
+		tok := f.tokens[open[len(open)-1]]
+		return phpErrorf(src, tok.start, "unclosed %s", tok.text)
+	}
+
+	for i, tok := range f.tokens {
+		if tok.kind != phpPunct || (tok.text != "(" && tok.text != "[" && tok.text != "#[") {
+			continue
+		}
+		end := f.match[i]
+		broken := f.tokens[end].newlines > 0
+		for j := i + 1; j < end && !broken; j++ {
+			broken = f.tokens[j].newlines > 0
+			if f.isOpener(j) {
+				j = f.match[j]
+			}
+		}
+		f.broken[i] = broken
+	}
+	return nil
+}
+
+func phpErrorf(src string, offset int, msg string, args ...interface{}) error {
+	return newSyntaxError(syntaxPHP, src, offset, msg, args...)
+}
+
+func (f *phpFormatter) isOpener(i int) bool {
+	tok := f.tokens[i]
+	return tok.kind == phpPunct && (tok.text == "(" || tok.text == "[" || tok.text == "#[" || tok.text == "{")
+}
+
+func (f *phpFormatter) top() *phpScope {
+	return f.stack[len(f.stack)-1]
+}
+
+func (f *phpFormatter) push(s *phpScope) {
+	f.stack = append(f.stack, s)
+}
+
+func (f *phpFormatter) pop() *phpScope {
+	s := f.top()
+	if len(f.stack) > 1 {
+		f.stack = f.stack[:len(f.stack)-1]
+	}
+	return s
+}
+
+// nextCode returns the index of the next token that is not a comment, or -1
+func (f *phpFormatter) nextCode(i int) int {
+	for i++; i < len(f.tokens); i++ {
+		if !f.tokens[i].isComment() {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *phpFormatter) prevText() string {
+	if f.prevCode < 0 || f.tokens[f.prevCode].kind != phpPunct {
+		return ""
+	}
+	return f.tokens[f.prevCode].text
+}
+
+func (f *phpFormatter) prevKeyword() string {
+	if f.prevCode < 0 {
+		return ""
+	}
+	return f.keywords[f.prevCode]
+}
+
+// keywordAt returns the keyword token i is used as, or an empty string
+// for names that merely spell one, such as methods and class constants
+func (f *phpFormatter) keywordAt(i int) string {
+	kw := f.tokens[i].keyword()
+	if kw == "" {
+		return ""
+	}
+	switch f.prevText() {
+	case "->", "?->", "::":
+		return ""
+	}
+	switch f.prevKeyword() {
+	case "function", "const":
+		return ""
+	case "case":
+		if !f.top().cases {
+			// An enum case name
+			return ""
+		}
+	}
+	if next := f.nextCode(i); next >= 0 && f.tokens[next].is(":") && f.top().kind == phpList {
+		// A named argument
+		return ""
+	}
+	return kw
+}
+
+// pushBrace records the block the keyword at the current depth expects,
+// replacing any earlier expectation at the same depth
+func (f *phpFormatter) pushBrace(b phpBrace) {
+	b.depth = len(f.stack)
+	for len(f.braces) > 0 && f.braces[len(f.braces)-1].depth >= b.depth {
+		f.braces = f.braces[:len(f.braces)-1]
+	}
+	f.braces = append(f.braces, b)
+}
+
+// takeBrace returns the expectation for a block opening at the current
+// depth, if there is one
+func (f *phpFormatter) takeBrace() (phpBrace, bool) {
+	if n := len(f.braces); n > 0 && f.braces[n-1].depth == len(f.stack) {
+		b := f.braces[n-1]
+		f.braces = f.braces[:n-1]
+		return b, true
+	}
+	return phpBrace{}, false
+}
+
+// dropBraces forgets expectations that a statement ended without meeting
+func (f *phpFormatter) dropBraces() {
+	for len(f.braces) > 0 && f.braces[len(f.braces)-1].depth >= len(f.stack) {
+		f.braces = f.braces[:len(f.braces)-1]
+	}
+}
+
+var (
+	phpControlKeywords = map[string]bool{
+		"if": true, "elseif": true, "while": true, "for": true, "foreach": true,
+		"switch": true, "declare": true, "catch": true, "match": true,
+	}
+	phpBlockKeywords = map[string]bool{
+		"else": true, "try": true, "finally": true, "do": true, "namespace": true,
+	}
+	phpAltEnds = map[string]bool{
+		"endif": true, "endwhile": true, "endfor": true, "endforeach": true,
+		"endswitch": true, "enddeclare": true,
+	}
+	phpModifiers = map[string]bool{
+		"public": true, "protected": true, "private": true, "var": true,
+		"readonly": true, "static": true, "const": true,
+	}
+	// phpTightParens are keywords written like function calls
+	phpTightParens = map[string]bool{
+		"array": true, "list": true, "isset": true, "empty": true, "unset": true,
+		"exit": true, "die": true, "eval": true, "print": true, "declare": true, "static": true,
+		"self": true, "parent": true, "class": true,
+	}
+	phpLeadingOperators = map[string]bool{
+		"&&": true, "||": true, "??": true, "?": true, ":": true, ".": true,
+		"+": true, "-": true, "*": true, "/": true, "and": true, "or": true, "xor": true,
+	}
+)
+
+func (f *phpFormatter) emit(i int) {
+	tok := f.tokens[i]
+	switch tok.kind {
+	case phpInlineHTML:
+		f.out = append(f.out, tok.text...)
+		f.lineIndent = 0
+		f.prev = i
+		f.force = phpSepNone
+		return
+	case phpOpenTag, phpOpenTagEcho:
+		text := "<?="
+		if tok.kind == phpOpenTag {
+			text = "<?"
+			if len(tok.text) > 2 {
+				text = "<?php"
+			}
+		}
+		f.out = append(f.out, text...)
+		f.prev, f.prevCode = i, i
+		f.force = phpSepNone
+		if next := i + 1; next < len(f.tokens) && f.tokens[next].newlines > 0 {
+			f.force = phpSepLine
+		}
+		f.top().fresh = true
+		f.glue, f.ends, f.afterBlock = false, false, nil
+		return
+	case phpCloseTag:
+		sep, indent := phpSepSpace, 0
+		if tok.newlines > 0 && f.prev >= 0 && f.tokens[f.prev].kind != phpOpenTag && f.tokens[f.prev].kind != phpOpenTagEcho {
+			sep, indent = phpSepLine, f.indent(f.top(), true)
+		}
+		if f.prev < 0 {
+			sep = phpSepNone
+		}
+		f.put(tok.text, sep, indent)
+		f.dropBraces()
+		f.top().fresh = true
+		f.prev, f.prevCode = i, i
+		f.force, f.glue, f.ends, f.afterBlock, f.typeHint = phpSepNone, false, false, nil, false
+		return
+	}
+
+	if tok.isComment() {
+		f.emitComment(i)
+		return
+	}
+
+	kw := f.keywordAt(i)
+	f.keywords[i] = kw
+	text := tok.text
+	if kw != "" {
+		text = kw
+	} else if tok.kind == phpCast {
+		text = castName(tok.text)
+	}
+
+	// Closing tokens leave their scope before the separator is chosen
+	var closed *phpScope
+	s := f.top()
+	fresh := s.fresh
+	switch {
+	case tok.is(")"), tok.is("]"), tok.is("}"):
+		closed = f.pop()
+	case phpAltEnds[kw] && s.alt:
+		closed = f.pop()
+	case (kw == "else" || kw == "elseif") && s.alt && f.prevText() != "}":
+		closed = f.pop()
+	}
+	s = f.top()
+
+	glueBefore, glueAfter := false, false
+	unary := false
+	var colon string
+	switch {
+	case tok.is("?"):
+		if f.nullable() {
+			glueAfter = true
+		} else {
+			s.ternaries++
+		}
+	case tok.is(":"):
+		glueBefore = true
+		switch {
+		case f.prevText() == "?":
+			s.ternaries--
+		case s.ternaries > 0:
+			s.ternaries--
+			glueBefore = false
+		case s.label:
+			colon = "case"
+		case f.control != "" || f.prevKeyword() == "else":
+			colon = "alt"
+		case f.params:
+			colon = "return"
+		case f.prevCode == f.stmtStart && f.tokens[f.prevCode].kind == phpName:
+			colon = "label"
+		}
+	case tok.is("-"), tok.is("+"):
+		unary = !f.ends
+	case tok.is("&"):
+		switch {
+		case !f.ends:
+			unary = true
+		case f.inType():
+			if next := f.nextCode(i); next >= 0 && (f.tokens[next].kind == phpVariable || f.tokens[next].is("...")) {
+				unary = true
+			} else {
+				glueBefore, glueAfter = true, true
+			}
+		}
+	case tok.is("|"):
+		if f.inType() {
+			glueBefore, glueAfter = true, true
+		}
+	case tok.is("="):
+		if s.role == "declare" {
+			glueBefore, glueAfter = true, true
+		}
+	case tok.is("++"), tok.is("--"):
+		if f.ends {
+			glueBefore = true
+		} else {
+			unary = true
+		}
+	case tok.is("!"), tok.is("~"), tok.is("@"), tok.is("$"), tok.is("..."):
+		glueAfter = true
+	}
+	glueAfter = glueAfter || unary
+
+	sep, indent := f.separator(i, kw, closed, glueBefore)
+	if f.prev >= 0 && f.tokens[f.prev].kind == phpLineComment && sep < phpSepLine {
+		// Nothing may follow a line comment on its line
+		sep, indent = phpSepLine, f.indent(s, s.fresh)
+		if closed != nil {
+			indent = closed.base
+		}
+	}
+
+	// A broken array or match gets a trailing comma
+	if closed != nil && sep >= phpSepLine && (closed.array || closed.kind == phpArms) && !closed.empty && f.prevText() != "," {
+		f.out = append(f.out[:f.codeEnd], append([]byte{','}, f.out[f.codeEnd:]...)...)
+	}
+	if tok.is("=>") && s.align > 0 && sep == phpSepSpace {
+		sep = phpSepAlign
+	}
+	f.put(text, sep, indent)
+	f.codeEnd = len(f.out)
+
+	if fresh && s.kind == phpStatements {
+		f.stmtStart = i
+	}
+	s.empty = false
+	s.fresh = false
+	f.force = phpSepNone
+	f.afterBlock = nil
+	control := f.control
+	f.control, f.params = "", false
+	f.glue = glueAfter
+	f.ends = false
+
+	switch tok.kind {
+	case phpVariable, phpNumber, phpString, phpHeredoc:
+		f.ends = true
+		if tok.kind == phpVariable {
+			f.typeHint = false
+		}
+	case phpName:
+		switch kw {
+		case "", "true", "false", "null", "self", "parent", "static":
+			f.ends = kw != "static" || !f.nextIs(i, "function", "fn")
+		}
+		f.keywordEffects(i, kw, fresh)
+	}
+
+	switch {
+	case tok.is("("), tok.is("["), tok.is("#["):
+		f.openList(i, kw)
+	case tok.is("{"):
+		f.typeHint = false
+		f.openBrace(i)
+	case tok.is(")"), tok.is("]"):
+		f.ends = true
+		switch closed.role {
+		case "params":
+			f.params = true
+			if closed.broken && len(f.braces) > 0 && f.braces[len(f.braces)-1].depth == len(f.stack) {
+				f.braces[len(f.braces)-1].broken = true
+			}
+		case "array", "attribute", "":
+		default:
+			if phpControlKeywords[closed.role] {
+				f.control = closed.role
+			}
+		}
+		if closed.role == "attribute" && s.kind == phpStatements {
+			if next := i + 1; next < len(f.tokens) && f.tokens[next].newlines > 0 {
+				f.force = phpSepLine
+				s.fresh = true
+			}
+		}
+	case tok.is("}"):
+		if closed.kind == phpStatements && !closed.expr {
+			f.force = phpSepLine
+			f.afterBlock = closed
+			s.fresh = true
+		} else {
+			f.ends = true
+		}
+	case tok.is(","):
+		s.assigned = false
+		if (s.kind == phpArms && s.arrow) || (s.kind == phpList && s.broken) {
+			s.arrow = false
+			f.force = phpSepLine
+			s.fresh = true
+		}
+	case tok.is(";"):
+		f.typeHint = false
+		if s.label {
+			f.endCaseLabel(s)
+		} else if s.kind == phpStatements {
+			f.force = f.headerBreak(i, s)
+			s.fresh = true
+			f.dropBraces()
+		}
+	case tok.is("="):
+		f.typeHint = false
+		if s.role == "params" {
+			s.assigned = true
+		}
+	case tok.is("=>"):
+		f.typeHint = false
+		s.arrow = true
+	case tok.is("++"), tok.is("--"):
+		f.ends = glueBefore
+	case tok.is(":"):
+		switch colon {
+		case "case":
+			f.endCaseLabel(s)
+		case "alt":
+			keyword := control
+			if keyword == "" {
+				keyword = "else"
+			}
+			if b, ok := f.takeBrace(); ok {
+				keyword = b.keyword
+			}
+			if keyword == "elseif" || keyword == "else" {
+				keyword = "if"
+			}
+			f.push(&phpScope{
+				kind:  phpStatements,
+				base:  f.indent(s, true),
+				fresh: true,
+				empty: true,
+				alt:   true,
+				cases: keyword == "switch",
+			})
+			f.force = phpSepLine
+		case "return":
+			f.typeHint = true
+		case "label":
+			f.force = phpSepLine
+			s.fresh = true
+		}
+	}
+	f.prev, f.prevCode = i, i
+}
+
+func (f *phpFormatter) nextIs(i int, keywords ...string) bool {
+	next := f.nextCode(i)
+	if next < 0 {
+		return false
+	}
+	tok := f.tokens[next]
+	for _, k := range keywords {
+		if tok.keyword() == k || tok.is(k) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *phpFormatter) endCaseLabel(s *phpScope) {
+	s.label = false
+	s.body = true
+	s.fresh = true
+	f.force = phpSepLine
+}
+
+// nullable reports whether a ? marks a nullable type rather than a ternary
+func (f *phpFormatter) nullable() bool {
+	s := f.top()
+	switch f.prevText() {
+	case "(", ",":
+		return s.role == "params" && !s.assigned
+	case ":":
+		return f.typeHint
+	}
+	return f.typeHint && phpModifiers[f.prevKeyword()]
+}
+
+// inType reports whether the next token is part of a type declaration,
+// where | and & join types without spaces
+func (f *phpFormatter) inType() bool {
+	s := f.top()
+	return f.typeHint || (s.role == "params" && !s.assigned)
+}
+
+// keywordEffects notes what a keyword implies for the tokens after it
+func (f *phpFormatter) keywordEffects(i int, kw string, fresh bool) {
+	s := f.top()
+	switch {
+	case phpControlKeywords[kw]:
+		f.pushBrace(phpBrace{keyword: kw, expr: kw == "match"})
+	case phpBlockKeywords[kw]:
+		f.pushBrace(phpBrace{keyword: kw})
+	case kw == "class" && f.prevKeyword() == "new":
+		f.pushBrace(phpBrace{keyword: kw, expr: true})
+	case kw == "class", kw == "interface", kw == "trait":
+		f.pushBrace(phpBrace{keyword: kw, nextLine: true})
+	case kw == "" && strings.EqualFold(f.tokens[i].text, "enum") && s.kind == phpStatements:
+		if next := f.nextCode(i); next >= 0 && f.tokens[next].kind == phpName {
+			f.pushBrace(phpBrace{keyword: "enum", nextLine: true})
+		}
+	case kw == "function":
+		f.typeHint = false
+		next := f.nextCode(i)
+		if next >= 0 && f.tokens[next].is("&") {
+			next = f.nextCode(next)
+		}
+		if next >= 0 && f.tokens[next].is("(") {
+			f.pushBrace(phpBrace{keyword: kw, expr: true})
+		} else {
+			f.pushBrace(phpBrace{keyword: kw, nextLine: true})
+		}
+	case kw == "use" && !f.nextIs(i, "("):
+		// A trait use may have a block of conflict resolutions
+		f.pushBrace(phpBrace{keyword: kw})
+	case (kw == "case" || kw == "default") && s.cases && fresh:
+		s.label = true
+	}
+	if next := f.nextCode(i); phpModifiers[kw] && s.kind == phpStatements && next >= 0 && !f.nextIs(i, "function", "fn") {
+		if tok := f.tokens[next]; tok.kind == phpName || tok.is("?") {
+			f.typeHint = true
+		}
+	}
+}
+
+// openList pushes the scope for a parenthesis or bracket
+func (f *phpFormatter) openList(i int, kw string) {
+	tok := f.tokens[i]
+	s := &phpScope{
+		kind:   phpList,
+		base:   f.lineIndent,
+		broken: f.broken[i],
+		fresh:  true,
+		empty:  true,
+	}
+	prevKw := ""
+	if j := f.prevCodeBefore(i); j >= 0 {
+		prevKw = f.keywords[j]
+	}
+	switch {
+	case tok.is("#["):
+		s.role = "attribute"
+	case tok.is("["):
+		s.array = !f.endsBefore(i)
+		if s.array {
+			s.role = "array"
+		}
+	case phpControlKeywords[prevKw]:
+		s.role = prevKw
+	case prevKw == "array":
+		s.role = "array"
+		s.array = true
+	case prevKw == "function", prevKw == "fn", prevKw == "use":
+		s.role = "params"
+	default:
+		if j := f.prevCodeBefore(i); j >= 0 && f.tokens[j].kind == phpName {
+			k := f.prevCodeBefore(j)
+			if k >= 0 && f.tokens[k].is("&") {
+				k = f.prevCodeBefore(k)
+			}
+			if k >= 0 && f.keywords[k] == "function" {
+				s.role = "params"
+			}
+		}
+	}
+	if s.array && s.broken && f.canAlign {
+		f.aligns++
+		s.align = f.aligns
+	}
+	f.ends = false
+	f.push(s)
+	if s.broken {
+		f.force = phpSepLine
+	}
+}
+
+// prevCodeBefore returns the last code token before i, which has been
+// written already
+func (f *phpFormatter) prevCodeBefore(i int) int {
+	for i--; i >= 0; i-- {
+		if !f.tokens[i].isComment() {
+			return i
+		}
+	}
+	return -1
+}
+
+// endsBefore reports whether the token before i ends an operand, which
+// makes a following bracket an index rather than an array
+func (f *phpFormatter) endsBefore(i int) bool {
+	j := f.prevCodeBefore(i)
+	if j < 0 {
+		return false
+	}
+	switch tok := f.tokens[j]; tok.kind {
+	case phpVariable, phpString, phpHeredoc, phpNumber:
+		return true
+	case phpName:
+		switch f.keywords[j] {
+		case "", "self", "parent", "static", "true", "false", "null":
+			return true
+		}
+	case phpPunct:
+		return tok.text == ")" || tok.text == "]" || tok.text == "}"
+	}
+	return false
+}
+
+// openBrace pushes the scope for a {, deciding from what precedes it
+// whether it opens a block, a match body or braces inside an expression
+func (f *phpFormatter) openBrace(i int) {
+	parent := f.top()
+	s := &phpScope{kind: phpInline, base: f.lineIndent, fresh: true, empty: true}
+	s.compact = f.match[i] == i+1 && f.tokens[i+1].newlines == 0
+
+	prev := f.prevCodeBefore(i)
+	switch {
+	case prev >= 0 && (f.tokens[prev].is("->") || f.tokens[prev].is("?->") || f.tokens[prev].is("::") || f.tokens[prev].is("$")):
+	case prev >= 0 && f.tokens[prev].kind == phpName && strings.HasSuffix(f.tokens[prev].text, "\\"):
+		// A group use list
+	default:
+		if b, ok := f.takeBrace(); ok {
+			s.kind = phpStatements
+			s.expr = b.expr
+			s.do = b.keyword == "do"
+			s.cases = b.keyword == "switch"
+			if b.keyword == "match" {
+				s.kind = phpArms
+			}
+		} else if parent.kind == phpStatements && parent.fresh {
+			s.kind = phpStatements
+		}
+	}
+	f.push(s)
+	f.ends = false
+	if s.kind != phpInline && !s.compact {
+		f.force = phpSepLine
+	}
+}
+
+// braceOnNextLine reports whether the { at i goes on a line of its own
+func (f *phpFormatter) braceOnNextLine(i int) bool {
+	if f.match[i] == i+1 && f.tokens[i+1].newlines == 0 {
+		return false
+	}
+	if n := len(f.braces); n > 0 && f.braces[n-1].depth == len(f.stack) {
+		b := f.braces[n-1]
+		return b.nextLine && !b.broken
+	}
+	return false
+}
+
+// separator chooses what goes between the previous token and token i, and
+// the indentation of its line when that is a line break
+func (f *phpFormatter) separator(i int, kw string, closed *phpScope, glueBefore bool) (phpSep, int) {
+	tok := f.tokens[i]
+	s := f.top()
+	if f.prev < 0 {
+		return phpSepNone, 0
+	}
+	prev := f.tokens[f.prev]
+	if (prev.kind == phpOpenTag || prev.kind == phpOpenTagEcho) && tok.newlines == 0 {
+		return phpSepSpace, 0
+	}
+
+	if closed != nil {
+		switch {
+		case closed.compact, closed.kind == phpInline:
+			return phpSepNone, 0
+		case closed.kind == phpList && !closed.broken:
+			return phpSepNone, 0
+		}
+		return phpSepLine, closed.base
+	}
+
+	if tok.is("{") && f.braceOnNextLine(i) {
+		return phpSepLine, f.indent(s, true)
+	}
+
+	if b := f.afterBlock; b != nil && ((kw == "else" || kw == "elseif" || kw == "catch" || kw == "finally") || (kw == "while" && b.do)) {
+		return phpSepSpace, 0
+	}
+
+	if s.kind == phpStatements && s.cases && s.fresh && (kw == "case" || kw == "default") {
+		return f.lineBreak(i, s.base+1)
+	}
+	if f.force >= phpSepLine {
+		return f.lineBreak(i, f.indent(s, s.fresh))
+	}
+
+	if tok.newlines > 0 && !s.fresh && f.keepsBreak(i, glueBefore) {
+		level := f.indent(s, false)
+		if s.kind == phpList && phpLeadingOperators[strings.ToLower(tok.text)] {
+			level--
+		}
+		return phpSepLine, level
+	}
+
+	if f.spaced(i, glueBefore) {
+		return phpSepSpace, 0
+	}
+	return phpSepNone, 0
+}
+
+// headerBreak returns the break after the statement ending at token i.
+// PSR-12 separates the blocks of a file header with a blank line: the
+// declare statements, the namespace declaration and each block of use
+// imports.
+func (f *phpFormatter) headerBreak(i int, s *phpScope) phpSep {
+	if s.base >= 0 || s.alt {
+		return phpSepLine
+	}
+	block := f.headerBlock(f.stmtStart)
+	if block == "" {
+		return phpSepLine
+	}
+	if next := f.nextCode(i); next >= 0 && block != "declare" && f.headerBlock(next) == block {
+		return phpSepLine
+	}
+	return phpSepBlank
+}
+
+// headerBlock returns the header block a statement starting at token i
+// belongs to, if any. Imports of classes, functions and constants are
+// separate blocks.
+func (f *phpFormatter) headerBlock(i int) string {
+	switch kw := f.tokens[i].keyword(); kw {
+	case "declare", "namespace":
+		if next := f.nextCode(i); next >= 0 && f.tokens[next].is("\\") {
+			// namespace\name() calls a function of the current namespace
+			return ""
+		}
+		return kw
+	case "use":
+		if next := f.nextCode(i); next >= 0 {
+			if next := f.tokens[next].keyword(); next == "function" || next == "const" {
+				return "use " + next
+			}
+		}
+		return kw
+	}
+	return ""
+}
+
+// lineBreak breaks the line before token i, keeping one blank line where
+// the source has any or a blank line is required
+func (f *phpFormatter) lineBreak(i, indent int) (phpSep, int) {
+	s := f.top()
+	if f.force == phpSepBlank {
+		return phpSepBlank, indent
+	}
+	if f.tokens[i].newlines > 1 && (!s.empty || s.kind == phpStatements && s.base < 0) {
+		return phpSepBlank, indent
+	}
+	return phpSepLine, indent
+}
+
+// indent returns the indentation level for a line in scope s, which
+// begins a statement or element when fresh
+func (f *phpFormatter) indent(s *phpScope, fresh bool) int {
+	level := s.base + 1
+	if s.kind == phpStatements && s.cases && s.body && !s.label {
+		level++
+	}
+	if !fresh {
+		level++
+	}
+	return level
+}
+
+// keepsBreak reports whether a line break in the source before token i,
+// in the middle of a statement or element, is kept
+func (f *phpFormatter) keepsBreak(i int, glueBefore bool) bool {
+	s := f.top()
+	if s.kind == phpInline || (s.kind == phpList && !s.broken) {
+		return false
+	}
+	tok := f.tokens[i]
+	if tok.kind == phpPunct {
+		switch tok.text {
+		case ";", ",", "{", "(":
+			return false
+		case ":":
+			// Only a ternary may start a line with its colon
+			return !glueBefore
+		case "[":
+			return !f.ends
+		}
+	}
+	return true
+}
+
+// spaced reports whether a space separates token i from the previous one
+// on the same line
+func (f *phpFormatter) spaced(i int, glueBefore bool) bool {
+	tok := f.tokens[i]
+	prev := f.tokens[f.prev]
+	if f.glue || glueBefore {
+		// Keep operators that would fuse into another one apart
+		return phpFuses(prev.text, tok.text)
+	}
+	if prev.isComment() {
+		return true
+	}
+	if tok.isComment() {
+		return !(prev.kind == phpPunct && (prev.text == "(" || prev.text == "[" || prev.text == "#["))
+	}
+	if prev.kind == phpCast {
+		return true
+	}
+	if tok.kind == phpPunct {
+		switch tok.text {
+		case ",", ";", ")", "]", "}", "->", "?->", "::":
+			return false
+		}
+	}
+	if prev.kind == phpPunct {
+		switch prev.text {
+		case "(", "[", "#[", "{", "->", "?->", "::":
+			return false
+		}
+	}
+	if tok.kind == phpPunct {
+		switch tok.text {
+		case "(":
+			if kw := f.prevKeyword(); kw != "" {
+				return !phpTightParens[kw]
+			}
+			return !f.ends
+		case "[":
+			return !f.ends
+		case "{":
+			// A group use list
+			return !(prev.kind == phpName && strings.HasSuffix(prev.text, "\\"))
+		}
+	}
+	return true
+}
+
+// phpFuses reports whether two operators written together would read as a
+// different operator
+func phpFuses(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	last, first := a[len(a)-1], b[0]
+	return (last == '-' || last == '+' || last == '&') && first == last
+}
+
+func (f *phpFormatter) emitComment(i int) {
+	tok := f.tokens[i]
+	s := f.top()
+	text := strings.TrimRight(tok.text, " \t")
+
+	sep, indent := phpSepSpace, 0
+	prev := phpToken{}
+	if f.prev >= 0 {
+		prev = f.tokens[f.prev]
+	}
+	switch {
+	case f.prev < 0:
+		sep = phpSepNone
+	case tok.newlines > 0:
+		level := f.indent(s, s.fresh)
+		if s.kind == phpStatements && s.cases && s.fresh && f.nextIs(i, "case", "default") {
+			level = s.base + 1
+		}
+		if s.kind == phpList && !s.broken {
+			level = s.base + 1
+		}
+		sep, indent = f.lineBreak(i, level)
+	case prev.kind == phpPunct && (prev.text == "(" || prev.text == "[" || prev.text == "#[") && f.force < phpSepLine:
+		sep = phpSepNone
+	}
+	if tok.kind == phpDocComment || tok.kind == phpBlockComment {
+		text = f.reindentComment(text, indent, sep >= phpSepLine)
+	}
+	f.put(text, sep, indent)
+	if sep >= phpSepLine && f.force == phpSepBlank {
+		// The blank line required after a header block came before the
+		// comment
+		f.force = phpSepLine
+	}
+
+	f.prev = i
+	f.glue = false
+	next := i + 1
+	if tok.kind == phpLineComment || (next < len(f.tokens) && f.tokens[next].newlines > 0) {
+		if f.force < phpSepLine {
+			f.force = phpSepLine
+		}
+	}
+}
+
+// reindentComment moves the continuation lines of a block comment whose
+// lines all start with * to the comment's new indentation
+func (f *phpFormatter) reindentComment(text string, indent int, ownLine bool) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) == 1 || !ownLine {
+		return text
+	}
+	for _, line := range lines[1:] {
+		if !strings.HasPrefix(strings.TrimLeft(line, " \t"), "*") {
+			return text
+		}
+	}
+	prefix := strings.Repeat(f.unit, indent) + " "
+	for j := 1; j < len(lines); j++ {
+		lines[j] = prefix + strings.TrimRight(strings.TrimLeft(lines[j], " \t"), " \t\r")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// put writes a token after its separator
+func (f *phpFormatter) put(text string, sep phpSep, indent int) {
+	switch sep {
+	case phpSepSpace:
+		f.out = append(f.out, ' ')
+	case phpSepAlign:
+		f.out = append(f.out, 0)
+		f.out = strconv.AppendInt(f.out, int64(f.top().align), 10)
+		f.out = append(f.out, 1)
+	case phpSepLine, phpSepBlank:
+		f.trimSpace()
+		f.out = append(f.out, '\n')
+		if sep == phpSepBlank {
+			f.out = append(f.out, '\n')
+		}
+		for j := 0; j < indent; j++ {
+			f.out = append(f.out, f.unit...)
+		}
+		f.lineIndent = indent
+	}
+	f.out = append(f.out, text...)
+}
+
+func (f *phpFormatter) trimSpace() {
+	for len(f.out) > 0 && (f.out[len(f.out)-1] == ' ' || f.out[len(f.out)-1] == '\t') {
+		f.out = f.out[:len(f.out)-1]
+	}
+}
+
+// alignArrows replaces the markers left before the => of broken arrays
+// with the padding that lines the arrows of each array up
+func (f *phpFormatter) alignArrows(out string) string {
+	if f.aligns == 0 || !strings.ContainsRune(out, 0) {
+		return out
+	}
+	lines := strings.Split(out, "\n")
+	widths := make(map[string]int)
+	marker := func(line string) (int, int, string) {
+		start := strings.IndexByte(line, 0)
+		if start < 0 {
+			return -1, -1, ""
+		}
+		end := start + strings.IndexByte(line[start:], 1)
+		return start, end, line[start+1 : end]
+	}
+	for _, line := range lines {
+		if start, _, id := marker(line); start >= 0 {
+			widths[id] = max(widths[id], utf8.RuneCountInString(line[:start]))
+		}
+	}
+	for j, line := range lines {
+		for {
+			start, end, id := marker(line)
+			if start < 0 {
+				break
+			}
+			pad := widths[id] - utf8.RuneCountInString(line[:start]) + 1
+			line = line[:start] + strings.Repeat(" ", pad) + line[end+1:]
+		}
+		lines[j] = line
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func TestFormatPHP(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			"class",
+			"<?php\nnamespace App;\nuse Foo\\Bar;\nclass A extends B implements C{\npublic function f($a,$b=1){if($a){return $b;}else{foreach($a as $k=>$v){echo \"$k: {$v}\";}}}\n}",
+			"<?php\nnamespace App;\n\nuse Foo\\Bar;\n\nclass A extends B implements C\n{\n    public function f($a, $b = 1)\n    {\n        if ($a) {\n            return $b;\n        } else {\n            foreach ($a as $k => $v) {\n                echo \"$k: {$v}\";\n            }\n        }\n    }\n}\n",
+		},
+		{
+			"header blocks",
+			"<?php\ndeclare(strict_types=1);\nnamespace A;\nuse B;\nuse C\\D;\nuse function E\\f;\nuse const G\\H;\n// note\nclass C{}",
+			"<?php\ndeclare(strict_types=1);\n\nnamespace A;\n\nuse B;\nuse C\\D;\n\nuse function E\\f;\n\nuse const G\\H;\n\n// note\nclass C {}\n",
+		},
+		{
+			"closure and trait use",
+			"<?php\nclass C{ use T;\npublic $a; }\n$g = function() use ($x) { return $x; };\n$y = 1;",
+			"<?php\nclass C\n{\n    use T;\n    public $a;\n}\n$g = function () use ($x) {\n    return $x;\n};\n$y = 1;\n",
+		},
+		{
+			"template",
+			"<html><body>\n<?php if ($x): ?>\n<p><?= $y ?></p>\n<?php endif; ?>\n</body></html>",
+			"<html><body>\n<?php if ($x): ?>\n<p><?= $y ?></p>\n<?php endif; ?>\n</body></html>",
+		},
+		{
+			"heredoc and strings",
+			"<?php\n$s = <<<EOT\n  keep   this\n    as is\nEOT;\n$t = 'a  b' . \"c  d\";",
+			"<?php\n$s = <<<EOT\n  keep   this\n    as is\nEOT;\n$t = 'a  b' . \"c  d\";\n",
+		},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Format(tt.code, "php")
+			if err != nil {
+				t.Fatalf("Format(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if again, err := f.Format(got, "php"); err != nil || again != got {
+				t.Errorf("Format(%q) = %q, %v; not idempotent", got, again, err)
+			}
+		})
+	}
+}
+
+func TestFormatPHPErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"<?php\nfunction f() {\n", "invalid PHP syntax: 2:14: unclosed {"},
+		{"<?php\n$s = \"unterminated;", "invalid PHP syntax: 2:6: unterminated string"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		_, err := f.Format(tt.code, "php")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Format(%q) error = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,498 @@
+package main
+
+import (
+	"strings"
+)
+
+type phpTokenKind int
+
+const (
+	phpEOF         phpTokenKind = iota
+	phpInlineHTML               // text outside the PHP tags, kept verbatim
+	phpOpenTag                  // <?php or <?
+	phpOpenTagEcho              // <?=
+	phpCloseTag                 // ?> and the single newline PHP swallows after it
+	phpVariable                 // $name
+	phpName                     // identifier, keyword or qualified name
+	phpNumber
+	phpString  // '...', "..." or `...`, interpolations included
+	phpHeredoc // <<<ID through the closing marker
+	phpCast    // (int), (string) and the other casts
+	phpPunct   // operators and punctuation
+	phpLineComment
+	phpBlockComment
+	phpDocComment
+)
+
+// phpToken is a lexical PHP token. Text is the exact source slice.
+type phpToken struct {
+	kind phpTokenKind
+	text string
+	// start and end are byte offsets into the source
+	start int
+	end   int
+	// newlines counts the line breaks in the whitespace before the token
+	newlines int
+	// spaced is set when any whitespace precedes the token
+	spaced bool
+}
+
+func (t phpToken) isComment() bool {
+	return t.kind == phpLineComment || t.kind == phpBlockComment || t.kind == phpDocComment
+}
+
+func (t phpToken) is(text string) bool {
+	return t.kind == phpPunct && t.text == text
+}
+
+// keyword returns the lowercased text of a name that is a reserved word,
+// or an empty string
+func (t phpToken) keyword() string {
+	if t.kind != phpName {
+		return ""
+	}
+	if word := strings.ToLower(t.text); phpKeywords[word] {
+		return word
+	}
+	return ""
+}
+
+// phpKeywords are the reserved words, which PSR-12 writes in lowercase.
+// true, false and null are constants but follow the same rule.
+var phpKeywords = map[string]bool{
+	"abstract": true, "and": true, "array": true, "as": true, "break": true,
+	"callable": true, "case": true, "catch": true, "class": true, "clone": true,
+	"const": true, "continue": true, "declare": true, "default": true, "die": true,
+	"do": true, "echo": true, "else": true, "elseif": true, "empty": true,
+	"enddeclare": true, "endfor": true, "endforeach": true, "endif": true,
+	"endswitch": true, "endwhile": true, "eval": true, "exit": true,
+	"extends": true, "false": true, "final": true, "finally": true, "fn": true,
+	"for": true, "foreach": true, "function": true, "global": true, "goto": true,
+	"if": true, "implements": true, "include": true, "include_once": true,
+	"instanceof": true, "insteadof": true, "interface": true, "isset": true,
+	"list": true, "match": true, "namespace": true, "new": true, "null": true,
+	"or": true, "parent": true, "print": true, "private": true, "protected": true,
+	"public": true, "readonly": true, "require": true, "require_once": true,
+	"return": true, "self": true, "static": true, "switch": true, "throw": true,
+	"trait": true, "true": true, "try": true, "unset": true, "use": true,
+	"var": true, "while": true, "xor": true, "yield": true,
+}
+
+var phpCasts = map[string]bool{
+	"int": true, "integer": true, "bool": true, "boolean": true, "float": true,
+	"double": true, "real": true, "string": true, "binary": true, "array": true,
+	"object": true, "unset": true,
+}
+
+// phpPunctuators is ordered so that longer operators match first
+var phpPunctuators = []string{
+	"<<=", ">>=", "**=", "...", "<=>", "===", "!==", "??=", "?->",
+	"++", "--", "->", "=>", "::", "==", "!=", "<>", "<=", ">=", "&&", "||",
+	"??", "+=", "-=", "*=", "/=", ".=", "%=", "&=", "|=", "^=", "<<", ">>",
+	"**", "#[",
+	"+", "-", "*", "/", "%", "=", "<", ">", "!", ".", "(", ")", "[", "]",
+	"{", "}", ",", ";", "?", ":", "&", "|", "^", "~", "@", "$",
+}
+
+// phpLexer tokenizes a PHP file, inline HTML included
+type phpLexer struct {
+	src  string
+	pos  int
+	html bool // outside the PHP tags
+}
+
+// tokenizePHP lexes a whole file, comments included. Source without any
+// PHP tag is taken to be PHP code, so bare snippets can be formatted.
+func tokenizePHP(src string) ([]phpToken, error) {
+	lx := &phpLexer{src: src, html: strings.Contains(src, "<?")}
+	var tokens []phpToken
+	for {
+		tok, err := lx.next()
+		if err != nil {
+			return nil, err
+		}
+		if tok.kind == phpEOF {
+			return tokens, nil
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+func (lx *phpLexer) errorf(offset int, format string, args ...interface{}) error {
+	return newSyntaxError(syntaxPHP, lx.src, offset, format, args...)
+}
+
+func (lx *phpLexer) next() (phpToken, error) {
+	if lx.html {
+		return lx.scanHTML()
+	}
+
+	newlines, spaced := 0, false
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		if c == '\n' {
+			newlines++
+		} else if c != ' ' && c != '\t' && c != '\r' && c != '\f' && c != '\v' {
+			break
+		}
+		spaced = true
+		lx.pos++
+	}
+
+	start := lx.pos
+	kind, err := lx.scan()
+	if err != nil {
+		return phpToken{}, err
+	}
+	return phpToken{
+		kind:     kind,
+		text:     lx.src[start:lx.pos],
+		start:    start,
+		end:      lx.pos,
+		newlines: newlines,
+		spaced:   spaced,
+	}, nil
+}
+
+// scanHTML returns the inline HTML up to the next opening tag, or the tag
+// itself when the HTML is empty
+func (lx *phpLexer) scanHTML() (phpToken, error) {
+	start := lx.pos
+	for lx.pos < len(lx.src) {
+		if kind, n := lx.openTag(lx.pos); n > 0 {
+			if lx.pos > start {
+				break
+			}
+			lx.pos += n
+			lx.html = false
+			return phpToken{kind: kind, text: lx.src[start:lx.pos], start: start, end: lx.pos}, nil
+		}
+		lx.pos++
+	}
+	if lx.pos == start {
+		return phpToken{kind: phpEOF, start: start, end: start}, nil
+	}
+	return phpToken{kind: phpInlineHTML, text: lx.src[start:lx.pos], start: start, end: lx.pos}, nil
+}
+
+// openTag reports the opening tag at offset and its length. A bare <? only
+// counts when followed by whitespace, so <?xml declarations stay HTML.
+func (lx *phpLexer) openTag(offset int) (phpTokenKind, int) {
+	rest := lx.src[offset:]
+	switch {
+	case !strings.HasPrefix(rest, "<?"):
+		return phpEOF, 0
+	case strings.HasPrefix(rest, "<?="):
+		return phpOpenTagEcho, 3
+	case len(rest) >= 5 && strings.EqualFold(rest[2:5], "php") && (len(rest) == 5 || isPHPSpace(rest[5])):
+		return phpOpenTag, 5
+	case len(rest) > 2 && isPHPSpace(rest[2]):
+		return phpOpenTag, 2
+	}
+	return phpEOF, 0
+}
+
+func (lx *phpLexer) scan() (phpTokenKind, error) {
+	src := lx.src
+	if lx.pos >= len(src) {
+		return phpEOF, nil
+	}
+	start := lx.pos
+	c := src[start]
+
+	switch {
+	case strings.HasPrefix(src[start:], "?>"):
+		lx.pos += 2
+		if strings.HasPrefix(src[lx.pos:], "\r\n") {
+			lx.pos += 2
+		} else if lx.pos < len(src) && src[lx.pos] == '\n' {
+			lx.pos++
+		}
+		lx.html = true
+		return phpCloseTag, nil
+	case c == '#' && !strings.HasPrefix(src[start:], "#["), strings.HasPrefix(src[start:], "//"):
+		lx.skipLineComment()
+		return phpLineComment, nil
+	case strings.HasPrefix(src[start:], "/*"):
+		end := strings.Index(src[start+2:], "*/")
+		if end < 0 {
+			return phpEOF, lx.errorf(start, "unterminated comment")
+		}
+		lx.pos = start + 2 + end + 2
+		if strings.HasPrefix(src[start:], "/**") && lx.pos-start > 4 && isPHPSpace(src[start+3]) {
+			return phpDocComment, nil
+		}
+		return phpBlockComment, nil
+	case c == '$' && start+1 < len(src) && isPHPNameStart(src[start+1]):
+		lx.pos++
+		lx.skipName()
+		return phpVariable, nil
+	case isPHPNameStart(c) || (c == '\\' && start+1 < len(src) && isPHPNameStart(src[start+1])):
+		if lx.prefixedString() {
+			return phpString, lx.scanQuoted(lx.src[lx.pos])
+		}
+		lx.scanQualifiedName()
+		return phpName, nil
+	case isDigit(rune(c)) || (c == '.' && start+1 < len(src) && isDigit(rune(src[start+1]))):
+		lx.scanNumber()
+		return phpNumber, nil
+	case c == '\'' || c == '"' || c == '`':
+		return phpString, lx.scanQuoted(c)
+	case strings.HasPrefix(src[start:], "<<<"):
+		if ok, err := lx.scanHeredoc(); ok || err != nil {
+			return phpHeredoc, err
+		}
+	case c == '(':
+		if n := lx.castLength(start); n > 0 {
+			lx.pos += n
+			return phpCast, nil
+		}
+	}
+
+	for _, p := range phpPunctuators {
+		if strings.HasPrefix(src[start:], p) {
+			lx.pos += len(p)
+			return phpPunct, nil
+		}
+	}
+	return phpEOF, lx.errorf(start, "unexpected character %q", rune(c))
+}
+
+// skipLineComment moves to the end of a // or # comment, which ends at a
+// line break or just before a closing tag
+func (lx *phpLexer) skipLineComment() {
+	for lx.pos < len(lx.src) {
+		if c := lx.src[lx.pos]; c == '\n' || c == '\r' || strings.HasPrefix(lx.src[lx.pos:], "?>") {
+			return
+		}
+		lx.pos++
+	}
+}
+
+func (lx *phpLexer) skipName() {
+	for lx.pos < len(lx.src) && isPHPNameChar(lx.src[lx.pos]) {
+		lx.pos++
+	}
+}
+
+// scanQualifiedName reads a name with its namespace separators. A trailing
+// separator is kept when a group use list follows.
+func (lx *phpLexer) scanQualifiedName() {
+	if lx.src[lx.pos] == '\\' {
+		lx.pos++
+	}
+	lx.skipName()
+	for lx.pos+1 < len(lx.src) && lx.src[lx.pos] == '\\' {
+		switch next := lx.src[lx.pos+1]; {
+		case isPHPNameStart(next):
+			lx.pos++
+			lx.skipName()
+		case next == '{':
+			lx.pos++
+			return
+		default:
+			return
+		}
+	}
+}
+
+// prefixedString reports whether the name at pos is the b prefix of a
+// binary string literal such as b"...", and skips the prefix if so
+func (lx *phpLexer) prefixedString() bool {
+	if c := lx.src[lx.pos]; (c == 'b' || c == 'B') && lx.pos+1 < len(lx.src) {
+		if q := lx.src[lx.pos+1]; q == '\'' || q == '"' {
+			lx.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (lx *phpLexer) scanNumber() {
+	src := lx.src
+	if src[lx.pos] == '0' && lx.pos+1 < len(src) {
+		switch src[lx.pos+1] {
+		case 'x', 'X', 'b', 'B', 'o', 'O':
+			lx.pos += 2
+			for lx.pos < len(src) && (isHexDigit(src[lx.pos]) || src[lx.pos] == '_') {
+				lx.pos++
+			}
+			return
+		}
+	}
+	digits := func() {
+		for lx.pos < len(src) && (isDigit(rune(src[lx.pos])) || src[lx.pos] == '_') {
+			lx.pos++
+		}
+	}
+	digits()
+	if lx.pos < len(src) && src[lx.pos] == '.' && !strings.HasPrefix(src[lx.pos:], "...") {
+		lx.pos++
+		digits()
+	}
+	if lx.pos < len(src) && (src[lx.pos] == 'e' || src[lx.pos] == 'E') {
+		exp := lx.pos + 1
+		if exp < len(src) && (src[exp] == '+' || src[exp] == '-') {
+			exp++
+		}
+		if exp < len(src) && isDigit(rune(src[exp])) {
+			lx.pos = exp
+			digits()
+		}
+	}
+}
+
+// scanQuoted reads a string literal. Interpolated expressions in double
+// quoted and backtick strings may contain quotes of their own, so {$...}
+// and ${...} are skipped as PHP code.
+func (lx *phpLexer) scanQuoted(quote byte) error {
+	start := lx.pos
+	lx.pos++
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		switch {
+		case c == '\\':
+			lx.pos += 2
+		case c == quote:
+			lx.pos++
+			return nil
+		case quote != '\'' && c == '{' && lx.pos+1 < len(lx.src) && lx.src[lx.pos+1] == '$':
+			if err := lx.skipInterpolation(); err != nil {
+				return err
+			}
+		case quote != '\'' && c == '$' && lx.pos+1 < len(lx.src) && lx.src[lx.pos+1] == '{':
+			lx.pos++
+			if err := lx.skipInterpolation(); err != nil {
+				return err
+			}
+		default:
+			lx.pos++
+		}
+	}
+	return lx.errorf(start, "unterminated string")
+}
+
+// skipInterpolation skips from a { to its matching }
+func (lx *phpLexer) skipInterpolation() error {
+	start := lx.pos
+	depth := 0
+	for {
+		tok, err := lx.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case tok.kind == phpEOF || tok.kind == phpCloseTag:
+			return lx.errorf(start, "unterminated string interpolation")
+		case tok.is("{"):
+			depth++
+		case tok.is("}"):
+			if depth--; depth == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// scanHeredoc reads a heredoc or nowdoc through its closing marker, which
+// since PHP 7.3 may be indented and followed by more code on its line. It
+// reports false when <<< does not start one.
+func (lx *phpLexer) scanHeredoc() (bool, error) {
+	src := lx.src
+	start := lx.pos
+	i := start + 3
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+		i++
+	}
+	quote := byte(0)
+	if i < len(src) && (src[i] == '\'' || src[i] == '"') {
+		quote = src[i]
+		i++
+	}
+	idStart := i
+	if i >= len(src) || !isPHPNameStart(src[i]) {
+		return false, nil
+	}
+	for i < len(src) && isPHPNameChar(src[i]) {
+		i++
+	}
+	id := src[idStart:i]
+	if quote != 0 {
+		if i >= len(src) || src[i] != quote {
+			return false, nil
+		}
+		i++
+	}
+	if strings.HasPrefix(src[i:], "\r\n") {
+		i += 2
+	} else if i < len(src) && src[i] == '\n' {
+		i++
+	} else {
+		return false, nil
+	}
+
+	for i <= len(src) {
+		j := i
+		for j < len(src) && (src[j] == ' ' || src[j] == '\t') {
+			j++
+		}
+		if strings.HasPrefix(src[j:], id) && (j+len(id) == len(src) || !isPHPNameChar(src[j+len(id)])) {
+			lx.pos = j + len(id)
+			return true, nil
+		}
+		nl := strings.IndexByte(src[i:], '\n')
+		if nl < 0 {
+			break
+		}
+		i += nl + 1
+	}
+	return true, lx.errorf(start, "unterminated heredoc; missing closing %s", id)
+}
+
+// castLength returns the length of the cast at offset, or 0 when the
+// parenthesis does not start one
+func (lx *phpLexer) castLength(offset int) int {
+	i := offset + 1
+	skip := func() {
+		for i < len(lx.src) && (lx.src[i] == ' ' || lx.src[i] == '\t') {
+			i++
+		}
+	}
+	skip()
+	wordStart := i
+	for i < len(lx.src) && isLetter(lx.src[i]) {
+		i++
+	}
+	word := strings.ToLower(lx.src[wordStart:i])
+	skip()
+	if !phpCasts[word] || i >= len(lx.src) || lx.src[i] != ')' {
+		return 0
+	}
+	return i + 1 - offset
+}
+
+// castName returns the normalized spelling of a cast token
+func castName(text string) string {
+	word := strings.ToLower(strings.Trim(text, "() \t"))
+	return "(" + word + ")"
+}
+
+func isPHPSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isPHPNameStart(c byte) bool {
+	return isLetter(c) || c == '_' || c >= 0x80
+}
+
+func isPHPNameChar(c byte) bool {
+	return isPHPNameStart(c) || isDigit(rune(c))
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(rune(c)) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func minifyPHPCode(code string, _ *FormatOptions) (string, error) {
+	tokens, err := tokenizePHP(code)
+	if err != nil {
+		return "", fmt.Errorf("invalid PHP syntax: %w", err)
+	}
+	return writeMinifiedPHP(tokens), nil
+}
+
+// isLegalPHPComment reports whether a block comment must survive
+// minification
+func isLegalPHPComment(tok phpToken) bool {
+	if tok.kind != phpBlockComment && tok.kind != phpDocComment {
+		return false
+	}
+	return strings.HasPrefix(tok.text, "/*!") ||
+		strings.Contains(tok.text, "@license") ||
+		strings.Contains(tok.text, "@preserve")
+}
+
+// writeMinifiedPHP prints tokens without comments and with a space only
+// where two tokens would otherwise lex differently. Inline HTML, heredocs
+// and the tags around PHP code are written exactly as they were.
+func writeMinifiedPHP(tokens []phpToken) string {
+	var out strings.Builder
+	prev := "" // last PHP token written since the opening tag
+	for i, tok := range tokens {
+		switch {
+		case tok.isComment() && !isLegalPHPComment(tok):
+			continue
+		case tok.kind == phpInlineHTML, tok.kind == phpCloseTag:
+			out.WriteString(tok.text)
+			prev = ""
+		case tok.kind == phpOpenTag:
+			// The tag needs whitespace after it to be recognized
+			out.WriteString(tok.text)
+			if i+1 < len(tokens) {
+				out.WriteByte(' ')
+			}
+			prev = ""
+		case tok.kind == phpOpenTagEcho:
+			out.WriteString(tok.text)
+			prev = ""
+		default:
+			if prev != "" && phpNeedsSpace(prev, tok.text) {
+				out.WriteByte(' ')
+			}
+			out.WriteString(tok.text)
+			prev = tok.text
+		}
+	}
+	return out.String()
+}
+
+// phpNeedsSpace reports whether tokens a and b written together would no
+// longer lex as a followed by b, as with - -1, 1 . 5, ? > or a keyword
+// before a qualified name
+func phpNeedsSpace(a, b string) bool {
+	lx := &phpLexer{src: a + b}
+	first, err := lx.next()
+	if err != nil || first.text != a {
+		return true
+	}
+	second, err := lx.next()
+	return err != nil || second.text != b
+}
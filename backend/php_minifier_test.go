@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMinifyPHP(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			"comments and URLs",
+			"<?php\n// comment\n$url = \"http://example.com/#anchor\"; # hash comment\n/* block */ echo $url;",
+			"<?php $url=\"http://example.com/#anchor\";echo$url;",
+		},
+		{"inline HTML", "<?php echo $a . ' x '; ?>\n<p>html   kept</p>", "<?php echo$a.' x ';?>\n<p>html   kept</p>"},
+		{"heredoc", "<?php\n$s = <<<EOT\nheredoc\nEOT;\necho $s;", "<?php $s=<<<EOT\nheredoc\nEOT;echo$s;"},
+		{"signs", "<?php\n$a = $b - -$c; $d = $e + ++$f; $g = $h?->i;", "<?php $a=$b- -$c;$d=$e+ ++$f;$g=$h?->i;"},
+		{"concatenated numbers", "<?php\n$a = 1 . 2; $b = 1 . .5; $c = $x . 2;", "<?php $a=1 . 2;$b=1 ..5;$c=$x. 2;"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Minify(tt.code, "php")
+			if err != nil {
+				t.Fatalf("Minify(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Minify(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			// The minified code reads as the same tokens
+			if got, want := phpCodeTokens(t, got), phpCodeTokens(t, tt.code); !reflect.DeepEqual(got, want) {
+				t.Errorf("minified tokens %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// phpCodeTokens returns the text of the tokens of PHP code other than
+// comments, with tags trimmed of the whitespace they take in
+func phpCodeTokens(t *testing.T, code string) []string {
+	tokens, err := tokenizePHP(code)
+	if err != nil {
+		t.Fatalf("tokenizePHP(%q) error: %v", code, err)
+	}
+	var texts []string
+	for _, tok := range tokens {
+		switch {
+		case tok.isComment():
+		case tok.kind == phpOpenTag || tok.kind == phpCloseTag:
+			texts = append(texts, strings.TrimSpace(tok.text))
+		default:
+			texts = append(texts, tok.text)
+		}
+	}
+	return texts
+}
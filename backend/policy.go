@@ -0,0 +1,645 @@
+package main
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Policy actions: what happens to a snippet a rule matches
+const (
+	policyBlock = "block"
+	policyWarn  = "warn"
+	policyOff   = "off"
+)
+
+// Rule severities
+const (
+	policyHigh   = "high"
+	policyMedium = "medium"
+)
+
+// policyAllRules stands for every rule when configuring actions
+const policyAllRules = "*"
+
+// policyTokenKind classifies the tokens policy rules match
+type policyTokenKind int
+
+const (
+	policyName   policyTokenKind = iota // identifier or keyword
+	policyString                        // string literal, quotes included
+	policyImport                        // string literal naming an imported module
+	policyPunct                         // operators and punctuation
+	policyOther                         // numbers, variables and the like
+)
+
+// policyToken is a token of code as a policy rule sees it. Comments are
+// left out, and strings are only ever seen whole, so text in them never
+// reads as code.
+type policyToken struct {
+	kind  policyTokenKind
+	text  string
+	start int
+	end   int
+	// language is set on tokens of code embedded in another language,
+	// such as the scripts of an HTML page
+	language string
+}
+
+// value returns the contents of a string token without its quotes
+func (t policyToken) value() string {
+	if unquoted, err := strconv.Unquote(t.text); err == nil {
+		return unquoted
+	}
+	if len(t.text) >= 2 {
+		return t.text[1 : len(t.text)-1]
+	}
+	return t.text
+}
+
+// isName reports whether the token is one of the names, in any case when
+// fold is set. A leading backslash, as in a fully qualified PHP function
+// name, is ignored.
+func (t policyToken) isName(fold bool, names ...string) bool {
+	if t.kind != policyName {
+		return false
+	}
+	text := strings.TrimPrefix(t.text, `\`)
+	for _, name := range names {
+		if text == name || fold && strings.EqualFold(text, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t policyToken) is(text string) bool {
+	return t.kind == policyPunct && t.text == text
+}
+
+// policyMatcher reports how many tokens from tokens[i] on a rule matches,
+// or 0 for none
+type policyMatcher func(tokens []policyToken, i int) int
+
+// policyRule is a suspicious code rule. Rules apply to the languages they
+// list, by backend name.
+type policyRule struct {
+	ID        string
+	Languages []string
+	Severity  string
+	// Action is what happens on a match unless the policy says otherwise
+	Action  string
+	Message string
+	match   policyMatcher
+}
+
+var (
+	jsPolicyLanguages  = []string{"JavaScript", "TypeScript", "TSX"}
+	phpPolicyLanguages = []string{"PHP"}
+	goPolicyLanguages  = []string{"Go"}
+	sqlPolicyLanguages = []string{"SQL"}
+)
+
+// policyRules are the built-in rules, in the order their matches are
+// reported at the same place
+var policyRules = []*policyRule{
+	{
+		ID:        "js-eval",
+		Languages: jsPolicyLanguages,
+		Severity:  policyHigh,
+		Action:    policyBlock,
+		Message:   "eval runs a string as code",
+		match:     policyCall(false, "eval"),
+	},
+	{
+		ID:        "js-function-constructor",
+		Languages: jsPolicyLanguages,
+		Severity:  policyHigh,
+		Action:    policyBlock,
+		Message:   "the Function constructor compiles a string as code",
+		match:     policyCall(false, "Function"),
+	},
+	{
+		ID:        "js-child-process",
+		Languages: jsPolicyLanguages,
+		Severity:  policyHigh,
+		Action:    policyBlock,
+		Message:   "child_process runs shell commands",
+		match:     policyImports("child_process", "node:child_process"),
+	},
+	{
+		ID:        "js-string-timer",
+		Languages: jsPolicyLanguages,
+		Severity:  policyMedium,
+		Action:    policyWarn,
+		Message:   "a timer given a string runs it as code",
+		match:     policyStringArgument(policyCall(false, "setTimeout", "setInterval")),
+	},
+	{
+		ID:        "php-eval",
+		Languages: phpPolicyLanguages,
+		Severity:  policyHigh,
+		Action:    policyBlock,
+		Message:   "eval runs a string as code",
+		match:     policyCall(true, "eval", "create_function"),
+	},
+	{
+		ID:        "php-shell",
+		Languages: phpPolicyLanguages,
+		Severity:  policyHigh,
+		Action:    policyBlock,
+		Message:   "the function runs shell commands",
+		match:     policyCall(true, "exec", "system", "shell_exec", "passthru", "proc_open", "popen", "pcntl_exec"),
+	},
+	{
+		ID:        "php-backtick",
+		Languages: phpPolicyLanguages,
+		Severity:  policyHigh,
+		Action:    policyBlock,
+		Message:   "backticks run a shell command",
+		match: func(tokens []policyToken, i int) int {
+			if tokens[i].kind == policyString && strings.HasPrefix(tokens[i].text, "`") {
+				return 1
+			}
+			return 0
+		},
+	},
+	{
+		ID:        "go-os-exec",
+		Languages: goPolicyLanguages,
+		Severity:  policyHigh,
+		Action:    policyBlock,
+		Message:   "os/exec runs external commands",
+		match:     policyImports("os/exec"),
+	},
+	{
+		ID:        "go-syscall-exec",
+		Languages: goPolicyLanguages,
+		Severity:  policyHigh,
+		Action:    policyBlock,
+		Message:   "the syscall package starts processes",
+		match:     policyMember("syscall", "Exec", "ForkExec", "StartProcess"),
+	},
+	{
+		ID:        "sql-shell",
+		Languages: sqlPolicyLanguages,
+		Severity:  policyHigh,
+		Action:    policyBlock,
+		Message:   "the statement runs shell commands",
+		match:     policyAny(policyWords("xp_cmdshell"), sqlCopyProgram),
+	},
+	{
+		ID:        "sql-file-access",
+		Languages: sqlPolicyLanguages,
+		Severity:  policyMedium,
+		Action:    policyWarn,
+		Message:   "the statement reads or writes files on the server",
+		match: policyAny(
+			policyCall(true, "load_file", "pg_read_file", "pg_read_binary_file", "pg_ls_dir"),
+			policyWords("into", "outfile"),
+			policyWords("into", "dumpfile"),
+		),
+	},
+}
+
+// policyMemberAccess are the operators that make a name a member rather
+// than a function of its own
+var policyMemberAccess = map[string]bool{".": true, "?.": true, "->": true, "?->": true, "::": true}
+
+// policyCall matches calls of the named functions. Methods with those
+// names and declarations of functions with them do not match.
+func policyCall(fold bool, names ...string) policyMatcher {
+	return func(tokens []policyToken, i int) int {
+		if !tokens[i].isName(fold, names...) || i+1 >= len(tokens) || !tokens[i+1].is("(") {
+			return 0
+		}
+		if i > 0 {
+			prev := tokens[i-1]
+			if prev.kind == policyPunct && policyMemberAccess[prev.text] || prev.isName(true, "function") {
+				return 0
+			}
+		}
+		return 2
+	}
+}
+
+// policyStringArgument matches what call matches when the first argument
+// is a string
+func policyStringArgument(call policyMatcher) policyMatcher {
+	return func(tokens []policyToken, i int) int {
+		n := call(tokens, i)
+		if n == 0 || i+n >= len(tokens) || tokens[i+n].kind != policyString {
+			return 0
+		}
+		return n + 1
+	}
+}
+
+// policyImports matches imports of the named modules
+func policyImports(modules ...string) policyMatcher {
+	return func(tokens []policyToken, i int) int {
+		if tokens[i].kind != policyImport {
+			return 0
+		}
+		for _, module := range modules {
+			if tokens[i].value() == module {
+				return 1
+			}
+		}
+		return 0
+	}
+}
+
+// policyMember matches object.name for any of the names
+func policyMember(object string, names ...string) policyMatcher {
+	return func(tokens []policyToken, i int) int {
+		if i+2 < len(tokens) && tokens[i].isName(false, object) && tokens[i+1].is(".") && tokens[i+2].isName(false, names...) {
+			return 3
+		}
+		return 0
+	}
+}
+
+// policyWords matches a sequence of words in any case
+func policyWords(words ...string) policyMatcher {
+	return func(tokens []policyToken, i int) int {
+		if i+len(words) > len(tokens) {
+			return 0
+		}
+		for j, word := range words {
+			if !tokens[i+j].isName(true, word) {
+				return 0
+			}
+		}
+		return len(words)
+	}
+}
+
+// policyAny matches what the first of matchers to match does
+func policyAny(matchers ...policyMatcher) policyMatcher {
+	return func(tokens []policyToken, i int) int {
+		for _, match := range matchers {
+			if n := match(tokens, i); n > 0 {
+				return n
+			}
+		}
+		return 0
+	}
+}
+
+// sqlCopyProgram matches the PostgreSQL COPY ... FROM PROGRAM and
+// TO PROGRAM forms
+func sqlCopyProgram(tokens []policyToken, i int) int {
+	if i+1 >= len(tokens) || !tokens[i].isName(true, "from", "to") || !tokens[i+1].isName(true, "program") {
+		return 0
+	}
+	for j := i - 1; j >= 0 && !tokens[j].is(";"); j-- {
+		if tokens[j].isName(true, "copy") {
+			return 2
+		}
+	}
+	return 0
+}
+
+// PolicyMatch reports a suspicious code rule matching a snippet. Lines
+// and columns are 1-based and columns count characters; the end position
+// is exclusive.
+type PolicyMatch struct {
+	Rule      string `json:"rule"`
+	Severity  string `json:"severity"`
+	Action    string `json:"action"`
+	Message   string `json:"message"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+}
+
+// Policy decides what happens to snippets matching the suspicious code
+// rules. A nil *Policy applies every rule's own action.
+type Policy struct {
+	actions map[string]string
+}
+
+// NewPolicy returns a policy overriding the actions of rules by ID with
+// block, warn or off. The ID * stands for every rule not named.
+func NewPolicy(actions map[string]string) (*Policy, error) {
+	known := map[string]bool{policyAllRules: true}
+	for _, rule := range policyRules {
+		known[rule.ID] = true
+	}
+	for id, action := range actions {
+		if !known[id] {
+			return nil, fmt.Errorf("unknown policy rule %q", id)
+		}
+		switch action {
+		case policyBlock, policyWarn, policyOff:
+		default:
+			return nil, fmt.Errorf("policy rule %s: action must be block, warn or off, not %q", id, action)
+		}
+	}
+	return &Policy{actions: actions}, nil
+}
+
+// action returns what happens when a rule matches
+func (p *Policy) action(rule *policyRule) string {
+	if p != nil {
+		if action, ok := p.actions[rule.ID]; ok {
+			return action
+		}
+		if action, ok := p.actions[policyAllRules]; ok {
+			return action
+		}
+	}
+	return rule.Action
+}
+
+// Check matches the rules for backend's language against code and
+// returns the matches, in the order they appear, along with the first
+// that blocks the code. Backends scan code they cannot parse with their
+// lexer alone, so only code that does not even lex goes unchecked, and
+// every operation on such code fails.
+func (p *Policy) Check(backend *LanguageBackend, code string, opts *FormatOptions) (matches []PolicyMatch, blocked *PolicyMatch) {
+	if backend == nil || backend.Scan == nil {
+		return nil, nil
+	}
+	tokens, err := backend.Scan(code, opts)
+	if err != nil {
+		return nil, nil
+	}
+	for i, tok := range tokens {
+		language := tok.language
+		if language == "" {
+			language = backend.Name
+		}
+		for _, rule := range policyRules {
+			action := p.action(rule)
+			if action == policyOff || !containsFold(rule.Languages, language) {
+				continue
+			}
+			n := rule.match(tokens, i)
+			if n == 0 {
+				continue
+			}
+			line, column := lineColumn(code, tok.start)
+			endLine, endColumn := lineColumn(code, tokens[i+n-1].end)
+			matches = append(matches, PolicyMatch{
+				Rule:      rule.ID,
+				Severity:  rule.Severity,
+				Action:    action,
+				Message:   rule.Message,
+				Line:      line,
+				Column:    column,
+				EndLine:   endLine,
+				EndColumn: endColumn,
+			})
+		}
+	}
+	for i := range matches {
+		if matches[i].Action == policyBlock {
+			return matches, &matches[i]
+		}
+	}
+	return matches, nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanJavaScript, scanTypeScript and scanTSX read the tokens of a program
+// as the parser does, so the text of JSX and the contents of regular
+// expressions and template literals are not taken for code. A program
+// the parser rejects is read by the lexer the minifier uses instead.
+func scanJavaScript(code string, _ *FormatOptions) ([]policyToken, error) {
+	return scanJSDialect(code, jsDialect{jsx: true})
+}
+
+func scanTypeScript(code string, _ *FormatOptions) ([]policyToken, error) {
+	return scanJSDialect(code, jsDialect{typescript: true})
+}
+
+func scanTSX(code string, _ *FormatOptions) ([]policyToken, error) {
+	return scanJSDialect(code, jsDialect{typescript: true, jsx: true})
+}
+
+func scanJSDialect(code string, dialect jsDialect) ([]policyToken, error) {
+	jsTokens, err := jsCodeTokens(code, dialect)
+	if err != nil {
+		lexed, lexErr := tokenizeJS(code)
+		if lexErr != nil {
+			return nil, err
+		}
+		jsTokens = jsTokens[:0]
+		for _, t := range lexed {
+			if !t.isComment() {
+				jsTokens = append(jsTokens, t)
+			}
+		}
+	}
+	// Backtracking may leave tokens of an abandoned reading behind
+	sort.SliceStable(jsTokens, func(i, j int) bool { return jsTokens[i].start < jsTokens[j].start })
+	tokens := make([]policyToken, 0, len(jsTokens))
+	end := 0
+	for _, t := range jsTokens {
+		if t.start < end {
+			continue
+		}
+		end = t.end
+		tok := policyToken{kind: policyOther, text: t.text, start: t.start, end: t.end}
+		switch t.kind {
+		case jsIdentifier, jsKeyword:
+			tok.kind = policyName
+		case jsPunctuator:
+			tok.kind = policyPunct
+		case jsString, jsTemplate, jsTemplateHead, jsTemplateMiddle, jsTemplateTail:
+			tok.kind = policyString
+			if t.kind == jsString && jsImportsString(tokens) {
+				tok.kind = policyImport
+			}
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
+
+// jsImportsString reports whether a string following tokens names a
+// module: import "m", from "m", require("m") or import("m")
+func jsImportsString(tokens []policyToken) bool {
+	n := len(tokens)
+	switch {
+	case n >= 1 && tokens[n-1].isName(false, "import", "from"):
+		return true
+	case n >= 2 && tokens[n-1].is("("):
+		return tokens[n-2].isName(false, "require", "import")
+	}
+	return false
+}
+
+// scanPHP reads the tokens of the PHP code in a file, leaving out the
+// HTML around it
+func scanPHP(code string, _ *FormatOptions) ([]policyToken, error) {
+	phpTokens, err := tokenizePHP(code)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []policyToken
+	for _, t := range phpTokens {
+		tok := policyToken{kind: policyOther, text: t.text, start: t.start, end: t.end}
+		switch t.kind {
+		case phpInlineHTML, phpOpenTag, phpOpenTagEcho, phpCloseTag:
+			continue
+		case phpName:
+			tok.kind = policyName
+		case phpPunct:
+			tok.kind = policyPunct
+		case phpString, phpHeredoc:
+			tok.kind = policyString
+		}
+		if t.isComment() {
+			continue
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
+
+// scanGoCode reads the tokens of Go code. The paths of imports are told
+// apart from other strings.
+func scanGoCode(code string, _ *FormatOptions) ([]policyToken, error) {
+	src := []byte(code)
+	fset := token.NewFileSet()
+	var errs scanner.ErrorList
+	var s scanner.Scanner
+	s.Init(fset.AddFile("", -1, len(src)), src, func(pos token.Position, msg string) { errs.Add(pos, msg) }, 0)
+
+	var tokens []policyToken
+	// importing is set in an import declaration, and grouped inside its
+	// parentheses
+	importing, grouped := false, false
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			if !grouped {
+				importing = false
+			}
+			continue
+		}
+		start := fset.Position(pos).Offset
+		text := lit
+		if text == "" {
+			text = tok.String()
+		}
+		t := policyToken{kind: policyOther, text: text, start: start, end: start + len(text)}
+		switch {
+		case tok == token.IDENT || tok.IsKeyword():
+			t.kind = policyName
+		case tok == token.STRING:
+			t.kind = policyString
+			if importing {
+				t.kind = policyImport
+			}
+		case tok.IsOperator():
+			t.kind = policyPunct
+		}
+		switch {
+		case tok == token.IMPORT:
+			importing = true
+		case importing && tok == token.LPAREN:
+			grouped = true
+		case importing && tok == token.RPAREN, importing && tok == token.SEMICOLON && !grouped:
+			importing, grouped = false, false
+		}
+		tokens = append(tokens, t)
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// scanSQL reads the tokens of SQL in the dialect the options name
+func scanSQL(code string, opts *FormatOptions) ([]policyToken, error) {
+	sqlTokens, err := tokenizeSQL(code, newSQLDialect(opts.dialect()))
+	if err != nil {
+		return nil, err
+	}
+	var tokens []policyToken
+	for _, t := range sqlTokens {
+		tok := policyToken{kind: policyOther, text: t.text, start: t.start, end: t.end}
+		switch t.kind {
+		case sqlLineComment, sqlBlockComment, sqlCommand:
+			continue
+		case sqlWord:
+			tok.kind = policyName
+		case sqlPunct, sqlDelimiter:
+			tok.kind = policyPunct
+			if t.kind == sqlDelimiter {
+				tok.text = ";"
+			}
+		case sqlString, sqlDollarString:
+			tok.kind = policyString
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
+
+// scanHTML reads the tokens of the scripts of an HTML page, in their own
+// languages
+func scanHTML(code string, opts *FormatOptions) ([]policyToken, error) {
+	root, err := parseHTML(code)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []policyToken
+	var visit func(node *htmlNode) error
+	visit = func(node *htmlNode) error {
+		if node.kind == htmlElement && node.name == "script" {
+			var scan ScanFunc
+			language := htmlEmbeddedLanguage(node)
+			switch language {
+			case "JavaScript":
+				scan = scanJavaScript
+			case "TypeScript":
+				scan = scanTypeScript
+			case "TSX":
+				scan = scanTSX
+			default:
+				return nil
+			}
+			scripts, err := scan(node.text, opts)
+			if err != nil {
+				return err
+			}
+			for _, t := range scripts {
+				t.start += node.inner
+				t.end += node.inner
+				t.language = language
+				tokens = append(tokens, t)
+			}
+			return nil
+		}
+		for _, child := range node.children {
+			if err := visit(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := visit(root); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
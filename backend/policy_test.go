@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPolicyChecksUnparseableCode(t *testing.T) {
+	tests := []struct {
+		name      string
+		language  string
+		code      string
+		operation string
+		want      string
+	}{
+		{"minify javascript", "JavaScript", "eval(x); if (", operationMinify, "Code blocked by policy rule js-eval at line 1, column 1"},
+		{"format javascript", "JavaScript", "eval(x)\nfoo bar", operationFormat, "Code blocked by policy rule js-eval at line 1, column 1"},
+		{"typescript", "TypeScript", "let a: number = 1\nnew Function(s) +", operationMinify, "Code blocked by policy rule js-function-constructor at line 2, column 5"},
+		{"tsx", "TSX", "const a = <b />\neval(x) +", operationFormat, "Code blocked by policy rule js-eval at line 2, column 1"},
+		{"html script", "HTML", "<p>x</p>\n<script>eval(x); if (</script>", operationMinify, "Code blocked by policy rule js-eval at line 2, column 9"},
+		{"string mentioning eval", "JavaScript", `"eval(x)"; if (`, operationMinify, ""},
+	}
+
+	h := &Handlers{}
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := h.processSnippet(f, &Request{Code: tt.code, Language: tt.language}, tt.operation)
+			if tt.want == "" {
+				if strings.Contains(response.Error, "policy") {
+					t.Errorf("%q blocked: %s", tt.code, response.Error)
+				}
+				return
+			}
+			if response.Success || !strings.HasPrefix(response.Error, tt.want) {
+				t.Errorf("%q: error %q, want %q", tt.code, response.Error, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FormatFunc formats source code for a single language. opts may be nil.
+type FormatFunc func(code string, opts *FormatOptions) (string, error)
+
+// MinifyFunc minifies source code for a single language. opts may be nil.
+type MinifyFunc func(code string, opts *FormatOptions) (string, error)
+
+// MinifyMapFunc minifies source code like a MinifyFunc while building the
+// source map the sourceMap option asks for. An inline map is appended to
+// the code; a separate one is returned as JSON. opts may be nil.
+type MinifyMapFunc func(code string, opts *FormatOptions) (minified, sourceMap string, err error)
+
+// DecodeFunc reads data in a language into plain values for conversion,
+// noting in log what the values cannot represent. opts may be nil.
+type DecodeFunc func(code string, opts *FormatOptions, log *conversionLog) (interface{}, error)
+
+// EncodeFunc writes plain values in a language, noting in log what the
+// language cannot represent. opts may be nil.
+type EncodeFunc func(value interface{}, opts *FormatOptions, log *conversionLog) (string, error)
+
+// TypesFunc declares types describing plain values, noting in log what
+// the types cannot describe. opts may be nil.
+type TypesFunc func(value interface{}, opts *FormatOptions, log *conversionLog) (string, error)
+
+// ScanFunc reads the tokens of code that the suspicious code policy
+// matches its rules against. opts may be nil.
+type ScanFunc func(code string, opts *FormatOptions) ([]policyToken, error)
+
+// LiteralsFunc returns the spans of formatted or minified code whose line
+// breaks are part of values, such as template literals, heredocs and block
+// scalars, in order. opts may be nil.
+type LiteralsFunc func(code string, opts *FormatOptions) []textSpan
+
+// DetectFunc scores how likely code is written in a language, from 0 for
+// certainly not to 1 for certainly
+type DetectFunc func(code string) float64
+
+// LanguageBackend describes a language the Formatter can process.
+// Format and Minify are optional; a nil function means the capability
+// is not supported by the backend.
+//
+// FormatSupports and MinifySupports name the options each operation
+// honors. The trailing newline and line ending options are applied to
+// every backend's output and need not be listed.
+//
+// MinifyMap is optional and is used instead of Minify when a source map
+// is asked for.
+//
+// Decode and Encode are optional and make data languages sources and
+// targets of conversions. ConvertSupports names the options they honor.
+//
+// Types is optional and makes the language a target of type generation.
+//
+// Scan is optional; the suspicious code policy does not check languages
+// without it.
+//
+// Literals is optional; the trailing newline and line ending options
+// leave the line breaks in the spans it returns alone.
+//
+// Detect is optional; backends without it are only detected by extension.
+type LanguageBackend struct {
+	Name            string
+	Aliases         []string
+	Extensions      []string
+	Format          FormatFunc
+	Minify          MinifyFunc
+	MinifyMap       MinifyMapFunc
+	FormatSupports  []string
+	MinifySupports  []string
+	Decode          DecodeFunc
+	Encode          EncodeFunc
+	ConvertSupports []string
+	Types           TypesFunc
+	Scan            ScanFunc
+	Literals        LiteralsFunc
+	Detect          DetectFunc
+}
+
+// CanFormat reports whether the backend supports formatting
+func (b *LanguageBackend) CanFormat() bool {
+	return b.Format != nil
+}
+
+// CanMinify reports whether the backend supports minification
+func (b *LanguageBackend) CanMinify() bool {
+	return b.Minify != nil
+}
+
+// CanConvert reports whether the backend can be converted from and to
+func (b *LanguageBackend) CanConvert() bool {
+	return b.Decode != nil && b.Encode != nil
+}
+
+// outputLiterals returns the literals of the backend's output when an
+// output option needs them
+func (b *LanguageBackend) outputLiterals(code string, opts *FormatOptions) []textSpan {
+	if b.Literals == nil || opts == nil || opts.TrailingNewline == nil && opts.LineEnding == "" {
+		return nil
+	}
+	return b.Literals(code, opts)
+}
+
+// Registry maps language names, aliases and file extensions to backends
+type Registry struct {
+	mu         sync.RWMutex
+	backends   map[string]*LanguageBackend
+	names      map[string]string
+	extensions map[string]string
+}
+
+// NewRegistry creates an empty language registry
+func NewRegistry() *Registry {
+	return &Registry{
+		backends:   make(map[string]*LanguageBackend),
+		names:      make(map[string]string),
+		extensions: make(map[string]string),
+	}
+}
+
+// Register adds a backend to the registry. Names, aliases and extensions
+// are matched case-insensitively and must not already be taken by another
+// backend.
+func (r *Registry) Register(backend *LanguageBackend) error {
+	if backend == nil || strings.TrimSpace(backend.Name) == "" {
+		return fmt.Errorf("language backend must have a name")
+	}
+	if backend.Format == nil && backend.Minify == nil && backend.Decode == nil && backend.Encode == nil && backend.Types == nil {
+		return fmt.Errorf("language backend %s has no capabilities", backend.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := normalizeLanguageKey(backend.Name)
+	if _, exists := r.backends[key]; exists {
+		return fmt.Errorf("language already registered: %s", backend.Name)
+	}
+
+	names := append([]string{backend.Name}, backend.Aliases...)
+	for _, name := range names {
+		if owner, exists := r.names[normalizeLanguageKey(name)]; exists {
+			return fmt.Errorf("language name %q already registered by %s", name, r.backends[owner].Name)
+		}
+	}
+	for _, ext := range backend.Extensions {
+		if owner, exists := r.extensions[normalizeExtension(ext)]; exists {
+			return fmt.Errorf("extension %q already registered by %s", ext, r.backends[owner].Name)
+		}
+	}
+
+	r.backends[key] = backend
+	for _, name := range names {
+		r.names[normalizeLanguageKey(name)] = key
+	}
+	for _, ext := range backend.Extensions {
+		r.extensions[normalizeExtension(ext)] = key
+	}
+
+	return nil
+}
+
+// Lookup finds a backend by its name or one of its aliases
+func (r *Registry) Lookup(name string) (*LanguageBackend, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.names[normalizeLanguageKey(name)]
+	if !ok {
+		return nil, false
+	}
+	return r.backends[key], true
+}
+
+// LookupExtension finds a backend by file extension, with or without the leading dot
+func (r *Registry) LookupExtension(ext string) (*LanguageBackend, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.extensions[normalizeExtension(ext)]
+	if !ok {
+		return nil, false
+	}
+	return r.backends[key], true
+}
+
+// Languages returns all registered backends sorted by name
+func (r *Registry) Languages() []*LanguageBackend {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	backends := make([]*LanguageBackend, 0, len(r.backends))
+	for _, backend := range r.backends {
+		backends = append(backends, backend)
+	}
+	sort.Slice(backends, func(i, j int) bool {
+		return backends[i].Name < backends[j].Name
+	})
+	return backends
+}
+
+func normalizeLanguageKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func normalizeExtension(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+}
+
+// defaultRegistry holds the built-in languages used by NewFormatter
+var defaultRegistry = newDefaultRegistry()
+
+// RegisterLanguage adds a backend to the default registry
+func RegisterLanguage(backend *LanguageBackend) error {
+	return defaultRegistry.Register(backend)
+}
+
+// jsFormatOptions are the options honored by the JavaScript printer
+var jsFormatOptions = []string{optionIndentSize, optionUseTabs, optionPrintWidth, optionQuoteStyle}
+
+// cssFormatOptions are the options honored by the style sheet formatter
+var cssFormatOptions = []string{optionIndentSize, optionUseTabs, optionSortDeclarations}
+
+// sourceMapOptions are the options honored by minifiers with a MinifyMap
+var sourceMapOptions = []string{optionSourceMap, optionSourceFile}
+
+// jsMinifyOptions are the options honored by the JavaScript minifier
+var jsMinifyOptions = []string{optionSourceMap, optionSourceFile, optionCompress, optionReserved}
+
+// htmlFormatOptions are the options honored by the HTML formatter, which
+// passes them on to the scripts and style sheets it contains
+var htmlFormatOptions = []string{optionIndentSize, optionUseTabs, optionPrintWidth, optionQuoteStyle, optionSortDeclarations}
+
+// htmlMinifyOptions are the options honored by the HTML minifier
+var htmlMinifyOptions = []string{optionCollapseWhitespace, optionRemoveComments, optionRemoveOptionalTags}
+
+// sqlFormatOptions are the options honored by the SQL formatter
+var sqlFormatOptions = []string{optionIndentSize, optionUseTabs, optionPrintWidth, optionDialect}
+
+func newDefaultRegistry() *Registry {
+	registry := NewRegistry()
+	// HTML hands <script> and <style> contents back to this registry
+	embedded := NewFormatterWithRegistry(registry)
+
+	builtins := []*LanguageBackend{
+		{
+			Name:       "Go",
+			Aliases:    []string{"golang"},
+			Extensions: []string{".go"},
+			// gofmt output is canonical, so no layout options apply
+			Format:         formatGoCode,
+			Minify:         minifyGoCode,
+			FormatSupports: []string{optionOrganizeImports, optionStrict, optionModernize},
+			MinifySupports: []string{optionJoinStatements},
+			Types:          goTypes,
+			Scan:           scanGoCode,
+			Literals:       goLiterals,
+			Detect:         detectGo,
+		},
+		{
+			Name:           "JSON",
+			Extensions:     []string{".json"},
+			Format:         formatJSONCode,
+			Minify:         minifyJSONCode,
+			FormatSupports: []string{optionIndentSize, optionUseTabs},
+			Decode:         decodeJSONCode,
+			Encode:         encodeJSON,
+			Detect:         detectJSON,
+		},
+		{
+			Name:           "PHP",
+			Extensions:     []string{".php", ".phtml"},
+			Format:         formatPHPCode,
+			Minify:         minifyPHPCode,
+			FormatSupports: []string{optionIndentSize, optionUseTabs},
+			Scan:           scanPHP,
+			Literals:       phpLiterals,
+			Detect:         detectPHP,
+		},
+		{
+			Name:           "JavaScript",
+			Aliases:        []string{"JS"},
+			Extensions:     []string{".js", ".mjs", ".cjs"},
+			Format:         formatJavaScriptCode,
+			Minify:         minifyJavaScriptCode,
+			MinifyMap:      minifyJavaScriptMapped,
+			FormatSupports: jsFormatOptions,
+			MinifySupports: jsMinifyOptions,
+			Scan:           scanJavaScript,
+			Literals:       jsLiterals,
+			Detect:         detectJavaScript,
+		},
+		{
+			Name:           "TypeScript",
+			Aliases:        []string{"TS"},
+			Extensions:     []string{".ts", ".mts", ".cts"},
+			Format:         formatTypeScriptCode,
+			FormatSupports: jsFormatOptions,
+			// Type annotations are ordinary tokens to the minifier
+			Minify:         minifyTypeScriptCode,
+			MinifyMap:      minifyTypeScriptMapped,
+			MinifySupports: sourceMapOptions,
+			Types:          tsTypes,
+			Scan:           scanTypeScript,
+			Literals:       tsLiterals,
+			Detect:         detectTypeScript,
+		},
+		{
+			// JSX text is not JavaScript tokens, so TSX is format-only
+			Name:           "TSX",
+			Extensions:     []string{".tsx"},
+			Format:         formatTSXCode,
+			FormatSupports: jsFormatOptions,
+			Scan:           scanTSX,
+			Literals:       tsxLiterals,
+			Detect:         detectTSX,
+		},
+		{
+			Name:           "CSS",
+			Extensions:     []string{".css"},
+			Format:         formatCSSCode,
+			Minify:         minifyCSSCode,
+			MinifyMap:      minifyCSSMapped,
+			FormatSupports: cssFormatOptions,
+			MinifySupports: sourceMapOptions,
+			Detect:         detectCSS,
+		},
+		{
+			Name:           "SCSS",
+			Extensions:     []string{".scss"},
+			Format:         formatSCSSCode,
+			Minify:         minifySCSSCode,
+			MinifyMap:      minifySCSSMapped,
+			FormatSupports: cssFormatOptions,
+			MinifySupports: sourceMapOptions,
+			Detect:         detectSCSS,
+		},
+		{
+			Name:           "Less",
+			Extensions:     []string{".less"},
+			Format:         formatLessCode,
+			Minify:         minifyLessCode,
+			MinifyMap:      minifyLessMapped,
+			FormatSupports: cssFormatOptions,
+			MinifySupports: sourceMapOptions,
+			Detect:         detectLess,
+		},
+		{
+			Name:           "HTML",
+			Extensions:     []string{".html", ".htm"},
+			Format:         formatHTMLCode(embedded),
+			Minify:         minifyHTMLCode(embedded),
+			FormatSupports: htmlFormatOptions,
+			MinifySupports: htmlMinifyOptions,
+			Scan:           scanHTML,
+			Detect:         detectHTML,
+		},
+		{
+			Name:           "SQL",
+			Extensions:     []string{".sql"},
+			Format:         formatSQLCode,
+			Minify:         minifySQLCode,
+			FormatSupports: sqlFormatOptions,
+			MinifySupports: []string{optionDialect},
+			Scan:           scanSQL,
+			Literals:       sqlLiterals,
+			Detect:         detectSQL,
+		},
+		{
+			Name:       "YAML",
+			Aliases:    []string{"yml"},
+			Extensions: []string{".yaml", ".yml"},
+			Format:     formatYAMLCode,
+			// YAML is indented with spaces only
+			FormatSupports: []string{optionIndentSize},
+			Decode:         decodeYAMLCode,
+			Encode:         encodeYAML,
+			Literals:       yamlLiterals,
+			Detect:         detectYAML,
+		},
+		{
+			Name:           "TOML",
+			Extensions:     []string{".toml"},
+			Format:         formatTOMLCode,
+			FormatSupports: []string{optionIndentSize, optionUseTabs},
+			Decode:         decodeTOMLCode,
+			Encode:         encodeTOML,
+			Detect:         detectTOML,
+		},
+		{
+			Name:            "XML",
+			Extensions:      []string{".xml", ".svg", ".xsd", ".xsl", ".xslt", ".plist"},
+			Format:          formatXMLCode,
+			FormatSupports:  []string{optionIndentSize, optionUseTabs, optionPrintWidth},
+			Decode:          decodeXMLCode,
+			Encode:          encodeXML,
+			ConvertSupports: []string{optionAttributePrefix, optionTextKey},
+			Detect:          detectXML,
+		},
+		{
+			// Any text is a CSV row, so CSV is only detected by extension
+			Name:            "CSV",
+			Extensions:      []string{".csv"},
+			Format:          formatCSVCode,
+			Decode:          decodeCSVCode,
+			Encode:          encodeCSV,
+			ConvertSupports: []string{optionHeader},
+		},
+	}
+
+	for _, backend := range builtins {
+		if err := registry.Register(backend); err != nil {
+			panic(err)
+		}
+	}
+
+	return registry
+}
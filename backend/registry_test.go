@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func upperFormat(code string, _ *FormatOptions) (string, error) {
+	return strings.ToUpper(code), nil
+}
+
+func TestRegistryRegister(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend *LanguageBackend
+		wantErr string
+	}{
+		{"no name", &LanguageBackend{Format: upperFormat}, "language backend must have a name"},
+		{"no capabilities", &LanguageBackend{Name: "Empty"}, "language backend Empty has no capabilities"},
+		{"taken name", &LanguageBackend{Name: "shout", Format: upperFormat}, "language already registered: shout"},
+		{"taken alias", &LanguageBackend{Name: "Yell", Aliases: []string{"SH"}, Format: upperFormat}, `language name "SH" already registered by Shout`},
+		{"taken extension", &LanguageBackend{Name: "Yell", Extensions: []string{"SHOUT"}, Format: upperFormat}, `extension "SHOUT" already registered by Shout`},
+		{"new language", &LanguageBackend{Name: "Yell", Extensions: []string{".yell"}, Format: upperFormat}, ""},
+	}
+
+	r := NewRegistry()
+	if err := r.Register(&LanguageBackend{Name: "Shout", Aliases: []string{"sh"}, Extensions: []string{".shout"}, Format: upperFormat}); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.Register(tt.backend)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Register error: %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("Register error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	tests := []struct {
+		name      string
+		lookup    func(r *Registry) (*LanguageBackend, bool)
+		wantFound string
+	}{
+		{"name", func(r *Registry) (*LanguageBackend, bool) { return r.Lookup("JavaScript") }, "JavaScript"},
+		{"name in other case", func(r *Registry) (*LanguageBackend, bool) { return r.Lookup(" javascript ") }, "JavaScript"},
+		{"alias", func(r *Registry) (*LanguageBackend, bool) { return r.Lookup("ts") }, "TypeScript"},
+		{"unknown name", func(r *Registry) (*LanguageBackend, bool) { return r.Lookup("cobol") }, ""},
+		{"extension", func(r *Registry) (*LanguageBackend, bool) { return r.LookupExtension(".mjs") }, "JavaScript"},
+		{"extension without dot", func(r *Registry) (*LanguageBackend, bool) { return r.LookupExtension("YML") }, "YAML"},
+		{"unknown extension", func(r *Registry) (*LanguageBackend, bool) { return r.LookupExtension(".cob") }, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, ok := tt.lookup(defaultRegistry)
+			switch {
+			case tt.wantFound == "" && ok:
+				t.Errorf("found %s, want none", backend.Name)
+			case tt.wantFound != "" && (!ok || backend.Name != tt.wantFound):
+				t.Errorf("found %v, want %s", backend, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestFormatterDispatch(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register(&LanguageBackend{Name: "Shout", Format: upperFormat}); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	f := NewFormatterWithRegistry(r)
+
+	tests := []struct {
+		name     string
+		language string
+		code     string
+		minify   bool
+		want     string
+		wantErr  string
+	}{
+		{"format", "shout", "hi", false, "HI", ""},
+		{"minify unsupported", "Shout", "hi", true, "", "minification is not supported for Shout"},
+		{"unknown language", "go", "package p", false, "", "unsupported language: go"},
+		{"empty code", "Shout", "", false, "", "code cannot be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := f.Format
+			if tt.minify {
+				run = f.Minify
+			}
+			got, err := run(tt.code, tt.language)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
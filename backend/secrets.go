@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SecretPattern describes a kind of credential. Patterns can be loaded
+// from JSON to extend the built-in ones.
+type SecretPattern struct {
+	ID          string `json:"id"`
+	Set         string `json:"set,omitempty"`
+	Description string `json:"description"`
+	Pattern     string `json:"pattern"`
+	// Group is the submatch holding the secret itself, or 0 for the whole
+	// match
+	Group int `json:"group,omitempty"`
+	// MinEntropy is the Shannon entropy, in bits per character, below
+	// which a value is taken for a made-up example rather than a secret
+	MinEntropy float64 `json:"minEntropy,omitempty"`
+}
+
+// Built-in pattern sets
+const (
+	secretSetCloud     = "cloud"
+	secretSetTokens    = "tokens"
+	secretSetKeys      = "keys"
+	secretSetDatabases = "databases"
+	secretSetGeneric   = "generic"
+)
+
+// secretPatterns are the built-in patterns. Where matches overlap, the
+// pattern listed first wins, so the generic ones come last.
+var secretPatterns = []SecretPattern{
+	{
+		ID:          "private-key",
+		Set:         secretSetKeys,
+		Description: "private key",
+		Pattern:     `-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----[\s\S]*?-----END (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----`,
+	},
+	{
+		ID:          "aws-access-key-id",
+		Set:         secretSetCloud,
+		Description: "AWS access key ID",
+		Pattern:     `\b(?:AKIA|ASIA|ABIA|ACCA|A3T[A-Z0-9])[A-Z0-9]{16}\b`,
+	},
+	{
+		ID:          "aws-secret-access-key",
+		Set:         secretSetCloud,
+		Description: "AWS secret access key",
+		Pattern:     `(?i)aws.{0,20}?(?:secret|sk).{0,20}?["']?\s*(?::=|=>|[:=])\s*["']?([A-Za-z0-9/+=]{40})(?:[^A-Za-z0-9/+=]|$)`,
+		Group:       1,
+		MinEntropy:  4,
+	},
+	{
+		ID:          "google-api-key",
+		Set:         secretSetCloud,
+		Description: "Google API key",
+		Pattern:     `\bAIza[0-9A-Za-z_-]{35}\b`,
+	},
+	{
+		ID:          "jwt",
+		Set:         secretSetTokens,
+		Description: "JSON Web Token",
+		Pattern:     `\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`,
+	},
+	{
+		ID:          "github-token",
+		Set:         secretSetTokens,
+		Description: "GitHub token",
+		Pattern:     `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{60,})\b`,
+	},
+	{
+		ID:          "slack-token",
+		Set:         secretSetTokens,
+		Description: "Slack token",
+		Pattern:     `\bxox[abposr]-[A-Za-z0-9-]{10,}`,
+	},
+	{
+		ID:          "stripe-key",
+		Set:         secretSetTokens,
+		Description: "Stripe secret key",
+		Pattern:     `\b(?:sk|rk)_live_[A-Za-z0-9]{24,}\b`,
+	},
+	{
+		ID:          "database-url",
+		Set:         secretSetDatabases,
+		Description: "password in a database URL",
+		Pattern:     `(?i)\b(?:postgres(?:ql)?|mysql|mariadb|mongodb(?:\+srv)?|rediss?|amqps?|mssql|sqlserver)://[^\s:@/'"]*:([^\s@/'"]+)@`,
+		Group:       1,
+	},
+	{
+		ID:          "generic-secret",
+		Set:         secretSetGeneric,
+		Description: "secret assigned to a key such as password or token",
+		Pattern:     `(?im)[A-Za-z0-9_.-]*(?:secret|token|passw(?:or)?d|pwd|api[_-]?key|access[_-]?key|auth[_-]?key|credential)s?[A-Za-z0-9_.-]*["']?\s*(?::=|=>|[:=])\s*["']?([A-Za-z0-9+/=_.~-]{12,})(?:["'\s,;)]|$)`,
+		Group:       1,
+		MinEntropy:  3.5,
+	},
+}
+
+// secretPatternsFor returns the built-in patterns in the named sets or
+// with the given IDs, or all of them when names is empty
+func secretPatternsFor(names []string) ([]SecretPattern, error) {
+	if len(names) == 0 {
+		return secretPatterns, nil
+	}
+	var patterns []SecretPattern
+	for _, name := range names {
+		found := false
+		for _, p := range secretPatterns {
+			if p.Set == name || p.ID == name {
+				patterns = append(patterns, p)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown secret pattern or set %q", name)
+		}
+	}
+	return patterns, nil
+}
+
+// LoadSecretPatterns reads patterns from a JSON file holding an array
+func LoadSecretPatterns(path string) ([]SecretPattern, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var patterns []SecretPattern
+	if err := json.Unmarshal(data, &patterns); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return patterns, nil
+}
+
+// compiledSecretPattern is a pattern ready to match
+type compiledSecretPattern struct {
+	SecretPattern
+	re *regexp.Regexp
+}
+
+// SecretScanner finds credentials in code by pattern and entropy. A nil
+// *SecretScanner finds nothing.
+type SecretScanner struct {
+	patterns []compiledSecretPattern
+}
+
+// NewSecretScanner returns a scanner for the built-in patterns in the
+// named sets or with the given IDs, all of them when names is empty,
+// followed by the extra patterns
+func NewSecretScanner(names []string, extra []SecretPattern) (*SecretScanner, error) {
+	patterns, err := secretPatternsFor(names)
+	if err != nil {
+		return nil, err
+	}
+	s := &SecretScanner{}
+	for _, p := range append(patterns[:len(patterns):len(patterns)], extra...) {
+		if p.ID == "" {
+			return nil, fmt.Errorf("secret pattern %q has no id", p.Pattern)
+		}
+		if p.Description == "" {
+			p.Description = p.ID
+		}
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("secret pattern %s: %v", p.ID, err)
+		}
+		if p.Group < 0 || p.Group > re.NumSubexp() {
+			return nil, fmt.Errorf("secret pattern %s: no group %d", p.ID, p.Group)
+		}
+		s.patterns = append(s.patterns, compiledSecretPattern{p, re})
+	}
+	return s, nil
+}
+
+// SecretFinding reports a credential found in a snippet. The value itself
+// is never reported. Lines and columns are 1-based and columns count
+// characters; the end position is exclusive.
+type SecretFinding struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	EndLine     int    `json:"endLine"`
+	EndColumn   int    `json:"endColumn"`
+	// start and end are the byte offsets of the value
+	start, end int
+}
+
+// Find returns the credentials in code in the order they appear
+func (s *SecretScanner) Find(code string) []SecretFinding {
+	if s == nil {
+		return nil
+	}
+	var findings []SecretFinding
+	overlaps := func(start, end int) bool {
+		for _, f := range findings {
+			if start < f.end && f.start < end {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range s.patterns {
+		for _, m := range p.re.FindAllStringSubmatchIndex(code, -1) {
+			start, end := m[2*p.Group], m[2*p.Group+1]
+			if start < 0 || start == end || overlaps(start, end) {
+				continue
+			}
+			value := code[start:end]
+			if secretPlaceholder(value) || p.MinEntropy > 0 && (shannonEntropy(value) < p.MinEntropy || !secretMixed(value)) {
+				continue
+			}
+			line, column := lineColumn(code, start)
+			endLine, endColumn := lineColumn(code, end)
+			findings = append(findings, SecretFinding{
+				Type:        p.ID,
+				Description: p.Description,
+				Line:        line,
+				Column:      column,
+				EndLine:     endLine,
+				EndColumn:   endColumn,
+				start:       start,
+				end:         end,
+			})
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool { return findings[i].start < findings[j].start })
+	return findings
+}
+
+// redactSecrets replaces the values found in code with placeholders such
+// as REDACTED_AWS_ACCESS_KEY_ID, which fit anywhere the values did:
+// strings, comments and bare values alike
+func redactSecrets(code string, findings []SecretFinding) string {
+	var b strings.Builder
+	last := 0
+	for _, f := range findings {
+		b.WriteString(code[last:f.start])
+		b.WriteString(secretPlaceholderFor(f.Type))
+		last = f.end
+	}
+	b.WriteString(code[last:])
+	return b.String()
+}
+
+func secretPlaceholderFor(id string) string {
+	return "REDACTED_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(id))
+}
+
+// secretPlaceholder reports whether a value stands in for a secret: a
+// reference to a variable or template parameter, a mask, or a value
+// already redacted
+func secretPlaceholder(value string) bool {
+	if strings.HasPrefix(value, "REDACTED_") || strings.ContainsAny(value[:1], "$%{<") {
+		return true
+	}
+	return strings.Trim(value, "*xX.") == ""
+}
+
+// secretMixed reports whether a value mixes letters and digits, as
+// generated secrets do and words and identifiers do not
+func secretMixed(value string) bool {
+	return strings.ContainsAny(value, "0123456789") && strings.IndexFunc(value, func(r rune) bool {
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+	}) >= 0
+}
+
+// shannonEntropy returns the entropy of a string in bits per byte
+func shannonEntropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	entropy := 0.0
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(len(s))
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
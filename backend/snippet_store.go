@@ -0,0 +1,287 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Snippet is a saved snippet, shared by its ID
+type Snippet struct {
+	ID            string     `json:"id"`
+	Code          string     `json:"code"`
+	Language      string     `json:"language,omitempty"`
+	Operation     string     `json:"operation"`
+	Output        string     `json:"output"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+	BurnAfterRead bool       `json:"burnAfterRead,omitempty"`
+	// DeleteTokenHash is the SHA-256 of the token that deletes the
+	// snippet. Only its creator is given the token itself.
+	DeleteTokenHash string `json:"-"`
+}
+
+// expired reports whether the snippet is past its expiry at now
+func (s *Snippet) expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+// deletableWith reports whether token is the snippet's delete token
+func (s *Snippet) deletableWith(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(hashDeleteToken(token)), []byte(s.DeleteTokenHash)) == 1
+}
+
+var (
+	errSnippetNotFound = errors.New("snippet not found")
+	errSnippetExists   = errors.New("snippet already exists")
+)
+
+// SnippetStore persists snippets. Implementations are safe for concurrent
+// use, and Delete succeeds for only one of several concurrent callers, so
+// a burn-after-read snippet is read once.
+type SnippetStore interface {
+	// Create saves a new snippet, failing with errSnippetExists when its
+	// ID is taken
+	Create(snippet *Snippet) error
+	// Get returns a snippet, expired or not, or errSnippetNotFound
+	Get(id string) (*Snippet, error)
+	// Delete removes a snippet, failing with errSnippetNotFound when it
+	// is already gone
+	Delete(id string) error
+	// DeleteExpired removes the snippets expired at now and returns how
+	// many there were
+	DeleteExpired(now time.Time) (int, error)
+	Close() error
+}
+
+// Snippet store types
+const (
+	snippetStoreFile = "file"
+	snippetStoreSQL  = "sql"
+	snippetStoreNone = "none"
+)
+
+// NewSnippetStore opens the store the configuration names, or returns nil
+// when snippet storage is disabled
+func NewSnippetStore(config SnippetsConfig) (SnippetStore, error) {
+	switch strings.ToLower(config.Store) {
+	case snippetStoreFile:
+		return NewFileSnippetStore(config.Dir)
+	case snippetStoreSQL:
+		return NewSQLSnippetStore(config.Driver, config.DSN)
+	case snippetStoreNone, "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown snippet store %q", config.Store)
+	}
+}
+
+// purgeExpiredSnippets deletes the expired snippets from store every
+// interval. Expired snippets are never served in between.
+func purgeExpiredSnippets(store SnippetStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := store.DeleteExpired(time.Now())
+		if err != nil {
+			log.Printf("Failed to delete expired snippets: %v", err)
+		} else if n > 0 {
+			log.Printf("Deleted %d expired snippets", n)
+		}
+	}
+}
+
+// Lengths of snippet IDs and delete tokens. IDs are short enough to share
+// and long enough not to be guessed.
+const (
+	snippetIDLength          = 10
+	snippetDeleteTokenLength = 32
+)
+
+const snippetAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// randomSnippetString returns n characters of snippetAlphabet chosen
+// uniformly at random
+func randomSnippetString(n int) (string, error) {
+	b := make([]byte, 0, n)
+	buf := make([]byte, n+n/2)
+	for len(b) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, c := range buf {
+			// 248 is the largest multiple of 62 a byte holds, so every
+			// character is equally likely
+			if c < 248 && len(b) < n {
+				b = append(b, snippetAlphabet[c%62])
+			}
+		}
+	}
+	return string(b), nil
+}
+
+// validSnippetID reports whether id could have been made by
+// randomSnippetString, so it is safe to use in a path or query
+func validSnippetID(id string) bool {
+	if len(id) != snippetIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if strings.IndexByte(snippetAlphabet, id[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func hashDeleteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// FileSnippetStore keeps each snippet in a JSON file named by its ID. It
+// relies on the file system for atomicity, so several servers can share
+// a directory.
+type FileSnippetStore struct {
+	dir string
+}
+
+// fileSnippet is a snippet as stored in its file
+type fileSnippet struct {
+	Snippet
+	DeleteTokenHash string `json:"deleteTokenHash"`
+}
+
+// NewFileSnippetStore returns a store in dir, creating it if needed
+func NewFileSnippetStore(dir string) (*FileSnippetStore, error) {
+	if dir == "" {
+		return nil, errors.New("no snippet directory")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileSnippetStore{dir: dir}, nil
+}
+
+func (s *FileSnippetStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// Create writes the snippet to a temporary file and links it into place,
+// so readers never see a partial file and an existing snippet is never
+// overwritten
+func (s *FileSnippetStore) Create(snippet *Snippet) error {
+	if !validSnippetID(snippet.ID) {
+		return fmt.Errorf("invalid snippet ID %q", snippet.ID)
+	}
+	data, err := json.Marshal(fileSnippet{*snippet, snippet.DeleteTokenHash})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".snippet-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Link(tmp.Name(), s.path(snippet.ID)); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return errSnippetExists
+		}
+		return err
+	}
+	return nil
+}
+
+// Get reads a snippet from its file
+func (s *FileSnippetStore) Get(id string) (*Snippet, error) {
+	if !validSnippetID(id) {
+		return nil, errSnippetNotFound
+	}
+	return s.read(s.path(id))
+}
+
+func (s *FileSnippetStore) read(path string) (*Snippet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errSnippetNotFound
+		}
+		return nil, err
+	}
+	var stored fileSnippet
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	snippet := stored.Snippet
+	snippet.DeleteTokenHash = stored.DeleteTokenHash
+	return &snippet, nil
+}
+
+// Delete removes a snippet's file
+func (s *FileSnippetStore) Delete(id string) error {
+	if !validSnippetID(id) {
+		return errSnippetNotFound
+	}
+	if err := os.Remove(s.path(id)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return errSnippetNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteExpired reads every snippet to find the expired ones
+func (s *FileSnippetStore) DeleteExpired(now time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !validSnippetID(id) {
+			continue
+		}
+		snippet, err := s.read(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			if errors.Is(err, errSnippetNotFound) {
+				continue
+			}
+			return deleted, err
+		}
+		if !snippet.expired(now) {
+			continue
+		}
+		if err := s.Delete(id); err == nil {
+			deleted++
+		} else if !errors.Is(err, errSnippetNotFound) {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// Close does nothing, as no files are held open
+func (s *FileSnippetStore) Close() error {
+	return nil
+}
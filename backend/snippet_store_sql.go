@@ -0,0 +1,160 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// The sqlite driver is pure Go, so the binary still builds without cgo
+	_ "modernc.org/sqlite"
+)
+
+// SQLSnippetStore keeps snippets in a table of a SQL database. Its queries
+// are written for SQLite and use nothing beyond what MySQL and compatible
+// databases accept. The sqlite driver is built in; others must be
+// registered under the configured driver name by a file of their own.
+type SQLSnippetStore struct {
+	db *sql.DB
+}
+
+// sqlSnippetSchema creates the snippets table. Times are Unix seconds.
+const sqlSnippetSchema = `CREATE TABLE IF NOT EXISTS snippets (
+	id VARCHAR(16) PRIMARY KEY,
+	code TEXT NOT NULL,
+	language VARCHAR(64) NOT NULL,
+	operation VARCHAR(16) NOT NULL,
+	output TEXT NOT NULL,
+	created_at BIGINT NOT NULL,
+	expires_at BIGINT,
+	burn_after_read BOOLEAN NOT NULL,
+	delete_token_hash CHAR(64) NOT NULL
+)`
+
+// NewSQLSnippetStore connects to the database and creates the snippets
+// table if it does not exist
+func NewSQLSnippetStore(driver, dsn string) (*SQLSnippetStore, error) {
+	if path := sqliteFilePath(driver, dsn); path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if driver == "sqlite" {
+		// SQLite takes one writer at a time and fails the others with
+		// SQLITE_BUSY, so statements wait for a single connection instead
+		db.SetMaxOpenConns(1)
+	}
+	if _, err := db.Exec(sqlSnippetSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLSnippetStore{db: db}, nil
+}
+
+// sqliteFilePath returns the database file a SQLite DSN opens, or "" for
+// other drivers and in-memory databases
+func sqliteFilePath(driver, dsn string) string {
+	if driver != "sqlite" {
+		return ""
+	}
+	path := strings.TrimPrefix(dsn, "file:")
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		if strings.Contains(path[i:], "mode=memory") {
+			return ""
+		}
+		path = path[:i]
+	}
+	if path == "" || path == ":memory:" {
+		return ""
+	}
+	return path
+}
+
+// Create inserts a snippet. Drivers report duplicate keys differently, so
+// a failed insert is put down to a taken ID when the ID turns out to exist.
+func (s *SQLSnippetStore) Create(snippet *Snippet) error {
+	var expiresAt sql.NullInt64
+	if snippet.ExpiresAt != nil {
+		expiresAt = sql.NullInt64{Int64: snippet.ExpiresAt.Unix(), Valid: true}
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO snippets (id, code, language, operation, output, created_at, expires_at, burn_after_read, delete_token_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		snippet.ID, snippet.Code, snippet.Language, snippet.Operation, snippet.Output,
+		snippet.CreatedAt.Unix(), expiresAt, snippet.BurnAfterRead, snippet.DeleteTokenHash,
+	)
+	if err != nil {
+		var exists int
+		if s.db.QueryRow(`SELECT 1 FROM snippets WHERE id = ?`, snippet.ID).Scan(&exists) == nil {
+			return errSnippetExists
+		}
+		return err
+	}
+	return nil
+}
+
+// Get selects a snippet by ID
+func (s *SQLSnippetStore) Get(id string) (*Snippet, error) {
+	var (
+		snippet   Snippet
+		createdAt int64
+		expiresAt sql.NullInt64
+	)
+	err := s.db.QueryRow(
+		`SELECT id, code, language, operation, output, created_at, expires_at, burn_after_read, delete_token_hash
+		FROM snippets WHERE id = ?`, id,
+	).Scan(
+		&snippet.ID, &snippet.Code, &snippet.Language, &snippet.Operation, &snippet.Output,
+		&createdAt, &expiresAt, &snippet.BurnAfterRead, &snippet.DeleteTokenHash,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errSnippetNotFound
+		}
+		return nil, err
+	}
+	snippet.CreatedAt = time.Unix(createdAt, 0).UTC()
+	if expiresAt.Valid {
+		t := time.Unix(expiresAt.Int64, 0).UTC()
+		snippet.ExpiresAt = &t
+	}
+	return &snippet, nil
+}
+
+// Delete deletes a snippet's row. Only the caller whose statement deletes
+// the row succeeds.
+func (s *SQLSnippetStore) Delete(id string) error {
+	result, err := s.db.Exec(`DELETE FROM snippets WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errSnippetNotFound
+	}
+	return nil
+}
+
+// DeleteExpired deletes the rows expired at now
+func (s *SQLSnippetStore) DeleteExpired(now time.Time) (int, error) {
+	result, err := s.db.Exec(`DELETE FROM snippets WHERE expires_at IS NOT NULL AND expires_at <= ?`, now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// Close closes the database
+func (s *SQLSnippetStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestSnippetStores opens an empty store of every type, each with the
+// configuration's default driver where it has one
+func newTestSnippetStores(t *testing.T) map[string]SnippetStore {
+	dir := t.TempDir()
+	configs := map[string]SnippetsConfig{
+		snippetStoreFile: {Store: snippetStoreFile, Dir: filepath.Join(dir, "files")},
+		snippetStoreSQL:  {Store: snippetStoreSQL, Driver: LoadConfig().Snippets.Driver, DSN: filepath.Join(dir, "data", "snippets.db")},
+	}
+	stores := make(map[string]SnippetStore)
+	for name, config := range configs {
+		store, err := NewSnippetStore(config)
+		if err != nil {
+			t.Fatalf("open %s store: %v", name, err)
+		}
+		t.Cleanup(func() { store.Close() })
+		stores[name] = store
+	}
+	return stores
+}
+
+func TestSnippetStoreDisabledByDefault(t *testing.T) {
+	t.Setenv("SNIPPET_STORE", "")
+	store, err := NewSnippetStore(LoadConfig().Snippets)
+	if err != nil || store != nil {
+		t.Errorf("NewSnippetStore(default) = %v, %v, want no store", store, err)
+	}
+}
+
+func TestSQLiteFilePath(t *testing.T) {
+	tests := []struct {
+		driver string
+		dsn    string
+		want   string
+	}{
+		{"sqlite", "data/snippets.db", "data/snippets.db"},
+		{"sqlite", "file:data/snippets.db?_pragma=busy_timeout(5000)", "data/snippets.db"},
+		{"sqlite", ":memory:", ""},
+		{"sqlite", "file:x?mode=memory&cache=shared", ""},
+		{"mysql", "user:pass@/snippets", ""},
+	}
+
+	for _, tt := range tests {
+		if got := sqliteFilePath(tt.driver, tt.dsn); got != tt.want {
+			t.Errorf("sqliteFilePath(%q, %q) = %q, want %q", tt.driver, tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestSnippetStores(t *testing.T) {
+	// Stores keep times to the second
+	now := time.Now().UTC().Truncate(time.Second)
+	expired, later := now.Add(-time.Minute), now.Add(time.Hour)
+	snippets := []*Snippet{
+		{ID: "0123456789", Code: "a{}", Language: "CSS", Operation: operationMinify, Output: "a{}", CreatedAt: now, DeleteTokenHash: hashDeleteToken("t")},
+		{ID: "abcdefghij", Code: "x", Operation: operationFormat, Output: "x\n", CreatedAt: now, ExpiresAt: &expired},
+		{ID: "ABCDEFGHIJ", Code: "y", Operation: operationFormat, Output: "y\n", CreatedAt: now, ExpiresAt: &later, BurnAfterRead: true},
+	}
+
+	for name, store := range newTestSnippetStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, snippet := range snippets {
+				if err := store.Create(snippet); err != nil {
+					t.Fatalf("Create(%s) error: %v", snippet.ID, err)
+				}
+			}
+			if err := store.Create(snippets[0]); !errors.Is(err, errSnippetExists) {
+				t.Errorf("Create of a taken ID error = %v, want %v", err, errSnippetExists)
+			}
+			for _, snippet := range snippets {
+				got, err := store.Get(snippet.ID)
+				if err != nil {
+					t.Fatalf("Get(%s) error: %v", snippet.ID, err)
+				}
+				if !reflect.DeepEqual(got, snippet) {
+					t.Errorf("Get(%s) = %+v, want %+v", snippet.ID, got, snippet)
+				}
+			}
+			if _, err := store.Get("zzzzzzzzzz"); !errors.Is(err, errSnippetNotFound) {
+				t.Errorf("Get of a missing ID error = %v, want %v", err, errSnippetNotFound)
+			}
+
+			if n, err := store.DeleteExpired(now); err != nil || n != 1 {
+				t.Errorf("DeleteExpired() = %d, %v, want 1", n, err)
+			}
+			if _, err := store.Get(snippets[1].ID); !errors.Is(err, errSnippetNotFound) {
+				t.Errorf("Get of an expired snippet error = %v, want %v", err, errSnippetNotFound)
+			}
+
+			// Only one of several concurrent deletes succeeds
+			var wg sync.WaitGroup
+			results := make(chan error, 8)
+			for i := 0; i < cap(results); i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					results <- store.Delete(snippets[2].ID)
+				}()
+			}
+			wg.Wait()
+			close(results)
+			deleted := 0
+			for err := range results {
+				switch {
+				case err == nil:
+					deleted++
+				case !errors.Is(err, errSnippetNotFound):
+					t.Errorf("Delete error: %v", err)
+				}
+			}
+			if deleted != 1 {
+				t.Errorf("%d concurrent deletes succeeded, want 1", deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// snippetPath is the path of the snippet endpoint, which is followed by the
+// snippet ID to read or delete one
+const snippetPath = "/api/v1/snippets/"
+
+// maxSnippetExpiry bounds how long a snippet can be kept for when it
+// expires at all
+const maxSnippetExpiry = 365 * 24 * time.Hour
+
+// deleteTokenHeader carries the token that deletes a snippet
+const deleteTokenHeader = "X-Delete-Token"
+
+// SnippetsHandler formats or minifies a snippet and saves it, returning its
+// ID and the token that deletes it
+func (h *Handlers) SnippetsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Validate request
+	if !h.validateRequest(w, r) {
+		return
+	}
+
+	var req SnippetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	operation := strings.ToLower(req.Operation)
+	switch operation {
+	case "":
+		operation = operationFormat
+	case operationFormat, operationMinify:
+	default:
+		h.respondError(w, fmt.Sprintf("Unsupported operation: %s", req.Operation), http.StatusBadRequest)
+		return
+	}
+	if req.Mode != "" {
+		h.respondError(w, "Mode is not supported for snippets", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresIn < 0 || req.ExpiresIn > int64(maxSnippetExpiry/time.Second) {
+		h.respondError(w, fmt.Sprintf("expiresIn must be between 0 and %d seconds", int64(maxSnippetExpiry/time.Second)), http.StatusBadRequest)
+		return
+	}
+
+	response := h.processSnippet(NewFormatter(), &req.Request, operation)
+	if !response.Success {
+		response.Timestamp = time.Now().Format(time.RFC3339)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Saved code is redacted like the output, so the secrets are not
+	// stored either
+	code := req.Code
+	if req.RedactSecrets && len(response.Secrets) > 0 {
+		code = redactSecrets(req.Code, response.Secrets)
+	}
+	language := response.Language
+	if language == "" {
+		language = req.Language
+	}
+
+	token, err := randomSnippetString(snippetDeleteTokenLength)
+	if err != nil {
+		log.Printf("Failed to generate snippet delete token: %v", err)
+		h.respondError(w, "Failed to save snippet", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	snippet := &Snippet{
+		Code:            code,
+		Language:        language,
+		Operation:       operation,
+		Output:          response.Code,
+		CreatedAt:       now,
+		BurnAfterRead:   req.BurnAfterRead,
+		DeleteTokenHash: hashDeleteToken(token),
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := now.Add(time.Duration(req.ExpiresIn) * time.Second)
+		snippet.ExpiresAt = &expiresAt
+	}
+	if err := h.createSnippet(snippet); err != nil {
+		log.Printf("Failed to save snippet: %v", err)
+		h.respondError(w, "Failed to save snippet", http.StatusInternalServerError)
+		return
+	}
+
+	h.respondSnippet(w, SnippetResponse{
+		Success:       true,
+		Snippet:       snippet,
+		URL:           h.snippetURL(snippet.ID),
+		DeleteToken:   token,
+		PolicyMatches: response.PolicyMatches,
+		Secrets:       response.Secrets,
+	}, http.StatusCreated)
+}
+
+// createSnippet saves snippet under a new ID, drawing another one in the
+// unlikely event that the first is taken
+func (h *Handlers) createSnippet(snippet *Snippet) error {
+	for attempt := 1; ; attempt++ {
+		id, err := randomSnippetString(snippetIDLength)
+		if err != nil {
+			return err
+		}
+		snippet.ID = id
+		err = h.snippets.Create(snippet)
+		if !errors.Is(err, errSnippetExists) || attempt == 3 {
+			return err
+		}
+	}
+}
+
+// SnippetHandler reads or deletes the snippet whose ID follows the
+// endpoint path
+func (h *Handlers) SnippetHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, snippetPath)
+	switch r.Method {
+	case http.MethodGet:
+		h.getSnippet(w, id)
+	case http.MethodDelete:
+		h.deleteSnippet(w, r, id)
+	default:
+		h.respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getSnippet returns a snippet, deleting it first when it is to be burnt
+// after reading
+func (h *Handlers) getSnippet(w http.ResponseWriter, id string) {
+	snippet, ok := h.findSnippet(w, id)
+	if !ok {
+		return
+	}
+	if snippet.BurnAfterRead {
+		// Of several concurrent readers, only the one whose delete
+		// succeeds gets to see the snippet
+		if err := h.snippets.Delete(id); err != nil {
+			h.respondSnippetError(w, err, "Failed to read snippet")
+			return
+		}
+	}
+
+	h.respondSnippet(w, SnippetResponse{
+		Success: true,
+		Snippet: snippet,
+		URL:     h.snippetURL(id),
+	}, http.StatusOK)
+}
+
+// deleteSnippet deletes a snippet given its delete token
+func (h *Handlers) deleteSnippet(w http.ResponseWriter, r *http.Request, id string) {
+	snippet, ok := h.findSnippet(w, id)
+	if !ok {
+		return
+	}
+	if !snippet.deletableWith(r.Header.Get(deleteTokenHeader)) {
+		h.respondError(w, "Invalid delete token", http.StatusForbidden)
+		return
+	}
+	if err := h.snippets.Delete(id); err != nil {
+		h.respondSnippetError(w, err, "Failed to delete snippet")
+		return
+	}
+
+	h.respondSnippet(w, SnippetResponse{Success: true}, http.StatusOK)
+}
+
+// findSnippet looks up a snippet that has not expired, responding with an
+// error when there is none. Expired snippets are deleted as they are found.
+func (h *Handlers) findSnippet(w http.ResponseWriter, id string) (*Snippet, bool) {
+	if !validSnippetID(id) {
+		h.respondError(w, "Snippet not found", http.StatusNotFound)
+		return nil, false
+	}
+	snippet, err := h.snippets.Get(id)
+	if err != nil {
+		h.respondSnippetError(w, err, "Failed to read snippet")
+		return nil, false
+	}
+	if snippet.expired(time.Now()) {
+		if err := h.snippets.Delete(id); err != nil && !errors.Is(err, errSnippetNotFound) {
+			log.Printf("Failed to delete expired snippet %s: %v", id, err)
+		}
+		h.respondError(w, "Snippet not found", http.StatusNotFound)
+		return nil, false
+	}
+	return snippet, true
+}
+
+// snippetURL returns the short link to a snippet, or "" when no base URL
+// is configured
+func (h *Handlers) snippetURL(id string) string {
+	if h.config.Snippets.BaseURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(h.config.Snippets.BaseURL, "/") + "/" + id
+}
+
+// respondSnippetError sends a not found response for a missing snippet, or
+// logs a store failure and reports it as message
+func (h *Handlers) respondSnippetError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, errSnippetNotFound) {
+		h.respondError(w, "Snippet not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("%s: %v", message, err)
+	h.respondError(w, message, http.StatusInternalServerError)
+}
+
+// respondSnippet sends a snippet response, which is never cached as the
+// snippet may be deleted at any time
+func (h *Handlers) respondSnippet(w http.ResponseWriter, response SnippetResponse, statusCode int) {
+	response.Timestamp = time.Now().Format(time.RFC3339)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
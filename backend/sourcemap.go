@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// Values of the sourceMap option
+const (
+	sourceMapInline   = "inline"
+	sourceMapSeparate = "separate"
+)
+
+// sourceMapping maps an offset into the generated code to the offset into
+// the source its token came from, and the original name of an identifier
+// the token renames
+type sourceMapping struct {
+	generated int
+	source    int
+	name      string
+}
+
+// mappedOutput collects generated code and, when it has a source map,
+// where each token written to it came from
+type mappedOutput struct {
+	strings.Builder
+	mappings []sourceMapping
+	mapped   bool
+}
+
+// token writes the text of a token starting at offset in the source
+func (o *mappedOutput) token(text string, offset int) {
+	if o.mapped {
+		o.mappings = append(o.mappings, sourceMapping{generated: o.Len(), source: offset})
+	}
+	o.WriteString(text)
+}
+
+// namedToken writes a token like token, recording name as the original
+// name of the identifier it renames
+func (o *mappedOutput) namedToken(text string, offset int, name string) {
+	if o.mapped {
+		o.mappings = append(o.mappings, sourceMapping{generated: o.Len(), source: offset, name: name})
+	}
+	o.WriteString(text)
+}
+
+// sourceMapJSON is a Source Map revision 3 document
+type sourceMapJSON struct {
+	Version        int      `json:"version"`
+	File           string   `json:"file,omitempty"`
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent"`
+	Names          []string `json:"names"`
+	Mappings       string   `json:"mappings"`
+}
+
+// textPosition walks text forward, counting lines and UTF-16 columns as
+// source maps do
+type textPosition struct {
+	text         string
+	offset       int
+	line, column int
+}
+
+func (p *textPosition) advance(to int) {
+	for p.offset < to {
+		r, size := utf8.DecodeRuneInString(p.text[p.offset:])
+		p.offset += size
+		switch {
+		case r == '\r' && p.offset < len(p.text) && p.text[p.offset] == '\n':
+			// The line ends after the \n
+		case r == '\n' || r == '\r':
+			p.line++
+			p.column = 0
+		case r >= 0x10000:
+			p.column += 2
+		default:
+			p.column++
+		}
+	}
+}
+
+const base64Digits = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// writeVLQ writes a number as a base64 variable-length quantity, with the
+// sign in the lowest bit
+func writeVLQ(b *strings.Builder, n int) {
+	v := n << 1
+	if n < 0 {
+		v = -n<<1 | 1
+	}
+	for {
+		digit := v & 31
+		v >>= 5
+		if v > 0 {
+			digit |= 32
+		}
+		b.WriteByte(base64Digits[digit])
+		if v == 0 {
+			return
+		}
+	}
+}
+
+// encodeMappings writes mappings, which must be in generated order, as
+// the mappings field of a source map, and returns the names they refer to
+func encodeMappings(generated, src string, mappings []sourceMapping) (string, []string) {
+	var b strings.Builder
+	names := []string{}
+	nameIndex := map[string]int{}
+	prevName := 0
+	gen := &textPosition{text: generated}
+	orig := &textPosition{text: src}
+	line, prevColumn := 0, 0
+	prevLine, prevSourceColumn := 0, 0
+	first := true
+	for _, m := range mappings {
+		gen.advance(m.generated)
+		if m.source < orig.offset {
+			// Tokens moved before ones they followed are located afresh
+			orig = &textPosition{text: src}
+		}
+		orig.advance(m.source)
+		for ; line < gen.line; line++ {
+			b.WriteByte(';')
+			prevColumn, first = 0, true
+		}
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+		writeVLQ(&b, gen.column-prevColumn)
+		writeVLQ(&b, 0) // the only source
+		writeVLQ(&b, orig.line-prevLine)
+		writeVLQ(&b, orig.column-prevSourceColumn)
+		prevColumn, prevLine, prevSourceColumn = gen.column, orig.line, orig.column
+		if m.name != "" {
+			index, ok := nameIndex[m.name]
+			if !ok {
+				index = len(names)
+				nameIndex[m.name] = index
+				names = append(names, m.name)
+			}
+			writeVLQ(&b, index-prevName)
+			prevName = index
+		}
+	}
+	return b.String(), names
+}
+
+// withSourceFile names the source of a source map after the file being
+// minified when the options don't name it
+func withSourceFile(opts *FormatOptions, filename string) *FormatOptions {
+	if opts.sourceMap() == "" || opts.SourceFile != "" || filename == "" {
+		return opts
+	}
+	named := *opts
+	named.SourceFile = filename[strings.LastIndexAny(filename, `/\`)+1:]
+	return &named
+}
+
+// finishSourceMap builds the source map the sourceMap option asks for.
+// An inline map is appended to the code in the comment returned by
+// comment; a separate one is returned on its own.
+func finishSourceMap(out *mappedOutput, src string, opts *FormatOptions, defaultSource string, comment func(url string) string) (code, sourceMap string) {
+	code = out.String()
+	source := opts.sourceFile(defaultSource)
+	mappings, names := encodeMappings(code, src, out.mappings)
+	doc := sourceMapJSON{
+		Version:        3,
+		Sources:        []string{source},
+		SourcesContent: []string{src},
+		Names:          names,
+		Mappings:       mappings,
+	}
+	if opts != nil && opts.SourceFile != "" {
+		ext := path.Ext(source)
+		doc.File = strings.TrimSuffix(path.Base(source), ext) + ".min" + ext
+	}
+	data, _ := json.Marshal(doc)
+	if opts.sourceMap() == sourceMapInline {
+		url := "data:application/json;charset=utf-8;base64," + base64.StdEncoding.EncodeToString(data)
+		return code + "\n" + comment(url), ""
+	}
+	return code, string(data)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestWriteVLQ(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "A"},
+		{1, "C"},
+		{-1, "D"},
+		{15, "e"},
+		{16, "gB"},
+		{-16, "hB"},
+		{1000, "w+B"},
+	}
+
+	for _, tt := range tests {
+		var b strings.Builder
+		writeVLQ(&b, tt.n)
+		if got := b.String(); got != tt.want {
+			t.Errorf("writeVLQ(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+// decodedMapping is a segment of a source map's mappings, with absolute
+// lines and UTF-16 columns
+type decodedMapping struct {
+	line, column             int
+	sourceLine, sourceColumn int
+	name                     int
+}
+
+func decodeMappings(t *testing.T, mappings string) []decodedMapping {
+	t.Helper()
+	var decoded []decodedMapping
+	var prev decodedMapping
+	for line, group := range strings.Split(mappings, ";") {
+		prev.column = 0
+		if group == "" {
+			continue
+		}
+		for _, segment := range strings.Split(group, ",") {
+			var fields []int
+			for value, shift := 0, 0; segment != ""; segment = segment[1:] {
+				digit := strings.IndexByte(base64Digits, segment[0])
+				if digit < 0 {
+					t.Fatalf("invalid base64 digit %q in %q", segment[0], mappings)
+				}
+				value |= digit & 31 << shift
+				shift += 5
+				if digit&32 == 0 {
+					if value&1 == 1 {
+						value = -(value >> 1)
+					} else {
+						value >>= 1
+					}
+					fields = append(fields, value)
+					value, shift = 0, 0
+				}
+			}
+			if len(fields) != 4 && len(fields) != 5 {
+				t.Fatalf("segment with %d fields in %q", len(fields), mappings)
+			}
+			m := decodedMapping{
+				line:         line,
+				column:       prev.column + fields[0],
+				sourceLine:   prev.sourceLine + fields[2],
+				sourceColumn: prev.sourceColumn + fields[3],
+				name:         -1,
+			}
+			if fields[1] != 0 {
+				t.Fatalf("segment refers to source %d in %q", fields[1], mappings)
+			}
+			prev.column, prev.sourceLine, prev.sourceColumn = m.column, m.sourceLine, m.sourceColumn
+			if len(fields) == 5 {
+				m.name = prev.name + fields[4]
+				prev.name = m.name
+			}
+			decoded = append(decoded, m)
+		}
+	}
+	return decoded
+}
+
+// offsetAt returns the byte offset of a line and UTF-16 column in text
+func offsetAt(t *testing.T, text string, line, column int) int {
+	t.Helper()
+	offset := 0
+	for ; line > 0; line-- {
+		end := strings.IndexByte(text[offset:], '\n')
+		if end < 0 {
+			t.Fatalf("line out of range in %q", text)
+		}
+		offset += end + 1
+	}
+	for column > 0 {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		if size == 0 || r == '\n' {
+			t.Fatalf("column out of range in %q", text)
+		}
+		offset += size
+		column--
+		if r >= 0x10000 {
+			column--
+		}
+	}
+	return offset
+}
+
+func TestMinifySourceMap(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       string
+		language   string
+		opts       *FormatOptions
+		wantSource string
+		wantNames  []string
+	}{
+		{
+			"javascript", "function add(first, second) {\n  // sum\n  return first + second;\n}\nconst s = '\u00e9\U0001F600x'; s;\n",
+			"javascript", &FormatOptions{SourceMap: sourceMapSeparate}, "input.js", nil,
+		},
+		{
+			"javascript renaming", "function add(first, second) {\n  return first + second;\n}\nadd(1, 2)\n",
+			"javascript", &FormatOptions{SourceMap: sourceMapSeparate, Compress: intPtr(2)}, "input.js", []string{"first", "second"},
+		},
+		{"typescript", "let a: number = 1\n", "typescript", &FormatOptions{SourceMap: sourceMapSeparate}, "input.ts", nil},
+		{"css", "a {\n  color: red;\n}\n\nb { margin: 0 }\n", "css", &FormatOptions{SourceMap: sourceMapSeparate}, "input.css", nil},
+		{"scss", "$c: red;\na {\n  b { color: $c; }\n}\n", "scss", &FormatOptions{SourceMap: sourceMapSeparate}, "input.scss", nil},
+		{"less", "@c: red;\na {\n  b { color: @c; }\n}\n", "less", &FormatOptions{SourceMap: sourceMapSeparate}, "input.less", nil},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := f.MinifyWithOptions(tt.code, tt.language, tt.opts)
+			if err != nil {
+				t.Fatalf("MinifyWithOptions(%q) error: %v", tt.code, err)
+			}
+			unmapped := *tt.opts
+			unmapped.SourceMap = ""
+			plain, err := f.MinifyWithOptions(tt.code, tt.language, &unmapped)
+			if err != nil || plain.Code != result.Code {
+				t.Errorf("MinifyWithOptions(%q) = %q with a source map, %q without", tt.code, result.Code, plain.Code)
+			}
+
+			var doc sourceMapJSON
+			if err := json.Unmarshal([]byte(result.SourceMap), &doc); err != nil {
+				t.Fatalf("source map %q: %v", result.SourceMap, err)
+			}
+			if doc.Version != 3 || len(doc.Sources) != 1 || doc.Sources[0] != tt.wantSource ||
+				len(doc.SourcesContent) != 1 || doc.SourcesContent[0] != tt.code {
+				t.Errorf("source map %q: want version 3 with source %q and its content", result.SourceMap, tt.wantSource)
+			}
+			if strings.Join(doc.Names, ",") != strings.Join(tt.wantNames, ",") {
+				t.Errorf("source map names = %q, want %q", doc.Names, tt.wantNames)
+			}
+
+			mappings := decodeMappings(t, doc.Mappings)
+			if len(mappings) == 0 {
+				t.Fatalf("source map %q has no mappings", result.SourceMap)
+			}
+			// Each mapping points at the token it was written from
+			for _, m := range mappings {
+				generated := result.Code[offsetAt(t, result.Code, m.line, m.column):]
+				source := tt.code[offsetAt(t, tt.code, m.sourceLine, m.sourceColumn):]
+				if m.name >= 0 {
+					if m.name >= len(doc.Names) || !strings.HasPrefix(source, doc.Names[m.name]) {
+						t.Errorf("mapping %+v: source %q does not start with its name", m, source)
+					}
+					continue
+				}
+				if generated == "" || source == "" || generated[0] != source[0] {
+					t.Errorf("mapping %+v: generated %q does not match source %q", m, generated, source)
+				}
+			}
+		})
+	}
+}
+
+func TestMinifyInlineSourceMap(t *testing.T) {
+	tests := []struct {
+		code     string
+		language string
+		opts     *FormatOptions
+		prefix   string
+		suffix   string
+		wantFile string
+	}{
+		{"let a = 1;\n", "javascript", &FormatOptions{SourceMap: "inline"}, "\n//# sourceMappingURL=", "", ""},
+		{"let a = 1;\n", "javascript", &FormatOptions{SourceMap: "Inline", SourceFile: "src/app.js"}, "\n//# sourceMappingURL=", "", "app.min.js"},
+		{"a { color: red }\n", "css", &FormatOptions{SourceMap: "inline", SourceFile: "site.css"}, "\n/*# sourceMappingURL=", " */", "site.min.css"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		result, err := f.MinifyWithOptions(tt.code, tt.language, tt.opts)
+		if err != nil {
+			t.Fatalf("MinifyWithOptions(%q) error: %v", tt.code, err)
+		}
+		if result.SourceMap != "" {
+			t.Errorf("MinifyWithOptions(%q) returned a separate source map with an inline one", tt.code)
+		}
+		i := strings.Index(result.Code, tt.prefix)
+		if i < 0 || !strings.HasSuffix(result.Code, tt.suffix) {
+			t.Fatalf("MinifyWithOptions(%q) = %q, want a %q comment", tt.code, result.Code, tt.prefix)
+		}
+		url := strings.TrimSuffix(result.Code[i+len(tt.prefix):], tt.suffix)
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(url, "data:application/json;charset=utf-8;base64,"))
+		if err != nil {
+			t.Fatalf("source map URL %q: %v", url, err)
+		}
+		var doc sourceMapJSON
+		if err := json.Unmarshal(data, &doc); err != nil {
+			t.Fatalf("source map %q: %v", data, err)
+		}
+		if doc.Version != 3 || doc.File != tt.wantFile || doc.Mappings == "" {
+			t.Errorf("source map %s: want version 3, file %q and mappings", data, tt.wantFile)
+		}
+	}
+}
+
+func TestWithSourceFile(t *testing.T) {
+	tests := []struct {
+		opts     *FormatOptions
+		filename string
+		want     string
+	}{
+		{&FormatOptions{SourceMap: "separate"}, "src/app.js", "app.js"},
+		{&FormatOptions{SourceMap: "separate"}, `C:\src\app.js`, "app.js"},
+		{&FormatOptions{SourceMap: "separate", SourceFile: "main.js"}, "src/app.js", "main.js"},
+		{&FormatOptions{SourceMap: "separate"}, "", ""},
+		{&FormatOptions{}, "src/app.js", ""},
+	}
+
+	for _, tt := range tests {
+		if got := withSourceFile(tt.opts, tt.filename).SourceFile; got != tt.want {
+			t.Errorf("withSourceFile(%+v, %q).SourceFile = %q, want %q", tt.opts, tt.filename, got, tt.want)
+		}
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
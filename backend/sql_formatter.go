@@ -0,0 +1,857 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sqlPrintWidth is the line width the SQL formatter aims for
+const sqlPrintWidth = 80
+
+type sqlNodeKind int
+
+const (
+	sqlLeaf  sqlNodeKind = iota
+	sqlParen             // ( ... ) or [ ... ]
+	sqlCase              // CASE ... END
+	sqlBlock             // BEGIN ... END holding statements
+)
+
+// sqlNode is a token or a bracketed group of them. Groups keep their
+// opening token in tok and their closing tokens in end.
+type sqlNode struct {
+	kind       sqlNodeKind
+	tok        sqlToken
+	children   []*sqlNode
+	statements []*sqlStatement
+	end        []sqlToken
+}
+
+// sqlStatement is a statement with the delimiter ending it, if any, or a
+// client command such as GO
+type sqlStatement struct {
+	nodes     []*sqlNode
+	delimiter *sqlToken
+}
+
+func (n *sqlNode) isWord(word string) bool {
+	return n.kind == sqlLeaf && n.tok.isWord(word)
+}
+
+func (n *sqlNode) isComment() bool {
+	return n.kind == sqlLeaf && n.tok.isComment()
+}
+
+// isSubquery reports whether a parenthesized group holds a statement
+func (n *sqlNode) isSubquery() bool {
+	if n.kind != sqlParen || !n.tok.is("(") {
+		return false
+	}
+	for _, child := range n.children {
+		if child.isComment() {
+			continue
+		}
+		return child.kind == sqlParen && child.isSubquery() ||
+			child.isWord("SELECT") || child.isWord("WITH") || child.isWord("VALUES") ||
+			child.isWord("INSERT") || child.isWord("UPDATE") || child.isWord("DELETE")
+	}
+	return false
+}
+
+// sqlParser groups tokens into statements and bracketed nodes
+type sqlParser struct {
+	src    string
+	tokens []sqlToken
+	pos    int
+}
+
+func parseSQL(src string, dialect sqlDialect) ([]*sqlStatement, error) {
+	tokens, err := tokenizeSQL(src, dialect)
+	if err != nil {
+		return nil, err
+	}
+	p := &sqlParser{src: src, tokens: tokens}
+	return p.statements(false)
+}
+
+// peek returns the next token that is not a comment after offset i of
+// the stream
+func (p *sqlParser) peek(i int) (sqlToken, bool) {
+	for ; i < len(p.tokens); i++ {
+		if !p.tokens[i].isComment() {
+			return p.tokens[i], true
+		}
+	}
+	return sqlToken{}, false
+}
+
+// statements reads statements up to the end of the input or, in a block,
+// up to the END closing it
+func (p *sqlParser) statements(block bool) ([]*sqlStatement, error) {
+	var statements []*sqlStatement
+	for p.pos < len(p.tokens) {
+		if block && p.blockEnd() {
+			return statements, nil
+		}
+		stmt, err := p.statement(block)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, stmt)
+	}
+	return statements, nil
+}
+
+func (p *sqlParser) statement(block bool) (*sqlStatement, error) {
+	stmt := &sqlStatement{}
+	for p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+		switch {
+		case tok.kind == sqlDelimiter || tok.is(";"):
+			p.pos++
+			stmt.delimiter = &tok
+			return stmt, nil
+		case tok.kind == sqlCommand:
+			if len(stmt.nodes) == 0 {
+				p.pos++
+				stmt.nodes = append(stmt.nodes, &sqlNode{tok: tok})
+			}
+			return stmt, nil
+		case block && p.blockEnd():
+			return stmt, nil
+		}
+		node, err := p.node()
+		if err != nil {
+			return nil, err
+		}
+		stmt.nodes = append(stmt.nodes, node)
+	}
+	return stmt, nil
+}
+
+// blockEnd reports whether the next token is the END of a BEGIN block
+// rather than of a procedural IF, LOOP, WHILE, REPEAT or CASE statement
+func (p *sqlParser) blockEnd() bool {
+	tok := p.tokens[p.pos]
+	if !tok.isWord("END") {
+		return false
+	}
+	next, _ := p.peek(p.pos + 1)
+	for _, word := range []string{"IF", "LOOP", "WHILE", "REPEAT", "CASE", "FOR"} {
+		if next.isWord(word) {
+			return false
+		}
+	}
+	return true
+}
+
+// blockBegin reports whether the BEGIN just read opens a block of
+// statements rather than a transaction
+func (p *sqlParser) blockBegin() bool {
+	next, ok := p.peek(p.pos)
+	if !ok || next.kind == sqlDelimiter || next.is(";") {
+		return false
+	}
+	for _, word := range []string{"TRANSACTION", "TRAN", "WORK", "DISTRIBUTED", "DEFERRED", "IMMEDIATE", "EXCLUSIVE"} {
+		if next.isWord(word) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *sqlParser) node() (*sqlNode, error) {
+	tok := p.tokens[p.pos]
+	p.pos++
+	switch {
+	case tok.is("(") || tok.is("["):
+		closer := ")"
+		if tok.is("[") {
+			closer = "]"
+		}
+		node := &sqlNode{kind: sqlParen, tok: tok}
+		for {
+			if p.pos >= len(p.tokens) {
+				return nil, sqlErrorf(p.src, tok.start, "unclosed %s", tok.text)
+			}
+			next := p.tokens[p.pos]
+			if next.is(closer) {
+				p.pos++
+				node.end = []sqlToken{next}
+				return node, nil
+			}
+			if next.kind == sqlDelimiter || next.kind == sqlCommand || next.is(";") {
+				return nil, sqlErrorf(p.src, tok.start, "unclosed %s", tok.text)
+			}
+			child, err := p.node()
+			if err != nil {
+				return nil, err
+			}
+			node.children = append(node.children, child)
+		}
+	case tok.is(")") || tok.is("]"):
+		return nil, sqlErrorf(p.src, tok.start, "unexpected %s", tok.text)
+	case tok.isWord("CASE"):
+		node := &sqlNode{kind: sqlCase, tok: tok}
+		for {
+			if p.pos >= len(p.tokens) || p.tokens[p.pos].kind == sqlCommand {
+				return nil, sqlErrorf(p.src, tok.start, "CASE without END")
+			}
+			next := p.tokens[p.pos]
+			if next.isWord("END") {
+				p.pos++
+				node.end = []sqlToken{next}
+				// END CASE closes a procedural CASE statement
+				if p.pos < len(p.tokens) && p.tokens[p.pos].isWord("CASE") {
+					node.end = append(node.end, p.tokens[p.pos])
+					p.pos++
+				}
+				return node, nil
+			}
+			child, err := p.node()
+			if err != nil {
+				return nil, err
+			}
+			node.children = append(node.children, child)
+		}
+	case tok.isWord("BEGIN") && p.blockBegin():
+		node := &sqlNode{kind: sqlBlock, tok: tok}
+		// T-SQL's BEGIN TRY and BEGIN CATCH end with END TRY and END CATCH
+		suffix := p.pos < len(p.tokens) && (p.tokens[p.pos].isWord("TRY") || p.tokens[p.pos].isWord("CATCH"))
+		if suffix {
+			node.children = append(node.children, &sqlNode{tok: p.tokens[p.pos]})
+			p.pos++
+		}
+		statements, err := p.statements(true)
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.tokens) {
+			return nil, sqlErrorf(p.src, tok.start, "BEGIN without END")
+		}
+		node.statements = statements
+		node.end = []sqlToken{p.tokens[p.pos]}
+		p.pos++
+		if suffix && p.pos < len(p.tokens) && p.tokens[p.pos].kind == sqlWord {
+			node.end = append(node.end, p.tokens[p.pos])
+			p.pos++
+		}
+		return node, nil
+	}
+	return &sqlNode{tok: tok}, nil
+}
+
+// sqlNameFollows lists the keywords after which a word names an object,
+// as in CREATE FUNCTION add or INSERT INTO user
+var sqlNameFollows = setOf("FUNCTION", "PROCEDURE", "TABLE", "VIEW", "INDEX", "TRIGGER", "INTO", "FROM", "JOIN", "REFERENCES")
+
+// uppercaseSQLKeywords rewrites keywords in uppercase. Words qualified by
+// a dot or following a keyword that introduces a name are names, and
+// function names only count as such when called.
+func uppercaseSQLKeywords(tokens []sqlToken) {
+	significant := func(i, step int) sqlToken {
+		for i += step; i >= 0 && i < len(tokens); i += step {
+			if !tokens[i].isComment() {
+				return tokens[i]
+			}
+		}
+		return sqlToken{}
+	}
+	for i, tok := range tokens {
+		if tok.kind != sqlWord {
+			continue
+		}
+		prev, next := significant(i, -1), significant(i, 1)
+		if prev.is(".") || next.is(".") {
+			continue
+		}
+		word := strings.ToUpper(tok.text)
+		if prev.kind == sqlWord && sqlNameFollows[strings.ToUpper(prev.text)] &&
+			word != "IF" && word != "ONLY" && word != "LATERAL" {
+			continue
+		}
+		if sqlKeywords[word] || sqlFunctions[word] && next.is("(") {
+			tokens[i].text = word
+		}
+	}
+}
+
+// sqlLayout is how a clause lays out its body
+type sqlLayout int
+
+const (
+	// sqlInline keeps the body on the keyword's line
+	sqlInline sqlLayout = iota
+	// sqlList puts the comma-separated items on the keyword's line when they
+	// fit and one per line below it otherwise
+	sqlList
+	// sqlConditions starts a line for each top-level AND and OR
+	sqlConditions
+	// sqlAlone puts the keyword on a line of its own
+	sqlAlone
+)
+
+// sqlClauses are the keywords starting a clause on a new line, longest
+// first so that the longest match wins
+var sqlClauses = []struct {
+	words  []string
+	layout sqlLayout
+}{
+	{[]string{"ON", "DUPLICATE", "KEY", "UPDATE"}, sqlList},
+	{[]string{"INSERT", "OR", "REPLACE", "INTO"}, sqlInline},
+	{[]string{"INSERT", "OR", "IGNORE", "INTO"}, sqlInline},
+	{[]string{"LEFT", "OUTER", "JOIN"}, sqlConditions},
+	{[]string{"RIGHT", "OUTER", "JOIN"}, sqlConditions},
+	{[]string{"FULL", "OUTER", "JOIN"}, sqlConditions},
+	{[]string{"INSERT", "IGNORE", "INTO"}, sqlInline},
+	{[]string{"DO", "UPDATE", "SET"}, sqlList},
+	{[]string{"WHEN", "NOT", "MATCHED"}, sqlInline},
+	{[]string{"SELECT", "DISTINCT"}, sqlList},
+	{[]string{"SELECT", "ALL"}, sqlList},
+	{[]string{"INSERT", "INTO"}, sqlInline},
+	{[]string{"REPLACE", "INTO"}, sqlInline},
+	{[]string{"DELETE", "FROM"}, sqlInline},
+	{[]string{"MERGE", "INTO"}, sqlInline},
+	{[]string{"GROUP", "BY"}, sqlList},
+	{[]string{"ORDER", "BY"}, sqlList},
+	{[]string{"ON", "CONFLICT"}, sqlInline},
+	{[]string{"DO", "NOTHING"}, sqlInline},
+	{[]string{"WHEN", "MATCHED"}, sqlInline},
+	{[]string{"WITH", "RECURSIVE"}, sqlList},
+	{[]string{"UNION", "ALL"}, sqlAlone},
+	{[]string{"UNION", "DISTINCT"}, sqlAlone},
+	{[]string{"INTERSECT", "ALL"}, sqlAlone},
+	{[]string{"EXCEPT", "ALL"}, sqlAlone},
+	{[]string{"INNER", "JOIN"}, sqlConditions},
+	{[]string{"CROSS", "JOIN"}, sqlConditions},
+	{[]string{"NATURAL", "JOIN"}, sqlConditions},
+	{[]string{"LEFT", "JOIN"}, sqlConditions},
+	{[]string{"RIGHT", "JOIN"}, sqlConditions},
+	{[]string{"FULL", "JOIN"}, sqlConditions},
+	{[]string{"CROSS", "APPLY"}, sqlConditions},
+	{[]string{"OUTER", "APPLY"}, sqlConditions},
+	{[]string{"SELECT"}, sqlList},
+	{[]string{"FROM"}, sqlList},
+	{[]string{"WHERE"}, sqlConditions},
+	{[]string{"HAVING"}, sqlConditions},
+	{[]string{"JOIN"}, sqlConditions},
+	{[]string{"STRAIGHT_JOIN"}, sqlConditions},
+	{[]string{"INSERT"}, sqlInline},
+	{[]string{"UPDATE"}, sqlInline},
+	{[]string{"DELETE"}, sqlInline},
+	{[]string{"INTO"}, sqlInline},
+	{[]string{"SET"}, sqlList},
+	{[]string{"VALUES"}, sqlList},
+	{[]string{"RETURNING"}, sqlList},
+	{[]string{"WITH"}, sqlList},
+	{[]string{"WINDOW"}, sqlList},
+	{[]string{"LIMIT"}, sqlInline},
+	{[]string{"OFFSET"}, sqlInline},
+	{[]string{"FETCH"}, sqlInline},
+	{[]string{"UNION"}, sqlAlone},
+	{[]string{"INTERSECT"}, sqlAlone},
+	{[]string{"EXCEPT"}, sqlAlone},
+	{[]string{"MINUS"}, sqlAlone},
+}
+
+// sqlNotClause lists the words after which a clause keyword is part of
+// the phrase, as in FOR UPDATE, ON DELETE or IS DISTINCT FROM
+var sqlNotClause = setOf(
+	"FOR", "ON", "BEFORE", "AFTER", "OF", "INSTEAD", "EACH", "DISTINCT",
+	"DEFAULT", "CHARACTER", "DELETE", "UPDATE", "THEN", "OR",
+)
+
+// sqlClause is a clause keyword with the nodes following it. Comments on
+// their own lines before the keyword are kept in comments.
+type sqlClause struct {
+	comments []*sqlNode
+	keyword  []*sqlNode
+	layout   sqlLayout
+	body     []*sqlNode
+}
+
+// splitSQLClauses divides a statement's nodes at its clause keywords. The
+// nodes before the first keyword form a clause without one.
+func splitSQLClauses(nodes []*sqlNode) []*sqlClause {
+	clause := &sqlClause{}
+	clauses := []*sqlClause{clause}
+	if len(nodes) > 0 && (nodes[0].isWord("GRANT") || nodes[0].isWord("REVOKE") || nodes[0].isWord("DENY")) {
+		clause.body = nodes
+		return clauses
+	}
+
+	var prev *sqlNode
+	for i := 0; i < len(nodes); i++ {
+		node := nodes[i]
+		length, layout := 0, sqlInline
+		if !node.isComment() {
+			length, layout = matchSQLClause(nodes[i:])
+		}
+		switch {
+		case length == 0:
+		case prev != nil && prev.kind == sqlLeaf && prev.tok.kind == sqlWord && sqlNotClause[strings.ToUpper(prev.tok.text)]:
+			length = 0
+		case node.isWord("WITH") && prev != nil && !prev.isWord("AS"):
+			// A list of common table expressions starts a statement
+			length = 0
+		}
+		if length == 0 {
+			clause.body = append(clause.body, node)
+			if !node.isComment() {
+				prev = node
+			}
+			continue
+		}
+
+		next := &sqlClause{layout: layout, keyword: nodes[i : i+length : i+length]}
+		// Comments on the lines before the keyword go with it
+		split := len(clause.body)
+		for split > 0 && clause.body[split-1].isComment() && clause.body[split-1].tok.newlines > 0 {
+			split--
+		}
+		next.comments = clause.body[split:]
+		clause.body = clause.body[:split]
+		if len(clause.keyword) == 0 && len(clause.body) == 0 {
+			clauses = clauses[:0]
+		}
+		clause = next
+		clauses = append(clauses, clause)
+		i += length - 1
+
+		// SELECT TOP n and SELECT DISTINCT ON (...) stay on the keyword's line
+		if layout == sqlList && next.keyword[0].isWord("SELECT") {
+			for _, word := range []string{"TOP", "ON"} {
+				if i+2 < len(nodes) && nodes[i+1].isWord(word) {
+					next.keyword = append(next.keyword, nodes[i+1], nodes[i+2])
+					i += 2
+				}
+			}
+			if i+1 < len(nodes) && nodes[i+1].isWord("PERCENT") {
+				next.keyword = append(next.keyword, nodes[i+1])
+				i++
+			}
+		}
+		prev = nodes[i]
+	}
+	return clauses
+}
+
+// matchSQLClause returns the number of nodes forming a clause keyword at
+// the start of nodes, or 0
+func matchSQLClause(nodes []*sqlNode) (int, sqlLayout) {
+	for _, clause := range sqlClauses {
+		if len(clause.words) > len(nodes) {
+			continue
+		}
+		match := true
+		for i, word := range clause.words {
+			if !nodes[i].isWord(word) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return len(clause.words), clause.layout
+		}
+	}
+	return 0, sqlInline
+}
+
+// sqlWriter assembles indented lines. Nested statements, broken CASE
+// expressions and blocks indent relative to the line they start on.
+type sqlWriter struct {
+	indent    string
+	width     int
+	out       strings.Builder
+	line      strings.Builder
+	lineDepth int
+	prev      sqlToken
+	prev2     sqlToken
+	// breakNext makes the next token start a line, as after a line comment
+	breakNext bool
+	// ownLine is set after a comment written on a line of its own, which
+	// the token after it does not join if the source did not
+	ownLine bool
+	// columns marks the parenthesized group holding a table's columns
+	columns *sqlNode
+	// flat makes CASE expressions stay on their line, to measure them
+	flat bool
+}
+
+// newline ends the current line, if any, and starts one at depth
+func (w *sqlWriter) newline(depth int) {
+	if w.line.Len() > 0 {
+		w.out.WriteString(strings.Repeat(w.indent, w.lineDepth))
+		w.out.WriteString(strings.TrimRight(w.line.String(), " "))
+		w.out.WriteByte('\n')
+		w.line.Reset()
+	}
+	w.lineDepth = depth
+	w.breakNext = false
+}
+
+// blank ends the current line and leaves an empty one
+func (w *sqlWriter) blank() {
+	w.newline(w.lineDepth)
+	if w.out.Len() > 0 {
+		w.out.WriteByte('\n')
+	}
+}
+
+func (w *sqlWriter) lineLen() int {
+	return len(w.indent)*w.lineDepth + w.line.Len()
+}
+
+func (w *sqlWriter) token(tok sqlToken) {
+	if w.breakNext {
+		w.newline(w.lineDepth)
+	}
+	if tok.isComment() {
+		if tok.newlines > 0 && w.line.Len() > 0 {
+			w.newline(w.lineDepth)
+		}
+		if w.line.Len() > 0 {
+			w.line.WriteByte(' ')
+		}
+		w.line.WriteString(tok.text)
+		w.breakNext = tok.kind == sqlLineComment
+		w.ownLine = tok.newlines > 0 || w.out.Len() == 0 && w.line.Len() == len(tok.text)
+		return
+	}
+	if w.ownLine && tok.newlines > 0 {
+		w.newline(w.lineDepth)
+	}
+	w.ownLine = false
+	if w.line.Len() > 0 && sqlSpaceBefore(w.prev2, w.prev, tok) {
+		w.line.WriteByte(' ')
+	}
+	w.line.WriteString(tok.text)
+	w.prev2, w.prev = w.prev, tok
+}
+
+// sqlSpaceBefore reports whether a space separates tok from prev, which
+// follows prev2
+func sqlSpaceBefore(prev2, prev, tok sqlToken) bool {
+	switch {
+	case tok.is(",") || tok.is(")") || tok.is("]") || tok.is(".") || tok.is("::") ||
+		tok.is(";") || tok.kind == sqlDelimiter:
+		return false
+	case prev.is("(") || prev.is("[") || prev.is(".") || prev.is("::"):
+		return false
+	case tok.is("("):
+		switch prev.kind {
+		case sqlWord:
+			word := strings.ToUpper(prev.text)
+			if sqlFunctions[word] && prev.text == word {
+				return false
+			}
+			if sqlKeywords[word] && prev.text == word {
+				return true
+			}
+			return tok.spaced
+		case sqlQuotedIdent:
+			return tok.spaced
+		}
+		return true
+	case tok.is("["):
+		return !(prev.kind == sqlWord || prev.kind == sqlQuotedIdent || prev.is(")") || prev.is("]"))
+	case prev.is("-") || prev.is("+") || prev.is("~"):
+		// A sign is written against its operand
+		unary := prev2.text == "" || prev2.kind == sqlDelimiter ||
+			prev2.kind == sqlPunct && !prev2.is(")") && !prev2.is("]") ||
+			prev2.kind == sqlWord && prev2.text == strings.ToUpper(prev2.text) && sqlKeywords[prev2.text]
+		return !unary
+	}
+	return true
+}
+
+// sqlFormatter prints statements clause by clause
+type sqlFormatter struct {
+	w *sqlWriter
+}
+
+func formatSQL(src string, opts *FormatOptions) (string, error) {
+	dialect := newSQLDialect(opts.dialect())
+	tokens, err := tokenizeSQL(src, dialect)
+	if err != nil {
+		return "", err
+	}
+	uppercaseSQLKeywords(tokens)
+	p := &sqlParser{src: src, tokens: tokens}
+	statements, err := p.statements(false)
+	if err != nil {
+		return "", err
+	}
+
+	w := &sqlWriter{indent: opts.indent(2), width: opts.width(sqlPrintWidth)}
+	f := &sqlFormatter{w: w}
+	f.statements(statements, 0)
+	w.newline(0)
+	return w.out.String(), nil
+}
+
+// statements prints statements one after another, keeping a blank line
+// where the source has one
+func (f *sqlFormatter) statements(statements []*sqlStatement, depth int) {
+	for i, stmt := range statements {
+		if len(stmt.nodes) == 0 && stmt.delimiter == nil {
+			continue
+		}
+		if i > 0 && len(stmt.nodes) > 0 && stmt.nodes[0].tok.newlines > 1 {
+			f.w.blank()
+		}
+		f.statement(stmt, depth)
+	}
+}
+
+func (f *sqlFormatter) statement(stmt *sqlStatement, depth int) {
+	w := f.w
+	w.newline(depth)
+	w.prev, w.prev2 = sqlToken{}, sqlToken{}
+	if len(stmt.nodes) == 1 && stmt.nodes[0].kind == sqlLeaf && stmt.nodes[0].tok.kind == sqlCommand {
+		fields := strings.Fields(stmt.nodes[0].tok.text)
+		fields[0] = strings.ToUpper(fields[0])
+		w.line.WriteString(strings.Join(fields, " "))
+		w.newline(depth)
+		return
+	}
+	columns := w.columns
+	w.columns = nil
+	if len(stmt.nodes) > 0 && stmt.nodes[0].isWord("CREATE") {
+		w.columns = sqlColumnList(stmt.nodes)
+	}
+	nodes := stmt.nodes
+	var trailing []*sqlNode
+	if stmt.delimiter != nil {
+		// The delimiter goes before comments ending the statement's last line
+		for len(nodes) > 0 && nodes[len(nodes)-1].isComment() && nodes[len(nodes)-1].tok.newlines == 0 {
+			trailing = append([]*sqlNode{nodes[len(nodes)-1]}, trailing...)
+			nodes = nodes[:len(nodes)-1]
+		}
+	}
+	f.clauses(nodes, depth)
+	w.columns = columns
+	if stmt.delimiter != nil {
+		if len(nodes) > 0 && nodes[len(nodes)-1].kind == sqlBlock {
+			w.breakNext = false
+		}
+		w.token(*stmt.delimiter)
+		f.nodes(trailing)
+	}
+}
+
+// sqlColumnList returns the group holding the column definitions of a
+// CREATE TABLE statement, or nil
+func sqlColumnList(nodes []*sqlNode) *sqlNode {
+	table := false
+	for _, node := range nodes {
+		switch {
+		case node.isWord("TABLE"):
+			table = true
+		case node.isWord("AS"):
+			return nil
+		case table && node.kind == sqlParen && node.tok.is("("):
+			return node
+		}
+	}
+	return nil
+}
+
+// clauses prints the clauses of a statement at depth
+func (f *sqlFormatter) clauses(nodes []*sqlNode, depth int) {
+	w := f.w
+	for _, clause := range splitSQLClauses(nodes) {
+		for _, comment := range clause.comments {
+			w.newline(depth)
+			w.token(comment.tok)
+		}
+		w.newline(depth)
+		for _, node := range clause.keyword {
+			f.node(node)
+		}
+		switch clause.layout {
+		case sqlList:
+			f.list(clause, depth)
+		case sqlConditions:
+			f.conditions(clause.body, depth)
+		case sqlAlone:
+			if len(clause.body) > 0 {
+				w.newline(depth)
+				f.nodes(clause.body)
+			}
+		default:
+			f.nodes(clause.body)
+		}
+	}
+}
+
+func (f *sqlFormatter) nodes(nodes []*sqlNode) {
+	for _, node := range nodes {
+		f.node(node)
+	}
+}
+
+func (f *sqlFormatter) node(node *sqlNode) {
+	w := f.w
+	switch node.kind {
+	case sqlLeaf:
+		w.token(node.tok)
+	case sqlParen:
+		depth := w.lineDepth
+		switch {
+		case node.isSubquery():
+			w.token(node.tok)
+			f.clauses(node.children, depth+1)
+			w.newline(depth)
+		case node == w.columns:
+			w.token(node.tok)
+			items := splitSQLItems(node.children)
+			for i, item := range items {
+				w.newline(depth + 1)
+				f.nodes(item.nodes)
+				if i < len(items)-1 {
+					w.token(sqlToken{kind: sqlPunct, text: ","})
+				}
+				f.nodes(item.comments)
+			}
+			w.newline(depth)
+		default:
+			w.token(node.tok)
+			f.nodes(node.children)
+		}
+		w.token(node.end[0])
+	case sqlCase:
+		if w.flat {
+			w.token(node.tok)
+			f.nodes(node.children)
+			f.tokens(node.end)
+			return
+		}
+		// A CASE with one WHEN stays on its line when it fits there
+		whens := 0
+		for _, child := range node.children {
+			if child.isWord("WHEN") {
+				whens++
+			}
+		}
+		if flat, ok := f.flat([]*sqlNode{node}); ok && whens < 2 && w.lineLen()+1+len(flat) <= w.width {
+			w.token(node.tok)
+			f.nodes(node.children)
+			f.tokens(node.end)
+			return
+		}
+		depth := w.lineDepth
+		w.token(node.tok)
+		for _, child := range node.children {
+			if child.isWord("WHEN") || child.isWord("ELSE") {
+				w.newline(depth + 1)
+			}
+			f.node(child)
+		}
+		w.newline(depth)
+		f.tokens(node.end)
+	case sqlBlock:
+		depth := w.lineDepth
+		w.newline(depth)
+		w.token(node.tok)
+		f.nodes(node.children)
+		f.statements(node.statements, depth+1)
+		w.newline(depth)
+		f.tokens(node.end)
+		w.breakNext = true
+	}
+}
+
+func (f *sqlFormatter) tokens(tokens []sqlToken) {
+	for _, tok := range tokens {
+		f.w.token(tok)
+	}
+}
+
+// flat renders nodes on a single line, reporting false when they need
+// more than one
+func (f *sqlFormatter) flat(nodes []*sqlNode) (string, bool) {
+	w := &sqlWriter{indent: f.w.indent, width: f.w.width, columns: f.w.columns, flat: true}
+	(&sqlFormatter{w: w}).nodes(nodes)
+	if w.breakNext || w.out.Len() > 0 {
+		return "", false
+	}
+	return w.line.String(), true
+}
+
+// sqlItem is an item of a comma-separated list with the comments
+// following its comma on the same line
+type sqlItem struct {
+	nodes    []*sqlNode
+	comments []*sqlNode
+}
+
+func splitSQLItems(nodes []*sqlNode) []*sqlItem {
+	item := &sqlItem{}
+	items := []*sqlItem{item}
+	afterComma := false
+	for _, node := range nodes {
+		switch {
+		case node.kind == sqlLeaf && node.tok.is(","):
+			item = &sqlItem{}
+			items = append(items, item)
+			afterComma = true
+			continue
+		case afterComma && node.isComment() && node.tok.newlines == 0 && len(items) > 1:
+			prev := items[len(items)-2]
+			prev.comments = append(prev.comments, node)
+			continue
+		}
+		afterComma = false
+		item.nodes = append(item.nodes, node)
+	}
+	return items
+}
+
+// list prints a clause's items after its keyword when they fit there and
+// one per line below it otherwise
+func (f *sqlFormatter) list(clause *sqlClause, depth int) {
+	w := f.w
+	items := splitSQLItems(clause.body)
+	if len(items) == 1 {
+		f.nodes(clause.body)
+		return
+	}
+	if flat, ok := f.flat(clause.body); ok && w.lineLen()+1+len(flat) <= w.width {
+		f.nodes(clause.body)
+		return
+	}
+	for i, item := range items {
+		w.newline(depth + 1)
+		f.nodes(item.nodes)
+		if i < len(items)-1 {
+			w.token(sqlToken{kind: sqlPunct, text: ","})
+		}
+		f.nodes(item.comments)
+	}
+}
+
+// conditions prints a condition, starting a line below the keyword for
+// each top-level AND and OR. The AND of BETWEEN ... AND does not count.
+func (f *sqlFormatter) conditions(nodes []*sqlNode, depth int) {
+	between := false
+	for _, node := range nodes {
+		switch {
+		case node.isWord("BETWEEN"):
+			between = true
+		case node.isWord("AND") && between:
+			between = false
+		case node.isWord("AND") || node.isWord("OR"):
+			f.w.newline(depth + 1)
+		}
+		f.node(node)
+	}
+}
+
+func formatSQLCode(code string, opts *FormatOptions) (string, error) {
+	formatted, err := formatSQL(code, opts)
+	if err != nil {
+		return "", fmt.Errorf("invalid SQL syntax: %w", err)
+	}
+	return formatted, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		code    string
+		want    string
+	}{
+		{
+			"select", "",
+			"select a,b,count(*) as n from t join u on t.id=u.id where a=1 and b in (1,2) group by a,b order by n desc limit 10",
+			"SELECT a, b, COUNT(*) AS n\nFROM t\nJOIN u ON t.id = u.id\nWHERE a = 1\n  AND b IN (1, 2)\nGROUP BY a, b\nORDER BY n DESC\nLIMIT 10\n",
+		},
+		{
+			"statements", "",
+			"insert into t (a,b) values (1,'x'),(2,'y'); update t set a=2 where b='x'",
+			"INSERT INTO t (a, b)\nVALUES (1, 'x'), (2, 'y');\nUPDATE t\nSET a = 2\nWHERE b = 'x'\n",
+		},
+		{
+			"subqueries", "",
+			"select a from (select a from t) s where exists (select 1 from u where u.a=s.a)",
+			"SELECT a\nFROM (\n  SELECT a\n  FROM t\n) s\nWHERE EXISTS (\n  SELECT 1\n  FROM u\n  WHERE u.a = s.a\n)\n",
+		},
+		{"postgresql", dialectPostgreSQL, "select $1::int, $$body$$, e'a\\'b' from t", "SELECT $1::INT, $$body$$, e'a\\'b'\nFROM t\n"},
+		{"default dollar quotes", "", "select $$ x -- y $$, $tag$ a $$ b $tag$, $1, $name", "SELECT $$ x -- y $$, $tag$ a $$ b $tag$, $1, $name\n"},
+		{"default backticks", "", "select `a b` from `t`", "SELECT `a b`\nFROM `t`\n"},
+		{"mysql", dialectMySQL, "select `a b`, \"str\" from t # comment\nwhere x = 1", "SELECT `a b`, \"str\"\nFROM t # comment\nWHERE x = 1\n"},
+		{"tsql", dialectTSQL, "select [a b] from t\ngo\nselect 1", "SELECT [a b]\nFROM t\nGO\nSELECT 1\n"},
+		{"sqlite", dialectSQLite, "select a from t where b glob 'x*'", "SELECT a\nFROM t\nWHERE b GLOB 'x*'\n"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &FormatOptions{Dialect: tt.dialect}
+			got, err := f.FormatWithOptions(tt.code, "sql", opts)
+			if err != nil {
+				t.Fatalf("Format(%q) error: %v", tt.code, err)
+			}
+			if got.Code != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.code, got.Code, tt.want)
+			}
+			if again, err := f.FormatWithOptions(got.Code, "sql", opts); err != nil || again.Code != got.Code {
+				t.Errorf("Format(%q) = %+v, %v; not idempotent", got.Code, again, err)
+			}
+		})
+	}
+}
+
+func TestMinifySQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		code    string
+		want    string
+	}{
+		{
+			"select", "",
+			"select a, b, count(*) as n\nfrom t\n-- comment\nwhere b in (1, 2)\ngroup by a, b",
+			"select a,b,count(*)as n from t where b in(1,2)group by a,b",
+		},
+		{"statements", "", "insert into t (a, b) values (1, 'x');\nupdate t set a = 2", "insert into t(a,b)values(1,'x');update t set a=2"},
+		{"postgresql", dialectPostgreSQL, "select $1::int, $$ a  b $$ from t", "select $1 ::int,$$ a  b $$ from t"},
+		{"mysql", dialectMySQL, "select `a b` from t # comment\nwhere x = 1", "select `a b` from t where x=1"},
+		{"default dollar quotes and backticks", "", "select $$ x -- y $$ as `a b`\nfrom t -- c", "select $$ x -- y $$ as `a b` from t"},
+		{"tsql batches", dialectTSQL, "select [a b] from t\ngo\nselect 1", "select [a b] from t\ngo\nselect 1"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &FormatOptions{Dialect: tt.dialect}
+			got, err := f.MinifyWithOptions(tt.code, "sql", opts)
+			if err != nil {
+				t.Fatalf("Minify(%q) error: %v", tt.code, err)
+			}
+			if got.Code != tt.want {
+				t.Errorf("Minify(%q) = %q, want %q", tt.code, got.Code, tt.want)
+			}
+			// The minified code reads as the same tokens
+			dialect := newSQLDialect(opts.dialect())
+			if got, want := sqlCodeTokens(t, got.Code, dialect), sqlCodeTokens(t, tt.code, dialect); !reflect.DeepEqual(got, want) {
+				t.Errorf("minified tokens %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// sqlCodeTokens returns the text of the tokens of SQL other than comments
+func sqlCodeTokens(t *testing.T, code string, dialect sqlDialect) []string {
+	tokens, err := tokenizeSQL(code, dialect)
+	if err != nil {
+		t.Fatalf("tokenizeSQL(%q) error: %v", code, err)
+	}
+	var texts []string
+	for _, tok := range tokens {
+		if tok.kind != sqlLineComment && tok.kind != sqlBlockComment {
+			texts = append(texts, tok.text)
+		}
+	}
+	return texts
+}
+
+func TestFormatSQLErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"select 'unterminated from t", "invalid SQL syntax: 1:8: unterminated string literal"},
+		{"select a\nfrom (b", "invalid SQL syntax: 2:6: unclosed ("},
+		{"select a) from t", "invalid SQL syntax: 1:9: unexpected )"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		for _, run := range []func(string, string) (string, error){f.Format, f.Minify} {
+			_, err := run(tt.code, "sql")
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("%q error = %v, want %q", tt.code, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestFormatSQLDialectErrors(t *testing.T) {
+	tests := []struct {
+		dialect string
+		code    string
+		want    string
+	}{
+		{dialectTSQL, "select `a` from t", "invalid SQL syntax: 1:8: unexpected character '`'; backtick identifiers need the mysql or sqlite dialect"},
+		{dialectMySQL, "select $$x$$", "invalid SQL syntax: 1:8: unexpected character '$'; dollar-quoted strings need the postgresql dialect"},
+		{"", "select $$ x", "invalid SQL syntax: 1:8: unterminated dollar-quoted string"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		_, err := f.FormatWithOptions(tt.code, "sql", &FormatOptions{Dialect: tt.dialect})
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%q with dialect %q error = %v, want %q", tt.code, tt.dialect, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"strings"
+)
+
+// SQL dialects accepted by the dialect option. The empty dialect is
+// standard SQL, which also reads PostgreSQL dollar quotes and MySQL
+// backtick identifiers since neither means anything else there.
+const (
+	dialectPostgreSQL = "postgresql"
+	dialectMySQL      = "mysql"
+	dialectSQLite     = "sqlite"
+	dialectTSQL       = "tsql"
+)
+
+// sqlDialect selects the lexical rules that differ between databases
+type sqlDialect struct {
+	name string
+	// backslash escapes in string literals, as in MySQL
+	backslash bool
+	// doubleQuoteString makes "..." a string rather than an identifier
+	doubleQuoteString bool
+	// quote characters of identifiers besides the standard double quote
+	backtick bool
+	bracket  bool
+	// dollarQuote allows $tag$...$tag$ strings, as in PostgreSQL
+	dollarQuote bool
+	// hashComment makes # start a line comment, as in MySQL
+	hashComment bool
+	// nestedComments lets block comments nest, as in PostgreSQL
+	nestedComments bool
+	// atVariable makes @name a variable rather than an operator
+	atVariable bool
+	// batches makes a line holding only GO end a batch, as in T-SQL
+	batches bool
+	// delimiter allows the client's DELIMITER command, as in MySQL
+	delimiter bool
+}
+
+func newSQLDialect(name string) sqlDialect {
+	switch strings.ToLower(name) {
+	case dialectPostgreSQL:
+		return sqlDialect{name: dialectPostgreSQL, dollarQuote: true, nestedComments: true}
+	case dialectMySQL:
+		return sqlDialect{name: dialectMySQL, backslash: true, doubleQuoteString: true, backtick: true, hashComment: true, atVariable: true, delimiter: true}
+	case dialectSQLite:
+		return sqlDialect{name: dialectSQLite, backtick: true, bracket: true, atVariable: true}
+	case dialectTSQL:
+		return sqlDialect{name: dialectTSQL, bracket: true, atVariable: true, batches: true}
+	}
+	return sqlDialect{dollarQuote: true, backtick: true}
+}
+
+type sqlTokenKind int
+
+const (
+	sqlWord         sqlTokenKind = iota // keyword or identifier
+	sqlQuotedIdent                      // "name", `name` or [name]
+	sqlString                           // '...', with any E, N, X, B or U& prefix
+	sqlDollarString                     // $tag$...$tag$
+	sqlNumber
+	sqlParam // ?, ?1, $1, :name, @name and @@name
+	sqlPunct // operators and punctuation
+	sqlLineComment
+	sqlBlockComment
+	// sqlCommand is a client command kept verbatim on its own line: a T-SQL
+	// batch separator or a MySQL DELIMITER line
+	sqlCommand
+	// sqlDelimiter ends a statement: ; or the delimiter set by DELIMITER
+	sqlDelimiter
+)
+
+// sqlToken is a lexical SQL token. Text is the exact source slice.
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+	// start and end are byte offsets into the source
+	start int
+	end   int
+	// newlines counts the line breaks in the whitespace before the token
+	newlines int
+	// spaced is set when any whitespace precedes the token
+	spaced bool
+}
+
+func (t sqlToken) isComment() bool {
+	return t.kind == sqlLineComment || t.kind == sqlBlockComment
+}
+
+func (t sqlToken) is(text string) bool {
+	return t.kind == sqlPunct && t.text == text
+}
+
+// isWord reports whether the token is the given word, in any case
+func (t sqlToken) isWord(word string) bool {
+	return t.kind == sqlWord && strings.EqualFold(t.text, word)
+}
+
+// keyword returns the uppercased text of a word that is a keyword, or an
+// empty string
+func (t sqlToken) keyword() string {
+	if t.kind != sqlWord {
+		return ""
+	}
+	if word := strings.ToUpper(t.text); sqlKeywords[word] || sqlFunctions[word] {
+		return word
+	}
+	return ""
+}
+
+func sqlErrorf(src string, offset int, format string, args ...interface{}) error {
+	return newSyntaxError(syntaxSQL, src, offset, format, args...)
+}
+
+// sqlKeywords are the reserved and commonly used words of the supported
+// dialects, which the formatter writes in uppercase. Words that often
+// name columns, such as name, date or value, are left out.
+var sqlKeywords = setOf(
+	"ADD", "ALL", "ALTER", "ALWAYS", "AND", "ANY", "ARRAY", "AS", "ASC",
+	"AUTOINCREMENT", "AUTO_INCREMENT", "BEGIN", "BETWEEN", "BIGINT", "BIGSERIAL",
+	"BOOLEAN", "BOTH", "BREAK", "BY", "CASCADE", "CASE", "CATCH", "CHECK", "CLUSTERED",
+	"COLLATE", "COLUMN", "COMMIT", "CONFLICT", "CONSTRAINT", "CONTINUE", "CREATE",
+	"CROSS", "CURRENT", "CURRENT_DATE", "CURRENT_TIME", "CURRENT_TIMESTAMP",
+	"CURRENT_USER", "CURSOR", "DATABASE", "DEALLOCATE", "DECLARE", "DEFAULT",
+	"DEFERRABLE", "DEFERRED", "DELETE", "DESC", "DISTINCT", "DO", "DROP", "DUPLICATE",
+	"EACH", "ELSE", "ELSEIF", "END", "ENGINE", "ESCAPE", "EXCEPT", "EXEC", "EXECUTE",
+	"EXISTS", "EXPLAIN", "EXTENSION", "FALSE", "FETCH", "FILTER", "FIRST",
+	"FOLLOWING", "FOR", "FOREIGN", "FROM", "FULL", "FUNCTION", "GENERATED",
+	"GLOB", "GRANT", "GROUP", "HAVING", "IDENTITY", "IF", "IGNORE", "ILIKE",
+	"IMMEDIATE", "IN", "INDEX", "INNER", "INSERT", "INSTEAD", "INT", "INTEGER",
+	"INTERSECT", "INTERVAL", "INTO", "IS", "ISNULL", "JOIN", "KEY", "LANGUAGE",
+	"LAST", "LATERAL", "LEADING", "LEFT", "LIKE", "LIMIT", "LOOP", "MATCHED",
+	"MATERIALIZED", "MERGE", "NATURAL", "NEXT", "NO", "NOCOUNT", "NONCLUSTERED",
+	"NOT", "NOTNULL", "NULL", "NULLS", "NVARCHAR", "OF", "OFFSET", "ON", "ONLY",
+	"OR", "ORDER", "OUT", "OUTER", "OUTPUT", "OVER", "PARTITION", "PRECEDING",
+	"PRIMARY", "PRINT", "PROCEDURE", "RAISERROR", "RANGE", "RECURSIVE",
+	"REFERENCES", "REGEXP", "RELEASE", "RENAME", "REPLACE", "RESTRICT", "RETURN",
+	"RETURNING", "RETURNS", "REVOKE", "RIGHT", "RLIKE", "ROLLBACK", "ROW", "ROWID",
+	"ROWS", "SAVEPOINT", "SCHEMA", "SELECT", "SEQUENCE", "SERIAL", "SET", "SIMILAR",
+	"SMALLINT", "SOME", "TABLE", "TEMP", "TEMPORARY", "THEN", "TIES", "TIMESTAMP", "TINYINT",
+	"TO", "TOP", "TRAILING", "TRAN", "TRANSACTION", "TRIGGER", "TRUE", "TRUNCATE",
+	"TRY", "UNBOUNDED", "UNION", "UNIQUE", "UNKNOWN", "UNSIGNED", "UPDATE", "USE",
+	"USING", "VACUUM", "VALUES", "VARCHAR", "VIEW", "WHEN", "WHERE", "WHILE",
+	"WINDOW", "WITH", "WITHIN", "WITHOUT", "XOR", "ZEROFILL",
+)
+
+// sqlFunctions are built-in functions and parameterized types, written in
+// uppercase and directly followed by their parenthesis
+var sqlFunctions = setOf(
+	"ABS", "AVG", "CAST", "CEIL", "CEILING", "CHAR", "CHAR_LENGTH", "COALESCE",
+	"CONCAT", "CONVERT", "COUNT", "DATEADD", "DATEDIFF", "DECIMAL", "DENSE_RANK",
+	"EXTRACT", "FLOOR", "GETDATE", "GREATEST", "GROUP_CONCAT", "IFNULL", "IIF",
+	"ISNULL", "LAG", "LEAD", "LEAST", "LENGTH", "LOWER", "LTRIM", "MAX", "MIN",
+	"NOW", "NTILE", "NULLIF", "NUMERIC", "RANK", "ROUND", "ROW_NUMBER", "RTRIM",
+	"STRING_AGG", "SUBSTR", "SUBSTRING", "SUM", "TRIM", "UPPER", "VARBINARY",
+	"VARCHAR", "NVARCHAR", "NCHAR", "ARRAY_AGG", "JSON_AGG", "JSONB_AGG",
+	"JSON_OBJECT", "JSON_BUILD_OBJECT", "TO_CHAR", "DATE_TRUNC", "STRFTIME",
+	"INT", "BIGINT", "SMALLINT", "TINYINT", "FLOAT", "BINARY", "BIT", "TIMESTAMP",
+)
+
+// sqlOperators is ordered so that longer operators match first
+var sqlOperators = []string{
+	"#>>", "->>", "!~*", "<=>", "||/", "|/",
+	"::", "<>", "<=", ">=", "!=", "||", "->", "#>", "@>", "<@", "&&", ":=", "=>",
+	"<<", ">>", "~*", "!~", "!<", "!>", "+=", "-=", "*=", "/=",
+	"+", "-", "*", "/", "%", "=", "<", ">", "!", "~", "^", "&", "|", "#", "@",
+	".", ",", "(", ")", "[", "]", "{", "}", ":",
+}
+
+// sqlLexer tokenizes SQL source, comments included
+type sqlLexer struct {
+	src       string
+	pos       int
+	dialect   sqlDialect
+	delimiter string
+}
+
+func tokenizeSQL(src string, dialect sqlDialect) ([]sqlToken, error) {
+	lx := &sqlLexer{src: src, dialect: dialect, delimiter: ";"}
+	var tokens []sqlToken
+	for {
+		newlines, spaced := lx.skipSpace()
+		if lx.pos >= len(src) {
+			return tokens, nil
+		}
+		start := lx.pos
+		kind, err := lx.scan(newlines > 0 || start == 0)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, sqlToken{
+			kind:     kind,
+			text:     src[start:lx.pos],
+			start:    start,
+			end:      lx.pos,
+			newlines: newlines,
+			spaced:   spaced,
+		})
+	}
+}
+
+func (lx *sqlLexer) skipSpace() (newlines int, spaced bool) {
+	for lx.pos < len(lx.src) {
+		switch lx.src[lx.pos] {
+		case '\n':
+			newlines++
+		case ' ', '\t', '\r', '\f', '\v':
+		default:
+			return newlines, spaced
+		}
+		spaced = true
+		lx.pos++
+	}
+	return newlines, spaced
+}
+
+// scan reads the token at lx.pos and returns its kind. lineStart tells
+// whether only whitespace precedes it on its line.
+func (lx *sqlLexer) scan(lineStart bool) (sqlTokenKind, error) {
+	src, start, d := lx.src, lx.pos, lx.dialect
+	rest := src[start:]
+	c := rest[0]
+
+	if lineStart {
+		if kind, ok := lx.command(); ok {
+			return kind, nil
+		}
+	}
+	if lx.delimiter != ";" && strings.HasPrefix(rest, lx.delimiter) {
+		lx.pos += len(lx.delimiter)
+		return sqlDelimiter, nil
+	}
+
+	switch {
+	case strings.HasPrefix(rest, "--") || c == '#' && d.hashComment:
+		end := strings.IndexByte(rest, '\n')
+		if end < 0 {
+			end = len(rest)
+		}
+		lx.pos += len(strings.TrimRight(rest[:end], "\r"))
+		return sqlLineComment, nil
+	case strings.HasPrefix(rest, "/*"):
+		return sqlBlockComment, lx.blockComment()
+	case c == '\'':
+		return sqlString, lx.quoted('\'', d.backslash, "string literal")
+	case c == '"' && d.doubleQuoteString:
+		return sqlString, lx.quoted('"', d.backslash, "string literal")
+	case c == '"':
+		return sqlQuotedIdent, lx.quoted('"', false, "quoted identifier")
+	case c == '`' && d.backtick:
+		return sqlQuotedIdent, lx.quoted('`', false, "quoted identifier")
+	case c == '[' && d.bracket:
+		return sqlQuotedIdent, lx.quoted(']', false, "quoted identifier")
+	case c == ';':
+		lx.pos++
+		if lx.delimiter != ";" {
+			// Part of a stored routine's body
+			return sqlPunct, nil
+		}
+		return sqlDelimiter, nil
+	case isDigit(rune(c)) || c == '.' && len(rest) > 1 && isDigit(rune(rest[1])):
+		lx.number()
+		return sqlNumber, nil
+	case c == '$' && d.dollarQuote && sqlDollarTag(rest) != "":
+		tag := sqlDollarTag(rest)
+		end := strings.Index(rest[len(tag):], tag)
+		if end < 0 {
+			return 0, sqlErrorf(src, start, "unterminated dollar-quoted string")
+		}
+		lx.pos += len(tag) + end + len(tag)
+		return sqlDollarString, nil
+	case c == '?' || c == '$' && len(rest) > 1 && (isDigit(rune(rest[1])) || isSQLIdentStart(rest[1])):
+		lx.pos++
+		lx.identifier()
+		return sqlParam, nil
+	case c == ':' && len(rest) > 1 && isSQLIdentStart(rest[1]) && (start == 0 || src[start-1] != ':'):
+		lx.pos++
+		lx.identifier()
+		return sqlParam, nil
+	case c == '@' && d.atVariable && len(rest) > 1 && (isSQLIdentStart(rest[1]) || rest[1] == '@'):
+		lx.pos++
+		if src[lx.pos] == '@' {
+			lx.pos++
+		}
+		lx.identifier()
+		return sqlParam, nil
+	case sqlStringPrefix(rest) > 0:
+		lx.pos += sqlStringPrefix(rest)
+		backslash := d.backslash || strings.EqualFold(rest[:1], "e")
+		return sqlString, lx.quoted('\'', backslash, "string literal")
+	case isSQLIdentStart(c) || c == '#' && d.name == dialectTSQL:
+		// T-SQL names temporary tables #name and ##name
+		for lx.pos < len(src) && src[lx.pos] == '#' {
+			lx.pos++
+		}
+		lx.identifier()
+		return sqlWord, nil
+	}
+
+	for _, op := range sqlOperators {
+		if strings.HasPrefix(rest, op) {
+			lx.pos += len(op)
+			return sqlPunct, nil
+		}
+	}
+	switch {
+	case c == '`' && !d.backtick:
+		return 0, sqlErrorf(src, start, "unexpected character %q; backtick identifiers need the mysql or sqlite dialect", c)
+	case c == '$' && !d.dollarQuote:
+		return 0, sqlErrorf(src, start, "unexpected character %q; dollar-quoted strings need the postgresql dialect", c)
+	}
+	return 0, sqlErrorf(src, start, "unexpected character %q", c)
+}
+
+// command reads a client command filling the line at lx.pos: GO in T-SQL
+// or DELIMITER in MySQL
+func (lx *sqlLexer) command() (sqlTokenKind, bool) {
+	rest := lx.src[lx.pos:]
+	end := strings.IndexByte(rest, '\n')
+	if end < 0 {
+		end = len(rest)
+	}
+	line := strings.TrimRight(rest[:end], " \t\r")
+	fields := strings.Fields(line)
+	switch {
+	case lx.dialect.batches && len(fields) > 0 && strings.EqualFold(fields[0], "go") &&
+		(len(fields) == 1 || len(fields) == 2 && isSQLNumber(fields[1])):
+	case lx.dialect.delimiter && len(fields) == 2 && strings.EqualFold(fields[0], "delimiter"):
+		lx.delimiter = fields[1]
+	default:
+		return 0, false
+	}
+	lx.pos += len(line)
+	return sqlCommand, true
+}
+
+func (lx *sqlLexer) blockComment() error {
+	src, start := lx.src, lx.pos
+	depth := 0
+	for i := start; i < len(src)-1; i++ {
+		switch {
+		case src[i] == '/' && src[i+1] == '*':
+			if depth > 0 && !lx.dialect.nestedComments {
+				continue
+			}
+			depth++
+			i++
+		case src[i] == '*' && src[i+1] == '/':
+			depth--
+			i++
+			if depth == 0 {
+				lx.pos = i + 1
+				return nil
+			}
+		}
+	}
+	return sqlErrorf(src, start, "unterminated comment")
+}
+
+// quoted reads a literal or identifier from its opening character to the
+// closing one. Doubling the closing character escapes it, and so does a
+// backslash when backslash is set.
+func (lx *sqlLexer) quoted(closing byte, backslash bool, what string) error {
+	src, start := lx.src, lx.pos
+	for i := start + 1; i < len(src); i++ {
+		switch {
+		case backslash && src[i] == '\\':
+			i++
+		case src[i] == closing:
+			if i+1 < len(src) && src[i+1] == closing {
+				i++
+				continue
+			}
+			lx.pos = i + 1
+			return nil
+		}
+	}
+	return sqlErrorf(src, start, "unterminated %s", what)
+}
+
+func (lx *sqlLexer) number() {
+	src := lx.src
+	if strings.HasPrefix(src[lx.pos:], "0x") || strings.HasPrefix(src[lx.pos:], "0X") {
+		lx.pos += 2
+		for lx.pos < len(src) && isHexDigit(src[lx.pos]) {
+			lx.pos++
+		}
+		return
+	}
+	for lx.pos < len(src) && (isDigit(rune(src[lx.pos])) || src[lx.pos] == '.' || src[lx.pos] == '_') {
+		lx.pos++
+	}
+	if lx.pos < len(src) && (src[lx.pos] == 'e' || src[lx.pos] == 'E') {
+		i := lx.pos + 1
+		if i < len(src) && (src[i] == '+' || src[i] == '-') {
+			i++
+		}
+		if i < len(src) && isDigit(rune(src[i])) {
+			lx.pos = i
+			for lx.pos < len(src) && isDigit(rune(src[lx.pos])) {
+				lx.pos++
+			}
+		}
+	}
+}
+
+// identifier reads name characters, stopping at a delimiter set by
+// DELIMITER as the MySQL client does: END$$ ends with the delimiter $$
+func (lx *sqlLexer) identifier() {
+	for lx.pos < len(lx.src) && isSQLIdentChar(lx.src[lx.pos]) {
+		if lx.delimiter != ";" && strings.HasPrefix(lx.src[lx.pos:], lx.delimiter) {
+			return
+		}
+		lx.pos++
+	}
+}
+
+// sqlDollarTag returns the opening $tag$ of a dollar-quoted string, or ""
+// when s does not start with one
+func sqlDollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '$' {
+			return s[:i+1]
+		}
+		if !isSQLIdentChar(s[i]) || s[i] == '$' || i == 1 && isDigit(rune(s[i])) {
+			return ""
+		}
+	}
+	return ""
+}
+
+// sqlStringPrefix returns the length of a string literal prefix such as
+// E, N, X, B or U& directly followed by a quote, or 0
+func sqlStringPrefix(s string) int {
+	switch {
+	case len(s) > 2 && (s[0] == 'u' || s[0] == 'U') && s[1] == '&' && s[2] == '\'':
+		return 2
+	case len(s) > 1 && s[1] == '\'' && strings.ContainsRune("eEnNxXbB", rune(s[0])):
+		return 1
+	}
+	return 0
+}
+
+func isSQLNumber(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isDigit(rune(s[i])) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isSQLIdentStart(c byte) bool {
+	return isLetter(c) || c == '_' || c >= 0x80
+}
+
+func isSQLIdentChar(c byte) bool {
+	return isSQLIdentStart(c) || isDigit(rune(c)) || c == '$'
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// isKeptSQLComment reports whether a comment must survive minification:
+// MySQL runs the contents of /*! ... */, and the same form marks legal
+// comments elsewhere
+func isKeptSQLComment(tok sqlToken) bool {
+	return tok.kind == sqlBlockComment && (strings.HasPrefix(tok.text, "/*!") ||
+		strings.Contains(tok.text, "@license") || strings.Contains(tok.text, "@preserve"))
+}
+
+// minifySQL drops comments and layout. Literals, quoted identifiers and
+// dollar-quoted bodies are copied as written; client commands such as GO
+// keep a line of their own.
+func minifySQL(src string, opts *FormatOptions) (string, error) {
+	dialect := newSQLDialect(opts.dialect())
+	if _, err := parseSQL(src, dialect); err != nil {
+		return "", err
+	}
+	tokens, err := tokenizeSQL(src, dialect)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	var prev sqlToken
+	for _, tok := range tokens {
+		if tok.isComment() && !isKeptSQLComment(tok) {
+			continue
+		}
+		switch {
+		case tok.kind == sqlCommand || prev.kind == sqlCommand:
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+		case b.Len() > 0 && sqlNeedsSpace(prev, tok):
+			b.WriteByte(' ')
+		}
+		if tok.kind == sqlCommand {
+			b.WriteString(strings.Join(strings.Fields(tok.text), " "))
+		} else {
+			b.WriteString(tok.text)
+		}
+		prev = tok
+	}
+	return b.String(), nil
+}
+
+// sqlNeedsSpace reports whether writing tok right after prev could read
+// back as different tokens. Adjacent words, literals and names keep a
+// space, since some dialects let names run into quotes, @ or #.
+func sqlNeedsSpace(prev, tok sqlToken) bool {
+	switch {
+	case prev.isComment() || tok.isComment():
+		return true
+	case prev.kind != sqlPunct && prev.kind != sqlDelimiter:
+		return tok.kind != sqlPunct && tok.kind != sqlDelimiter ||
+			isSQLIdentChar(prev.text[len(prev.text)-1]) && strings.ContainsAny(tok.text[:1], "@#:")
+	case tok.kind == sqlPunct && prev.kind == sqlPunct:
+		// Operators would merge, as - - into a comment or < > into <>
+		return !strings.ContainsAny(prev.text+tok.text, "(),;.[]")
+	}
+	return false
+}
+
+func minifySQLCode(code string, opts *FormatOptions) (string, error) {
+	minified, err := minifySQL(code, opts)
+	if err != nil {
+		return "", fmt.Errorf("invalid SQL syntax: %w", err)
+	}
+	return minified, nil
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// tomlTableState records how a table came to exist, which decides what
+// may still be added to it
+type tomlTableState int
+
+const (
+	tomlImplicit tomlTableState = iota // named on the way to a header
+	tomlDotted                         // created by a dotted key
+	tomlDefined                        // given its own header
+	tomlFrozen                         // written inline
+)
+
+// tomlTableArray is an array of tables while it may still grow
+type tomlTableArray struct {
+	tables []*dataMap
+}
+
+// tomlDecoder builds plain values from a TOML document and checks that no
+// table or key is defined twice
+type tomlDecoder struct {
+	code   string
+	text   string
+	log    *conversionLog
+	root   *dataMap
+	states map[*dataMap]tomlTableState
+	// section holds the dotted tables created under the current header,
+	// which its key/value pairs may still add to
+	section map[*dataMap]bool
+}
+
+func decodeTOML(code string, log *conversionLog) (*dataMap, error) {
+	doc, err := parseTOML(code)
+	if err != nil {
+		return nil, err
+	}
+	if doc.comments > 0 {
+		log.warnf("comments were dropped")
+	}
+	d := &tomlDecoder{
+		code:   code,
+		text:   strings.ReplaceAll(code, "\r\n", "\n"),
+		log:    log,
+		root:   newDataMap(),
+		states: make(map[*dataMap]tomlTableState),
+	}
+	d.states[d.root] = tomlDefined
+	current := d.root
+	d.section = make(map[*dataMap]bool)
+	for _, st := range doc.statements {
+		switch st.kind {
+		case tomlPair:
+			err = d.pair(current, st.pair, false)
+		case tomlTable:
+			current, err = d.table(st.key)
+		case tomlArrayTable:
+			current, err = d.arrayTable(st.key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if st.kind != tomlPair {
+			d.section = make(map[*dataMap]bool)
+		}
+	}
+	finishTOMLTables(d.root)
+	return d.root, nil
+}
+
+func (d *tomlDecoder) errorf(offset int, format string, args ...interface{}) error {
+	return tomlErrorf(d.code, crlfOffset(d.code, offset), format, args...)
+}
+
+func (d *tomlDecoder) line(offset int) int {
+	line, _ := lineColumn(d.text, offset)
+	return line
+}
+
+// child returns the table a key part names in m for a header to go
+// through, creating it if needed. An array of tables stands for its last
+// table.
+func (d *tomlDecoder) child(m *dataMap, key tomlKey, i int) (*dataMap, error) {
+	name := key.parts[i]
+	switch v := m.values[name].(type) {
+	case nil:
+		// TOML has no null, so the key is new
+		t := newDataMap()
+		d.states[t] = tomlImplicit
+		m.set(name, t)
+		return t, nil
+	case *dataMap:
+		if d.states[v] == tomlFrozen {
+			return nil, d.errorf(key.offset, "cannot add to inline table %s", strings.Join(key.raw[:i+1], "."))
+		}
+		return v, nil
+	case *tomlTableArray:
+		return v.tables[len(v.tables)-1], nil
+	}
+	return nil, d.errorf(key.offset, "key %s is already a value", strings.Join(key.raw[:i+1], "."))
+}
+
+func (d *tomlDecoder) table(key tomlKey) (*dataMap, error) {
+	m := d.root
+	for i := range key.parts[:len(key.parts)-1] {
+		var err error
+		if m, err = d.child(m, key, i); err != nil {
+			return nil, err
+		}
+	}
+	name := key.parts[len(key.parts)-1]
+	if !m.has(name) {
+		t := newDataMap()
+		d.states[t] = tomlDefined
+		m.set(name, t)
+		return t, nil
+	}
+	if t, ok := m.values[name].(*dataMap); ok && d.states[t] == tomlImplicit {
+		d.states[t] = tomlDefined
+		return t, nil
+	}
+	return nil, d.errorf(key.offset, "table %s is defined more than once", key)
+}
+
+func (d *tomlDecoder) arrayTable(key tomlKey) (*dataMap, error) {
+	m := d.root
+	for i := range key.parts[:len(key.parts)-1] {
+		var err error
+		if m, err = d.child(m, key, i); err != nil {
+			return nil, err
+		}
+	}
+	name := key.parts[len(key.parts)-1]
+	t := newDataMap()
+	d.states[t] = tomlDefined
+	if !m.has(name) {
+		m.set(name, &tomlTableArray{tables: []*dataMap{t}})
+		return t, nil
+	}
+	if a, ok := m.values[name].(*tomlTableArray); ok {
+		a.tables = append(a.tables, t)
+		return t, nil
+	}
+	return nil, d.errorf(key.offset, "key %s is not an array of tables", key)
+}
+
+// pair adds a key/value pair to m. Dotted keys create tables on the way,
+// which only pairs of the same section, or the same inline table, may add
+// to.
+func (d *tomlDecoder) pair(m *dataMap, kv *tomlKeyValue, inline bool) error {
+	key := kv.key
+	for i, name := range key.parts[:len(key.parts)-1] {
+		if !m.has(name) {
+			t := newDataMap()
+			d.states[t] = tomlDotted
+			if inline {
+				d.states[t] = tomlFrozen
+			}
+			d.section[t] = true
+			m.set(name, t)
+			m = t
+			continue
+		}
+		t, ok := m.values[name].(*dataMap)
+		if !ok || !d.section[t] {
+			return d.errorf(key.offset, "key %s is already defined", strings.Join(key.raw[:i+1], "."))
+		}
+		m = t
+	}
+	name := key.parts[len(key.parts)-1]
+	if m.has(name) {
+		return d.errorf(key.offset, "key %s is already defined", key)
+	}
+	value, err := d.value(kv.value)
+	if err != nil {
+		return err
+	}
+	m.set(name, value)
+	return nil
+}
+
+var tomlDigitSeparator = strings.NewReplacer("_", "")
+
+func (d *tomlDecoder) value(v *tomlValue) (interface{}, error) {
+	switch v.kind {
+	case tomlString:
+		return v.str, nil
+	case tomlBool:
+		return v.raw == "true", nil
+	case tomlInteger:
+		digits := tomlDigitSeparator.Replace(v.raw)
+		n, _ := new(big.Int).SetString(strings.TrimPrefix(digits, "+"), 0)
+		return dataNumber(n.String()), nil
+	case tomlFloat:
+		if tomlSpecialPattern.MatchString(v.raw) {
+			d.log.warnf("line %d: %s cannot be represented and was converted to a string", d.line(v.offset), v.raw)
+			return v.raw, nil
+		}
+		return jsonFloat(tomlDigitSeparator.Replace(v.raw)), nil
+	case tomlDateTime:
+		d.log.warnf("line %d: date-time %s was converted to a string", d.line(v.offset), v.raw)
+		return v.raw, nil
+	case tomlArray:
+		list := make([]interface{}, 0, len(v.items))
+		for _, item := range v.items {
+			value, err := d.value(item.value)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	}
+
+	// Dotted keys of an inline table may only add to tables of the same
+	// inline table
+	outer := d.section
+	d.section = make(map[*dataMap]bool)
+	defer func() { d.section = outer }()
+	m := newDataMap()
+	d.states[m] = tomlFrozen
+	for _, kv := range v.pairs {
+		if err := d.pair(m, kv, true); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// finishTOMLTables turns arrays of tables into plain lists
+func finishTOMLTables(m *dataMap) {
+	for _, key := range m.keys {
+		switch v := m.values[key].(type) {
+		case *dataMap:
+			finishTOMLTables(v)
+		case *tomlTableArray:
+			list := make([]interface{}, len(v.tables))
+			for i, t := range v.tables {
+				finishTOMLTables(t)
+				list[i] = t
+			}
+			m.values[key] = list
+		}
+	}
+}
+
+func decodeTOMLCode(code string, _ *FormatOptions, log *conversionLog) (interface{}, error) {
+	value, err := decodeTOML(code, log)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOML syntax: %w", err)
+	}
+	return value, nil
+}
+
+// tomlWrapKey names the table holding data that is not a table itself
+const tomlWrapKey = "value"
+
+var tomlBareKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tomlEncoder writes plain values as TOML: the plain keys of a table
+// first, then its tables under headers and its lists of tables as arrays
+// of tables
+type tomlEncoder struct {
+	b   strings.Builder
+	log *conversionLog
+}
+
+func encodeTOML(value interface{}, _ *FormatOptions, log *conversionLog) (string, error) {
+	root, ok := value.(*dataMap)
+	if !ok {
+		log.warnf("the data was put under the key %q, as a TOML document must be a table", tomlWrapKey)
+		root = newDataMap()
+		root.set(tomlWrapKey, value)
+	}
+	e := &tomlEncoder{log: log}
+	e.table(root, nil, false)
+	return e.b.String(), nil
+}
+
+// isTOMLTableArray reports whether a list is written as an array of tables
+func isTOMLTableArray(value interface{}) bool {
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 {
+		return false
+	}
+	for _, item := range list {
+		if _, ok := item.(*dataMap); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// table writes the contents of a table at path. A header is written when
+// the table has plain keys or nothing at all, or is an array element.
+func (e *tomlEncoder) table(m *dataMap, path []string, element bool) {
+	var plain, nested []string
+	for _, key := range m.keys {
+		switch value := m.values[key].(type) {
+		case nil:
+			e.log.warnf("null values were dropped")
+		case *dataMap:
+			nested = append(nested, key)
+		default:
+			if isTOMLTableArray(value) {
+				nested = append(nested, key)
+			} else {
+				plain = append(plain, key)
+			}
+		}
+	}
+
+	if element || len(path) > 0 && (len(plain) > 0 || len(nested) == 0) {
+		if e.b.Len() > 0 {
+			e.b.WriteByte('\n')
+		}
+		header := strings.Join(path, ".")
+		if element {
+			e.b.WriteString("[[" + header + "]]\n")
+		} else {
+			e.b.WriteString("[" + header + "]\n")
+		}
+	}
+	for _, key := range plain {
+		e.b.WriteString(tomlKeyString(key) + " = ")
+		e.value(m.values[key])
+		e.b.WriteByte('\n')
+	}
+	for _, key := range nested {
+		child := append(path[:len(path):len(path)], tomlKeyString(key))
+		if t, ok := m.values[key].(*dataMap); ok {
+			e.table(t, child, false)
+			continue
+		}
+		for _, item := range m.values[key].([]interface{}) {
+			e.table(item.(*dataMap), child, true)
+		}
+	}
+}
+
+// value writes a value inline
+func (e *tomlEncoder) value(value interface{}) {
+	switch v := value.(type) {
+	case bool:
+		e.b.WriteString(strconv.FormatBool(v))
+	case dataNumber:
+		e.b.WriteString(e.number(v))
+	case string:
+		e.b.WriteString(tomlQuote(v))
+	case []interface{}:
+		e.b.WriteByte('[')
+		first := true
+		for _, item := range v {
+			if item == nil {
+				e.log.warnf("null values were dropped")
+				continue
+			}
+			if !first {
+				e.b.WriteString(", ")
+			}
+			first = false
+			e.value(item)
+		}
+		e.b.WriteByte(']')
+	case *dataMap:
+		e.b.WriteByte('{')
+		first := true
+		for _, key := range v.keys {
+			if v.values[key] == nil {
+				e.log.warnf("null values were dropped")
+				continue
+			}
+			if first {
+				e.b.WriteByte(' ')
+			} else {
+				e.b.WriteString(", ")
+			}
+			first = false
+			e.b.WriteString(tomlKeyString(key) + " = ")
+			e.value(v.values[key])
+		}
+		if !first {
+			e.b.WriteByte(' ')
+		}
+		e.b.WriteByte('}')
+	}
+}
+
+// number writes a number as a TOML integer when it is one that fits in 64
+// bits, and as a float otherwise
+func (e *tomlEncoder) number(n dataNumber) string {
+	s := string(n)
+	if !strings.ContainsAny(s, ".eE") {
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return s
+		}
+		e.log.warnf("integer %s does not fit in 64 bits and was written as a float", s)
+		return s + ".0"
+	}
+	return s
+}
+
+func tomlKeyString(key string) string {
+	if tomlBareKeyPattern.MatchString(key) {
+		return key
+	}
+	return tomlQuote(key)
+}
+
+// tomlQuote writes a basic string. TOML accepts every escape JSON has,
+// but not a raw DEL character.
+func tomlQuote(s string) string {
+	return strings.ReplaceAll(jsonQuote(s), "\x7f", `\u007f`)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tomlPrinter prints TOML with one statement per line and no indentation
+// outside arrays. Each table header is set off by a blank line, other
+// blank lines are kept and values are printed as written.
+type tomlPrinter struct {
+	out    strings.Builder
+	indent string
+}
+
+func formatTOML(src string, opts *FormatOptions) (string, error) {
+	doc, err := parseTOML(src)
+	if err != nil {
+		return "", err
+	}
+	// Only well-formed documents are formatted, which the decoder checks
+	if _, err := decodeTOML(src, &conversionLog{}); err != nil {
+		return "", err
+	}
+
+	p := &tomlPrinter{indent: opts.indent(2)}
+	for _, st := range doc.statements {
+		// A blank line before the comments of a statement or, when it has
+		// none, before the statement itself, is kept
+		blank := st.blank
+		if len(st.comments) > 0 {
+			blank = st.comments[0].blank
+		}
+		if p.out.Len() > 0 && (blank || st.kind != tomlPair) {
+			p.out.WriteByte('\n')
+		}
+		p.comments(st.comments, "")
+		if st.blank && len(st.comments) > 0 {
+			p.out.WriteByte('\n')
+		}
+		switch st.kind {
+		case tomlPair:
+			p.pair(st.pair, 0)
+		case tomlTable:
+			p.out.WriteString("[" + tomlKeyText(st.key) + "]")
+		case tomlArrayTable:
+			p.out.WriteString("[[" + tomlKeyText(st.key) + "]]")
+		}
+		if st.comment != "" {
+			p.out.WriteString(" " + st.comment)
+		}
+		p.out.WriteByte('\n')
+	}
+	if len(doc.foot) > 0 && p.out.Len() > 0 && doc.foot[0].blank {
+		p.out.WriteByte('\n')
+	}
+	p.comments(doc.foot, "")
+	return p.out.String(), nil
+}
+
+// comments prints comments on lines of their own, keeping the blank lines
+// between them
+func (p *tomlPrinter) comments(comments []tomlComment, indent string) {
+	for i, c := range comments {
+		if c.blank && i > 0 {
+			p.out.WriteByte('\n')
+		}
+		p.out.WriteString(indent + c.text + "\n")
+	}
+}
+
+func tomlKeyText(key tomlKey) string {
+	return strings.Join(key.raw, ".")
+}
+
+func (p *tomlPrinter) pair(kv *tomlKeyValue, depth int) {
+	p.out.WriteString(tomlKeyText(kv.key) + " = ")
+	p.value(kv.value, depth)
+}
+
+func (p *tomlPrinter) value(v *tomlValue, depth int) {
+	switch v.kind {
+	case tomlArray:
+		p.array(v, depth)
+	case tomlInlineTable:
+		if len(v.pairs) == 0 {
+			p.out.WriteString("{}")
+			return
+		}
+		p.out.WriteString("{ ")
+		for i, kv := range v.pairs {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.pair(kv, depth)
+		}
+		p.out.WriteString(" }")
+	default:
+		p.out.WriteString(v.raw)
+	}
+}
+
+// array prints an array on one line, or one element per line when it was
+// written over several lines
+func (p *tomlPrinter) array(v *tomlValue, depth int) {
+	if !v.multiline {
+		p.out.WriteByte('[')
+		for i, item := range v.items {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.value(item.value, depth)
+		}
+		p.out.WriteByte(']')
+		return
+	}
+
+	inner := strings.Repeat(p.indent, depth+1)
+	p.out.WriteString("[\n")
+	for _, item := range v.items {
+		p.comments(item.comments, inner)
+		p.out.WriteString(inner)
+		p.value(item.value, depth+1)
+		p.out.WriteByte(',')
+		if item.comment != "" {
+			p.out.WriteString(" " + item.comment)
+		}
+		p.out.WriteByte('\n')
+	}
+	p.comments(v.foot, inner)
+	p.out.WriteString(strings.Repeat(p.indent, depth) + "]")
+}
+
+func formatTOMLCode(code string, opts *FormatOptions) (string, error) {
+	formatted, err := formatTOML(code, opts)
+	if err != nil {
+		return "", fmt.Errorf("invalid TOML syntax: %w", err)
+	}
+	return formatted, nil
+}
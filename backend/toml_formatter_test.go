@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"tables", "title='x'\n[owner]\nname=\"a\"\n", "title = 'x'\n\n[owner]\nname = \"a\"\n"},
+		{"array tables", "[[items]]\nid=1\n[[items]]\nid=2\n", "[[items]]\nid = 1\n\n[[items]]\nid = 2\n"},
+		{"multiline array", "a = [1,2,\n3]\n", "a = [\n  1,\n  2,\n  3,\n]\n"},
+		{"inline table and comment", "b = {c=1, d='x'}\n# comment\n", "b = { c = 1, d = 'x' }\n# comment\n"},
+		{"date-time as written", "dob=1979-05-27T07:32:00Z\n", "dob = 1979-05-27T07:32:00Z\n"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Format(tt.code, "toml")
+			if err != nil {
+				t.Fatalf("Format(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if again, err := f.Format(got, "toml"); err != nil || again != got {
+				t.Errorf("Format(%q) = %q, %v; not idempotent", got, again, err)
+			}
+		})
+	}
+}
+
+func TestFormatTOMLErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"a = 1\na = 2\n", "invalid TOML syntax: 2:1: key a is already defined"},
+		{"a = \n", "invalid TOML syntax: 1:5: expected a value, found end of line"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		_, err := f.Format(tt.code, "toml")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Format(%q) error = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+}
+
+func TestConvertTOML(t *testing.T) {
+	tests := []struct {
+		name         string
+		code         string
+		from, to     string
+		want         string
+		wantWarnings []string
+	}{
+		{
+			"date-time to JSON", "dob = 1979-05-27T07:32:00Z\n", "toml", "json", "{\n  \"dob\": \"1979-05-27T07:32:00Z\"\n}\n",
+			[]string{"line 1: date-time 1979-05-27T07:32:00Z was converted to a string"},
+		},
+		{"comments to YAML", "# c\na = [1, 2]\n", "toml", "yaml", "a:\n  - 1\n  - 2\n", []string{"comments were dropped"}},
+		{
+			"tables from JSON", `{"a":1,"t":{"b":[1,2],"c":{"d":"x"}},"arr":[{"x":1},{"x":2}]}`, "json", "toml",
+			"a = 1\n\n[t]\nb = [1, 2]\n\n[t.c]\nd = \"x\"\n\n[[arr]]\nx = 1\n\n[[arr]]\nx = 2\n", nil,
+		},
+		{
+			"array from JSON", `[1,2]`, "json", "toml", "value = [1, 2]\n",
+			[]string{"the data was put under the key \"value\", as a TOML document must be a table"},
+		},
+		{"null from JSON", `{"a":null,"b":1}`, "json", "toml", "b = 1\n", []string{"null values were dropped"}},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Convert(tt.code, tt.from, tt.to, nil)
+			if err != nil {
+				t.Fatalf("Convert(%q) error: %v", tt.code, err)
+			}
+			if got.Code != tt.want {
+				t.Errorf("Convert(%q) = %q, want %q", tt.code, got.Code, tt.want)
+			}
+			if len(got.Warnings) > 0 || len(tt.wantWarnings) > 0 {
+				if !reflect.DeepEqual(got.Warnings, tt.wantWarnings) {
+					t.Errorf("Convert(%q) warnings = %q, want %q", tt.code, got.Warnings, tt.wantWarnings)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertTOMLRoundTrip(t *testing.T) {
+	codes := []string{
+		`{"a":1,"t":{"b":[1,2],"c":{"d":"x"}},"arr":[{"x":1},{"x":2}]}`,
+		`{"s":"a\"b","f":1.5,"ok":false,"nested":[[1],[2,3]]}`,
+	}
+
+	f := NewFormatter()
+	for _, code := range codes {
+		toml, err := f.Convert(code, "json", "toml", nil)
+		if err != nil {
+			t.Fatalf("Convert(%q) error: %v", code, err)
+		}
+		back, err := f.Convert(toml.Code, "toml", "json", nil)
+		if err != nil {
+			t.Fatalf("Convert(%q) error: %v", toml.Code, err)
+		}
+		want, _ := f.Format(code, "json")
+		if back.Code != want {
+			t.Errorf("Convert(%q) back to JSON = %q, want %q", toml.Code, back.Code, want)
+		}
+	}
+}
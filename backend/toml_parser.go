@@ -0,0 +1,723 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTOMLDepth bounds the nesting of arrays and inline tables
+const maxTOMLDepth = 1000
+
+func tomlErrorf(src string, offset int, format string, args ...interface{}) error {
+	return newSyntaxError(syntaxTOML, src, offset, format, args...)
+}
+
+type tomlValueKind int
+
+const (
+	tomlString tomlValueKind = iota
+	tomlInteger
+	tomlFloat
+	tomlBool
+	tomlDateTime
+	tomlArray
+	tomlInlineTable
+)
+
+// tomlComment is a comment on a line of its own
+type tomlComment struct {
+	text  string
+	blank bool // a blank line comes before it
+}
+
+// tomlKey is a dotted key. raw keeps each part as written and parts the
+// names they stand for.
+type tomlKey struct {
+	raw    []string
+	parts  []string
+	offset int
+}
+
+func (k tomlKey) String() string {
+	return strings.Join(k.raw, ".")
+}
+
+// tomlValue is a value as written. Scalars keep their source text, which
+// the formatter prints unchanged and the converter decodes.
+type tomlValue struct {
+	kind tomlValueKind
+	raw  string
+	// str is the decoded content of a string
+	str   string
+	items []*tomlArrayItem
+	pairs []*tomlKeyValue
+	// multiline is set for arrays written over several lines, which keep
+	// one element per line
+	multiline bool
+	// foot holds the comments after the last element of an array
+	foot   []tomlComment
+	offset int
+}
+
+type tomlArrayItem struct {
+	comments []tomlComment
+	value    *tomlValue
+	comment  string
+}
+
+type tomlKeyValue struct {
+	key   tomlKey
+	value *tomlValue
+}
+
+type tomlStatementKind int
+
+const (
+	tomlPair tomlStatementKind = iota
+	tomlTable
+	tomlArrayTable
+)
+
+// tomlStatement is a key/value pair or a table header, with the comments
+// on the lines above it and the comment ending its line
+type tomlStatement struct {
+	kind     tomlStatementKind
+	comments []tomlComment
+	blank    bool // a blank line comes between the comments and the statement
+	pair     *tomlKeyValue
+	key      tomlKey
+	comment  string
+}
+
+type tomlDocument struct {
+	statements []*tomlStatement
+	foot       []tomlComment
+	comments   int
+}
+
+type tomlParser struct {
+	src      string
+	pos      int
+	depth    int
+	pending  []tomlComment
+	blank    bool
+	comments int
+}
+
+// parseTOML reads a TOML document, keeping its comments and the way
+// values are written. Line breaks are read as \n; offsets in errors still
+// count the \r of \r\n.
+func parseTOML(src string) (*tomlDocument, error) {
+	text := strings.ReplaceAll(src, "\r\n", "\n")
+	p := &tomlParser{src: text}
+	doc, err := p.document()
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) && len(text) != len(src) {
+		syntaxErr.Offset = crlfOffset(src, syntaxErr.Offset)
+	}
+	return doc, err
+}
+
+func (p *tomlParser) errorf(offset int, format string, args ...interface{}) error {
+	return tomlErrorf(p.src, offset, format, args...)
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *tomlParser) skipSpaces() {
+	for !p.eof() && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// describe names what is at offset for error messages
+func (p *tomlParser) describe(offset int) string {
+	if offset >= len(p.src) {
+		return "end of document"
+	}
+	if p.src[offset] == '\n' {
+		return "end of line"
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[offset:])
+	return fmt.Sprintf("%q", r)
+}
+
+// comment reads a comment at p.pos up to the end of its line
+func (p *tomlParser) comment() (string, error) {
+	start := p.pos
+	for !p.eof() && p.src[p.pos] != '\n' {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if r == utf8.RuneError && size == 1 {
+			return "", p.errorf(p.pos, "invalid UTF-8")
+		}
+		if r != '\t' && (r < 0x20 || r == 0x7f) {
+			return "", p.errorf(p.pos, "control characters are not allowed in comments")
+		}
+		p.pos += size
+	}
+	p.comments++
+	return p.src[start:p.pos], nil
+}
+
+// skipLines skips blank lines and collects comment lines until content
+func (p *tomlParser) skipLines() error {
+	lineStart := true
+	for !p.eof() {
+		p.skipSpaces()
+		switch {
+		case p.eof():
+			return nil
+		case p.src[p.pos] == '\n':
+			if lineStart {
+				p.blank = true
+			}
+			p.pos++
+			lineStart = true
+		case p.src[p.pos] == '#':
+			text, err := p.comment()
+			if err != nil {
+				return err
+			}
+			p.pending = append(p.pending, tomlComment{text: text, blank: p.blank})
+			p.blank = false
+			lineStart = false
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// endOfLine reads the optional comment ending a statement and the line
+// break after it
+func (p *tomlParser) endOfLine(what string) (string, error) {
+	p.skipSpaces()
+	var comment string
+	if !p.eof() && p.src[p.pos] == '#' {
+		var err error
+		if comment, err = p.comment(); err != nil {
+			return "", err
+		}
+	}
+	if !p.eof() && p.src[p.pos] != '\n' {
+		return "", p.errorf(p.pos, "expected a new line after %s, found %s", what, p.describe(p.pos))
+	}
+	if !p.eof() {
+		p.pos++
+	}
+	return comment, nil
+}
+
+func (p *tomlParser) document() (*tomlDocument, error) {
+	if strings.HasPrefix(p.src, "\uFEFF") {
+		p.pos += len("\uFEFF")
+	}
+	doc := &tomlDocument{}
+	for {
+		if err := p.skipLines(); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			break
+		}
+		st := &tomlStatement{comments: p.pending, blank: p.blank}
+		p.pending, p.blank = nil, false
+		var err error
+		what := "a key/value pair"
+		if p.src[p.pos] == '[' {
+			what = "a table header"
+			err = p.header(st)
+		} else {
+			st.pair, err = p.keyValue()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if st.comment, err = p.endOfLine(what); err != nil {
+			return nil, err
+		}
+		doc.statements = append(doc.statements, st)
+	}
+	doc.foot = p.pending
+	doc.comments = p.comments
+	return doc, nil
+}
+
+func (p *tomlParser) header(st *tomlStatement) error {
+	st.kind = tomlTable
+	p.pos++
+	if !p.eof() && p.src[p.pos] == '[' {
+		st.kind = tomlArrayTable
+		p.pos++
+	}
+	p.skipSpaces()
+	key, err := p.key()
+	if err != nil {
+		return err
+	}
+	st.key = key
+	p.skipSpaces()
+	closing := "]"
+	if st.kind == tomlArrayTable {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return p.errorf(p.pos, "expected %q to close the header, found %s", closing, p.describe(p.pos))
+	}
+	p.pos += len(closing)
+	return nil
+}
+
+func (p *tomlParser) keyValue() (*tomlKeyValue, error) {
+	key, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.eof() || p.src[p.pos] != '=' {
+		return nil, p.errorf(p.pos, "expected '=' after key %s, found %s", key, p.describe(p.pos))
+	}
+	p.pos++
+	p.skipSpaces()
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	return &tomlKeyValue{key: key, value: value}, nil
+}
+
+func isTOMLBareKeyChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// key reads a dotted key
+func (p *tomlParser) key() (tomlKey, error) {
+	key := tomlKey{offset: p.pos}
+	for {
+		start := p.pos
+		var part string
+		switch {
+		case p.eof():
+			return key, p.errorf(p.pos, "expected a key, found end of document")
+		case p.src[p.pos] == '"' || p.src[p.pos] == '\'':
+			if strings.HasPrefix(p.src[p.pos:], `"""`) || strings.HasPrefix(p.src[p.pos:], `'''`) {
+				return key, p.errorf(p.pos, "keys cannot be multi-line strings")
+			}
+			s, err := p.quoted()
+			if err != nil {
+				return key, err
+			}
+			part = s
+		case isTOMLBareKeyChar(p.src[p.pos]):
+			for !p.eof() && isTOMLBareKeyChar(p.src[p.pos]) {
+				p.pos++
+			}
+			part = p.src[start:p.pos]
+		default:
+			return key, p.errorf(p.pos, "expected a key, found %s", p.describe(p.pos))
+		}
+		key.raw = append(key.raw, p.src[start:p.pos])
+		key.parts = append(key.parts, part)
+
+		save := p.pos
+		p.skipSpaces()
+		if p.eof() || p.src[p.pos] != '.' {
+			p.pos = save
+			return key, nil
+		}
+		p.pos++
+		p.skipSpaces()
+	}
+}
+
+var (
+	tomlDecimalPattern = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)$`)
+	tomlHexPattern     = regexp.MustCompile(`^0x[0-9a-fA-F](_?[0-9a-fA-F])*$`)
+	tomlOctalPattern   = regexp.MustCompile(`^0o[0-7](_?[0-7])*$`)
+	tomlBinaryPattern  = regexp.MustCompile(`^0b[01](_?[01])*$`)
+	tomlFloatPattern   = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][-+]?[0-9](_?[0-9])*)?$`)
+	tomlSpecialPattern = regexp.MustCompile(`^[-+]?(inf|nan)$`)
+	tomlDatePattern    = regexp.MustCompile(`^([0-9]{4})-([0-9]{2})-([0-9]{2})$`)
+	tomlTimePattern    = regexp.MustCompile(`^([0-9]{2}):([0-9]{2}):([0-9]{2})(\.[0-9]+)?$`)
+	tomlOffsetPattern  = regexp.MustCompile(`^([Zz]|[-+]([0-9]{2}):([0-9]{2}))$`)
+)
+
+func (p *tomlParser) value() (*tomlValue, error) {
+	if p.eof() || p.src[p.pos] == '\n' {
+		return nil, p.errorf(p.pos, "expected a value, found %s", p.describe(p.pos))
+	}
+	start := p.pos
+	switch p.src[p.pos] {
+	case '"', '\'':
+		s, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		return &tomlValue{kind: tomlString, raw: p.src[start:p.pos], str: s, offset: start}, nil
+	case '[':
+		return p.array()
+	case '{':
+		return p.inlineTable()
+	}
+
+	for !p.eof() && strings.IndexByte(" \t\n,]}#", p.src[p.pos]) < 0 {
+		p.pos++
+	}
+	// A date and a time may be separated by a space
+	if tomlDatePattern.MatchString(p.src[start:p.pos]) && p.pos+3 < len(p.src) && p.src[p.pos] == ' ' &&
+		isDigit(rune(p.src[p.pos+1])) && isDigit(rune(p.src[p.pos+2])) && p.src[p.pos+3] == ':' {
+		p.pos++
+		for !p.eof() && strings.IndexByte(" \t\n,]}#", p.src[p.pos]) < 0 {
+			p.pos++
+		}
+	}
+	raw := p.src[start:p.pos]
+	v := &tomlValue{raw: raw, offset: start}
+	switch {
+	case raw == "true" || raw == "false":
+		v.kind = tomlBool
+	case tomlDecimalPattern.MatchString(raw):
+		v.kind = tomlInteger
+		if _, err := strconv.ParseInt(strings.ReplaceAll(raw, "_", ""), 10, 64); err != nil {
+			return nil, p.errorf(start, "integer %s is out of range", raw)
+		}
+	case tomlHexPattern.MatchString(raw) || tomlOctalPattern.MatchString(raw) || tomlBinaryPattern.MatchString(raw):
+		v.kind = tomlInteger
+		if _, err := strconv.ParseInt(strings.ReplaceAll(raw, "_", ""), 0, 64); err != nil {
+			return nil, p.errorf(start, "integer %s is out of range", raw)
+		}
+	case tomlFloatPattern.MatchString(raw) || tomlSpecialPattern.MatchString(raw):
+		v.kind = tomlFloat
+	case raw == "":
+		return nil, p.errorf(start, "expected a value, found %s", p.describe(start))
+	default:
+		if err := validTOMLDateTime(raw); err != "" {
+			return nil, p.errorf(start, "invalid value %s: %s", raw, err)
+		}
+		v.kind = tomlDateTime
+	}
+	return v, nil
+}
+
+// validTOMLDateTime checks a date, a time or both, returning what is
+// wrong with it or "" when it is valid
+func validTOMLDateTime(raw string) string {
+	date, rest := raw, ""
+	if len(raw) > 10 && (raw[10] == 'T' || raw[10] == 't' || raw[10] == ' ') {
+		date, rest = raw[:10], raw[11:]
+	}
+	m := tomlDatePattern.FindStringSubmatch(date)
+	if m == nil {
+		if tomlTimePattern.MatchString(raw) {
+			return checkTOMLTime(raw)
+		}
+		return "not a number, date or time"
+	}
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	if month < 1 || month > 12 {
+		return "month out of range"
+	}
+	days := []int{31, 28, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}[month-1]
+	if month == 2 && year%4 == 0 && (year%100 != 0 || year%400 == 0) {
+		days = 29
+	}
+	if day < 1 || day > days {
+		return "day out of range"
+	}
+	if len(raw) == 10 {
+		return ""
+	}
+	if rest == "" {
+		return "not a number, date or time"
+	}
+
+	clock := rest
+	if i := strings.IndexAny(rest, "Zz+-"); i >= 0 {
+		clock = rest[:i]
+		m := tomlOffsetPattern.FindStringSubmatch(rest[i:])
+		if m == nil {
+			return "invalid time offset"
+		}
+		if m[2] != "" {
+			hour, _ := strconv.Atoi(m[2])
+			minute, _ := strconv.Atoi(m[3])
+			if hour > 23 || minute > 59 {
+				return "time offset out of range"
+			}
+		}
+	}
+	if !tomlTimePattern.MatchString(clock) {
+		return "invalid time"
+	}
+	return checkTOMLTime(clock)
+}
+
+func checkTOMLTime(clock string) string {
+	m := tomlTimePattern.FindStringSubmatch(clock)
+	hour, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	second, _ := strconv.Atoi(m[3])
+	if hour > 23 || minute > 59 || second > 60 {
+		return "time out of range"
+	}
+	return ""
+}
+
+// quoted reads a basic or literal string, single- or multi-line, and
+// returns its content
+func (p *tomlParser) quoted() (string, error) {
+	start := p.pos
+	q := p.src[p.pos]
+	multi := strings.HasPrefix(p.src[p.pos:], strings.Repeat(string(q), 3))
+	if multi {
+		p.pos += 3
+		// A line break right after the opening quotes is not content
+		if strings.HasPrefix(p.src[p.pos:], "\n") {
+			p.pos++
+		}
+	} else {
+		p.pos++
+	}
+
+	var b strings.Builder
+	for {
+		if p.eof() || !multi && p.src[p.pos] == '\n' {
+			return "", p.errorf(start, "unterminated string")
+		}
+		c := p.src[p.pos]
+		if c == q {
+			if !multi {
+				p.pos++
+				return b.String(), nil
+			}
+			if strings.HasPrefix(p.src[p.pos:], strings.Repeat(string(q), 3)) {
+				// Up to two quotes may end the content
+				n := 3
+				for n < 5 && p.pos+n < len(p.src) && p.src[p.pos+n] == q {
+					n++
+				}
+				b.WriteString(strings.Repeat(string(q), n-3))
+				p.pos += n
+				return b.String(), nil
+			}
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		if c == '\\' && q == '"' {
+			if err := p.escape(&b, multi); err != nil {
+				return "", err
+			}
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if r == utf8.RuneError && size == 1 {
+			return "", p.errorf(p.pos, "invalid UTF-8")
+		}
+		if r != '\t' && r != '\n' && (r < 0x20 || r == 0x7f) {
+			return "", p.errorf(p.pos, "control characters must be escaped")
+		}
+		b.WriteRune(r)
+		p.pos += size
+	}
+}
+
+var tomlEscapes = map[byte]string{
+	'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", '"': "\"", '\\': "\\",
+}
+
+// escape reads an escape sequence in a basic string
+func (p *tomlParser) escape(b *strings.Builder, multi bool) error {
+	start := p.pos
+	p.pos++
+	if p.eof() {
+		return p.errorf(start, "unterminated string")
+	}
+	c := p.src[p.pos]
+	if s, ok := tomlEscapes[c]; ok {
+		b.WriteString(s)
+		p.pos++
+		return nil
+	}
+	switch c {
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		hex := p.src[p.pos+1 : min(p.pos+1+size, len(p.src))]
+		if len(hex) != size || !isHexString(hex) {
+			return p.errorf(start, "invalid unicode escape")
+		}
+		code, _ := strconv.ParseUint(hex, 16, 32)
+		if code > utf8.MaxRune || code >= 0xD800 && code <= 0xDFFF {
+			return p.errorf(start, "escape \\%c%s is not a unicode scalar value", c, hex)
+		}
+		b.WriteRune(rune(code))
+		p.pos += 1 + size
+		return nil
+	}
+	if multi {
+		// A backslash ending a line trims the line break and the white
+		// space that follows
+		i := p.pos
+		for i < len(p.src) && (p.src[i] == ' ' || p.src[i] == '\t') {
+			i++
+		}
+		if i < len(p.src) && p.src[i] == '\n' {
+			for i < len(p.src) && strings.IndexByte(" \t\n", p.src[i]) >= 0 {
+				i++
+			}
+			p.pos = i
+			return nil
+		}
+	}
+	return p.errorf(start, "invalid escape sequence \\%c", c)
+}
+
+// skipArraySpace skips white space, line breaks and comments inside an
+// array, collecting the comments
+func (p *tomlParser) skipArraySpace(v *tomlValue) error {
+	lineStart := false
+	for !p.eof() {
+		p.skipSpaces()
+		switch {
+		case p.eof():
+			return nil
+		case p.src[p.pos] == '\n':
+			v.multiline = true
+			if lineStart {
+				p.blank = true
+			}
+			p.pos++
+			lineStart = true
+		case p.src[p.pos] == '#':
+			text, err := p.comment()
+			if err != nil {
+				return err
+			}
+			v.multiline = true
+			p.pending = append(p.pending, tomlComment{text: text, blank: p.blank})
+			p.blank = false
+			lineStart = false
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *tomlParser) enter(offset int) error {
+	if p.depth++; p.depth > maxTOMLDepth {
+		return p.errorf(offset, "exceeded max nesting depth")
+	}
+	return nil
+}
+
+func (p *tomlParser) array() (*tomlValue, error) {
+	v := &tomlValue{kind: tomlArray, offset: p.pos}
+	if err := p.enter(p.pos); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	// Comments before the array belong to the statement
+	outer, outerBlank := p.pending, p.blank
+	p.pending, p.blank = nil, false
+	defer func() { p.pending, p.blank = outer, outerBlank }()
+
+	p.pos++
+	var last *tomlArrayItem
+	for {
+		if err := p.skipArraySpace(v); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return nil, p.errorf(v.offset, "unclosed [")
+		}
+		if p.src[p.pos] == ']' {
+			p.pos++
+			v.foot, p.pending = p.pending, nil
+			return v, nil
+		}
+		if last != nil {
+			if p.src[p.pos] != ',' {
+				return nil, p.errorf(p.pos, "expected ',' or ']' after an array element, found %s", p.describe(p.pos))
+			}
+			p.pos++
+			p.skipSpaces()
+			if !p.eof() && p.src[p.pos] == '#' && last.comment == "" {
+				var err error
+				if last.comment, err = p.comment(); err != nil {
+					return nil, err
+				}
+			}
+			last = nil
+			continue
+		}
+
+		item := &tomlArrayItem{comments: p.pending}
+		p.pending, p.blank = nil, false
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		item.value = value
+		v.items = append(v.items, item)
+		last = item
+		p.skipSpaces()
+		if !p.eof() && p.src[p.pos] == '#' {
+			if item.comment, err = p.comment(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (p *tomlParser) inlineTable() (*tomlValue, error) {
+	v := &tomlValue{kind: tomlInlineTable, offset: p.pos}
+	if err := p.enter(p.pos); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	p.pos++
+	p.skipSpaces()
+	if !p.eof() && p.src[p.pos] == '}' {
+		p.pos++
+		return v, nil
+	}
+	for {
+		p.skipSpaces()
+		if p.eof() || p.src[p.pos] == '\n' {
+			return nil, p.errorf(p.pos, "inline tables must be written on one line")
+		}
+		if p.src[p.pos] == '}' {
+			return nil, p.errorf(p.pos, "trailing comma in an inline table")
+		}
+		pair, err := p.keyValue()
+		if err != nil {
+			return nil, err
+		}
+		v.pairs = append(v.pairs, pair)
+		p.skipSpaces()
+		switch {
+		case p.eof() || p.src[p.pos] == '\n':
+			return nil, p.errorf(p.pos, "inline tables must be written on one line")
+		case p.src[p.pos] == ',':
+			p.pos++
+		case p.src[p.pos] == '}':
+			p.pos++
+			return v, nil
+		default:
+			return nil, p.errorf(p.pos, "expected ',' or '}' after a key/value pair, found %s", p.describe(p.pos))
+		}
+	}
+}
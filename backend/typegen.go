@@ -0,0 +1,532 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// typeKind is a set of the kinds of value seen at one place in the data
+type typeKind int
+
+const (
+	kindNull typeKind = 1 << iota
+	kindBool
+	kindInt
+	// kindUint is an integer beyond int64 that fits uint64, and kindBigInt
+	// one beyond both
+	kindUint
+	kindBigInt
+	kindFloat
+	kindString
+	kindTime
+	kindObject
+	kindArray
+)
+
+// typeShape merges every value seen at one place in sample data: the
+// fields of all objects there and the items of all arrays there
+type typeShape struct {
+	kinds   typeKind
+	keys    []string // object keys in the order they first appear
+	fields  map[string]*typeField
+	objects int
+	items   *typeShape
+	// negative is set once a negative integer is seen
+	negative bool
+	// path locates the shape for warnings, as in items[].id
+	path string
+	// name is the name of the type declared for objects, and of is the
+	// shape of the same structure whose type is reused instead
+	name string
+	of   *typeShape
+}
+
+// typeField is an object key and how many of the objects had it
+type typeField struct {
+	shape *typeShape
+	count int
+}
+
+func newTypeShape(path string) *typeShape {
+	return &typeShape{fields: make(map[string]*typeField), path: path}
+}
+
+// add merges a value into the shape
+func (s *typeShape) add(value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		s.kinds |= kindNull
+	case bool:
+		s.kinds |= kindBool
+	case dataNumber:
+		if _, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			s.kinds |= kindInt
+			s.negative = s.negative || strings.HasPrefix(string(v), "-")
+		} else if _, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			s.kinds |= kindUint
+		} else if integerPattern.MatchString(string(v)) {
+			s.kinds |= kindBigInt
+		} else {
+			s.kinds |= kindFloat
+		}
+	case string:
+		if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			s.kinds |= kindTime
+		} else {
+			s.kinds |= kindString
+		}
+	case []interface{}:
+		s.kinds |= kindArray
+		if s.items == nil {
+			s.items = newTypeShape(s.path + "[]")
+		}
+		for _, item := range v {
+			s.items.add(item)
+		}
+	case *dataMap:
+		s.kinds |= kindObject
+		s.objects++
+		for _, key := range v.keys {
+			f, ok := s.fields[key]
+			if !ok {
+				path := key
+				if s.path != "" {
+					path = s.path + "." + key
+				}
+				f = &typeField{shape: newTypeShape(path)}
+				s.fields[key] = f
+				s.keys = append(s.keys, key)
+			}
+			f.count++
+			f.shape.add(v.values[key])
+		}
+	}
+}
+
+// integerPattern matches the number literals that are integers
+var integerPattern = regexp.MustCompile(`^-?[0-9]+$`)
+
+// resolved returns the kinds a type is written for: integers seen with
+// floats are floats, integers are of the smallest integer kind holding all
+// of them, and times seen with other text are strings
+func (s *typeShape) resolved() typeKind {
+	k := s.kinds &^ kindNull
+	switch {
+	case k&kindFloat != 0:
+		k &^= kindInt | kindUint | kindBigInt
+	case k&kindBigInt != 0, k&kindUint != 0 && s.negative:
+		k = k&^(kindInt|kindUint) | kindBigInt
+	case k&kindUint != 0:
+		k &^= kindInt
+	}
+	if k&kindString != 0 {
+		k &^= kindTime
+	}
+	return k
+}
+
+// nullable reports whether the value is null in some samples only
+func (s *typeShape) nullable() bool {
+	return s.kinds&kindNull != 0 && s.resolved() != 0
+}
+
+// optional reports whether some objects lack the field
+func (s *typeShape) optional(key string) bool {
+	return s.fields[key].count < s.objects
+}
+
+// single reports whether k holds exactly one kind
+func (k typeKind) single() bool {
+	return k != 0 && k&(k-1) == 0
+}
+
+// signature describes the structure of a shape, to find objects of the
+// same structure at different places
+func (s *typeShape) signature() string {
+	sig := strconv.Itoa(int(s.kinds)) + s.fieldSignature()
+	if s.items != nil {
+		sig += "[" + s.items.signature() + "]"
+	}
+	return sig
+}
+
+// fieldSignature describes the fields of the objects of a shape
+func (s *typeShape) fieldSignature() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for _, key := range s.keys {
+		b.WriteString(strconv.Quote(key))
+		if s.optional(key) {
+			b.WriteByte('?')
+		}
+		b.WriteString(":" + s.fields[key].shape.signature() + ",")
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// typeNamer names the object types of the data breadth first, so types
+// nearer the root get the plain names
+type typeNamer struct {
+	initialisms bool
+	taken       map[string]*typeShape
+	signatures  map[string]*typeShape
+	shapes      []*typeShape
+}
+
+// name names the object type of s after base, unless an object type of
+// the same structure is already declared. An array passes the name on to
+// its items in the singular.
+func (n *typeNamer) name(s *typeShape, base, parent string) {
+	if s.kinds&kindObject != 0 {
+		sig := s.fieldSignature()
+		if other, ok := n.signatures[sig]; ok {
+			s.of = other
+			return
+		}
+		name := base
+		if _, ok := n.taken[name]; ok && parent != "" {
+			name = parent + base
+		}
+		for i := 2; n.taken[name] != nil; i++ {
+			name = base + strconv.Itoa(i)
+		}
+		s.name = name
+		n.taken[name] = s
+		n.signatures[sig] = s
+		n.shapes = append(n.shapes, s)
+	}
+	if s.items != nil {
+		n.name(s.items, singular(base), parent)
+	}
+}
+
+// typeName returns the name of the type declared for an object shape
+func (s *typeShape) typeName() string {
+	if s.of != nil {
+		return s.of.name
+	}
+	return s.name
+}
+
+// goInitialisms are written in capitals in Go identifiers
+var goInitialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true,
+	"EOF": true, "GUID": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true,
+	"IP": true, "JSON": true, "QPS": true, "RAM": true, "RPC": true, "SLA": true,
+	"SMTP": true, "SQL": true, "SSH": true, "TCP": true, "TLS": true, "TTL": true,
+	"UDP": true, "UI": true, "UID": true, "URI": true, "URL": true, "UTF8": true,
+	"UUID": true, "VM": true, "XML": true, "XSRF": true, "XSS": true,
+}
+
+// typeIdentifier turns a key into an exported identifier, splitting it
+// into words at separators and case changes
+func typeIdentifier(key string, initialisms bool) string {
+	var words []string
+	var word []rune
+	runes := []rune(key)
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		// A word starts at a capital after a lower case letter, or before
+		// a lower case letter after capitals, as in HTTPServer
+		if unicode.IsUpper(r) && len(word) > 0 {
+			prev := runes[i-1]
+			next := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && next {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+
+	var b strings.Builder
+	for _, w := range words {
+		upper := strings.ToUpper(w)
+		if initialisms && goInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		if upper == w {
+			w = strings.ToLower(w)
+		}
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "Field" + name
+	}
+	return name
+}
+
+// singular returns the name of the items of an array named name
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 4:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"), strings.HasSuffix(name, "ches"), strings.HasSuffix(name, "shes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 3:
+		return name[:len(name)-1]
+	}
+	return name + "Item"
+}
+
+// inferTypes merges sample data into shapes and names their object types
+// after the keys holding them. A root object's type is named name.
+func inferTypes(value interface{}, name string, initialisms bool) (*typeShape, []*typeShape) {
+	root := newTypeShape("")
+	root.add(value)
+	n := &typeNamer{
+		initialisms: initialisms,
+		taken:       make(map[string]*typeShape),
+		signatures:  make(map[string]*typeShape),
+	}
+	// Other data is declared as a type named name of its own
+	if root.resolved() != kindObject {
+		n.taken[name] = root
+	}
+	n.name(root, name, "")
+	for i := 0; i < len(n.shapes); i++ {
+		s := n.shapes[i]
+		for _, key := range s.keys {
+			n.name(s.fields[key].shape, typeIdentifier(key, initialisms), s.name)
+		}
+	}
+	return root, n.shapes
+}
+
+// goTagPattern matches the keys encoding/json can name in a struct tag
+var goTagPattern = regexp.MustCompile("^[\\p{L}\\p{N}!#$%&()*+\\-./:;<=>?@\\[\\]^_{|}~ ]+$")
+
+// goTypes writes Go type declarations for sample data, with json tags
+// naming the keys
+func goTypes(value interface{}, opts *FormatOptions, log *conversionLog) (string, error) {
+	name := opts.typeName()
+	root, shapes := inferTypes(value, name, true)
+	g := &goTypeWriter{log: log}
+	var decls strings.Builder
+	if root.resolved() != kindObject {
+		fmt.Fprintf(&decls, "type %s %s\n\n", name, g.typ(root))
+	}
+	for _, s := range shapes {
+		g.object(&decls, s)
+	}
+	var b strings.Builder
+	switch {
+	case g.json && g.time:
+		b.WriteString("import (\n\"encoding/json\"\n\"time\"\n)\n\n")
+	case g.json:
+		b.WriteString("import \"encoding/json\"\n\n")
+	case g.time:
+		b.WriteString("import \"time\"\n\n")
+	}
+	b.WriteString(decls.String())
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+type goTypeWriter struct {
+	log *conversionLog
+	// json and time are set once a type of the package is used
+	json bool
+	time bool
+}
+
+func (g *goTypeWriter) object(b *strings.Builder, s *typeShape) {
+	fmt.Fprintf(b, "type %s struct {\n", s.name)
+	names := make(map[string]bool)
+	for _, key := range s.keys {
+		if !goTagPattern.MatchString(key) {
+			g.log.warnf("key %q cannot be named in a json tag and was left out", key)
+			continue
+		}
+		field := s.fields[key].shape
+		name := typeIdentifier(key, true)
+		for i := 2; names[name]; i++ {
+			name = typeIdentifier(key, true) + strconv.Itoa(i)
+		}
+		names[name] = true
+
+		typ := g.typ(field)
+		tag := key
+		if s.optional(key) {
+			tag += ",omitempty"
+			// omitempty leaves out nil pointers but never structs
+			if field.resolved() == kindObject && !field.nullable() {
+				typ = "*" + typ
+			}
+		}
+		fmt.Fprintf(b, "%s %s `json:%s`\n", name, typ, strconv.Quote(tag))
+	}
+	b.WriteString("}\n\n")
+}
+
+// typ returns the Go type of the values of a shape
+func (g *goTypeWriter) typ(s *typeShape) string {
+	k := s.resolved()
+	if !k.single() {
+		if k != 0 {
+			g.log.warnf("%s holds values of different types, so its type is any", s.describe())
+		}
+		return "any"
+	}
+	var typ string
+	switch k {
+	case kindBool:
+		typ = "bool"
+	case kindInt:
+		typ = "int64"
+	case kindUint:
+		typ = "uint64"
+	case kindBigInt:
+		g.log.warnf("%s holds integers too large for uint64, so its type is json.Number", s.describe())
+		g.json = true
+		typ = "json.Number"
+	case kindFloat:
+		typ = "float64"
+	case kindString:
+		typ = "string"
+	case kindTime:
+		g.time = true
+		typ = "time.Time"
+	case kindObject:
+		typ = s.typeName()
+	case kindArray:
+		return "[]" + g.typ(s.items)
+	}
+	if s.nullable() {
+		typ = "*" + typ
+	}
+	return typ
+}
+
+// describe names a shape in warnings
+func (s *typeShape) describe() string {
+	if s.path == "" {
+		return "the data"
+	}
+	return s.path
+}
+
+var tsIdentifierPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsTypes writes TypeScript interfaces for sample data
+func tsTypes(value interface{}, opts *FormatOptions, log *conversionLog) (string, error) {
+	name := opts.typeName()
+	root, shapes := inferTypes(value, name, false)
+	var b strings.Builder
+	if root.resolved() != kindObject {
+		fmt.Fprintf(&b, "export type %s = %s;\n\n", name, tsType(root, log))
+	}
+	for _, s := range shapes {
+		fmt.Fprintf(&b, "export interface %s {\n", s.name)
+		for _, key := range s.keys {
+			property := key
+			if !tsIdentifierPattern.MatchString(key) {
+				property = jsonQuote(key)
+			}
+			if s.optional(key) {
+				property += "?"
+			}
+			fmt.Fprintf(&b, "%s: %s;\n", property, tsType(s.fields[key].shape, log))
+		}
+		b.WriteString("}\n\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// tsType returns the TypeScript type of the values of a shape, a union
+// when they are of different kinds
+func tsType(s *typeShape, log *conversionLog) string {
+	var types []string
+	k := s.resolved()
+	if k&kindBool != 0 {
+		types = append(types, "boolean")
+	}
+	if k&(kindInt|kindFloat) != 0 {
+		types = append(types, "number")
+	}
+	if k&(kindUint|kindBigInt) != 0 {
+		// Beyond int64 integers are far past what a number holds exactly
+		log.warnf("%s holds integers too large for a number, so its type is bigint, which JSON.parse does not produce", s.describe())
+		types = append(types, "bigint")
+	}
+	if k&(kindString|kindTime) != 0 {
+		types = append(types, "string")
+	}
+	if k&kindObject != 0 {
+		types = append(types, s.typeName())
+	}
+	if k&kindArray != 0 {
+		items := tsType(s.items, log)
+		if strings.Contains(items, " ") {
+			items = "(" + items + ")"
+		}
+		types = append(types, items+"[]")
+	}
+	if s.kinds&kindNull != 0 {
+		types = append(types, "null")
+	}
+	if k == 0 {
+		return "unknown"
+	}
+	return strings.Join(types, " | ")
+}
+
+// GenerateTypes reads sample data in the from language and declares types
+// describing it in the to language. The output is formatted by the target
+// backend with opts.
+func (f *Formatter) GenerateTypes(code, from, to string, opts *FormatOptions) (*Conversion, error) {
+	source, err := f.lookup(code, from, opts)
+	if err != nil {
+		return nil, err
+	}
+	target, ok := f.registry.Lookup(to)
+	if !ok {
+		return nil, fmt.Errorf("unsupported language: %s", to)
+	}
+	switch {
+	case source.Decode == nil:
+		return nil, fmt.Errorf("type generation from %s is not supported", source.Name)
+	case target.Types == nil:
+		return nil, fmt.Errorf("type generation for %s is not supported", target.Name)
+	}
+
+	log := &conversionLog{}
+	value, err := source.Decode(code, opts, log)
+	if err != nil {
+		return nil, err
+	}
+	declarations, err := target.Types(value, opts, log)
+	if err != nil {
+		return nil, err
+	}
+	result, err := f.FormatWithOptions(declarations, target.Name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	supported := []string{optionTypeName}
+	supported = append(supported, target.FormatSupports...)
+	supported = append(supported, source.ConvertSupports...)
+	return &Conversion{
+		Code:           result.Code,
+		Warnings:       log.warnings,
+		IgnoredOptions: ignoredOptions(opts, supported),
+	}, nil
+}
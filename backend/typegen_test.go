@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerateTypesIntegers(t *testing.T) {
+	tests := []struct {
+		name     string
+		to       string
+		code     string
+		want     string
+		warnings []string
+	}{
+		{"int64", "go", `[1, -2]`, "type Root []int64\n", nil},
+		{"uint64", "go", `[1, 18446744073709551615]`, "type Root []uint64\n", nil},
+		{
+			"beyond uint64", "go", `{"id": 99999999999999999999}`,
+			"import \"encoding/json\"\n\ntype Root struct {\n\tID json.Number `json:\"id\"`\n}\n",
+			[]string{"id holds integers too large for uint64, so its type is json.Number"},
+		},
+		{
+			"negative and beyond int64", "go", `[-1, 9223372036854775808]`,
+			"import \"encoding/json\"\n\ntype Root []json.Number\n",
+			[]string{"[] holds integers too large for uint64, so its type is json.Number"},
+		},
+		{
+			"with a time", "go", `{"id": 99999999999999999999, "at": "2024-05-01T09:30:00Z"}`,
+			"import (\n\t\"encoding/json\"\n\t\"time\"\n)\n\ntype Root struct {\n\tID json.Number `json:\"id\"`\n\tAt time.Time   `json:\"at\"`\n}\n",
+			[]string{"id holds integers too large for uint64, so its type is json.Number"},
+		},
+		{"with floats", "go", `[1.5, 18446744073709551615]`, "type Root []float64\n", nil},
+		{"number", "typescript", `{"n": 9223372036854775807}`, "export interface Root {\n  n: number;\n}\n", nil},
+		{
+			"bigint", "typescript", `{"n": 18446744073709551615}`,
+			"export interface Root {\n  n: bigint;\n}\n",
+			[]string{"n holds integers too large for a number, so its type is bigint, which JSON.parse does not produce"},
+		},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := f.GenerateTypes(tt.code, "json", tt.to, &FormatOptions{})
+			if err != nil {
+				t.Fatalf("GenerateTypes(%q) error: %v", tt.code, err)
+			}
+			if result.Code != tt.want {
+				t.Errorf("GenerateTypes(%q) = %q, want %q", tt.code, result.Code, tt.want)
+			}
+			if !reflect.DeepEqual(result.Warnings, tt.warnings) {
+				t.Errorf("GenerateTypes(%q) warnings = %q, want %q", tt.code, result.Warnings, tt.warnings)
+			}
+		})
+	}
+}
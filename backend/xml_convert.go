@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// xmlRootName names the element wrapping data that is not a mapping with
+// a single key
+const xmlRootName = "root"
+
+// xmlItemName names the elements holding the items of nested lists
+const xmlItemName = "item"
+
+// xmlDecoder reads XML into plain values. An element holding only text
+// becomes a string; any other element becomes a mapping of its attributes,
+// under keys starting with the attribute prefix, its child elements by
+// name, with repeated names gathered in lists, and its text under the
+// text key.
+type xmlDecoder struct {
+	text    string
+	log     *conversionLog
+	prefix  string
+	textKey string
+}
+
+func decodeXMLCode(code string, opts *FormatOptions, log *conversionLog) (interface{}, error) {
+	doc, err := parseXML(code)
+	if err != nil {
+		return nil, fmt.Errorf("invalid XML syntax: %w", err)
+	}
+	d := &xmlDecoder{
+		text:    strings.ReplaceAll(code, "\r\n", "\n"),
+		log:     log,
+		prefix:  opts.attributePrefix(),
+		textKey: opts.textKey(),
+	}
+	for _, n := range doc.nodes {
+		d.dropped(n)
+	}
+	root := newDataMap()
+	root.set(doc.root.name, d.element(doc.root))
+	return root, nil
+}
+
+func (d *xmlDecoder) line(n *xmlNode) int {
+	line, _ := lineColumn(d.text, n.offset)
+	return line
+}
+
+// dropped warns about a node that has no place in the data
+func (d *xmlDecoder) dropped(n *xmlNode) {
+	switch {
+	case n.kind == xmlComment:
+		d.log.warnf("comments were dropped")
+	case n.kind == xmlDoctype:
+		d.log.warnf("the document type declaration was dropped")
+	case n.kind == xmlProcInst && !strings.EqualFold(n.name, "xml"):
+		d.log.warnf("processing instructions were dropped")
+	}
+}
+
+func (d *xmlDecoder) element(n *xmlNode) interface{} {
+	var texts []string
+	var elements []*xmlNode
+	for _, child := range n.children {
+		switch child.kind {
+		case xmlText, xmlCDATA:
+			texts = append(texts, child.text)
+		case xmlElement:
+			elements = append(elements, child)
+		default:
+			d.dropped(child)
+		}
+		if child.kind == xmlText && strings.Contains(child.raw, "&") && strings.Contains(child.text, "&") {
+			d.entities(child)
+		}
+	}
+	text := strings.Join(texts, "")
+	if len(n.attrs) == 0 && len(elements) == 0 {
+		return text
+	}
+
+	m := newDataMap()
+	for _, a := range n.attrs {
+		m.set(d.prefix+a.name, a.value)
+	}
+	// Children of the same name are gathered where the first one is
+	var order []string
+	children := make(map[string][]interface{})
+	last := ""
+	for _, child := range elements {
+		if _, seen := children[child.name]; seen && child.name != last {
+			d.log.warnf("line %d: <%s> elements were gathered, changing their order among <%s> elements", d.line(child), child.name, n.name)
+		} else if !seen {
+			order = append(order, child.name)
+		}
+		children[child.name] = append(children[child.name], d.element(child))
+		last = child.name
+	}
+	for _, name := range order {
+		if m.has(name) {
+			d.log.warnf("line %d: <%s> elements and a %s attribute share the key %q", d.line(n), name, d.prefix+name, name)
+		}
+		if values := children[name]; len(values) == 1 {
+			m.set(name, values[0])
+		} else {
+			m.set(name, values)
+		}
+	}
+
+	if len(elements) > 0 {
+		// White space between elements is layout
+		var parts []string
+		for _, t := range texts {
+			if t = strings.TrimSpace(t); t != "" {
+				parts = append(parts, t)
+			}
+		}
+		if len(parts) > 1 {
+			d.log.warnf("line %d: the text of <%s> around its elements was joined", d.line(n), n.name)
+		}
+		text = strings.Join(parts, " ")
+	}
+	if text != "" {
+		m.set(d.textKey, text)
+	}
+	return m
+}
+
+// entities warns about references to entities a document type declares,
+// which are kept as written
+func (d *xmlDecoder) entities(n *xmlNode) {
+	for _, ref := range strings.Split(n.raw, "&")[1:] {
+		name, _, _ := strings.Cut(ref, ";")
+		if _, ok := xmlEntities[name]; !ok && !strings.HasPrefix(name, "#") {
+			d.log.warnf("line %d: entity &%s; was kept as written", d.line(n), name)
+		}
+	}
+}
+
+// xmlEncoder writes plain values as XML elements
+type xmlEncoder struct {
+	b       strings.Builder
+	log     *conversionLog
+	prefix  string
+	textKey string
+}
+
+func encodeXML(value interface{}, opts *FormatOptions, log *conversionLog) (string, error) {
+	e := &xmlEncoder{log: log, prefix: opts.attributePrefix(), textKey: opts.textKey()}
+	e.b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+	if m, ok := value.(*dataMap); ok && len(m.keys) == 1 {
+		if _, list := m.values[m.keys[0]].([]interface{}); !list && !strings.HasPrefix(m.keys[0], e.prefix) && m.keys[0] != e.textKey {
+			e.element(m.keys[0], m.values[m.keys[0]])
+			return e.b.String(), nil
+		}
+	}
+	log.warnf("the data was wrapped in a <%s> element, as an XML document has a single root element", xmlRootName)
+	if list, ok := value.([]interface{}); ok {
+		e.b.WriteString("<" + xmlRootName + ">")
+		e.element(xmlItemName, list)
+		e.b.WriteString("</" + xmlRootName + ">")
+		return e.b.String(), nil
+	}
+	e.element(xmlRootName, value)
+	return e.b.String(), nil
+}
+
+// element writes value as an element named key, or as one element per
+// item if it is a list
+func (e *xmlEncoder) element(key string, value interface{}) {
+	name := e.name(key)
+	switch v := value.(type) {
+	case nil:
+		e.log.warnf("null values were written as empty elements")
+		e.b.WriteString("<" + name + "/>")
+	case []interface{}:
+		if len(v) == 0 {
+			e.log.warnf("empty lists were dropped")
+		}
+		for _, item := range v {
+			if list, ok := item.([]interface{}); ok {
+				e.log.warnf("the items of nested lists were written as <%s> elements", xmlItemName)
+				e.b.WriteString("<" + name + ">")
+				e.element(xmlItemName, list)
+				e.b.WriteString("</" + name + ">")
+				continue
+			}
+			e.element(key, item)
+		}
+	case *dataMap:
+		e.b.WriteString("<" + name)
+		var text string
+		var children []string
+		for _, k := range v.keys {
+			switch {
+			case k == e.textKey:
+				text = e.scalar(k, v.values[k])
+			case strings.HasPrefix(k, e.prefix) && len(k) > len(e.prefix):
+				attr := e.name(k[len(e.prefix):])
+				e.b.WriteString(" " + attr + `="` + escapeXMLAttribute(e.scalar(k, v.values[k])) + `"`)
+			default:
+				children = append(children, k)
+			}
+		}
+		if text == "" && len(children) == 0 {
+			e.b.WriteString("/>")
+			return
+		}
+		e.b.WriteString(">" + escapeXMLText(text, e.log))
+		for _, k := range children {
+			e.element(k, v.values[k])
+		}
+		e.b.WriteString("</" + name + ">")
+	default:
+		text := e.scalar(key, v)
+		if text == "" {
+			e.b.WriteString("<" + name + "/>")
+			return
+		}
+		e.b.WriteString("<" + name + ">" + escapeXMLText(text, e.log) + "</" + name + ">")
+	}
+}
+
+// scalar returns the text of a value written as text or an attribute
+func (e *xmlEncoder) scalar(key string, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		return strconv.FormatBool(v)
+	case dataNumber:
+		return string(v)
+	case string:
+		return v
+	}
+	e.log.warnf("the value of %s is not text and was written as JSON", key)
+	json, _ := encodeJSON(value, nil, e.log)
+	return json
+}
+
+// name returns key if it can name an element, and otherwise key with the
+// characters an XML name cannot hold replaced
+func (e *xmlEncoder) name(key string) string {
+	if isXMLName(key) {
+		return key
+	}
+	var b strings.Builder
+	for i, r := range key {
+		if i == 0 && !isXMLNameStart(r) {
+			b.WriteByte('_')
+		}
+		if isXMLNameChar(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	name := b.String()
+	if name == "" {
+		name = "_"
+	}
+	e.log.warnf("key %q was written as <%s>", key, name)
+	return name
+}
+
+func escapeXMLText(s string, log *conversionLog) string {
+	s = xmlChars(s, log)
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#13;").Replace(s)
+}
+
+func escapeXMLAttribute(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\n", "&#10;", "\r", "&#13;", "\t", "&#9;").Replace(s)
+}
+
+// xmlChars replaces the characters XML 1.0 cannot hold
+func xmlChars(s string, log *conversionLog) string {
+	ok := true
+	for _, r := range s {
+		if !isXMLChar(r) || r == utf8.RuneError {
+			ok = false
+			break
+		}
+	}
+	if ok {
+		return s
+	}
+	log.warnf("characters XML cannot hold were replaced by %q", utf8.RuneError)
+	return strings.Map(func(r rune) rune {
+		if !isXMLChar(r) {
+			return utf8.RuneError
+		}
+		return r
+	}, s)
+}
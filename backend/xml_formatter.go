@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// xmlPrintWidth is the line width start tags are wrapped at
+const xmlPrintWidth = 80
+
+// xmlPrinter indents elements that hold only elements, comments and
+// processing instructions, one per line. Elements with text are printed
+// as written, since white space in them may matter, and so are elements
+// marked xml:space="preserve".
+type xmlPrinter struct {
+	out    strings.Builder
+	indent string
+	width  int
+}
+
+func formatXML(src string, opts *FormatOptions) (string, error) {
+	doc, err := parseXML(src)
+	if err != nil {
+		return "", err
+	}
+	p := &xmlPrinter{indent: opts.indent(2), width: opts.width(xmlPrintWidth)}
+	for _, n := range doc.nodes {
+		p.node(n, 0)
+	}
+	return p.out.String(), nil
+}
+
+// node prints a node on lines of its own at depth
+func (p *xmlPrinter) node(n *xmlNode, depth int) {
+	pad := strings.Repeat(p.indent, depth)
+	if n.kind != xmlElement {
+		p.out.WriteString(pad + strings.TrimSpace(n.raw) + "\n")
+		return
+	}
+	p.out.WriteString(pad)
+	p.startTag(n, depth)
+	if n.selfClosing {
+		p.out.WriteString("\n")
+		return
+	}
+	if !xmlElementContent(n) {
+		p.out.WriteString(xmlInnerText(n) + "</" + n.name + ">\n")
+		return
+	}
+	p.out.WriteString("\n")
+	for _, child := range n.children {
+		if child.kind != xmlText {
+			p.node(child, depth+1)
+		}
+	}
+	p.out.WriteString(pad + "</" + n.name + ">\n")
+}
+
+// startTag prints a start tag, with one attribute per line when they do
+// not fit in the print width
+func (p *xmlPrinter) startTag(n *xmlNode, depth int) {
+	end := ">"
+	if n.selfClosing {
+		end = "/>"
+	}
+	attrs := make([]string, len(n.attrs))
+	length := depth*utf8.RuneCountInString(p.indent) + 1 + len(n.name) + len(end)
+	for i, a := range n.attrs {
+		attrs[i] = a.name + "=" + a.raw
+		length += 1 + utf8.RuneCountInString(attrs[i])
+	}
+	p.out.WriteString("<" + n.name)
+	if len(attrs) > 1 && length > p.width && !strings.Contains(strings.Join(attrs, ""), "\n") {
+		pad := strings.Repeat(p.indent, depth+1)
+		for _, a := range attrs {
+			p.out.WriteString("\n" + pad + a)
+		}
+		p.out.WriteString("\n" + strings.Repeat(p.indent, depth) + end)
+		return
+	}
+	for _, a := range attrs {
+		p.out.WriteString(" " + a)
+	}
+	p.out.WriteString(end)
+}
+
+// xmlElementContent reports whether an element holds only elements,
+// comments, processing instructions and white space between them, which
+// may be reindented
+func xmlElementContent(n *xmlNode) bool {
+	if len(n.children) == 0 {
+		return false
+	}
+	for _, a := range n.attrs {
+		if a.name == "xml:space" && a.value == "preserve" {
+			return false
+		}
+	}
+	nested := false
+	for _, child := range n.children {
+		switch child.kind {
+		case xmlText:
+			if strings.TrimSpace(child.raw) != "" {
+				return false
+			}
+		case xmlCDATA:
+			return false
+		default:
+			nested = true
+		}
+	}
+	return nested
+}
+
+// xmlInnerText returns the content of an element as written
+func xmlInnerText(n *xmlNode) string {
+	var b strings.Builder
+	for _, child := range n.children {
+		writeXMLSource(&b, child)
+	}
+	return b.String()
+}
+
+func writeXMLSource(b *strings.Builder, n *xmlNode) {
+	if n.kind != xmlElement {
+		b.WriteString(n.raw)
+		return
+	}
+	b.WriteString("<" + n.name)
+	for _, a := range n.attrs {
+		b.WriteString(" " + a.name + "=" + a.raw)
+	}
+	if n.selfClosing {
+		b.WriteString("/>")
+		return
+	}
+	b.WriteString(">")
+	for _, child := range n.children {
+		writeXMLSource(b, child)
+	}
+	b.WriteString("</" + n.name + ">")
+}
+
+func formatXMLCode(code string, opts *FormatOptions) (string, error) {
+	formatted, err := formatXML(code, opts)
+	if err != nil {
+		return "", fmt.Errorf("invalid XML syntax: %w", err)
+	}
+	return formatted, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatXML(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			"nesting", "<?xml version=\"1.0\"?><root a=\"1\"><item>x</item><item>y</item><!-- c --><e/></root>",
+			"<?xml version=\"1.0\"?>\n<root a=\"1\">\n  <item>x</item>\n  <item>y</item>\n  <!-- c -->\n  <e/>\n</root>\n",
+		},
+		{"single element", "<a><b x='1'>t</b></a>", "<a>\n  <b x='1'>t</b>\n</a>\n"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Format(tt.code, "xml")
+			if err != nil {
+				t.Fatalf("Format(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if again, err := f.Format(got, "xml"); err != nil || again != got {
+				t.Errorf("Format(%q) = %q, %v; not idempotent", got, again, err)
+			}
+		})
+	}
+}
+
+func TestFormatXMLErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"<a><b></a>", "invalid XML syntax: 1:7: expected </b>, found </a>"},
+		{"<a>\n<b x='1' x='2'/></a>", "invalid XML syntax: 2:9: duplicate attribute x"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		_, err := f.Format(tt.code, "xml")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Format(%q) error = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+}
+
+func TestConvertXML(t *testing.T) {
+	tests := []struct {
+		name         string
+		code         string
+		from, to     string
+		opts         *FormatOptions
+		want         string
+		wantWarnings []string
+	}{
+		{
+			"attributes and text", "<a id=\"1\">hi<b>x</b></a>", "xml", "json", nil,
+			"{\n  \"a\": {\n    \"@id\": \"1\",\n    \"b\": \"x\",\n    \"#text\": \"hi\"\n  }\n}\n", nil,
+		},
+		{
+			"repeated elements", "<r><item>x</item><item>y</item><!-- c --><e/></r>", "xml", "json", nil,
+			"{\n  \"r\": {\n    \"item\": [\n      \"x\",\n      \"y\"\n    ],\n    \"e\": \"\"\n  }\n}\n", []string{"comments were dropped"},
+		},
+		{
+			"custom keys", "<a id=\"1\">hi</a>", "xml", "json", &FormatOptions{AttributePrefix: "_", TextKey: "value"},
+			"{\n  \"a\": {\n    \"_id\": \"1\",\n    \"value\": \"hi\"\n  }\n}\n", nil,
+		},
+		{
+			"from JSON", `{"a":{"@id":"1","#text":"hi","b":[1,2]}}`, "json", "xml", nil,
+			"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<a id=\"1\">hi<b>1</b><b>2</b></a>\n", nil,
+		},
+		{
+			"several roots from JSON", `{"a":1,"b":2}`, "json", "xml", nil,
+			"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<root>\n  <a>1</a>\n  <b>2</b>\n</root>\n",
+			[]string{"the data was wrapped in a <root> element, as an XML document has a single root element"},
+		},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Convert(tt.code, tt.from, tt.to, tt.opts)
+			if err != nil {
+				t.Fatalf("Convert(%q) error: %v", tt.code, err)
+			}
+			if got.Code != tt.want {
+				t.Errorf("Convert(%q) = %q, want %q", tt.code, got.Code, tt.want)
+			}
+			if len(got.Warnings) > 0 || len(tt.wantWarnings) > 0 {
+				if !reflect.DeepEqual(got.Warnings, tt.wantWarnings) {
+					t.Errorf("Convert(%q) warnings = %q, want %q", tt.code, got.Warnings, tt.wantWarnings)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertXMLRoundTrip(t *testing.T) {
+	tests := []struct {
+		code string
+		opts *FormatOptions
+	}{
+		{"<a id=\"1\">hi<b>x</b></a>", nil},
+		{"<a id=\"1\">hi</a>", &FormatOptions{AttributePrefix: "_", TextKey: "value"}},
+		{"<list><item>1</item><item>2</item></list>", nil},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		json, err := f.Convert(tt.code, "xml", "json", tt.opts)
+		if err != nil {
+			t.Fatalf("Convert(%q) error: %v", tt.code, err)
+		}
+		xml, err := f.Convert(json.Code, "json", "xml", tt.opts)
+		if err != nil {
+			t.Fatalf("Convert(%q) error: %v", json.Code, err)
+		}
+		back, err := f.Convert(xml.Code, "xml", "json", tt.opts)
+		if err != nil {
+			t.Fatalf("Convert(%q) error: %v", xml.Code, err)
+		}
+		if back.Code != json.Code {
+			t.Errorf("Convert(%q) back to JSON = %q, want %q", xml.Code, back.Code, json.Code)
+		}
+	}
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxXMLDepth bounds element nesting so hostile input cannot exhaust the
+// stack
+const maxXMLDepth = 10000
+
+func xmlErrorf(src string, offset int, format string, args ...interface{}) error {
+	return newSyntaxError(syntaxXML, src, offset, format, args...)
+}
+
+type xmlKind int
+
+const (
+	xmlElement xmlKind = iota
+	xmlText
+	xmlCDATA
+	xmlComment
+	xmlProcInst
+	xmlDoctype
+)
+
+type xmlAttr struct {
+	name string
+	// raw is the value as written, quotes included
+	raw   string
+	value string
+}
+
+// xmlNode is a node of an XML document. raw holds the source of text,
+// comments, processing instructions and document type declarations, which
+// are printed as written; text holds the decoded content of text and
+// CDATA sections.
+type xmlNode struct {
+	kind        xmlKind
+	name        string
+	attrs       []xmlAttr
+	children    []*xmlNode
+	selfClosing bool
+	raw         string
+	text        string
+	offset      int
+}
+
+// xmlDocument holds the nodes around the root element and the root itself
+type xmlDocument struct {
+	nodes []*xmlNode
+	root  *xmlNode
+}
+
+type xmlParser struct {
+	src     string
+	pos     int
+	depth   int
+	doctype bool
+}
+
+// parseXML reads a well-formed XML document. Line breaks are read as \n;
+// offsets in errors still count the \r of \r\n.
+func parseXML(src string) (*xmlDocument, error) {
+	text := strings.ReplaceAll(src, "\r\n", "\n")
+	p := &xmlParser{src: text}
+	doc, err := p.document()
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) && len(text) != len(src) {
+		syntaxErr.Offset = crlfOffset(src, syntaxErr.Offset)
+	}
+	return doc, err
+}
+
+func (p *xmlParser) errorf(offset int, format string, args ...interface{}) error {
+	return xmlErrorf(p.src, offset, format, args...)
+}
+
+func (p *xmlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *xmlParser) skipSpace() {
+	for !p.eof() && strings.IndexByte(" \t\n\r", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func isXMLNameStart(r rune) bool {
+	return r == '_' || r == ':' || unicode.IsLetter(r)
+}
+
+func isXMLNameChar(r rune) bool {
+	return isXMLNameStart(r) || r == '-' || r == '.' || unicode.IsDigit(r) || r == '\u00b7' || unicode.Is(unicode.Mn, r)
+}
+
+// isXMLName reports whether s can name an element or attribute
+func isXMLName(s string) bool {
+	for i, r := range s {
+		if i == 0 && !isXMLNameStart(r) || !isXMLNameChar(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func (p *xmlParser) name() (string, error) {
+	start := p.pos
+	for !p.eof() {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if p.pos == start && !isXMLNameStart(r) || !isXMLNameChar(r) {
+			break
+		}
+		p.pos += size
+	}
+	if p.pos == start {
+		return "", p.errorf(start, "expected a name, found %s", p.describe(start))
+	}
+	return p.src[start:p.pos], nil
+}
+
+func (p *xmlParser) describe(offset int) string {
+	if offset >= len(p.src) {
+		return "end of document"
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[offset:])
+	return fmt.Sprintf("%q", r)
+}
+
+func (p *xmlParser) document() (*xmlDocument, error) {
+	if strings.HasPrefix(p.src, "\uFEFF") {
+		p.pos += len("\uFEFF")
+	}
+	doc := &xmlDocument{}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		start := p.pos
+		if p.src[p.pos] != '<' {
+			if doc.root != nil {
+				return nil, p.errorf(start, "text is not allowed after the root element")
+			}
+			return nil, p.errorf(start, "text is not allowed before the root element")
+		}
+		node, err := p.markup(false)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case node.kind == xmlProcInst && strings.EqualFold(node.name, "xml") && len(doc.nodes) > 0:
+			return nil, p.errorf(start, "the XML declaration must come first")
+		case node.kind == xmlDoctype && doc.root != nil:
+			return nil, p.errorf(start, "the document type must come before the root element")
+		case node.kind == xmlElement && doc.root != nil:
+			return nil, p.errorf(start, "only one root element is allowed")
+		case node.kind == xmlElement:
+			doc.root = node
+		}
+		doc.nodes = append(doc.nodes, node)
+	}
+	if doc.root == nil {
+		return nil, p.errorf(p.pos, "no root element")
+	}
+	return doc, nil
+}
+
+// markup reads the construct starting with '<' at p.pos
+func (p *xmlParser) markup(inElement bool) (*xmlNode, error) {
+	start := p.pos
+	rest := p.src[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "<!--"):
+		end := strings.Index(rest[4:], "--")
+		if end < 0 {
+			return nil, p.errorf(start, "unterminated comment")
+		}
+		if !strings.HasPrefix(rest[4+end:], "-->") {
+			return nil, p.errorf(start+4+end, "-- is not allowed in comments")
+		}
+		p.pos += 4 + end + 3
+		return &xmlNode{kind: xmlComment, raw: p.src[start:p.pos], offset: start}, nil
+	case strings.HasPrefix(rest, "<![CDATA["):
+		if !inElement {
+			return nil, p.errorf(start, "CDATA sections are only allowed in elements")
+		}
+		end := strings.Index(rest, "]]>")
+		if end < 0 {
+			return nil, p.errorf(start, "unterminated CDATA section")
+		}
+		p.pos += end + 3
+		return &xmlNode{kind: xmlCDATA, raw: p.src[start:p.pos], text: rest[9:end], offset: start}, nil
+	case strings.HasPrefix(rest, "<!DOCTYPE"):
+		if inElement {
+			return nil, p.errorf(start, "the document type must come before the root element")
+		}
+		return p.doctypeDecl()
+	case strings.HasPrefix(rest, "<?"):
+		p.pos += 2
+		target, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		end := strings.Index(p.src[p.pos:], "?>")
+		if end < 0 {
+			return nil, p.errorf(start, "unterminated processing instruction")
+		}
+		p.pos += end + 2
+		return &xmlNode{kind: xmlProcInst, name: target, raw: p.src[start:p.pos], offset: start}, nil
+	case strings.HasPrefix(rest, "</"):
+		return nil, p.errorf(start, "unexpected end tag")
+	case strings.HasPrefix(rest, "<!"):
+		return nil, p.errorf(start, "unexpected declaration")
+	}
+	return p.element()
+}
+
+// doctypeDecl reads a document type declaration, skipping over its
+// internal subset
+func (p *xmlParser) doctypeDecl() (*xmlNode, error) {
+	start := p.pos
+	p.pos += len("<!DOCTYPE")
+	var quote byte
+	brackets := 0
+	for ; !p.eof(); p.pos++ {
+		c := p.src[p.pos]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case brackets > 0 && strings.HasPrefix(p.src[p.pos:], "<!--"):
+			// Comments in the internal subset may hold quotes
+			end := strings.Index(p.src[p.pos+4:], "-->")
+			if end < 0 {
+				return nil, p.errorf(p.pos, "unterminated comment")
+			}
+			p.pos += 4 + end + 2
+		case c == '[':
+			brackets++
+		case c == ']':
+			brackets--
+		case c == '>' && brackets <= 0:
+			p.pos++
+			p.doctype = true
+			return &xmlNode{kind: xmlDoctype, raw: p.src[start:p.pos], offset: start}, nil
+		}
+	}
+	return nil, p.errorf(start, "unterminated document type declaration")
+}
+
+func (p *xmlParser) element() (*xmlNode, error) {
+	start := p.pos
+	if p.depth++; p.depth > maxXMLDepth {
+		return nil, p.errorf(start, "exceeded max nesting depth")
+	}
+	defer func() { p.depth-- }()
+
+	p.pos++
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	n := &xmlNode{kind: xmlElement, name: name, offset: start}
+	for {
+		spaced := p.pos
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorf(start, "unclosed start tag <%s>", name)
+		}
+		if strings.HasPrefix(p.src[p.pos:], "/>") {
+			p.pos += 2
+			n.selfClosing = true
+			return n, nil
+		}
+		if p.src[p.pos] == '>' {
+			p.pos++
+			break
+		}
+		if p.pos == spaced {
+			return nil, p.errorf(p.pos, "expected white space before an attribute, found %s", p.describe(p.pos))
+		}
+		attr, err := p.attribute()
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range n.attrs {
+			if other.name == attr.name {
+				return nil, p.errorf(spaced, "duplicate attribute %s", attr.name)
+			}
+		}
+		n.attrs = append(n.attrs, attr)
+	}
+
+	for {
+		if p.eof() {
+			return nil, p.errorf(start, "unclosed element <%s>", name)
+		}
+		if p.src[p.pos] != '<' {
+			text, err := p.text()
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, text)
+			continue
+		}
+		if strings.HasPrefix(p.src[p.pos:], "</") {
+			end := p.pos
+			p.pos += 2
+			closing, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if closing != name {
+				return nil, p.errorf(end, "expected </%s>, found </%s>", name, closing)
+			}
+			p.skipSpace()
+			if p.eof() || p.src[p.pos] != '>' {
+				return nil, p.errorf(p.pos, "expected '>' to end </%s>", name)
+			}
+			p.pos++
+			return n, nil
+		}
+		child, err := p.markup(true)
+		if err != nil {
+			return nil, err
+		}
+		n.children = append(n.children, child)
+	}
+}
+
+func (p *xmlParser) attribute() (xmlAttr, error) {
+	name, err := p.name()
+	if err != nil {
+		return xmlAttr{}, err
+	}
+	p.skipSpace()
+	if p.eof() || p.src[p.pos] != '=' {
+		return xmlAttr{}, p.errorf(p.pos, "expected '=' after attribute %s", name)
+	}
+	p.pos++
+	p.skipSpace()
+	if p.eof() || p.src[p.pos] != '"' && p.src[p.pos] != '\'' {
+		return xmlAttr{}, p.errorf(p.pos, "attribute values must be quoted")
+	}
+	start := p.pos
+	end := strings.IndexByte(p.src[p.pos+1:], p.src[p.pos])
+	if end < 0 {
+		return xmlAttr{}, p.errorf(start, "unterminated attribute value")
+	}
+	p.pos += end + 2
+	raw := p.src[start:p.pos]
+	if i := strings.IndexByte(raw, '<'); i >= 0 {
+		return xmlAttr{}, p.errorf(start+i, "'<' is not allowed in attribute values")
+	}
+	value, err := p.unescape(raw[1:len(raw)-1], start+1)
+	if err != nil {
+		return xmlAttr{}, err
+	}
+	// Attribute values are normalized: white space characters read as
+	// spaces
+	value = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' || r == '\r' {
+			return ' '
+		}
+		return r
+	}, value)
+	return xmlAttr{name: name, raw: raw, value: value}, nil
+}
+
+// text reads character data up to the next markup
+func (p *xmlParser) text() (*xmlNode, error) {
+	start := p.pos
+	end := strings.IndexByte(p.src[p.pos:], '<')
+	if end < 0 {
+		end = len(p.src) - p.pos
+	}
+	p.pos += end
+	raw := p.src[start:p.pos]
+	text, err := p.unescape(raw, start)
+	if err != nil {
+		return nil, err
+	}
+	return &xmlNode{kind: xmlText, raw: raw, text: text, offset: start}, nil
+}
+
+var xmlEntities = map[string]string{
+	"lt": "<", "gt": ">", "amp": "&", "quot": "\"", "apos": "'",
+}
+
+// unescape replaces references in s, which starts at offset. Entities a
+// document type declares are kept as written.
+func (p *xmlParser) unescape(s string, offset int) (string, error) {
+	if !strings.Contains(s, "&") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '&' {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		end := strings.IndexByte(s[i:], ';')
+		if end < 0 {
+			return "", p.errorf(offset+i, "'&' must start a reference; write &amp;")
+		}
+		ref := s[i+1 : i+end]
+		switch {
+		case strings.HasPrefix(ref, "#x"):
+			code, err := strconv.ParseUint(ref[2:], 16, 32)
+			if err != nil || !isXMLChar(rune(code)) {
+				return "", p.errorf(offset+i, "invalid character reference &%s;", ref)
+			}
+			b.WriteRune(rune(code))
+		case strings.HasPrefix(ref, "#"):
+			code, err := strconv.ParseUint(ref[1:], 10, 32)
+			if err != nil || !isXMLChar(rune(code)) {
+				return "", p.errorf(offset+i, "invalid character reference &%s;", ref)
+			}
+			b.WriteRune(rune(code))
+		case xmlEntities[ref] != "":
+			b.WriteString(xmlEntities[ref])
+		case p.doctype && isXMLName(ref):
+			b.WriteString("&" + ref + ";")
+		default:
+			return "", p.errorf(offset+i, "undefined entity &%s;", ref)
+		}
+		i += end + 1
+	}
+	return b.String(), nil
+}
+
+// isXMLChar reports whether r may appear in an XML 1.0 document
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' || r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD || r >= 0x10000 && r <= 0x10FFFF
+}
@@ -0,0 +1,511 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxYAMLValues bounds the values a document may expand to, so a few
+// lines of nested aliases cannot exhaust memory
+const maxYAMLValues = 1000000
+
+var (
+	yamlIntPattern     = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlOctalPattern   = regexp.MustCompile(`^0o[0-7]+$`)
+	yamlHexPattern     = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	yamlFloatPattern   = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	yamlSpecialPattern = regexp.MustCompile(`^([-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+
+	// yaml11BoolPattern matches the words YAML 1.1 tools read as booleans
+	yaml11BoolPattern = regexp.MustCompile(`^(yes|Yes|YES|no|No|NO|on|On|ON|off|Off|OFF)$`)
+
+	// yaml11Pattern matches the other plain scalars that YAML 1.1 tools
+	// read as something other than a string: single-letter booleans, the
+	// value and merge keys, numbers with underscores, binary, old-style
+	// octal and base 60 numbers, and timestamps
+	yaml11Pattern = regexp.MustCompile(`^(y|Y|n|N|=|<<|[-+]?(0b[01_]+|0x[0-9a-fA-F_]+|[0-9][0-9_]*(:[0-5]?[0-9])*(\.[0-9_]*)?([eE][-+]?[0-9]+)?|\.[0-9_]+([eE][-+]?[0-9]+)?)|[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}([Tt ].*)?)$`)
+)
+
+// resolveYAMLScalar reads a plain scalar by the YAML 1.2 core schema
+func resolveYAMLScalar(text string) interface{} {
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if number, ok := yamlNumber(text); ok {
+		return number
+	}
+	return text
+}
+
+// yamlNumber rewrites an integer or float in JSON number syntax
+func yamlNumber(text string) (dataNumber, bool) {
+	base, digits := 10, text
+	switch {
+	case yamlOctalPattern.MatchString(text):
+		base, digits = 8, text[2:]
+	case yamlHexPattern.MatchString(text):
+		base, digits = 16, text[2:]
+	case yamlIntPattern.MatchString(text):
+	case yamlFloatPattern.MatchString(text):
+		return jsonFloat(text), true
+	default:
+		return "", false
+	}
+	n, _ := new(big.Int).SetString(digits, base)
+	return dataNumber(n.String()), true
+}
+
+// jsonFloat adds the digits JSON requires around the point of a float
+// and drops a plus sign and leading zeros
+func jsonFloat(text string) dataNumber {
+	sign := ""
+	switch text[0] {
+	case '-':
+		sign = "-"
+		fallthrough
+	case '+':
+		text = text[1:]
+	}
+	mantissa, exponent := text, ""
+	if i := strings.IndexAny(text, "eE"); i >= 0 {
+		mantissa, exponent = text[:i], text[i:]
+	}
+	whole, fraction, point := strings.Cut(mantissa, ".")
+	if whole = strings.TrimLeft(whole, "0"); whole == "" {
+		whole = "0"
+	}
+	number := sign + whole
+	if point {
+		if fraction == "" {
+			fraction = "0"
+		}
+		number += "." + fraction
+	}
+	return dataNumber(number + exponent)
+}
+
+// yamlDecoder turns parsed YAML into plain values. Aliases are replaced
+// by copies of the node they name and merge keys are applied.
+type yamlDecoder struct {
+	code    string
+	text    string
+	log     *conversionLog
+	anchors map[string]*yamlNode
+	active  map[*yamlNode]bool
+	aliased int
+	values  int
+}
+
+func decodeYAML(code string, log *conversionLog) (interface{}, error) {
+	stream, err := parseYAML(code)
+	if err != nil {
+		return nil, err
+	}
+	if stream.comments > 0 {
+		log.warnf("comments were dropped")
+	}
+	d := &yamlDecoder{
+		code:   code,
+		text:   strings.ReplaceAll(code, "\r\n", "\n"),
+		log:    log,
+		active: make(map[*yamlNode]bool),
+	}
+	var docs []interface{}
+	for _, doc := range stream.docs {
+		if doc.root == nil && len(stream.docs) > 1 {
+			// Separators around a stream do not start documents of note
+			continue
+		}
+		d.anchors = make(map[string]*yamlNode)
+		value, err := d.value(doc.root)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, value)
+	}
+	switch len(docs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return docs[0], nil
+	}
+	log.warnf("the %d documents were combined into an array", len(docs))
+	return docs, nil
+}
+
+func (d *yamlDecoder) errorf(offset int, format string, args ...interface{}) error {
+	return yamlErrorf(d.code, crlfOffset(d.code, offset), format, args...)
+}
+
+func (d *yamlDecoder) line(n *yamlNode) int {
+	line, _ := lineColumn(d.text, n.offset)
+	return line
+}
+
+func (d *yamlDecoder) value(n *yamlNode) (interface{}, error) {
+	if n == nil {
+		return nil, nil
+	}
+	if d.values++; d.values > maxYAMLValues {
+		return nil, d.errorf(n.offset, "aliases expand to more than %d values", maxYAMLValues)
+	}
+	if n.anchor != "" {
+		d.log.warnf("anchors were dropped and aliases replaced by copies of their values")
+		if d.aliased == 0 {
+			d.anchors[n.anchor] = n
+		}
+	}
+
+	switch n.kind {
+	case yamlAlias:
+		target := d.anchors[n.text]
+		if target == nil {
+			return nil, d.errorf(n.offset, "unknown anchor %q", n.text)
+		}
+		if d.active[target] {
+			return nil, d.errorf(n.offset, "alias *%s refers to a node that contains it", n.text)
+		}
+		d.aliased++
+		defer func() { d.aliased-- }()
+		return d.value(target)
+	case yamlScalar:
+		return d.scalar(n)
+	}
+
+	switch yamlTagName(n.tag) {
+	case "", "!", "map", "seq", "omap", "set":
+	default:
+		d.log.warnf("line %d: tag %s was dropped", d.line(n), n.tag)
+	}
+	d.active[n] = true
+	defer delete(d.active, n)
+	if n.kind == yamlMapping {
+		return d.pairs(n.entries)
+	}
+	list := make([]interface{}, 0, len(n.entries))
+	for _, e := range n.entries {
+		var item interface{}
+		var err error
+		if e.key != nil {
+			// A single pair in a flow sequence
+			item, err = d.pairs([]*yamlEntry{e})
+		} else {
+			item, err = d.value(e.value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+// pairs reads mapping entries. Keys written in the mapping win over merged
+// ones, and earlier merged mappings win over later ones.
+func (d *yamlDecoder) pairs(entries []*yamlEntry) (interface{}, error) {
+	type pair struct {
+		key    string
+		value  interface{}
+		merged []*dataMap
+	}
+	pairs := make([]pair, 0, len(entries))
+	written := make(map[string]bool)
+	for _, e := range entries {
+		if k := e.key; k.kind == yamlScalar && k.style == yamlPlain && k.tag == "" && k.text == "<<" {
+			value, err := d.value(e.value)
+			if err != nil {
+				return nil, err
+			}
+			merged, ok := yamlMergeSources(value)
+			if !ok {
+				return nil, d.errorf(k.offset, "<< must merge a mapping or a list of mappings")
+			}
+			pairs = append(pairs, pair{merged: merged})
+			continue
+		}
+		key, err := d.key(e.key)
+		if err != nil {
+			return nil, err
+		}
+		if written[key] {
+			d.log.warnf("line %d: duplicate key %q replaces the earlier value", d.line(e.key), key)
+		}
+		written[key] = true
+		value, err := d.value(e.value)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair{key: key, value: value})
+	}
+
+	m := newDataMap()
+	for _, p := range pairs {
+		if p.merged == nil {
+			m.set(p.key, p.value)
+			continue
+		}
+		for _, source := range p.merged {
+			for _, key := range source.keys {
+				if !written[key] && !m.has(key) {
+					m.set(key, source.values[key])
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+func yamlMergeSources(value interface{}) ([]*dataMap, bool) {
+	switch v := value.(type) {
+	case *dataMap:
+		return []*dataMap{v}, true
+	case []interface{}:
+		sources := make([]*dataMap, 0, len(v))
+		for _, item := range v {
+			m, ok := item.(*dataMap)
+			if !ok {
+				return nil, false
+			}
+			sources = append(sources, m)
+		}
+		return sources, true
+	}
+	return nil, false
+}
+
+// key reads a mapping key. Scalars that are not strings become the text
+// of their value.
+func (d *yamlDecoder) key(n *yamlNode) (string, error) {
+	value, err := d.value(n)
+	if err != nil {
+		return "", err
+	}
+	var key string
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case nil:
+		key = "null"
+	case bool:
+		key = strconv.FormatBool(v)
+	case dataNumber:
+		key = string(v)
+	default:
+		return "", d.errorf(n.offset, "a collection cannot be converted to a key")
+	}
+	d.log.warnf("line %d: key %s was converted to a string", d.line(n), key)
+	return key, nil
+}
+
+// yamlTagName returns the name of a tag from the YAML namespace, such as
+// str for !!str, the tag itself for other tags, or "" for none
+func yamlTagName(tag string) string {
+	switch {
+	case strings.HasPrefix(tag, "!!"):
+		return tag[2:]
+	case strings.HasPrefix(tag, "!<tag:yaml.org,2002:"):
+		return strings.TrimSuffix(strings.TrimPrefix(tag, "!<tag:yaml.org,2002:"), ">")
+	}
+	return tag
+}
+
+func (d *yamlDecoder) scalar(n *yamlNode) (interface{}, error) {
+	text, err := n.scalarValue()
+	if err != nil {
+		return nil, d.errorf(n.offset, "%v", err)
+	}
+	tag := yamlTagName(n.tag)
+	switch tag {
+	case "":
+		if n.style != yamlPlain {
+			return text, nil
+		}
+		return d.plain(n, text), nil
+	case "!", "str", "binary", "timestamp":
+		return text, nil
+	case "null", "bool", "int", "float":
+		value := d.plain(n, text)
+		var ok bool
+		switch v := value.(type) {
+		case nil:
+			ok = tag == "null"
+		case bool:
+			ok = tag == "bool"
+		case dataNumber:
+			ok = tag == "float" || !strings.ContainsAny(string(v), ".eE")
+		case string:
+			ok = tag == "float" && yamlSpecialPattern.MatchString(v)
+		}
+		if !ok {
+			return nil, d.errorf(n.offset, "%q is not a valid %s", text, n.tag)
+		}
+		return value, nil
+	}
+	d.log.warnf("line %d: tag %s was dropped", d.line(n), n.tag)
+	if n.style != yamlPlain {
+		return text, nil
+	}
+	return d.plain(n, text), nil
+}
+
+// plain resolves a plain scalar, warning about the values other tools
+// read differently
+func (d *yamlDecoder) plain(n *yamlNode, text string) interface{} {
+	value := resolveYAMLScalar(text)
+	if s, ok := value.(string); ok {
+		switch {
+		case yamlSpecialPattern.MatchString(s):
+			d.log.warnf("line %d: %s cannot be represented and was converted to a string", d.line(n), s)
+		case yaml11BoolPattern.MatchString(s):
+			d.log.warnf("line %d: %s was read as a string; YAML 1.1 tools read it as a boolean", d.line(n), s)
+		}
+	}
+	return value
+}
+
+func decodeYAMLCode(code string, _ *FormatOptions, log *conversionLog) (interface{}, error) {
+	value, err := decodeYAML(code, log)
+	if err != nil {
+		return nil, fmt.Errorf("invalid YAML syntax: %w", err)
+	}
+	return value, nil
+}
+
+// encodeYAML writes plain values as block YAML; the YAML formatter lays it
+// out afterwards
+func encodeYAML(value interface{}, _ *FormatOptions, _ *conversionLog) (string, error) {
+	var b strings.Builder
+	writeYAMLValue(&b, value, 0)
+	// The last line break belongs to a kept block scalar
+	return strings.TrimLeft(b.String(), " \n") + "\n", nil
+}
+
+// writeYAMLValue writes a value after a key's ':' or an item's '-'.
+// Collections continue on the following lines at indent.
+func writeYAMLValue(b *strings.Builder, value interface{}, indent int) {
+	pad := "\n" + strings.Repeat(" ", indent)
+	switch v := value.(type) {
+	case nil:
+		b.WriteString(" null")
+	case bool:
+		b.WriteString(" " + strconv.FormatBool(v))
+	case dataNumber:
+		b.WriteString(" " + string(v))
+	case string:
+		b.WriteString(" " + yamlString(v, max(indent, 2)))
+	case []interface{}:
+		if len(v) == 0 {
+			b.WriteString(" []")
+		}
+		for _, item := range v {
+			b.WriteString(pad + "-")
+			writeYAMLValue(b, item, indent+2)
+		}
+	case *dataMap:
+		if len(v.keys) == 0 {
+			b.WriteString(" {}")
+		}
+		for _, key := range v.keys {
+			b.WriteString(pad + yamlKeyString(key) + ":")
+			writeYAMLValue(b, v.values[key], indent+2)
+		}
+	}
+}
+
+// yamlString writes a string as a plain scalar where that reads back as
+// the same string, as a literal block when it spans lines, and quoted
+// otherwise. Block content goes at indent.
+func yamlString(s string, indent int) string {
+	if !strings.Contains(s, "\n") || !yamlLiteralSafe(s) {
+		if yamlPlainSafe(s) {
+			return s
+		}
+		return strconv.Quote(s)
+	}
+
+	text, header := s, "|-"
+	if strings.HasSuffix(s, "\n") {
+		text, header = s[:len(s)-1], "|"
+		if strings.HasSuffix(text, "\n") {
+			header = "|+"
+		}
+	}
+	lines := strings.Split(text, "\n")
+	for _, line := range lines {
+		if line != "" {
+			if line[0] == ' ' {
+				// The first line would be read as indentation
+				header += "2"
+			}
+			break
+		}
+	}
+	var b strings.Builder
+	b.WriteString(header)
+	pad := "\n" + strings.Repeat(" ", indent)
+	for _, line := range lines {
+		if line == "" {
+			b.WriteByte('\n')
+			continue
+		}
+		b.WriteString(pad + line)
+	}
+	return b.String()
+}
+
+func yamlKeyString(key string) string {
+	if yamlPlainSafe(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+// yamlLiteralSafe reports whether a literal block reproduces s: it holds
+// only printable characters and tabs, and no line is white space alone
+func yamlLiteralSafe(s string) bool {
+	// A block of nothing but line breaks reads back empty
+	if !utf8.ValidString(s) || strings.Trim(s, "\n") == "" {
+		return false
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" && strings.TrimLeft(line, " \t") == "" {
+			return false
+		}
+		for _, r := range line {
+			if r != '\t' && !unicode.IsPrint(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// yamlPlainSafe reports whether s can be written as a plain scalar that
+// reads back as the same string, in YAML 1.2 and 1.1 alike
+func yamlPlainSafe(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s || !utf8.ValidString(s) ||
+		strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") ||
+		strings.HasPrefix(s, "...") {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	if _, ok := resolveYAMLScalar(s).(string); !ok {
+		return false
+	}
+	return !yamlSpecialPattern.MatchString(s) && !yaml11BoolPattern.MatchString(s) && !yaml11Pattern.MatchString(s)
+}
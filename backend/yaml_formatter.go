@@ -0,0 +1,352 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// yamlPrinter prints YAML documents with uniform indentation. Mapping
+// values are indented by the indentation size, and the content of a
+// sequence item lines up after its "- ". Scalars are printed as written.
+type yamlPrinter struct {
+	out  strings.Builder
+	size int
+	// fresh is set when a block has been opened and nothing printed in it
+	// yet, where blank lines are dropped
+	fresh bool
+}
+
+func formatYAML(src string, opts *FormatOptions) (string, error) {
+	stream, err := parseYAML(src)
+	if err != nil {
+		return "", err
+	}
+	// YAML cannot be indented with tabs
+	size := 2
+	if opts != nil && opts.IndentSize != nil {
+		size = *opts.IndentSize
+	}
+	p := &yamlPrinter{size: size, fresh: true}
+	for i, doc := range stream.docs {
+		p.document(doc, i == 0)
+	}
+	if p.out.Len() == 0 {
+		return "", nil
+	}
+	return p.out.String() + "\n", nil
+}
+
+// line starts a new line indented by indent spaces
+func (p *yamlPrinter) line(indent int) {
+	if p.out.Len() > 0 {
+		p.out.WriteByte('\n')
+	}
+	p.out.WriteString(strings.Repeat(" ", indent))
+	p.fresh = false
+}
+
+// blankLine keeps a blank line from the source, except at the start of
+// the output or of a block
+func (p *yamlPrinter) blankLine() {
+	if p.out.Len() > 0 && !p.fresh {
+		p.out.WriteByte('\n')
+	}
+}
+
+// comment writes a comment at the end of the current line
+func (p *yamlPrinter) comment(text string) {
+	if text != "" {
+		p.out.WriteString(" " + text)
+	}
+}
+
+func (p *yamlPrinter) comments(comments []yamlComment, indent int) {
+	for _, c := range comments {
+		if c.blank {
+			p.blankLine()
+		}
+		p.line(indent)
+		p.out.WriteString(c.text)
+	}
+}
+
+func (p *yamlPrinter) document(doc *yamlDocument, first bool) {
+	p.comments(doc.head, 0)
+	for _, directive := range doc.directives {
+		p.line(0)
+		p.out.WriteString(directive)
+	}
+	marker := doc.start || !first || len(doc.directives) > 0
+	if marker {
+		p.line(0)
+		p.out.WriteString("---")
+		p.comment(doc.startComment)
+		p.fresh = true
+	}
+	p.comments(doc.comments, 0)
+	if doc.blank && len(doc.comments) > 0 {
+		p.blankLine()
+	}
+
+	if root := doc.root; root != nil {
+		// A root that is not a block collection may share the marker line
+		inline := marker && doc.startComment == "" && len(doc.comments) == 0
+		switch {
+		case isYAMLBlockCollection(root):
+			if props := yamlProperties(root); props != "" {
+				if inline {
+					p.out.WriteString(" " + props)
+				} else {
+					p.line(0)
+					p.out.WriteString(props)
+				}
+				p.comment(root.comment)
+			}
+			p.fresh = true
+			p.block(root, 0, false)
+		default:
+			if inline {
+				p.out.WriteByte(' ')
+			} else {
+				p.line(0)
+			}
+			p.scalar(root, -1, p.size)
+		}
+	}
+
+	p.comments(doc.foot, 0)
+	if doc.end {
+		p.line(0)
+		p.out.WriteString("...")
+		p.comment(doc.endComment)
+	}
+}
+
+func isYAMLBlockCollection(n *yamlNode) bool {
+	return (n.kind == yamlMapping || n.kind == yamlSequence) && !n.flow
+}
+
+// yamlProperties returns the anchor and tag of a node as written before it
+func yamlProperties(n *yamlNode) string {
+	var props []string
+	if n.anchor != "" {
+		props = append(props, "&"+n.anchor)
+	}
+	if n.tag != "" {
+		props = append(props, n.tag)
+	}
+	return strings.Join(props, " ")
+}
+
+// block prints the entries of a block collection at indent. When inline
+// is set, the first entry continues the current line after a "- ".
+func (p *yamlPrinter) block(n *yamlNode, indent int, inline bool) {
+	for i, e := range n.entries {
+		if i > 0 || !inline {
+			p.comments(e.comments, indent)
+			if e.blank {
+				p.blankLine()
+			}
+			p.line(indent)
+		}
+		if n.kind == yamlSequence {
+			p.out.WriteByte('-')
+			p.value(e, indent, indent+2, true)
+			continue
+		}
+		p.inline(e.key, indent)
+		if e.key.kind == yamlAlias {
+			p.out.WriteByte(' ')
+		}
+		p.out.WriteByte(':')
+		p.value(e, indent, indent+p.size, false)
+	}
+	p.comments(n.foot, indent)
+}
+
+// value prints the value of an entry after its ':' or '-'. child is the
+// indentation of a nested block.
+func (p *yamlPrinter) value(e *yamlEntry, indent, child int, item bool) {
+	v := e.value
+	if v == nil {
+		p.comment(e.comment)
+		return
+	}
+	props := yamlProperties(v)
+	if isYAMLBlockCollection(v) {
+		first := v.entries[0]
+		if item && props == "" && e.comment == "" && v.comment == "" && len(first.comments) == 0 {
+			// A compact collection starts on the line of its "- "
+			p.out.WriteByte(' ')
+			p.block(v, indent+2, true)
+			return
+		}
+		if props != "" {
+			p.out.WriteString(" " + props)
+		}
+		p.comment(e.comment)
+		p.comment(v.comment)
+		p.fresh = true
+		p.block(v, child, false)
+		return
+	}
+	if e.comment != "" {
+		// The value was written below a comment after the indicator
+		p.comment(e.comment)
+		p.line(child)
+	} else {
+		p.out.WriteByte(' ')
+	}
+	p.scalar(v, indent, child)
+}
+
+// scalar prints a scalar, alias or flow collection where the line so far
+// ends with a space. parent is the indentation of the collection holding
+// it and child the indentation of its continuation lines.
+func (p *yamlPrinter) scalar(n *yamlNode, parent, child int) {
+	if n.style == yamlLiteral || n.style == yamlFolded {
+		p.blockScalar(n, parent, child)
+		return
+	}
+	p.inline(n, child)
+	p.comment(n.comment)
+}
+
+// inline prints a node that is not a block collection or block scalar,
+// with continuation lines at indent
+func (p *yamlPrinter) inline(n *yamlNode, indent int) {
+	props := yamlProperties(n)
+	if props != "" {
+		p.out.WriteString(props)
+		if n.kind == yamlScalar && n.text == "" && !n.flow {
+			return
+		}
+		p.out.WriteByte(' ')
+	}
+	switch {
+	case n.kind == yamlAlias:
+		p.out.WriteString("*" + n.text)
+	case n.raw != "":
+		p.lines(strings.Split(n.raw, "\n"), indent)
+	case n.flow:
+		p.out.WriteString(yamlFlowText(n))
+	default:
+		p.lines(strings.Split(n.text, "\n"), indent)
+	}
+}
+
+// lines prints the lines of a multi-line node, all but the first at
+// indent and without the indentation they had
+func (p *yamlPrinter) lines(lines []string, indent int) {
+	for i, line := range lines {
+		line = strings.TrimLeft(line, " \t")
+		switch {
+		case i == 0:
+		case line == "":
+			p.out.WriteByte('\n')
+			continue
+		default:
+			p.line(indent)
+		}
+		p.out.WriteString(line)
+	}
+}
+
+// yamlFlowText prints a flow collection on one line
+func yamlFlowText(n *yamlNode) string {
+	parts := make([]string, 0, len(n.entries))
+	for _, e := range n.entries {
+		var part string
+		switch {
+		case e.key == nil:
+			part = yamlInlineText(e.value)
+		case e.value == nil && n.kind == yamlMapping:
+			part = yamlInlineText(e.key)
+		default:
+			part = yamlInlineText(e.key)
+			if e.key.kind == yamlAlias {
+				part += " "
+			}
+			part += ":"
+			if e.value != nil {
+				part += " " + yamlInlineText(e.value)
+			}
+		}
+		parts = append(parts, part)
+	}
+	switch {
+	case n.kind == yamlSequence:
+		return "[" + strings.Join(parts, ", ") + "]"
+	case len(parts) == 0:
+		return "{}"
+	}
+	return "{ " + strings.Join(parts, ", ") + " }"
+}
+
+// yamlInlineText prints a node inside a flow collection. Line breaks in
+// scalars are folded into spaces, which reads back the same.
+func yamlInlineText(n *yamlNode) string {
+	text := n.text
+	switch {
+	case n.kind == yamlAlias:
+		text = "*" + n.text
+	case n.raw != "":
+		text = n.raw
+	case n.flow:
+		text = yamlFlowText(n)
+	case !strings.Contains(n.text, "\n\n"):
+		text = strings.ReplaceAll(n.text, "\n", " ")
+	}
+	if props := yamlProperties(n); props != "" {
+		if text == "" {
+			return props
+		}
+		return props + " " + text
+	}
+	return text
+}
+
+// blockScalar prints a literal or folded scalar with its content indented
+// at child. The indentation must be given in the header when the first
+// line starts with a space, which would otherwise be read as indentation.
+func (p *yamlPrinter) blockScalar(n *yamlNode, parent, child int) {
+	if props := yamlProperties(n); props != "" {
+		p.out.WriteString(props + " ")
+	}
+	header := "|"
+	if n.style == yamlFolded {
+		header = ">"
+	}
+	if n.chomp != 0 {
+		header += string(n.chomp)
+	}
+	for _, line := range n.lines {
+		if line == "" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			base := max(parent, 0)
+			child = min(child, base+9)
+			header += fmt.Sprint(child - base)
+		}
+		break
+	}
+	p.out.WriteString(header)
+	p.comment(n.comment)
+	for _, line := range n.lines {
+		if line == "" {
+			p.out.WriteByte('\n')
+			continue
+		}
+		p.line(child)
+		p.out.WriteString(line)
+	}
+}
+
+func formatYAMLCode(code string, opts *FormatOptions) (string, error) {
+	formatted, err := formatYAML(code, opts)
+	if err != nil {
+		return "", fmt.Errorf("invalid YAML syntax: %w", err)
+	}
+	return formatted, nil
+}
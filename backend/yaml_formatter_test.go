@@ -0,0 +1,125 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"indentation and flow", "a:   1\nb: [1,2]\nc:\n    - x\n    - {k: v}\n", "a: 1\nb: [1, 2]\nc:\n  - x\n  - { k: v }\n"},
+		{"comments", "# head\nkey: value # trailing\nlist:\n- a\n- b\n", "# head\nkey: value # trailing\nlist:\n  - a\n  - b\n"},
+		{"anchors", "anchors:\n  base: &b {x: 1}\n  use: *b\n", "anchors:\n  base: &b { x: 1 }\n  use: *b\n"},
+		{"block scalars", "text: |\n  line 1\n  line 2\nfolded: >-\n  a\n  b\n", "text: |\n  line 1\n  line 2\nfolded: >-\n  a\n  b\n"},
+		{"documents", "---\na: 1\n---\nb: 2\n", "---\na: 1\n---\nb: 2\n"},
+		{"scalars as written", "'quoted': \"x\"\nplain: yes\nnum: 0x1F\n", "'quoted': \"x\"\nplain: yes\nnum: 0x1F\n"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Format(tt.code, "yaml")
+			if err != nil {
+				t.Fatalf("Format(%q) error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if again, err := f.Format(got, "yaml"); err != nil || again != got {
+				t.Errorf("Format(%q) = %q, %v; not idempotent", got, again, err)
+			}
+		})
+	}
+}
+
+func TestFormatYAMLErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"a: [1, 2\n", "invalid YAML syntax: 1:4: unclosed ["},
+		{"a: 1\n  b: 2\n", "invalid YAML syntax: 2:4: mapping values are not allowed here"},
+		{"a:\n\t- x\n", "invalid YAML syntax: 2:1: tabs cannot be used for indentation"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		_, err := f.Format(tt.code, "yaml")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Format(%q) error = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+}
+
+func TestConvertYAMLToJSON(t *testing.T) {
+	tests := []struct {
+		name         string
+		code         string
+		want         string
+		wantWarnings []string
+	}{
+		{"block scalars", "text: |\n  line 1\n  line 2\nfolded: >-\n  a\n  b\n", "{\n  \"text\": \"line 1\\nline 2\\n\",\n  \"folded\": \"a b\"\n}\n", nil},
+		{"comments", "# head\nkey: value # trailing\n", "{\n  \"key\": \"value\"\n}\n", []string{"comments were dropped"}},
+		{
+			"aliases", "base: &b {x: 1}\nuse: *b\n", "{\n  \"base\": {\n    \"x\": 1\n  },\n  \"use\": {\n    \"x\": 1\n  }\n}\n",
+			[]string{"anchors were dropped and aliases replaced by copies of their values"},
+		},
+		{"documents", "a: 1\n---\nb: 2\n", "[\n  {\n    \"a\": 1\n  },\n  {\n    \"b\": 2\n  }\n]\n", []string{"the 2 documents were combined into an array"}},
+		{
+			"yaml 1.1 scalars", "plain: yes\nnum: 0x1F\n", "{\n  \"plain\": \"yes\",\n  \"num\": 31\n}\n",
+			[]string{"line 1: yes was read as a string; YAML 1.1 tools read it as a boolean"},
+		},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Convert(tt.code, "yaml", "json", nil)
+			if err != nil {
+				t.Fatalf("Convert(%q) error: %v", tt.code, err)
+			}
+			if got.Code != tt.want {
+				t.Errorf("Convert(%q) = %q, want %q", tt.code, got.Code, tt.want)
+			}
+			if len(got.Warnings) > 0 || len(tt.wantWarnings) > 0 {
+				if !reflect.DeepEqual(got.Warnings, tt.wantWarnings) {
+					t.Errorf("Convert(%q) warnings = %q, want %q", tt.code, got.Warnings, tt.wantWarnings)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertJSONToYAML(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`{"a":[1,{"b":null}],"s":"yes","n":"1.0","e":"","m":"a\nb"}`, "a:\n  - 1\n  - b: null\ns: \"yes\"\n\"n\": \"1.0\"\ne: \"\"\nm: |-\n  a\n  b\n"},
+		{`[1,"x"]`, "- 1\n- x\n"},
+	}
+
+	f := NewFormatter()
+	for _, tt := range tests {
+		got, err := f.Convert(tt.code, "json", "yaml", nil)
+		if err != nil {
+			t.Fatalf("Convert(%q) error: %v", tt.code, err)
+		}
+		if got.Code != tt.want {
+			t.Errorf("Convert(%q) = %q, want %q", tt.code, got.Code, tt.want)
+		}
+		// Converting back gives the same data
+		back, err := f.Convert(got.Code, "yaml", "json", nil)
+		if err != nil {
+			t.Fatalf("Convert(%q) error: %v", got.Code, err)
+		}
+		want, _ := f.Format(tt.code, "json")
+		if back.Code != want {
+			t.Errorf("Convert(%q) back to JSON = %q, want %q", got.Code, back.Code, want)
+		}
+	}
+}
@@ -0,0 +1,1226 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxYAMLDepth bounds nesting so hostile input cannot exhaust the stack
+const maxYAMLDepth = 1000
+
+func yamlErrorf(src string, offset int, format string, args ...interface{}) error {
+	return newSyntaxError(syntaxYAML, src, offset, format, args...)
+}
+
+type yamlKind int
+
+const (
+	yamlScalar yamlKind = iota
+	yamlMapping
+	yamlSequence
+	yamlAlias
+)
+
+type yamlStyle int
+
+const (
+	yamlPlain yamlStyle = iota
+	yamlSingleQuoted
+	yamlDoubleQuoted
+	yamlLiteral
+	yamlFolded
+)
+
+// yamlComment is a comment on a line of its own
+type yamlComment struct {
+	text  string
+	blank bool // a blank line comes before it
+}
+
+// yamlNode is a node of a YAML document. Scalars keep the text they were
+// written with, so the formatter prints them unchanged and the converter
+// decodes them on demand.
+type yamlNode struct {
+	kind   yamlKind
+	style  yamlStyle
+	flow   bool
+	anchor string
+	tag    string
+	// text holds a flow scalar's lines without their indentation, or the
+	// name an alias refers to
+	text string
+	// chomp and lines are a block scalar's chomping indicator and its
+	// content lines relative to the content indentation
+	chomp byte
+	lines []string
+	// raw is the source of a flow collection holding comments, which is
+	// printed as written
+	raw     string
+	entries []*yamlEntry
+	// foot holds the comments after the last entry of a block collection
+	// that are indented like its entries
+	foot []yamlComment
+	// comment trails the line the node ends on, the header of a block
+	// scalar, or the properties of a block collection
+	comment string
+	offset  int
+}
+
+// yamlEntry is a mapping pair or a sequence item. Items have no key,
+// except the single-pair mappings written inside flow sequences.
+type yamlEntry struct {
+	key      *yamlNode
+	value    *yamlNode // nil when the value is empty
+	comments []yamlComment
+	blank    bool // a blank line comes right before the entry
+	// comment trails the indicator when the value starts on a later line
+	comment string
+}
+
+// yamlDocument is one document of a stream. head holds the comments before
+// the start marker and comments those between it and the root node.
+type yamlDocument struct {
+	head         []yamlComment
+	directives   []string
+	start        bool
+	startComment string
+	comments     []yamlComment
+	blank        bool
+	root         *yamlNode
+	foot         []yamlComment
+	end          bool
+	endComment   string
+}
+
+type yamlStream struct {
+	docs     []*yamlDocument
+	comments int
+}
+
+// Where a block node starts decides what it may be
+const (
+	yamlLineStart = iota // first on its line: anything
+	yamlAfterDash        // after "- ", where compact collections may start
+	yamlAfterKey         // after "key: " or "---": no block collection
+)
+
+// pendingComment is a comment read but not yet given to a node
+type pendingComment struct {
+	yamlComment
+	col int
+}
+
+type yamlParser struct {
+	src      string
+	pos      int
+	depth    int
+	pending  []pendingComment
+	blank    bool // a blank line was skipped since the last comment or node
+	comments int
+	anchors  map[string]bool
+	// literals holds the content of the block scalars read, with the line
+	// breaks that belong to their values
+	literals []textSpan
+}
+
+// parseYAML reads a YAML stream, keeping the comments and the way scalars
+// are written. Line breaks are read as \n; offsets in errors still count
+// the \r of \r\n.
+func parseYAML(src string) (*yamlStream, error) {
+	text := strings.ReplaceAll(src, "\r\n", "\n")
+	p := &yamlParser{src: text}
+	stream, err := p.stream()
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) && len(text) != len(src) {
+		syntaxErr.Offset = crlfOffset(src, syntaxErr.Offset)
+	}
+	return stream, err
+}
+
+// crlfOffset maps an offset into src with \r\n read as \n back to src
+func crlfOffset(src string, offset int) int {
+	i := 0
+	for n := 0; n < offset && i < len(src); n++ {
+		if src[i] == '\r' && i+1 < len(src) && src[i+1] == '\n' {
+			i++
+		}
+		i++
+	}
+	return i
+}
+
+func (p *yamlParser) errorf(offset int, format string, args ...interface{}) error {
+	return yamlErrorf(p.src, offset, format, args...)
+}
+
+func (p *yamlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+// col returns the column of p.pos. Indentation is spaces only, so bytes
+// and characters agree where it matters.
+func (p *yamlParser) col() int {
+	return p.pos - (strings.LastIndexByte(p.src[:p.pos], '\n') + 1)
+}
+
+// spaceAt reports whether the byte at i is white space or past the end
+func (p *yamlParser) spaceAt(i int) bool {
+	return i >= len(p.src) || p.src[i] == ' ' || p.src[i] == '\t' || p.src[i] == '\n'
+}
+
+func (p *yamlParser) skipSpaces() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *yamlParser) lineEnd() int {
+	if end := strings.IndexByte(p.src[p.pos:], '\n'); end >= 0 {
+		return p.pos + end
+	}
+	return len(p.src)
+}
+
+// describe names the character at p.pos for error messages
+func (p *yamlParser) describe() string {
+	if p.eof() {
+		return "end of input"
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	return fmt.Sprintf("%q", r)
+}
+
+func (p *yamlParser) atMarker(marker string) bool {
+	return p.col() == 0 && strings.HasPrefix(p.src[p.pos:], marker) && p.spaceAt(p.pos+3)
+}
+
+func (p *yamlParser) atDocumentMarker() bool {
+	return p.atMarker("---") || p.atMarker("...")
+}
+
+func (p *yamlParser) atSequenceEntry() bool {
+	return !p.eof() && p.src[p.pos] == '-' && p.spaceAt(p.pos+1)
+}
+
+// skipToContent moves to the next content, collecting the comments on the
+// way for the node that follows them
+func (p *yamlParser) skipToContent() error {
+	for p.pos < len(p.src) {
+		lineStart := p.pos == 0 || p.src[p.pos-1] == '\n'
+		start := p.pos
+		p.skipSpaces()
+		switch {
+		case p.eof():
+			return nil
+		case p.src[p.pos] == '\n':
+			if lineStart {
+				p.blank = true
+			}
+			p.pos++
+		case p.src[p.pos] == '#':
+			end := p.lineEnd()
+			p.pending = append(p.pending, pendingComment{
+				yamlComment{strings.TrimRight(p.src[p.pos:end], " \t"), p.blank},
+				p.col(),
+			})
+			p.comments++
+			p.blank = false
+			p.pos = end
+		default:
+			if lineStart && strings.ContainsRune(p.src[start:p.pos], '\t') {
+				return p.errorf(start, "tabs cannot be used for indentation")
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// takeComments hands the collected comments to the node that follows
+// them, with whether a blank line separates them from it
+func (p *yamlParser) takeComments() ([]yamlComment, bool) {
+	var comments []yamlComment
+	for _, c := range p.pending {
+		comments = append(comments, c.yamlComment)
+	}
+	blank := p.blank
+	p.pending, p.blank = nil, false
+	return comments, blank
+}
+
+// footComments takes the collected comments indented at least as far as
+// the entries of a collection that has just ended
+func (p *yamlParser) footComments(indent int) []yamlComment {
+	n := 0
+	for n < len(p.pending) && p.pending[n].col >= indent {
+		n++
+	}
+	var foot []yamlComment
+	for _, c := range p.pending[:n] {
+		foot = append(foot, c.yamlComment)
+	}
+	p.pending = p.pending[n:]
+	return foot
+}
+
+// lineComment reads the rest of the line after a node: white space and
+// perhaps a comment
+func (p *yamlParser) lineComment() (string, error) {
+	p.skipSpaces()
+	switch {
+	case p.eof() || p.src[p.pos] == '\n':
+		return "", nil
+	case p.src[p.pos] == '#':
+		end := p.lineEnd()
+		comment := strings.TrimRight(p.src[p.pos:end], " \t")
+		p.comments++
+		p.pos = end
+		return comment, nil
+	case p.src[p.pos] == ':':
+		return "", p.errorf(p.pos, "mapping values are not allowed here")
+	}
+	return "", p.errorf(p.pos, "unexpected %s", p.describe())
+}
+
+func (p *yamlParser) stream() (*yamlStream, error) {
+	if strings.HasPrefix(p.src, "\uFEFF") {
+		p.pos = len("\uFEFF")
+	}
+	s := &yamlStream{}
+	for {
+		doc, err := p.document()
+		if err != nil {
+			return nil, err
+		}
+		s.docs = append(s.docs, doc)
+		if p.eof() {
+			break
+		}
+	}
+	s.comments = p.comments
+	return s, nil
+}
+
+func (p *yamlParser) document() (*yamlDocument, error) {
+	doc := &yamlDocument{}
+	p.anchors = make(map[string]bool)
+	if err := p.skipToContent(); err != nil {
+		return nil, err
+	}
+	for !p.eof() && p.col() == 0 && p.src[p.pos] == '%' {
+		if len(doc.directives) == 0 {
+			doc.head, _ = p.takeComments()
+		}
+		end := p.lineEnd()
+		doc.directives = append(doc.directives, strings.TrimRight(p.src[p.pos:end], " \t"))
+		p.pos = end
+		if err := p.skipToContent(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.atMarker("---") {
+		if len(doc.directives) == 0 {
+			doc.head, _ = p.takeComments()
+		}
+		doc.start = true
+		p.pos += 3
+		p.skipSpaces()
+		if !p.eof() && p.src[p.pos] != '\n' && p.src[p.pos] != '#' {
+			// The root starts on the marker line
+			root, err := p.node(-1, yamlAfterKey)
+			if err != nil {
+				return nil, err
+			}
+			doc.root = root
+		} else {
+			comment, err := p.lineComment()
+			if err != nil {
+				return nil, err
+			}
+			doc.startComment = comment
+		}
+	} else if len(doc.directives) > 0 {
+		return nil, p.errorf(p.pos, "directives must be followed by ---")
+	}
+
+	if doc.root == nil {
+		if err := p.skipToContent(); err != nil {
+			return nil, err
+		}
+		if !p.eof() && !p.atDocumentMarker() {
+			doc.comments, doc.blank = p.takeComments()
+			root, err := p.node(-1, yamlLineStart)
+			if err != nil {
+				return nil, err
+			}
+			doc.root = root
+		}
+	}
+
+	if err := p.skipToContent(); err != nil {
+		return nil, err
+	}
+	doc.foot, _ = p.takeComments()
+	if p.atMarker("...") {
+		doc.end = true
+		p.pos += 3
+		comment, err := p.lineComment()
+		if err != nil {
+			return nil, err
+		}
+		doc.endComment = comment
+		if err := p.skipToContent(); err != nil {
+			return nil, err
+		}
+	}
+	if !p.eof() && !p.atMarker("---") && !doc.end {
+		return nil, p.errorf(p.pos, "unexpected %s after the document", p.describe())
+	}
+	return doc, nil
+}
+
+// node parses a block node starting at p.pos. parent is the indentation
+// of the collection holding it, which the node's lines must exceed.
+func (p *yamlParser) node(parent int, mode int) (*yamlNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxYAMLDepth {
+		return nil, p.errorf(p.pos, "exceeded max nesting depth")
+	}
+
+	col := p.col()
+	if mode != yamlAfterKey {
+		if key, ok := p.implicitKey(); ok {
+			return p.mapping(col, key)
+		}
+	}
+
+	start := p.pos
+	anchor, tag, err := p.properties()
+	if err != nil {
+		return nil, err
+	}
+	p.define(anchor)
+	var n *yamlNode
+	if (anchor != "" || tag != "") && (p.eof() || p.src[p.pos] == '\n' || p.src[p.pos] == '#') {
+		// Properties on a line of their own apply to the node below them
+		comment, err := p.lineComment()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.skipToContent(); err != nil {
+			return nil, err
+		}
+		c := p.col()
+		if !p.eof() && !p.atDocumentMarker() && (c > parent || c == parent && mode == yamlAfterKey && p.atSequenceEntry()) {
+			if n, err = p.node(parent, yamlLineStart); err != nil {
+				return nil, err
+			}
+			if n.anchor != "" || n.tag != "" {
+				return nil, p.errorf(n.offset, "a node cannot have properties twice")
+			}
+		} else {
+			n = &yamlNode{kind: yamlScalar, offset: start}
+		}
+		n.anchor, n.tag = anchor, tag
+		if comment != "" {
+			n.comment = comment
+		}
+		return n, nil
+	}
+
+	if p.eof() {
+		n = &yamlNode{kind: yamlScalar, offset: p.pos}
+	} else {
+		switch c := p.src[p.pos]; {
+		case c == '*':
+			if anchor != "" || tag != "" {
+				return nil, p.errorf(p.pos, "an alias cannot have properties")
+			}
+			n, err = p.alias()
+		case c == '-' && p.spaceAt(p.pos+1):
+			if mode == yamlAfterKey || anchor != "" || tag != "" {
+				return nil, p.errorf(p.pos, "block sequence entries are not allowed here")
+			}
+			return p.sequence(col)
+		case c == '?' && p.spaceAt(p.pos+1):
+			return nil, p.errorf(p.pos, "complex mapping keys are not supported")
+		case c == '|' || c == '>':
+			n, err = p.blockScalar(parent)
+			if err == nil {
+				n.anchor, n.tag = anchor, tag
+			}
+			return n, err
+		case c == '[' || c == '{':
+			n, err = p.flowCollection()
+		case c == '"' || c == '\'':
+			n, err = p.quoted()
+		default:
+			n, err = p.plain(parent, false)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	n.anchor, n.tag = anchor, tag
+	if n.comment, err = p.lineComment(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// implicitKey parses a mapping key written on one line and the ':' after
+// it. p.pos is left unchanged when there is none.
+func (p *yamlParser) implicitKey() (*yamlNode, bool) {
+	start := p.pos
+	fail := func() (*yamlNode, bool) {
+		p.pos = start
+		return nil, false
+	}
+
+	anchor, tag, err := p.properties()
+	if err != nil || p.eof() {
+		return fail()
+	}
+	var key *yamlNode
+	switch c := p.src[p.pos]; c {
+	case '*':
+		key, err = p.alias()
+	case '"', '\'':
+		key, err = p.quoted()
+		if err == nil && strings.Contains(key.text, "\n") {
+			return fail()
+		}
+	default:
+		if !p.plainStart(false) {
+			return fail()
+		}
+		key = &yamlNode{kind: yamlScalar, offset: p.pos, text: p.plainLine(false)}
+	}
+	if err != nil {
+		return fail()
+	}
+	p.skipSpaces()
+	if p.eof() || p.src[p.pos] != ':' || !p.spaceAt(p.pos+1) {
+		return fail()
+	}
+	p.pos++
+	p.define(anchor)
+	key.anchor, key.tag = anchor, tag
+	return key, true
+}
+
+// properties reads the anchor and tag written before a node, in either
+// order
+func (p *yamlParser) properties() (anchor, tag string, err error) {
+	for !p.eof() {
+		start := p.pos
+		switch p.src[p.pos] {
+		case '&':
+			if anchor != "" {
+				return "", "", p.errorf(start, "a node cannot have two anchors")
+			}
+			p.pos++
+			if anchor = p.name(); anchor == "" {
+				return "", "", p.errorf(start, "expected an anchor name")
+			}
+		case '!':
+			if tag != "" {
+				return "", "", p.errorf(start, "a node cannot have two tags")
+			}
+			if strings.HasPrefix(p.src[p.pos:], "!<") {
+				end := strings.IndexByte(p.src[p.pos:p.lineEnd()], '>')
+				if end < 0 {
+					return "", "", p.errorf(start, "unclosed verbatim tag")
+				}
+				p.pos += end + 1
+			} else {
+				p.pos++
+				p.name()
+			}
+			tag = p.src[start:p.pos]
+		default:
+			return anchor, tag, nil
+		}
+		p.skipSpaces()
+	}
+	return anchor, tag, nil
+}
+
+// define makes an anchor known to the aliases after it. A node's anchor
+// is defined before its content is read.
+func (p *yamlParser) define(anchor string) {
+	if anchor != "" {
+		p.anchors[anchor] = true
+	}
+}
+
+// name reads an anchor name or the rest of a tag, which run up to white
+// space or a flow indicator
+func (p *yamlParser) name() string {
+	start := p.pos
+	for p.pos < len(p.src) && !p.spaceAt(p.pos) && !isYAMLFlowIndicator(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func isYAMLFlowIndicator(c byte) bool {
+	return c == ',' || c == '[' || c == ']' || c == '{' || c == '}'
+}
+
+func (p *yamlParser) alias() (*yamlNode, error) {
+	start := p.pos
+	p.pos++
+	name := p.name()
+	if name == "" {
+		return nil, p.errorf(start, "expected an alias name")
+	}
+	if !p.anchors[name] {
+		return nil, p.errorf(start, "unknown anchor %q", name)
+	}
+	return &yamlNode{kind: yamlAlias, text: name, offset: start}, nil
+}
+
+func (p *yamlParser) mapping(indent int, key *yamlNode) (*yamlNode, error) {
+	m := &yamlNode{kind: yamlMapping, offset: key.offset}
+	comments, blank := p.takeComments()
+	for {
+		entry := &yamlEntry{key: key, comments: comments, blank: blank}
+		if err := p.value(indent, entry, false); err != nil {
+			return nil, err
+		}
+		m.entries = append(m.entries, entry)
+		if err := p.skipToContent(); err != nil {
+			return nil, err
+		}
+		if p.eof() || p.atDocumentMarker() || p.col() < indent {
+			break
+		}
+		if p.col() > indent {
+			return nil, p.errorf(p.pos, "bad indentation of a mapping entry")
+		}
+		var ok bool
+		if key, ok = p.implicitKey(); !ok {
+			if p.atSequenceEntry() {
+				return nil, p.errorf(p.pos, "expected a mapping key, found a sequence entry")
+			}
+			return nil, p.errorf(p.pos, "could not find expected ':'")
+		}
+		comments, blank = p.takeComments()
+	}
+	m.foot = p.footComments(indent)
+	return m, nil
+}
+
+func (p *yamlParser) sequence(indent int) (*yamlNode, error) {
+	s := &yamlNode{kind: yamlSequence, offset: p.pos}
+	comments, blank := p.takeComments()
+	for {
+		p.pos++
+		entry := &yamlEntry{comments: comments, blank: blank}
+		if err := p.value(indent, entry, true); err != nil {
+			return nil, err
+		}
+		s.entries = append(s.entries, entry)
+		if err := p.skipToContent(); err != nil {
+			return nil, err
+		}
+		if p.eof() || p.atDocumentMarker() || p.col() < indent {
+			s.foot = p.footComments(indent)
+			break
+		}
+		if p.col() > indent {
+			return nil, p.errorf(p.pos, "bad indentation of a sequence entry")
+		}
+		if !p.atSequenceEntry() {
+			// The next key of the mapping the sequence is a value of
+			break
+		}
+		comments, blank = p.takeComments()
+	}
+	return s, nil
+}
+
+// value parses what follows a mapping ':' or a sequence '-'. A value
+// starting on a later line must be indented past the entry, except that a
+// sequence may line up with the key it belongs to.
+func (p *yamlParser) value(indent int, entry *yamlEntry, seq bool) error {
+	p.skipSpaces()
+	if p.eof() || p.src[p.pos] == '\n' || p.src[p.pos] == '#' {
+		comment, err := p.lineComment()
+		if err != nil {
+			return err
+		}
+		entry.comment = comment
+		if err := p.skipToContent(); err != nil {
+			return err
+		}
+		if p.eof() || p.atDocumentMarker() {
+			return nil
+		}
+		if col := p.col(); col > indent || !seq && col == indent && p.atSequenceEntry() {
+			entry.value, err = p.node(indent, yamlLineStart)
+		}
+		return err
+	}
+	mode := yamlAfterKey
+	if seq {
+		mode = yamlAfterDash
+	}
+	var err error
+	entry.value, err = p.node(indent, mode)
+	return err
+}
+
+// plainStart reports whether a plain scalar may start at p.pos
+func (p *yamlParser) plainStart(flow bool) bool {
+	switch c := p.src[p.pos]; c {
+	case '-', '?', ':':
+		return !p.spaceAt(p.pos+1) && !(flow && isYAMLFlowIndicator(p.src[p.pos+1]))
+	case ',', '[', ']', '{', '}', '#', '&', '*', '!', '|', '>', '\'', '"', '%', '@', '`', ' ', '\t', '\n':
+		return false
+	}
+	return true
+}
+
+// plainLine scans a plain scalar to the end of its line, or to ": ", " #"
+// or, in a flow collection, a flow indicator
+func (p *yamlParser) plainLine(flow bool) string {
+	start, end := p.pos, p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '\n' ||
+			c == ':' && (p.spaceAt(p.pos+1) || flow && isYAMLFlowIndicator(p.src[p.pos+1])) ||
+			c == '#' && p.pos > start && (p.src[p.pos-1] == ' ' || p.src[p.pos-1] == '\t') ||
+			flow && isYAMLFlowIndicator(c) {
+			break
+		}
+		p.pos++
+		if c != ' ' && c != '\t' {
+			end = p.pos
+		}
+	}
+	p.pos = end
+	return p.src[start:end]
+}
+
+// plain parses a plain scalar, which continues on the following lines
+// that are indented past parent and are not comments
+func (p *yamlParser) plain(parent int, flow bool) (*yamlNode, error) {
+	if !p.plainStart(flow) {
+		return nil, p.errorf(p.pos, "unexpected %s", p.describe())
+	}
+	n := &yamlNode{kind: yamlScalar, offset: p.pos}
+	lines := []string{p.plainLine(flow)}
+	for {
+		save := p.pos
+		p.skipSpaces()
+		if p.eof() || p.src[p.pos] != '\n' {
+			p.pos = save
+			break
+		}
+		// Find the next line with content
+		blanks := 0
+		lineStart := p.pos + 1
+		i := lineStart
+		for i < len(p.src) && (p.src[i] == ' ' || p.src[i] == '\t') {
+			i++
+		}
+		for i < len(p.src) && p.src[i] == '\n' {
+			blanks++
+			lineStart = i + 1
+			for i = lineStart; i < len(p.src) && (p.src[i] == ' ' || p.src[i] == '\t'); i++ {
+			}
+		}
+		if i >= len(p.src) || p.src[i] == '#' || !flow && i-lineStart <= parent {
+			p.pos = save
+			break
+		}
+		p.pos = i
+		if i == lineStart && p.atDocumentMarker() {
+			p.pos = save
+			break
+		}
+		line := p.plainLine(flow)
+		if line == "" {
+			p.pos = save
+			break
+		}
+		for ; blanks > 0; blanks-- {
+			lines = append(lines, "")
+		}
+		lines = append(lines, line)
+	}
+	n.text = strings.Join(lines, "\n")
+	return n, nil
+}
+
+// quoted parses a single- or double-quoted scalar. Its lines are kept
+// without the white space that folding drops around line breaks.
+func (p *yamlParser) quoted() (*yamlNode, error) {
+	start := p.pos
+	q := p.src[p.pos]
+	p.pos++
+	for {
+		if p.eof() {
+			return nil, p.errorf(start, "unterminated quoted scalar")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == q && q == '\'' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '\'':
+			p.pos += 2
+			continue
+		case c == '\\' && q == '"':
+			p.pos += 2
+			continue
+		case c == q:
+			p.pos++
+		case c == '\n':
+			p.pos++
+			if p.atDocumentMarker() {
+				return nil, p.errorf(start, "unterminated quoted scalar")
+			}
+			continue
+		default:
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	lines := strings.Split(p.src[start:p.pos], "\n")
+	for i, line := range lines {
+		if i > 0 {
+			line = strings.TrimLeft(line, " \t")
+		}
+		if i < len(lines)-1 {
+			line = trimQuotedLine(line, q == '"')
+		}
+		lines[i] = line
+	}
+	n := &yamlNode{kind: yamlScalar, style: yamlSingleQuoted, text: strings.Join(lines, "\n"), offset: start}
+	if q == '"' {
+		n.style = yamlDoubleQuoted
+		// Check the escapes now, so errors point into the source
+		if _, err := n.scalarValue(); err != nil {
+			return nil, p.errorf(start, "%v", err)
+		}
+	}
+	return n, nil
+}
+
+// trimQuotedLine drops the white space before a line break inside a quoted
+// scalar, except a character escaped by a backslash
+func trimQuotedLine(line string, double bool) string {
+	trimmed := strings.TrimRight(line, " \t")
+	if double && len(trimmed) < len(line) && trailingBackslashes(trimmed)%2 == 1 {
+		return line[:len(trimmed)+1]
+	}
+	return trimmed
+}
+
+func trailingBackslashes(s string) int {
+	n := 0
+	for n < len(s) && s[len(s)-1-n] == '\\' {
+		n++
+	}
+	return n
+}
+
+// blockScalar parses a literal or folded scalar. Its indentation is given
+// by the header or by the first line with content.
+func (p *yamlParser) blockScalar(parent int) (*yamlNode, error) {
+	n := &yamlNode{kind: yamlScalar, style: yamlLiteral, offset: p.pos}
+	if p.src[p.pos] == '>' {
+		n.style = yamlFolded
+	}
+	p.pos++
+	explicit := 0
+	for !p.eof() && strings.IndexByte("+-123456789", p.src[p.pos]) >= 0 {
+		c := p.src[p.pos]
+		switch {
+		case c == '+' || c == '-':
+			if n.chomp != 0 {
+				return nil, p.errorf(p.pos, "repeated chomping indicator")
+			}
+			n.chomp = c
+		case explicit != 0:
+			return nil, p.errorf(p.pos, "repeated indentation indicator")
+		default:
+			explicit = int(c - '0')
+		}
+		p.pos++
+	}
+	if !p.eof() && p.src[p.pos] != ' ' && p.src[p.pos] != '\t' && p.src[p.pos] != '\n' {
+		return nil, p.errorf(p.pos, "unexpected %s in block scalar header", p.describe())
+	}
+	comment, err := p.lineComment()
+	if err != nil {
+		return nil, err
+	}
+	n.comment = comment
+	if p.eof() {
+		return n, nil
+	}
+	p.pos++
+
+	indent := explicit + max(parent, 0)
+	if explicit == 0 {
+		// The first line with content sets the indentation
+		for i := p.pos; i < len(p.src); {
+			j := i
+			for j < len(p.src) && p.src[j] == ' ' {
+				j++
+			}
+			if j < len(p.src) && p.src[j] != '\n' {
+				indent = j - i
+				break
+			}
+			i = j + 1
+		}
+		indent = max(indent, parent+1, 1)
+	}
+
+	var lines []string
+	start, contentEnd := p.pos, -1
+	for !p.eof() {
+		lineStart := p.pos
+		end := p.lineEnd()
+		line := p.src[lineStart:end]
+		spaces := len(line) - len(strings.TrimLeft(line, " "))
+		switch {
+		case spaces >= indent:
+			lines = append(lines, line[indent:])
+			if strings.TrimLeft(line, " \t") != "" {
+				contentEnd = end
+			}
+		case strings.TrimLeft(line, " \t") == "":
+			lines = append(lines, "")
+		default:
+			p.pos = lineStart
+			goto done
+		}
+		p.pos = end
+		if !p.eof() {
+			p.pos++
+		}
+	}
+done:
+	// Trailing empty lines belong to the scalar only when kept
+	trailing := len(lines)
+	for trailing > 0 && strings.TrimLeft(lines[trailing-1], " \t") == "" {
+		trailing--
+	}
+	if n.chomp != '+' {
+		if trailing < len(lines) {
+			p.blank = true
+		}
+		lines = lines[:trailing]
+	}
+	n.lines = lines
+
+	switch {
+	case n.chomp == '+':
+		p.literals = append(p.literals, textSpan{start, p.pos})
+	case contentEnd < 0:
+	case n.chomp == '-' || contentEnd == len(p.src):
+		p.literals = append(p.literals, textSpan{start, contentEnd})
+	default:
+		// A clipped scalar keeps its final line break
+		p.literals = append(p.literals, textSpan{start, contentEnd + 1})
+	}
+	return n, nil
+}
+
+// flowCollection parses a [sequence] or {mapping}, which may span lines
+func (p *yamlParser) flowCollection() (*yamlNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxYAMLDepth {
+		return nil, p.errorf(p.pos, "exceeded max nesting depth")
+	}
+
+	start := p.pos
+	comments := p.comments
+	n := &yamlNode{kind: yamlSequence, flow: true, offset: start}
+	closer := byte(']')
+	if p.src[p.pos] == '{' {
+		n.kind, closer = yamlMapping, '}'
+	}
+	p.pos++
+	for {
+		if err := p.skipFlowSpace(); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return nil, p.errorf(start, "unclosed %c", p.src[start])
+		}
+		if p.src[p.pos] == closer {
+			p.pos++
+			break
+		}
+		entry, err := p.flowEntry(n.kind == yamlMapping, closer)
+		if err != nil {
+			return nil, err
+		}
+		n.entries = append(n.entries, entry)
+		if err := p.skipFlowSpace(); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return nil, p.errorf(start, "unclosed %c", p.src[start])
+		}
+		if p.src[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		if p.src[p.pos] != closer {
+			return nil, p.errorf(p.pos, "expected ',' or '%c', found %s", closer, p.describe())
+		}
+	}
+	if p.comments > comments {
+		n.raw = p.src[start:p.pos]
+	}
+	return n, nil
+}
+
+// skipFlowSpace skips white space, line breaks and comments inside a flow
+// collection
+func (p *yamlParser) skipFlowSpace() error {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			p.pos++
+		case c == '#':
+			p.comments++
+			p.pos = p.lineEnd()
+		default:
+			if p.atDocumentMarker() {
+				return p.errorf(p.pos, "document marker inside a flow collection")
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// flowEntry parses an entry of a flow collection: a value, or a key with
+// an optional ':' and value
+func (p *yamlParser) flowEntry(mapping bool, closer byte) (*yamlEntry, error) {
+	if p.src[p.pos] == '?' && p.spaceAt(p.pos+1) {
+		return nil, p.errorf(p.pos, "complex mapping keys are not supported")
+	}
+	node, err := p.flowNode()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.skipFlowSpace(); err != nil {
+		return nil, err
+	}
+	// JSON-like keys may be followed by ':' directly
+	adjacent := node.kind != yamlScalar || node.style != yamlPlain
+	entry := &yamlEntry{}
+	if !p.eof() && p.src[p.pos] == ':' && (adjacent || p.pos+1 >= len(p.src) || p.spaceAt(p.pos+1) || isYAMLFlowIndicator(p.src[p.pos+1])) {
+		p.pos++
+		entry.key = node
+		if err := p.skipFlowSpace(); err != nil {
+			return nil, err
+		}
+		if !p.eof() && p.src[p.pos] != ',' && p.src[p.pos] != closer {
+			if entry.value, err = p.flowNode(); err != nil {
+				return nil, err
+			}
+		}
+		return entry, nil
+	}
+	if mapping {
+		entry.key = node
+	} else {
+		entry.value = node
+	}
+	return entry, nil
+}
+
+func (p *yamlParser) flowNode() (*yamlNode, error) {
+	start := p.pos
+	anchor, tag, err := p.properties()
+	if err != nil {
+		return nil, err
+	}
+	p.define(anchor)
+	if err := p.skipFlowSpace(); err != nil {
+		return nil, err
+	}
+	if p.eof() {
+		return nil, p.errorf(start, "unexpected end of input")
+	}
+	var n *yamlNode
+	switch c := p.src[p.pos]; {
+	case c == '*':
+		n, err = p.alias()
+	case c == '[' || c == '{':
+		n, err = p.flowCollection()
+	case c == '"' || c == '\'':
+		n, err = p.quoted()
+	case (anchor != "" || tag != "") && (c == ',' || c == ']' || c == '}' || c == ':'):
+		n = &yamlNode{kind: yamlScalar, offset: start}
+	default:
+		n, err = p.plain(-1, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n.kind == yamlAlias && (anchor != "" || tag != "") {
+		return nil, p.errorf(start, "an alias cannot have properties")
+	}
+	n.anchor, n.tag = anchor, tag
+	return n, nil
+}
+
+// scalarValue decodes the content of a scalar node
+func (n *yamlNode) scalarValue() (string, error) {
+	switch n.style {
+	case yamlSingleQuoted:
+		lines := strings.Split(n.text[1:len(n.text)-1], "\n")
+		return strings.ReplaceAll(foldFlowLines(lines, false), "''", "'"), nil
+	case yamlDoubleQuoted:
+		lines := strings.Split(n.text[1:len(n.text)-1], "\n")
+		return unescapeYAML(foldFlowLines(lines, true))
+	case yamlLiteral, yamlFolded:
+		return n.blockValue(), nil
+	}
+	return foldFlowLines(strings.Split(n.text, "\n"), false), nil
+}
+
+// foldFlowLines joins the lines of a plain or quoted scalar. A line break
+// reads as a space, unless empty lines follow it, which read as line
+// breaks. In double quotes a backslash at the end of a line joins it to
+// the next one directly.
+func foldFlowLines(lines []string, double bool) string {
+	var b strings.Builder
+	breaks := 0
+	for i, line := range lines {
+		if i > 0 && line == "" && i < len(lines)-1 {
+			breaks++
+			continue
+		}
+		if i > 0 {
+			switch {
+			case breaks > 0:
+				b.WriteString(strings.Repeat("\n", breaks))
+			case double && trailingBackslashes(lines[i-1])%2 == 1:
+				joined := b.String()
+				b.Reset()
+				b.WriteString(joined[:len(joined)-1])
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		breaks = 0
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// yamlEscapes maps the single-character escapes of double-quoted scalars
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+	'/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+	'P': "\u2029",
+}
+
+// unescapeYAML decodes the escape sequences of a double-quoted scalar
+func unescapeYAML(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("unterminated escape sequence")
+		}
+		if text, ok := yamlEscapes[s[i]]; ok {
+			b.WriteString(text)
+			continue
+		}
+		size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+		if size == 0 {
+			return "", fmt.Errorf("invalid escape sequence \\%c", s[i])
+		}
+		if i+size >= len(s) || !isHexString(s[i+1:i+1+size]) {
+			return "", fmt.Errorf("invalid escape sequence \\%c", s[i])
+		}
+		code, _ := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+		if code > utf8.MaxRune {
+			return "", fmt.Errorf("invalid escape sequence \\%s", s[i:i+1+size])
+		}
+		b.WriteRune(rune(code))
+		i += size
+	}
+	return b.String(), nil
+}
+
+// blockValue decodes a block scalar. Literal lines are kept as they are
+// and folded ones joined; the chomping indicator decides whether the
+// final line break and the empty lines after it are kept.
+func (n *yamlNode) blockValue() string {
+	body := len(n.lines)
+	for body > 0 && strings.TrimLeft(n.lines[body-1], " \t") == "" {
+		body--
+	}
+	var s string
+	if n.style == yamlLiteral {
+		s = strings.Join(n.lines[:body], "\n")
+	} else {
+		s = foldBlockLines(n.lines[:body])
+	}
+	switch {
+	case n.chomp == '+':
+		if body > 0 {
+			s += "\n"
+		}
+		return s + strings.Repeat("\n", len(n.lines)-body)
+	case n.chomp == '-' || body == 0:
+		return s
+	}
+	return s + "\n"
+}
+
+// foldBlockLines joins the lines of a folded scalar. Line breaks next to
+// more indented lines are kept, and each empty line reads as a line break.
+func foldBlockLines(lines []string) string {
+	var b strings.Builder
+	started, more := false, false
+	breaks := 0
+	for _, line := range lines {
+		if line == "" {
+			breaks++
+			continue
+		}
+		indented := line[0] == ' ' || line[0] == '\t'
+		switch {
+		case !started:
+			b.WriteString(strings.Repeat("\n", breaks))
+		case breaks == 0 && !indented && !more:
+			b.WriteByte(' ')
+		case indented || more:
+			b.WriteString(strings.Repeat("\n", breaks+1))
+		default:
+			b.WriteString(strings.Repeat("\n", breaks))
+		}
+		b.WriteString(line)
+		started, more, breaks = true, indented, 0
+	}
+	return b.String()
+}